@@ -0,0 +1,42 @@
+#!/bin/bash
+
+# Cross-compile the server for the platforms deployments run on
+# Usage: [VERSION=v1.4.0] ./build_release.sh [output_dir]
+#
+# The default build (Vosk server or AssemblyAI providers) is pure Go and
+# cross-compiles without a C toolchain. The in-process "vosk_local" provider
+# needs cgo, a C compiler for the target and libvosk built for it, e.g. for an
+# ARM edge box:
+#
+#   CGO_ENABLED=1 CC=aarch64-linux-gnu-gcc \
+#   CGO_CFLAGS="-I/opt/vosk-linux-aarch64" CGO_LDFLAGS="-L/opt/vosk-linux-aarch64" \
+#   GOOS=linux GOARCH=arm64 go build -tags vosk -o server ./cmd/server
+#
+# Ogg Opus live listening (-tags opus) likewise needs cgo and libopus.
+
+OUT_DIR="${1:-dist}"
+VERSION="${VERSION:-$(git describe --tags --always --dirty 2>/dev/null || echo dev)}"
+COMMIT="$(git rev-parse HEAD 2>/dev/null)"
+DATE="$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+PKG="github.com/amanullahtanweer/audiosocket-transcriber/internal/buildinfo"
+LDFLAGS="-X $PKG.Version=$VERSION -X $PKG.Commit=$COMMIT -X $PKG.Date=$DATE"
+PLATFORMS="linux/amd64 linux/arm64 linux/arm windows/amd64 darwin/arm64"
+
+mkdir -p "$OUT_DIR"
+
+for PLATFORM in $PLATFORMS; do
+    GOOS="${PLATFORM%/*}"
+    GOARCH="${PLATFORM#*/}"
+    OUTPUT="$OUT_DIR/audiosocket-transcriber-$GOOS-$GOARCH"
+    if [ "$GOOS" = "windows" ]; then
+        OUTPUT="$OUTPUT.exe"
+    fi
+
+    echo "Building $OUTPUT"
+    if ! CGO_ENABLED=0 GOOS=$GOOS GOARCH=$GOARCH go build -ldflags "$LDFLAGS" -o "$OUTPUT" ./cmd/server; then
+        echo "Build failed for $PLATFORM"
+        exit 1
+    fi
+done
+
+echo "Binaries for $VERSION written to $OUT_DIR"
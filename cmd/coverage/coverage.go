@@ -0,0 +1,466 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+// outcomes lists the transition keys the engine follows for each node type.
+// Question nodes are not checked: they also follow any result a classifier
+// hook returns. Audio nodes also follow "background:<environment>", condition
+// nodes the transitions of their conditions.
+var outcomes = map[string][]string{
+	"audio":             {"default"},
+	"question":          {string(flow.ResponsePositive), string(flow.ResponseNegative), string(flow.ResponseUnknown), "timeout", "escalation", "long_winded", flow.ExtractMatched, flow.ExtractNoMatch, "default"},
+	"survey":            {flow.SurveyAllPositive, flow.SurveyAnyNegative, flow.SurveyIncomplete, "default"},
+	"collect_digits":    {flow.DigitsCollected, flow.DigitsInvalid, flow.DigitsTimeout, "default"},
+	"schedule_callback": {flow.CallbackConfirmed, flow.CallbackRejected, flow.CallbackUnclear, flow.CallbackTimeout, "default"},
+	"condition":         {"default"},
+	"interrupt":         {"default"},
+	"transfer":          {"failed", "no_agents"},
+	"hangup":            nil,
+	"flow":              nil,
+}
+
+// fallbackNode is where the engine goes when a timeout or digit outcome has
+// no transition
+const fallbackNode = "end_call"
+
+// Edge is one step between two nodes; Key is the transition key or, for the
+// fallback paths, the outcome that had no transition of its own
+type Edge struct {
+	From string
+	Key  string
+	To   string
+}
+
+// Coverage accumulates the nodes and transitions exercised by session logs
+type Coverage struct {
+	flow       *flow.FlowConfig
+	nodes      map[string]*flow.FlowNode
+	interrupts map[string]bool // interrupt rule keys; nil when unknown
+
+	Sessions int
+	Visits   map[string]int // node_start count per node
+	Reached  map[string]int // sessions that visited each node
+
+	Fired      map[Edge]int   // configured transitions that were followed
+	Fallbacks  map[Edge]int   // outcomes without a transition that fell back to default or end_call
+	Interrupts map[Edge]int   // interrupts: the node interrupted and the interrupt node entered
+	Errors     map[Edge]int   // flow errors: the node that failed and the error_fallback node entered
+	Unexpected map[Edge]int   // steps the flow does not explain (edited flow, other version)
+	Missing    map[string]int // visits to node IDs the flow does not have
+
+	OverBudget map[string][]int // elapsed ms of each over_budget record per node
+
+	Environments map[string]*EnvironmentStats // by classified caller background; "unknown" when none
+}
+
+// EnvironmentStats counts how calls from one caller background ended
+type EnvironmentStats struct {
+	Calls       int
+	Transferred int
+}
+
+// NewCoverage prepares a report for cfg. interruptKeys are the configured
+// interrupt rules, which enter the flow at the node of the same ID; pass nil
+// when they are not known.
+func NewCoverage(cfg *flow.FlowConfig, interruptKeys []string) *Coverage {
+	c := &Coverage{
+		flow:       cfg,
+		nodes:      make(map[string]*flow.FlowNode),
+		Visits:     make(map[string]int),
+		Reached:    make(map[string]int),
+		Fired:      make(map[Edge]int),
+		Fallbacks:  make(map[Edge]int),
+		Interrupts: make(map[Edge]int),
+		Errors:     make(map[Edge]int),
+		Unexpected: make(map[Edge]int),
+		Missing:    make(map[string]int),
+		OverBudget: make(map[string][]int),
+
+		Environments: make(map[string]*EnvironmentStats),
+	}
+	for i := range cfg.Nodes {
+		// The engine uses the first node with an ID
+		if _, ok := c.nodes[cfg.Nodes[i].ID]; !ok {
+			c.nodes[cfg.Nodes[i].ID] = &cfg.Nodes[i]
+		}
+	}
+	if interruptKeys != nil {
+		c.interrupts = make(map[string]bool)
+		for _, key := range interruptKeys {
+			c.interrupts[key] = true
+		}
+	}
+	return c
+}
+
+// Filter selects the sessions to count
+type Filter struct {
+	From, To time.Time // call start dates, inclusive; zero = open
+	Version  string    // flow version label from the flow_version record; empty = any
+}
+
+// logEvent is the part of a session log record the report uses
+type logEvent struct {
+	Timestamp string            `json:"ts"`
+	Event     string            `json:"event"`
+	NodeID    string            `json:"node_id"`
+	Details   map[string]string `json:"details"`
+}
+
+// AddSession reads one session log and counts it if it passes filter. It
+// reports whether the session was counted.
+func (c *Coverage) AddSession(r io.Reader, filter Filter) (bool, error) {
+	var events []logEvent
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev logEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	if len(events) == 0 || !filter.matches(events) {
+		return false, nil
+	}
+
+	c.Sessions++
+	reached := make(map[string]bool)
+	prev, key := "", ""
+	env, transferred := "unknown", false
+	chained := false // the rest of the session runs a follow-on flow
+	for _, ev := range events {
+		switch ev.Event {
+		case "background":
+			env = ev.Details["environment"]
+		case "flow_end":
+			transferred = ev.Details["reason"] == "transfer"
+		case "transition":
+			key = ev.Details["reason"]
+		case "timeout":
+			key = "timeout"
+		case "interrupt":
+			key = "interrupt"
+		case "flow_chain":
+			chained = true
+		case "over_budget":
+			if chained {
+				continue
+			}
+			if ms, err := strconv.Atoi(ev.Details["elapsed_ms"]); err == nil {
+				c.OverBudget[ev.NodeID] = append(c.OverBudget[ev.NodeID], ms)
+			}
+		case "node_start":
+			if chained {
+				continue
+			}
+			c.Visits[ev.NodeID]++
+			if !reached[ev.NodeID] {
+				reached[ev.NodeID] = true
+				c.Reached[ev.NodeID]++
+			}
+			if c.nodes[ev.NodeID] == nil {
+				c.Missing[ev.NodeID]++
+			}
+			if prev != "" {
+				c.step(prev, key, ev.NodeID)
+			}
+			prev, key = ev.NodeID, ""
+		}
+	}
+	stats := c.Environments[env]
+	if stats == nil {
+		stats = &EnvironmentStats{}
+		c.Environments[env] = stats
+	}
+	stats.Calls++
+	if transferred {
+		stats.Transferred++
+	}
+	return true, nil
+}
+
+// matches checks the session's start date (its first record) and flow version
+func (f Filter) matches(events []logEvent) bool {
+	started, err := time.Parse(time.RFC3339Nano, events[0].Timestamp)
+	if err != nil {
+		return false
+	}
+	day := time.Date(started.Year(), started.Month(), started.Day(), 0, 0, 0, 0, time.UTC)
+	if (!f.From.IsZero() && day.Before(f.From)) || (!f.To.IsZero() && day.After(f.To)) {
+		return false
+	}
+	if f.Version == "" {
+		return true
+	}
+	for _, ev := range events {
+		if ev.Event == "flow_version" {
+			return ev.Details["version"] == f.Version
+		}
+	}
+	return false
+}
+
+// step classifies one move from node from to node to. key is the reason
+// logged for it, empty when the engine logs none (audio and interrupt node
+// defaults, interrupts raised outside a question).
+func (c *Coverage) step(from, key, to string) {
+	node := c.nodes[from]
+	if node == nil {
+		c.Unexpected[Edge{From: from, Key: key, To: to}]++
+		return
+	}
+	if key == "interrupt" || (key == "" && c.isInterruptEntry(node, to)) {
+		c.Interrupts[Edge{From: from, To: to}]++
+		return
+	}
+	if fb := c.flow.Metadata.ErrorFallback; key == "error" && fb != nil && fb.Node == to {
+		c.Errors[Edge{From: from, To: to}]++
+		return
+	}
+	if key == "" {
+		key = "default"
+	}
+	switch {
+	case node.Transitions[key] == to && to != "":
+		c.Fired[Edge{From: from, Key: key, To: to}]++
+	case node.Transitions[key] == "" && node.Transitions["default"] == to && to != "":
+		c.Fired[Edge{From: from, Key: "default", To: to}]++
+		c.Fallbacks[Edge{From: from, Key: key, To: to}]++
+	case node.Transitions[key] == "" && node.Transitions["default"] == "" && to == fallbackNode:
+		c.Fallbacks[Edge{From: from, Key: key, To: to}]++
+	default:
+		c.Unexpected[Edge{From: from, Key: key, To: to}]++
+	}
+}
+
+// isInterruptEntry reports whether entering to from node without a logged
+// reason is an interrupt rather than the node's default transition
+func (c *Coverage) isInterruptEntry(node *flow.FlowNode, to string) bool {
+	if node.Transitions["default"] == to {
+		return false
+	}
+	if c.interrupts != nil {
+		return c.interrupts[to]
+	}
+	target := c.nodes[to]
+	return target != nil && target.Type == "interrupt"
+}
+
+// Offender is a node that exceeded its latency budget
+type Offender struct {
+	Node     string
+	BudgetMs int // from the flow; 0 when the node no longer has one
+	Count    int
+	MedianMs int
+	WorstMs  int
+}
+
+// Offenders ranks the nodes that went over budget, most often first and then
+// by their worst time
+func (c *Coverage) Offenders() []Offender {
+	offenders := make([]Offender, 0, len(c.OverBudget))
+	for id, elapsed := range c.OverBudget {
+		sorted := append([]int(nil), elapsed...)
+		sort.Ints(sorted)
+		o := Offender{Node: id, Count: len(sorted), MedianMs: sorted[len(sorted)/2], WorstMs: sorted[len(sorted)-1]}
+		if node := c.nodes[id]; node != nil {
+			o.BudgetMs = node.BudgetMs
+		}
+		offenders = append(offenders, o)
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		a, b := offenders[i], offenders[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.WorstMs != b.WorstMs {
+			return a.WorstMs > b.WorstMs
+		}
+		return a.Node < b.Node
+	})
+	return offenders
+}
+
+// Transitions returns the flow's configured transitions in flow order
+func (c *Coverage) Transitions() []Edge {
+	var edges []Edge
+	for _, node := range c.flow.Nodes {
+		keys := make([]string, 0, len(node.Transitions))
+		for key := range node.Transitions {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			edges = append(edges, Edge{From: node.ID, Key: key, To: node.Transitions[key]})
+		}
+	}
+	return edges
+}
+
+// Problems lists configuration mistakes found without looking at the logs:
+// transitions to nodes that do not exist or that the engine never follows,
+// outcomes a node cannot leave on, interrupt rules without a node, and nodes
+// nothing leads to.
+func (c *Coverage) Problems() []string {
+	var problems []string
+	seen := make(map[string]bool)
+	for _, node := range c.flow.Nodes {
+		if seen[node.ID] {
+			problems = append(problems, fmt.Sprintf("%s: duplicate node ID; only the first is used", node.ID))
+		}
+		seen[node.ID] = true
+	}
+	for _, edge := range c.Transitions() {
+		node := c.nodes[edge.From]
+		if c.nodes[edge.To] == nil {
+			problems = append(problems, fmt.Sprintf("%s: %s transition to missing node %q", edge.From, edge.Key, edge.To))
+		}
+		if known, ok := outcomes[node.Type]; ok && node.Type != "question" && !contains(known, edge.Key) &&
+			!(node.Type == "audio" && strings.HasPrefix(edge.Key, "background:")) && !conditionKey(node, edge.Key) {
+			problems = append(problems, fmt.Sprintf("%s: %s node never follows %q transitions", edge.From, node.Type, edge.Key))
+		}
+	}
+	for _, node := range c.flow.Nodes {
+		switch node.Type {
+		case "audio":
+			if node.Transitions["default"] == "" {
+				problems = append(problems, fmt.Sprintf("%s: audio node without a default transition stops the flow", node.ID))
+			}
+		case "question":
+			if node.Transitions["default"] != "" {
+				continue
+			}
+			for _, key := range []flow.ResponseType{flow.ResponsePositive, flow.ResponseNegative, flow.ResponseUnknown} {
+				if node.Transitions[string(key)] == "" {
+					problems = append(problems, fmt.Sprintf("%s: no %s or default transition; the flow waits for the timeout", node.ID, key))
+				}
+			}
+		default:
+			if _, ok := outcomes[node.Type]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: unknown node type %q", node.ID, node.Type))
+			}
+		}
+	}
+	if c.interrupts != nil {
+		keys := make([]string, 0, len(c.interrupts))
+		for key := range c.interrupts {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if c.nodes[key] == nil {
+				problems = append(problems, fmt.Sprintf("interrupt rule %q has no node; the flow does not move when it fires", key))
+			}
+		}
+	}
+	for _, id := range c.unreachable() {
+		problems = append(problems, fmt.Sprintf("%s: no transition or interrupt leads to this node", id))
+	}
+	return problems
+}
+
+// unreachable returns the nodes that cannot be entered from start, an
+// interrupt or a flow error, in flow order
+func (c *Coverage) unreachable() []string {
+	seen := make(map[string]bool)
+	var queue []string
+	enter := func(id string) {
+		if !seen[id] && c.nodes[id] != nil {
+			seen[id] = true
+			queue = append(queue, id)
+		}
+	}
+	enter("start")
+	if fb := c.flow.Metadata.ErrorFallback; fb != nil {
+		enter(fb.Node)
+	}
+	for _, node := range c.flow.Nodes {
+		if c.interrupts != nil && c.interrupts[node.ID] || c.interrupts == nil && node.Type == "interrupt" {
+			enter(node.ID)
+		}
+	}
+	for len(queue) > 0 {
+		node := c.nodes[queue[0]]
+		queue = queue[1:]
+		for _, to := range node.Transitions {
+			enter(to)
+		}
+		if fallsBack(node) {
+			enter(fallbackNode)
+		}
+	}
+
+	var ids []string
+	for _, node := range c.flow.Nodes {
+		if !seen[node.ID] {
+			ids = append(ids, node.ID)
+		}
+	}
+	return ids
+}
+
+// fallsBack reports whether the engine can send node to end_call without a
+// transition saying so
+func fallsBack(node *flow.FlowNode) bool {
+	switch node.Type {
+	case "question":
+		return node.Transitions["timeout"] == ""
+	case "condition":
+		return node.Transitions["default"] == ""
+	case "survey":
+		return missingOutcome(node, flow.SurveyAllPositive, flow.SurveyAnyNegative, flow.SurveyIncomplete)
+	case "collect_digits":
+		return missingOutcome(node, flow.DigitsCollected, flow.DigitsInvalid, flow.DigitsTimeout)
+	case "schedule_callback":
+		return missingOutcome(node, flow.CallbackConfirmed, flow.CallbackRejected, flow.CallbackUnclear, flow.CallbackTimeout)
+	}
+	return false
+}
+
+// conditionKey reports whether one of node's conditions follows key
+func conditionKey(node *flow.FlowNode, key string) bool {
+	for _, c := range node.Conditions {
+		if c.Transition == key {
+			return true
+		}
+	}
+	return false
+}
+
+// missingOutcome reports whether one of outcomes has neither its own nor a
+// default transition
+func missingOutcome(node *flow.FlowNode, outcomes ...string) bool {
+	if node.Transitions["default"] != "" {
+		return false
+	}
+	for _, outcome := range outcomes {
+		if node.Transitions[outcome] == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
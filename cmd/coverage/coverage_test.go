@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+const testFlow = `{"metadata": {"name": "test", "version": "2"}, "nodes": [
+ {"id": "start", "type": "question", "transitions": {"positive": "offer", "default": "bye"}},
+ {"id": "offer", "type": "question", "transitions": {"positive": "transfer", "negative": "bye", "maybe": "transfer"}},
+ {"id": "dnc", "type": "interrupt", "transitions": {"default": "end_call"}},
+ {"id": "transfer", "type": "transfer", "transitions": {"default": "bye"}},
+ {"id": "bye", "type": "hangup"},
+ {"id": "orphan", "type": "audio", "transitions": {"default": "gone"}},
+ {"id": "end_call", "type": "hangup"}
+]}`
+
+func TestCoverage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	if err := os.WriteFile(path, []byte(testFlow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := flow.LoadFlowConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCoverage(cfg, []string{"dnc", "robot"})
+
+	sessions := []string{
+		// start -unknown(default)-> bye
+		`{"ts":"2026-10-02T10:00:00Z","event":"build"}
+{"event":"flow_version","details":{"version":"2"}}
+{"event":"node_start","node_id":"start"}
+{"event":"transition","node_id":"start","next_node_id":"bye","details":{"reason":"unknown"}}
+{"event":"node_start","node_id":"bye"}`,
+		// start -positive-> offer, timeout -> end_call, interrupt raised outside a question
+		`{"ts":"2026-10-03T10:00:00Z","event":"build"}
+{"event":"flow_version","details":{"version":"2"}}
+{"event":"node_start","node_id":"start"}
+{"event":"transition","node_id":"start","next_node_id":"offer","details":{"reason":"positive"}}
+{"event":"node_start","node_id":"offer"}
+{"event":"timeout","node_id":"offer"}
+{"event":"node_start","node_id":"end_call"}
+{"event":"node_start","node_id":"dnc"}
+{"event":"node_start","node_id":"end_call"}`,
+		// outside the date range
+		`{"ts":"2026-10-20T10:00:00Z","event":"build"}
+{"event":"node_start","node_id":"start"}`,
+		// another flow version
+		`{"ts":"2026-10-03T10:00:00Z","event":"build"}
+{"event":"flow_version","details":{"version":"1"}}
+{"event":"node_start","node_id":"start"}`,
+	}
+	filter := Filter{
+		From:    time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC),
+		Version: "2",
+	}
+	for i, s := range sessions {
+		counted, err := c.AddSession(strings.NewReader(s), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counted != (i < 2) {
+			t.Errorf("session %d counted = %v", i, counted)
+		}
+	}
+
+	if c.Sessions != 2 || c.Visits["start"] != 2 || c.Visits["end_call"] != 2 || c.Reached["end_call"] != 1 {
+		t.Errorf("sessions %d, visits %v, reached %v", c.Sessions, c.Visits, c.Reached)
+	}
+	if c.Fired[Edge{"start", "default", "bye"}] != 1 || c.Fired[Edge{"start", "positive", "offer"}] != 1 {
+		t.Errorf("fired = %v", c.Fired)
+	}
+	if c.Fallbacks[Edge{"start", "unknown", "bye"}] != 1 || c.Fallbacks[Edge{"offer", "timeout", "end_call"}] != 1 {
+		t.Errorf("fallbacks = %v", c.Fallbacks)
+	}
+	if c.Interrupts[Edge{From: "end_call", To: "dnc"}] != 1 || len(c.Unexpected) != 0 {
+		t.Errorf("interrupts = %v, unexpected = %v", c.Interrupts, c.Unexpected)
+	}
+
+	problems := strings.Join(c.Problems(), "\n")
+	for _, want := range []string{
+		`orphan: default transition to missing node "gone"`,
+		`transfer: transfer node never follows "default" transitions`,
+		`interrupt rule "robot" has no node`,
+		"orphan: no transition or interrupt leads to this node",
+	} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("problems missing %q:\n%s", want, problems)
+		}
+	}
+	// end_call is reached through offer's missing timeout transition
+	if strings.Contains(problems, "end_call:") || strings.Contains(problems, "maybe") {
+		t.Errorf("unexpected problems:\n%s", problems)
+	}
+}
+
+func TestOffenders(t *testing.T) {
+	cfg := &flow.FlowConfig{Nodes: []flow.FlowNode{{ID: "start", Type: "question", BudgetMs: 5000}, {ID: "transfer", Type: "transfer", BudgetMs: 2000}}}
+	c := NewCoverage(cfg, nil)
+	log := `{"ts":"2026-10-02T10:00:00Z","event":"build"}
+{"event":"over_budget","node_id":"start","details":{"elapsed_ms":"9000","budget_ms":"5000"}}
+{"event":"over_budget","node_id":"transfer","details":{"elapsed_ms":"2500","budget_ms":"2000"}}
+{"event":"over_budget","node_id":"transfer","details":{"elapsed_ms":"4000","budget_ms":"2000"}}
+{"event":"over_budget","node_id":"transfer","details":{"elapsed_ms":"3000","budget_ms":"2000"}}`
+	if _, err := c.AddSession(strings.NewReader(log), Filter{}); err != nil {
+		t.Fatal(err)
+	}
+	offenders := c.Offenders()
+	want := []Offender{
+		{Node: "transfer", BudgetMs: 2000, Count: 3, MedianMs: 3000, WorstMs: 4000},
+		{Node: "start", BudgetMs: 5000, Count: 1, MedianMs: 9000, WorstMs: 9000},
+	}
+	if len(offenders) != len(want) || offenders[0] != want[0] || offenders[1] != want[1] {
+		t.Errorf("offenders = %+v, want %+v", offenders, want)
+	}
+}
+
+func TestEnvironments(t *testing.T) {
+	c := NewCoverage(&flow.FlowConfig{}, nil)
+	for _, log := range []string{
+		`{"ts":"2026-10-02T10:00:00Z","event":"build"}
+{"event":"background","details":{"environment":"car"}}
+{"event":"flow_end","details":{"reason":"transfer"}}`,
+		`{"ts":"2026-10-02T11:00:00Z","event":"build"}
+{"event":"background","details":{"environment":"car"}}
+{"event":"flow_end","details":{"reason":"hangup"}}`,
+		`{"ts":"2026-10-02T12:00:00Z","event":"build"}
+{"event":"flow_end","details":{"reason":"transfer"}}`,
+	} {
+		if _, err := c.AddSession(strings.NewReader(log), Filter{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if car := c.Environments["car"]; car == nil || *car != (EnvironmentStats{Calls: 2, Transferred: 1}) {
+		t.Errorf("car = %+v", car)
+	}
+	if unknown := c.Environments["unknown"]; unknown == nil || *unknown != (EnvironmentStats{Calls: 1, Transferred: 1}) {
+		t.Errorf("unknown = %+v", unknown)
+	}
+}
+
+func TestFlowErrors(t *testing.T) {
+	cfg := &flow.FlowConfig{
+		Metadata: flow.FlowMetadata{ErrorFallback: &flow.ErrorFallbackSettings{Node: "sorry"}},
+		Nodes: []flow.FlowNode{
+			{ID: "start", Type: "audio", Transitions: map[string]string{"default": "gone"}},
+			{ID: "sorry", Type: "hangup"},
+		},
+	}
+	c := NewCoverage(cfg, nil)
+	log := `{"ts":"2026-10-02T10:00:00Z","event":"build"}
+{"event":"node_start","node_id":"start"}
+{"event":"flow_error","node_id":"start","details":{"error":"next node gone not found"}}
+{"event":"transition","node_id":"start","next_node_id":"sorry","details":{"reason":"error"}}
+{"event":"node_start","node_id":"sorry"}`
+	if _, err := c.AddSession(strings.NewReader(log), Filter{}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Errors[Edge{From: "start", To: "sorry"}] != 1 || len(c.Unexpected) != 0 {
+		t.Errorf("errors = %v, unexpected = %v", c.Errors, c.Unexpected)
+	}
+	if problems := strings.Join(c.Problems(), "\n"); strings.Contains(problems, "sorry:") {
+		t.Errorf("error_fallback node reported:\n%s", problems)
+	}
+}
+
+func TestChainedSession(t *testing.T) {
+	cfg := &flow.FlowConfig{Nodes: []flow.FlowNode{
+		{ID: "start", Type: "audio", Transitions: map[string]string{"default": "verify"}},
+		{ID: "verify", Type: "flow", Flow: "verify.json"},
+	}}
+	c := NewCoverage(cfg, nil)
+	log := `{"ts":"2026-10-02T10:00:00Z","event":"build"}
+{"event":"node_start","node_id":"start"}
+{"event":"node_start","node_id":"verify"}
+{"event":"flow_chain","node_id":"verify","details":{"file":"verify.json"}}
+{"event":"node_start","node_id":"start"}
+{"event":"node_start","node_id":"confirm"}`
+	if _, err := c.AddSession(strings.NewReader(log), Filter{}); err != nil {
+		t.Fatal(err)
+	}
+	// The follow-on flow's nodes are not this flow's
+	if c.Visits["start"] != 1 || len(c.Missing) != 0 || len(c.Unexpected) != 0 {
+		t.Errorf("visits %v, missing %v, unexpected %v", c.Visits, c.Missing, c.Unexpected)
+	}
+}
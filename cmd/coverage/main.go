@@ -0,0 +1,238 @@
+// Command coverage replays session logs against a flow file and reports
+// which nodes and transitions calls exercised, which never fired, and which
+// parts of the flow are misconfigured:
+//
+//	coverage -flow config/flow.json -from 2026-10-01 -to 2026-10-15 ./transcripts
+//
+// Directories are searched for session logs (*_session_*.jsonl). Sessions
+// are selected by the date of their first record; -version keeps only calls
+// that ran one deployed flow version. Nodes that went over their budget_ms
+// are ranked, most often first, and with background classification on the
+// transfer rate is broken down by the caller's environment.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+func main() {
+	var flowPath, interruptsPath, from, to string
+	var filter Filter
+	flag.StringVar(&flowPath, "flow", "config/flow.json", "Flow file to report on")
+	flag.StringVar(&interruptsPath, "interrupts", "config/interrupts.yaml", "Interrupt patterns, whose keys name interrupt nodes (empty to skip)")
+	flag.StringVar(&from, "from", "", "First call date, YYYY-MM-DD")
+	flag.StringVar(&to, "to", "", "Last call date, YYYY-MM-DD")
+	flag.StringVar(&filter.Version, "version", "", "Only calls that ran this flow version")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] session-log-or-dir...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var err error
+	if filter.From, err = parseDate(from); err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	if filter.To, err = parseDate(to); err != nil {
+		log.Fatalf("Invalid -to: %v", err)
+	}
+
+	cfg, err := flow.LoadFlowConfig(flowPath)
+	if err != nil {
+		log.Fatalf("Failed to load flow: %v", err)
+	}
+	var interruptKeys []string
+	if interruptsPath != "" {
+		log.SetOutput(io.Discard) // the matcher logs every load
+		matcher, err := audio.NewPatternMatcher(interruptsPath)
+		log.SetOutput(os.Stderr)
+		if err != nil {
+			log.Fatalf("Failed to load interrupt patterns: %v", err)
+		}
+		interruptKeys = []string{}
+		for key := range matcher.GetInterrupts() {
+			interruptKeys = append(interruptKeys, key)
+		}
+	}
+	coverage := NewCoverage(cfg, interruptKeys)
+
+	files, err := sessionLogs(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to list session logs: %v", err)
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", file, err)
+		}
+		_, err = coverage.AddSession(f, filter)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", file, err)
+		}
+	}
+
+	fmt.Printf("Flow %s (%s %s), %d of %d session logs in range\n", flowPath, cfg.Metadata.Name, cfg.Metadata.Version, coverage.Sessions, len(files))
+	report(os.Stdout, coverage)
+}
+
+// report prints the node and transition tables, the paths taken outside the
+// configured transitions, the nodes over their latency budget, transfers by
+// caller background and the configuration problems
+func report(w io.Writer, c *Coverage) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\nNODE\tTYPE\tVISITS\tCALLS\t")
+	for _, node := range c.flow.Nodes {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", node.ID, node.Type, c.Visits[node.ID], c.Reached[node.ID], never(c.Visits[node.ID]))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(tw, "\nFROM\tON\tTO\tCOUNT\t")
+	for _, edge := range c.Transitions() {
+		n := c.Fired[edge]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", edge.From, edge.Key, edge.To, n, never(n))
+	}
+	tw.Flush()
+
+	section(w, "Outcomes without their own transition", c.Fallbacks, func(e Edge) string {
+		return fmt.Sprintf("%s: %s -> %s", e.From, e.Key, e.To)
+	})
+	section(w, "Interrupts", c.Interrupts, func(e Edge) string {
+		return fmt.Sprintf("%s -> %s", e.From, e.To)
+	})
+	section(w, "Flow errors", c.Errors, func(e Edge) string {
+		return fmt.Sprintf("%s -> %s", e.From, e.To)
+	})
+	section(w, "Steps the flow does not explain (edited flow or another version?)", c.Unexpected, func(e Edge) string {
+		return fmt.Sprintf("%s: %s -> %s", e.From, e.Key, e.To)
+	})
+	if len(c.Missing) > 0 {
+		fmt.Fprintln(w, "\nNodes in the logs but not in the flow")
+		for _, id := range sortedKeys(c.Missing) {
+			fmt.Fprintf(w, "  %6d  %s\n", c.Missing[id], id)
+		}
+	}
+
+	if offenders := c.Offenders(); len(offenders) > 0 {
+		fmt.Fprintln(tw, "\nOVER BUDGET\tBUDGET\tTIMES\tOF VISITS\tMEDIAN\tWORST\t")
+		for _, o := range offenders {
+			share := "-"
+			if visits := c.Visits[o.Node]; visits > 0 {
+				share = fmt.Sprintf("%.0f%%", 100*float64(o.Count)/float64(visits))
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t\n", o.Node, ms(o.BudgetMs), o.Count, share, ms(o.MedianMs), ms(o.WorstMs))
+		}
+		tw.Flush()
+	}
+
+	// Only worth showing when background classification was on
+	if n := len(c.Environments); n > 1 || n == 1 && c.Environments["unknown"] == nil {
+		fmt.Fprintln(tw, "\nBACKGROUND\tCALLS\tTRANSFERRED\t")
+		envs := make([]string, 0, len(c.Environments))
+		for env := range c.Environments {
+			envs = append(envs, env)
+		}
+		sort.Strings(envs)
+		for _, env := range envs {
+			e := c.Environments[env]
+			fmt.Fprintf(tw, "%s\t%d\t%d (%.0f%%)\t\n", env, e.Calls, e.Transferred, 100*float64(e.Transferred)/float64(e.Calls))
+		}
+		tw.Flush()
+	}
+
+	if problems := c.Problems(); len(problems) > 0 {
+		fmt.Fprintln(w, "\nProblems")
+		for _, p := range problems {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+}
+
+// section prints counted edges, most frequent first
+func section(w io.Writer, title string, counts map[Edge]int, label func(Edge) string) {
+	if len(counts) == 0 {
+		return
+	}
+	edges := make([]Edge, 0, len(counts))
+	for e := range counts {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if counts[edges[i]] != counts[edges[j]] {
+			return counts[edges[i]] > counts[edges[j]]
+		}
+		return label(edges[i]) < label(edges[j])
+	})
+	fmt.Fprintf(w, "\n%s\n", title)
+	for _, e := range edges {
+		fmt.Fprintf(w, "  %6d  %s\n", counts[e], label(e))
+	}
+}
+
+// ms formats a duration in milliseconds, "-" for none
+func ms(n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return (time.Duration(n) * time.Millisecond).String()
+}
+
+// never marks what no call exercised
+func never(n int) string {
+	if n == 0 {
+		return "never"
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseDate parses a YYYY-MM-DD flag; empty means no bound
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// sessionLogs expands directories in args to the session logs they contain
+func sessionLogs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*_session_*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import "fmt"
+
+// Dashboard is the subset of the Grafana dashboard JSON model the generator
+// fills in; Grafana supplies defaults for the rest on import
+type Dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	Timezone      string     `json:"timezone"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []*Panel   `json:"panels"`
+}
+
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// Variable is a dashboard variable, shown as a selector at the top
+type Variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Type       string      `json:"type"` // datasource, query or textbox
+	Query      string      `json:"query"`
+	Datasource *Datasource `json:"datasource,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"` // 2: on time range change
+	IncludeAll bool        `json:"includeAll,omitempty"`
+	Multi      bool        `json:"multi,omitempty"`
+	AllValue   string      `json:"allValue,omitempty"`
+	Current    *Current    `json:"current,omitempty"`
+}
+
+type Current struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+type Datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Panel is a graph, stat or row header
+type Panel struct {
+	ID          int          `json:"id"`
+	Type        string       `json:"type"` // row, timeseries or stat
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	GridPos     GridPos      `json:"gridPos"`
+	Datasource  *Datasource  `json:"datasource,omitempty"`
+	Targets     []Target     `json:"targets,omitempty"`
+	FieldConfig *FieldConfig `json:"fieldConfig,omitempty"`
+	Collapsed   bool         `json:"collapsed,omitempty"`
+}
+
+type Target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+}
+
+type FieldConfig struct {
+	Defaults FieldDefaults `json:"defaults"`
+}
+
+type FieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+	Min  *int   `json:"min,omitempty"`
+}
+
+// prometheus refers to the datasource picked in the dashboard's selector
+var prometheus = &Datasource{Type: "prometheus", UID: "${datasource}"}
+
+// Grafana lays dashboards out on a 24 column grid
+const (
+	gridWidth   = 24
+	panelHeight = 8
+)
+
+// builder lays panels out left to right, two per line, with a full width
+// header for each row
+type builder struct {
+	d      *Dashboard
+	nextID int
+	x, y   int
+}
+
+func (b *builder) row(title string) {
+	if b.x > 0 {
+		b.x, b.y = 0, b.y+panelHeight
+	}
+	b.add(&Panel{Type: "row", Title: title, GridPos: GridPos{H: 1, W: gridWidth, Y: b.y}})
+	b.y++
+}
+
+// graph adds a time series panel; exprs alternate PromQL and legend
+func (b *builder) graph(title, description, unit string, exprs ...string) {
+	b.panel("timeseries", title, description, unit, exprs)
+}
+
+// stat adds a single value panel
+func (b *builder) stat(title, description, unit string, exprs ...string) {
+	b.panel("stat", title, description, unit, exprs)
+}
+
+func (b *builder) panel(kind, title, description, unit string, exprs []string) {
+	zero := 0
+	p := &Panel{
+		Type:        kind,
+		Title:       title,
+		Description: description,
+		GridPos:     GridPos{H: panelHeight, W: gridWidth / 2, X: b.x, Y: b.y},
+		Datasource:  prometheus,
+		FieldConfig: &FieldConfig{Defaults: FieldDefaults{Unit: unit, Min: &zero}},
+	}
+	for i := 0; i+1 < len(exprs); i += 2 {
+		p.Targets = append(p.Targets, Target{RefID: string(rune('A' + i/2)), Expr: exprs[i], LegendFormat: exprs[i+1]})
+	}
+	b.add(p)
+	if b.x += gridWidth / 2; b.x >= gridWidth {
+		b.x, b.y = 0, b.y+panelHeight
+	}
+}
+
+func (b *builder) add(p *Panel) {
+	b.nextID++
+	p.ID = b.nextID
+	b.d.Panels = append(b.d.Panels, p)
+}
+
+// flowFilter selects the campaigns and flow versions picked in the
+// dashboard's selectors
+const flowFilter = `campaign=~"$campaign",version=~"$version"`
+
+// rate is the PromQL per-second rate of a counter over Grafana's interval
+func rate(metric string) string {
+	return fmt.Sprintf("rate(%s[$__rate_interval])", metric)
+}
+
+// quantile is the PromQL q-quantile of a histogram, restricted to the
+// series matching selector and grouped by the labels in by
+func quantile(q float64, histogram, selector, by string) string {
+	return fmt.Sprintf("histogram_quantile(%g, sum by (le, %s) (%s))", q, by, rate(histogram+"_bucket"+selector))
+}
+
+// NewDashboard builds the monitoring dashboard for the metrics the server
+// exports on its admin /metrics endpoint
+func NewDashboard(uid, title string) *Dashboard {
+	d := &Dashboard{
+		UID:           uid,
+		Title:         title,
+		Tags:          []string{"audiosocket"},
+		Timezone:      "browser",
+		SchemaVersion: 39,
+		Refresh:       "30s",
+		Time:          TimeRange{From: "now-6h", To: "now"},
+	}
+	all := &Current{Text: "All", Value: "$__all"}
+	d.Templating.List = []Variable{
+		{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		{Name: "campaign", Label: "Campaign", Type: "query", Datasource: prometheus, Refresh: 2,
+			Query: "label_values(audiosocket_dispositions_total, campaign)", IncludeAll: true, Multi: true, AllValue: ".*", Current: all},
+		{Name: "version", Label: "Flow version", Type: "query", Datasource: prometheus, Refresh: 2,
+			Query: `label_values(audiosocket_dispositions_total{campaign=~"$campaign"}, version)`, IncludeAll: true, Multi: true, AllValue: ".*", Current: all},
+		{Name: "transfer_status", Label: "Transfer status", Type: "textbox", Query: "TRSFR", Current: &Current{Text: "TRSFR", Value: "TRSFR"}},
+	}
+
+	b := &builder{d: d}
+	b.row("Sessions")
+	b.stat("Active calls", "Calls in progress across the selected instances", "none",
+		"sum(audiosocket_sessions_active)", "active")
+	b.graph("Calls started", "New calls per minute by transcription provider", "cpm",
+		"sum by (provider) ("+rate("audiosocket_sessions_started_total")+") * 60", "{{provider}}")
+	b.graph("Connections", "AudioSocket connections accepted and refused for lack of a free worker", "cpm",
+		"sum("+rate("audiosocket_connections_accepted_total")+") * 60", "accepted",
+		"sum("+rate("audiosocket_connections_rejected_total")+") * 60", "rejected")
+	b.graph("Workers", "Busy workers and connections waiting for one", "none",
+		"sum(audiosocket_workers_busy)", "busy",
+		"sum(audiosocket_accept_queue_depth)", "queued")
+
+	b.row("Latency")
+	b.graph("Node duration p95", "Time spent in each flow node, 95th percentile", "s",
+		quantile(0.95, "flow_node_duration_seconds", "{"+flowFilter+"}", "node"), "{{node}}")
+	b.graph("Node duration p50", "Time spent in each flow node, median", "s",
+		quantile(0.5, "flow_node_duration_seconds", "{"+flowFilter+"}", "node"), "{{node}}")
+	b.graph("Nodes over budget", "Node executions per minute that took longer than their budget_ms", "cpm",
+		"sum by (node) ("+rate("flow_node_over_budget_total{"+flowFilter+"}")+") * 60", "{{node}}")
+	b.graph("Time to first transcription", "Time from call start to the provider's first result", "s",
+		quantile(0.5, "audiosocket_transcriber_first_result_seconds", "", "provider"), "{{provider}} p50",
+		quantile(0.95, "audiosocket_transcriber_first_result_seconds", "", "provider"), "{{provider}} p95")
+
+	b.row("Dispositions")
+	b.graph("Dispositions", "Final call statuses per minute", "cpm",
+		"sum by (status) ("+rate("audiosocket_dispositions_total{"+flowFilter+"}")+") * 60", "{{status}}")
+	b.graph("Transfer rate", "Share of calls ending in the transfer status, by campaign", "percentunit",
+		`sum by (campaign) (`+rate(`audiosocket_dispositions_total{`+flowFilter+`,status="$transfer_status"}`)+`) / sum by (campaign) (`+rate("audiosocket_dispositions_total{"+flowFilter+"}")+`)`, "{{campaign}}")
+	b.graph("Transfer API calls", "Vicidial transfer requests per minute by result", "cpm",
+		"sum by (result) ("+rate("flow_transfer_requests_total{"+flowFilter+"}")+") * 60", "{{result}}")
+	b.graph("Transfer outcomes", "Transferred calls per minute an agent answered or that were dropped in queue", "cpm",
+		"sum by (outcome) ("+rate("audiosocket_transfer_outcomes_total")+") * 60", "{{outcome}}")
+	b.graph("Webhooks", "Webhook deliveries per minute by result, and webhooks waiting in the outbox", "cpm",
+		"sum by (result) ("+rate("audiosocket_webhook_deliveries_total")+") * 60", "{{result}}",
+		"sum(audiosocket_webhooks_pending)", "pending")
+	b.graph("Agent lookups", "Vicidial agent user lookups per minute answered from the call's cache, the shared cache or the API", "cpm",
+		"sum by (result) ("+rate("audiosocket_agent_lookups_total")+") * 60", "{{result}}",
+		"sum("+rate("audiosocket_agent_cache_evictions_total")+") * 60", "evicted")
+	b.graph("Classifications", "Caller answers per minute by classification", "cpm",
+		"sum by (classification) ("+rate("flow_classifications_total{"+flowFilter+"}")+") * 60", "{{classification}}")
+	b.graph("Interrupts", "Interrupts per minute by type", "cpm",
+		"sum by (interrupt) ("+rate("flow_interrupts_total{"+flowFilter+"}")+") * 60", "{{interrupt}}")
+	b.graph("Flow errors", "Calls per minute sent to the error_fallback node, by the node that failed", "cpm",
+		"sum by (node) ("+rate("flow_errors_total{"+flowFilter+"}")+") * 60", "{{node}}")
+	b.graph("Calls ended by the server", "Machines, disconnected numbers and DNC numbers hung up on per minute", "cpm",
+		"sum by (kind) ("+rate("audiosocket_machine_answers_total")+") * 60", "{{kind}}",
+		"sum("+rate("audiosocket_sit_dispositions_total")+") * 60", "disconnected",
+		"sum("+rate("audiosocket_dnc_blocked_total")+") * 60", "dnc")
+	b.graph("Disposition reconciliation", "Posted statuses Vicidial did not match, and the reconciler's re-posts", "cpm",
+		"sum("+rate("audiosocket_disposition_discrepancies_total")+") * 60", "discrepancies",
+		"sum("+rate("audiosocket_disposition_reposts_total")+") * 60", "re-posted",
+		"sum("+rate("audiosocket_disposition_repost_errors_total")+") * 60", "re-post failures")
+
+	b.row("Provider health")
+	b.graph("Transcriber errors", "Transcribers that failed to start or stopped accepting audio, per minute", "cpm",
+		"sum by (provider) ("+rate("audiosocket_transcriber_errors_total")+") * 60", "{{provider}}")
+	b.graph("Transcriber error rate", "Transcriber errors per call started", "percentunit",
+		"sum by (provider) ("+rate("audiosocket_transcriber_errors_total")+") / sum by (provider) ("+rate("audiosocket_sessions_started_total")+")", "{{provider}}")
+	b.graph("Vosk dropped audio", "20ms frames dropped per minute because a Vosk server fell over a second behind", "cpm",
+		"sum("+rate("audiosocket_vosk_dropped_frames_total")+") * 60", "dropped")
+	b.graph("Dropped partials", "Partial transcripts dropped per minute because the session fell behind reading results", "cpm",
+		"sum by (provider) ("+rate("audiosocket_transcriber_dropped_results_total")+") * 60", "{{provider}}")
+	b.graph("Standby transcribers", "Calls per minute started on a standby provider connection (hit) or waiting for a new one (miss)", "cpm",
+		"sum by (result) ("+rate("audiosocket_standby_transcribers_total")+") * 60", "{{result}}")
+	b.graph("Stale sessions", "Calls ended after inbound audio stopped arriving, per minute", "cpm",
+		"sum("+rate("audiosocket_stale_sessions_total")+") * 60", "stale")
+	b.graph("Dead air", "Calls hung up per minute because no caller audio arrived (one-way audio)", "cpm",
+		"sum("+rate("audiosocket_dead_air_hangups_total")+") * 60", "dead air")
+	b.graph("Signaling tones", "Beep, fax and SIT tones heard per minute", "cpm",
+		"sum by (tone) ("+rate("audiosocket_tones_detected_total")+") * 60", "{{tone}}")
+	return d
+}
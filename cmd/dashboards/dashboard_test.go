@@ -0,0 +1,72 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+
+	// Register the metrics the dashboard graphs
+	_ "github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	_ "github.com/amanullahtanweer/audiosocket-transcriber/internal/server"
+)
+
+var metricName = regexp.MustCompile(`\b(?:audiosocket|flow)_[a-z_]+`)
+
+func TestDashboardMetricsExist(t *testing.T) {
+	var b strings.Builder
+	if err := metrics.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	exported := map[string]string{}
+	for _, line := range strings.Split(b.String(), "\n") {
+		if fields := strings.Fields(line); len(fields) == 4 && fields[1] == "TYPE" {
+			exported[fields[2]] = fields[3]
+		}
+	}
+
+	d := NewDashboard("test", "Test")
+	for _, p := range d.Panels {
+		for _, target := range p.Targets {
+			for _, name := range metricName.FindAllString(target.Expr, -1) {
+				if kind, ok := exported[name]; ok && kind != "histogram" {
+					continue
+				}
+				base := name
+				for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+					base = strings.TrimSuffix(base, suffix)
+				}
+				if exported[base] != "histogram" {
+					t.Errorf("panel %q graphs %s, which the server does not export", p.Title, name)
+				}
+			}
+		}
+	}
+}
+
+func TestDashboardLayout(t *testing.T) {
+	d := NewDashboard("test", "Test")
+	ids := map[int]bool{}
+	taken := map[[2]int]string{}
+	for _, p := range d.Panels {
+		if ids[p.ID] {
+			t.Errorf("panel id %d used twice", p.ID)
+		}
+		ids[p.ID] = true
+		if p.GridPos.X+p.GridPos.W > gridWidth {
+			t.Errorf("panel %q overflows the grid", p.Title)
+		}
+		for y := p.GridPos.Y; y < p.GridPos.Y+p.GridPos.H; y++ {
+			for x := p.GridPos.X; x < p.GridPos.X+p.GridPos.W; x++ {
+				if other, ok := taken[[2]int{x, y}]; ok {
+					t.Fatalf("panel %q overlaps %q", p.Title, other)
+				}
+				taken[[2]int{x, y}] = p.Title
+			}
+		}
+		if p.Type != "row" && len(p.Targets) == 0 {
+			t.Errorf("panel %q has no queries", p.Title)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+// Command dashboards writes a Grafana dashboard for the Prometheus metrics
+// the server exports on its admin /metrics endpoint, ready to import:
+//
+//	dashboards -out audiosocket.json
+//
+// The dashboard has rows for sessions, latency, dispositions and provider
+// health, and selectors for the Prometheus data source, campaign, flow
+// version and the Vicidial status transfers end with.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	var out, uid, title string
+	flag.StringVar(&out, "out", "", "File to write the dashboard JSON to (default stdout)")
+	flag.StringVar(&uid, "uid", "audiosocket", "Dashboard UID; importing again with the same UID replaces the dashboard")
+	flag.StringVar(&title, "title", "AudioSocket", "Dashboard title")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	data, err := json.MarshalIndent(NewDashboard(uid, title), "", "  ")
+	if err != nil {
+		log.Fatalf("Failed to encode dashboard: %v", err)
+	}
+	data = append(data, '\n')
+	if out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		log.Fatalf("Failed to write dashboard: %v", err)
+	}
+}
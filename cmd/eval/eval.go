@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+// Sample is one labeled caller answer in the corpus
+type Sample struct {
+	Text           string `json:"text"`
+	Interrupt      string `json:"interrupt,omitempty"`      // expected interrupt rule key; empty = none
+	Classification string `json:"classification,omitempty"` // expected classifier result; empty = not evaluated
+}
+
+// Score counts the outcomes of one rule over the corpus
+type Score struct {
+	Rule string
+	TP   int
+	FP   int
+	FN   int
+
+	Errors []string // "FP"/"FN" and the text, for -v
+}
+
+// Precision is the share of matches that were right; ok is false when the
+// rule matched nothing
+func (s *Score) Precision() (float64, bool) {
+	if s.TP+s.FP == 0 {
+		return 0, false
+	}
+	return float64(s.TP) / float64(s.TP+s.FP), true
+}
+
+// Recall is the share of labeled samples the rule matched; ok is false when
+// no sample carries the label
+func (s *Score) Recall() (float64, bool) {
+	if s.TP+s.FN == 0 {
+		return 0, false
+	}
+	return float64(s.TP) / float64(s.TP+s.FN), true
+}
+
+// record adds one sample's outcome
+func (s *Score) record(expected, predicted bool, text string) {
+	switch {
+	case expected && predicted:
+		s.TP++
+	case predicted:
+		s.FP++
+		s.Errors = append(s.Errors, "FP "+text)
+	case expected:
+		s.FN++
+		s.Errors = append(s.Errors, "FN "+text)
+	}
+}
+
+// readCorpus reads JSONL samples; blank lines and lines starting with # are
+// skipped
+func readCorpus(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var sample Sample
+		if err := json.Unmarshal([]byte(text), &sample); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	return samples, scanner.Err()
+}
+
+// evaluateInterrupts scores every interrupt rule on its own against every
+// sample, so the result does not depend on which rule the live matcher tries
+// first. Labels naming no configured rule are reported as an error.
+func evaluateInterrupts(matcher *audio.PatternMatcher, samples []Sample) ([]*Score, error) {
+	rules := matcher.GetInterrupts()
+	for _, sample := range samples {
+		if _, ok := rules[sample.Interrupt]; sample.Interrupt != "" && !ok {
+			return nil, fmt.Errorf("sample %q: no interrupt rule %q", sample.Text, sample.Interrupt)
+		}
+	}
+
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	scores := make([]*Score, 0, len(keys))
+	for _, key := range keys {
+		score := &Score{Rule: key}
+		for _, sample := range samples {
+			score.record(sample.Interrupt == key, matcher.MatchesRule(key, sample.Text), sample.Text)
+		}
+		scores = append(scores, score)
+	}
+	return scores, nil
+}
+
+// evaluateClassifier scores each response type over the samples labeled with
+// a classification
+func evaluateClassifier(classifier *flow.ResponseClassifier, samples []Sample) ([]*Score, int) {
+	types := []flow.ResponseType{flow.ResponsePositive, flow.ResponseNegative, flow.ResponseUnknown}
+	scores := make([]*Score, len(types))
+	for i, t := range types {
+		scores[i] = &Score{Rule: string(t)}
+	}
+
+	labeled := 0
+	for _, sample := range samples {
+		if sample.Classification == "" {
+			continue
+		}
+		labeled++
+		predicted := classifier.ClassifyResponse(sample.Text)
+		for i, t := range types {
+			scores[i].record(sample.Classification == string(t), predicted == t, sample.Text)
+		}
+	}
+	return scores, labeled
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+func TestEvaluate(t *testing.T) {
+	samples, err := readCorpus(strings.NewReader(`# comment
+{"text": "please stop calling me", "interrupt": "dnc"}
+{"text": "am I talking to a real person", "interrupt": "robot"}
+{"text": "yes I have it", "classification": "positive"}
+
+{"text": "I know my rights", "classification": "unknown"}
+`))
+	if err != nil || len(samples) != 4 {
+		t.Fatalf("readCorpus = %d samples, %v", len(samples), err)
+	}
+
+	matcher, err := audio.NewPatternMatcher("../../config/interrupts.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	interrupts, err := evaluateInterrupts(matcher, samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scores := make(map[string]*Score)
+	for _, s := range interrupts {
+		scores[s.Rule] = s
+	}
+	if dnc := scores["dnc"]; dnc.TP != 1 || dnc.FP != 0 || dnc.FN != 0 {
+		t.Errorf("dnc = %+v", dnc)
+	}
+	if robot := scores["robot"]; robot.FN != 1 {
+		t.Errorf("robot = %+v, want the missed sample as FN", robot)
+	}
+	if _, ok := scores["robot"].Precision(); ok {
+		t.Error("precision of a rule that matched nothing should be undefined")
+	}
+
+	classes, labeled := evaluateClassifier(flow.NewResponseClassifier(), samples)
+	if labeled != 2 {
+		t.Errorf("labeled = %d, want 2", labeled)
+	}
+	// "I know my rights" contains "no"
+	if negative := classes[1]; negative.Rule != "negative" || negative.FP != 1 {
+		t.Errorf("negative = %+v", negative)
+	}
+	if recall, _ := classes[0].Recall(); recall != 1 {
+		t.Errorf("positive recall = %.2f", recall)
+	}
+
+	if _, err := evaluateInterrupts(matcher, []Sample{{Text: "x", Interrupt: "nope"}}); err == nil {
+		t.Error("unknown rule label should be rejected")
+	}
+}
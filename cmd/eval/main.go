@@ -0,0 +1,111 @@
+// Command eval measures the interrupt patterns and the response classifier
+// against a labeled corpus of caller answers, reporting precision and recall
+// per rule so keyword changes can be checked before they are deployed:
+//
+//	eval -interrupts config/interrupts.yaml -corpus examples/eval/corpus.jsonl
+//
+// Each corpus line is a JSON object:
+//
+//	{"text": "please stop calling me", "interrupt": "dnc"}
+//	{"text": "yeah I have part b", "classification": "positive"}
+//
+// A sample without "interrupt" should trigger no interrupt; one without
+// "classification" is not used for the classifier.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+func main() {
+	var interruptsPath, corpusPath string
+	var minPrecision, minRecall float64
+	var verbose bool
+	flag.StringVar(&interruptsPath, "interrupts", "config/interrupts.yaml", "Interrupt patterns to evaluate")
+	flag.StringVar(&corpusPath, "corpus", "", "Labeled JSONL corpus (required)")
+	flag.Float64Var(&minPrecision, "min-precision", 0, "Exit with status 1 if any rule's precision is below this")
+	flag.Float64Var(&minRecall, "min-recall", 0, "Exit with status 1 if any rule's recall is below this")
+	flag.BoolVar(&verbose, "v", false, "List the false positives and negatives of each rule")
+	flag.Parse()
+
+	if corpusPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	f, err := os.Open(corpusPath)
+	if err != nil {
+		log.Fatalf("Failed to open corpus: %v", err)
+	}
+	samples, err := readCorpus(f)
+	f.Close()
+	if err != nil {
+		log.Fatalf("Failed to read corpus: %v", err)
+	}
+
+	log.SetOutput(io.Discard) // the matcher logs every load
+	matcher, err := audio.NewPatternMatcher(interruptsPath)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		log.Fatalf("Failed to load interrupt patterns: %v", err)
+	}
+	interrupts, err := evaluateInterrupts(matcher, samples)
+	if err != nil {
+		log.Fatalf("Invalid corpus: %v", err)
+	}
+	classes, labeled := evaluateClassifier(flow.NewResponseClassifier(), samples)
+
+	fmt.Printf("Interrupts (%s, %d samples)\n", interruptsPath, len(samples))
+	failed := report(os.Stdout, interrupts, minPrecision, minRecall, verbose)
+	fmt.Printf("\nClassifier (%d labeled samples)\n", labeled)
+	if report(os.Stdout, classes, minPrecision, minRecall, verbose) {
+		failed = true
+	}
+	if failed {
+		fmt.Printf("\nFAIL: rules below -min-precision %.2f / -min-recall %.2f are marked with !\n", minPrecision, minRecall)
+		os.Exit(1)
+	}
+}
+
+// report prints a table of scores and returns whether any rule is below the
+// thresholds
+func report(w io.Writer, scores []*Score, minPrecision, minRecall float64, verbose bool) bool {
+	failed := false
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tTP\tFP\tFN\tPRECISION\tRECALL\t")
+	for _, s := range scores {
+		precision, hasPrecision := s.Precision()
+		recall, hasRecall := s.Recall()
+		mark := ""
+		if (hasPrecision && precision < minPrecision) || (hasRecall && recall < minRecall) {
+			mark = "!"
+			failed = true
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\t%s\n", s.Rule, s.TP, s.FP, s.FN, ratio(precision, hasPrecision), ratio(recall, hasRecall), mark)
+	}
+	tw.Flush()
+
+	if verbose {
+		for _, s := range scores {
+			for _, e := range s.Errors {
+				fmt.Fprintf(w, "  %s %s\n", s.Rule, e)
+			}
+		}
+	}
+	return failed
+}
+
+// ratio formats a precision or recall, "-" when undefined
+func ratio(v float64, ok bool) string {
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", v)
+}
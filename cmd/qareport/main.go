@@ -0,0 +1,94 @@
+// Command qareport summarizes the QA scores the server logs for each call
+// when a rubric is configured (qa_rubric), by campaign, and lists the calls
+// that scored below a threshold with the checks they failed:
+//
+//	qareport -below 80 -from 2026-10-01 -to 2026-10-15 ./transcripts
+//
+// Directories are searched for session logs (*_session_*.jsonl). Calls
+// without a campaign_id are reported as "default"; calls logged before QA
+// was enabled are skipped.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	var from, to string
+	var below int
+	var filter Filter
+	flag.StringVar(&from, "from", "", "First call date, YYYY-MM-DD")
+	flag.StringVar(&to, "to", "", "Last call date, YYYY-MM-DD")
+	flag.StringVar(&filter.Campaign, "campaign", "", "Only report on this campaign")
+	flag.IntVar(&below, "below", 80, "List calls scoring below this")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] session-log-or-dir...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var err error
+	if filter.From, err = parseDate(from); err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	if filter.To, err = parseDate(to); err != nil {
+		log.Fatalf("Invalid -to: %v", err)
+	}
+
+	files, err := sessionLogs(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to list session logs: %v", err)
+	}
+	report := NewReport()
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", file, err)
+		}
+		_, err = report.AddSession(f, filter)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", file, err)
+		}
+	}
+
+	fmt.Printf("%d of %d calls in range were scored\n\n", len(report.Calls), report.Sessions)
+	report.Print(os.Stdout, below)
+}
+
+// parseDate parses a YYYY-MM-DD flag; empty means no bound
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// sessionLogs expands directories in args to the session logs they contain
+func sessionLogs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*_session_*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/qa"
+)
+
+// defaultCampaign groups calls without a campaign_id
+const defaultCampaign = "default"
+
+// Call is one scored call
+type Call struct {
+	CallTime   string // start of the call, RFC 3339
+	SessionID  string
+	LeadID     string
+	Campaign   string
+	Score      int
+	Violations []qa.Violation
+}
+
+// CampaignStats sums up the scores of one campaign's calls
+type CampaignStats struct {
+	Calls      int
+	Clean      int // calls without violations
+	TotalScore int
+	Violations map[string]int // by check
+}
+
+// Average is the campaign's mean score
+func (c *CampaignStats) Average() float64 {
+	if c.Calls == 0 {
+		return 0
+	}
+	return float64(c.TotalScore) / float64(c.Calls)
+}
+
+// Filter selects the calls to report on
+type Filter struct {
+	From, To time.Time // call start dates, inclusive; zero = open
+	Campaign string    // empty = all
+}
+
+// logEvent is the part of a session log record the report uses
+type logEvent struct {
+	Timestamp string            `json:"ts"`
+	Event     string            `json:"event"`
+	SessionID string            `json:"session_id"`
+	Details   map[string]string `json:"details"`
+}
+
+// Report collects the QA results of session logs
+type Report struct {
+	Sessions  int // session logs in range, scored or not
+	Calls     []Call
+	Campaigns map[string]*CampaignStats
+}
+
+// NewReport returns an empty report
+func NewReport() *Report {
+	return &Report{Campaigns: make(map[string]*CampaignStats)}
+}
+
+// AddSession reads one session log and adds its QA result if the call passes
+// filter. It reports whether the call was scored; calls logged before QA
+// was enabled have no result and are only counted in Sessions.
+func (r *Report) AddSession(rd io.Reader, filter Filter) (bool, error) {
+	var events []logEvent
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev logEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	if len(events) == 0 {
+		return false, nil
+	}
+
+	started, err := time.Parse(time.RFC3339Nano, events[0].Timestamp)
+	if err != nil {
+		return false, nil
+	}
+	day := time.Date(started.Year(), started.Month(), started.Day(), 0, 0, 0, 0, time.UTC)
+	if !filter.From.IsZero() && day.Before(filter.From) || !filter.To.IsZero() && day.After(filter.To) {
+		return false, nil
+	}
+
+	call := Call{CallTime: started.Format(time.RFC3339), Campaign: defaultCampaign}
+	scored := false
+	for _, ev := range events {
+		switch ev.Event {
+		case "flow_version":
+			if campaign := ev.Details["campaign"]; campaign != "" {
+				call.Campaign = campaign
+			}
+		case "caller":
+			call.LeadID = ev.Details["lead_id"]
+		case "qa":
+			score, err := strconv.Atoi(ev.Details["score"])
+			if err != nil {
+				continue
+			}
+			var violations []qa.Violation
+			if json.Unmarshal([]byte(ev.Details["violations"]), &violations) != nil {
+				continue
+			}
+			call.SessionID, call.Score, call.Violations = ev.SessionID, score, violations
+			scored = true
+		}
+	}
+	if filter.Campaign != "" && call.Campaign != filter.Campaign {
+		return false, nil
+	}
+	r.Sessions++
+	if !scored {
+		return false, nil
+	}
+
+	r.Calls = append(r.Calls, call)
+	stats := r.Campaigns[call.Campaign]
+	if stats == nil {
+		stats = &CampaignStats{Violations: make(map[string]int)}
+		r.Campaigns[call.Campaign] = stats
+	}
+	stats.Calls++
+	stats.TotalScore += call.Score
+	if len(call.Violations) == 0 {
+		stats.Clean++
+	}
+	for _, v := range call.Violations {
+		stats.Violations[v.Check]++
+	}
+	return true, nil
+}
+
+// checks are the report's violation columns
+var checks = []string{qa.CheckDisclosure, qa.CheckTalkOver, qa.CheckDisposition}
+
+// Print writes the per-campaign summary, then the calls scoring below
+// threshold, lowest first, with what they failed
+func (r *Report) Print(w io.Writer, threshold int) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "CAMPAIGN\tCALLS\tAVG SCORE\tCLEAN\t%s\t\n", strings.ToUpper(strings.Join(checks, "\t")))
+	campaigns := make([]string, 0, len(r.Campaigns))
+	for campaign := range r.Campaigns {
+		campaigns = append(campaigns, campaign)
+	}
+	sort.Strings(campaigns)
+	for _, campaign := range campaigns {
+		c := r.Campaigns[campaign]
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d (%.0f%%)\t", campaign, c.Calls, c.Average(), c.Clean, 100*float64(c.Clean)/float64(c.Calls))
+		for _, check := range checks {
+			fmt.Fprintf(tw, "%d\t", c.Violations[check])
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+
+	var low []Call
+	for _, call := range r.Calls {
+		if call.Score < threshold {
+			low = append(low, call)
+		}
+	}
+	if len(low) == 0 {
+		return
+	}
+	sort.SliceStable(low, func(i, j int) bool {
+		if low[i].Score != low[j].Score {
+			return low[i].Score < low[j].Score
+		}
+		return low[i].CallTime < low[j].CallTime
+	})
+	fmt.Fprintf(w, "\nCalls scoring below %d\n", threshold)
+	for _, call := range low {
+		fmt.Fprintf(w, "  %3d  %s  %s  %s  lead %s\n", call.Score, call.CallTime, call.SessionID, call.Campaign, orDash(call.LeadID))
+		for _, v := range call.Violations {
+			fmt.Fprintf(w, "         %s: %s\n", v.Check, v.Detail)
+		}
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
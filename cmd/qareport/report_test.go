@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReport(t *testing.T) {
+	sessions := []string{
+		// Clean call
+		`{"ts":"2026-10-02T09:00:00Z","event":"build","session_id":"s1"}
+{"event":"flow_version","session_id":"s1","details":{"campaign":"SOLAR","version":"2"}}
+{"event":"qa","session_id":"s1","details":{"score":"100","violations":"[]"}}`,
+		// Disclosure cut short and the caller's DNC dispositioned NI
+		`{"ts":"2026-10-03T10:00:00Z","event":"build","session_id":"s2"}
+{"event":"flow_version","session_id":"s2","details":{"campaign":"SOLAR","version":"2"}}
+{"event":"caller","session_id":"s2","details":{"lead_id":"1002"}}
+{"event":"qa","session_id":"s2","details":{"score":"20","violations":"[{\"check\":\"disclosure\",\"detail\":\"greeting.wav was cut short\"},{\"check\":\"disposition\",\"detail\":\"dispositioned NI, but the caller's words call for DNC\"}]"}}`,
+		// No campaign, talked over
+		`{"ts":"2026-10-04T09:00:00Z","event":"build","session_id":"s3"}
+{"event":"qa","session_id":"s3","details":{"score":"80","violations":"[{\"check\":\"talk_over\",\"detail\":\"caller spoke over greeting.wav\"}]"}}`,
+		// Logged before QA was enabled
+		`{"ts":"2026-10-05T09:00:00Z","event":"build","session_id":"s4"}`,
+		// Outside the date range
+		`{"ts":"2026-10-20T09:00:00Z","event":"build","session_id":"s5"}
+{"event":"qa","session_id":"s5","details":{"score":"0","violations":"[]"}}`,
+	}
+	filter := Filter{To: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)}
+	r := NewReport()
+	for i, s := range sessions {
+		ok, err := r.AddSession(strings.NewReader(s), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != (i < 3) {
+			t.Errorf("session %d scored = %v", i, ok)
+		}
+	}
+	if r.Sessions != 4 || len(r.Calls) != 3 {
+		t.Fatalf("sessions = %d, calls = %d, want 4 and 3", r.Sessions, len(r.Calls))
+	}
+	solar := r.Campaigns["SOLAR"]
+	if solar == nil || solar.Calls != 2 || solar.Clean != 1 || solar.Average() != 60 ||
+		solar.Violations["disclosure"] != 1 || solar.Violations["disposition"] != 1 {
+		t.Errorf("SOLAR = %+v", solar)
+	}
+	if d := r.Campaigns[defaultCampaign]; d == nil || d.Violations["talk_over"] != 1 {
+		t.Errorf("default = %+v", d)
+	}
+
+	var out bytes.Buffer
+	r.Print(&out, 80)
+	got := out.String()
+	for _, want := range []string{"SOLAR", "60.0", "1 (50%)", "Calls scoring below 80", "s2", "lead 1002", "disclosure: greeting.wav was cut short"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "s3") {
+		t.Errorf("a call scoring 80 should not be listed below 80:\n%s", got)
+	}
+
+	// Filtering by campaign
+	r = NewReport()
+	for _, s := range sessions {
+		r.AddSession(strings.NewReader(s), Filter{Campaign: "SOLAR"})
+	}
+	if len(r.Calls) != 2 || r.Campaigns[defaultCampaign] != nil {
+		t.Errorf("campaign filter kept %d calls: %v", len(r.Calls), r.Campaigns)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultCampaign names the export of calls without a campaign_id
+const defaultCampaign = "default"
+
+// header is the first line of every export
+var header = []string{"call_time", "session_id", "lead_id", "node", "question", "answer", "classification", "outcome", "disposition"}
+
+// Row is one question a caller answered
+type Row struct {
+	CallTime       string // start of the call, RFC 3339
+	SessionID      string
+	LeadID         string
+	Node           string
+	Question       string // the node's content
+	Answer         string // the caller's words as transcribed
+	Classification string
+	Outcome        string // how the flow ended: transfer, hangup, interrupt, error or incomplete
+	Disposition    string // the last status posted to Vicidial, if any
+}
+
+func (r Row) record() []string {
+	return []string{r.CallTime, r.SessionID, r.LeadID, r.Node, r.Question, r.Answer, r.Classification, r.Outcome, r.Disposition}
+}
+
+// Filter selects the calls to export
+type Filter struct {
+	From, To time.Time // call start dates, inclusive; zero = open
+	Campaign string    // empty = all
+}
+
+// logEvent is the part of a session log record the export uses
+type logEvent struct {
+	Timestamp      string            `json:"ts"`
+	Event          string            `json:"event"`
+	SessionID      string            `json:"session_id"`
+	NodeID         string            `json:"node_id"`
+	NodeContent    string            `json:"node_content"`
+	Text           string            `json:"text"`
+	Classification string            `json:"classification"`
+	Details        map[string]string `json:"details"`
+}
+
+// Export collects the Q&A rows of session logs by campaign
+type Export struct {
+	Sessions  int
+	Campaigns map[string][]Row
+}
+
+// NewExport returns an empty export
+func NewExport() *Export {
+	return &Export{Campaigns: make(map[string][]Row)}
+}
+
+// AddSession reads one session log and adds its answers if the call passes
+// filter. It reports whether the call was exported.
+func (e *Export) AddSession(r io.Reader, filter Filter) (bool, error) {
+	var events []logEvent
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var ev logEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		events = append(events, ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return false, err
+	}
+	if len(events) == 0 {
+		return false, nil
+	}
+
+	started, err := time.Parse(time.RFC3339Nano, events[0].Timestamp)
+	if err != nil {
+		return false, nil
+	}
+	day := time.Date(started.Year(), started.Month(), started.Day(), 0, 0, 0, 0, time.UTC)
+	if !filter.From.IsZero() && day.Before(filter.From) || !filter.To.IsZero() && day.After(filter.To) {
+		return false, nil
+	}
+
+	// The call's details are logged around its answers, so collect them first
+	campaign, leadID, outcome, disposition := "", "", "incomplete", ""
+	var rows []Row
+	for _, ev := range events {
+		switch ev.Event {
+		case "flow_version":
+			campaign = ev.Details["campaign"]
+		case "caller":
+			leadID = ev.Details["lead_id"]
+		case "flow_end":
+			outcome = ev.Details["reason"]
+		case "api_call":
+			if status := ev.Details["vd_status"]; status != "" {
+				disposition = status
+			}
+		case "qna":
+			rows = append(rows, Row{
+				CallTime:       started.Format(time.RFC3339),
+				SessionID:      ev.SessionID,
+				Node:           ev.NodeID,
+				Question:       ev.NodeContent,
+				Answer:         ev.Text,
+				Classification: ev.Classification,
+			})
+		}
+	}
+	if campaign == "" {
+		campaign = defaultCampaign
+	}
+	if filter.Campaign != "" && campaign != filter.Campaign {
+		return false, nil
+	}
+
+	e.Sessions++
+	for _, row := range rows {
+		row.LeadID = leadID
+		row.Outcome = outcome
+		row.Disposition = disposition
+		e.Campaigns[campaign] = append(e.Campaigns[campaign], row)
+	}
+	return true, nil
+}
+
+// WriteCSV writes a campaign's rows, oldest call first
+func (e *Export) WriteCSV(w io.Writer, campaign string) error {
+	rows := e.Campaigns[campaign]
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].CallTime < rows[j].CallTime })
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, row := range rows {
+		cw.Write(row.record())
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// CampaignNames returns the exported campaigns in order
+func (e *Export) CampaignNames() []string {
+	campaigns := make([]string, 0, len(e.Campaigns))
+	for campaign := range e.Campaigns {
+		campaigns = append(campaigns, campaign)
+	}
+	sort.Strings(campaigns)
+	return campaigns
+}
+
+// WriteFiles writes one CSV per campaign into dir as qna_<campaign>.csv and
+// returns the paths written, in CampaignNames order
+func (e *Export) WriteFiles(dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	var paths []string
+	for _, campaign := range e.CampaignNames() {
+		path := filepath.Join(dir, "qna_"+fileSafe(campaign)+".csv")
+		f, err := os.Create(path)
+		if err != nil {
+			return paths, fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		err = e.WriteCSV(f, campaign)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return paths, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// fileSafe replaces characters a campaign ID may contain that do not belong
+// in a file name
+func fileSafe(campaign string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, campaign)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExport(t *testing.T) {
+	sessions := []string{
+		// Transferred, answers include a comma and quotes
+		`{"ts":"2026-10-03T10:00:00Z","event":"build","session_id":"s2"}
+{"event":"flow_version","session_id":"s2","details":{"campaign":"SOLAR","version":"2"}}
+{"event":"caller","session_id":"s2","details":{"lead_id":"1002"}}
+{"event":"qna","session_id":"s2","node_id":"start","node_content":"Do you own your home?","text":"yes, I \"own\" it","classification":"positive"}
+{"event":"qna","session_id":"s2","node_id":"bill","node_content":"Is your bill over $100?","text":"about two hundred","classification":"positive"}
+{"event":"api_call","session_id":"s2","details":{"endpoint":"/transfer_call","status":"ok","vd_status":"XFER"}}
+{"event":"flow_end","session_id":"s2","details":{"reason":"transfer"}}`,
+		// Earlier call, same campaign
+		`{"ts":"2026-10-02T09:00:00Z","event":"build","session_id":"s1"}
+{"event":"flow_version","session_id":"s1","details":{"campaign":"SOLAR","version":"2"}}
+{"event":"caller","session_id":"s1","details":{"lead_id":"1001"}}
+{"event":"qna","session_id":"s1","node_id":"start","node_content":"Do you own your home?","text":"no","classification":"negative"}
+{"event":"api_call","session_id":"s1","details":{"endpoint":"/end_call","status":"ok","vd_status":"NI"}}
+{"event":"flow_end","session_id":"s1","details":{"reason":"hangup"}}`,
+		// No campaign, the caller hung up mid-flow
+		`{"ts":"2026-10-04T09:00:00Z","event":"build","session_id":"s3"}
+{"event":"qna","session_id":"s3","node_id":"start","node_content":"Do you own your home?","text":"who is this","classification":"unknown"}`,
+		// Outside the date range
+		`{"ts":"2026-10-20T09:00:00Z","event":"build","session_id":"s4"}
+{"event":"qna","session_id":"s4","node_id":"start","text":"yes","classification":"positive"}`,
+	}
+	filter := Filter{To: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)}
+	e := NewExport()
+	for i, s := range sessions {
+		ok, err := e.AddSession(strings.NewReader(s), filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != (i < 3) {
+			t.Errorf("session %d exported = %v", i, ok)
+		}
+	}
+	if got := e.CampaignNames(); !reflect.DeepEqual(got, []string{"SOLAR", "default"}) {
+		t.Fatalf("campaigns = %v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := e.WriteCSV(&buf, "SOLAR"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		header,
+		{"2026-10-02T09:00:00Z", "s1", "1001", "start", "Do you own your home?", "no", "negative", "hangup", "NI"},
+		{"2026-10-03T10:00:00Z", "s2", "1002", "start", "Do you own your home?", `yes, I "own" it`, "positive", "transfer", "XFER"},
+		{"2026-10-03T10:00:00Z", "s2", "1002", "bill", "Is your bill over $100?", "about two hundred", "positive", "transfer", "XFER"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("SOLAR export:\n got %q\nwant %q", records, want)
+	}
+
+	dir := t.TempDir()
+	paths, err := e.WriteFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "qna_SOLAR.csv"), filepath.Join(dir, "qna_default.csv")}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+	data, err := os.ReadFile(paths[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "s3,,start,Do you own your home?,who is this,unknown,incomplete,\n") {
+		t.Errorf("default export:\n%s", data)
+	}
+}
+
+func TestExportCampaignFilter(t *testing.T) {
+	e := NewExport()
+	ok, err := e.AddSession(strings.NewReader(`{"ts":"2026-10-03T10:00:00Z","event":"build"}
+{"event":"flow_version","details":{"campaign":"AUTO"}}
+{"event":"qna","node_id":"start","text":"yes","classification":"positive"}`), Filter{Campaign: "SOLAR"})
+	if err != nil || ok {
+		t.Errorf("AddSession = %v, %v; want other campaigns skipped", ok, err)
+	}
+}
+
+func TestFileSafe(t *testing.T) {
+	if got := fileSafe("solar/../Q4 2026"); got != "solar____Q4_2026" {
+		t.Errorf("fileSafe = %q", got)
+	}
+}
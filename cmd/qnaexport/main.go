@@ -0,0 +1,100 @@
+// Command qnaexport flattens the questions and answers in session logs into
+// one CSV per campaign, for analysts who work in spreadsheets rather than
+// JSONL:
+//
+//	qnaexport -out ./exports -from 2026-10-01 -to 2026-10-15 ./transcripts
+//
+// Directories are searched for session logs (*_session_*.jsonl). Each row is
+// one answered question: the call, lead_id, node, question, the caller's
+// verbatim answer, its classification, how the flow ended and the Vicidial
+// disposition. Calls without a campaign_id go to qna_default.csv.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+	var out, from, to string
+	var filter Filter
+	flag.StringVar(&out, "out", ".", "Directory to write qna_<campaign>.csv files to")
+	flag.StringVar(&from, "from", "", "First call date, YYYY-MM-DD")
+	flag.StringVar(&to, "to", "", "Last call date, YYYY-MM-DD")
+	flag.StringVar(&filter.Campaign, "campaign", "", "Only export this campaign")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] session-log-or-dir...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	var err error
+	if filter.From, err = parseDate(from); err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+	if filter.To, err = parseDate(to); err != nil {
+		log.Fatalf("Invalid -to: %v", err)
+	}
+
+	files, err := sessionLogs(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to list session logs: %v", err)
+	}
+	export := NewExport()
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", file, err)
+		}
+		_, err = export.AddSession(f, filter)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", file, err)
+		}
+	}
+
+	paths, err := export.WriteFiles(out)
+	if err != nil {
+		log.Fatalf("Failed to export: %v", err)
+	}
+	fmt.Printf("%d of %d session logs exported\n", export.Sessions, len(files))
+	for i, campaign := range export.CampaignNames() {
+		fmt.Printf("  %s: %d answers\n", paths[i], len(export.Campaigns[campaign]))
+	}
+}
+
+// parseDate parses a YYYY-MM-DD flag; empty means no bound
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// sessionLogs expands directories in args to the session logs they contain
+func sessionLogs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*_session_*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+// fallbackNode is where the engine goes when a timeout or digit outcome has
+// no transition
+const fallbackNode = "end_call"
+
+// Marks of script lines, as in a unified diff
+const (
+	Scripted = ' ' // said where the flow says it
+	Skipped  = '-' // the flow says it here, the bot did not
+	Extra    = '+' // the bot said it, the flow does not have it here
+)
+
+// Line is one prompt of a session's script
+type Line struct {
+	Mark    byte
+	Node    string
+	Content string
+	Note    string // why the line deviates
+}
+
+// Diff is what a session's bot said against what its flow scripts
+type Diff struct {
+	SessionID string
+	Version   string // flow version the session ran, if logged
+	Lines     []Line
+}
+
+// Deviations counts the lines that were skipped or not scripted
+func (d *Diff) Deviations() int {
+	n := 0
+	for _, line := range d.Lines {
+		if line.Mark != Scripted {
+			n++
+		}
+	}
+	return n
+}
+
+// logEvent is the part of a session log record the diff uses
+type logEvent struct {
+	Event       string            `json:"event"`
+	SessionID   string            `json:"session_id"`
+	NodeID      string            `json:"node_id"`
+	NodeContent string            `json:"node_content"`
+	Details     map[string]string `json:"details"`
+}
+
+// Script walks a flow along the outcomes a session logged
+type Script struct {
+	flow  *flow.FlowConfig
+	nodes map[string]*flow.FlowNode
+}
+
+// NewScript prepares diffs against cfg
+func NewScript(cfg *flow.FlowConfig) *Script {
+	s := &Script{flow: cfg, nodes: make(map[string]*flow.FlowNode)}
+	for i := range cfg.Nodes {
+		// The engine uses the first node with an ID
+		if _, ok := s.nodes[cfg.Nodes[i].ID]; !ok {
+			s.nodes[cfg.Nodes[i].ID] = &cfg.Nodes[i]
+		}
+	}
+	return s
+}
+
+// Compare reads one session log and lines up the prompts its bot started
+// (node_start records) with the ones the flow scripts for the answers,
+// timeouts and interrupts the session logged. A scripted node the bot went
+// past is Skipped, a node it started instead is Extra; a node started again
+// where the flow does not loop back to it is a repeated prompt. Interrupts
+// may enter any node. The diff stops where the session chains to another
+// flow.
+func (s *Script) Compare(r io.Reader) (*Diff, error) {
+	d := &Diff{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	prev, key := "", ""
+	for scanner.Scan() {
+		var ev logEvent
+		if json.Unmarshal(scanner.Bytes(), &ev) != nil {
+			continue
+		}
+		if d.SessionID == "" {
+			d.SessionID = ev.SessionID
+		}
+		switch ev.Event {
+		case "flow_version":
+			d.Version = ev.Details["version"]
+		case "transition":
+			key = ev.Details["reason"]
+		case "timeout":
+			key = "timeout"
+		case "interrupt":
+			key = "interrupt"
+		case "flow_chain":
+			return d, scanner.Err()
+		case "node_start":
+			s.step(d, prev, key, ev)
+			prev, key = ev.NodeID, ""
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// Audio nodes move on as soon as their prompt starts, so one that ends
+	// the log never reached its next node
+	if node := s.nodes[prev]; node != nil && node.Type == "audio" && node.Transitions["default"] != "" {
+		s.skip(d, node.Transitions["default"], "not reached after "+prev)
+	}
+	return d, nil
+}
+
+// step adds the node a session started after prev on key
+func (s *Script) step(d *Diff, prev, key string, ev logEvent) {
+	said := Line{Mark: Scripted, Node: ev.NodeID, Content: ev.NodeContent}
+	want := "start"
+	if prev != "" {
+		want = s.next(prev, key, ev.NodeID)
+	}
+	switch {
+	case want == ev.NodeID:
+	case s.nodes[ev.NodeID] == nil:
+		if want != "" {
+			s.skip(d, want, onKey(prev, key)+" leads here")
+		}
+		said.Mark, said.Note = Extra, "not in the flow"
+	case want == "":
+	case ev.NodeID == prev:
+		said.Mark, said.Note = Extra, "repeated; "+onKey(prev, key)+" leads to "+want
+	default:
+		s.skip(d, want, onKey(prev, key)+" leads here")
+		said.Mark, said.Note = Extra, "not scripted after "+prev
+	}
+	d.Lines = append(d.Lines, said)
+}
+
+// skip adds a scripted node the session did not start
+func (s *Script) skip(d *Diff, id, note string) {
+	line := Line{Mark: Skipped, Node: id, Note: note}
+	if node := s.nodes[id]; node != nil {
+		line.Content = node.Content
+	}
+	d.Lines = append(d.Lines, line)
+}
+
+// next returns the node the flow enters from prev on key, "" when any node
+// may follow (an interrupt, or a node the flow does not have)
+func (s *Script) next(prev, key, to string) string {
+	node := s.nodes[prev]
+	if node == nil || key == "interrupt" {
+		return ""
+	}
+	// Interrupts raised outside a question log no reason
+	if target := s.nodes[to]; key == "" && target != nil && target.Type == "interrupt" && node.Transitions["default"] != to {
+		return ""
+	}
+	if fb := s.flow.Metadata.ErrorFallback; key == "error" && fb != nil {
+		return fb.Node
+	}
+	if key == "" {
+		key = "default"
+	}
+	if next := node.Transitions[key]; next != "" {
+		return next
+	}
+	if next := node.Transitions["default"]; next != "" {
+		return next
+	}
+	return fallbackNode
+}
+
+// onKey describes leaving node on key
+func onKey(node, key string) string {
+	if key == "" {
+		return node
+	}
+	return node + " on " + key
+}
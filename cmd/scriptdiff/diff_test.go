@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+const testFlow = `{"metadata": {"name": "test", "version": "2"}, "nodes": [
+ {"id": "start", "type": "audio", "content": "Hi there.", "transitions": {"default": "ask"}},
+ {"id": "ask", "type": "question", "content": "Interested?", "transitions": {"positive": "offer", "unknown": "ask", "default": "bye"}},
+ {"id": "offer", "type": "audio", "content": "Great offer.", "transitions": {"default": "confirm"}},
+ {"id": "confirm", "type": "question", "content": "Shall I connect you?", "transitions": {"positive": "transfer", "default": "bye"}},
+ {"id": "dnc", "type": "interrupt", "transitions": {"default": "bye"}},
+ {"id": "transfer", "type": "transfer"},
+ {"id": "bye", "type": "hangup", "content": "Goodbye."},
+ {"id": "end_call", "type": "hangup"}
+]}`
+
+func TestCompare(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	if err := os.WriteFile(path, []byte(testFlow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := flow.LoadFlowConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := NewScript(cfg)
+
+	for _, tt := range []struct {
+		name string
+		log  string
+		want []string // mark and node of each line
+	}{
+		{"as scripted, with a re-ask the flow loops to and an interrupt", `{"event":"flow_version","session_id":"s1","details":{"version":"2"}}
+{"event":"node_start","node_id":"start"}
+{"event":"node_start","node_id":"ask"}
+{"event":"transition","node_id":"ask","next_node_id":"ask","details":{"reason":"unknown"}}
+{"event":"node_start","node_id":"ask"}
+{"event":"timeout","node_id":"ask"}
+{"event":"node_start","node_id":"bye"}
+{"event":"node_start","node_id":"dnc"}
+{"event":"node_start","node_id":"bye"}`,
+			[]string{" start", " ask", " ask", " bye", " dnc", " bye"}},
+		{"skipped node", `{"event":"node_start","node_id":"start"}
+{"event":"node_start","node_id":"ask"}
+{"event":"transition","node_id":"ask","next_node_id":"offer","details":{"reason":"positive"}}
+{"event":"node_start","node_id":"offer"}
+{"event":"node_start","node_id":"transfer"}`,
+			[]string{" start", " ask", " offer", "-confirm", "+transfer"}},
+		{"repeated prompt", `{"event":"node_start","node_id":"start"}
+{"event":"node_start","node_id":"ask"}
+{"event":"transition","node_id":"ask","next_node_id":"ask","details":{"reason":"negative"}}
+{"event":"node_start","node_id":"ask"}`,
+			[]string{" start", " ask", "+ask"}},
+		{"audio node ending the log, then a chained flow", `{"event":"node_start","node_id":"start"}
+{"event":"node_start","node_id":"gone"}
+{"event":"flow_chain","node_id":"gone"}
+{"event":"node_start","node_id":"other"}`,
+			[]string{" start", "-ask", "+gone"}},
+		{"audio node ending the log", `{"event":"node_start","node_id":"start"}`,
+			[]string{" start", "-ask"}},
+	} {
+		d, err := script.Compare(strings.NewReader(tt.log))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, line := range d.Lines {
+			got = append(got, string(line.Mark)+line.Node)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: lines %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	d, _ := script.Compare(strings.NewReader(`{"event":"flow_version","session_id":"s1","details":{"version":"2"}}
+{"event":"node_start","node_id":"start","node_content":"Hi there."}`))
+	if d.SessionID != "s1" || d.Version != "2" || d.Deviations() != 1 || d.Lines[1].Content != "Interested?" {
+		t.Errorf("diff = %+v", d)
+	}
+}
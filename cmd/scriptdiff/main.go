@@ -0,0 +1,112 @@
+// Command scriptdiff compares what the bot said on calls with what their
+// flow scripts for them, to catch regressions after engine changes:
+//
+//	scriptdiff -flow config/flow.json ./transcripts
+//
+// Each session log (*_session_*.jsonl in directories) is walked through the
+// flow along the answers, timeouts and interrupts it logged. Sessions whose
+// prompts deviate, by skipping a scripted node, starting one the flow does
+// not lead to or repeating a prompt, are printed as a diff: "-" lines were
+// scripted but not said, "+" lines were said but not scripted. -v prints the
+// sessions that followed the script too. The exit status is 1 when any
+// session deviates.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+func main() {
+	var flowPath, version string
+	var verbose bool
+	flag.StringVar(&flowPath, "flow", "config/flow.json", "Flow file the sessions ran")
+	flag.StringVar(&version, "version", "", "Only sessions that ran this flow version")
+	flag.BoolVar(&verbose, "v", false, "Also print sessions that followed the script")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] session-log-or-dir...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := flow.LoadFlowConfig(flowPath)
+	if err != nil {
+		log.Fatalf("Failed to load flow: %v", err)
+	}
+	script := NewScript(cfg)
+
+	files, err := sessionLogs(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to list session logs: %v", err)
+	}
+	compared, deviating := 0, 0
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", file, err)
+		}
+		d, err := script.Compare(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", file, err)
+		}
+		if len(d.Lines) == 0 || version != "" && d.Version != version {
+			continue
+		}
+		compared++
+		if d.Deviations() > 0 {
+			deviating++
+		} else if !verbose {
+			continue
+		}
+		printDiff(os.Stdout, file, d)
+	}
+
+	fmt.Printf("%d of %d sessions deviate from %s (%s %s)\n", deviating, compared, flowPath, cfg.Metadata.Name, cfg.Metadata.Version)
+	if deviating > 0 {
+		os.Exit(1)
+	}
+}
+
+// printDiff prints a session's script with its deviations marked
+func printDiff(w io.Writer, file string, d *Diff) {
+	fmt.Fprintf(w, "Session %s (%s): %d deviations\n", d.SessionID, file, d.Deviations())
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, line := range d.Lines {
+		fmt.Fprintf(tw, "%c %s\t%s\t%s\n", line.Mark, line.Node, line.Content, line.Note)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+}
+
+// sessionLogs expands directories in args to the session logs they contain
+func sessionLogs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*_session_*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
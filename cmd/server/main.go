@@ -1,13 +1,27 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/buildinfo"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/defaults"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/notify"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/qa"
 	"github.com/amanullahtanweer/audiosocket-transcriber/internal/server"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/tts"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,19 +29,85 @@ type Config struct {
     Server struct {
         Host string `yaml:"host"`
         Port int    `yaml:"port"`
+        AllowList []string `yaml:"allow_list"` // optional CIDRs/IPs allowed to connect
+        ProxyProtocol bool     `yaml:"proxy_protocol"` // read PROXY v1/v2 headers from HAProxy or an NLB
+        ProxyTrusted  []string `yaml:"proxy_trusted"`  // load balancers sending them (default every peer)
+        AdminAddr string   `yaml:"admin_addr"` // optional live session API, e.g. 127.0.0.1:9020
+        DeadAirSeconds int  `yaml:"dead_air_seconds"` // hang up if no caller audio within N seconds (0 = off)
+        DeadAirStatus  string `yaml:"dead_air_status"` // disposition for dead-air calls (default DC)
+        ReadTimeoutMs  int    `yaml:"read_timeout_ms"`  // per-read deadline (default 2000)
+        ReadRetries    int    `yaml:"read_retries"`     // consecutive read timeouts tolerated (default 5)
+        StaleSeconds   int    `yaml:"stale_seconds"`    // end sessions with no inbound frames for N seconds (0 = off)
+        StaleStatus    string `yaml:"stale_status"`     // disposition for stale sessions (default DC)
+        KeepAliveMs    int    `yaml:"keepalive_ms"`     // send a silence frame when no audio was sent for N ms (0 = off)
+        DuplicatePolicy string `yaml:"duplicate_policy"` // "reject" (default) or "adopt" for reused call UUIDs
+        Workers        int    `yaml:"workers"`          // connections handled concurrently (0 = unbounded)
+        AcceptQueue    int    `yaml:"accept_queue"`     // connections waiting for a worker before new ones are refused (default = workers)
+        Listeners      int    `yaml:"listeners"`        // accept loops sharing the port via SO_REUSEPORT (Linux/BSD)
+        AdvertiseAddr  string `yaml:"advertise_addr"`   // register in Redis for fleet routing as this host:port
+        Capacity       int    `yaml:"capacity"`         // concurrent calls advertised to the fleet (default workers or 100)
+        DrainSeconds   int    `yaml:"drain_seconds"`    // on SIGTERM, wait N seconds for calls to end (default 25)
+        DrainStatus    string `yaml:"drain_status"`     // disposition for calls still up after the drain (default DC)
+        AuditLog       string `yaml:"audit_log"`        // append-only log of admin API actions, e.g. /var/log/audiosocket/audit.jsonl
+        PromptCheck    string `yaml:"prompt_check"`     // verify prompt audio at startup and flow staging: "warn" or "strict" (refuse)
+        LiveListen     bool   `yaml:"live_listen"`      // let supervisors listen to calls through the admin API
+        // Turn new calls away while ASR or Vicidial latency is too high
+        LoadShedding struct {
+            ASRLatencyMs      int     `yaml:"asr_latency_ms"`      // average ASR latency that starts shedding (0 = not watched)
+            VicidialLatencyMs int     `yaml:"vicidial_latency_ms"` // average Vicidial API latency that starts shedding (0 = not watched)
+            WindowSeconds     int     `yaml:"window_seconds"`      // latencies averaged over N seconds (default 30)
+            MinSamples        int     `yaml:"min_samples"`         // samples needed before shedding starts (default 5)
+            Resume            float64 `yaml:"resume"`              // accept calls again below this fraction of the thresholds (default 0.8)
+            Status            string  `yaml:"status"`              // disposition of calls turned away (default DROP)
+            Prompt            string  `yaml:"prompt"`              // optional message played before hanging up
+        } `yaml:"load_shedding"`
+        AdminAuth      struct {
+            Keys []struct {
+                Name string `yaml:"name"`
+                Key  string `yaml:"key"`
+                Role string `yaml:"role"` // "read" or "control"
+            } `yaml:"keys"`
+            TLSCert     string            `yaml:"tls_cert"`
+            TLSKey      string            `yaml:"tls_key"`
+            ClientCA    string            `yaml:"client_ca"`    // accept client certificates signed by this CA
+            ClientRoles map[string]string `yaml:"client_roles"` // client certificate CN -> role
+        } `yaml:"admin_auth"`
     } `yaml:"server"`
     
     Transcription struct {
-        Provider        string `yaml:"provider"` // "vosk" or "assemblyai"
+        Provider        string `yaml:"provider"` // "vosk", "vosk_local", "assemblyai" or a registered provider
         OutputDir       string `yaml:"output_dir"`
         SaveTranscripts bool   `yaml:"save_transcripts"`
         SaveAudio       bool   `yaml:"save_audio"`
+        AudioSpillMB    int    `yaml:"audio_spill_mb"` // recorded audio held in memory before spilling to disk (0 = 8MB, -1 = never)
         SaveSessionLogs bool   `yaml:"save_session_logs"`
+        SubtitleFormats []string `yaml:"subtitle_formats"` // optional: "srt", "vtt"
+        Normalize struct {
+            Casing      bool `yaml:"casing"`      // capitalize sentences and "I"
+            Punctuation bool `yaml:"punctuation"` // end utterances with "." or "?"
+            Numbers     bool `yaml:"numbers"`     // "twenty five" -> "25"
+        } `yaml:"normalize"` // readable saved transcripts and subtitles (Vosk output is lowercase and unpunctuated)
+        DebugSampleRate float64  `yaml:"debug_sample_rate"` // fraction of calls with a detailed debug capture, e.g. 0.01
+        DebugLeadIDs    []string `yaml:"debug_lead_ids"`    // leads always captured
+        CaptureProviderFrames bool `yaml:"capture_provider_frames"` // dump raw Vosk/AssemblyAI frames for every call
+        StandbyConnections    int  `yaml:"standby_connections"`      // provider connections kept open for new calls (0 = off)
+        StandbyMaxIdleSeconds int  `yaml:"standby_max_idle_seconds"` // replace standby connections unused this long (default 60)
+
+        // Optional per-call provider selection
+        ProviderVar   string `yaml:"provider_var"` // Redis field that forces a provider, e.g. "transcriber"
+        ProviderRules []struct {
+            Campaign string `yaml:"campaign"`
+            Language string `yaml:"language"`
+            Provider string `yaml:"provider"`
+        } `yaml:"provider_rules"`
     } `yaml:"transcription"`
     
     Vosk struct {
-        ServerURL  string `yaml:"server_url"`
-        SampleRate int    `yaml:"sample_rate"`
+        ServerURL  string   `yaml:"server_url"`
+        ServerURLs []string `yaml:"server_urls"` // optional: balance across several Vosk servers
+        ModelPath  string   `yaml:"model_path"`  // model dir for provider "vosk_local" (build with -tags vosk)
+        SampleRate int      `yaml:"sample_rate"`
+        ModelSampleRate int `yaml:"model_sample_rate"` // optional: model rate if it differs from sample_rate (e.g. 8000 for 16k sessions)
     } `yaml:"vosk"`
     
     AssemblyAI struct {
@@ -35,6 +115,10 @@ type Config struct {
         SampleRate int    `yaml:"sample_rate"`
     } `yaml:"assemblyai"`
 
+    // Settings of providers added with transcriber.Register, by name, passed
+    // to the provider as they are
+    Providers map[string]map[string]any `yaml:"providers"`
+
     Vicidial struct {
         ServerURL      string `yaml:"server_url"`
         AdminDir       string `yaml:"admin_dir"`
@@ -44,6 +128,13 @@ type Config struct {
         SourceAdmin    string `yaml:"source_admin"`
         TransferStatus string `yaml:"transfer_status"`
         TransferPhone  string `yaml:"transfer_phone"`
+        ReconcileSeconds       int `yaml:"reconcile_seconds"`        // re-check posted dispositions every N seconds (0 = off)
+        ReconcileWindowMinutes int `yaml:"reconcile_window_minutes"` // stop re-checking after N minutes (default 60)
+        TransferCheckSeconds        int      `yaml:"transfer_check_seconds"`         // after a transfer, check the lead every N seconds for an agent (0 = off)
+        TransferCheckTimeoutSeconds int      `yaml:"transfer_check_timeout_seconds"` // count the transfer abandoned after N seconds (default 120)
+        AbandonStatuses             []string `yaml:"abandon_statuses"`               // lead statuses of a dropped transfer (default DROP, XDROP, AFTHRS, NANQUE, HOLDTO, WAITTO)
+        AgentCacheSize              int      `yaml:"agent_cache_size"`               // leads whose agent user is shared between calls (0 = per call only)
+        AgentCacheSeconds           int      `yaml:"agent_cache_seconds"`            // how long a shared agent user is trusted
     } `yaml:"vicidial"`
 
     Redis struct {
@@ -51,58 +142,416 @@ type Config struct {
         DB     int    `yaml:"db"`     // default 0
         Prefix string `yaml:"prefix"` // optional; leave empty to use bare UUID keys
     } `yaml:"redis"`
+
+    DNC struct {
+        RedisKey string `yaml:"redis_key"` // Redis set of blocked numbers
+        File     string `yaml:"file"`      // one number per line
+        Prompt   string `yaml:"prompt"`    // message played before hanging up (default dnc.wav)
+    } `yaml:"dnc"`
+
+    // Short "we just spoke" flow for leads the dialer calls again soon after a call
+    RecentCalls struct {
+        Flow    string `yaml:"flow"`    // flow file run instead of the campaign's flow
+        Minutes int    `yaml:"minutes"` // a call within N minutes of the lead's last is a redial (default 10)
+    } `yaml:"recent_calls"`
+
+    // Optional calendar confirmed callbacks are booked in besides Vicidial
+    Calendar struct {
+        URL             string `yaml:"url"`              // CalDAV collection ending in "/", or an iCalendar webhook
+        Authorization   string `yaml:"authorization"`    // Authorization header, e.g. "Bearer <token>"
+        DurationMinutes int    `yaml:"duration_minutes"` // length of the booked slot (default 15)
+    } `yaml:"calendar"`
+
+    // Optional senders of the flow's notify actions (follow-up SMS and email)
+    Notify struct {
+        Twilio struct {
+            AccountSID string `yaml:"account_sid"`
+            AuthToken  string `yaml:"auth_token"`
+            From       string `yaml:"from"` // sending number or messaging service SID
+        } `yaml:"twilio"`
+        SendGrid struct {
+            APIKey   string `yaml:"api_key"`
+            From     string `yaml:"from"`      // verified sender address
+            FromName string `yaml:"from_name"` // optional display name
+        } `yaml:"sendgrid"`
+    } `yaml:"notify"`
+
+    // Optional speech synthesis of the flow's tts_text prompts
+    TTS struct {
+        Provider string `yaml:"provider"`  // google, azure, elevenlabs or coqui
+        APIKey   string `yaml:"api_key"`   // google, azure (subscription key), elevenlabs
+        Voice    string `yaml:"voice"`     // voice name, ElevenLabs voice ID or Coqui speaker
+        Language string `yaml:"language"`  // google, coqui, e.g. "en-US"
+        Region   string `yaml:"region"`    // azure, e.g. "eastus"
+        URL      string `yaml:"url"`       // Coqui server; optional for the others
+        CacheDir string `yaml:"cache_dir"` // synthesized prompts are kept here (default ./audios/tts)
+    } `yaml:"tts"`
+
+    // Optional offline re-transcription of saved recordings after each call
+    Retranscription struct {
+        Provider string `yaml:"provider"` // whisper
+        URL      string `yaml:"url"`      // OpenAI-compatible API (default https://api.openai.com)
+        APIKey   string `yaml:"api_key"`
+        Model    string `yaml:"model"`    // default whisper-1
+        Language string `yaml:"language"` // e.g. "en"; detected if empty
+        Prompt   string `yaml:"prompt"`   // vocabulary hint, e.g. plan names
+        Workers  int    `yaml:"workers"`  // calls transcribed at once (default 1)
+    } `yaml:"retranscription"`
+
+    // Optional post-call QA scoring against a compliance rubric
+    QA struct {
+        Rubric string `yaml:"rubric"` // rubric file, e.g. config/qa.yaml
+    } `yaml:"qa"`
+
+    // Optional outbox of the flow's webhook notify actions (Zapier, Make, n8n, ...)
+    Webhooks struct {
+        OutboxDir   string `yaml:"outbox_dir"`    // queued webhooks, delivered at least once
+        MaxAgeHours int    `yaml:"max_age_hours"` // retry an undelivered webhook for N hours (default 24)
+    } `yaml:"webhooks"`
+
+    // Optional detection of shouting or rising agitation from the caller's audio
+    Escalation struct {
+        Enabled   bool    `yaml:"enabled"`
+        RiseDB    float64 `yaml:"rise_db"`    // loudness rise over the caller's baseline (default 6)
+        PitchRise float64 `yaml:"pitch_rise"` // pitch rise over the baseline, 0.2 = 20% (default 0.2)
+        ShoutDB   float64 `yaml:"shout_db"`   // speech level taken as shouting (default -12 dBFS)
+    } `yaml:"escalation"`
+
+    // Optional classification of the caller's background noise (quiet, car, crowd, tv, noisy)
+    Background struct {
+        Enabled bool    `yaml:"enabled"`
+        Seconds float64 `yaml:"seconds"` // background audio heard before deciding (default 4)
+    } `yaml:"background"`
+
+    // Optional hang-up of calls answered by hold music or an IVR instead of a person
+    Machine struct {
+        Enabled bool    `yaml:"enabled"`
+        Seconds float64 `yaml:"seconds"` // caller audio heard before deciding (default 5)
+        Status  string  `yaml:"status"`  // disposition for machine-answered calls (default A)
+    } `yaml:"machine"`
+
+    // Optional beep, fax and SIT tone detection; questions follow "tone:<tone>" transitions
+    Tones struct {
+        Enabled        bool   `yaml:"enabled"`
+        SITDisposition bool   `yaml:"sit_disposition"` // hang up on an early SIT tone as a disconnected number
+        SITSeconds     int    `yaml:"sit_seconds"`     // how early (default 10)
+        SITStatus      string `yaml:"sit_status"`      // disposition (default ADC)
+    } `yaml:"tones"`
+
+    // Experimental behaviors per campaign; Redis hash features:<campaign_id> overrides
+    Features struct {
+        Defaults  map[string]bool            `yaml:"defaults"`
+        Campaigns map[string]map[string]bool `yaml:"campaigns"`
+    } `yaml:"features"`
 }
 
 func main() {
     var configFile string
+    var initDefaults, force, setup, selfTest bool
+    var selfTestScript string
+    var selfTestSeed int64
+    var showVersion bool
+    var verifyAudit string
+    var alertRules string
     flag.StringVar(&configFile, "config", "config.yaml", "Configuration file path")
+    flag.BoolVar(&initDefaults, "init", false, "Write a demo config.yaml, flow, interrupt patterns and prompts to the current directory and exit")
+    flag.BoolVar(&force, "force", false, "With -init, overwrite existing files")
+    flag.BoolVar(&setup, "setup", false, "Interactively configure and test Vosk/AssemblyAI, Redis and Vicidial, then write the config file")
+    flag.BoolVar(&selfTest, "selftest", false, "Run one scripted loopback call through the configured flow, report per node and exit (status 1 on failure)")
+    flag.StringVar(&selfTestScript, "selftest-script", "", "JSON list of {\"node\", \"say\", \"expect\"} answers for -selftest (default: \"yes\" to every question)")
+    flag.Int64Var(&selfTestSeed, "selftest-seed", 0, "Session seed for -selftest, e.g. from a call's session log, to replay its random choices (default random)")
+    flag.BoolVar(&showVersion, "version", false, "Print the build version and exit")
+    flag.StringVar(&verifyAudit, "verify-audit", "", "Check the hash chain of an audit log and exit (status 1 if it was tampered with)")
+    flag.StringVar(&alertRules, "alert-rules", "", "Write the default Prometheus alerting rules to this file (- for stdout) and exit")
     flag.Parse()
 
+    build := buildinfo.Get()
+    if showVersion {
+        fmt.Println(build)
+        return
+    }
+    if verifyAudit != "" {
+        n, err := server.VerifyAuditLog(verifyAudit)
+        if err != nil {
+            log.Fatalf("Audit log %s: %v", verifyAudit, err)
+        }
+        fmt.Printf("Audit log %s: %d records, chain intact\n", verifyAudit, n)
+        return
+    }
+    if alertRules != "" {
+        if err := writeAlertRules(alertRules); err != nil {
+            log.Fatalf("Failed to write alert rules: %v", err)
+        }
+        return
+    }
+    log.Printf("AudioSocket transcriber %s", build)
+
+    if initDefaults {
+        written, skipped, err := defaults.WriteTo(".", force)
+        for _, path := range written {
+            log.Printf("Wrote %s", path)
+        }
+        for _, path := range skipped {
+            log.Printf("Kept existing %s (use -force to overwrite)", path)
+        }
+        if err != nil {
+            log.Fatalf("Failed to write default files: %v", err)
+        }
+        log.Printf("Start a Vosk server on ws://localhost:2700, then run the server to take a demo call")
+        return
+    }
+
+    if setup {
+        if err := runSetup(configFile); err != nil {
+            log.Fatalf("Setup failed: %v", err)
+        }
+        return
+    }
+
     // Load configuration
     config := &Config{}
     if err := loadConfig(configFile, config); err != nil {
         log.Fatalf("Failed to load config: %v", err)
     }
 
-    // Validate provider
-    if config.Transcription.Provider != "vosk" && config.Transcription.Provider != "assemblyai" {
-        log.Fatalf("Invalid transcription provider: %s. Must be 'vosk' or 'assemblyai'", config.Transcription.Provider)
-    }
-
-    // Create server config
-    serverConfig := server.Config{
-        Host:            config.Server.Host,
-        Port:            config.Server.Port,
-        Provider:        config.Transcription.Provider,
-        OutputDir:       config.Transcription.OutputDir,
-        SaveTranscripts: config.Transcription.SaveTranscripts,
-        SaveAudio:       config.Transcription.SaveAudio,
-        SaveSessionLogs: config.Transcription.SaveSessionLogs,
-        AudioDir:        "./audios", // Directory containing audio files
-        VicidialServerURL:   config.Vicidial.ServerURL,
-        VicidialAdminDir:    config.Vicidial.AdminDir,
-        VicidialAPIUser:     config.Vicidial.APIUser,
-        VicidialAPIPass:     config.Vicidial.APIPass,
-        VicidialSourceRA:    config.Vicidial.SourceRA,
-        VicidialSourceAdmin: config.Vicidial.SourceAdmin,
-        TransferStatus:      config.Vicidial.TransferStatus,
-        TransferPhone:       config.Vicidial.TransferPhone,
-        RedisAddr:           config.Redis.Addr,
-        RedisDB:             config.Redis.DB,
-        RedisPrefix:         config.Redis.Prefix,
-    }
-
-    // Add provider-specific config
-    if config.Transcription.Provider == "vosk" {
-        serverConfig.VoskServerURL = config.Vosk.ServerURL
-        serverConfig.SampleRate = config.Vosk.SampleRate
+    if err := validateConfig(config); err != nil {
+        log.Fatalf("Invalid config: %v", err)
+    }
+    var providerRules []server.ProviderRule
+    for _, r := range config.Transcription.ProviderRules {
+        providerRules = append(providerRules, server.ProviderRule{Campaign: r.Campaign, Language: r.Language, Provider: r.Provider})
+    }
+
+    // Build server options
+    opts := []server.Option{
+        server.WithListenAddr(config.Server.Host, config.Server.Port),
+        server.WithOutput(
+            config.Transcription.OutputDir,
+            config.Transcription.SaveTranscripts,
+            config.Transcription.SaveAudio,
+            config.Transcription.SaveSessionLogs,
+        ),
+        server.WithSubtitles(config.Transcription.SubtitleFormats...),
+        server.WithAudioSpill(config.Transcription.AudioSpillMB << 20),
+        server.WithAudioDir(audioDir), // Directory containing audio files
+        server.WithVicidial(server.VicidialConfig{
+            ServerURL:      config.Vicidial.ServerURL,
+            AdminDir:       config.Vicidial.AdminDir,
+            APIUser:        config.Vicidial.APIUser,
+            APIPass:        config.Vicidial.APIPass,
+            SourceRA:       config.Vicidial.SourceRA,
+            SourceAdmin:    config.Vicidial.SourceAdmin,
+            TransferStatus: config.Vicidial.TransferStatus,
+            TransferPhone:  config.Vicidial.TransferPhone,
+        }),
+        server.WithRedis(config.Redis.Addr, config.Redis.DB, config.Redis.Prefix),
+        server.WithAdminAddr(config.Server.AdminAddr),
+        server.WithDrain(time.Duration(config.Server.DrainSeconds)*time.Second, config.Server.DrainStatus),
+        server.WithDuplicatePolicy(config.Server.DuplicatePolicy),
+        server.WithOneWayAudioDetection(time.Duration(config.Server.DeadAirSeconds)*time.Second, config.Server.DeadAirStatus),
+    }
+
+    if auth := config.Server.AdminAuth; len(auth.Keys) > 0 {
+        creds := make([]server.AdminCredential, 0, len(auth.Keys))
+        for _, k := range auth.Keys {
+            creds = append(creds, server.AdminCredential{Name: k.Name, Key: k.Key, Role: k.Role})
+        }
+        opts = append(opts, server.WithAdminAuth(creds...))
+    }
+    if auth := config.Server.AdminAuth; auth.TLSCert != "" || auth.TLSKey != "" || auth.ClientCA != "" {
+        opts = append(opts, server.WithAdminTLS(auth.TLSCert, auth.TLSKey, auth.ClientCA, auth.ClientRoles))
+    }
+    if config.Server.AuditLog != "" {
+        opts = append(opts, server.WithAuditLog(config.Server.AuditLog))
+    }
+    if config.Server.PromptCheck != "" {
+        opts = append(opts, server.WithPromptCheck(config.Server.PromptCheck == "strict"))
+    }
+    if config.Server.AdvertiseAddr != "" {
+        opts = append(opts, server.WithFleet(config.Server.AdvertiseAddr, config.Server.Capacity))
+    }
+    if config.Server.Listeners > 1 {
+        opts = append(opts, server.WithListeners(config.Server.Listeners))
+    }
+    if config.Server.Workers > 0 {
+        opts = append(opts, server.WithWorkerPool(config.Server.Workers, config.Server.AcceptQueue))
+    }
+    if config.Server.StaleSeconds > 0 {
+        opts = append(opts, server.WithHeartbeat(time.Duration(config.Server.StaleSeconds)*time.Second, config.Server.StaleStatus))
+    }
+    if config.Server.KeepAliveMs > 0 {
+        opts = append(opts, server.WithKeepAlive(time.Duration(config.Server.KeepAliveMs)*time.Millisecond))
+    }
+    if config.Server.LiveListen {
+        opts = append(opts, server.WithLiveListen(true))
+    }
+    if ls := config.Server.LoadShedding; ls.ASRLatencyMs > 0 || ls.VicidialLatencyMs > 0 {
+        opts = append(opts, server.WithLoadShedding(server.LoadShedding{
+            ASRLatency:      time.Duration(ls.ASRLatencyMs) * time.Millisecond,
+            VicidialLatency: time.Duration(ls.VicidialLatencyMs) * time.Millisecond,
+            Window:          time.Duration(ls.WindowSeconds) * time.Second,
+            MinSamples:      ls.MinSamples,
+            Resume:          ls.Resume,
+            Status:          ls.Status,
+            Prompt:          ls.Prompt,
+        }))
+    }
+    opts = append(opts, server.WithFeatureFlags(server.FeatureFlags{
+        Defaults:  config.Features.Defaults,
+        Campaigns: config.Features.Campaigns,
+    }))
+    if config.Transcription.CaptureProviderFrames {
+        opts = append(opts, server.WithProviderCapture(true))
+    }
+    if config.Transcription.DebugSampleRate > 0 || len(config.Transcription.DebugLeadIDs) > 0 {
+        opts = append(opts, server.WithDebugSampling(config.Transcription.DebugSampleRate, config.Transcription.DebugLeadIDs...))
+    }
+    if config.DNC.RedisKey != "" || config.DNC.File != "" {
+        opts = append(opts, server.WithDNCList(config.DNC.RedisKey, config.DNC.File, config.DNC.Prompt))
+    }
+    if config.RecentCalls.Flow != "" {
+        opts = append(opts, server.WithRecentCallFlow(time.Duration(config.RecentCalls.Minutes)*time.Minute, config.RecentCalls.Flow))
+    }
+    if e := config.Escalation; e.Enabled {
+        opts = append(opts, server.WithEscalationDetection(audio.EscalationSettings{RiseDB: e.RiseDB, PitchRise: e.PitchRise, ShoutDB: e.ShoutDB}))
+    }
+    if config.Background.Enabled {
+        opts = append(opts, server.WithBackgroundClassification(config.Background.Seconds))
+    }
+    if m := config.Machine; m.Enabled {
+        opts = append(opts, server.WithMachineDetection(m.Seconds, m.Status))
+    }
+    if t := config.Tones; t.Enabled {
+        opts = append(opts, server.WithToneDetection())
+        if t.SITDisposition {
+            opts = append(opts, server.WithSITDisposition(time.Duration(t.SITSeconds)*time.Second, t.SITStatus))
+        }
+    }
+    if config.Vicidial.ReconcileSeconds > 0 {
+        opts = append(opts, server.WithReconciler(
+            time.Duration(config.Vicidial.ReconcileSeconds)*time.Second,
+            time.Duration(config.Vicidial.ReconcileWindowMinutes)*time.Minute,
+        ))
+    }
+    if config.Vicidial.TransferCheckSeconds > 0 {
+        opts = append(opts, server.WithTransferTracking(
+            time.Duration(config.Vicidial.TransferCheckSeconds)*time.Second,
+            time.Duration(config.Vicidial.TransferCheckTimeoutSeconds)*time.Second,
+            config.Vicidial.AbandonStatuses...,
+        ))
+    }
+    if cal := config.Calendar; cal.URL != "" {
+        opts = append(opts, server.WithCalendar(flow.NewICalendar(cal.URL, cal.Authorization, time.Duration(cal.DurationMinutes)*time.Minute)))
+    }
+    if tw := config.Notify.Twilio; tw.AccountSID != "" {
+        opts = append(opts, server.WithNotifier(notify.SMS, notify.NewTwilio(tw.AccountSID, tw.AuthToken, tw.From)))
+    }
+    if sg := config.Notify.SendGrid; sg.APIKey != "" {
+        opts = append(opts, server.WithNotifier(notify.Email, notify.NewSendGrid(sg.APIKey, sg.From, sg.FromName)))
+    }
+    if t := config.TTS; t.Provider != "" {
+        synth, err := tts.New(ttsConfig(config))
+        if err != nil {
+            log.Fatalf("Invalid tts: %v", err)
+        }
+        cacheDir := t.CacheDir
+        if cacheDir == "" {
+            cacheDir = filepath.Join(audioDir, "tts")
+        }
+        voice := strings.Join([]string{t.Provider, t.Voice, t.Language}, ":")
+        opts = append(opts, server.WithTTS(tts.NewCache(cacheDir, voice, synth)))
+    }
+    if rt := config.Retranscription; rt.Provider != "" {
+        whisper := transcriber.NewWhisper(rt.URL, rt.APIKey, rt.Model, rt.Language)
+        whisper.Prompt = rt.Prompt
+        opts = append(opts, server.WithRetranscription(whisper, rt.Workers))
+    }
+    if config.QA.Rubric != "" {
+        rubric, err := qa.LoadRubric(config.QA.Rubric)
+        if err != nil {
+            log.Fatalf("Invalid qa: %v", err)
+        }
+        opts = append(opts, server.WithQARubric(rubric))
+    }
+    if wh := config.Webhooks; wh.OutboxDir != "" {
+        opts = append(opts, server.WithWebhookOutbox(wh.OutboxDir, time.Duration(wh.MaxAgeHours)*time.Hour))
+    }
+    if vc := config.Vicidial; vc.AgentCacheSize > 0 && vc.AgentCacheSeconds > 0 {
+        opts = append(opts, server.WithAgentCache(vc.AgentCacheSize, time.Duration(vc.AgentCacheSeconds)*time.Second))
+    }
+    if config.Server.ReadTimeoutMs > 0 {
+        opts = append(opts, server.WithReadTimeout(time.Duration(config.Server.ReadTimeoutMs)*time.Millisecond, config.Server.ReadRetries))
+    }
+
+    if config.Server.ProxyProtocol {
+        opts = append(opts, server.WithProxyProtocol(config.Server.ProxyTrusted...))
+    }
+    if len(config.Server.AllowList) > 0 {
+        allow, err := server.AllowList(config.Server.AllowList...)
+        if err != nil {
+            log.Fatalf("Invalid server.allow_list: %v", err)
+        }
+        opts = append(opts, server.WithMiddleware(server.LogSessions(), allow))
+    }
+
+    // Add provider-specific config; both are configured so calls can be
+    // routed to either provider, the default provider's sample rate wins
+    voskOpt := server.WithVosk(config.Vosk.ServerURL, config.Vosk.SampleRate)
+    if len(config.Vosk.ServerURLs) > 0 {
+        voskOpt = server.WithVoskPool(config.Vosk.ServerURLs, config.Vosk.SampleRate)
+    }
+    if config.Transcription.Provider == "vosk_local" {
+        opts = append(opts,
+            voskOpt,
+            server.WithAssemblyAI(config.AssemblyAI.APIKey, config.AssemblyAI.SampleRate),
+            server.WithVoskLocal(config.Vosk.ModelPath, config.Vosk.SampleRate),
+        )
+    } else if config.Transcription.Provider == "vosk" {
+        opts = append(opts,
+            server.WithAssemblyAI(config.AssemblyAI.APIKey, config.AssemblyAI.SampleRate),
+            voskOpt,
+        )
     } else {
-        serverConfig.AssemblyAPIKey = config.AssemblyAI.APIKey
-        serverConfig.SampleRate = config.AssemblyAI.SampleRate
+        opts = append(opts,
+            voskOpt,
+            server.WithAssemblyAI(config.AssemblyAI.APIKey, config.AssemblyAI.SampleRate),
+        )
+    }
+    for name, settings := range config.Providers {
+        opts = append(opts, server.WithProviderConfig(name, settings))
+    }
+    if p := config.Transcription.Provider; !transcriber.IsBuiltin(p) {
+        // A registered default provider, with the session rate from its sample_rate
+        opts = append(opts, server.WithProvider(p))
+        if rate, _ := transcriber.ConfigInt(config.Providers[p], "sample_rate", 0); rate > 0 {
+            opts = append(opts, server.WithSampleRate(rate))
+        }
+    }
+    if config.Vosk.ModelSampleRate > 0 {
+        opts = append(opts, server.WithVoskSampleRate(config.Vosk.ModelSampleRate))
+    }
+    if n := config.Transcription.Normalize; n.Casing || n.Punctuation || n.Numbers {
+        opts = append(opts, server.WithTranscriptNormalizer(transcriber.TextNormalizer{Casing: n.Casing, Punctuation: n.Punctuation, Numbers: n.Numbers}))
+    }
+    if t := config.Transcription; t.StandbyConnections > 0 {
+        opts = append(opts, server.WithStandbyTranscribers(t.StandbyConnections, time.Duration(t.StandbyMaxIdleSeconds)*time.Second))
+    }
+    if config.Transcription.ProviderVar != "" || len(providerRules) > 0 {
+        opts = append(opts, server.WithProviderSelection(config.Transcription.ProviderVar, providerRules...))
+    }
+
+    if selfTest {
+        if selfTestSeed != 0 {
+            opts = append(opts, server.WithSeed(selfTestSeed))
+        }
+        if !runSelfTest(selfTestScript, opts) {
+            os.Exit(1)
+        }
+        return
     }
 
     // Create and start server
-    srv, err := server.New(serverConfig)
+    srv, err := server.New(opts...)
     if err != nil {
         log.Fatalf("Failed to create server: %v", err)
     }
@@ -121,8 +570,134 @@ func main() {
     signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
     <-sigChan
 
+    // Drain active calls; a second signal exits immediately
     log.Println("Shutting down server...")
-    srv.Stop()
+    go func() {
+        <-sigChan
+        log.Println("Forced shutdown")
+        os.Exit(1)
+    }()
+    srv.Drain()
+}
+
+// runSelfTest places a scripted loopback call and prints the result per node
+func runSelfTest(scriptFile string, opts []server.Option) bool {
+    var script []server.SelfTestStep
+    if scriptFile != "" {
+        data, err := os.ReadFile(scriptFile)
+        if err != nil {
+            log.Fatalf("Failed to read self-test script: %v", err)
+        }
+        if err := json.Unmarshal(data, &script); err != nil {
+            log.Fatalf("Failed to parse self-test script: %v", err)
+        }
+    }
+
+    report, err := server.SelfTest(script, time.Minute, opts...)
+    if err != nil {
+        log.Fatalf("Self-test failed to start: %v", err)
+    }
+    fmt.Printf("\nSeed %d\n", report.Seed)
+    for _, n := range report.Nodes {
+        result := "PASS"
+        if !n.Passed {
+            result = "FAIL"
+        }
+        line := fmt.Sprintf("%s  %-20s %-10s", result, n.Node, n.Type)
+        if n.Said != "" {
+            line += fmt.Sprintf(" said %q -> %s", n.Said, n.Classification)
+        }
+        if n.Detail != "" {
+            line += "  (" + n.Detail + ")"
+        }
+        fmt.Println(strings.TrimRight(line, " "))
+    }
+    for _, e := range report.Errors {
+        fmt.Printf("FAIL  %s\n", e)
+    }
+    if report.Passed() {
+        fmt.Printf("Self-test passed in %v\n", report.Duration.Round(time.Millisecond))
+        return true
+    }
+    fmt.Printf("Self-test failed after %v\n", report.Duration.Round(time.Millisecond))
+    return false
+}
+
+// writeAlertRules writes the default alerting rules to path, or stdout for -
+func writeAlertRules(path string) error {
+    if path == "-" {
+        return metrics.WriteAlertRules(os.Stdout, "audiosocket", metrics.DefaultAlertRules())
+    }
+    f, err := os.Create(path)
+    if err != nil {
+        return err
+    }
+    if err := metrics.WriteAlertRules(f, "audiosocket", metrics.DefaultAlertRules()); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// validProvider reports whether name is a built-in or registered provider
+func validProvider(name string) bool {
+    _, registered := transcriber.Lookup(name)
+    return transcriber.IsBuiltin(name) || registered
+}
+
+// validateConfig checks the settings the server cannot start without
+func validateConfig(config *Config) error {
+    if !validProvider(config.Transcription.Provider) {
+        return fmt.Errorf("transcription provider %q must be one of %s", config.Transcription.Provider, strings.Join(transcriber.Providers(), ", "))
+    }
+    for _, r := range config.Transcription.ProviderRules {
+        if !validProvider(r.Provider) {
+            return fmt.Errorf("provider %q in transcription.provider_rules", r.Provider)
+        }
+    }
+    if p := config.Transcription.Provider; !transcriber.IsBuiltin(p) {
+        if _, err := transcriber.ConfigInt(config.Providers[p], "sample_rate", 0); err != nil {
+            return fmt.Errorf("providers.%s: %w", p, err)
+        }
+    }
+    if pc := config.Server.PromptCheck; pc != "" && pc != "warn" && pc != "strict" {
+        return fmt.Errorf("server.prompt_check %q must be 'warn' or 'strict'", pc)
+    }
+    if r := config.Server.LoadShedding.Resume; r < 0 || r > 1 {
+        return fmt.Errorf("server.load_shedding.resume %v must be between 0 and 1", r)
+    }
+    if len(config.Server.AllowList) > 0 {
+        if _, err := server.AllowList(config.Server.AllowList...); err != nil {
+            return fmt.Errorf("server.allow_list: %w", err)
+        }
+    }
+    if config.TTS.Provider != "" {
+        if _, err := tts.New(ttsConfig(config)); err != nil {
+            return fmt.Errorf("tts: %w", err)
+        }
+    }
+    if rt := config.Retranscription; rt.Provider != "" {
+        if rt.Provider != "whisper" {
+            return fmt.Errorf("retranscription.provider %q must be 'whisper'", rt.Provider)
+        }
+        if !config.Transcription.SaveAudio {
+            return fmt.Errorf("retranscription needs transcription.save_audio")
+        }
+    }
+    if config.QA.Rubric != "" {
+        if !config.Transcription.SaveSessionLogs {
+            return fmt.Errorf("qa needs transcription.save_session_logs")
+        }
+        if _, err := qa.LoadRubric(config.QA.Rubric); err != nil {
+            return fmt.Errorf("qa: %w", err)
+        }
+    }
+    return nil
+}
+
+func ttsConfig(config *Config) tts.Config {
+    t := config.TTS
+    return tts.Config{Provider: t.Provider, APIKey: t.APIKey, Voice: t.Voice, Language: t.Language, Region: t.Region, URL: t.URL}
 }
 
 func loadConfig(filename string, config *Config) error {
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/defaults"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+	redis "github.com/redis/go-redis/v9"
+	"gopkg.in/yaml.v3"
+)
+
+// audioDir is where the server loads prompts from
+const audioDir = "./audios"
+
+// wizard walks through the settings a new install needs, testing each
+// service as it is configured. It edits the YAML document rather than
+// re-encoding Config so the comments in config.yaml survive.
+type wizard struct {
+	in     *bufio.Scanner
+	out    io.Writer
+	doc    yaml.Node
+	failed []string // checks that did not pass
+}
+
+// runSetup runs the interactive setup and writes configFile. An existing
+// configFile provides the defaults, otherwise the embedded demo config does.
+func runSetup(configFile string) error {
+	data, err := os.ReadFile(configFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err = fs.ReadFile(defaults.Files(), "config.yaml")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+
+	w := &wizard{in: bufio.NewScanner(os.Stdin), out: os.Stdout}
+	if err := yaml.Unmarshal(data, &w.doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", configFile, err)
+	}
+	if w.doc.Kind != yaml.DocumentNode {
+		w.doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	fmt.Fprintf(w.out, "Setting up %s. Press Enter to keep the value in brackets.\n", configFile)
+	w.transcription()
+	w.redis()
+	w.vicidial()
+	w.audio()
+
+	var config Config
+	if err := w.doc.Decode(&config); err != nil {
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+	if err := validateConfig(&config); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+
+	if len(w.failed) > 0 {
+		fmt.Fprintf(w.out, "\nThese checks failed: %s\n", strings.Join(w.failed, ", "))
+		if !w.confirm(fmt.Sprintf("Write %s anyway?", configFile), false) {
+			return fmt.Errorf("setup aborted, %s not written", configFile)
+		}
+	}
+	if err := w.write(configFile); err != nil {
+		return err
+	}
+	fmt.Fprintf(w.out, "\nWrote %s\n", configFile)
+	return nil
+}
+
+// transcription configures and tests the speech-to-text provider
+func (w *wizard) transcription() {
+	fmt.Fprintln(w.out, "\n== Transcription")
+	for {
+		provider := w.ask("Provider ("+strings.Join(transcriber.Providers(), ", ")+")", false, "transcription", "provider")
+		if validProvider(provider) {
+			break
+		}
+		fmt.Fprintf(w.out, "  %q is not a provider\n", provider)
+	}
+
+	switch w.get("transcription", "provider") {
+	case "vosk":
+		w.check("Vosk", func() error {
+			url := w.ask("Vosk server URL", false, "vosk", "server_url")
+			rate, err := w.askInt("Sample rate", "vosk", "sample_rate")
+			if err != nil {
+				return err
+			}
+			t, err := transcriber.NewVoskTranscriber(url, rate)
+			if err != nil {
+				return err
+			}
+			return t.Close()
+		})
+	case "vosk_local":
+		w.check("Vosk model", func() error {
+			dir := w.ask("Vosk model directory", false, "vosk", "model_path")
+			if _, err := os.Stat(filepath.Join(dir, "conf")); err != nil {
+				return fmt.Errorf("%s does not look like a Vosk model: %w", dir, err)
+			}
+			return nil
+		})
+	case "assemblyai":
+		w.check("AssemblyAI", func() error {
+			key := w.ask("AssemblyAI API key", true, "assemblyai", "api_key")
+			rate, err := w.askInt("Sample rate", "assemblyai", "sample_rate")
+			if err != nil {
+				return err
+			}
+			t, err := transcriber.NewAssemblyAITranscriber(key, rate)
+			if err != nil {
+				return err
+			}
+			return t.Close()
+		})
+	}
+}
+
+// redis configures and pings Redis, where the dialplan stores call variables
+func (w *wizard) redis() {
+	fmt.Fprintln(w.out, "\n== Redis")
+	w.check("Redis", func() error {
+		addr := w.ask("Address", false, "redis", "addr")
+		db, err := w.askInt("Database", "redis", "db")
+		if err != nil {
+			return err
+		}
+		w.ask("Key prefix", false, "redis", "prefix")
+
+		client := redis.NewClient(&redis.Options{Addr: addr, DB: db})
+		defer client.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		return client.Ping(ctx).Err()
+	})
+}
+
+// vicidial configures and tests the Vicidial API used for dispositions and
+// transfers; it is optional
+func (w *wizard) vicidial() {
+	fmt.Fprintln(w.out, "\n== Vicidial (leave the URL empty to skip dispositions)")
+	w.check("Vicidial", func() error {
+		url := w.ask("Server URL", false, "vicidial", "server_url")
+		if url == "" {
+			return nil
+		}
+		adminDir := w.ask("Admin directory", false, "vicidial", "admin_dir")
+		user := w.ask("API user", false, "vicidial", "api_user")
+		pass := w.ask("API password", true, "vicidial", "api_pass")
+		source := w.ask("Admin API source", false, "vicidial", "source_admin")
+		api := flow.NewVicidialClient(url, adminDir, user, pass, "", source, "", "")
+		return api.CheckCredentials()
+	})
+}
+
+// audio checks that every prompt decodes the way the player loads it
+func (w *wizard) audio() {
+	fmt.Fprintf(w.out, "\n== Prompts in %s\n", audioDir)
+	files, _ := filepath.Glob(filepath.Join(audioDir, "*.wav"))
+	background, _ := filepath.Glob(filepath.Join(audioDir, "background", "*.wav"))
+	files = append(files, background...)
+	if len(files) == 0 {
+		fmt.Fprintf(w.out, "  FAIL no WAV files found (run with -init for the demo prompts)\n")
+		w.failed = append(w.failed, "prompts")
+		return
+	}
+
+	bad := 0
+	for _, file := range files {
+		pcm, err := audio.LoadWAV(file)
+		if err != nil {
+			fmt.Fprintf(w.out, "  FAIL %s: %v\n", file, err)
+			bad++
+			continue
+		}
+		if levels := audio.MeasureLevels(pcm); levels.Clipping() {
+			fmt.Fprintf(w.out, "  warn %s is clipping (peak %.1f dBFS)\n", file, levels.PeakDB)
+		}
+	}
+	if bad > 0 {
+		w.failed = append(w.failed, "prompts")
+		return
+	}
+	fmt.Fprintf(w.out, "  ok   %d files decode\n", len(files))
+}
+
+// check runs test, which prompts for its settings, until it passes or the
+// user moves on
+func (w *wizard) check(name string, test func() error) {
+	for {
+		err := test()
+		if err == nil {
+			fmt.Fprintf(w.out, "  ok   %s\n", name)
+			return
+		}
+		fmt.Fprintf(w.out, "  FAIL %s: %v\n", name, err)
+		if !w.confirm("Change the settings and retry?", true) {
+			w.failed = append(w.failed, name)
+			return
+		}
+	}
+}
+
+// ask prompts for the setting at path, showing its current value, and stores
+// the answer. Secrets are not echoed back in the prompt.
+func (w *wizard) ask(label string, secret bool, path ...string) string {
+	current := w.get(path...)
+	shown := current
+	if secret && current != "" {
+		shown = "********"
+	}
+	fmt.Fprintf(w.out, "%s [%s]: ", label, shown)
+	if answer := w.readLine(); answer != "" {
+		w.set(answer, "!!str", path...)
+		return answer
+	}
+	return current
+}
+
+// askInt is ask for a numeric setting
+func (w *wizard) askInt(label string, path ...string) (int, error) {
+	current := w.get(path...)
+	answer := w.ask(label, false, path...)
+	if answer == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(answer)
+	if err != nil {
+		// Keep the config decodable if the user gives up on this check
+		if _, cerr := strconv.Atoi(current); cerr != nil {
+			current = "0"
+		}
+		w.set(current, "!!int", path...)
+		return 0, fmt.Errorf("%s: %q is not a number", label, answer)
+	}
+	w.set(answer, "!!int", path...)
+	return n, nil
+}
+
+// confirm asks a yes/no question
+func (w *wizard) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	fmt.Fprintf(w.out, "%s [%s]: ", question, hint)
+	switch strings.ToLower(w.readLine()) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	}
+	return def
+}
+
+// readLine reads one answer; at end of input every answer is empty
+func (w *wizard) readLine() string {
+	if !w.in.Scan() {
+		return ""
+	}
+	return strings.TrimSpace(w.in.Text())
+}
+
+// get returns the scalar at path, or "" if it is not set
+func (w *wizard) get(path ...string) string {
+	node := w.doc.Content[0]
+	for _, key := range path {
+		node = mappingValue(node, key)
+		if node == nil {
+			return ""
+		}
+	}
+	if node.Kind != yaml.ScalarNode || node.Tag == "!!null" {
+		return ""
+	}
+	return node.Value
+}
+
+// set stores value with the YAML tag at path, creating the sections it needs.
+// Strings are quoted so passwords like 1234 stay strings.
+func (w *wizard) set(value, tag string, path ...string) {
+	node := w.doc.Content[0]
+	for i, key := range path {
+		next := mappingValue(node, key)
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, next)
+		}
+		if i == len(path)-1 {
+			next.Kind = yaml.ScalarNode
+			next.Tag = tag
+			next.Style = 0
+			if tag == "!!str" {
+				next.Style = yaml.DoubleQuotedStyle
+			}
+			next.Value = value
+			next.Content = nil
+		}
+		node = next
+	}
+}
+
+// mappingValue returns the value of key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// write saves the config, readable by the owner only as it holds credentials
+func (w *wizard) write(filename string) error {
+	var sb strings.Builder
+	enc := yaml.NewEncoder(&sb)
+	enc.SetIndent(2)
+	if err := enc.Encode(&w.doc); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(filename, []byte(sb.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Command suggest reads session logs, clusters the caller answers the
+// classifier labeled "unknown" by the phrases they share, and prints the
+// phrases as candidate keywords for the classifier or interrupts.yaml:
+//
+//	suggest ./transcripts
+//	suggest -min 3 -top 10 ./transcripts/20261016_*_session_*.jsonl
+//
+// Directories are searched for session logs (*_session_*.jsonl). Check each
+// candidate with cmd/eval before adding it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	var minSize, top, examples int
+	flag.IntVar(&minSize, "min", 2, "Smallest cluster to report")
+	flag.IntVar(&top, "top", 20, "Clusters to report")
+	flag.IntVar(&examples, "examples", 3, "Example answers shown per cluster")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] session-log-or-dir...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := sessionLogs(flag.Args())
+	if err != nil {
+		log.Fatalf("Failed to list session logs: %v", err)
+	}
+	var answers []Answer
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", file, err)
+		}
+		found, err := readUnknownAnswers(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Failed to read %s: %v", file, err)
+		}
+		answers = append(answers, found...)
+	}
+
+	clusters, rest := clusterAnswers(answers, minSize)
+	fmt.Printf("%d unknown answers in %d session logs, %d clustered\n", len(answers), len(files), len(answers)-len(rest))
+	for i, c := range clusters {
+		if i == top {
+			fmt.Printf("\n... %d more clusters (-top)\n", len(clusters)-top)
+			break
+		}
+		fmt.Printf("\n%4d  %q  nodes: %v\n", len(c.Answers), c.Phrase, c.Nodes())
+		for j, a := range c.Answers {
+			if j == examples {
+				break
+			}
+			fmt.Printf("        %s\n", a.Text)
+		}
+	}
+}
+
+// sessionLogs expands directories in args to the session logs they contain
+func sessionLogs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(arg, "*_session_*.jsonl"))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxPhraseWords is the longest phrase suggested
+const maxPhraseWords = 3
+
+// stopwords never make a phrase on their own
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "for": true, "from": true, "he": true, "her": true, "him": true, "i": true, "i'm": true,
+	"in": true, "is": true, "it": true, "it's": true, "me": true, "my": true, "of": true, "on": true,
+	"or": true, "so": true, "that": true, "the": true, "this": true, "to": true, "uh": true, "um": true,
+	"was": true, "we": true, "what": true, "with": true, "you": true, "your": true,
+}
+
+// Answer is one caller answer the classifier could not place
+type Answer struct {
+	Text string
+	Node string
+}
+
+// Cluster is a group of answers sharing a candidate keyword phrase
+type Cluster struct {
+	Phrase  string
+	Answers []Answer
+}
+
+// Nodes counts the cluster's answers per question node, most frequent first
+func (c *Cluster) Nodes() []string {
+	counts := make(map[string]int)
+	for _, a := range c.Answers {
+		counts[a.Node]++
+	}
+	nodes := make([]string, 0, len(counts))
+	for node := range counts {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if counts[nodes[i]] != counts[nodes[j]] {
+			return counts[nodes[i]] > counts[nodes[j]]
+		}
+		return nodes[i] < nodes[j]
+	})
+	return nodes
+}
+
+// readUnknownAnswers returns the "unknown" Q&A answers in a session log
+func readUnknownAnswers(r io.Reader) ([]Answer, error) {
+	var answers []Answer
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec struct {
+			Event          string `json:"event"`
+			NodeID         string `json:"node_id"`
+			Text           string `json:"text"`
+			Classification string `json:"classification"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &rec) != nil {
+			continue
+		}
+		if rec.Event == "qna" && rec.Classification == "unknown" && strings.TrimSpace(rec.Text) != "" {
+			answers = append(answers, Answer{Text: rec.Text, Node: rec.NodeID})
+		}
+	}
+	return answers, scanner.Err()
+}
+
+// words lowercases text and splits it into words, keeping apostrophes
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
+
+// phrases returns the distinct phrases of up to maxPhraseWords words in
+// text that are not made of stopwords only
+func phrases(text string) map[string]bool {
+	ws := words(text)
+	result := make(map[string]bool)
+	for n := 1; n <= maxPhraseWords; n++ {
+		for i := 0; i+n <= len(ws); i++ {
+			gram := ws[i : i+n]
+			content := false
+			for _, w := range gram {
+				if !stopwords[w] {
+					content = true
+					break
+				}
+			}
+			if content {
+				result[strings.Join(gram, " ")] = true
+			}
+		}
+	}
+	return result
+}
+
+// clusterAnswers groups answers greedily: the phrase shared by the most
+// remaining answers forms a cluster, its answers are removed, and so on
+// until no phrase is shared by minSize answers. A cluster is labeled with
+// the longest phrase that still covers most of it, since longer phrases
+// make keywords with fewer false matches.
+func clusterAnswers(answers []Answer, minSize int) (clusters []Cluster, rest []Answer) {
+	grams := make([]map[string]bool, len(answers))
+	for i, a := range answers {
+		grams[i] = phrases(a.Text)
+	}
+	remaining := make([]int, len(answers))
+	for i := range remaining {
+		remaining[i] = i
+	}
+
+	for {
+		counts := make(map[string]int)
+		for _, i := range remaining {
+			for g := range grams[i] {
+				counts[g]++
+			}
+		}
+		best := ""
+		for g, n := range counts {
+			if best == "" || n > counts[best] || (n == counts[best] && better(g, best)) {
+				best = g
+			}
+		}
+		if best == "" || counts[best] < minSize {
+			break
+		}
+
+		var members, left []int
+		for _, i := range remaining {
+			if grams[i][best] {
+				members = append(members, i)
+			} else {
+				left = append(left, i)
+			}
+		}
+		remaining = left
+
+		// Prefer a longer phrase containing best that covers 3/4 of the cluster
+		label := best
+		inCluster := make(map[string]int)
+		for _, i := range members {
+			for g := range grams[i] {
+				if strings.Contains(" "+g+" ", " "+best+" ") {
+					inCluster[g]++
+				}
+			}
+		}
+		for g, n := range inCluster {
+			if n*4 >= len(members)*3 && better(g, label) {
+				label = g
+			}
+		}
+
+		cluster := Cluster{Phrase: label}
+		for _, i := range members {
+			cluster.Answers = append(cluster.Answers, answers[i])
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	for _, i := range remaining {
+		rest = append(rest, answers[i])
+	}
+	return clusters, rest
+}
+
+// better orders equally frequent phrases: longer first, then alphabetical
+func better(a, b string) bool {
+	la, lb := len(strings.Fields(a)), len(strings.Fields(b))
+	if la != lb {
+		return la > lb
+	}
+	return a < b
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClusterAnswers(t *testing.T) {
+	log := `{"event":"qna","node_id":"pitch","text":"I have medicare advantage","classification":"unknown"}
+{"event":"qna","node_id":"pitch","text":"it's a medicare advantage plan","classification":"unknown"}
+{"event":"qna","node_id":"greeting","text":"Medicare Advantage.","classification":"unknown"}
+{"event":"qna","node_id":"pitch","text":"yes","classification":"positive"}
+{"event":"qna","node_id":"pitch","text":"who is this","classification":"unknown"}
+{"event":"qna","node_id":"greeting","text":"who is calling","classification":"unknown"}
+{"event":"qna","node_id":"greeting","text":"the weather is nice","classification":"unknown"}
+{"event":"timeout","node_id":"pitch"}
+`
+	answers, err := readUnknownAnswers(strings.NewReader(log))
+	if err != nil || len(answers) != 6 {
+		t.Fatalf("readUnknownAnswers = %d answers, %v", len(answers), err)
+	}
+
+	clusters, rest := clusterAnswers(answers, 2)
+	if len(clusters) != 2 || len(rest) != 1 {
+		t.Fatalf("got %d clusters, %d unclustered: %+v", len(clusters), len(rest), clusters)
+	}
+	if c := clusters[0]; c.Phrase != "medicare advantage" || len(c.Answers) != 3 {
+		t.Errorf("first cluster = %q with %d answers", c.Phrase, len(c.Answers))
+	}
+	if nodes := clusters[0].Nodes(); nodes[0] != "pitch" {
+		t.Errorf("nodes = %v, want pitch first", nodes)
+	}
+	// "who" and "who is" cover the same answers; the longer phrase wins
+	if c := clusters[1]; c.Phrase != "who is" || len(c.Answers) != 2 {
+		t.Errorf("second cluster = %q with %d answers", c.Phrase, len(c.Answers))
+	}
+}
@@ -0,0 +1,113 @@
+package audio
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
+)
+
+// Ambient bed defaults
+const (
+	DefaultAmbientLevelDB = -20.0 // bed gain between prompts, relative to the recording
+	DefaultAmbientDuckDB  = -32.0 // bed gain under prompts
+	DefaultAmbientFadeMs  = 150   // time to ramp between levels
+	DefaultAmbientHoldMs  = 400   // the bed stays muted this long after the caller stops
+)
+
+// AmbientSettings tunes an ambient bed; zero values use the defaults above
+type AmbientSettings struct {
+	LevelDB  float64 // gain between prompts
+	DuckDB   float64 // gain while a prompt plays
+	PromptDB float64 // gain of the prompts the bed plays under
+	FadeMs   int     // ramp from one gain to another
+	HoldMs   int     // muted after the caller stops speaking
+}
+
+// Ambient loops a background recording, e.g. call-center murmur, under the
+// whole call. The bed is mixed into every frame the bot sends at DuckDB, sent
+// on its own at LevelDB on frames the bot has nothing to send on, and muted
+// while the caller speaks. Gain changes ramp over FadeMs so they never click. Whatever writes
+// the bot's audio calls Mix, so prompts, gaps and fades all carry the bed
+// without two writers interleaving frames on the connection.
+type Ambient struct {
+	mixer  *Mixer
+	bed    *MixerStream
+	level  float64 // linear gains
+	duck   float64
+	prompt float64
+	hold   time.Duration
+	now    func() time.Time
+
+	mu          sync.Mutex
+	callerUntil time.Time // muted until
+}
+
+// NewAmbient creates an ambient bed looping the cached audio file
+func (p *Player) NewAmbient(file string, settings AmbientSettings) (*Ambient, error) {
+	bed, ok := p.GetAudio(file)
+	if !ok || len(bed) < audiosocket.DefaultSlinChunkSize {
+		return nil, fmt.Errorf("ambient audio %s not found", file)
+	}
+	return newAmbient(bed, settings), nil
+}
+
+func newAmbient(bed []byte, settings AmbientSettings) *Ambient {
+	if settings.LevelDB == 0 {
+		settings.LevelDB = DefaultAmbientLevelDB
+	}
+	if settings.DuckDB == 0 {
+		settings.DuckDB = DefaultAmbientDuckDB
+	}
+	if settings.FadeMs <= 0 {
+		settings.FadeMs = DefaultAmbientFadeMs
+	}
+	if settings.HoldMs <= 0 {
+		settings.HoldMs = DefaultAmbientHoldMs
+	}
+	mixer := NewMixer(settings.FadeMs)
+	return &Ambient{
+		mixer:  mixer,
+		bed:    mixer.Add(bed, 0, true), // fades in
+		level:  Gain(settings.LevelDB),
+		duck:   Gain(settings.DuckDB),
+		prompt: Gain(settings.PromptDB),
+		hold:   time.Duration(settings.HoldMs) * time.Millisecond,
+		now:    time.Now,
+	}
+}
+
+// Mix returns frame, a chunk of 8kHz SLIN the bot is sending, at PromptDB
+// with the ducked bed added
+func (a *Ambient) Mix(frame []byte) []byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mixer.SetGain(a.bed, a.bedGain(a.now(), a.duck))
+	return a.mixer.Mix(frame, a.prompt)
+}
+
+// CallerSpeaking mutes the bed until the caller has been quiet for HoldMs;
+// call it for each caller frame with speech
+func (a *Ambient) CallerSpeaking() {
+	a.mu.Lock()
+	a.callerUntil = a.now().Add(a.hold)
+	a.mu.Unlock()
+}
+
+// IdleFrame returns the next frame of the bed alone, for a frame the bot has
+// nothing to send on
+func (a *Ambient) IdleFrame() []byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mixer.SetGain(a.bed, a.bedGain(a.now(), a.level))
+	return a.mixer.Next()
+}
+
+// bedGain returns gain, or 0 while the caller speaks; mu must be held
+func (a *Ambient) bedGain(now time.Time, gain float64) float64 {
+	if now.Before(a.callerUntil) {
+		return 0
+	}
+	return gain
+}
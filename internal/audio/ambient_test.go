@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// frameDB returns the RMS level of a SLIN frame in dBFS
+func frameDB(frame []byte) float64 {
+	var sum float64
+	for i := 0; i+1 < len(frame); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(frame[i:])))
+		sum += v * v
+	}
+	return toDB(math.Sqrt(sum / float64(len(frame)/2)))
+}
+
+func TestAmbientDucking(t *testing.T) {
+	now := time.Unix(0, 0)
+	a := newAmbient(tone(300, -6, 1), AmbientSettings{})
+	a.now = func() time.Time { return now }
+	tick := func() { now = now.Add(20 * time.Millisecond) }
+
+	// The bed fades in to LevelDB below the recording while the bot is silent
+	first := a.IdleFrame()
+	for i := 0; i < 10; i++ {
+		tick()
+		a.IdleFrame()
+	}
+	tick()
+	if db := frameDB(a.IdleFrame()); math.Abs(db-(-6+DefaultAmbientLevelDB)) > 1 {
+		t.Errorf("bed at %.1f dBFS, want about %.0f", db, -6+DefaultAmbientLevelDB)
+	}
+	if frameDB(first) > -6+DefaultAmbientLevelDB-6 {
+		t.Error("bed should fade in rather than start at full level")
+	}
+
+	// A prompt carries the bed ducked
+	silence := make([]byte, 320)
+	var mixed []byte
+	for i := 0; i < 15; i++ {
+		tick()
+		mixed = a.Mix(silence)
+	}
+	if db := frameDB(mixed); math.Abs(db-(-6+DefaultAmbientDuckDB)) > 1 {
+		t.Errorf("ducked bed at %.1f dBFS, want about %.0f", db, -6+DefaultAmbientDuckDB)
+	}
+
+	// The caller speaking mutes it until they have been quiet for HoldMs
+	for i := 0; i < 15; i++ {
+		tick()
+		a.CallerSpeaking()
+		mixed = a.Mix(silence)
+	}
+	if db := frameDB(mixed); db > -80 {
+		t.Errorf("bed at %.1f dBFS while the caller speaks, want muted", db)
+	}
+	now = now.Add(time.Duration(DefaultAmbientHoldMs) * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		tick()
+		mixed = a.IdleFrame()
+	}
+	if db := frameDB(mixed); math.Abs(db-(-6+DefaultAmbientLevelDB)) > 1 {
+		t.Errorf("bed at %.1f dBFS after the caller stopped, want about %.0f", db, -6+DefaultAmbientLevelDB)
+	}
+}
+
+func TestAmbientMixKeepsPrompt(t *testing.T) {
+	a := newAmbient(make([]byte, 640), AmbientSettings{}) // a silent bed
+	prompt := tone(440, -12, 0.02)
+	mixed := a.Mix(prompt)
+	if len(mixed) != len(prompt) {
+		t.Fatalf("mixed %d bytes, want %d", len(mixed), len(prompt))
+	}
+	for i := range prompt {
+		if mixed[i] != prompt[i] {
+			t.Fatal("a silent bed should leave the prompt unchanged")
+		}
+	}
+
+	player := &Player{audioCache: map[string][]byte{"bed.wav": make([]byte, 8)}}
+	if _, err := player.NewAmbient("bed.wav", AmbientSettings{}); err == nil {
+		t.Error("a bed shorter than one frame should be rejected")
+	}
+	if _, err := player.NewAmbient("missing.wav", AmbientSettings{}); err == nil {
+		t.Error("a missing bed should be rejected")
+	}
+}
@@ -0,0 +1,177 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// Background is the caller's acoustic environment
+type Background string
+
+const (
+	BackgroundUnknown Background = ""      // not enough background audio yet
+	BackgroundQuiet   Background = "quiet" // little or no noise
+	BackgroundCar     Background = "car"   // steady low rumble of a moving vehicle
+	BackgroundCrowd   Background = "crowd" // fluctuating babble of many voices
+	BackgroundTV      Background = "tv"    // voices or music playing in the room
+	BackgroundNoisy   Background = "noisy" // noise that fits none of the above
+)
+
+// DefaultBackgroundSeconds is how much background audio the classifier
+// needs before deciding
+const DefaultBackgroundSeconds = 4
+
+const (
+	quietDB        = -55.0 // background below this is quiet
+	lowBandHz      = 300   // upper edge of the band road noise concentrates in
+	carLowShare    = 0.5   // share of background energy below lowBandHz for a car
+	steadyStdDB    = 1.5   // block level deviation below which noise counts as steady
+	tvPitchedShare = 0.3   // share of pitched background frames for tv
+	blockFrames    = 10    // frames per level block (200ms) for the steadiness measure
+)
+
+// BackgroundClassifier classifies the caller's environment from the audio
+// between their words: frames below the speech level. Once it has enough
+// background it decides and keeps that answer for the call. It expects
+// 16-bit little-endian mono PCM.
+type BackgroundClassifier struct {
+	sampleRate int
+	frameLen   int
+	needFrames int
+	lowAlpha   float64 // one-pole low-pass coefficient for lowBandHz
+
+	mu      sync.Mutex
+	pending []float64
+	prev    []float64
+	low     float64 // low-pass filter state
+
+	frames      int     // background frames seen
+	sumDB       float64 // for the mean level
+	sumLow      float64 // low-band energy of background frames
+	sumTotal    float64 // total energy of background frames
+	pitched     int     // background frames with a clear period
+	blockPower  float64 // power summed over the current block
+	blockCount  int
+	blockLevels []float64 // dB of each complete block
+	result      Background
+}
+
+// NewBackgroundClassifier creates a classifier for audio at sampleRate that
+// decides after seconds of background audio (DefaultBackgroundSeconds if 0)
+func NewBackgroundClassifier(sampleRate int, seconds float64) *BackgroundClassifier {
+	if sampleRate <= 0 {
+		sampleRate = 8000
+	}
+	if seconds <= 0 {
+		seconds = DefaultBackgroundSeconds
+	}
+	return &BackgroundClassifier{
+		sampleRate: sampleRate,
+		frameLen:   sampleRate / 50,
+		needFrames: int(seconds * 50),
+		lowAlpha:   1 - math.Exp(-2*math.Pi*lowBandHz/float64(sampleRate)),
+	}
+}
+
+// Add analyzes a block of audio. It returns the environment once, when the
+// classifier decides, and BackgroundUnknown otherwise.
+func (c *BackgroundClassifier) Add(pcm []byte) Background {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.result != BackgroundUnknown {
+		return BackgroundUnknown
+	}
+
+	for i := 0; i+1 < len(pcm); i += 2 {
+		c.pending = append(c.pending, float64(int16(binary.LittleEndian.Uint16(pcm[i:]))))
+		if len(c.pending) < c.frameLen {
+			continue
+		}
+		c.frame(c.pending)
+		c.prev, c.pending = c.pending, c.prev[:0]
+		if c.frames >= c.needFrames {
+			c.result = c.classify()
+			return c.result
+		}
+	}
+	return BackgroundUnknown
+}
+
+// Result returns the environment, BackgroundUnknown until decided
+func (c *BackgroundClassifier) Result() Background {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.result
+}
+
+// frame accumulates the features of one frame if it is background
+func (c *BackgroundClassifier) frame(samples []float64) {
+	var total, low float64
+	for _, v := range samples {
+		c.low += c.lowAlpha * (v - c.low)
+		total += v * v
+		low += c.low * c.low
+	}
+	power := total / float64(len(samples))
+	db := toDB(math.Sqrt(power))
+	if db >= speechGateDB {
+		return // the caller is speaking
+	}
+
+	c.frames++
+	c.sumDB += db
+	c.sumTotal += total
+	c.sumLow += low
+	if len(c.prev) == len(samples) && db > quietDB {
+		window := append(append([]float64(nil), c.prev...), samples...)
+		if _, clarity := periodicity(window, c.sampleRate); clarity >= pitchClarity {
+			c.pitched++
+		}
+	}
+	c.blockPower += power
+	c.blockCount++
+	if c.blockCount == blockFrames {
+		c.blockLevels = append(c.blockLevels, toDB(math.Sqrt(c.blockPower/blockFrames)))
+		c.blockPower, c.blockCount = 0, 0
+	}
+}
+
+// classify decides the environment from the accumulated features
+func (c *BackgroundClassifier) classify() Background {
+	if c.sumDB/float64(c.frames) < quietDB {
+		return BackgroundQuiet
+	}
+	lowShare := 0.0
+	if c.sumTotal > 0 {
+		lowShare = c.sumLow / c.sumTotal
+	}
+	pitchedShare := float64(c.pitched) / float64(c.frames)
+	std := stdDev(c.blockLevels)
+
+	switch {
+	case lowShare >= carLowShare && std < steadyStdDB:
+		return BackgroundCar
+	case pitchedShare >= tvPitchedShare:
+		return BackgroundTV
+	case std >= steadyStdDB:
+		return BackgroundCrowd
+	}
+	return BackgroundNoisy
+}
+
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sum / float64(len(values)))
+}
@@ -0,0 +1,85 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// synth returns seconds of 8kHz PCM whose sample i is f(i) scaled to
+// levelDB; level(i) may vary the level over time
+func synth(seconds float64, level func(i int) float64, f func(i int) float64) []byte {
+	n := int(8000 * seconds)
+	raw := make([]float64, n)
+	var power float64
+	for i := range raw {
+		raw[i] = f(i)
+		power += raw[i] * raw[i]
+	}
+	rms := math.Sqrt(power / float64(n))
+	pcm := make([]byte, n*2)
+	for i, v := range raw {
+		v = v / rms * 32767 * math.Pow(10, level(i)/20)
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(v)))
+	}
+	return pcm
+}
+
+func constant(db float64) func(int) float64 { return func(int) float64 { return db } }
+
+func TestBackgroundClassifier(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	white := func(int) float64 { return rng.NormFloat64() }
+	rumble := func() func(int) float64 {
+		var y float64
+		return func(int) float64 {
+			y += 0.03 * (rng.NormFloat64() - y) // low-pass around 40 Hz
+			return y
+		}
+	}
+	voice := func(i int) float64 {
+		var v float64
+		for h := 1; h <= 8; h++ {
+			v += math.Sin(2 * math.Pi * 180 * float64(h) * float64(i) / 8000)
+		}
+		return v
+	}
+	// Babble rises and falls every 200ms
+	babbleLevels := make([]float64, 100)
+	for i := range babbleLevels {
+		babbleLevels[i] = -52 + 10*rng.Float64()
+	}
+	babble := func(i int) float64 { return babbleLevels[i/1600%len(babbleLevels)] }
+	// Speech-like level for the tv: syllables every 250ms
+	syllables := func(i int) float64 { return -50 + 6*math.Abs(math.Sin(math.Pi*float64(i)/2000)) }
+
+	tests := []struct {
+		name string
+		pcm  []byte
+		want Background
+	}{
+		{"quiet", synth(5, constant(-65), white), BackgroundQuiet},
+		{"car", synth(5, constant(-45), rumble()), BackgroundCar},
+		{"crowd", synth(5, babble, white), BackgroundCrowd},
+		{"tv", synth(5, syllables, voice), BackgroundTV},
+		{"hiss", synth(5, constant(-45), white), BackgroundNoisy},
+	}
+	caller := synth(1, constant(-20), voice)
+	for _, tt := range tests {
+		c := NewBackgroundClassifier(8000, 0)
+		// The caller's own speech is not background
+		if got := c.Add(caller); got != BackgroundUnknown {
+			t.Fatalf("%s: decided %q on caller speech", tt.name, got)
+		}
+		var decided []Background
+		for pcm := tt.pcm; len(pcm) > 0; pcm = pcm[min(320, len(pcm)):] {
+			if got := c.Add(pcm[:min(320, len(pcm))]); got != BackgroundUnknown {
+				decided = append(decided, got)
+			}
+		}
+		if len(decided) != 1 || decided[0] != tt.want || c.Result() != tt.want {
+			t.Errorf("%s: decided %v, result %q, want %q", tt.name, decided, c.Result(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CheckPrompt verifies the audio file name the player would load from
+// audioDir: it exists, decodes, is recorded at the 8kHz AudioSocket plays
+// back (other rates are resampled, at some loss of quality) and carries
+// sound. Files in the background directory, such as room tone, may be
+// quiet.
+func CheckPrompt(audioDir, name string) error {
+	path := filepath.Join(audioDir, name)
+	background := false
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(audioDir, "background", name)
+		background = true
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return fmt.Errorf("not found in %s", audioDir)
+		}
+	}
+
+	pcm, _, sampleRate, err := readWAV(path)
+	if err != nil {
+		return fmt.Errorf("does not decode: %w", err)
+	}
+	if sampleRate != audioSampleRate {
+		return fmt.Errorf("recorded at %d Hz, expected %d Hz", sampleRate, audioSampleRate)
+	}
+	if len(pcm) == 0 {
+		return fmt.Errorf("has no audio")
+	}
+	if levels := MeasureLevels(pcm); !background && levels.NearSilent() {
+		return fmt.Errorf("is silent (RMS %.1f dBFS)", levels.RMSDB)
+	}
+	return nil
+}
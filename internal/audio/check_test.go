@@ -0,0 +1,56 @@
+package audio
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeWAV writes mono 16-bit pcm at rate as a WAV file
+func writeWAV(t *testing.T, path string, rate int, pcm []byte) {
+	t.Helper()
+	header := make([]byte, 44)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(36+len(pcm)))
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:], 1) // mono
+	binary.LittleEndian.PutUint32(header[24:], uint32(rate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(rate*2))
+	binary.LittleEndian.PutUint16(header[32:], 2)
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(len(pcm)))
+	if err := os.WriteFile(path, append(header, pcm...), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckPrompt(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "background"), 0755)
+	writeWAV(t, filepath.Join(dir, "hello.wav"), 8000, tone(440, -20, 0.5))
+	writeWAV(t, filepath.Join(dir, "wideband.wav"), 16000, tone(440, -20, 0.5))
+	writeWAV(t, filepath.Join(dir, "silent.wav"), 8000, make([]byte, 8000))
+	writeWAV(t, filepath.Join(dir, "background", "room.wav"), 8000, make([]byte, 8000))
+	os.WriteFile(filepath.Join(dir, "broken.wav"), []byte("RIFF\x00\x00\x00\x00WAVE"), 0644)
+
+	for name, want := range map[string]string{
+		"hello.wav":    "",
+		"room.wav":     "", // room tone may be quiet
+		"wideband.wav": "recorded at 16000 Hz",
+		"silent.wav":   "is silent",
+		"broken.wav":   "does not decode",
+		"missing.wav":  "not found",
+	} {
+		err := CheckPrompt(dir, name)
+		if want == "" && err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if want != "" && (err == nil || !strings.Contains(err.Error(), want)) {
+			t.Errorf("%s: error %v, want %q", name, err, want)
+		}
+	}
+}
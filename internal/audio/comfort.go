@@ -0,0 +1,136 @@
+package audio
+
+import (
+	"encoding/binary"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
+)
+
+// DefaultComfortNoiseLevel is the generated noise level in dBFS: audible
+// enough that the caller knows the line is open, quiet enough not to disturb
+// transcription
+const DefaultComfortNoiseLevel = -60.0
+
+// ComfortNoise fills gaps between prompts with low-level noise, or a looped
+// room-tone recording, so the caller never hears dead digital silence and
+// hangs up thinking the call dropped. Prompt playback must be bracketed with
+// Pause/Resume so the two never interleave.
+type ComfortNoise struct {
+	conn     net.Conn
+	roomTone []byte // looped when set, otherwise noise is generated
+	pos      int
+	amp      float64
+	rng      *rand.Rand
+	lp       float64 // low-pass state; softens white noise towards a hiss
+
+	mu     sync.Mutex
+	paused int
+}
+
+// NewComfortNoise creates a comfort noise source. roomTone names a cached
+// audio file to loop; if empty or missing, noise is generated at levelDB dBFS
+// (DefaultComfortNoiseLevel if zero).
+func (p *Player) NewComfortNoise(conn net.Conn, roomTone string, levelDB float64) *ComfortNoise {
+	if levelDB == 0 {
+		levelDB = DefaultComfortNoiseLevel
+	}
+	cn := &ComfortNoise{
+		conn: conn,
+		amp:  32767 * math.Pow(10, levelDB/20),
+		rng:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if roomTone != "" {
+		if data, ok := p.GetAudio(roomTone); ok && len(data) >= audiosocket.DefaultSlinChunkSize {
+			cn.roomTone = data
+		} else {
+			log.Printf("Comfort noise: room tone %s not found, generating noise", roomTone)
+		}
+	}
+	return cn
+}
+
+// SetRand replaces the generator of the noise, e.g. with one derived from
+// the session seed; call it before Start
+func (cn *ComfortNoise) SetRand(rng *rand.Rand) {
+	cn.rng = rng
+}
+
+// Start sends comfort noise in 20ms chunks until stopChan is closed
+func (cn *ComfortNoise) Start(stopChan <-chan struct{}) {
+	go func() {
+		for {
+			select {
+			case <-stopChan:
+				return
+			default:
+			}
+
+			if cn.isPaused() {
+				time.Sleep(20 * time.Millisecond)
+				continue
+			}
+			if _, err := cn.conn.Write(audiosocket.SlinMessage(cn.nextChunk())); err != nil {
+				log.Printf("Comfort noise stopped: %v", err)
+				return
+			}
+			waitFrame(cn.conn)
+		}
+	}()
+}
+
+// Pause stops comfort noise while a prompt plays; calls nest
+func (cn *ComfortNoise) Pause() {
+	cn.mu.Lock()
+	cn.paused++
+	cn.mu.Unlock()
+}
+
+// Resume undoes one Pause
+func (cn *ComfortNoise) Resume() {
+	cn.mu.Lock()
+	if cn.paused > 0 {
+		cn.paused--
+	}
+	cn.mu.Unlock()
+}
+
+func (cn *ComfortNoise) isPaused() bool {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	return cn.paused > 0
+}
+
+// nextChunk returns the next DefaultSlinChunkSize bytes of comfort noise
+func (cn *ComfortNoise) nextChunk() []byte {
+	chunk := make([]byte, audiosocket.DefaultSlinChunkSize)
+
+	if cn.roomTone != nil {
+		for i := range chunk {
+			chunk[i] = cn.roomTone[cn.pos]
+			cn.pos = (cn.pos + 1) % (len(cn.roomTone) &^ 1)
+		}
+		return chunk
+	}
+
+	for i := 0; i < len(chunk); i += 2 {
+		cn.lp += 0.5 * (cn.rng.NormFloat64() - cn.lp)
+		binary.LittleEndian.PutUint16(chunk[i:], uint16(clampSample(cn.lp*cn.amp*2)))
+	}
+	return chunk
+}
+
+func clampSample(v float64) int16 {
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(math.Round(v))
+}
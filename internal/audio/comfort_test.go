@@ -0,0 +1,78 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
+)
+
+func TestComfortNoiseLevel(t *testing.T) {
+	player := &Player{audioCache: make(map[string][]byte)}
+	cn := player.NewComfortNoise(nil, "", 0)
+
+	var sum float64
+	n := 0
+	for i := 0; i < 50; i++ {
+		chunk := cn.nextChunk()
+		if len(chunk) != audiosocket.DefaultSlinChunkSize {
+			t.Fatalf("chunk size %d, want %d", len(chunk), audiosocket.DefaultSlinChunkSize)
+		}
+		for j := 0; j < len(chunk); j += 2 {
+			v := float64(int16(binary.LittleEndian.Uint16(chunk[j:])))
+			sum += v * v
+			n++
+		}
+	}
+
+	rmsDB := 20 * math.Log10(math.Sqrt(sum/float64(n))/32767)
+	if math.Abs(rmsDB-DefaultComfortNoiseLevel) > 3 {
+		t.Errorf("noise level %.1f dBFS, want about %.0f", rmsDB, DefaultComfortNoiseLevel)
+	}
+}
+
+func TestComfortNoiseRoomToneAndPause(t *testing.T) {
+	tone := make([]byte, 480)
+	for i := range tone {
+		tone[i] = byte(i)
+	}
+	player := &Player{audioCache: map[string][]byte{"room.wav": tone}}
+
+	server, client := net.Pipe()
+	defer client.Close()
+	cn := player.NewComfortNoise(server, "room.wav", 0)
+
+	// The loop wraps around the end of the room tone
+	cn.nextChunk()
+	if chunk := cn.nextChunk(); chunk[0] != tone[320] || chunk[160] != tone[0] {
+		t.Error("room tone should loop")
+	}
+
+	cn.Pause()
+	stop := make(chan struct{})
+	defer close(stop)
+	cn.Start(stop)
+
+	received := make(chan struct{}, 1)
+	go func() {
+		if _, err := audiosocket.NextMessage(client); err == nil {
+			received <- struct{}{}
+		}
+	}()
+
+	select {
+	case <-received:
+		t.Fatal("no comfort noise should be sent while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cn.Resume()
+	select {
+	case <-received:
+	case <-time.After(time.Second):
+		t.Fatal("comfort noise should resume")
+	}
+}
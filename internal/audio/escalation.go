@@ -0,0 +1,231 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// EscalationLevel is how agitated the caller sounds
+type EscalationLevel string
+
+const (
+	EscalationNone     EscalationLevel = ""
+	EscalationAgitated EscalationLevel = "agitated" // louder and higher pitched than earlier in the call
+	EscalationShouting EscalationLevel = "shouting" // very loud speech
+)
+
+// EscalationSettings tunes the escalation detector; zero values use the
+// defaults below
+type EscalationSettings struct {
+	RiseDB    float64 // speech level increase over the caller's baseline; default 6 dB
+	PitchRise float64 // relative pitch increase over the baseline, e.g. 0.2 for 20%; default 0.2
+	ShoutDB   float64 // speech level taken as shouting; default -12 dBFS
+}
+
+// Escalation detector defaults
+const (
+	DefaultEscalationRiseDB    = 6.0
+	DefaultEscalationPitchRise = 0.2
+	DefaultEscalationShoutDB   = -12.0
+)
+
+const (
+	speechGateDB   = -40.0 // frames below this are not speech
+	baselineFrames = 100   // voiced 20ms frames that make up the baseline (2s of speech)
+	trendAlpha     = 0.04  // weight of each voiced frame in the recent averages (~0.5s)
+	minPitchHz     = 70
+	maxPitchHz     = 400
+	pitchClarity   = 0.5 // normalized autocorrelation needed to call a frame pitched
+)
+
+// EscalationDetector follows the loudness and pitch of caller speech and
+// flags shouting or a sustained rise in both over the caller's own baseline
+// from the start of the call. It expects 16-bit little-endian mono PCM.
+type EscalationDetector struct {
+	settings   EscalationSettings
+	sampleRate int
+	frameLen   int
+
+	mu      sync.Mutex
+	pending []float64 // samples not yet making up a full frame
+	prev    []float64 // previous frame, for the pitch window
+
+	voiced      int     // voiced frames seen
+	baseDB      float64 // baseline level; sum until the baseline is complete
+	basePitch   float64 // baseline pitch; sum until the baseline is complete
+	pitchedBase int     // baseline frames with a pitch
+	recentDB    float64
+	recentPitch float64
+	level       EscalationLevel
+}
+
+// NewEscalationDetector creates a detector for audio at sampleRate
+func NewEscalationDetector(settings EscalationSettings, sampleRate int) *EscalationDetector {
+	if settings.RiseDB == 0 {
+		settings.RiseDB = DefaultEscalationRiseDB
+	}
+	if settings.PitchRise == 0 {
+		settings.PitchRise = DefaultEscalationPitchRise
+	}
+	if settings.ShoutDB == 0 {
+		settings.ShoutDB = DefaultEscalationShoutDB
+	}
+	if sampleRate <= 0 {
+		sampleRate = 8000
+	}
+	return &EscalationDetector{settings: settings, sampleRate: sampleRate, frameLen: sampleRate / 50}
+}
+
+// Add analyzes a block of audio. It returns the new level when the caller
+// escalates (calm to agitated or shouting, agitated to shouting) and
+// EscalationNone otherwise.
+func (d *EscalationDetector) Add(pcm []byte) EscalationLevel {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	raised := EscalationNone
+	for i := 0; i+1 < len(pcm); i += 2 {
+		d.pending = append(d.pending, float64(int16(binary.LittleEndian.Uint16(pcm[i:]))))
+		if len(d.pending) < d.frameLen {
+			continue
+		}
+		if level := d.frame(d.pending); level != EscalationNone {
+			raised = level
+		}
+		d.prev, d.pending = d.pending, d.prev[:0]
+	}
+	return raised
+}
+
+// Level returns the current escalation level
+func (d *EscalationDetector) Level() EscalationLevel {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.level
+}
+
+// frame updates the trends with one frame and returns a raised level
+func (d *EscalationDetector) frame(samples []float64) EscalationLevel {
+	var sum float64
+	for _, v := range samples {
+		sum += v * v
+	}
+	db := toDB(math.Sqrt(sum / float64(len(samples))))
+	if db < speechGateDB {
+		return EscalationNone
+	}
+	pitch := 0.0
+	if len(d.prev) == len(samples) {
+		pitch = estimatePitch(append(append([]float64(nil), d.prev...), samples...), d.sampleRate)
+	}
+
+	if d.voiced == 0 {
+		d.recentDB = db
+	} else {
+		d.recentDB += trendAlpha * (db - d.recentDB)
+	}
+	if pitch > 0 {
+		if d.recentPitch == 0 {
+			d.recentPitch = pitch
+		}
+		d.recentPitch += trendAlpha * (pitch - d.recentPitch)
+	}
+
+	d.voiced++
+	if d.voiced <= baselineFrames {
+		d.baseDB += db
+		if pitch > 0 {
+			d.basePitch += pitch
+			d.pitchedBase++
+		}
+		if d.voiced == baselineFrames {
+			d.baseDB /= baselineFrames
+			if d.pitchedBase > 0 {
+				d.basePitch /= float64(d.pitchedBase)
+			}
+		}
+	}
+	return d.update()
+}
+
+// update moves between levels; a level holds until speech is back near the
+// baseline so one outburst is reported once. Shouting needs no baseline.
+func (d *EscalationDetector) update() EscalationLevel {
+	s := d.settings
+	baseline := d.voiced >= baselineFrames
+	next := EscalationNone
+	switch {
+	case d.recentDB >= s.ShoutDB:
+		next = EscalationShouting
+	case baseline && d.basePitch > 0 &&
+		d.recentDB-d.baseDB >= s.RiseDB && d.recentPitch >= d.basePitch*(1+s.PitchRise):
+		next = EscalationAgitated
+	}
+
+	if rank(next) > rank(d.level) {
+		d.level = next
+		return next
+	}
+	calm := d.recentDB < s.ShoutDB-s.RiseDB && (!baseline || d.recentDB-d.baseDB < s.RiseDB/2)
+	if d.level != EscalationNone && calm {
+		d.level = EscalationNone
+	}
+	return EscalationNone
+}
+
+func rank(level EscalationLevel) int {
+	switch level {
+	case EscalationAgitated:
+		return 1
+	case EscalationShouting:
+		return 2
+	}
+	return 0
+}
+
+// estimatePitch returns the fundamental frequency of a speech window by
+// autocorrelation, or 0 when the window is not clearly pitched
+func estimatePitch(window []float64, sampleRate int) float64 {
+	freq, clarity := periodicity(window, sampleRate)
+	if clarity < pitchClarity {
+		return 0
+	}
+	return freq
+}
+
+// periodicity finds the strongest period of a window in the voice pitch
+// range. clarity is its normalized autocorrelation: near 1 for a clean tone
+// or vowel, near 0 for noise.
+func periodicity(window []float64, sampleRate int) (freq, clarity float64) {
+	minLag, maxLag := sampleRate/maxPitchHz, sampleRate/minPitchHz
+	if maxLag >= len(window)/2 {
+		maxLag = len(window)/2 - 1
+	}
+	var energy float64
+	for _, v := range window {
+		energy += v * v
+	}
+	if energy == 0 || minLag >= maxLag {
+		return 0, 0
+	}
+
+	corr := make([]float64, maxLag+1)
+	best := 0.0
+	for lag := minLag; lag <= maxLag; lag++ {
+		var sum float64
+		for i := 0; i+lag < len(window); i++ {
+			sum += window[i] * window[i+lag]
+		}
+		// Normalize for the shrinking overlap
+		corr[lag] = sum / energy * float64(len(window)) / float64(len(window)-lag)
+		best = math.Max(best, corr[lag])
+	}
+	// The shortest lag close to the best avoids picking a multiple of the period
+	for lag := minLag; lag <= maxLag; lag++ {
+		if corr[lag] >= 0.9*best && (lag == maxLag || corr[lag] >= corr[lag+1]) {
+			return float64(sampleRate) / float64(lag), best
+		}
+	}
+	return 0, best
+}
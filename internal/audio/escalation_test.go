@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// tone returns seconds of a sine at freq Hz and level dBFS (RMS), 8kHz PCM
+func tone(freq, levelDB, seconds float64) []byte {
+	amplitude := 32767 * math.Pow(10, levelDB/20) * math.Sqrt2
+	n := int(8000 * seconds)
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		v := amplitude * math.Sin(2*math.Pi*freq*float64(i)/8000)
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(v)))
+	}
+	return pcm
+}
+
+// feed adds pcm in 20ms frames and returns the levels raised
+func feed(d *EscalationDetector, pcm []byte) []EscalationLevel {
+	var raised []EscalationLevel
+	for len(pcm) > 0 {
+		n := min(320, len(pcm))
+		if level := d.Add(pcm[:n]); level != EscalationNone {
+			raised = append(raised, level)
+		}
+		pcm = pcm[n:]
+	}
+	return raised
+}
+
+func TestEstimatePitch(t *testing.T) {
+	for _, freq := range []float64{110, 150, 220, 300} {
+		pcm := tone(freq, -20, 0.04)
+		window := make([]float64, len(pcm)/2)
+		for i := range window {
+			window[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+		}
+		if got := estimatePitch(window, 8000); math.Abs(got-freq)/freq > 0.05 {
+			t.Errorf("pitch of %.0f Hz tone = %.1f", freq, got)
+		}
+	}
+	if got := estimatePitch(make([]float64, 320), 8000); got != 0 {
+		t.Errorf("pitch of silence = %.1f", got)
+	}
+}
+
+func TestEscalationDetector(t *testing.T) {
+	d := NewEscalationDetector(EscalationSettings{}, 8000)
+	if raised := feed(d, tone(150, -30, 3)); len(raised) != 0 {
+		t.Fatalf("calm speech raised %v", raised)
+	}
+	// Louder at the same pitch: closer to the phone, not agitated
+	if raised := feed(d, tone(150, -20, 2)); len(raised) != 0 {
+		t.Errorf("louder speech at the same pitch raised %v", raised)
+	}
+	feed(d, tone(150, -30, 2))
+
+	if raised := feed(d, tone(220, -20, 2)); len(raised) != 1 || raised[0] != EscalationAgitated {
+		t.Errorf("louder, higher speech raised %v, want agitated", raised)
+	}
+	if raised := feed(d, tone(220, -6, 2)); len(raised) != 1 || raised[0] != EscalationShouting {
+		t.Errorf("shouting raised %v", raised)
+	}
+	if d.Level() != EscalationShouting {
+		t.Errorf("level = %q while shouting", d.Level())
+	}
+
+	// Silence does not calm the caller down; calm speech does
+	feed(d, make([]byte, 16000))
+	if d.Level() != EscalationShouting {
+		t.Errorf("level = %q after silence", d.Level())
+	}
+	feed(d, tone(150, -30, 3))
+	if d.Level() != EscalationNone {
+		t.Errorf("level = %q after calm speech", d.Level())
+	}
+	if raised := feed(d, tone(150, -6, 2)); len(raised) != 1 || raised[0] != EscalationShouting {
+		t.Errorf("second outburst raised %v", raised)
+	}
+}
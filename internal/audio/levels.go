@@ -0,0 +1,114 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// Level thresholds used for warnings
+const (
+	ClipThreshold     = 32000 // samples at or above this magnitude count as clipped
+	NearSilentLevelDB = -50.0 // RMS below this is effectively silence on a phone line
+)
+
+// silenceDB is reported for a stream with no energy at all
+const silenceDB = -96.0
+
+// Levels summarizes the signal level of an audio stream in dBFS
+type Levels struct {
+	RMSDB          float64 `json:"rms_db"`
+	RecentRMSDB    float64 `json:"recent_rms_db"` // RMS over roughly the last second
+	PeakDB         float64 `json:"peak_db"`
+	ClippedSamples int64   `json:"clipped_samples"`
+	Samples        int64   `json:"samples"`
+}
+
+// Clipping reports whether more than 0.1% of samples were clipped
+func (l Levels) Clipping() bool {
+	return l.Samples > 0 && l.ClippedSamples*1000 > l.Samples
+}
+
+// NearSilent reports whether the stream carries no meaningful audio
+func (l Levels) NearSilent() bool {
+	return l.RMSDB < NearSilentLevelDB
+}
+
+// LevelMeter accumulates RMS and peak levels of 16-bit PCM
+type LevelMeter struct {
+	mu         sync.Mutex
+	sumSquares float64
+	recent     float64 // exponentially averaged mean square
+	peak       int
+	clipped    int64
+	samples    int64
+}
+
+// recentAlpha weights each 20ms frame so the average spans about a second
+const recentAlpha = 0.02
+
+// Add meters a block of little-endian 16-bit samples
+func (m *LevelMeter) Add(pcm []byte) {
+	n := len(pcm) / 2
+	if n == 0 {
+		return
+	}
+
+	var sum float64
+	peak := 0
+	var clipped int64
+	for i := 0; i < n; i++ {
+		v := int(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+		if v < 0 {
+			v = -v
+		}
+		if v > peak {
+			peak = v
+		}
+		if v >= ClipThreshold {
+			clipped++
+		}
+		sum += float64(v) * float64(v)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.samples == 0 {
+		m.recent = sum / float64(n)
+	} else {
+		m.recent += recentAlpha * (sum/float64(n) - m.recent)
+	}
+	m.sumSquares += sum
+	m.samples += int64(n)
+	m.clipped += clipped
+	if peak > m.peak {
+		m.peak = peak
+	}
+}
+
+// Levels returns the levels measured so far
+func (m *LevelMeter) Levels() Levels {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l := Levels{RMSDB: silenceDB, RecentRMSDB: silenceDB, PeakDB: silenceDB, ClippedSamples: m.clipped, Samples: m.samples}
+	if m.samples > 0 {
+		l.RMSDB = toDB(math.Sqrt(m.sumSquares / float64(m.samples)))
+		l.RecentRMSDB = toDB(math.Sqrt(m.recent))
+		l.PeakDB = toDB(float64(m.peak))
+	}
+	return l
+}
+
+// MeasureLevels returns the levels of a complete PCM buffer
+func MeasureLevels(pcm []byte) Levels {
+	var m LevelMeter
+	m.Add(pcm)
+	return m.Levels()
+}
+
+func toDB(amplitude float64) float64 {
+	if amplitude < 1 {
+		return silenceDB
+	}
+	return math.Round(20*math.Log10(amplitude/32767)*10) / 10
+}
@@ -0,0 +1,43 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestLevelMeter(t *testing.T) {
+	// Full-scale square wave: RMS and peak both at 0 dBFS, every sample clipped
+	loud := make([]byte, 320)
+	for i := 0; i < len(loud); i += 2 {
+		v := int16(32767)
+		if i%4 == 0 {
+			v = -32767
+		}
+		binary.LittleEndian.PutUint16(loud[i:], uint16(v))
+	}
+	levels := MeasureLevels(loud)
+	if levels.RMSDB != 0 || levels.PeakDB != 0 || !levels.Clipping() {
+		t.Errorf("full scale levels = %+v", levels)
+	}
+
+	// A -40 dBFS sine is quiet but not silent
+	quiet := make([]byte, 1600)
+	for i := 0; i < len(quiet)/2; i++ {
+		v := 327.67 * math.Sqrt2 * math.Sin(2*math.Pi*float64(i)/20)
+		binary.LittleEndian.PutUint16(quiet[i*2:], uint16(int16(math.Round(v))))
+	}
+	levels = MeasureLevels(quiet)
+	if math.Abs(levels.RMSDB+40) > 0.5 || levels.NearSilent() || levels.Clipping() {
+		t.Errorf("-40 dBFS sine levels = %+v", levels)
+	}
+
+	var m LevelMeter
+	if l := m.Levels(); !l.NearSilent() || l.Samples != 0 {
+		t.Errorf("empty meter levels = %+v", l)
+	}
+	m.Add(make([]byte, 320))
+	if l := m.Levels(); l.RMSDB != silenceDB || !l.NearSilent() {
+		t.Errorf("digital silence levels = %+v", l)
+	}
+}
@@ -0,0 +1,179 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// Machine is what answered the call, judged from its audio
+type Machine string
+
+const (
+	MachineUnknown Machine = ""      // not enough audio yet
+	MachineHuman   Machine = "human" // speech with pauses, or silence while the bot talks
+	MachineMusic   Machine = "music" // continuous, pitched, evenly loud audio such as hold music
+	MachineTones   Machine = "tones" // synthetic tones: IVR beeps, special information tones
+)
+
+// DefaultMachineSeconds is how much caller audio the detector hears before
+// deciding
+const DefaultMachineSeconds = 5
+
+const (
+	musicActiveShare  = 0.9  // share of frames with sound for continuous audio
+	musicPitchedShare = 0.3  // share of sounding frames with a clear period
+	musicSteadyStdDB  = 4.0  // block level deviation below which continuous sound is not speech
+	musicHeldShare    = 0.5  // share of pitched frames holding the previous frame's pitch (notes, not intonation)
+	toneClarity       = 0.9  // periodicity of a synthetic tone
+	toneDriftDB       = 1.0  // level change between frames of one tone
+	toneDrift         = 0.01 // relative pitch change between frames of one note or tone
+	toneRunFrames     = 10   // frames (200ms) of one unchanging tone before it counts
+	toneWindowShare   = 0.2  // share of the window spent in tones
+)
+
+// MachineDetector tells a person from hold music or an IVR by the first
+// seconds of caller audio. People answer with a short greeting and then
+// listen, and their pitch keeps moving while they talk; hold music plays
+// without pauses at an even level in held notes, and IVRs emit steady tones
+// no voice holds. It expects 16-bit little-endian mono PCM.
+type MachineDetector struct {
+	sampleRate int
+	frameLen   int
+	needFrames int
+
+	mu      sync.Mutex
+	pending []float64
+	prev    []float64
+
+	frames      int     // frames heard
+	active      int     // frames with sound
+	pitched     int     // sounding frames with a clear period
+	held        int     // pitched frames at the previous frame's pitch
+	toneRun     int     // consecutive frames of the current tone
+	toneFrames  int     // frames in tones of at least toneRunFrames
+	prevPitch   float64 // pitch of the previous frame, 0 if unpitched
+	prevFreq    float64 // tone frequency of the previous frame, 0 if not a tone
+	prevDB      float64
+	blockPower  float64
+	blockCount  int
+	blockLevels []float64 // dB of each complete block of sound
+	result      Machine
+}
+
+// NewMachineDetector creates a detector for audio at sampleRate that decides
+// after seconds of audio (DefaultMachineSeconds if 0)
+func NewMachineDetector(sampleRate int, seconds float64) *MachineDetector {
+	if sampleRate <= 0 {
+		sampleRate = 8000
+	}
+	if seconds <= 0 {
+		seconds = DefaultMachineSeconds
+	}
+	return &MachineDetector{sampleRate: sampleRate, frameLen: sampleRate / 50, needFrames: int(seconds * 50)}
+}
+
+// Add analyzes a block of audio. It returns the verdict once, when the
+// detector decides, and MachineUnknown otherwise.
+func (d *MachineDetector) Add(pcm []byte) Machine {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.result != MachineUnknown {
+		return MachineUnknown
+	}
+
+	for i := 0; i+1 < len(pcm); i += 2 {
+		d.pending = append(d.pending, float64(int16(binary.LittleEndian.Uint16(pcm[i:]))))
+		if len(d.pending) < d.frameLen {
+			continue
+		}
+		d.frame(d.pending)
+		d.prev, d.pending = d.pending, d.prev[:0]
+		if d.frames >= d.needFrames {
+			d.result = d.classify()
+			return d.result
+		}
+	}
+	return MachineUnknown
+}
+
+// Result returns the verdict, MachineUnknown until decided
+func (d *MachineDetector) Result() Machine {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.result
+}
+
+// frame accumulates the features of one frame
+func (d *MachineDetector) frame(samples []float64) {
+	d.frames++
+	var sum float64
+	for _, v := range samples {
+		sum += v * v
+	}
+	power := sum / float64(len(samples))
+	db := toDB(math.Sqrt(power))
+	if db < speechGateDB {
+		d.toneRun, d.prevPitch, d.prevFreq = 0, 0, 0
+		return
+	}
+
+	d.active++
+	freq, clarity := 0.0, 0.0
+	if len(d.prev) == len(samples) {
+		freq, clarity = periodicity(append(append([]float64(nil), d.prev...), samples...), d.sampleRate)
+	}
+	if clarity >= pitchClarity {
+		d.pitched++
+		if d.prevPitch > 0 && math.Abs(freq-d.prevPitch) <= toneDrift*d.prevPitch {
+			d.held++
+		}
+	}
+	steady := clarity >= toneClarity && d.prevFreq > 0 &&
+		math.Abs(freq-d.prevFreq) <= toneDrift*d.prevFreq && math.Abs(db-d.prevDB) <= toneDriftDB
+	switch {
+	case !steady:
+		d.toneRun = 0
+	case d.toneRun+1 == toneRunFrames:
+		d.toneFrames += toneRunFrames
+	case d.toneRun+1 > toneRunFrames:
+		d.toneFrames++
+	}
+	if steady {
+		d.toneRun++
+	}
+	d.prevPitch, d.prevFreq, d.prevDB = 0, 0, db
+	if clarity >= pitchClarity {
+		d.prevPitch = freq
+	}
+	if clarity >= toneClarity {
+		d.prevFreq = freq
+	}
+
+	d.blockPower += power
+	d.blockCount++
+	if d.blockCount == blockFrames {
+		d.blockLevels = append(d.blockLevels, toDB(math.Sqrt(d.blockPower/blockFrames)))
+		d.blockPower, d.blockCount = 0, 0
+	}
+}
+
+// classify decides from the accumulated features
+func (d *MachineDetector) classify() Machine {
+	activeShare := float64(d.active) / float64(d.frames)
+	pitchedShare, heldShare := 0.0, 0.0
+	if d.active > 0 {
+		pitchedShare = float64(d.pitched) / float64(d.active)
+	}
+	if d.pitched > 0 {
+		heldShare = float64(d.held) / float64(d.pitched)
+	}
+	switch {
+	case activeShare >= musicActiveShare && pitchedShare >= musicPitchedShare && heldShare >= musicHeldShare &&
+		stdDev(d.blockLevels) < musicSteadyStdDB:
+		return MachineMusic
+	case float64(d.toneFrames) >= toneWindowShare*float64(d.frames):
+		return MachineTones
+	}
+	return MachineHuman
+}
@@ -0,0 +1,62 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMachineDetector(t *testing.T) {
+	notes := []float64{262, 330, 392, 330, 294, 349, 440, 392}
+	// melody plays a note with two harmonics every 300ms
+	melody := func(i int) float64 {
+		f := notes[i/2400%len(notes)]
+		x := 2 * math.Pi * f * float64(i) / 8000
+		return math.Sin(x) + 0.5*math.Sin(2*x) + 0.25*math.Sin(3*x)
+	}
+	// syllables are 200ms of gliding voice and 100ms of near silence
+	syllables := func(i int) float64 {
+		return -20 - 30*float64(i%2400/1600)
+	}
+	glide := func(i int) float64 {
+		t := float64(i%2400) / 8000
+		return math.Sin(2 * math.Pi * (120*t + 150*t*t))
+	}
+	// sit plays the three special information tones, then 1s of silence
+	sitTones := []float64{913.8, 1370.6, 1776.7}
+	sit := func(i int) float64 {
+		n := i % 16000 / 2640
+		if n >= len(sitTones) {
+			return 0
+		}
+		return math.Sin(2 * math.Pi * sitTones[n] * float64(i) / 8000)
+	}
+
+	for _, tt := range []struct {
+		name string
+		pcm  []byte
+		want Machine
+	}{
+		{"silence", make([]byte, 5*16000), MachineHuman},
+		{"greeting", append(synth(0.6, constant(-20), glide), make([]byte, 5*16000)...), MachineHuman},
+		{"talking", synth(5, syllables, glide), MachineHuman},
+		{"talking without pauses", synth(5, func(i int) float64 { return syllables(i)/2 - 10 }, glide), MachineHuman},
+		{"hold music", synth(5, func(i int) float64 { return -20 - 3*float64(i%2400)/2400 }, melody), MachineMusic},
+		{"sit", synth(5, constant(-20), sit), MachineTones},
+		{"beeps", synth(5, func(i int) float64 { return -20 - 40*float64(i%16000/4000) }, func(i int) float64 {
+			return math.Sin(2 * math.Pi * 1000 * float64(i) / 8000)
+		}), MachineTones},
+	} {
+		d := NewMachineDetector(8000, 0)
+		var got []Machine
+		for pcm := tt.pcm; len(pcm) > 0; {
+			n := min(320, len(pcm))
+			if m := d.Add(pcm[:n]); m != MachineUnknown {
+				got = append(got, m)
+			}
+			pcm = pcm[n:]
+		}
+		if len(got) != 1 || got[0] != tt.want || d.Result() != tt.want {
+			t.Errorf("%s: decided %v (result %q), want %s", tt.name, got, d.Result(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/CyCoreSystems/audiosocket"
+)
+
+// Gain converts a level in dB to a linear gain
+func Gain(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+// MixerStream is one recording summed by a Mixer
+type MixerStream struct {
+	pcm    []byte
+	loop   bool
+	pos    int
+	gain   float64 // linear gain of the next sample
+	target float64 // gain being ramped to
+	step   float64 // gain change per sample while ramping
+}
+
+// Mixer sums streams of 8kHz SLIN, each at its own gain, into the 320-byte
+// chunks the bot sends, e.g. a looping background bed under prompts. A
+// looping stream plays until it is removed; any other is dropped once played
+// out. Gain changes ramp over the mixer's fade time so they never click.
+// Samples are summed in floating point and clipped once, so loud streams
+// saturate instead of wrapping around.
+type Mixer struct {
+	fade int // samples a gain change ramps over
+
+	mu      sync.Mutex
+	streams []*MixerStream
+}
+
+// NewMixer creates a mixer whose gain changes take fadeMs
+func NewMixer(fadeMs int) *Mixer {
+	return &Mixer{fade: max(fadeMs*8, 1)} // 8 samples per ms at 8kHz
+}
+
+// Add starts playing pcm at a linear gain and returns its stream
+func (m *Mixer) Add(pcm []byte, gain float64, loop bool) *MixerStream {
+	s := &MixerStream{pcm: pcm[:len(pcm)&^1], loop: loop, gain: gain, target: gain}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(s.pcm) > 0 {
+		m.streams = append(m.streams, s)
+	}
+	return s
+}
+
+// SetGain ramps the stream to a linear gain over the fade time
+func (m *Mixer) SetGain(s *MixerStream, gain float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if gain == s.target {
+		return
+	}
+	s.target = gain
+	s.step = math.Abs(gain-s.gain) / float64(m.fade)
+}
+
+// Remove stops a stream
+func (m *Mixer) Remove(s *MixerStream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, other := range m.streams {
+		if other == s {
+			m.streams = append(m.streams[:i], m.streams[i+1:]...)
+			return
+		}
+	}
+}
+
+// Playing reports whether a stream that is not looped has audio left
+func (m *Mixer) Playing() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.streams {
+		if !s.loop {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the next chunk of all streams mixed, silence if there are none
+func (m *Mixer) Next() []byte {
+	return m.Mix(make([]byte, audiosocket.DefaultSlinChunkSize), 0)
+}
+
+// Mix returns frame, a chunk of SLIN, at a linear gain with the next
+// samples of every stream added
+func (m *Mixer) Mix(frame []byte, gain float64) []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]byte, len(frame)&^1)
+	for i := 0; i < len(out); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(frame[i:]))) * gain
+		for _, s := range m.streams {
+			v += s.next()
+		}
+		binary.LittleEndian.PutUint16(out[i:], uint16(clampSample(v)))
+	}
+	m.dropFinished()
+	return out
+}
+
+// next returns the stream's next sample at its gain, moving the gain one
+// step towards its target; 0 once a stream that is not looped ends
+func (s *MixerStream) next() float64 {
+	if s.pos >= len(s.pcm) {
+		return 0
+	}
+	switch {
+	case s.gain < s.target:
+		s.gain = math.Min(s.gain+s.step, s.target)
+	case s.gain > s.target:
+		s.gain = math.Max(s.gain-s.step, s.target)
+	}
+	v := float64(int16(binary.LittleEndian.Uint16(s.pcm[s.pos:]))) * s.gain
+	s.pos += 2
+	if s.loop && s.pos >= len(s.pcm) {
+		s.pos = 0
+	}
+	return v
+}
+
+// dropFinished removes the streams that have played out; mu must be held
+func (m *Mixer) dropFinished() {
+	kept := m.streams[:0]
+	for _, s := range m.streams {
+		if s.loop || s.pos < len(s.pcm) {
+			kept = append(kept, s)
+		}
+	}
+	clear(m.streams[len(kept):])
+	m.streams = kept
+}
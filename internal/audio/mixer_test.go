@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// steady returns n samples of SLIN at value v
+func steady(v int16, n int) []byte {
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(v))
+	}
+	return pcm
+}
+
+// sampleAt returns sample i of a SLIN chunk
+func sampleAt(pcm []byte, i int) int16 {
+	return int16(binary.LittleEndian.Uint16(pcm[i*2:]))
+}
+
+func TestMixer(t *testing.T) {
+	m := NewMixer(10)
+	bed := m.Add(steady(1000, 100), 0.5, true)
+	m.Add(steady(2000, 200), 0.25, false)
+
+	// Streams are summed at their gains; the looping one wraps around
+	chunk := m.Next()
+	if len(chunk) != 320 {
+		t.Fatalf("chunk of %d bytes, want 320", len(chunk))
+	}
+	if v := sampleAt(chunk, 150); v != 1000 {
+		t.Errorf("mixed sample = %d, want 500 + 500", v)
+	}
+	if !m.Playing() {
+		t.Error("the one-shot stream has 40 samples left")
+	}
+	chunk = m.Next()
+	if v := sampleAt(chunk, 100); v != 500 || m.Playing() {
+		t.Errorf("sample after the one-shot ended = %d, playing = %v", v, m.Playing())
+	}
+
+	// A frame is mixed at its own gain, and the sum clips instead of wrapping
+	chunk = m.Mix(steady(math.MaxInt16, 160), 1)
+	if v := sampleAt(chunk, 0); v != math.MaxInt16 {
+		t.Errorf("clipped sample = %d", v)
+	}
+	chunk = m.Mix(steady(4000, 160), 0.5)
+	if v := sampleAt(chunk, 0); v != 2500 {
+		t.Errorf("sample = %d, want 2000 + 500", v)
+	}
+
+	// Gain changes ramp over the fade time: 80 samples at 8kHz
+	m.SetGain(bed, 0)
+	chunk = m.Next()
+	if v := sampleAt(chunk, 40); v < 200 || v > 300 {
+		t.Errorf("sample halfway through the fade = %d, want about 250", v)
+	}
+	if v := sampleAt(chunk, 100); v != 0 {
+		t.Errorf("sample after the fade = %d, want 0", v)
+	}
+
+	m.Remove(bed)
+	if chunk = m.Next(); sampleAt(chunk, 0) != 0 {
+		t.Error("a removed stream is still mixed")
+	}
+}
@@ -0,0 +1,108 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// opusGranuleRate is the rate Ogg Opus counts granule positions in,
+// whatever the encoder's input rate (RFC 7845 section 4)
+const opusGranuleRate = 48000
+
+// oggCRC is the lookup table of the Ogg page checksum: CRC-32 with the
+// polynomial 0x04c11db7, unreflected, starting from zero
+var oggCRC = func() (table [256]uint32) {
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return table
+}()
+
+// OggOpusWriter writes Opus packets as an Ogg Opus stream (RFC 7845). Each
+// packet gets a page of its own, so a live listener hears every frame as
+// soon as it is written.
+type OggOpusWriter struct {
+	w        io.Writer
+	rate     int // input sample rate of the encoder
+	serial   uint32
+	sequence uint32
+	granule  uint64
+}
+
+// NewOggOpusWriter writes the identification and comment headers of a mono
+// stream encoded at sampleRate to w. preSkip is the encoder's lookahead in
+// 48kHz samples, which players drop from the start of the stream.
+func NewOggOpusWriter(w io.Writer, sampleRate, preSkip int, serial uint32) (*OggOpusWriter, error) {
+	o := &OggOpusWriter{w: w, rate: sampleRate, serial: serial}
+
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1 // version
+	head[9] = 1 // channels
+	binary.LittleEndian.PutUint16(head[10:], uint16(preSkip))
+	binary.LittleEndian.PutUint32(head[12:], uint32(sampleRate))
+	// Output gain and channel mapping family 0 stay zero
+	if err := o.writePage(head, 0x02); err != nil {
+		return nil, err
+	}
+
+	vendor := "audiosocket-transcriber"
+	tags := make([]byte, 8+4+len(vendor)+4)
+	copy(tags, "OpusTags")
+	binary.LittleEndian.PutUint32(tags[8:], uint32(len(vendor)))
+	copy(tags[12:], vendor)
+	if err := o.writePage(tags, 0); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// WritePacket writes one Opus packet holding samples at the encoder's rate
+func (o *OggOpusWriter) WritePacket(packet []byte, samples int) error {
+	o.granule += uint64(samples * opusGranuleRate / o.rate)
+	return o.writePage(packet, 0)
+}
+
+// writePage writes packet as a page of its own with the header type flags
+func (o *OggOpusWriter) writePage(packet []byte, flags byte) error {
+	if len(packet) >= 255*255 {
+		return fmt.Errorf("ogg: packet of %d bytes does not fit a page", len(packet))
+	}
+	segments := len(packet)/255 + 1
+	page := make([]byte, 27+segments, 27+segments+len(packet))
+	copy(page, "OggS")
+	page[5] = flags
+	binary.LittleEndian.PutUint64(page[6:], o.granule)
+	binary.LittleEndian.PutUint32(page[14:], o.serial)
+	binary.LittleEndian.PutUint32(page[18:], o.sequence)
+	page[26] = byte(segments)
+	for i := 0; i < segments-1; i++ {
+		page[27+i] = 255
+	}
+	page[27+segments-1] = byte(len(packet) % 255)
+	page = append(page, packet...)
+
+	binary.LittleEndian.PutUint32(page[22:], oggChecksum(page))
+
+	o.sequence++
+	_, err := o.w.Write(page)
+	return err
+}
+
+// oggChecksum returns the checksum of a page with its checksum field zeroed
+func oggChecksum(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRC[byte(crc>>24)^b]
+	}
+	return crc
+}
@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestOggOpusWriter(t *testing.T) {
+	// CRC-32/CKSUM without its final inversion
+	if got := oggChecksum([]byte("123456789")); got != 0x765e7680^0xffffffff {
+		t.Fatalf("checksum = %#x", got)
+	}
+
+	var b bytes.Buffer
+	o, err := NewOggOpusWriter(&b, 8000, 312, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.WritePacket(bytes.Repeat([]byte{1}, 10), 160)
+	o.WritePacket(bytes.Repeat([]byte{2}, 300), 160)
+
+	type page struct {
+		flags   byte
+		granule uint64
+		packet  []byte
+	}
+	var pages []page
+	for data := b.Bytes(); len(data) > 0; {
+		if len(data) < 27 || string(data[:4]) != "OggS" {
+			t.Fatalf("page %d: bad capture pattern", len(pages))
+		}
+		segments := int(data[26])
+		size := 0
+		for _, lace := range data[27 : 27+segments] {
+			size += int(lace)
+		}
+		end := 27 + segments + size
+		raw := append([]byte(nil), data[:end]...)
+		want := binary.LittleEndian.Uint32(raw[22:])
+		binary.LittleEndian.PutUint32(raw[22:], 0)
+		if got := oggChecksum(raw); got != want {
+			t.Errorf("page %d: checksum %#x, want %#x", len(pages), want, got)
+		}
+		if serial, seq := binary.LittleEndian.Uint32(data[14:]), binary.LittleEndian.Uint32(data[18:]); serial != 7 || int(seq) != len(pages) {
+			t.Errorf("page %d: serial %d, sequence %d", len(pages), serial, seq)
+		}
+		pages = append(pages, page{data[5], binary.LittleEndian.Uint64(data[6:]), data[27+segments : end]})
+		data = data[end:]
+	}
+
+	if len(pages) != 4 {
+		t.Fatalf("%d pages, want headers and two packets", len(pages))
+	}
+	head := pages[0].packet
+	if pages[0].flags != 0x02 || string(head[:8]) != "OpusHead" || head[9] != 1 ||
+		binary.LittleEndian.Uint16(head[10:]) != 312 || binary.LittleEndian.Uint32(head[12:]) != 8000 {
+		t.Errorf("identification header %v (flags %#x)", head, pages[0].flags)
+	}
+	if string(pages[1].packet[:8]) != "OpusTags" {
+		t.Errorf("comment header %q", pages[1].packet)
+	}
+	// Granule positions count 48kHz samples
+	if pages[2].granule != 960 || pages[3].granule != 1920 {
+		t.Errorf("granules %d and %d, want 960 and 1920", pages[2].granule, pages[3].granule)
+	}
+	if len(pages[3].packet) != 300 || pages[3].packet[299] != 2 {
+		t.Errorf("a packet over 255 bytes came back as %d bytes", len(pages[3].packet))
+	}
+}
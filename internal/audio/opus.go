@@ -0,0 +1,77 @@
+//go:build opus && cgo
+
+package audio
+
+/*
+#cgo pkg-config: opus
+#include <opus.h>
+
+static int opus_lookahead(OpusEncoder *enc) {
+	opus_int32 lookahead = 0;
+	opus_encoder_ctl(enc, OPUS_GET_LOOKAHEAD(&lookahead));
+	return lookahead;
+}
+*/
+import "C"
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// OpusAvailable reports whether Opus encoding was compiled in
+const OpusAvailable = true
+
+// maxOpusPacket bounds an encoded packet, as libopus recommends
+const maxOpusPacket = 4000
+
+// OpusEncoder encodes mono 16-bit PCM with libopus, tuned for speech
+type OpusEncoder struct {
+	enc     *C.OpusEncoder
+	rate    int
+	samples []C.opus_int16
+	packet  []byte
+}
+
+// NewOpusEncoder creates an encoder for audio at sampleRate: 8, 12, 16, 24
+// or 48kHz
+func NewOpusEncoder(sampleRate int) (*OpusEncoder, error) {
+	var status C.int
+	enc := C.opus_encoder_create(C.opus_int32(sampleRate), 1, C.OPUS_APPLICATION_VOIP, &status)
+	if status != C.OPUS_OK {
+		return nil, fmt.Errorf("opus encoder: %s", C.GoString(C.opus_strerror(status)))
+	}
+	return &OpusEncoder{enc: enc, rate: sampleRate, packet: make([]byte, maxOpusPacket)}, nil
+}
+
+// PreSkip returns the encoder's lookahead in 48kHz samples, the pre-skip of
+// an Ogg Opus stream
+func (e *OpusEncoder) PreSkip() int {
+	return int(C.opus_lookahead(e.enc)) * opusGranuleRate / e.rate
+}
+
+// Encode encodes one frame of little-endian PCM, 2.5 to 60ms long, and
+// returns the packet, valid until the next call
+func (e *OpusEncoder) Encode(pcm []byte) ([]byte, error) {
+	n := len(pcm) / 2
+	if n == 0 {
+		return nil, fmt.Errorf("opus encoder: empty frame")
+	}
+	e.samples = e.samples[:0]
+	for i := 0; i < n; i++ {
+		e.samples = append(e.samples, C.opus_int16(int16(binary.LittleEndian.Uint16(pcm[2*i:]))))
+	}
+	size := C.opus_encode(e.enc, &e.samples[0], C.int(n), (*C.uchar)(&e.packet[0]), C.opus_int32(len(e.packet)))
+	if size < 0 {
+		return nil, fmt.Errorf("opus encoder: %s", C.GoString(C.opus_strerror(C.int(size))))
+	}
+	return e.packet[:size], nil
+}
+
+// Close frees the encoder
+func (e *OpusEncoder) Close() {
+	if e.enc != nil {
+		C.opus_encoder_destroy(e.enc)
+		e.enc = nil
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !opus || !cgo
+
+package audio
+
+import (
+	"fmt"
+)
+
+// OpusAvailable reports whether Opus encoding was compiled in
+const OpusAvailable = false
+
+// errOpusUnavailable is returned when the binary was built without libopus
+var errOpusUnavailable = fmt.Errorf("Opus encoding not available: rebuild with CGO_ENABLED=1 -tags opus")
+
+// OpusEncoder is a placeholder when built without the opus tag
+type OpusEncoder struct{}
+
+// NewOpusEncoder always fails when built without the opus tag
+func NewOpusEncoder(sampleRate int) (*OpusEncoder, error) {
+	return nil, errOpusUnavailable
+}
+
+// PreSkip returns 0
+func (e *OpusEncoder) PreSkip() int { return 0 }
+
+// Encode always fails when built without the opus tag
+func (e *OpusEncoder) Encode(pcm []byte) ([]byte, error) {
+	return nil, errOpusUnavailable
+}
+
+// Close is a no-op
+func (e *OpusEncoder) Close() {}
@@ -136,6 +136,24 @@ func (matcher *PatternMatcher) DetectInterrupt(text string) *InterruptRule {
 	return nil
 }
 
+// MatchesRule reports whether text matches the interrupt rule with the given
+// key, regardless of the other rules. Unlike DetectInterrupt it does not log
+// or reload the config, so it suits offline evaluation.
+func (matcher *PatternMatcher) MatchesRule(key, text string) bool {
+	matcher.mu.RLock()
+	defer matcher.mu.RUnlock()
+
+	rule, ok := matcher.config.Interrupts[key]
+	if !ok {
+		return false
+	}
+	searchText := text
+	if !matcher.config.Settings.CaseSensitive {
+		searchText = strings.ToLower(text)
+	}
+	return matcher.matchesRule(searchText, rule)
+}
+
 // matchesRule checks if the text matches any pattern in the rule
 func (matcher *PatternMatcher) matchesRule(searchText string, rule InterruptRule) bool {
 	for _, pattern := range rule.Patterns {
@@ -14,6 +14,7 @@ See CODE_RULES.md for complete documentation.
 */
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 	"log"
@@ -24,8 +25,12 @@ import (
 	"time"
 
 	"github.com/CyCoreSystems/audiosocket"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/dsp"
 )
 
+// audioSampleRate is the SLIN sample rate AudioSocket plays back
+const audioSampleRate = 8000
+
 // Player handles audio file loading and playback
 type Player struct {
 	audioCache map[string][]byte
@@ -76,45 +81,191 @@ func (p *Player) preloadAudioFiles() error {
 		p.mutex.Unlock()
 
 		log.Printf("Loaded audio file: %s (%d bytes)", filename, len(audioData))
+
+		// Catch gain problems in recordings before callers hear them;
+		// background files such as room tone are expected to be quiet
+		isPrompt := filepath.Dir(file) == filepath.Clean(p.audioDir)
+		if levels := MeasureLevels(audioData); levels.Clipping() {
+			log.Printf("Warning: Audio file %s is clipping (%d samples, peak %.1f dBFS)", filename, levels.ClippedSamples, levels.PeakDB)
+		} else if isPrompt && levels.NearSilent() {
+			log.Printf("Warning: Audio file %s is near silent (RMS %.1f dBFS)", filename, levels.RMSDB)
+		}
 	}
 
 	return nil
 }
 
-// loadWAVFile reads a WAV file and returns the raw PCM data
+// LoadDir loads the WAV files of dir, relative to the audio directory, keyed
+// by their path within it, e.g. "digits/3.wav" for the number prompts
+func (p *Player) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(p.audioDir, dir, "*.wav"))
+	if err != nil {
+		return fmt.Errorf("failed to glob audio files in %s: %w", dir, err)
+	}
+	for _, file := range files {
+		name := filepath.Join(dir, filepath.Base(file))
+		audioData, err := p.loadWAVFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to load audio file %s: %w", name, err)
+		}
+		p.mutex.Lock()
+		p.audioCache[name] = audioData
+		p.mutex.Unlock()
+	}
+	log.Printf("Loaded %d audio files from %s", len(files), filepath.Join(p.audioDir, dir))
+	return nil
+}
+
+// LoadWAV decodes a WAV file the way the player does on startup, returning
+// 8kHz mono 16-bit PCM
+func LoadWAV(path string) ([]byte, error) {
+	return (&Player{}).loadWAVFile(path)
+}
+
+// DecodeWAV decodes WAV data in memory, e.g. from a speech synthesis API,
+// the way the player decodes prompt files
+func DecodeWAV(data []byte) ([]byte, error) {
+	pcm, channels, sampleRate, err := parseWAV(data)
+	if err != nil {
+		return nil, err
+	}
+	return toPlayback(pcm, channels, sampleRate), nil
+}
+
+// EncodeWAV wraps 8kHz mono 16-bit PCM in a WAV header
+func EncodeWAV(pcm []byte) []byte {
+	out := make([]byte, 44, 44+len(pcm))
+	copy(out[0:], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(36+len(pcm)))
+	copy(out[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(out[16:], 16)
+	binary.LittleEndian.PutUint16(out[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(out[22:], 1)
+	binary.LittleEndian.PutUint32(out[24:], audioSampleRate)
+	binary.LittleEndian.PutUint32(out[28:], audioSampleRate*2)
+	binary.LittleEndian.PutUint16(out[32:], 2)
+	binary.LittleEndian.PutUint16(out[34:], 16)
+	copy(out[36:], "data")
+	binary.LittleEndian.PutUint32(out[40:], uint32(len(pcm)))
+	return append(out, pcm...)
+}
+
+// loadWAVFile reads a WAV file and returns raw 8kHz mono 16-bit PCM data.
+// Prompts recorded at other sample rates are resampled and stereo is downmixed.
 func (p *Player) loadWAVFile(filepath string) ([]byte, error) {
-	file, err := os.Open(filepath)
+	pcm, channels, sampleRate, err := readWAV(filepath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	return toPlayback(pcm, channels, sampleRate), nil
+}
+
+// toPlayback downmixes and resamples PCM to the format AudioSocket plays
+func toPlayback(pcm []byte, channels, sampleRate int) []byte {
+	if channels > 1 {
+		pcm = downmix(pcm, channels)
+	}
+	if sampleRate != audioSampleRate {
+		pcm = dsp.ResampleBytes(pcm, sampleRate, audioSampleRate)
+	}
+	return pcm
+}
 
-	// Read WAV header to find data chunk
-	header := make([]byte, 44)
-	if _, err := io.ReadFull(file, header); err != nil {
-		return nil, fmt.Errorf("failed to read WAV header: %w", err)
+// readWAV reads a 16-bit WAV file and returns its PCM data as recorded. A
+// file without a fmt chunk is taken to be AudioSocket format already.
+func readWAV(filepath string) (pcm []byte, channels, sampleRate int, err error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, 0, 0, err
 	}
+	return parseWAV(data)
+}
 
+// parseWAV is readWAV for WAV data in memory
+func parseWAV(data []byte) (pcm []byte, channels, sampleRate int, err error) {
 	// Verify it's a WAV file
-	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
-		return nil, fmt.Errorf("not a valid WAV file")
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, 0, 0, fmt.Errorf("not a valid WAV file")
 	}
 
-	// Find the data chunk
-	dataStart := 44
-	for i := 12; i < len(header)-4; i++ {
-		if string(header[i:i+4]) == "data" {
-			dataStart = i + 8
-			break
+	// Walk the chunks to find the format and data
+	var bitsPerSample int
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		body := data[pos+8:]
+		if size > len(body) {
+			size = len(body)
 		}
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, 0, fmt.Errorf("invalid fmt chunk")
+			}
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+		case "data":
+			pcm = body[:size]
+		}
+		pos += 8 + size + size%2
+	}
+	if pcm == nil {
+		return nil, 0, 0, fmt.Errorf("WAV file has no data chunk")
 	}
+	if sampleRate == 0 {
+		// No fmt chunk; assume the file is already AudioSocket format
+		return pcm, 1, audioSampleRate, nil
+	}
+	if bitsPerSample != 16 {
+		return nil, 0, 0, fmt.Errorf("unsupported WAV format: %d bits per sample", bitsPerSample)
+	}
+	return pcm, channels, sampleRate, nil
+}
+
+// downmix averages interleaved 16-bit channels into mono
+func downmix(pcm []byte, channels int) []byte {
+	frames := len(pcm) / (2 * channels)
+	out := make([]byte, frames*2)
+	for i := 0; i < frames; i++ {
+		sum := 0
+		for c := 0; c < channels; c++ {
+			off := (i*channels + c) * 2
+			sum += int(int16(binary.LittleEndian.Uint16(pcm[off:])))
+		}
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(int16(sum/channels)))
+	}
+	return out
+}
 
-	// Seek to data chunk and read PCM data
-	if _, err := file.Seek(int64(dataStart), 0); err != nil {
-		return nil, fmt.Errorf("failed to seek to data chunk: %w", err)
+// AddSpeedVariant caches source time-stretched to speed under name, keeping
+// the pitch unchanged. Variants already cached are left as they are, so the
+// stretch runs once per file and speed.
+func (p *Player) AddSpeedVariant(name, source string, speed float64) error {
+	if _, exists := p.GetAudio(name); exists {
+		return nil
+	}
+	audioData, exists := p.GetAudio(source)
+	if !exists {
+		return fmt.Errorf("audio file not found: %s", source)
 	}
 
-	return io.ReadAll(file)
+	stretched := dsp.SamplesToBytes(dsp.TimeStretch(dsp.BytesToSamples(audioData), audioSampleRate, speed))
+
+	p.mutex.Lock()
+	p.audioCache[name] = stretched
+	p.mutex.Unlock()
+
+	log.Printf("Prepared audio file: %s (%.2fx, %d bytes)", source, speed, len(stretched))
+	return nil
+}
+
+// AddAudio caches 8kHz mono 16-bit PCM under name, e.g. a synthesized
+// prompt, replacing audio cached under that name
+func (p *Player) AddAudio(name string, pcm []byte) {
+	p.mutex.Lock()
+	p.audioCache[name] = pcm
+	p.mutex.Unlock()
 }
 
 // GetAudio returns cached audio data for a given filename
@@ -133,10 +284,17 @@ func (p *Player) PlayAudio(conn net.Conn, filename string) error {
 		return fmt.Errorf("audio file not found: %s", filename)
 	}
 
-	// Use the built-in SendSlinChunks function with correct chunk size
-	// DefaultSlinChunkSize = 320 bytes (8000Hz * 20ms * 2 bytes)
-	if err := audiosocket.SendSlinChunks(conn, audiosocket.DefaultSlinChunkSize, audioData); err != nil {
-		return fmt.Errorf("failed to send audio: %w", err)
+	if pacesFrames(conn) {
+		// The connection keeps the 20ms clock; a second one would drift against it
+		if err := writeChunks(conn, audioData); err != nil {
+			return err
+		}
+	} else {
+		// Use the built-in SendSlinChunks function with correct chunk size
+		// DefaultSlinChunkSize = 320 bytes (8000Hz * 20ms * 2 bytes)
+		if err := audiosocket.SendSlinChunks(conn, audiosocket.DefaultSlinChunkSize, audioData); err != nil {
+			return fmt.Errorf("failed to send audio: %w", err)
+		}
 	}
 
 	log.Printf("Played audio file: %s (%d bytes)", filename, len(audioData))
@@ -150,25 +308,18 @@ func (p *Player) PlayAudioWithStop(conn net.Conn, filename string, stopChan <-ch
 		return fmt.Errorf("audio file not found: %s", filename)
 	}
 
-	// For the first chunk, ensure it's properly aligned to avoid distortion
-	// This fixes the 0.1 second distortion at the start
 	chunkSize := audiosocket.DefaultSlinChunkSize
-	
-	// If the first chunk is incomplete, skip it and start from a complete chunk
-	startOffset := 0
-	if len(audioData) > chunkSize && len(audioData)%chunkSize != 0 {
-		// Find the first complete chunk boundary
-		startOffset = chunkSize - (len(audioData) % chunkSize)
-		if startOffset >= len(audioData) {
-			startOffset = 0
-		}
-	}
+	startOffset := alignedStart(audioData)
 
 	// Send chunks with frequent stop checks
 	for i := startOffset; i < len(audioData); i += chunkSize {
 		// Check for stop signal before each chunk
 		select {
 		case <-stopChan:
+			// Fade out instead of cutting the prompt mid-word
+			if err := duckOut(conn, audioData[i:]); err != nil {
+				return err
+			}
 			log.Printf("Audio playback stopped: %s", filename)
 			return nil
 		default:
@@ -186,72 +337,109 @@ func (p *Player) PlayAudioWithStop(conn net.Conn, filename string, stopChan <-ch
 		}
 
 		// Small delay between chunks
-		time.Sleep(20 * time.Millisecond)
+		waitFrame(conn)
 	}
 
 	log.Printf("Played audio file: %s (%d bytes)", filename, len(audioData))
 	return nil
 }
 
-// PlayGreeting plays the greeting audio when a call connects
-func (p *Player) PlayGreeting(conn net.Conn) error {
-	// Try different greeting files in order of preference
-	greetingFiles := []string{"greeting.wav", "hello.wav"}
-
-	for _, filename := range greetingFiles {
-		if _, exists := p.GetAudio(filename); exists {
-			return p.PlayAudio(conn, filename)
-		}
-	}
+// PacedConn is implemented by connections that send SLIN frames on their
+// own 20ms clock, returning from Write once the frame is on its way, such as
+// a session's outbound stream. Audio written to them is not paced again.
+type PacedConn interface {
+	PacesFrames() bool
+}
 
-	return fmt.Errorf("no greeting audio file found")
+// pacesFrames reports whether w paces the frames written to it
+func pacesFrames(w io.Writer) bool {
+	p, ok := w.(PacedConn)
+	return ok && p.PacesFrames()
 }
 
-// StartAmbientAudio starts playing background ambient audio continuously
-// DISABLED FOR NOW - Will be re-enabled later when we solve the audio mixing issues
-func (p *Player) StartAmbientAudio(conn net.Conn, stopChan <-chan struct{}) {
-	log.Printf("Ambient audio DISABLED - will be re-enabled later")
-	// TODO: Re-enable ambient audio when we solve the audio mixing issues
-	return
+// waitFrame waits out the frame just written to w, unless w paces frames
+func waitFrame(w io.Writer) {
+	if !pacesFrames(w) {
+		time.Sleep(20 * time.Millisecond)
+	}
 }
 
-// PlayAmbientAudioWithPause plays ambient audio with frequent pause checks
-func (p *Player) PlayAmbientAudioWithPause(conn net.Conn, filename string, pauseChan <-chan struct{}, stopChan <-chan struct{}) error {
-	audioData, exists := p.GetAudio(filename)
-	if !exists {
-		return fmt.Errorf("audio file not found: %s", filename)
+// writeChunks writes audioData to a paced connection in 20ms chunks
+func writeChunks(w io.Writer, audioData []byte) error {
+	chunkSize := audiosocket.DefaultSlinChunkSize
+	for i := 0; i < len(audioData); i += chunkSize {
+		end := min(i+chunkSize, len(audioData))
+		if _, err := w.Write(audiosocket.SlinMessage(audioData[i:end])); err != nil {
+			return fmt.Errorf("failed to send audio chunk: %w", err)
+		}
 	}
+	return nil
+}
 
-	// For 8kHz audio, send in 20ms chunks (320 bytes = 8000Hz * 0.02s * 2 bytes)
+// alignedStart returns the offset playback should start from so the first
+// chunk is properly aligned. This fixes the 0.1 second distortion at the start.
+func alignedStart(audioData []byte) int {
 	chunkSize := audiosocket.DefaultSlinChunkSize
 
-	// Send chunks with frequent pause checks
-	for i := 0; i < len(audioData); i += chunkSize {
-		// Check for pause/stop signals before each chunk
-		select {
-		case <-pauseChan:
-			log.Printf("Ambient audio paused mid-playback")
-			return nil
-		case <-stopChan:
-			log.Printf("Ambient audio stopped mid-playback")
-			return nil
-		default:
-			// Continue playing
+	// If the first chunk is incomplete, skip it and start from a complete chunk
+	startOffset := 0
+	if len(audioData) > chunkSize && len(audioData)%chunkSize != 0 {
+		// Find the first complete chunk boundary
+		startOffset = chunkSize - (len(audioData) % chunkSize)
+		if startOffset >= len(audioData) {
+			startOffset = 0
 		}
+	}
+	return startOffset
+}
+
+// DuckDuration is how long an interrupted prompt takes to fade to silence
+const DuckDuration = 140 * time.Millisecond
+
+// duckOut plays up to DuckDuration of the remaining audio with a linear fade
+// to silence, so barge-in doesn't cut the prompt with an audible click
+func duckOut(conn net.Conn, remaining []byte) error {
+	chunkSize := audiosocket.DefaultSlinChunkSize
+	chunks := int(DuckDuration / (20 * time.Millisecond))
+	n := chunks * chunkSize
+	if n > len(remaining) {
+		n = len(remaining) &^ 1
+	}
+	if n == 0 {
+		return nil
+	}
+
+	faded := make([]byte, n)
+	samples := n / 2
+	for s := 0; s < samples; s++ {
+		gain := float64(samples-s) / float64(samples)
+		v := int16(binary.LittleEndian.Uint16(remaining[s*2:]))
+		binary.LittleEndian.PutUint16(faded[s*2:], uint16(int16(float64(v)*gain)))
+	}
 
+	for i := 0; i < n; i += chunkSize {
 		end := i + chunkSize
-		if end > len(audioData) {
-			end = len(audioData)
+		if end > n {
+			end = n
 		}
-
-		chunk := audioData[i:end]
-		if _, err := conn.Write(audiosocket.SlinMessage(chunk)); err != nil {
-			return fmt.Errorf("failed to send ambient audio chunk: %w", err)
+		if _, err := conn.Write(audiosocket.SlinMessage(faded[i:end])); err != nil {
+			return fmt.Errorf("failed to send audio chunk: %w", err)
 		}
+		waitFrame(conn)
+	}
+	return nil
+}
 
-		// Small delay between chunks
-		time.Sleep(20 * time.Millisecond)
+// PlayGreeting plays the greeting audio when a call connects
+func (p *Player) PlayGreeting(conn net.Conn) error {
+	// Try different greeting files in order of preference
+	greetingFiles := []string{"greeting.wav", "hello.wav"}
+
+	for _, filename := range greetingFiles {
+		if _, exists := p.GetAudio(filename); exists {
+			return p.PlayAudio(conn, filename)
+		}
 	}
 
-	return nil
+	return fmt.Errorf("no greeting audio file found")
 }
@@ -1,8 +1,13 @@
 package audio
 
 import (
+	"encoding/binary"
+	"net"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
 )
 
 func TestNewPlayer(t *testing.T) {
@@ -47,3 +52,51 @@ func TestLoadWAVFile(t *testing.T) {
 		t.Error("Expected error when loading non-existent file")
 	}
 }
+
+func TestPlayAudioWithStopDucks(t *testing.T) {
+	// One second of full-scale DC so the fade is easy to measure
+	audioData := make([]byte, 16000)
+	for i := 0; i < len(audioData); i += 2 {
+		binary.LittleEndian.PutUint16(audioData[i:], 10000)
+	}
+	player := &Player{audioCache: map[string][]byte{"prompt.wav": audioData}}
+
+	server, client := net.Pipe()
+	defer client.Close()
+
+	stop := make(chan struct{})
+	close(stop) // barge-in before the first chunk
+	done := make(chan error, 1)
+	go func() {
+		done <- player.PlayAudioWithStop(server, "prompt.wav", stop)
+		server.Close()
+	}()
+
+	var samples []int16
+	for {
+		msg, err := audiosocket.NextMessage(client)
+		if err != nil {
+			break
+		}
+		p := msg.Payload()
+		for i := 0; i+1 < len(p); i += 2 {
+			samples = append(samples, int16(binary.LittleEndian.Uint16(p[i:])))
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	want := int(DuckDuration / time.Millisecond * 8)
+	if len(samples) != want {
+		t.Fatalf("ducked %d samples, want %d", len(samples), want)
+	}
+	if samples[0] != 10000 || samples[len(samples)-1] > 100 {
+		t.Errorf("fade should go from full level to silence, got %d..%d", samples[0], samples[len(samples)-1])
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i] > samples[i-1] {
+			t.Fatalf("fade not monotonic at sample %d", i)
+		}
+	}
+}
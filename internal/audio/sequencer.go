@@ -0,0 +1,227 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
+)
+
+// Sequencer plays a session's prompts one after another on its connection.
+// Prompts requested while another is playing are queued and joined either
+// with a short crossfade or a fixed gap of silence, so multi-file messages
+// sound continuous instead of clicking or drifting with scheduling jitter.
+type Sequencer struct {
+	player    *Player
+	conn      net.Conn
+	crossfade int // bytes of overlap between consecutive prompts
+	gap       int // bytes of silence between consecutive prompts
+
+	mu      sync.Mutex
+	queue   []*promptRequest
+	running bool
+}
+
+type promptRequest struct {
+	filename string
+	data     []byte
+	stop     <-chan struct{}
+	started  func()
+	done     chan error
+}
+
+// NewSequencer creates a sequencer for conn. crossfade overlaps consecutive
+// prompts; otherwise gap inserts silence between them. Both are rounded to
+// 20ms chunks.
+func (p *Player) NewSequencer(conn net.Conn, crossfade, gap time.Duration) *Sequencer {
+	return &Sequencer{
+		player:    p,
+		conn:      conn,
+		crossfade: durationBytes(crossfade),
+		gap:       durationBytes(gap),
+	}
+}
+
+// durationBytes converts d to whole 20ms chunks of 8kHz SLIN
+func durationBytes(d time.Duration) int {
+	return int(d/(20*time.Millisecond)) * audiosocket.DefaultSlinChunkSize
+}
+
+// Play queues filename and blocks until it has played or stop is closed.
+// started, if not nil, is called when the prompt actually begins.
+func (sq *Sequencer) Play(filename string, stop <-chan struct{}, started func()) error {
+	audioData, exists := sq.player.GetAudio(filename)
+	if !exists {
+		return fmt.Errorf("audio file not found: %s", filename)
+	}
+
+	req := &promptRequest{
+		filename: filename,
+		data:     audioData[alignedStart(audioData):],
+		stop:     stop,
+		started:  started,
+		done:     make(chan error, 1),
+	}
+
+	sq.mu.Lock()
+	sq.queue = append(sq.queue, req)
+	if !sq.running {
+		sq.running = true
+		go sq.run()
+	}
+	sq.mu.Unlock()
+
+	return <-req.done
+}
+
+// next pops the next prompt that has not been stopped while queued
+func (sq *Sequencer) next() *promptRequest {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	for len(sq.queue) > 0 {
+		req := sq.queue[0]
+		if !stopped(req.stop) {
+			return req
+		}
+		sq.queue = sq.queue[1:]
+		log.Printf("Audio playback skipped: %s", req.filename)
+		req.done <- nil
+	}
+	sq.running = false
+	return nil
+}
+
+// pop removes the prompt that just finished from the head of the queue
+func (sq *Sequencer) pop() {
+	sq.mu.Lock()
+	sq.queue = sq.queue[1:]
+	sq.mu.Unlock()
+}
+
+// run plays queued prompts until the queue is empty
+func (sq *Sequencer) run() {
+	var joined *promptRequest // prompt whose head was sent in a crossfade
+	var joinedBytes int
+	for req := sq.next(); req != nil; req = sq.next() {
+		offset := 0
+		if req == joined {
+			offset = joinedBytes
+		}
+		if req.started != nil {
+			req.started()
+		}
+		var err error
+		joined, joinedBytes, err = sq.play(req, offset)
+		sq.pop()
+		req.done <- err
+	}
+}
+
+// play streams req from offset. If another prompt is queued when the tail is
+// reached it is crossfaded in (returning it and how much of it was sent) or
+// preceded by the configured gap.
+func (sq *Sequencer) play(req *promptRequest, offset int) (*promptRequest, int, error) {
+	chunkSize := audiosocket.DefaultSlinChunkSize
+	data := req.data
+
+	for i := offset; i < len(data); i += chunkSize {
+		select {
+		case <-req.stop:
+			if err := duckOut(sq.conn, data[i:]); err != nil {
+				return nil, 0, err
+			}
+			log.Printf("Audio playback stopped: %s", req.filename)
+			return nil, 0, nil
+		default:
+		}
+
+		end := i + chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[i:end]
+
+		// Overlap the tail with the head of the next prompt
+		if sq.crossfade > 0 && len(data)-i <= sq.crossfade {
+			if following := sq.following(); following != nil {
+				n, err := sq.crossfadeInto(data[i:], following.data)
+				log.Printf("Played audio file: %s (%d bytes, crossfaded into %s)", req.filename, len(data), following.filename)
+				return following, n, err
+			}
+		}
+
+		if _, err := sq.conn.Write(audiosocket.SlinMessage(chunk)); err != nil {
+			return nil, 0, fmt.Errorf("failed to send audio chunk: %w", err)
+		}
+		waitFrame(sq.conn)
+	}
+
+	if sq.gap > 0 && sq.following() != nil {
+		silence := make([]byte, sq.gap)
+		for i := 0; i < len(silence); i += chunkSize {
+			if _, err := sq.conn.Write(audiosocket.SlinMessage(silence[i : i+chunkSize])); err != nil {
+				return nil, 0, fmt.Errorf("failed to send audio chunk: %w", err)
+			}
+			waitFrame(sq.conn)
+		}
+	}
+
+	log.Printf("Played audio file: %s (%d bytes)", req.filename, len(data))
+	return nil, 0, nil
+}
+
+// following returns the prompt queued after the one playing, if any
+func (sq *Sequencer) following() *promptRequest {
+	sq.mu.Lock()
+	defer sq.mu.Unlock()
+	if len(sq.queue) < 2 || stopped(sq.queue[1].stop) {
+		return nil
+	}
+	return sq.queue[1]
+}
+
+// crossfadeInto sends tail mixed with the start of next using linear gains and
+// returns how many bytes of next were consumed
+func (sq *Sequencer) crossfadeInto(tail, next []byte) (int, error) {
+	n := len(tail) &^ 1
+	if n > len(next) {
+		n = len(next) &^ 1
+	}
+	mixed := make([]byte, len(tail)&^1)
+	samples := len(mixed) / 2
+	for s := 0; s < samples; s++ {
+		out := float64(int16(binary.LittleEndian.Uint16(tail[s*2:])))
+		in := 0.0
+		if s*2 < n {
+			in = float64(int16(binary.LittleEndian.Uint16(next[s*2:])))
+		}
+		gain := float64(s) / float64(samples)
+		binary.LittleEndian.PutUint16(mixed[s*2:], uint16(clampSample(out*(1-gain)+in*gain)))
+	}
+
+	chunkSize := audiosocket.DefaultSlinChunkSize
+	for i := 0; i < len(mixed); i += chunkSize {
+		end := i + chunkSize
+		if end > len(mixed) {
+			end = len(mixed)
+		}
+		if _, err := sq.conn.Write(audiosocket.SlinMessage(mixed[i:end])); err != nil {
+			return 0, fmt.Errorf("failed to send audio chunk: %w", err)
+		}
+		waitFrame(sq.conn)
+	}
+	return n, nil
+}
+
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,107 @@
+package audio
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
+)
+
+// playTwo queues a and b back to back and returns the bytes sent
+func playTwo(t *testing.T, crossfade, gap time.Duration) int {
+	t.Helper()
+	player := &Player{audioCache: map[string][]byte{
+		"a.wav": make([]byte, 3200), // 200ms
+		"b.wav": make([]byte, 3200),
+	}}
+
+	server, client := net.Pipe()
+	sq := player.NewSequencer(server, crossfade, gap)
+
+	received := make(chan int)
+	go func() {
+		total := 0
+		for {
+			msg, err := audiosocket.NextMessage(client)
+			if err != nil {
+				received <- total
+				return
+			}
+			total += len(msg.Payload())
+		}
+	}()
+
+	var wg sync.WaitGroup
+	var order []string
+	var mu sync.Mutex
+	for _, name := range []string{"a.wav", "b.wav"} {
+		name := name
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started := func() {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+			}
+			if err := sq.Play(name, nil, started); err != nil {
+				t.Error(err)
+			}
+		}()
+		time.Sleep(30 * time.Millisecond) // queue b while a plays
+	}
+	wg.Wait()
+	server.Close()
+
+	if len(order) != 2 || order[0] != "a.wav" {
+		t.Errorf("prompts started in order %v", order)
+	}
+	return <-received
+}
+
+func TestSequencerJoinsPrompts(t *testing.T) {
+	if got := playTwo(t, 0, 0); got != 6400 {
+		t.Errorf("back to back sent %d bytes, want 6400", got)
+	}
+	if got := playTwo(t, 40*time.Millisecond, 0); got != 6400-640 {
+		t.Errorf("crossfade sent %d bytes, want %d", got, 6400-640)
+	}
+	if got := playTwo(t, 0, 60*time.Millisecond); got != 6400+960 {
+		t.Errorf("gap sent %d bytes, want %d", got, 6400+960)
+	}
+}
+
+func TestSequencerStopSkipsQueued(t *testing.T) {
+	player := &Player{audioCache: map[string][]byte{"a.wav": make([]byte, 32000)}}
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		for {
+			if _, err := audiosocket.NextMessage(client); err != nil {
+				return
+			}
+		}
+	}()
+
+	sq := player.NewSequencer(server, 0, 0)
+	stop := make(chan struct{})
+	results := make(chan error, 2)
+	go func() { results <- sq.Play("a.wav", stop, nil) }()
+	time.Sleep(30 * time.Millisecond)
+	go func() { results <- sq.Play("a.wav", stop, nil) }()
+	time.Sleep(30 * time.Millisecond)
+
+	close(stop)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-results:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("barge-in should stop the playing prompt and skip the queued one")
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// Voice detector defaults
+const (
+	DefaultVoiceMinDB    = -38.0 // quieter frames are never speech
+	DefaultVoiceMarginDB = 10.0  // speech stands this far above the line noise
+	DefaultVoiceOnsetMs  = 60    // speech must last this long to count
+)
+
+const (
+	voiceFrameMs    = 10   // analysis frame; short so speech is caught quickly
+	noiseFloorAlpha = 0.05 // weight of each quiet frame in the noise floor (~200ms)
+)
+
+// VoiceSettings tunes the voice detector; zero values use the defaults above
+type VoiceSettings struct {
+	MinDB    float64
+	MarginDB float64
+	OnsetMs  int
+}
+
+// VoiceDetector notices the caller starting to speak, for barge-in. A frame
+// is voiced when it is louder than MinDB and MarginDB above the noise floor
+// learned from quieter frames; OnsetMs of consecutive voiced audio is speech.
+// Short clicks and line noise therefore do not count, and the caller is
+// heard within OnsetMs plus one frame. It expects 16-bit little-endian mono
+// PCM.
+type VoiceDetector struct {
+	settings VoiceSettings
+	frameLen int
+	onset    int // voiced frames that make speech
+
+	mu       sync.Mutex
+	pending  []byte  // bytes not yet making up a full frame
+	noiseDB  float64 // noise floor; silenceDB until a quiet frame is seen
+	voiced   int     // consecutive voiced frames
+	speaking bool    // speech was reported and has not ended
+}
+
+// NewVoiceDetector creates a detector for audio at sampleRate
+func NewVoiceDetector(settings VoiceSettings, sampleRate int) *VoiceDetector {
+	if settings.MinDB == 0 {
+		settings.MinDB = DefaultVoiceMinDB
+	}
+	if settings.MarginDB == 0 {
+		settings.MarginDB = DefaultVoiceMarginDB
+	}
+	if settings.OnsetMs == 0 {
+		settings.OnsetMs = DefaultVoiceOnsetMs
+	}
+	if sampleRate <= 0 {
+		sampleRate = 8000
+	}
+	onset := settings.OnsetMs / voiceFrameMs
+	if onset < 1 {
+		onset = 1
+	}
+	return &VoiceDetector{
+		settings: settings,
+		frameLen: sampleRate * voiceFrameMs / 1000 * 2,
+		onset:    onset,
+		noiseDB:  silenceDB,
+	}
+}
+
+// Add analyzes a block of audio and reports whether the caller started
+// speaking in it. Speech is reported once until the caller falls silent.
+func (d *VoiceDetector) Add(pcm []byte) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	started := false
+	d.pending = append(d.pending, pcm...)
+	for len(d.pending) >= d.frameLen {
+		if d.frame(d.pending[:d.frameLen]) {
+			started = true
+		}
+		d.pending = d.pending[d.frameLen:]
+	}
+	// Keep the partial frame without growing the buffer forever
+	d.pending = append(d.pending[:0:0], d.pending...)
+	return started
+}
+
+// Speaking reports whether the caller is speaking: speech was reported and
+// no quiet frame has followed
+func (d *VoiceDetector) Speaking() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.speaking
+}
+
+// Reset forgets speech in progress, so a caller already talking when a
+// prompt starts is reported again. The noise floor is kept.
+func (d *VoiceDetector) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.voiced = 0
+	d.speaking = false
+	d.pending = d.pending[:0]
+}
+
+// frame updates the detector with one frame and reports a speech onset
+func (d *VoiceDetector) frame(frame []byte) bool {
+	var sum float64
+	n := len(frame) / 2
+	for i := 0; i < n; i++ {
+		v := float64(int16(binary.LittleEndian.Uint16(frame[i*2:])))
+		sum += v * v
+	}
+	db := toDB(math.Sqrt(sum / float64(n)))
+
+	if db < d.settings.MinDB || db < d.noiseDB+d.settings.MarginDB {
+		// Quiet frames teach the noise floor; the first sets it outright
+		if d.noiseDB == silenceDB {
+			d.noiseDB = db
+		} else {
+			d.noiseDB += noiseFloorAlpha * (db - d.noiseDB)
+		}
+		d.voiced = 0
+		d.speaking = false
+		return false
+	}
+	d.voiced++
+	if d.voiced < d.onset || d.speaking {
+		return false
+	}
+	d.speaking = true
+	return true
+}
@@ -0,0 +1,54 @@
+package audio
+
+import "testing"
+
+// onsets adds pcm in 20ms frames and returns the offsets in ms at which
+// speech was reported
+func onsets(d *VoiceDetector, pcm []byte) []int {
+	var at []int
+	for i := 0; i < len(pcm); i += 320 {
+		if d.Add(pcm[i:min(i+320, len(pcm))]) {
+			at = append(at, (i+320)/16)
+		}
+	}
+	return at
+}
+
+func TestVoiceDetector(t *testing.T) {
+	d := NewVoiceDetector(VoiceSettings{}, 8000)
+	if at := onsets(d, tone(300, -50, 1)); len(at) != 0 {
+		t.Fatalf("line noise reported as speech at %v ms", at)
+	}
+	// A click is too short to be speech
+	if at := onsets(d, append(tone(300, -20, 0.02), tone(300, -50, 0.2)...)); len(at) != 0 {
+		t.Errorf("click reported as speech at %v ms", at)
+	}
+
+	// Speech is heard within ~100ms and reported once
+	at := onsets(d, tone(150, -25, 1))
+	if len(at) != 1 || at[0] > 100 {
+		t.Fatalf("speech reported at %v ms, want once within 100ms", at)
+	}
+	// A pause ends it; the next utterance is reported again
+	if at := onsets(d, append(tone(300, -50, 0.3), tone(150, -25, 0.5)...)); len(at) != 1 {
+		t.Errorf("second utterance reported at %v ms, want once", at)
+	}
+
+	// Reset reports a caller who keeps talking
+	d.Reset()
+	if at := onsets(d, tone(150, -25, 0.2)); len(at) != 1 {
+		t.Errorf("speech after reset reported at %v ms, want once", at)
+	}
+}
+
+func TestVoiceDetectorNoisyLine(t *testing.T) {
+	d := NewVoiceDetector(VoiceSettings{}, 8000)
+	// Loud background noise raises the floor; speech must stand out from it
+	onsets(d, tone(300, -42, 1))
+	if at := onsets(d, tone(300, -36, 0.5)); len(at) != 0 {
+		t.Errorf("noise 6dB over the floor reported as speech at %v ms", at)
+	}
+	if at := onsets(d, tone(150, -20, 0.5)); len(at) != 1 {
+		t.Errorf("speech over noise reported at %v ms, want once", at)
+	}
+}
@@ -0,0 +1,72 @@
+// Package bufpool provides sync.Pool-backed byte and sample buffers for the
+// per-frame audio path. With hundreds of sessions each producing a 20ms frame
+// every 20ms, allocating fresh buffers per frame keeps the garbage collector
+// busy for no benefit.
+//
+// A buffer obtained from Get* must not be used after it is passed to Put*.
+package bufpool
+
+import (
+	"math/bits"
+	"sync"
+)
+
+// MaxPooled is the largest capacity kept for reuse; bigger buffers (e.g. a
+// whole prompt) are left to the garbage collector
+const MaxPooled = 1 << maxClass
+
+// maxClass is the size class of MaxPooled (64K elements)
+const maxClass = 16
+
+// slicePool pools slices in power-of-two size classes so callers asking for
+// different sizes do not keep trading buffers that are too small. sync.Pool
+// needs pointers, so the *[]T holders are recycled through a second pool to
+// keep Put allocation-free.
+type slicePool[T any] struct {
+	classes [maxClass + 1]sync.Pool // *[]T with cap >= 1<<class
+	holders sync.Pool               // empty *[]T
+}
+
+func (p *slicePool[T]) get(n int) []T {
+	if n <= 0 || n > MaxPooled {
+		return make([]T, 0, n)
+	}
+	class := bits.Len(uint(n - 1)) // smallest class with 1<<class >= n
+	if h, ok := p.classes[class].Get().(*[]T); ok {
+		buf := *h
+		*h = nil
+		p.holders.Put(h)
+		return buf[:0]
+	}
+	return make([]T, 0, 1<<class)
+}
+
+func (p *slicePool[T]) put(buf []T) {
+	if cap(buf) == 0 || cap(buf) > MaxPooled {
+		return
+	}
+	class := bits.Len(uint(cap(buf))) - 1 // largest class with 1<<class <= cap
+	h, ok := p.holders.Get().(*[]T)
+	if !ok {
+		h = new([]T)
+	}
+	*h = buf[:0]
+	p.classes[class].Put(h)
+}
+
+var (
+	bytes   slicePool[byte]
+	samples slicePool[int16]
+)
+
+// GetBytes returns an empty byte slice with capacity for at least n bytes
+func GetBytes(n int) []byte { return bytes.get(n) }
+
+// PutBytes returns a buffer obtained from GetBytes to the pool
+func PutBytes(b []byte) { bytes.put(b) }
+
+// GetSamples returns an empty sample slice with capacity for at least n samples
+func GetSamples(n int) []int16 { return samples.get(n) }
+
+// PutSamples returns a buffer obtained from GetSamples to the pool
+func PutSamples(s []int16) { samples.put(s) }
@@ -0,0 +1,37 @@
+package bufpool
+
+import "testing"
+
+func TestGetPut(t *testing.T) {
+	for _, n := range []int{1, 320, 640, 1000, MaxPooled} {
+		b := GetBytes(n)
+		if len(b) != 0 || cap(b) < n {
+			t.Errorf("GetBytes(%d): len=%d cap=%d", n, len(b), cap(b))
+		}
+		PutBytes(append(b, 1))
+
+		s := GetSamples(n)
+		if len(s) != 0 || cap(s) < n {
+			t.Errorf("GetSamples(%d): len=%d cap=%d", n, len(s), cap(s))
+		}
+		PutSamples(s)
+	}
+
+	// Oversized buffers are allocated but never pooled
+	if b := GetBytes(MaxPooled + 1); cap(b) < MaxPooled+1 {
+		t.Errorf("oversized GetBytes cap=%d", cap(b))
+	}
+	PutBytes(make([]byte, 0, MaxPooled*2))
+}
+
+func BenchmarkGetPutMixedSizes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		in := GetSamples(320)
+		out := GetSamples(161)
+		pcm := GetBytes(322)
+		PutBytes(pcm)
+		PutSamples(out)
+		PutSamples(in)
+	}
+}
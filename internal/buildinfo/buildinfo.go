@@ -0,0 +1,110 @@
+// Package buildinfo identifies the running build. Release builds set the
+// variables with ldflags (see build_release.sh):
+//
+//	go build -ldflags "-X github.com/amanullahtanweer/audiosocket-transcriber/internal/buildinfo.Version=v1.4.0 \
+//	    -X github.com/amanullahtanweer/audiosocket-transcriber/internal/buildinfo.Commit=$(git rev-parse HEAD) \
+//	    -X github.com/amanullahtanweer/audiosocket-transcriber/internal/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+//
+// Builds without them fall back to the VCS details the Go toolchain embeds.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// Set at build time with -ldflags "-X ..."
+var (
+	Version = "dev"
+	Commit  = ""
+	Date    = ""
+)
+
+// maxSourceLen is the longest source Vicidial's APIs record
+const maxSourceLen = 20
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+var (
+	once sync.Once
+	info Info
+)
+
+// Get returns the build information
+func Get() Info {
+	once.Do(func() {
+		info = Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+		bi, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		modified := false
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.Date == "" {
+					info.Date = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			}
+		}
+		if modified && Commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	})
+	return info
+}
+
+// ShortCommit returns the abbreviated commit hash
+func (i Info) ShortCommit() string {
+	if len(i.Commit) > 7 {
+		return i.Commit[:7]
+	}
+	return i.Commit
+}
+
+// Tag identifies the build compactly: the version for releases, otherwise
+// the version and the commit it was built from, e.g. "dev-1a2b3c4"
+func (i Info) Tag() string {
+	if i.Version != "dev" || i.Commit == "" {
+		return i.Version
+	}
+	return i.Version + "-" + i.ShortCommit()
+}
+
+// String formats the build for logs, e.g. "v1.4.0 (commit 1a2b3c4, built
+// 2026-10-01T12:00:00Z, go1.23.4)"
+func (i Info) String() string {
+	s := i.Version + " ("
+	if i.Commit != "" {
+		s += "commit " + i.ShortCommit() + ", "
+	}
+	if i.Date != "" {
+		s += "built " + i.Date + ", "
+	}
+	return s + i.GoVersion + ")"
+}
+
+// Source appends the build tag to a Vicidial API source so calls in
+// Vicidial's API log can be traced to a build, e.g. "igent-v1.4.0". The
+// source is returned unchanged when there is no room for the tag.
+func Source(source string) string {
+	tagged := fmt.Sprintf("%s-%s", source, Get().Tag())
+	if source == "" || len(tagged) > maxSourceLen {
+		return source
+	}
+	return tagged
+}
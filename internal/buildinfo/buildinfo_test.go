@@ -0,0 +1,30 @@
+package buildinfo
+
+import "testing"
+
+func TestTagAndString(t *testing.T) {
+	dev := Info{Version: "dev", Commit: "1a2b3c4d5e6f", GoVersion: "go1.23.4"}
+	if got := dev.Tag(); got != "dev-1a2b3c4" {
+		t.Errorf("dev Tag() = %q", got)
+	}
+	release := Info{Version: "v1.4.0", Commit: "1a2b3c4d5e6f", Date: "2026-10-01T12:00:00Z", GoVersion: "go1.23.4"}
+	if got := release.Tag(); got != "v1.4.0" {
+		t.Errorf("release Tag() = %q", got)
+	}
+	if got, want := release.String(), "v1.4.0 (commit 1a2b3c4, built 2026-10-01T12:00:00Z, go1.23.4)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSource(t *testing.T) {
+	tag := Get().Tag()
+	if got := Source("igent"); got != "igent-"+tag && len("igent-"+tag) <= maxSourceLen {
+		t.Errorf("Source(igent) = %q", got)
+	}
+	if got := Source("a-very-long-source-name"); got != "a-very-long-source-name" {
+		t.Errorf("long source changed to %q", got)
+	}
+	if got := Source(""); got != "" {
+		t.Errorf("empty source changed to %q", got)
+	}
+}
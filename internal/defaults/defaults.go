@@ -0,0 +1,60 @@
+// Package defaults embeds a demo configuration, call flow, interrupt patterns
+// and prompts so a new deployment can run a demo call out of the box
+// (cmd/server --init writes them to disk).
+package defaults
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+//go:embed files
+var embedded embed.FS
+
+// Files returns the default files laid out like a deployment directory:
+// config.yaml, config/flow.json, config/interrupts.yaml and audios/*.wav
+func Files() fs.FS {
+	sub, err := fs.Sub(embedded, "files")
+	if err != nil {
+		panic(err) // the embedded directory always exists
+	}
+	return sub
+}
+
+// WriteTo writes the default files under dir. Existing files are left alone
+// unless overwrite is set. It returns the paths written and those skipped.
+func WriteTo(dir string, overwrite bool) (written, skipped []string, err error) {
+	files := Files()
+	err = fs.WalkDir(files, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if !overwrite {
+			if _, err := os.Stat(target); err == nil {
+				skipped = append(skipped, target)
+				return nil
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+
+		data, err := fs.ReadFile(files, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		written = append(written, target)
+		return nil
+	})
+	return written, skipped, err
+}
@@ -0,0 +1,58 @@
+package defaults
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteTo(t *testing.T) {
+	dir := t.TempDir()
+	custom := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(custom, []byte("custom"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	written, skipped, err := WriteTo(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skipped) != 1 || skipped[0] != custom {
+		t.Errorf("skipped = %v, want only the existing config.yaml", skipped)
+	}
+	for _, name := range []string{"config/flow.json", "config/interrupts.yaml", "audios/hello.wav"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not written: %v", name, err)
+		}
+	}
+	if data, _ := os.ReadFile(custom); string(data) != "custom" {
+		t.Error("existing config.yaml was overwritten")
+	}
+
+	written2, _, err := WriteTo(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written2) != len(written)+1 {
+		t.Errorf("overwrite wrote %d files, want %d", len(written2), len(written)+1)
+	}
+}
+
+func TestDefaultsMatchRepository(t *testing.T) {
+	// The embedded flow and prompts are copies; keep them in sync with the
+	// repository's own defaults
+	for _, name := range []string{"config/flow.json", "config/interrupts.yaml", "audios/pitch.wav"} {
+		embedded, err := os.ReadFile(filepath.Join("files", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo, err := os.ReadFile(filepath.Join("..", "..", name))
+		if err != nil {
+			t.Skipf("repository copy unavailable: %v", err)
+		}
+		if !bytes.Equal(embedded, repo) {
+			t.Errorf("embedded %s differs from the repository copy", name)
+		}
+	}
+}
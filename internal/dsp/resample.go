@@ -0,0 +1,276 @@
+// Package dsp contains signal processing helpers for 16-bit mono PCM audio.
+package dsp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// tapsPerPhase is the number of filter taps applied per output sample.
+// 24 taps gives >60dB stopband with a Kaiser window while costing only a
+// few microseconds per 20ms frame.
+const tapsPerPhase = 24
+
+// kaiserBeta controls the window's sidelobe attenuation (~80dB)
+const kaiserBeta = 8.0
+
+// Resampler converts mono PCM between sample rates with a windowed-sinc
+// polyphase filter. It keeps filter history between calls so a stream can be
+// fed frame by frame without discontinuities at frame boundaries.
+type Resampler struct {
+	inRate, outRate int
+	up, down        int         // rational ratio out/in = up/down
+	phases          [][]float64 // phases[p][k] = h[p + k*up]
+
+	history []float64 // trailing input samples, oldest first
+	base    int64     // absolute input index of history[0]
+	inCount int64     // total input samples received
+	outPos  int64     // absolute index of the next output sample
+}
+
+// NewResampler creates a streaming resampler from inRate to outRate
+func NewResampler(inRate, outRate int) *Resampler {
+	g := gcd(inRate, outRate)
+	up, down := outRate/g, inRate/g
+
+	r := &Resampler{
+		inRate:  inRate,
+		outRate: outRate,
+		up:      up,
+		down:    down,
+		phases:  designPolyphase(inRate, outRate, up, down),
+	}
+	r.Reset()
+	return r
+}
+
+// Reset clears the filter history
+func (r *Resampler) Reset() {
+	// Pre-fill history with silence so the first samples have full support
+	r.history = make([]float64, tapsPerPhase-1)
+	r.base = -int64(tapsPerPhase - 1)
+	r.inCount = 0
+	r.outPos = 0
+}
+
+// Delay returns the filter's group delay in output samples
+func (r *Resampler) Delay() int {
+	return filterCenter(r.up, r.down) / r.down
+}
+
+// Process resamples the next block of input and returns the output samples
+// that can be produced so far
+func (r *Resampler) Process(in []int16) []int16 {
+	return r.ProcessAppend(make([]int16, 0, r.OutputSize(len(in))), in)
+}
+
+// OutputSize returns an upper bound on the samples Process produces for n
+// input samples
+func (r *Resampler) OutputSize(n int) int {
+	return n*r.up/r.down + 1
+}
+
+// ProcessAppend is Process appending the output to dst, so callers can reuse
+// (e.g. pooled) buffers instead of allocating per frame
+func (r *Resampler) ProcessAppend(dst, in []int16) []int16 {
+	if r.up == r.down {
+		return append(dst, in...)
+	}
+
+	for _, s := range in {
+		r.history = append(r.history, float64(s))
+	}
+	r.inCount += int64(len(in))
+
+	for s, ok := r.next(); ok; s, ok = r.next() {
+		dst = append(dst, s)
+	}
+	r.trim()
+	return dst
+}
+
+// ProcessBytes is ProcessAppend for little-endian 16-bit PCM bytes. Input is
+// decoded straight into the filter history and output encoded straight into
+// dst, skipping the intermediate sample buffers. A trailing odd byte is
+// ignored.
+func (r *Resampler) ProcessBytes(dst, in []byte) []byte {
+	in = in[:len(in)&^1]
+	if r.up == r.down {
+		return append(dst, in...)
+	}
+
+	for i := 0; i < len(in); i += 2 {
+		r.history = append(r.history, float64(int16(binary.LittleEndian.Uint16(in[i:]))))
+	}
+	r.inCount += int64(len(in) / 2)
+
+	for s, ok := r.next(); ok; s, ok = r.next() {
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(s))
+	}
+	r.trim()
+	return dst
+}
+
+// next computes the next output sample, if the input received so far allows it
+func (r *Resampler) next() (int16, bool) {
+	pos := r.outPos * int64(r.down)
+	i := pos / int64(r.up) // newest input sample used
+	if i >= r.inCount {
+		return 0, false
+	}
+	coeffs := r.phases[pos%int64(r.up)]
+
+	var acc float64
+	idx := int(i - r.base)
+	for k, c := range coeffs {
+		acc += c * r.history[idx-k]
+	}
+	r.outPos++
+	return clamp16(acc), true
+}
+
+// trim drops history no longer needed by the next output sample
+func (r *Resampler) trim() {
+	nextI := (r.outPos * int64(r.down)) / int64(r.up)
+	keepFrom := nextI - int64(tapsPerPhase-1)
+	if drop := int(keepFrom - r.base); drop > 0 {
+		if drop > len(r.history) {
+			drop = len(r.history)
+		}
+		r.history = append(r.history[:0], r.history[drop:]...)
+		r.base += int64(drop)
+	}
+}
+
+// Resample converts a complete buffer, compensating for the filter delay so
+// the output is time-aligned with the input
+func Resample(in []int16, inRate, outRate int) []int16 {
+	if inRate == outRate || len(in) == 0 {
+		out := make([]int16, len(in))
+		copy(out, in)
+		return out
+	}
+
+	r := NewResampler(inRate, outRate)
+	want := int(int64(len(in)) * int64(outRate) / int64(inRate))
+	delay := r.Delay()
+
+	out := r.Process(in)
+	// Flush the filter tail with silence
+	padding := make([]int16, tapsPerPhase)
+	for len(out) < want+delay {
+		out = append(out, r.Process(padding)...)
+	}
+	return out[delay : delay+want]
+}
+
+// ResampleBytes converts little-endian 16-bit PCM bytes between rates
+func ResampleBytes(in []byte, inRate, outRate int) []byte {
+	return SamplesToBytes(Resample(BytesToSamples(in), inRate, outRate))
+}
+
+// BytesToSamples decodes little-endian 16-bit PCM; a trailing odd byte is ignored
+func BytesToSamples(b []byte) []int16 {
+	return AppendSamples(make([]int16, 0, len(b)/2), b)
+}
+
+// AppendSamples decodes little-endian 16-bit PCM and appends it to dst
+func AppendSamples(dst []int16, b []byte) []int16 {
+	for i := 0; i+1 < len(b); i += 2 {
+		dst = append(dst, int16(binary.LittleEndian.Uint16(b[i:])))
+	}
+	return dst
+}
+
+// SamplesToBytes encodes samples as little-endian 16-bit PCM
+func SamplesToBytes(samples []int16) []byte {
+	return AppendBytes(make([]byte, 0, len(samples)*2), samples)
+}
+
+// AppendBytes encodes samples as little-endian 16-bit PCM and appends them to dst
+func AppendBytes(dst []byte, samples []int16) []byte {
+	for _, s := range samples {
+		dst = binary.LittleEndian.AppendUint16(dst, uint16(s))
+	}
+	return dst
+}
+
+// filterCenter returns the filter's center tap, rounded to a multiple of down
+// so the group delay is a whole number of output samples
+func filterCenter(up, down int) int {
+	return (tapsPerPhase * up / 2) / down * down
+}
+
+// designPolyphase builds a Kaiser-windowed sinc lowpass at the upsampled rate
+// and splits it into up phases
+func designPolyphase(inRate, outRate, up, down int) [][]float64 {
+	n := tapsPerPhase * up
+	upRate := float64(inRate) * float64(up)
+
+	// Cut off slightly below the lower Nyquist frequency to leave room for
+	// the transition band
+	cutoff := 0.45 * float64(minInt(inRate, outRate)) / upRate
+	center := float64(filterCenter(up, down))
+	halfWidth := math.Max(center, float64(n-1)-center) + 1
+	i0Beta := besselI0(kaiserBeta)
+
+	h := make([]float64, n)
+	for j := range h {
+		x := float64(j) - center
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		ratio := x / halfWidth
+		w := besselI0(kaiserBeta*math.Sqrt(1-ratio*ratio)) / i0Beta
+		// Gain of up compensates for the zeros inserted by upsampling
+		h[j] = sinc * w * float64(up)
+	}
+
+	phases := make([][]float64, up)
+	for p := 0; p < up; p++ {
+		phases[p] = make([]float64, tapsPerPhase)
+		for k := 0; k < tapsPerPhase; k++ {
+			phases[p][k] = h[p+k*up]
+		}
+	}
+	return phases
+}
+
+// besselI0 is the zeroth-order modified Bessel function of the first kind
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < 1e-12*sum {
+			break
+		}
+	}
+	return sum
+}
+
+func clamp16(v float64) int16 {
+	v = math.Round(v)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
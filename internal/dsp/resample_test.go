@@ -0,0 +1,111 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func sine(freq float64, rate, n int, amp float64) []int16 {
+	out := make([]int16, n)
+	for i := range out {
+		out[i] = int16(amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate)))
+	}
+	return out
+}
+
+// rmsError compares got against an ideal sine, skipping edge samples
+func rmsError(got []int16, freq float64, rate int, amp float64) float64 {
+	want := sine(freq, rate, len(got), amp)
+	var sum float64
+	edge := 64
+	for i := edge; i < len(got)-edge; i++ {
+		d := float64(got[i]) - float64(want[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(len(got)-2*edge))
+}
+
+func TestResampleUpPreservesTone(t *testing.T) {
+	in := sine(1000, 8000, 8000, 10000)
+	out := Resample(in, 8000, 16000)
+	if len(out) != 16000 {
+		t.Fatalf("Expected 16000 samples, got %d", len(out))
+	}
+	// Linear interpolation is off by several hundred here; sinc is far closer
+	if err := rmsError(out, 1000, 16000, 10000); err > 20 {
+		t.Errorf("RMS error too high: %.1f", err)
+	}
+}
+
+func TestResampleDownRejectsAlias(t *testing.T) {
+	// 1kHz survives 16k->8k
+	out := Resample(sine(1000, 16000, 16000, 10000), 16000, 8000)
+	if len(out) != 8000 {
+		t.Fatalf("Expected 8000 samples, got %d", len(out))
+	}
+	if err := rmsError(out, 1000, 8000, 10000); err > 20 {
+		t.Errorf("RMS error too high: %.1f", err)
+	}
+
+	// 6kHz is above the 4kHz Nyquist of the output and must be filtered out
+	alias := Resample(sine(6000, 16000, 16000, 10000), 16000, 8000)
+	var energy float64
+	for _, s := range alias[100 : len(alias)-100] {
+		energy += float64(s) * float64(s)
+	}
+	if rms := math.Sqrt(energy / float64(len(alias)-200)); rms > 200 {
+		t.Errorf("Aliased tone not attenuated, RMS %.1f", rms)
+	}
+}
+
+func TestResamplerStreamingMatchesBlock(t *testing.T) {
+	in := sine(440, 8000, 4000, 8000)
+
+	whole := NewResampler(8000, 16000).Process(in)
+
+	stream := NewResampler(8000, 16000)
+	var chunked []int16
+	for i := 0; i < len(in); i += 160 {
+		chunked = append(chunked, stream.Process(in[i:i+160])...)
+	}
+
+	if len(whole) != len(chunked) {
+		t.Fatalf("Length mismatch: %d vs %d", len(whole), len(chunked))
+	}
+	for i := range whole {
+		if whole[i] != chunked[i] {
+			t.Fatalf("Sample %d differs: %d vs %d", i, whole[i], chunked[i])
+		}
+	}
+}
+
+func TestProcessBytesMatchesProcess(t *testing.T) {
+	in := sine(440, 8000, 4000, 8000)
+	want := NewResampler(8000, 16000).Process(in)
+
+	r := NewResampler(8000, 16000)
+	pcm := SamplesToBytes(in)
+	var got []byte
+	for i := 0; i < len(pcm); i += 320 {
+		got = r.ProcessBytes(got, pcm[i:i+320])
+	}
+
+	if len(got) != len(want)*2 {
+		t.Fatalf("Length mismatch: %d bytes vs %d samples", len(got), len(want))
+	}
+	for i, s := range BytesToSamples(got) {
+		if s != want[i] {
+			t.Fatalf("Sample %d differs: %d vs %d", i, s, want[i])
+		}
+	}
+}
+
+func TestBytesRoundTrip(t *testing.T) {
+	samples := []int16{0, 1, -1, math.MaxInt16, math.MinInt16}
+	got := BytesToSamples(SamplesToBytes(samples))
+	for i := range samples {
+		if got[i] != samples[i] {
+			t.Errorf("Sample %d: expected %d, got %d", i, samples[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package dsp
+
+import (
+	"math"
+)
+
+// Stretch parameters: 30ms Hann frames at 50% overlap, with the analysis
+// position allowed to drift ±8ms to keep consecutive frames in phase
+const (
+	stretchFrameMs     = 30
+	stretchToleranceMs = 8
+)
+
+// TimeStretch changes the playback speed of mono PCM without changing its
+// pitch using WSOLA (waveform similarity overlap-add). speed > 1 shortens the
+// audio, speed < 1 lengthens it. It is intended for small adjustments such as
+// 0.9x–1.1x; larger factors work but become audibly artificial.
+func TimeStretch(in []int16, sampleRate int, speed float64) []int16 {
+	if speed <= 0 || speed == 1 || len(in) == 0 {
+		out := make([]int16, len(in))
+		copy(out, in)
+		return out
+	}
+
+	n := sampleRate * stretchFrameMs / 1000
+	hop := n / 2
+	tol := sampleRate * stretchToleranceMs / 1000
+
+	// Pad so every frame, including the search window, stays in bounds
+	padded := make([]float64, len(in)+2*n+tol)
+	for i, s := range in {
+		padded[i] = float64(s)
+	}
+
+	win := make([]float64, n)
+	for i := range win {
+		win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+	}
+
+	outLen := int(float64(len(in)) / speed)
+	acc := make([]float64, outLen+n)
+	norm := make([]float64, outLen+n)
+
+	prev := 0
+	for synth := 0; synth < outLen; synth += hop {
+		start := int(float64(synth) * speed)
+		if synth > 0 {
+			start = bestOverlap(padded, prev+hop, start, hop, tol)
+		}
+		for i := 0; i < n; i++ {
+			acc[synth+i] += padded[start+i] * win[i]
+			norm[synth+i] += win[i]
+		}
+		prev = start
+	}
+
+	out := make([]int16, outLen)
+	for i := range out {
+		if norm[i] > 1e-3 {
+			out[i] = clamp16(acc[i] / norm[i])
+		} else {
+			out[i] = clamp16(acc[i])
+		}
+	}
+	return out
+}
+
+// bestOverlap returns the frame start within ±tol of nominal whose first hop
+// samples best match the natural continuation of the previous frame
+func bestOverlap(in []float64, natural, nominal, hop, tol int) int {
+	best, bestScore := nominal, math.Inf(-1)
+	for c := nominal - tol; c <= nominal+tol; c++ {
+		if c < 0 || c+hop > len(in) || natural+hop > len(in) {
+			continue
+		}
+		score := 0.0
+		for i := 0; i < hop; i++ {
+			score += in[c+i] * in[natural+i]
+		}
+		if score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	return best
+}
@@ -0,0 +1,61 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+// zeroCrossingRate estimates a tone's frequency from sign changes
+func zeroCrossingRate(samples []int16, rate int) float64 {
+	crossings := 0
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1] < 0) != (samples[i] < 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / 2 / (float64(len(samples)) / float64(rate))
+}
+
+func rms(samples []int16) float64 {
+	sum := 0.0
+	for _, s := range samples {
+		sum += float64(s) * float64(s)
+	}
+	return math.Sqrt(sum / float64(len(samples)))
+}
+
+func TestTimeStretchKeepsPitch(t *testing.T) {
+	const rate = 8000
+	in := make([]int16, rate) // one second of 440Hz
+	for i := range in {
+		in[i] = int16(8000 * math.Sin(2*math.Pi*440*float64(i)/rate))
+	}
+
+	for _, speed := range []float64{0.9, 1.1} {
+		out := TimeStretch(in, rate, speed)
+
+		wantLen := int(float64(len(in)) / speed)
+		if len(out) != wantLen {
+			t.Errorf("speed %.1f: got %d samples, want %d", speed, len(out), wantLen)
+		}
+
+		// Skip the edges where frames are only partially overlapped
+		f := zeroCrossingRate(out[400:len(out)-400], rate)
+		if math.Abs(f-440) > 5 {
+			t.Errorf("speed %.1f: tone moved to %.1fHz, want 440Hz", speed, f)
+		}
+
+		// Frames that overlap in phase keep the level steady
+		if r := rms(out[400:len(out)-400]) / rms(in); math.Abs(r-1) > 0.05 {
+			t.Errorf("speed %.1f: level changed by factor %.2f", speed, r)
+		}
+	}
+}
+
+func TestTimeStretchIdentity(t *testing.T) {
+	in := []int16{1, 2, 3}
+	out := TimeStretch(in, 8000, 1)
+	if len(out) != 3 || out[2] != 3 {
+		t.Errorf("speed 1 should copy input, got %v", out)
+	}
+}
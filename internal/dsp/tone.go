@@ -0,0 +1,192 @@
+package dsp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Tone is a signaling tone recognized by ToneDetector
+type Tone string
+
+const (
+	ToneBeep Tone = "beep" // answering machine beep or 1004Hz test tone, reported when it ends
+	ToneFax  Tone = "fax"  // fax calling (CNG, 1100Hz) or answer (CED, 2100Hz) tone
+	ToneSIT  Tone = "sit"  // special information tone: disconnected, vacant or unreachable number
+)
+
+// Tone detection parameters, in 20ms frames
+const (
+	toneFrameMs   = 20
+	toneMinDB     = -45.0 // frames quieter than this are silence
+	tonePurity    = 0.6   // share of frame energy at the target frequency
+	beepMinFrames = 6     // 120ms
+	faxMinFrames  = 20    // 400ms
+	sitMinFrames  = 5     // 100ms per segment; segments are 276 or 380ms
+	toneGapFrames = 1     // mixed frames tolerated at a tone boundary
+)
+
+// toneTarget is a frequency the detector listens for and the role a tone
+// there plays
+type toneTarget struct {
+	freq float64
+	role string
+}
+
+// Targets closer than the 50Hz bin width (985.2 and 1004) are told apart
+// by their role in a sequence rather than by frequency
+var toneTargets = []toneTarget{
+	{913.8, "sit1"}, {985.2, "sit1"}, {1004, "beep"},
+	{1100, "fax"},
+	{1370.6, "sit2"}, {1428.5, "sit2"},
+	{1776.7, "sit3"},
+	{2100, "fax"},
+}
+
+// ToneDetector finds signaling tones in a stream of mono PCM with the
+// Goertzel algorithm, one 20ms frame at a time. Like Resampler it keeps
+// state between calls and is not safe for concurrent use.
+type ToneDetector struct {
+	frameLen int
+	coeffs   []float64 // Goertzel coefficient per target
+	pending  []float64
+
+	role string // role of the current tone, "" for none
+	run  int    // frames of the current tone
+	gap  int    // non-tone frames since it ended
+	sit  int    // SIT segments heard in order (0-2)
+	beep bool   // a beep ended and waits to be told apart from a SIT
+}
+
+// NewToneDetector creates a detector for audio at sampleRate
+func NewToneDetector(sampleRate int) *ToneDetector {
+	if sampleRate <= 0 {
+		sampleRate = 8000
+	}
+	d := &ToneDetector{frameLen: sampleRate * toneFrameMs / 1000}
+	for _, t := range toneTargets {
+		d.coeffs = append(d.coeffs, 2*math.Cos(2*math.Pi*t.freq/float64(sampleRate)))
+	}
+	return d
+}
+
+// Process analyzes samples and returns the tones completed in them
+func (d *ToneDetector) Process(in []int16) []Tone {
+	var tones []Tone
+	for _, s := range in {
+		d.pending = append(d.pending, float64(s))
+		if len(d.pending) < d.frameLen {
+			continue
+		}
+		tones = d.frame(d.pending, tones)
+		d.pending = d.pending[:0]
+	}
+	return tones
+}
+
+// ProcessBytes analyzes 16-bit little-endian PCM
+func (d *ToneDetector) ProcessBytes(in []byte) []Tone {
+	samples := make([]int16, len(in)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(in[i*2:]))
+	}
+	return d.Process(samples)
+}
+
+// frame classifies one frame and advances the tone state
+func (d *ToneDetector) frame(samples []float64, tones []Tone) []Tone {
+	role := d.classify(samples)
+	if role != "" && role == d.role {
+		d.run++
+		d.gap = 0
+		switch {
+		case role == "fax" && d.run == faxMinFrames:
+			tones = append(tones, ToneFax)
+		case role == "sit3" && d.sit == 2 && d.run == sitMinFrames:
+			tones = append(tones, ToneSIT)
+			d.sit, d.beep = 0, false
+		}
+		return tones
+	}
+
+	if role == "" && d.role != "" && d.gap < toneGapFrames {
+		// Possibly the frame straddling two tones
+		d.gap++
+		return tones
+	}
+	d.end()
+	if role != "" && d.beep && role != "sit2" {
+		tones = append(tones, ToneBeep)
+		d.beep = false
+	}
+	d.role, d.run, d.gap = role, 1, 0
+	if role == "" {
+		// Silence after the tone settles a pending beep
+		if d.beep {
+			tones = append(tones, ToneBeep)
+			d.beep = false
+		}
+		d.sit, d.role, d.run = 0, "", 0
+	}
+	return tones
+}
+
+// end closes the current tone, advancing the SIT sequence and holding a
+// beep until the next frame shows whether it opened a SIT
+func (d *ToneDetector) end() {
+	role, run := d.role, d.run
+	switch {
+	case role == "":
+		return
+	case (role == "sit1" || role == "beep") && run >= sitMinFrames:
+		d.sit = 1
+	case role == "sit2" && run >= sitMinFrames && d.sit == 1:
+		d.sit = 2
+		d.beep = false
+	default:
+		d.sit = 0
+	}
+	if (role == "beep" || role == "sit1") && run >= beepMinFrames {
+		d.beep = true
+	}
+}
+
+// classify returns the role of the target tone dominating the frame, or ""
+func (d *ToneDetector) classify(samples []float64) string {
+	var energy float64
+	for _, v := range samples {
+		energy += v * v
+	}
+	n := float64(len(samples))
+	if energy == 0 || toDB(math.Sqrt(energy/n)) < toneMinDB {
+		return ""
+	}
+	best, bestPower := -1, 0.0
+	for i, coeff := range d.coeffs {
+		if p := goertzel(samples, coeff); p > bestPower {
+			best, bestPower = i, p
+		}
+	}
+	// A pure sine at the target frequency puts all its energy in the bin
+	if best < 0 || 2*bestPower/(n*energy) < tonePurity {
+		return ""
+	}
+	return toneTargets[best].role
+}
+
+// goertzel returns the squared magnitude of samples at the frequency whose
+// coefficient is 2cos(2πf/rate)
+func goertzel(samples []float64, coeff float64) float64 {
+	var s1, s2 float64
+	for _, v := range samples {
+		s1, s2 = v+coeff*s1-s2, s1
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
+
+// toDB converts a 16-bit sample RMS to dBFS
+func toDB(rms float64) float64 {
+	if rms <= 0 {
+		return -120
+	}
+	return 20 * math.Log10(rms/32768)
+}
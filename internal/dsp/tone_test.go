@@ -0,0 +1,72 @@
+package dsp
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// tones concatenates sine segments at 8kHz; freq 0 is silence
+func tones(segments ...[2]float64) []int16 {
+	var out []int16
+	for _, seg := range segments {
+		n := int(seg[1] * 8000)
+		if seg[0] == 0 {
+			out = append(out, make([]int16, n)...)
+			continue
+		}
+		out = append(out, sine(seg[0], 8000, n, 8000)...)
+	}
+	return out
+}
+
+func TestGoertzel(t *testing.T) {
+	in := sine(1004, 8000, 160, 8000)
+	samples := make([]float64, len(in))
+	var energy float64
+	for i, s := range in {
+		samples[i] = float64(s)
+		energy += samples[i] * samples[i]
+	}
+	for _, tt := range []struct {
+		freq     float64
+		min, max float64
+	}{
+		{1004, 0.95, 1.05}, // nearly all the energy
+		{2100, 0, 0.01},
+	} {
+		coeff := 2 * math.Cos(2*math.Pi*tt.freq/8000)
+		if share := 2 * goertzel(samples, coeff) / (160 * energy); share < tt.min || share > tt.max {
+			t.Errorf("share of 1004Hz tone at %.0fHz = %.3f", tt.freq, share)
+		}
+	}
+}
+
+func TestToneDetector(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   []int16
+		want []Tone
+	}{
+		{"beep", tones([2]float64{0, 0.5}, [2]float64{1004, 0.4}, [2]float64{0, 0.5}), []Tone{ToneBeep}},
+		{"short blip", tones([2]float64{1004, 0.06}, [2]float64{0, 0.5}), nil},
+		{"fax cng", tones([2]float64{1100, 0.5}, [2]float64{0, 3}, [2]float64{1100, 0.5}), []Tone{ToneFax, ToneFax}},
+		{"fax ced", tones([2]float64{2100, 3}), []Tone{ToneFax}},
+		{"sit", tones([2]float64{913.8, 0.276}, [2]float64{1370.6, 0.276}, [2]float64{1776.7, 0.38}, [2]float64{0, 1}), []Tone{ToneSIT}},
+		{"sit low", tones([2]float64{985.2, 0.38}, [2]float64{1428.5, 0.276}, [2]float64{1776.7, 0.38}, [2]float64{0, 1}), []Tone{ToneSIT}},
+		{"sit out of order", tones([2]float64{1370.6, 0.276}, [2]float64{913.8, 0.276}, [2]float64{1776.7, 0.38}, [2]float64{0, 1}), []Tone{ToneBeep}},
+		{"speech-like", tones([2]float64{150, 0.5}, [2]float64{220, 0.5}), nil},
+	} {
+		d := NewToneDetector(8000)
+		var got []Tone
+		// Odd chunk sizes so tones straddle frame boundaries
+		for in := tt.in; len(in) > 0; {
+			n := min(97, len(in))
+			got = append(got, d.Process(in[:n])...)
+			in = in[n:]
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: tones %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
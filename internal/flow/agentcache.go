@@ -0,0 +1,141 @@
+package flow
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+// callAgentCacheSize bounds the agent users a single call remembers; a call
+// rarely looks up more than its own lead
+const callAgentCacheSize = 4
+
+var (
+	agentLookups   = metrics.NewCounterVec("audiosocket_agent_lookups_total", "Vicidial agent user lookups by result: call_hit, shared_hit or miss", "result")
+	agentEvictions = metrics.NewCounter("audiosocket_agent_cache_evictions_total", "Agent users dropped from the shared cache to make room")
+)
+
+// AgentCache remembers the Vicidial agent user handling each lead, so
+// hangup and transfer requests do not look it up again. It holds at most
+// size leads, dropping the least recently used, and forgets entries older
+// than ttl (0 keeps them). It is safe for concurrent use.
+type AgentCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	order *list.List // of *agentEntry, most recently used first
+	items map[string]*list.Element
+
+	evictions *metrics.Counter // nil for per-call caches
+}
+
+type agentEntry struct {
+	leadID string
+	user   string
+	stored time.Time
+}
+
+// NewAgentCache creates a cache of up to size leads whose entries expire
+// after ttl, 0 for never, to share between calls with SetAgentCache
+func NewAgentCache(size int, ttl time.Duration) *AgentCache {
+	c := newAgentCache(size, ttl)
+	c.evictions = agentEvictions
+	return c
+}
+
+func newAgentCache(size int, ttl time.Duration) *AgentCache {
+	if size <= 0 {
+		size = 1
+	}
+	return &AgentCache{size: size, ttl: ttl, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Get returns the agent user cached for leadID
+func (c *AgentCache) Get(leadID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[leadID]
+	if !ok {
+		return "", false
+	}
+	entry := el.Value.(*agentEntry)
+	if c.ttl > 0 && time.Since(entry.stored) > c.ttl {
+		c.order.Remove(el)
+		delete(c.items, leadID)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return entry.user, true
+}
+
+// Put caches the agent user of leadID
+func (c *AgentCache) Put(leadID, user string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[leadID]; ok {
+		el.Value = &agentEntry{leadID: leadID, user: user, stored: time.Now()}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[leadID] = c.order.PushFront(&agentEntry{leadID: leadID, user: user, stored: time.Now()})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*agentEntry).leadID)
+		if c.evictions != nil {
+			c.evictions.Inc()
+		}
+	}
+}
+
+// Forget drops the agent user cached for leadID, e.g. when the lead is
+// called again and another agent may take it
+func (c *AgentCache) Forget(leadID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[leadID]; ok {
+		c.order.Remove(el)
+		delete(c.items, leadID)
+	}
+}
+
+// Len returns the number of cached leads
+func (c *AgentCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// SetAgentCache shares cache between several clients, so the clients a call
+// uses for hangup, disposition and transfer checks look its lead up once
+func (api *APIClient) SetAgentCache(cache *AgentCache) {
+	api.sharedAgents = cache
+}
+
+// cachedAgentUser returns the agent user of leadID from the call's cache or
+// the shared one
+func (api *APIClient) cachedAgentUser(leadID string) (string, bool) {
+	if user, ok := api.agents.Get(leadID); ok {
+		agentLookups.With("call_hit").Inc()
+		return user, true
+	}
+	if api.sharedAgents != nil {
+		if user, ok := api.sharedAgents.Get(leadID); ok {
+			agentLookups.With("shared_hit").Inc()
+			api.agents.Put(leadID, user)
+			return user, true
+		}
+	}
+	agentLookups.With("miss").Inc()
+	return "", false
+}
+
+// cacheAgentUser remembers a looked up agent user in both caches
+func (api *APIClient) cacheAgentUser(leadID, user string) {
+	api.agents.Put(leadID, user)
+	if api.sharedAgents != nil {
+		api.sharedAgents.Put(leadID, user)
+	}
+}
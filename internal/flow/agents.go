@@ -0,0 +1,21 @@
+package flow
+
+import "log"
+
+// noAgentsTarget returns the node a transfer node's "no_agents" transition
+// leads to when no agent in its in_group is waiting for a call, or nil to go
+// ahead with the transfer. A failed lookup does not hold up the transfer.
+func (fe *FlowEngine) noAgentsTarget(node *FlowNode) *FlowNode {
+	if node.InGroup == "" || node.Transitions["no_agents"] == "" || fe.apiClient == nil {
+		return nil
+	}
+	waiting, err := fe.apiClient.AgentsWaiting(node.InGroup)
+	if err != nil {
+		log.Printf("Warning: failed to check agents in %s, transferring anyway: %v", node.InGroup, err)
+		return nil
+	}
+	if waiting > 0 {
+		return nil
+	}
+	return fe.signalTarget(node, "no_agents")
+}
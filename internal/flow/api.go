@@ -7,9 +7,11 @@ import (
     "net/http"
     "net/url"
     "path"
+    "strconv"
     "strings"
     "time"
 
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
 	redis "github.com/redis/go-redis/v9"
 )
 
@@ -27,14 +29,24 @@ type APIClient struct {
 
     httpClient *http.Client
 
+    // Agent users by lead: this call's, and optionally one shared by calls
+    agents       *AgentCache
+    sharedAgents *AgentCache
+
     // Redis for session-scoped variables
     redis       *redis.Client
     redisPrefix string
 
     // Optional session logger for structured api_call events
     logger *SessionLogger
+
+    // Optional observer of every request's latency, e.g. for load shedding
+    observeLatency func(time.Duration)
 }
 
+// vicidialLatency is the time each Vicidial API request took, by API function
+var vicidialLatency = metrics.NewHistogramVec("flow_vicidial_request_seconds", "Vicidial API request latency by function", nil, "function")
+
 // NewVicidialClient constructs a fully configured API client
 func NewVicidialClient(serverURL, adminDir, apiUser, apiPass, sourceRA, sourceAdmin, transferStatus, transferPhone string) *APIClient {
     return &APIClient{
@@ -47,6 +59,7 @@ func NewVicidialClient(serverURL, adminDir, apiUser, apiPass, sourceRA, sourceAd
         transferStatus: transferStatus,
         transferPhone:  transferPhone,
         httpClient: &http.Client{Timeout: 10 * time.Second},
+        agents:     newAgentCache(callAgentCacheSize, 0),
     }
 }
 
@@ -61,6 +74,11 @@ func (api *APIClient) SetLogger(logger *SessionLogger) {
     api.logger = logger
 }
 
+// SetLatencyObserver calls fn with the latency of every API request
+func (api *APIClient) SetLatencyObserver(fn func(time.Duration)) {
+    api.observeLatency = fn
+}
+
 func (api *APIClient) getVar(ctx context.Context, sessionID, key string) (string, error) {
     if api.redis == nil {
         return "", fmt.Errorf("redis client not configured")
@@ -113,6 +131,10 @@ func (api *APIClient) UpdateRaCallControlBySession(sessionID, stage, status, pho
     start := time.Now()
     code, body, reqErr := api.makeRequest(fullURL, params)
     dur := time.Since(start).Milliseconds()
+    if reqErr == nil && strings.HasPrefix(strings.TrimSpace(body), "ERROR") {
+        // Vicidial reports refused calls with HTTP 200
+        reqErr = fmt.Errorf("ra_call_control: %s", strings.TrimSpace(body))
+    }
     if api.logger != nil {
         details := map[string]string{
             "lead_id":     leadID,
@@ -174,6 +196,95 @@ func (api *APIClient) UpdateLeadStatusBySession(sessionID, status string) error
     return reqErr
 }
 
+// ScheduleCallbackBySession books a callback for the session's lead at when,
+// in the server's time, for any agent to take. Non-empty comments are kept
+// with the callback, leaving the lead's own comments as they are.
+func (api *APIClient) ScheduleCallbackBySession(sessionID, status string, when time.Time, comments string) error {
+    ctx, cancel := context.WithTimeout(context.Background(), 800*time.Millisecond)
+    defer cancel()
+    leadID, err := api.getVar(ctx, sessionID, "lead_id")
+    if err != nil {
+        return err
+    }
+    fullURL := api.serverURL + "/" + path.Join(api.adminDir, "non_agent_api.php")
+    params := map[string]string{
+        "source":            api.sourceAdmin,
+        "user":              api.apiUser,
+        "pass":              api.apiPass,
+        "function":          "update_lead",
+        "lead_id":           leadID,
+        "callback":          "Y",
+        "callback_status":   status,
+        "callback_datetime": when.Format("2006-01-02 15:04:05"),
+        "callback_type":     "ANYONE",
+    }
+    if comments != "" {
+        params["callback_comments"] = comments
+    }
+    start := time.Now()
+    code, body, reqErr := api.makeRequest(fullURL, params)
+    dur := time.Since(start).Milliseconds()
+    if api.logger != nil {
+        details := map[string]string{
+            "lead_id":           leadID,
+            "vd_status":         status,
+            "callback_datetime": params["callback_datetime"],
+            "http_status":       fmt.Sprintf("%d", code),
+            "duration_ms":       fmt.Sprintf("%d", dur),
+        }
+        if resp := strings.TrimSpace(body); resp != "" {
+            if len(resp) > 200 {
+                resp = resp[:200] + "…"
+            }
+            details["response"] = resp
+        }
+        api.logger.LogAPICallDetails(sessionID, "vicidial:schedule_callback", map[bool]string{true: "ok", false: "error"}[reqErr == nil], details)
+    }
+    return reqErr
+}
+
+// UpdateLeadScore writes a lead score to the lead's custom field and, with
+// rank, to its rank as well
+func (api *APIClient) UpdateLeadScore(sessionID, leadID, field string, score int, rank bool) error {
+    if strings.TrimSpace(leadID) == "" {
+        return fmt.Errorf("leadID is empty")
+    }
+    fullURL := api.serverURL + "/" + path.Join(api.adminDir, "non_agent_api.php")
+    params := map[string]string{
+        "source":   api.sourceAdmin,
+        "user":     api.apiUser,
+        "pass":     api.apiPass,
+        "function": "update_lead",
+        "lead_id":  leadID,
+    }
+    if field != "" {
+        params["custom_fields"] = "Y"
+        params[field] = fmt.Sprint(score)
+    }
+    if rank {
+        params["rank"] = fmt.Sprint(LeadRank(score))
+    }
+    start := time.Now()
+    code, body, reqErr := api.makeRequest(fullURL, params)
+    dur := time.Since(start).Milliseconds()
+    if api.logger != nil {
+        details := map[string]string{
+            "lead_id":     leadID,
+            "score":       fmt.Sprint(score),
+            "http_status": fmt.Sprintf("%d", code),
+            "duration_ms": fmt.Sprintf("%d", dur),
+        }
+        if resp := strings.TrimSpace(body); resp != "" {
+            if len(resp) > 200 {
+                resp = resp[:200] + "…"
+            }
+            details["response"] = resp
+        }
+        api.logger.LogAPICallDetails(sessionID, "vicidial:update_lead_score", map[bool]string{true: "ok", false: "error"}[reqErr == nil], details)
+    }
+    return reqErr
+}
+
 func (api *APIClient) UpdateLogEntryBySession(sessionID, status string) error {
     ctx, cancel := context.WithTimeout(context.Background(), 800*time.Millisecond)
     defer cancel()
@@ -230,6 +341,19 @@ func (api *APIClient) SetVicidialConfig(serverURL, adminDir, apiUser, apiPass, s
     api.transferPhone = transferPhone
 }
 
+// get sends a GET request for an API function and records its latency;
+// failed requests count too, as a timeout is the slowest answer of all
+func (api *APIClient) get(function, rawURL string) (*http.Response, error) {
+    start := time.Now()
+    resp, err := api.httpClient.Get(rawURL)
+    latency := time.Since(start)
+    vicidialLatency.With(function).Observe(latency.Seconds())
+    if api.observeLatency != nil {
+        api.observeLatency(latency)
+    }
+    return resp, err
+}
+
 // makeRequest performs a GET request to a full URL with params and returns HTTP status and body
 func (api *APIClient) makeRequest(fullURL string, params map[string]string) (int, string, error) {
     u, err := url.Parse(fullURL)
@@ -242,8 +366,7 @@ func (api *APIClient) makeRequest(fullURL string, params map[string]string) (int
     }
     u.RawQuery = q.Encode()
 
-    start := time.Now()
-    resp, err := api.httpClient.Get(u.String())
+    resp, err := api.get(params["function"], u.String())
     if err != nil {
         return 0, "", fmt.Errorf("request failed: %w", err)
     }
@@ -253,7 +376,6 @@ func (api *APIClient) makeRequest(fullURL string, params map[string]string) (int
         // prefer original HTTP status for logging even if read failed
         return resp.StatusCode, "", fmt.Errorf("read body: %w", rerr)
     }
-    _ = start // reserved for future latency metrics if needed here
     if resp.StatusCode != http.StatusOK {
         return resp.StatusCode, string(body), fmt.Errorf("unexpected status: %d", resp.StatusCode)
     }
@@ -312,8 +434,83 @@ func (api *APIClient) UpdateLogEntry(campaignID, callID, status string) error {
 }
 
 // GetAgentUserByLead queries Vicidial for the agent (user) handling a lead
-// Equivalent to the Python get_agent_user_info(lead_id)
+// Equivalent to the Python get_agent_user_info(lead_id). Users found are
+// cached for the rest of the call (see agentcache.go).
 func (api *APIClient) GetAgentUserByLead(leadID string) (string, error) {
+    if user, ok := api.cachedAgentUser(leadID); ok {
+        return user, nil
+    }
+    user, err := api.leadFieldInfo(leadID, "user")
+    if err == nil && user != "" && !strings.HasPrefix(user, "ERROR") {
+        api.cacheAgentUser(leadID, user)
+    }
+    return user, err
+}
+
+// GetLeadStatus queries Vicidial for the current status of a lead
+func (api *APIClient) GetLeadStatus(leadID string) (string, error) {
+    status, err := api.leadFieldInfo(leadID, "status")
+    if err != nil {
+        return "", err
+    }
+    if strings.HasPrefix(status, "ERROR") {
+        return "", fmt.Errorf("lead_field_info: %s", status)
+    }
+    return status, nil
+}
+
+// GetLeadPhone queries Vicidial for the phone number of a lead
+func (api *APIClient) GetLeadPhone(leadID string) (string, error) {
+    phone, err := api.leadFieldInfo(leadID, "phone_number")
+    if err != nil {
+        return "", err
+    }
+    if strings.HasPrefix(phone, "ERROR") {
+        return "", fmt.Errorf("lead_field_info: %s", phone)
+    }
+    return phone, nil
+}
+
+// AgentsWaiting queries Vicidial for the number of agents in an in-group
+// who are ready for a call
+// -> {SERVER_URL}/{ADMIN_DIR}/non_agent_api.php?function=in_group_status
+func (api *APIClient) AgentsWaiting(inGroup string) (int, error) {
+    fullURL := api.serverURL + "/" + path.Join(api.adminDir, "non_agent_api.php")
+    params := map[string]string{
+        "source":    api.sourceAdmin,
+        "user":      api.apiUser,
+        "pass":      api.apiPass,
+        "function":  "in_group_status",
+        "in_groups": inGroup,
+        "header":    "YES",
+    }
+    _, body, err := api.makeRequest(fullURL, params)
+    if err != nil {
+        return 0, err
+    }
+    // A header line naming the columns, then one line per in-group:
+    // ingroup|total_calls|calls_waiting|agents_logged_in|...|agents_waiting|...
+    lines := strings.Split(strings.TrimSpace(body), "\n")
+    if len(lines) < 2 || strings.HasPrefix(lines[0], "ERROR") {
+        return 0, fmt.Errorf("in_group_status: %s", strings.TrimSpace(body))
+    }
+    header := strings.Split(strings.TrimSpace(lines[0]), "|")
+    for _, line := range lines[1:] {
+        fields := strings.Split(strings.TrimSpace(line), "|")
+        if fields[0] != inGroup {
+            continue
+        }
+        for i, name := range header {
+            if name == "agents_waiting" && i < len(fields) {
+                return strconv.Atoi(fields[i])
+            }
+        }
+    }
+    return 0, fmt.Errorf("in_group_status: no agents_waiting for %s", inGroup)
+}
+
+// leadFieldInfo -> {SERVER_URL}/{ADMIN_DIR}/non_agent_api.php?function=lead_field_info
+func (api *APIClient) leadFieldInfo(leadID, field string) (string, error) {
     if strings.TrimSpace(leadID) == "" {
         return "", fmt.Errorf("leadID is empty")
     }
@@ -328,12 +525,12 @@ func (api *APIClient) GetAgentUserByLead(leadID string) (string, error) {
     q.Set("pass", api.apiPass)
     q.Set("function", "lead_field_info")
     q.Set("lead_id", leadID)
-    q.Set("field_name", "user")
+    q.Set("field_name", field)
     q.Set("custom_fields", "N")
     q.Set("archived_lead", "N")
     u.RawQuery = q.Encode()
 
-    resp, err := api.httpClient.Get(u.String())
+    resp, err := api.get("lead_field_info", u.String())
     if err != nil {
         return "", fmt.Errorf("request failed: %w", err)
     }
@@ -348,6 +545,33 @@ func (api *APIClient) GetAgentUserByLead(leadID string) (string, error) {
     return strings.TrimSpace(string(body)), nil
 }
 
+// CheckCredentials verifies the server is reachable and accepts the API user.
+// It issues a read-only lead_field_info lookup for a lead that does not
+// exist: Vicidial authenticates the user before looking up the lead.
+func (api *APIClient) CheckCredentials() error {
+    if api.serverURL == "" {
+        return fmt.Errorf("server URL is empty")
+    }
+    fullURL := api.serverURL + "/" + path.Join(api.adminDir, "non_agent_api.php")
+    params := map[string]string{
+        "source":     api.sourceAdmin,
+        "user":       api.apiUser,
+        "pass":       api.apiPass,
+        "function":   "lead_field_info",
+        "lead_id":    "0",
+        "field_name": "status",
+    }
+    _, body, err := api.makeRequest(fullURL, params)
+    if err != nil {
+        return err
+    }
+    body = strings.TrimSpace(body)
+    if strings.Contains(body, "Invalid Username/Password") || strings.Contains(body, "PERMISSION") {
+        return fmt.Errorf("credentials rejected: %s", body)
+    }
+    return nil
+}
+
 // Helpers to expose configured transfer params
 func (api *APIClient) TransferStatus() string { return api.transferStatus }
 func (api *APIClient) TransferPhone() string  { return api.transferPhone }
@@ -0,0 +1,24 @@
+package flow
+
+import "log"
+
+// BackgroundVar is the session variable holding the caller's classified
+// environment ("quiet", "car", "crowd", "tv" or "noisy")
+const BackgroundVar = "background"
+
+// backgroundTransition returns the node's "background:<environment>"
+// transition for the caller's environment and its key, or "" when the
+// environment is not known yet or the node does not branch on it
+func (fe *FlowEngine) backgroundTransition(node *FlowNode) (string, string) {
+	env, ok := fe.session.GetVar(BackgroundVar)
+	if !ok || env == "" {
+		return "", ""
+	}
+	key := "background:" + env
+	nextNodeID := node.Transitions[key]
+	if nextNodeID == "" {
+		return "", ""
+	}
+	log.Printf("Flow transition: %s -> %s | Background: %s", node.ID, nextNodeID, env)
+	return nextNodeID, key
+}
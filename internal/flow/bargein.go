@@ -0,0 +1,18 @@
+package flow
+
+// BargeInSession is implemented by sessions that hear the caller start
+// speaking over a prompt. PlayAudioBargeIn plays filename like PlayAudio but
+// stops it, and any prompt queued behind it, as soon as the caller speaks,
+// without waiting for a transcript.
+type BargeInSession interface {
+	PlayAudioBargeIn(filename string) error
+}
+
+// bargeIn reports whether caller speech should cut node's prompt short: the
+// node's barge_in setting, or else the barge_in feature flag
+func (fe *FlowEngine) bargeIn(node *FlowNode) bool {
+	if node.BargeIn != nil {
+		return *node.BargeIn
+	}
+	return fe.featureEnabled(FeatureBargeIn)
+}
@@ -0,0 +1,27 @@
+package flow
+
+import (
+	"log"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var nodesOverBudget = metrics.NewCounterVec("flow_node_over_budget_total", "Node executions that took longer than the node's budget_ms", "flow", "version", "campaign", "node")
+
+// checkBudget reports a node that took longer than its budget_ms, such as a
+// question left in silence or a transfer waiting on a slow API
+func (fe *FlowEngine) checkBudget(node *FlowNode, elapsed time.Duration) {
+	if node.BudgetMs <= 0 {
+		return
+	}
+	budget := time.Duration(node.BudgetMs) * time.Millisecond
+	if elapsed <= budget {
+		return
+	}
+	log.Printf("Session %s: node %s took %v, over its %v budget", fe.session.GetID(), node.ID, elapsed.Round(time.Millisecond), budget)
+	nodesOverBudget.With(fe.labelValues(node.ID)...).Inc()
+	if fe.logger != nil {
+		fe.logger.LogOverBudget(fe.session.GetID(), node, elapsed, budget)
+	}
+}
@@ -0,0 +1,161 @@
+package flow
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultBookingDuration is how long a callback slot booked in a calendar
+// lasts when the calendar sets none
+const DefaultBookingDuration = 15 * time.Minute
+
+// Booking is a confirmed callback to put in a calendar
+type Booking struct {
+	SessionID string
+	LeadID    string
+	Phone     string
+	Start     time.Time
+	Status    string // disposition of the call, e.g. CALLBK
+}
+
+// Calendar books confirmed callbacks outside Vicidial, e.g. in the calendar
+// the agents work from. Book returns the ID of the event it created.
+type Calendar interface {
+	Book(b Booking) (eventID string, err error)
+}
+
+// SetCalendar sets the calendar confirmed callbacks are booked in
+func (fe *FlowEngine) SetCalendar(cal Calendar) {
+	fe.calendar = cal
+}
+
+// ICalendar books callbacks by sending an iCalendar event over HTTP. A URL
+// ending in "/" is taken as a CalDAV collection (Nextcloud, Fastmail, Google
+// Calendar's CalDAV endpoint, ...) and the event is PUT as <uid>.ics in it;
+// any other URL is a webhook the event is POSTed to. A webhook may answer
+// with JSON {"id": "..."} to name the event; otherwise its ID is the UID.
+type ICalendar struct {
+	URL           string
+	Authorization string        // Authorization header, e.g. "Bearer <token>"; empty for none
+	Duration      time.Duration // length of the booked slot, DefaultBookingDuration if 0
+	Client        *http.Client
+}
+
+// NewICalendar creates an ICalendar booking into url
+func NewICalendar(url, authorization string, duration time.Duration) *ICalendar {
+	return &ICalendar{
+		URL:           url,
+		Authorization: authorization,
+		Duration:      duration,
+		Client:        &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Book sends the booking as a VEVENT and returns the event's ID
+func (c *ICalendar) Book(b Booking) (string, error) {
+	uid := uuid.New().String()
+	method, url := http.MethodPost, c.URL
+	if strings.HasSuffix(url, "/") {
+		method, url = http.MethodPut, url+uid+".ics"
+	}
+	req, err := http.NewRequest(method, url, strings.NewReader(c.event(uid, b)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create calendar request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/calendar; charset=utf-8")
+	if c.Authorization != "" {
+		req.Header.Set("Authorization", c.Authorization)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to book callback: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("failed to book callback: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(body, &created) == nil && created.ID != "" {
+		return created.ID, nil
+	}
+	return uid, nil
+}
+
+// event renders the booking as an iCalendar object with one VEVENT
+func (c *ICalendar) event(uid string, b Booking) string {
+	duration := c.Duration
+	if duration <= 0 {
+		duration = DefaultBookingDuration
+	}
+	const stamp = "20060102T150405Z"
+	who := b.Phone
+	if who == "" {
+		who = "lead " + b.LeadID
+	}
+	description := fmt.Sprintf("Lead %s, phone %s, status %s, session %s", b.LeadID, b.Phone, b.Status, b.SessionID)
+
+	var buf bytes.Buffer
+	for _, line := range []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//audiosocket-transcriber//callback//EN",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"DTSTAMP:" + time.Now().UTC().Format(stamp),
+		"DTSTART:" + b.Start.UTC().Format(stamp),
+		"DTEND:" + b.Start.Add(duration).UTC().Format(stamp),
+		"SUMMARY:" + icalText("Callback "+who),
+		"DESCRIPTION:" + icalText(description),
+		"END:VEVENT",
+		"END:VCALENDAR",
+	} {
+		buf.WriteString(line + "\r\n")
+	}
+	return buf.String()
+}
+
+// icalText escapes a TEXT value
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// bookCallback books a confirmed callback in the calendar, if one is set,
+// and returns the event ID, "" if none was created
+func (fe *FlowEngine) bookCallback(status string, when time.Time) string {
+	if fe.calendar == nil {
+		return ""
+	}
+	leadID := fe.startLeadID
+	if leadID == "" {
+		leadID, _ = fe.session.GetVar("lead_id")
+	}
+	eventID, err := fe.calendar.Book(Booking{
+		SessionID: fe.session.GetID(),
+		LeadID:    leadID,
+		Phone:     fe.startPhone,
+		Start:     when,
+		Status:    status,
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to book callback in calendar: %v", err)
+		return ""
+	}
+	log.Printf("Session %s: Callback booked in calendar as %s", fe.session.GetID(), eventID)
+	return eventID
+}
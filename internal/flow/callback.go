@@ -0,0 +1,413 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/spoken"
+)
+
+// Defaults for schedule_callback nodes
+const (
+	DefaultCallbackVariable = "callback_time"
+	DefaultCallbackStatus   = "CALLBK"
+	DefaultCallbackHour     = 10 // when the caller names a day but no time
+	DefaultEveningHour      = 18 // when the caller names an evening but no time
+)
+
+// Outcomes of a schedule_callback node, used as its transition keys
+const (
+	CallbackConfirmed = "confirmed" // the caller agreed to the time read back
+	CallbackRejected  = "rejected"  // the caller declined a callback
+	CallbackUnclear   = "unclear"   // no time could be made out, or the answer to the read-back was neither yes nor no
+	CallbackTimeout   = "timeout"   // no answer before the response timeout
+)
+
+// CallbackSettings configures a schedule_callback node
+type CallbackSettings struct {
+	ReadbackAudio string `json:"readback_audio,omitempty"` // played before the time, e.g. "so I'll have someone call you"
+	ConfirmAudio  string `json:"confirm_audio,omitempty"`  // played after it, e.g. "is that right?"
+	SoundsDir     string `json:"sounds_dir,omitempty"`     // number and date prompts, relative to the audio directory, default DefaultSoundsDir
+	Timezone      string `json:"timezone,omitempty"`       // IANA zone the caller's times are in, default the server's
+	Variable      string `json:"variable,omitempty"`       // session variable for the time, default callback_time
+	Status        string `json:"status,omitempty"`         // disposition of the call, default CALLBK
+}
+
+// callbackSettings returns the node's callback settings with defaults applied
+func (n *FlowNode) callbackSettings() CallbackSettings {
+	var s CallbackSettings
+	if n.Callback != nil {
+		s = *n.Callback
+	}
+	if s.Variable == "" {
+		s.Variable = DefaultCallbackVariable
+	}
+	if s.Status == "" {
+		s.Status = DefaultCallbackStatus
+	}
+	return s
+}
+
+// location returns the caller's time zone
+func (s CallbackSettings) location() *time.Location {
+	if loc, err := time.LoadLocation(s.Timezone); err == nil && s.Timezone != "" {
+		return loc
+	}
+	return time.Local
+}
+
+// validate checks the callback settings of a node
+func (s *CallbackSettings) validate() error {
+	if s.Timezone == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("callback timezone: %w", err)
+	}
+	return nil
+}
+
+// handleScheduleCallbackNode asks when to call back, reads the time the
+// caller names back to them with the number and date prompts and waits for
+// a yes. A different time is read back again. The confirmed time is stored
+// in the node's session variable, scheduled with Vicidial and the call is
+// dispositioned with the node's status.
+func (fe *FlowEngine) handleScheduleCallbackNode(node *FlowNode) error {
+	settings := node.callbackSettings()
+	loc := settings.location()
+	log.Printf("Scheduling callback: %s - %s", node.AudioFile, node.Content)
+
+	play := fe.startAudio(fe.promptFile(node), false)
+	go func() {
+		if err := play(); err != nil {
+			log.Printf("Failed to play audio: %v", err)
+		}
+	}()
+
+	fe.waitingFor = node
+	fe.timer.Start()
+	transcriptionChan := fe.session.GetTranscriptionResults()
+
+	var when time.Time // the time read back, zero until one is heard
+	stopReadback := func() {}
+	outcome := ""
+	for outcome == "" {
+		select {
+		case <-fe.timer.GetTimeoutChan():
+			log.Printf("CALLBACK - Question: %s | Answer: [TIMEOUT] | Node: %s", node.Content, node.ID)
+			outcome = CallbackTimeout
+
+		case result, ok := <-transcriptionChan:
+			if !ok {
+				transcriptionChan = nil
+				continue
+			}
+			if fe.skipped(result) {
+				continue
+			}
+			if !result.IsFinal {
+				fe.partialHeard(node, result.Text)
+				continue
+			}
+			if fe.interrupted(node, result.Text) {
+				stopReadback()
+				return nil
+			}
+
+			now := time.Now().In(loc)
+			heard, ok := ParseCallbackTime(result.Text, now)
+			switch {
+			case ok && heard.Equal(when):
+				// The caller repeated the time read back
+				outcome = CallbackConfirmed
+			case ok:
+				// A time, or a different one: read it back
+				when = heard
+				log.Printf("CALLBACK - Question: %s | Answer: %s | Time: %s | Node: %s", node.Content, result.Text, when.Format(time.RFC3339), node.ID)
+				stopReadback()
+				stopReadback = fe.playSequence(fe.readback(settings, when, now))
+				fe.timer.Stop()
+				fe.timer.Start()
+			default:
+				switch fe.classify(node, result.Text) {
+				case ResponsePositive:
+					outcome = CallbackConfirmed
+					if when.IsZero() {
+						outcome = CallbackUnclear // yes to what?
+					}
+				case ResponseNegative:
+					outcome = CallbackRejected
+				default:
+					outcome = CallbackUnclear
+				}
+			}
+		}
+	}
+	stopReadback()
+	return fe.finishCallback(node, settings, when, outcome)
+}
+
+// readback returns the prompts reading when back to the caller
+func (fe *FlowEngine) readback(settings CallbackSettings, when, now time.Time) []string {
+	var files []string
+	if settings.ReadbackAudio != "" {
+		files = append(files, settings.ReadbackAudio)
+	}
+	files = append(files, Sayer{Dir: settings.SoundsDir}.SayDateTime(when, now)...)
+	if settings.ConfirmAudio != "" {
+		files = append(files, settings.ConfirmAudio)
+	}
+	return files
+}
+
+// playSequence plays files one after another in the background; the
+// returned function stops the sequence before its next file
+func (fe *FlowEngine) playSequence(files []string) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		for _, file := range files {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := fe.playAudio(file); err != nil {
+				log.Printf("Failed to play audio: %v", err)
+			}
+		}
+	}()
+	var once bool
+	return func() {
+		if !once {
+			once = true
+			close(done)
+		}
+	}
+}
+
+// finishCallback records the node's outcome, schedules a confirmed callback
+// in Vicidial and the calendar and follows the transition for outcome
+func (fe *FlowEngine) finishCallback(node *FlowNode, settings CallbackSettings, when time.Time, outcome string) error {
+	fe.timer.Stop()
+	shown, eventID := "", ""
+	if !when.IsZero() {
+		shown = when.Format(time.RFC3339)
+	}
+	if outcome == CallbackConfirmed {
+		fe.session.SetVar(settings.Variable, shown)
+		fe.lastReason.Store(settings.Status)
+		eventID = fe.bookCallback(settings.Status, when)
+		if fe.apiClient != nil {
+			comments := ""
+			if eventID != "" {
+				comments = "Callback calendar event " + eventID
+			}
+			// Vicidial schedules callbacks in the server's time
+			if err := fe.apiClient.ScheduleCallbackBySession(fe.session.GetID(), settings.Status, when.In(time.Local), comments); err != nil {
+				log.Printf("Warning: Failed to schedule callback: %v", err)
+			}
+		}
+	}
+	log.Printf("CALLBACK - Question: %s | Time: %s | Result: %s | Node: %s", node.Content, shown, outcome, node.ID)
+	if fe.logger != nil {
+		fe.logger.LogCallback(fe.session.GetID(), node, shown, outcome, eventID)
+	}
+	return fe.followOutcome(node, outcome)
+}
+
+// clockTime matches times written as numerals: "3", "3pm", "3:30", "15:00"
+var clockTime = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// ParseCallbackTime finds the callback time in what the caller said, as
+// heard at now: a day ("today", "tomorrow", "the day after tomorrow", a
+// weekday) and a time of day ("3", "3:30pm", "three thirty", "half past
+// four", "quarter to five", "noon", "in the morning"), or a delay ("in two
+// hours"). Hours from 1 to 7 without am or pm are taken as afternoon, a day
+// without a time as DefaultCallbackHour and a time already past today as
+// tomorrow; "tonight" or "tomorrow evening" alone is DefaultEveningHour.
+// It reports false if no time was named or the one named has
+// passed.
+func ParseCallbackTime(text string, now time.Time) (time.Time, bool) {
+	tokens := callbackTokens(text)
+	day, hour, minute, meridiem := -1, -1, 0, ""
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok == "in":
+			n, used := spokenCount(tokens[i+1:])
+			if n == 0 || i+1+used >= len(tokens) {
+				continue
+			}
+			switch unit := tokens[i+1+used]; {
+			case strings.HasPrefix(unit, "hour"):
+				return now.Add(time.Duration(n) * time.Hour).Truncate(time.Minute), true
+			case strings.HasPrefix(unit, "minute"):
+				return now.Add(time.Duration(n) * time.Minute).Truncate(time.Minute), true
+			}
+		case tok == "today":
+			day = 0
+		case tok == "tonight":
+			day, meridiem = 0, "pm"
+		case tok == "tomorrow":
+			day = 1
+			if i >= 2 && tokens[i-2] == "day" && tokens[i-1] == "after" {
+				day = 2
+			}
+		case tok == "noon" || tok == "midday":
+			hour, minute, meridiem = 12, 0, "pm"
+		case tok == "am" || tok == "morning":
+			meridiem = "am"
+		case tok == "pm" || tok == "afternoon" || tok == "evening" || tok == "night":
+			meridiem = "pm"
+		case (tok == "half" || tok == "quarter") && i+2 < len(tokens) && spokenHour(tokens[i+2]) > 0:
+			h, m := spokenHour(tokens[i+2]), 30
+			if tok == "quarter" {
+				m = 15
+			}
+			switch tokens[i+1] {
+			case "past", "after":
+			case "to", "till", "of":
+				h, m = h-1, 60-m
+				if h == 0 {
+					h = 12
+				}
+			default:
+				continue
+			}
+			hour, minute = h, m
+			i += 2
+		default:
+			if wd, ok := weekdays[tok]; ok {
+				day = (int(wd) - int(now.Weekday()) + 7) % 7
+				if day == 0 {
+					day = 7 // "Monday" said on a Monday is next week's
+				}
+			} else if m := clockTime.FindStringSubmatch(tok); m != nil && hour < 0 {
+				hour, _ = strconv.Atoi(m[1])
+				minute = 0
+				if m[2] != "" {
+					minute, _ = strconv.Atoi(m[2])
+				}
+				if m[3] != "" {
+					meridiem = m[3]
+				}
+			} else if h := spokenHour(tok); h > 0 && hour < 0 {
+				hour, minute = h, 0
+				if n, used := spokenMinutes(tokens[i+1:]); used > 0 {
+					minute = n
+					i += used
+				}
+			}
+		}
+	}
+	if hour < 0 && day < 0 {
+		return time.Time{}, false
+	}
+	if hour < 0 {
+		hour, minute = DefaultCallbackHour, 0
+		if meridiem == "pm" {
+			hour, meridiem = DefaultEveningHour, "" // "tomorrow evening"
+		}
+	}
+	switch {
+	case hour > 23 || minute > 59:
+		return time.Time{}, false
+	case meridiem == "pm" && hour < 12:
+		hour += 12
+	case meridiem == "am" && hour == 12:
+		hour = 0
+	case meridiem == "" && hour >= 1 && hour <= 7:
+		hour += 12 // nobody asks for a call at 3 in the morning
+	}
+
+	offset := day
+	if offset < 0 {
+		offset = 0
+	}
+	when := time.Date(now.Year(), now.Month(), now.Day()+offset, hour, minute, 0, 0, now.Location())
+	if !when.After(now) {
+		if day >= 0 {
+			return time.Time{}, false
+		}
+		when = when.AddDate(0, 0, 1)
+	}
+	return when, true
+}
+
+// callbackTokens splits text into lower case words, with "o'clock", "a.m."
+// and "p.m." written as single words
+func callbackTokens(text string) []string {
+	text = strings.NewReplacer("o'clock", "oclock", "a.m.", "am", "p.m.", "pm").Replace(strings.ToLower(text))
+	tokens := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '-' || r == '\t'
+	})
+	for i, tok := range tokens {
+		tokens[i] = strings.Trim(tok, ".?!;\"'()")
+	}
+	return tokens
+}
+
+// spokenHour returns the hour a word or numeral names, 0 if none
+func spokenHour(tok string) int {
+	h, err := strconv.Atoi(tok)
+	if err != nil {
+		_, h = spoken.Kind(tok)
+	}
+	if h >= 1 && h <= 12 {
+		return h
+	}
+	return 0
+}
+
+// spokenMinutes reads the minutes following a spoken hour: "oclock",
+// "oh five", "fifteen", "forty five". It returns them and the number of
+// tokens used.
+func spokenMinutes(tokens []string) (int, int) {
+	if len(tokens) == 0 {
+		return 0, 0
+	}
+	switch tok := tokens[0]; {
+	case tok == "oclock":
+		return 0, 1
+	case zeroWords[tok] && len(tokens) > 1:
+		if m, ok := spoken.Digit(tokens[1]); ok {
+			return m, 2
+		}
+	default:
+		// A lone digit after the hour is not minutes ("three five")
+		if kind, _ := spoken.Kind(tok); kind == "teen" || kind == "ten" {
+			if n, used := spoken.Parse(tokens); n.Value() < 60 {
+				return n.Value(), used
+			}
+		}
+	}
+	return 0, 0
+}
+
+// spokenCount reads the number at the start of tokens in "in two hours" or
+// "in forty five minutes". It returns it and the number of tokens used, 0
+// if there is none.
+func spokenCount(tokens []string) (int, int) {
+	if len(tokens) == 0 {
+		return 0, 0
+	}
+	if tokens[0] == "a" || tokens[0] == "an" {
+		return 1, 1
+	}
+	if n, err := strconv.Atoi(tokens[0]); err == nil && n > 0 {
+		return n, 1
+	}
+	if n, used := spoken.Parse(tokens); n.Value() > 0 {
+		return n.Value(), used
+	}
+	return 0, 0
+}
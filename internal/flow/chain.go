@@ -0,0 +1,127 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+)
+
+// maxChainedFlows bounds the follow-on flows one call runs, so flows that
+// chain to each other cannot loop forever
+const maxChainedFlows = 8
+
+// chainedFlow is a flow a "flow" node hands the call to
+type chainedFlow struct {
+	config *FlowConfig
+	dir    string // directory of the flow file, to resolve its assets
+}
+
+// chainPath resolves the flow file of a "flow" node in a flow from dir
+func chainPath(dir, file string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return filepath.Clean(file)
+}
+
+// loadChain adds the flows config's "flow" nodes hand the call to, and the
+// flows those chain to, to chain by path. Flows already in chain are not
+// loaded again, so flows may chain back to each other.
+func loadChain(config *FlowConfig, dir string, chain map[string]*chainedFlow) error {
+	for _, node := range config.Nodes {
+		if node.Type != "flow" {
+			continue
+		}
+		path := chainPath(dir, node.Flow)
+		if chain[path] != nil {
+			continue
+		}
+		next, err := loadFlowConfig(path)
+		if err != nil {
+			return fmt.Errorf("node %s: chained flow %s: %w", node.ID, node.Flow, err)
+		}
+		if !hasNode(next, "start") {
+			return fmt.Errorf("node %s: chained flow %s has no start node", node.ID, node.Flow)
+		}
+		chain[path] = &chainedFlow{config: next, dir: filepath.Dir(path)}
+		if err := loadChain(next, filepath.Dir(path), chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasNode(config *FlowConfig, id string) bool {
+	for _, node := range config.Nodes {
+		if node.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// chainedNodes returns the nodes of the flows the engine's flow chains to
+func (fe *FlowEngine) chainedNodes() []FlowNode {
+	paths := make([]string, 0, len(fe.chain))
+	for path, cf := range fe.chain {
+		if cf.config != fe.config {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	var nodes []FlowNode
+	for _, path := range paths {
+		nodes = append(nodes, fe.chain[path].config.Nodes...)
+	}
+	return nodes
+}
+
+// handleFlowNode hands the call to the follow-on flow the node names, e.g.
+// a verification flow only qualified leads reach before the transfer. The
+// follow-on flow starts at its start node and runs with the same session:
+// variables, disposition, session log and Vicidial client carry over.
+func (fe *FlowEngine) handleFlowNode(node *FlowNode) error {
+	path := chainPath(fe.configDir, node.Flow)
+	next := fe.chain[path]
+	if next == nil {
+		return fmt.Errorf("chained flow %s not loaded", node.Flow)
+	}
+	if fe.chained >= maxChainedFlows {
+		return fmt.Errorf("more than %d chained flows", maxChainedFlows)
+	}
+	fe.chained++
+
+	// Play the hand-over prompt (if specified)
+	if node.AudioFile != "" {
+		if err := fe.playAudio(fe.promptFile(node)); err != nil {
+			return fmt.Errorf("failed to play audio: %w", err)
+		}
+	}
+
+	meta := next.config.Metadata
+	log.Printf("Flow %s chained to %s (%s) at node %s for session %s",
+		fe.config.Metadata.Name, meta.Name, node.Flow, node.ID, fe.session.GetID())
+	// The node's metrics keep the labels of the flow it belongs to
+	fe.exitNode()
+	if fe.logger != nil {
+		fe.logger.LogFlowChain(fe.session.GetID(), node, node.Flow, meta.Name, meta.Version)
+	}
+
+	fe.config = next.config
+	fe.configDir = next.dir
+	fe.labels.Flow = meta.Name
+	fe.labels.Version = meta.Version
+	// Answers in chained flows are scored as "<flow file>:<node>"
+	fe.scorePrefix = ""
+	if path != fe.rootFlow {
+		fe.scorePrefix = filepath.Base(path) + ":"
+	}
+
+	start := fe.findNode("start")
+	fe.currentNode.Store(start)
+	return fe.executeNode(start)
+}
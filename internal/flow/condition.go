@@ -0,0 +1,313 @@
+package flow
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Condition is one branch of a condition node: when If holds, the node
+// follows its Transition
+type Condition struct {
+	If         string `json:"if"`         // expression over session variables, e.g. age >= 65 && state == "FL"
+	Transition string `json:"transition"` // transition key followed when it holds
+}
+
+// conditionOps are the binary operators condition expressions support
+var conditionOps = map[token.Token]bool{
+	token.LAND: true, token.LOR: true,
+	token.EQL: true, token.NEQ: true,
+	token.LSS: true, token.LEQ: true, token.GTR: true, token.GEQ: true,
+}
+
+// validateConditions checks the conditions of a node
+func validateConditions(node *FlowNode) error {
+	if len(node.Conditions) == 0 {
+		if node.Type == "condition" {
+			return fmt.Errorf("condition node has no conditions")
+		}
+		return nil
+	}
+	if node.Type != "condition" {
+		return fmt.Errorf("conditions apply to condition nodes")
+	}
+	if node.AudioFile != "" || node.TTSText != "" {
+		return fmt.Errorf("condition nodes play no audio")
+	}
+	for _, c := range node.Conditions {
+		if c.If == "" || c.Transition == "" {
+			return fmt.Errorf("conditions need an if and a transition")
+		}
+		if node.Transitions[c.Transition] == "" {
+			return fmt.Errorf("condition %q: no %q transition", c.If, c.Transition)
+		}
+		if err := checkCondition(c.If); err != nil {
+			return fmt.Errorf("condition %q: %w", c.If, err)
+		}
+	}
+	return nil
+}
+
+// conditionCycle returns a loop of condition nodes, which would route the
+// call in circles without ever waiting for the caller, or nil
+func conditionCycle(nodes []FlowNode) []string {
+	conditions := make(map[string]*FlowNode)
+	for i := range nodes {
+		// The engine uses the first node with an ID
+		if _, ok := conditions[nodes[i].ID]; !ok {
+			conditions[nodes[i].ID] = &nodes[i]
+		}
+	}
+	for id, node := range conditions {
+		if node.Type != "condition" {
+			delete(conditions, id)
+		}
+	}
+
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var path []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		node := conditions[id]
+		if node == nil || state[id] == done {
+			return nil
+		}
+		if state[id] == visiting {
+			for i, p := range path {
+				if p == id {
+					return append(append([]string{}, path[i:]...), id)
+				}
+			}
+		}
+		state[id] = visiting
+		path = append(path, id)
+		next := make([]string, 0, len(node.Transitions)+1)
+		for _, to := range node.Transitions {
+			next = append(next, to)
+		}
+		if node.Transitions["default"] == "" {
+			next = append(next, "end_call")
+		}
+		for _, to := range next {
+			if cycle := visit(to); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+	for i := range nodes {
+		if cycle := visit(nodes[i].ID); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// checkCondition reports expressions a condition node cannot evaluate
+func checkCondition(expr string) error {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return err
+	}
+	ast.Inspect(e, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case nil, *ast.ParenExpr, *ast.Ident:
+		case *ast.BasicLit:
+			if n.Kind != token.INT && n.Kind != token.FLOAT && n.Kind != token.STRING {
+				err = fmt.Errorf("unsupported literal %s", n.Value)
+			}
+		case *ast.UnaryExpr:
+			if n.Op != token.NOT && n.Op != token.SUB {
+				err = fmt.Errorf("unsupported operator %s", n.Op)
+			}
+		case *ast.BinaryExpr:
+			if !conditionOps[n.Op] {
+				err = fmt.Errorf("unsupported operator %s", n.Op)
+			}
+		default:
+			err = fmt.Errorf("unsupported expression %q", expr[n.Pos()-1:n.End()-1])
+		}
+		return err == nil
+	})
+	return err
+}
+
+// evalCondition reports whether expr holds for the session variables vars
+// returns. Identifiers name variables, unset ones are empty; true and false
+// are booleans. == and != compare numbers numerically when both sides are
+// numbers, and text regardless of case otherwise. <, <=, > and >= hold only
+// between numbers. A value alone holds when it is true, a non-zero number or
+// non-empty text.
+func evalCondition(expr string, vars func(string) (string, bool)) (bool, error) {
+	e, err := parser.ParseExpr(expr)
+	if err != nil {
+		return false, err
+	}
+	v, err := evalExpr(e, vars)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// evalExpr evaluates e to a bool, a float64 or a string
+func evalExpr(e ast.Expr, vars func(string) (string, bool)) (any, error) {
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return evalExpr(e.X, vars)
+	case *ast.BasicLit:
+		if e.Kind == token.STRING {
+			return strconv.Unquote(e.Value)
+		}
+		return strconv.ParseFloat(e.Value, 64)
+	case *ast.Ident:
+		switch e.Name {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		v, _ := vars(e.Name)
+		return v, nil
+	case *ast.UnaryExpr:
+		x, err := evalExpr(e.X, vars)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op == token.NOT {
+			return !truthy(x), nil
+		}
+		n, ok := asNumber(x)
+		if e.Op != token.SUB || !ok {
+			return nil, fmt.Errorf("cannot apply %s to %v", e.Op, x)
+		}
+		return -n, nil
+	case *ast.BinaryExpr:
+		x, err := evalExpr(e.X, vars)
+		if err != nil {
+			return nil, err
+		}
+		// && and || only evaluate their right side when it decides
+		if e.Op == token.LAND && !truthy(x) || e.Op == token.LOR && truthy(x) {
+			return truthy(x), nil
+		}
+		y, err := evalExpr(e.Y, vars)
+		if err != nil {
+			return nil, err
+		}
+		if e.Op == token.LAND || e.Op == token.LOR {
+			return truthy(y), nil
+		}
+		return compare(e.Op, x, y)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", e)
+}
+
+// compare applies a comparison operator to two values
+func compare(op token.Token, x, y any) (bool, error) {
+	a, aok := asNumber(x)
+	b, bok := asNumber(y)
+	switch op {
+	case token.EQL, token.NEQ:
+		equal := aok && bok && a == b || !(aok && bok) && strings.EqualFold(asText(x), asText(y))
+		return equal == (op == token.EQL), nil
+	case token.LSS:
+		return aok && bok && a < b, nil
+	case token.LEQ:
+		return aok && bok && a <= b, nil
+	case token.GTR:
+		return aok && bok && a > b, nil
+	case token.GEQ:
+		return aok && bok && a >= b, nil
+	}
+	return false, fmt.Errorf("unsupported operator %s", op)
+}
+
+// asNumber returns v as a number, parsing text such as a collected age
+func asNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// asText returns v as text
+func asText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+// truthy reports whether v holds on its own, as a name alone or an operand
+// of !, && and ||
+func truthy(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case float64:
+		return v != 0
+	case string:
+		// Session variables are text, so "false", "no" and "0" hold no
+		// more than false and 0 do
+		if n, ok := asNumber(v); ok {
+			return n != 0
+		}
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "", "false", "no":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// handleConditionNode follows the transition of the node's first condition
+// that holds for the session's variables, the default transition when none
+// does. Condition nodes play nothing and wait for nothing, so a prompt the
+// previous node started keeps playing.
+func (fe *FlowEngine) handleConditionNode(node *FlowNode) error {
+	outcome := "default"
+	for _, c := range node.Conditions {
+		holds, err := evalCondition(c.If, fe.session.GetVar)
+		if err != nil {
+			return fmt.Errorf("condition %q: %w", c.If, err)
+		}
+		if holds {
+			outcome = c.Transition
+			break
+		}
+	}
+	log.Printf("CONDITION - Node: %s | Result: %s", node.ID, outcome)
+
+	nextNodeID := node.Transitions[outcome]
+	if nextNodeID == "" {
+		nextNodeID = "end_call"
+	}
+	nextNode := fe.findNode(nextNodeID)
+	if nextNode == nil {
+		return fmt.Errorf("next node %s not found", nextNodeID)
+	}
+	if fe.logger != nil {
+		fe.logger.LogTransition(fe.session.GetID(), node, nextNode, outcome)
+	}
+	fe.currentNode.Store(nextNode)
+	return fe.executeNode(nextNode)
+}
@@ -0,0 +1,268 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Defaults for collect_digits nodes
+const (
+	DefaultDigitTerminator   = "#"
+	DefaultInterDigitTimeout = 3 * time.Second
+)
+
+// Outcomes of a collect_digits node, used as its transition keys
+const (
+	DigitsCollected = "collected" // enough digits, ended by terminator, max length or pause
+	DigitsInvalid   = "invalid"   // fewer than min_length digits before the terminator or pause
+	DigitsTimeout   = "timeout"   // no digit at all before the response timeout
+)
+
+// CollectSettings configures a collect_digits node
+type CollectSettings struct {
+	MinLength           int    `json:"min_length,omitempty"`             // default 1
+	MaxLength           int    `json:"max_length,omitempty"`             // finish as soon as reached; 0 = no limit
+	Terminator          string `json:"terminator,omitempty"`             // key ending entry, default "#"
+	InterDigitTimeoutMs int    `json:"inter_digit_timeout_ms,omitempty"` // pause ending entry, default 3000
+	Variable            string `json:"variable,omitempty"`               // session variable for the digits, default the node ID
+	Mask                bool   `json:"mask,omitempty"`                   // keep the digits out of logs and transcripts
+	Speech              bool   `json:"speech,omitempty"`                 // also accept spoken digits ("five five oh one")
+}
+
+// DigitSession is implemented by sessions that deliver the caller's DTMF
+// key presses. collect_digits nodes only time out on sessions without it.
+type DigitSession interface {
+	Digits() <-chan byte
+}
+
+// collectSettings returns the node's collect settings with defaults applied
+func (n *FlowNode) collectSettings() CollectSettings {
+	var s CollectSettings
+	if n.Collect != nil {
+		s = *n.Collect
+	}
+	if s.MinLength <= 0 {
+		s.MinLength = 1
+	}
+	if s.Terminator == "" {
+		s.Terminator = DefaultDigitTerminator
+	}
+	if s.Variable == "" {
+		s.Variable = n.ID
+	}
+	return s
+}
+
+// interDigitTimeout returns the pause that ends digit entry
+func (s CollectSettings) interDigitTimeout() time.Duration {
+	if s.InterDigitTimeoutMs > 0 {
+		return time.Duration(s.InterDigitTimeoutMs) * time.Millisecond
+	}
+	return DefaultInterDigitTimeout
+}
+
+// validate checks the collect settings of a node
+func (s *CollectSettings) validate() error {
+	if s.MinLength < 0 || s.MaxLength < 0 || s.InterDigitTimeoutMs < 0 {
+		return fmt.Errorf("collect lengths and timeout must not be negative")
+	}
+	if s.MaxLength > 0 && s.MinLength > s.MaxLength {
+		return fmt.Errorf("collect min_length %d exceeds max_length %d", s.MinLength, s.MaxLength)
+	}
+	if len(s.Terminator) > 1 || (s.Terminator != "" && !strings.Contains("#*", s.Terminator)) {
+		return fmt.Errorf("collect terminator must be # or *")
+	}
+	return nil
+}
+
+// MaskDigits replaces every digit with '*', keeping the length visible
+func MaskDigits(digits string) string {
+	return strings.Repeat("*", len(digits))
+}
+
+// MaskSpokenDigits masks the numbers in a transcript: numerals keep their
+// length as '*'s and each number word ("five", "forty", "double") becomes
+// one '*', keeping trailing punctuation. Other words are kept, so "it's
+// five five oh one" reads "it's * * * *".
+func MaskSpokenDigits(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		if strings.ContainsAny(word, "0123456789") {
+			words[i] = strings.Map(func(r rune) rune {
+				if r >= '0' && r <= '9' {
+					return '*'
+				}
+				return r
+			}, word)
+			continue
+		}
+		for _, part := range strings.Split(strings.ToLower(word), "-") {
+			part = strings.Trim(part, ".?!;:,\"'()")
+			if isNumberWord(part) || part == "hundred" || part == "thousand" {
+				words[i] = "*" + word[len(strings.TrimRight(word, ".?!;:,\"')")):]
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// MaskingDigits reports whether the flow is collecting digits that must not
+// be logged; the server checks it before logging a key press or transcript
+func (fe *FlowEngine) MaskingDigits() bool {
+	return fe.maskDigits.Load()
+}
+
+// handleCollectDigitsNode plays the prompt and collects DTMF digits. Entry
+// ends with the terminator, at max_length or after a pause; the response
+// timeout applies until the first digit. Keys pressed during the prompt
+// count, keys pressed before the node started do not. With Speech, a final
+// transcript containing digits is taken as the whole entry.
+func (fe *FlowEngine) handleCollectDigitsNode(node *FlowNode) error {
+	settings := node.collectSettings()
+	log.Printf("Collecting digits: %s - %s", node.AudioFile, node.Content)
+
+	play := fe.startAudio(fe.promptFile(node), false)
+	go func() {
+		if err := play(); err != nil {
+			log.Printf("Failed to play audio: %v", err)
+		}
+	}()
+
+	var keys <-chan byte
+	if ds, ok := fe.session.(DigitSession); ok {
+		keys = ds.Digits()
+		drainDigits(keys)
+	}
+	fe.maskDigits.Store(settings.Mask)
+
+	fe.waitingFor = node
+	fe.timer.Start()
+	var interDigit <-chan time.Time
+	transcriptionChan := fe.session.GetTranscriptionResults()
+
+	var digits []byte
+	for {
+		select {
+		case key := <-keys:
+			if string(key) == settings.Terminator {
+				return fe.finishCollect(node, settings, string(digits), collectOutcome(digits, settings))
+			}
+			if key < '0' || key > '9' {
+				continue
+			}
+			digits = append(digits, key)
+			fe.timer.Stop()
+			if settings.MaxLength > 0 && len(digits) >= settings.MaxLength {
+				return fe.finishCollect(node, settings, string(digits), DigitsCollected)
+			}
+			interDigit = time.After(settings.interDigitTimeout())
+
+		case <-interDigit:
+			return fe.finishCollect(node, settings, string(digits), collectOutcome(digits, settings))
+
+		case <-fe.timer.GetTimeoutChan():
+			if len(digits) > 0 {
+				continue // the inter-digit timeout ends entry once it started
+			}
+			return fe.finishCollect(node, settings, "", DigitsTimeout)
+
+		case result, ok := <-transcriptionChan:
+			if !ok {
+				transcriptionChan = nil
+				continue
+			}
+			if fe.skipped(result) || !result.IsFinal {
+				continue
+			}
+			if fe.interrupted(node, result.Text) {
+				return nil
+			}
+			if !settings.Speech {
+				continue
+			}
+			// A spoken answer is the whole entry; words without digits
+			// are not an answer
+			if spoken := NormalizeDigits(result.Text); spoken != "" {
+				return fe.finishCollect(node, settings, spoken, spokenOutcome(spoken, settings))
+			}
+		}
+	}
+}
+
+// collectOutcome classifies digits that ended by terminator or pause
+func collectOutcome(digits []byte, settings CollectSettings) string {
+	if len(digits) == 0 || len(digits) < settings.MinLength {
+		return DigitsInvalid
+	}
+	return DigitsCollected
+}
+
+// spokenOutcome classifies a spoken entry, which has no max_length cut-off
+func spokenOutcome(digits string, settings CollectSettings) string {
+	if settings.MaxLength > 0 && len(digits) > settings.MaxLength {
+		return DigitsInvalid
+	}
+	return collectOutcome([]byte(digits), settings)
+}
+
+// finishCollect stores collected digits in the node's session variable and
+// follows the transition for outcome
+func (fe *FlowEngine) finishCollect(node *FlowNode, settings CollectSettings, digits, outcome string) error {
+	fe.timer.Stop()
+	fe.maskDigits.Store(false)
+
+	shown := digits
+	if settings.Mask {
+		shown = MaskDigits(digits)
+	}
+	if outcome == DigitsCollected {
+		fe.session.SetVar(settings.Variable, digits)
+	}
+	log.Printf("DTMF COLLECT - Question: %s | Digits: %s | Result: %s | Node: %s", node.Content, shown, outcome, node.ID)
+	if fe.logger != nil {
+		fe.logger.LogDigits(fe.session.GetID(), node, shown, outcome)
+	}
+	return fe.followOutcome(node, outcome)
+}
+
+// followOutcome leaves node by the transition for outcome, falling back to
+// "default" and then end_call
+func (fe *FlowEngine) followOutcome(node *FlowNode, outcome string) error {
+	if err := fe.stopAudio(); err != nil {
+		log.Printf("Warning: Failed to stop audio: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	nextNodeID := node.Transitions[outcome]
+	if nextNodeID == "" {
+		nextNodeID = node.Transitions["default"]
+	}
+	if nextNodeID == "" {
+		nextNodeID = "end_call"
+	}
+	nextNode := fe.findNode(nextNodeID)
+	if nextNode == nil {
+		return fmt.Errorf("next node %s not found", nextNodeID)
+	}
+	if fe.logger != nil {
+		fe.logger.LogTransition(fe.session.GetID(), node, nextNode, outcome)
+	}
+	fe.scoreAnswer(node, outcome)
+	fe.waitingFor = nil
+	fe.currentNode.Store(nextNode)
+	return fe.executeNode(nextNode)
+}
+
+// drainDigits discards key presses that arrived before collection started
+func drainDigits(keys <-chan byte) {
+	for {
+		select {
+		case <-keys:
+		default:
+			return
+		}
+	}
+}
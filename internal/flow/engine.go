@@ -5,47 +5,139 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/notify"
 )
 
 // FlowEngine manages the call flow execution
 type FlowEngine struct {
     session     Session
-    currentNode *FlowNode
+    currentNode atomic.Pointer[FlowNode] // read by the admin API while the call runs
     config      *FlowConfig
+    configDir   string // directory of the flow file, used to resolve flow assets
     timer       *GlobalTimer
     isActive    bool
     classifier  *ResponseClassifier
     waitingFor  *FlowNode // Node we're currently waiting for response on
     apiClient   *APIClient
     logger      *SessionLogger
-    lastReason  string // tracks last flow reason for hangup reporting
-    transferred bool   // track if transfer occurred to avoid DC fallback
+    lastReason  atomic.Value // string; tracks last flow reason for hangup reporting
+    transferred atomic.Bool  // track if transfer occurred to avoid DC fallback
+    failing     bool   // the error_fallback node is running (see fallback.go)
+    skipFinal   bool   // drop the rest of an utterance already handled eagerly
+    extracting  *extraction // values found for the question being asked (see extract.go)
+    maskDigits  atomic.Bool // a masked collect_digits node is active
+    playing     playbacks   // prompts started and not finished, with a PlaybackSession
+    calendar    Calendar    // books confirmed callbacks (see calendar.go)
+    notifiers   map[string]notify.Sender // senders of notify actions by channel
+    chain       map[string]*chainedFlow  // flows "flow" nodes hand the call to, by path (see chain.go)
+    chained     int                      // follow-on flows started so far
+
+    scoreMu     sync.Mutex
+    answers     map[string]string  // last answer by node, for the lead score (see leadscore.go)
+    scoring     *LeadScoreSettings // the first flow's lead_score, kept across chained flows
+    rootFlow    string             // path of the flow the call started in
+    scorePrefix string             // prefix of the current flow's nodes in answers
+
+    // Plugin hooks registered by the embedding server
+    hooks      []Hooks
+    activeNode *FlowNode // node whose exit hook has not fired yet
+    enteredAt  time.Time // when activeNode was entered, for its latency budget
 
     // Optional context for improved start logging
     startPhone  string
     startLeadID string
+
+    // Random streams derived from the session seed (see seed.go)
+    rngMu       sync.Mutex
+    seed        int64
+    variantRand *rand.Rand
+    scriptRand  *rand.Rand
+
+    labels MetricLabels // flow, version and campaign of the engine's metrics
 }
 
 // FlowNode represents a single step in the flow
 type FlowNode struct {
 	ID          string            `json:"id"`
-	Type        string            `json:"type"`    // audio, question, transfer, hangup, interrupt
+	Type        string            `json:"type"`    // audio, question, survey, collect_digits, schedule_callback, condition, transfer, hangup, interrupt, flow
 	Content     string            `json:"content"` // Human readable description
 	AudioFile   string            `json:"audio_file"`
+	TTSText     string            `json:"tts_text,omitempty"` // prompt synthesized with the server's tts provider instead of audio_file
+	Variants    []string          `json:"variants,omitempty"` // alternative prompts; one of audio_file and these plays per visit
+	Speed       float64           `json:"speed,omitempty"` // playback speed 0.9-1.1, default 1
 	Transitions map[string]string `json:"transitions"`
 	Actions     []Action          `json:"actions"`
+
+	Collect          *CollectSettings  `json:"collect,omitempty"`            // collect_digits settings
+	Callback         *CallbackSettings `json:"callback,omitempty"`           // schedule_callback settings
+	Survey           *SurveySettings   `json:"survey,omitempty"`             // survey settings
+	Conditions       []Condition       `json:"conditions,omitempty"`         // condition nodes: branches tried in order (see condition.go)
+	BudgetMs         int               `json:"budget_ms,omitempty"`          // expected max time in the node; 0 = no budget
+	MaxAnswerSeconds int               `json:"max_answer_seconds,omitempty"` // question and survey nodes: longest answer listened to; 0 = no limit
+	ResetOnPartial   *bool             `json:"reset_on_partial,omitempty"`   // question, survey and schedule_callback nodes: partials keep the caller's time running (default true)
+	MinPartialLen    int               `json:"min_partial_len,omitempty"`    // question, survey and schedule_callback nodes: partials longer than this count (default 10 characters)
+	ExtendBy         int               `json:"extend_by,omitempty"`          // question, survey and schedule_callback nodes: least ms a partial leaves to answer; 0 = the full timeout
+	InGroup          string            `json:"in_group,omitempty"`           // transfer nodes: in-group checked for a free agent first
+	BargeIn          *bool             `json:"barge_in,omitempty"`           // question and audio nodes: caller speech stops the prompt (default: the barge_in feature flag)
+	HangupDelayMs    int               `json:"post_hangup_delay_ms,omitempty"` // hangup nodes: ms waited after the prompt before hanging up
+	Flow             string            `json:"flow,omitempty"`               // flow nodes: follow-on flow file, relative to this flow
+	Extract          []ExtractRule     `json:"extract,omitempty"`            // question nodes: values pulled from the answer into variables (see extract.go)
+	ExtractRetries   *int              `json:"extract_retries,omitempty"`    // question nodes: times asked again for a missing value (default 1)
+	RetryAudio       string            `json:"retry_audio,omitempty"`        // question nodes: played when asking again instead of the prompt
+}
+
+// MaxPostHangupDelayMs bounds a hangup node's post_hangup_delay_ms, which
+// keeps the line open in silence
+const MaxPostHangupDelayMs = 5000
+
+// Playback speed limits; beyond these time-stretching becomes audible
+const (
+	MinNodeSpeed = 0.9
+	MaxNodeSpeed = 1.1
+)
+
+// PromptFile returns the audio to play for the node: AudioFile, or the name
+// of its time-stretched variant when Speed is set
+func (n *FlowNode) PromptFile() string {
+	return n.PromptFor(n.AudioFile)
+}
+
+// PromptFor returns the audio to play for one of the node's audio files,
+// its time-stretched variant when Speed is set
+func (n *FlowNode) PromptFor(file string) string {
+	if n.Speed == 0 || n.Speed == 1 || file == "" {
+		return file
+	}
+	return fmt.Sprintf("%s@%.2fx", file, n.Speed)
+}
+
+// AudioFiles returns AudioFile followed by the node's variants
+func (n *FlowNode) AudioFiles() []string {
+	if n.AudioFile == "" {
+		return nil
+	}
+	return append([]string{n.AudioFile}, n.Variants...)
 }
 
 // Action represents an action to execute when a node is processed
 type Action struct {
-	Type     string            `json:"type"`     // api_call, log, transfer
+	Type     string            `json:"type"`     // api_call, log, transfer, script, notify
 	Endpoint string            `json:"endpoint"` // For API calls
 	Method   string            `json:"method"`   // GET, POST, etc.
 	Message  string            `json:"message"`  // For logging
 	Priority string            `json:"priority"` // For API calls
 	Timeout  int               `json:"timeout"`  // For transfers
 	Params   map[string]string `json:"params"`   // Additional parameters
+
+	Script     string `json:"script,omitempty"`      // Inline Starlark source for script actions
+	ScriptFile string `json:"script_file,omitempty"` // Starlark file, relative to the flow file
 }
 
 // FlowConfig represents the entire flow configuration
@@ -59,6 +151,38 @@ type FlowMetadata struct {
 	Name        string `json:"name"`
 	Version     string `json:"version"`
 	Description string `json:"description"`
+
+	ComfortNoise  *ComfortNoiseSettings  `json:"comfort_noise,omitempty"`
+	Ambient       *AmbientSettings       `json:"ambient,omitempty"`
+	Playback      *PlaybackSettings      `json:"playback,omitempty"`
+	ErrorFallback *ErrorFallbackSettings `json:"error_fallback,omitempty"`
+	LeadScore     *LeadScoreSettings     `json:"lead_score,omitempty"`
+}
+
+// PlaybackSettings controls how consecutive prompts are joined: crossfaded
+// when CrossfadeMs is set, otherwise separated by GapMs of silence
+type PlaybackSettings struct {
+	CrossfadeMs int `json:"crossfade_ms,omitempty"`
+	GapMs       int `json:"gap_ms,omitempty"`
+}
+
+// ComfortNoiseSettings enables comfort noise between prompts for a flow
+type ComfortNoiseSettings struct {
+	Enabled  bool    `json:"enabled"`
+	RoomTone string  `json:"room_tone,omitempty"` // audio file to loop instead of generated noise
+	LevelDB  float64 `json:"level_db,omitempty"`  // generated noise level in dBFS, default -60
+}
+
+// AmbientSettings loops a background bed under the whole call, ducked under
+// prompts and muted while the caller speaks. It replaces comfort noise.
+type AmbientSettings struct {
+	Enabled  bool    `json:"enabled"`
+	File     string  `json:"file"`                // audio file to loop
+	LevelDB  float64 `json:"level_db,omitempty"`  // gain between prompts, default -20 dB
+	DuckDB   float64 `json:"duck_db,omitempty"`   // gain under prompts, default -32 dB
+	PromptDB float64 `json:"prompt_db,omitempty"` // gain of the prompts, default 0 dB
+	FadeMs   int     `json:"fade_ms,omitempty"`   // ramp between levels, default 150
+	HoldMs   int     `json:"hold_ms,omitempty"`   // stays muted after the caller stops, default 400
 }
 
 // Session interface for flow engine to interact with server session
@@ -71,6 +195,8 @@ type Session interface {
     ReportStatus(status, reason string) error
     CheckForInterrupt(text string) (string, bool) // Returns interrupt type and whether found
     EndCall() error                               // Ends the call by sending hangup command
+    GetVar(key string) (string, bool)             // Reads a session-scoped variable
+    SetVar(key, value string)                     // Writes a session-scoped variable
 }
 
 // TranscriptionResult represents a transcription result
@@ -78,6 +204,8 @@ type TranscriptionResult struct {
 	Text      string
 	IsFinal   bool
 	Timestamp time.Time
+	Start     float64 // seconds from call start
+	End       float64 // seconds from call start
 }
 
 // NewFlowEngine creates a new flow engine instance
@@ -87,6 +215,11 @@ func NewFlowEngine(session Session, configPath string) (*FlowEngine, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load flow config: %w", err)
 	}
+	rootFlow := chainPath(filepath.Dir(configPath), filepath.Base(configPath))
+	chain := map[string]*chainedFlow{rootFlow: {config: config, dir: filepath.Dir(configPath)}}
+	if err := loadChain(config, filepath.Dir(configPath), chain); err != nil {
+		return nil, fmt.Errorf("failed to load flow config: %w", err)
+	}
 
 	// Create global timer
 	timer := NewGlobalTimer(15 * time.Second)
@@ -101,11 +234,18 @@ func NewFlowEngine(session Session, configPath string) (*FlowEngine, error) {
     engine := &FlowEngine{
         session:    session,
         config:     config,
+        configDir:  filepath.Dir(configPath),
         timer:      timer,
         isActive:   false,
         classifier: classifier,
         apiClient:  apiClient,
+        chain:      chain,
+        labels:     MetricLabels{Flow: config.Metadata.Name, Version: config.Metadata.Version},
+        scoring:    config.Metadata.LeadScore,
+        rootFlow:   rootFlow,
     }
+    // The server replaces this with the session seed
+    engine.SetSeed(rand.Int63())
 
 	return engine, nil
 }
@@ -135,6 +275,38 @@ func (fe *FlowEngine) SetStartContext(phone, leadID string) {
 // GetSessionLogger returns the session logger if configured
 func (fe *FlowEngine) GetSessionLogger() *SessionLogger { return fe.logger }
 
+// Nodes returns the flow's nodes, followed by those of the flows it chains to
+func (fe *FlowEngine) Nodes() []FlowNode {
+	chained := fe.chainedNodes()
+	if len(chained) == 0 {
+		return fe.config.Nodes
+	}
+	return append(append([]FlowNode(nil), fe.config.Nodes...), chained...)
+}
+
+// Metadata returns the flow's metadata block
+func (fe *FlowEngine) Metadata() FlowMetadata { return fe.config.Metadata }
+
+// LoadFlowMetadata validates a flow file, and the flows it chains to, and
+// returns its metadata
+func LoadFlowMetadata(configPath string) (FlowMetadata, error) {
+	config, err := loadFlowConfig(configPath)
+	if err != nil {
+		return FlowMetadata{}, err
+	}
+	chain := map[string]*chainedFlow{chainPath(filepath.Dir(configPath), filepath.Base(configPath)): {config: config}}
+	if err := loadChain(config, filepath.Dir(configPath), chain); err != nil {
+		return FlowMetadata{}, err
+	}
+	return config.Metadata, nil
+}
+
+// LoadFlowConfig validates a flow file and returns it, for tools that
+// inspect a flow without running it
+func LoadFlowConfig(configPath string) (*FlowConfig, error) {
+	return loadFlowConfig(configPath)
+}
+
 // loadFlowConfig loads flow configuration from JSON file
 func loadFlowConfig(configPath string) (*FlowConfig, error) {
 	data, err := ioutil.ReadFile(configPath)
@@ -147,6 +319,86 @@ func loadFlowConfig(configPath string) (*FlowConfig, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	for _, node := range config.Nodes {
+		if node.Speed != 0 && (node.Speed < MinNodeSpeed || node.Speed > MaxNodeSpeed) {
+			return nil, fmt.Errorf("node %s: speed %.2f outside %.1f-%.1f", node.ID, node.Speed, MinNodeSpeed, MaxNodeSpeed)
+		}
+		if node.BudgetMs < 0 {
+			return nil, fmt.Errorf("node %s: budget_ms must not be negative", node.ID)
+		}
+		if node.MaxAnswerSeconds < 0 {
+			return nil, fmt.Errorf("node %s: max_answer_seconds must not be negative", node.ID)
+		}
+		if node.MinPartialLen < 0 || node.ExtendBy < 0 {
+			return nil, fmt.Errorf("node %s: min_partial_len and extend_by must not be negative", node.ID)
+		}
+		if node.HangupDelayMs < 0 || node.HangupDelayMs > MaxPostHangupDelayMs {
+			return nil, fmt.Errorf("node %s: post_hangup_delay_ms must be 0-%d", node.ID, MaxPostHangupDelayMs)
+		}
+		if node.BargeIn != nil && node.Type != "question" && node.Type != "audio" {
+			return nil, fmt.Errorf("node %s: barge_in applies to question and audio nodes", node.ID)
+		}
+		if node.Type == "flow" && node.Flow == "" {
+			return nil, fmt.Errorf("node %s: flow node names no flow", node.ID)
+		}
+		if len(node.Variants) > 0 && node.AudioFile == "" {
+			return nil, fmt.Errorf("node %s: variants need an audio_file", node.ID)
+		}
+		if node.TTSText != "" && node.AudioFile != "" {
+			return nil, fmt.Errorf("node %s: tts_text and audio_file are exclusive", node.ID)
+		}
+		if node.Collect != nil {
+			if err := node.Collect.validate(); err != nil {
+				return nil, fmt.Errorf("node %s: %w", node.ID, err)
+			}
+		}
+		if node.Callback != nil {
+			if err := node.Callback.validate(); err != nil {
+				return nil, fmt.Errorf("node %s: %w", node.ID, err)
+			}
+		}
+		if err := validateSurvey(&node); err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+		if err := validateConditions(&node); err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+		if err := validateExtract(&node); err != nil {
+			return nil, fmt.Errorf("node %s: %w", node.ID, err)
+		}
+		for _, action := range node.Actions {
+			if action.Type != "notify" {
+				continue
+			}
+			if err := validateNotify(action); err != nil {
+				return nil, fmt.Errorf("node %s: %w", node.ID, err)
+			}
+		}
+	}
+	if cycle := conditionCycle(config.Nodes); cycle != nil {
+		return nil, fmt.Errorf("condition nodes loop without waiting for the caller: %s", strings.Join(cycle, " -> "))
+	}
+	if ls := config.Metadata.LeadScore; ls != nil {
+		if err := ls.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if fb := config.Metadata.ErrorFallback; fb != nil {
+		found := false
+		for _, node := range config.Nodes {
+			found = found || node.ID == fb.Node
+		}
+		if !found {
+			return nil, fmt.Errorf("error_fallback: node %q not found", fb.Node)
+		}
+	}
+	// Synthesized prompts play like recorded ones
+	for i := range config.Nodes {
+		if config.Nodes[i].TTSText != "" {
+			config.Nodes[i].AudioFile = TTSPrompt(config.Nodes[i].TTSText)
+		}
+	}
+
 	return &config, nil
 }
 
@@ -157,10 +409,10 @@ func (fe *FlowEngine) Start() error {
 	// Find start node
 	startNode := fe.findNode("start")
 	if startNode == nil {
-		return fmt.Errorf("start node not found in flow configuration")
+		return fe.fail(nil, fmt.Errorf("start node not found in flow configuration"))
 	}
 
-    fe.currentNode = startNode
+    fe.currentNode.Store(startNode)
     log.Printf("Flow started for session %s", fe.session.GetID())
 
     // Structured log
@@ -196,24 +448,46 @@ func (fe *FlowEngine) findNode(id string) *FlowNode {
 }
 
 // executeNode executes a single flow node
-func (fe *FlowEngine) executeNode(node *FlowNode) error {
+func (fe *FlowEngine) executeNode(node *FlowNode) (err error) {
+	// Errors the node cannot recover from go to the flow's error_fallback
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in node %s: %v", node.ID, r)
+		}
+		if err != nil {
+			err = fe.fail(node, err)
+		}
+	}()
+
     log.Printf("Executing node: %s (type: %s)", node.ID, node.Type)
 
     if fe.logger != nil {
         fe.logger.LogNodeStart(fe.session.GetID(), node)
     }
+    fe.enterNode(node)
+	fe.notifyOnEnter(node)
 
 	switch node.Type {
 	case "audio":
 		return fe.handleAudioNode(node)
 	case "question":
 		return fe.handleQuestionNode(node)
+	case "survey":
+		return fe.handleSurveyNode(node)
+	case "collect_digits":
+		return fe.handleCollectDigitsNode(node)
+	case "schedule_callback":
+		return fe.handleScheduleCallbackNode(node)
+	case "condition":
+		return fe.handleConditionNode(node)
 	case "transfer":
 		return fe.handleTransferNode(node)
 	case "hangup":
 		return fe.handleHangupNode(node)
 	case "interrupt":
 		return fe.handleInterruptNode(node)
+	case "flow":
+		return fe.handleFlowNode(node)
 	default:
 		return fmt.Errorf("unknown node type: %s", node.Type)
 	}
@@ -221,17 +495,24 @@ func (fe *FlowEngine) executeNode(node *FlowNode) error {
 
 // handleAudioNode handles audio-only nodes
 func (fe *FlowEngine) handleAudioNode(node *FlowNode) error {
-	log.Printf("Playing audio: %s - %s", node.AudioFile, node.Content)
-
-	// Play audio in background (non-blocking)
-	go func() {
-		if err := fe.session.PlayAudio(node.AudioFile); err != nil {
-			log.Printf("Failed to play audio: %v", err)
-		}
-	}()
+	// An audio node without audio only routes, e.g. on the caller's background
+	if node.AudioFile != "" {
+		log.Printf("Playing audio: %s - %s", node.AudioFile, node.Content)
+
+		// Play audio in background (non-blocking)
+		play := fe.startPrompt(node, fe.promptFile(node))
+		go func() {
+			if err := play(); err != nil {
+				log.Printf("Failed to play audio: %v", err)
+			}
+		}()
+	}
 
 	// Move to next node immediately (don't wait for audio)
-	nextNodeID := node.Transitions["default"]
+	nextNodeID, reason := fe.backgroundTransition(node)
+	if nextNodeID == "" {
+		nextNodeID = node.Transitions["default"]
+	}
 	if nextNodeID == "" {
 		return fmt.Errorf("no default transition for audio node %s", node.ID)
 	}
@@ -240,8 +521,11 @@ func (fe *FlowEngine) handleAudioNode(node *FlowNode) error {
 	if nextNode == nil {
 		return fmt.Errorf("next node %s not found", nextNodeID)
 	}
+	if reason != "" && fe.logger != nil {
+		fe.logger.LogTransition(fe.session.GetID(), node, nextNode, reason)
+	}
 
-	fe.currentNode = nextNode
+	fe.currentNode.Store(nextNode)
 	return fe.executeNode(nextNode)
 }
 
@@ -250,8 +534,9 @@ func (fe *FlowEngine) handleQuestionNode(node *FlowNode) error {
     log.Printf("Playing question audio: %s - %s", node.AudioFile, node.Content)
 
 	// Play audio in background (non-blocking)
+	play := fe.startPrompt(node, fe.promptFile(node))
 	go func() {
-		if err := fe.session.PlayAudio(node.AudioFile); err != nil {
+		if err := play(); err != nil {
 			log.Printf("Failed to play audio: %v", err)
 		}
 	}()
@@ -269,100 +554,64 @@ func (fe *FlowEngine) handleQuestionNode(node *FlowNode) error {
 // waitForResponse waits for user response or timeout
 func (fe *FlowEngine) waitForResponse(node *FlowNode) {
 	fe.waitingFor = node
+	fe.extracting = nil
 
 	// Log what question we're waiting for
 	log.Printf("Waiting for response to: %s (Node: %s)", node.Content, node.ID)
 
 	// Listen for transcription results
 	transcriptionChan := fe.session.GetTranscriptionResults()
+	escalations := fe.escalations()
+	tones := fe.tones()
+
+	// Caller speech heard so far, for max_answer_seconds: finals that did
+	// not move the flow and the utterance in progress
+	var said []string
+	var partial string
+	var answerLimit <-chan time.Time
 
 	for {
 		select {
+		case level := <-escalations:
+			if fe.escalate(node, level) {
+				return
+			}
+
+		case tone := <-tones:
+			if fe.followTone(node, tone) {
+				return
+			}
+
 		case result := <-transcriptionChan:
+			if fe.skipped(result) {
+				continue
+			}
+			if answerLimit == nil && node.MaxAnswerSeconds > 0 {
+				// The caller started answering
+				limit := time.NewTimer(time.Duration(node.MaxAnswerSeconds) * time.Second)
+				defer limit.Stop()
+				answerLimit = limit.C
+			}
 			if !result.IsFinal {
-				// Partial transcript - only reset timer for substantial partials
-				// This prevents excessive resets and premature flow transitions
-				if fe.timer.IsActive() && len(result.Text) > 10 {
-					fe.timer.Reset()
+				partial = result.Text
+				// A partial answer may hold part of a value to extract
+				if len(node.Extract) > 0 || !fe.eagerFinal(result.Text) {
+					fe.partialHeard(node, result.Text)
+					continue
 				}
-				continue
+				log.Printf("Eager transition on partial: %s (Node: %s)", result.Text, node.ID)
 			}
 
-			// Final transcript - check for interrupts first
-            if interruptType, found := fe.session.CheckForInterrupt(result.Text); found {
-                log.Printf("Q&A INTERRUPT - Question: %s | Answer: %s | Interrupt: %s | Node: %s",
-                    node.Content, result.Text, interruptType, node.ID)
-                // Map interrupt to hangup reason codes used by Vicidial
-                switch interruptType {
-                case "dnc":
-                    fe.lastReason = "DNC"
-                case "not_interested":
-                    fe.lastReason = "NI"
-                case "robot":
-                    fe.lastReason = "DNQ"
-                case "amd":
-                    fe.lastReason = "A"
-                case "callback":
-                    fe.lastReason = "CALLBK"
-                default:
-                    fe.lastReason = "DNQ"
-                }
-                if fe.logger != nil {
-                    fe.logger.LogInterrupt(fe.session.GetID(), node, result.Text, interruptType)
-                }
-                fe.HandleInterrupt(interruptType)
-                return
-            }
-
-			// No interrupt - classify response
-			responseType := fe.classifier.ClassifyResponse(result.Text)
-
-			// Log Question & Answer for training/inspection
-            log.Printf("Q&A LOG - Question: %s | Answer: %s | Classification: %s | Node: %s",
-                node.Content, result.Text, responseType, node.ID)
-            if fe.logger != nil {
-                fe.logger.LogQnA(fe.session.GetID(), node, result.Text, string(responseType))
-            }
-
-			// Find next node based on response type
-			nextNodeID := node.Transitions[string(responseType)]
-			if nextNodeID == "" {
-				// Fallback to default transition
-				nextNodeID = node.Transitions["default"]
+			partial = ""
+			if fe.answer(node, result.Text) {
+				return
 			}
+			said = append(said, result.Text)
 
-            if nextNodeID != "" {
-                nextNode := fe.findNode(nextNodeID)
-                if nextNode != nil {
-                    log.Printf("Flow transition: %s (%s) -> %s (%s) | Response: %s",
-                        node.ID, node.Content, nextNode.ID, nextNode.Content, responseType)
-                    if fe.logger != nil {
-                        fe.logger.LogTransition(fe.session.GetID(), node, nextNode, string(responseType))
-                    }
-                    // Track reason based on classification for later hangup reporting if not interrupted
-                    switch string(responseType) {
-                    case "negative":
-                        fe.lastReason = "NI"
-                    case "unknown":
-                        // leave as-is
-                    }
-
-					// Stop current audio completely before transitioning
-					if fe.waitingFor != nil {
-						if err := fe.session.StopAudio(); err != nil {
-							log.Printf("Warning: Failed to stop audio: %v", err)
-						}
-						
-						// Small delay to ensure audio stops completely
-						time.Sleep(100 * time.Millisecond)
-					}
-
-					fe.timer.Stop()
-					fe.waitingFor = nil
-					fe.currentNode = nextNode
-					fe.executeNode(nextNode)
-					return
-				}
+		case <-answerLimit:
+			heard := strings.TrimSpace(strings.Join(append(said, partial), " "))
+			if fe.answerTooLong(node, heard, partial != "") {
+				return
 			}
 
         case <-fe.timer.GetTimeoutChan():
@@ -378,6 +627,105 @@ func (fe *FlowEngine) waitForResponse(node *FlowNode) {
     }
 }
 
+// answer handles the caller's answer to node: interrupts first, then the
+// transition for its classification. It reports whether the flow moved on.
+func (fe *FlowEngine) answer(node *FlowNode, text string) bool {
+	// Final transcript - check for interrupts first
+	if fe.interrupted(node, text) {
+		return true
+	}
+	if len(node.Extract) > 0 {
+		if handled, moved := fe.extractAnswer(node, text); handled {
+			return moved
+		}
+	}
+
+	// No interrupt - classify response
+	responseType := fe.classify(node, text)
+
+	// Log Question & Answer for training/inspection
+	log.Printf("Q&A LOG - Question: %s | Answer: %s | Classification: %s | Node: %s",
+		node.Content, text, responseType, node.ID)
+	if fe.logger != nil {
+		fe.logger.LogQnA(fe.session.GetID(), node, text, string(responseType))
+	}
+
+	// Find next node based on response type
+	nextNodeID := node.Transitions[string(responseType)]
+	if nextNodeID == "" {
+		// Fallback to default transition
+		nextNodeID = node.Transitions["default"]
+	}
+	if nextNodeID == "" {
+		return false
+	}
+
+	nextNode := fe.findNode(nextNodeID)
+	if nextNode == nil {
+		return fe.fail(node, fmt.Errorf("next node %s not found", nextNodeID)) == nil
+	}
+	log.Printf("Flow transition: %s (%s) -> %s (%s) | Response: %s",
+		node.ID, node.Content, nextNode.ID, nextNode.Content, responseType)
+	if fe.logger != nil {
+		fe.logger.LogTransition(fe.session.GetID(), node, nextNode, string(responseType))
+	}
+	// Track reason based on classification for later hangup reporting if not interrupted
+	switch string(responseType) {
+	case "negative":
+		fe.lastReason.Store("NI")
+	case "unknown":
+		// leave as-is
+	}
+
+	// Stop current audio completely before transitioning
+	if fe.waitingFor != nil {
+		if err := fe.stopAudio(); err != nil {
+			log.Printf("Warning: Failed to stop audio: %v", err)
+		}
+
+		// Small delay to ensure audio stops completely
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	fe.timer.Stop()
+	fe.waitingFor = nil
+	fe.currentNode.Store(nextNode)
+	fe.executeNode(nextNode)
+	return true
+}
+
+// interrupted checks a caller utterance for interrupts (dnc, robot, ...) and
+// moves the flow to the interrupt node if one is found
+func (fe *FlowEngine) interrupted(node *FlowNode, text string) bool {
+    interruptType, found := fe.session.CheckForInterrupt(text)
+    if !found {
+        return false
+    }
+    log.Printf("Q&A INTERRUPT - Question: %s | Answer: %s | Interrupt: %s | Node: %s",
+        node.Content, text, interruptType, node.ID)
+    // Map interrupt to hangup reason codes used by Vicidial
+    switch interruptType {
+    case "dnc":
+        fe.lastReason.Store("DNC")
+    case "not_interested":
+        fe.lastReason.Store("NI")
+    case "robot":
+        fe.lastReason.Store("DNQ")
+    case "amd":
+        fe.lastReason.Store("A")
+    case "callback":
+        fe.lastReason.Store("CALLBK")
+    default:
+        fe.lastReason.Store("DNQ")
+    }
+    if fe.logger != nil {
+        fe.logger.LogInterrupt(fe.session.GetID(), node, text, interruptType)
+    }
+    fe.notifyInterrupt(node, text, interruptType)
+    fe.HandleInterrupt(interruptType)
+    return true
+}
+
 // handleTimeout handles timeout events
 func (fe *FlowEngine) handleTimeout() {
 	if fe.waitingFor == nil {
@@ -385,12 +733,12 @@ func (fe *FlowEngine) handleTimeout() {
 	}
 
 	// Stop current audio before timeout transition
-	if err := fe.session.StopAudio(); err != nil {
+	if err := fe.stopAudio(); err != nil {
 		log.Printf("Warning: Failed to stop audio during timeout: %v", err)
 	}
 	
-	// Small delay to ensure audio stops completely
-	time.Sleep(100 * time.Millisecond)
+	// Let the interrupted prompt fade out (~140ms) before the next one starts
+	time.Sleep(200 * time.Millisecond)
 
 	// Find timeout transition
 	nextNodeID := fe.waitingFor.Transitions["timeout"]
@@ -402,8 +750,10 @@ func (fe *FlowEngine) handleTimeout() {
 	nextNode := fe.findNode(nextNodeID)
 	if nextNode != nil {
 		fe.waitingFor = nil
-		fe.currentNode = nextNode
+		fe.currentNode.Store(nextNode)
 		fe.executeNode(nextNode)
+	} else {
+		fe.fail(fe.waitingFor, fmt.Errorf("timeout node %s not found", nextNodeID))
 	}
 }
 
@@ -415,9 +765,10 @@ func (fe *FlowEngine) HandleInterrupt(interruptType string) {
 	if fe.timer.IsActive() {
 		fe.timer.Stop()
 	}
+	fe.maskDigits.Store(false)
 
 	// Stop current audio playback (if possible)
-	if err := fe.session.StopAudio(); err != nil {
+	if err := fe.stopAudio(); err != nil {
 		log.Printf("Warning: Failed to stop audio: %v", err)
 	}
 	
@@ -428,23 +779,32 @@ func (fe *FlowEngine) HandleInterrupt(interruptType string) {
 	interruptNode := fe.findNode(interruptType)
 	if interruptNode != nil {
 		fe.waitingFor = nil
-		fe.currentNode = interruptNode
+		fe.currentNode.Store(interruptNode)
 		fe.executeNode(interruptNode)
 	} else {
 		log.Printf("Warning: Interrupt node %s not found in flow configuration", interruptType)
+		fe.fail(fe.currentNode.Load(), fmt.Errorf("interrupt node %s not found", interruptType))
 	}
 }
 
 // handleTransferNode handles transfer nodes
 func (fe *FlowEngine) handleTransferNode(node *FlowNode) error {
+	// Offer something else rather than transfer into a queue nobody answers
+	if nextNode := fe.noAgentsTarget(node); nextNode != nil {
+		log.Printf("Flow transition: %s (%s) -> %s (%s) | No agents in %s",
+			node.ID, node.Content, nextNode.ID, nextNode.Content, node.InGroup)
+		if fe.logger != nil {
+			fe.logger.LogTransition(fe.session.GetID(), node, nextNode, "no_agents")
+		}
+		fe.currentNode.Store(nextNode)
+		return fe.executeNode(nextNode)
+	}
+
 	// Play transfer audio
-	if err := fe.session.PlayAudio(node.AudioFile); err != nil {
+	if err := fe.playAudio(fe.promptFile(node)); err != nil {
 		return fmt.Errorf("failed to play audio: %w", err)
 	}
 
-    // Stop transcription (AssemblyAI)
-    fe.session.StopTranscription()
-
     // Execute actions
     if err := fe.executeActions(node.Actions); err != nil {
         log.Printf("Warning: failed to execute transfer actions: %v", err)
@@ -454,17 +814,34 @@ func (fe *FlowEngine) handleTransferNode(node *FlowNode) error {
     if fe.apiClient != nil {
         status := fe.apiClient.TransferStatus()
         phone := fe.apiClient.TransferPhone()
-        if err := fe.apiClient.UpdateRaCallControlBySession(fe.session.GetID(), "EXTENSIONTRANSFER", status, phone); err != nil {
+        err := fe.apiClient.UpdateRaCallControlBySession(fe.session.GetID(), "EXTENSIONTRANSFER", status, phone)
+        fe.countTransfer(err)
+        if err != nil {
             log.Printf("Warning: transfer ra_call_control failed: %v", err)
+            // The caller is still with us: follow the node's "failed"
+            // transition, e.g. to offer a callback
+            if nextNode := fe.signalTarget(node, "failed"); nextNode != nil {
+                log.Printf("Flow transition: %s (%s) -> %s (%s) | Transfer failed",
+                    node.ID, node.Content, nextNode.ID, nextNode.Content)
+                if fe.logger != nil {
+                    fe.logger.LogTransition(fe.session.GetID(), node, nextNode, "failed")
+                }
+                fe.currentNode.Store(nextNode)
+                return fe.executeNode(nextNode)
+            }
         }
     }
 
+    // Stop transcription (AssemblyAI)
+    fe.session.StopTranscription()
+
     // Mark as transferred so raw hangup does not post DC later
-    fe.transferred = true
+    fe.transferred.Store(true)
 
     // Flow ends here (call continues but flow is done)
     fe.isActive = false
     log.Printf("Transfer completed, flow ended for session %s", fe.session.GetID())
+    fe.exitNode()
     if fe.logger != nil {
         fe.logger.LogFlowEnd(fe.session.GetID(), time.Now(), "transfer")
         _ = fe.logger.Close()
@@ -477,11 +854,17 @@ func (fe *FlowEngine) handleTransferNode(node *FlowNode) error {
 func (fe *FlowEngine) handleHangupNode(node *FlowNode) error {
     // Play hangup audio (if specified)
     if node.AudioFile != "" {
-        if err := fe.session.PlayAudio(node.AudioFile); err != nil {
+        if err := fe.playAudio(fe.promptFile(node)); err != nil {
             return fmt.Errorf("failed to play audio: %w", err)
         }
     }
 
+    // Let the goodbye play out of the jitter buffers before anything,
+    // Vicidial included, hangs up the channel
+    if node.HangupDelayMs > 0 {
+        time.Sleep(time.Duration(node.HangupDelayMs) * time.Millisecond)
+    }
+
     // Execute actions
     if err := fe.executeActions(node.Actions); err != nil {
         log.Printf("Warning: failed to execute hangup actions: %v", err)
@@ -489,7 +872,7 @@ func (fe *FlowEngine) handleHangupNode(node *FlowNode) error {
 
     // Vicidial: ra_call_control for hangup with flow reason
     if fe.apiClient != nil && !hasEndCallAction(node.Actions) {
-        status := fe.lastReason
+        status := fe.GetLastReason()
         if status == "" {
             status = "DC"
         }
@@ -506,6 +889,7 @@ func (fe *FlowEngine) handleHangupNode(node *FlowNode) error {
     // Flow ends here
     fe.isActive = false
     log.Printf("Hangup completed, flow ended for session %s", fe.session.GetID())
+    fe.exitNode()
     if fe.logger != nil {
         fe.logger.LogHangup(fe.session.GetID())
         fe.logger.LogFlowEnd(fe.session.GetID(), time.Now(), "hangup")
@@ -519,7 +903,7 @@ func (fe *FlowEngine) handleHangupNode(node *FlowNode) error {
 func (fe *FlowEngine) handleInterruptNode(node *FlowNode) error {
     // Play interrupt audio (if specified)
     if node.AudioFile != "" {
-        if err := fe.session.PlayAudio(node.AudioFile); err != nil {
+        if err := fe.playAudio(fe.promptFile(node)); err != nil {
             return fmt.Errorf("failed to play audio: %w", err)
         }
     }
@@ -534,7 +918,7 @@ func (fe *FlowEngine) handleInterruptNode(node *FlowNode) error {
 	if nextNodeID != "" {
 		nextNode := fe.findNode(nextNodeID)
 		if nextNode != nil {
-			fe.currentNode = nextNode
+			fe.currentNode.Store(nextNode)
 			return fe.executeNode(nextNode)
 		}
 	}
@@ -542,6 +926,7 @@ func (fe *FlowEngine) handleInterruptNode(node *FlowNode) error {
     // Flow ends here
     fe.isActive = false
     log.Printf("Interrupt completed, flow ended for session %s", fe.session.GetID())
+    fe.exitNode()
     if fe.logger != nil {
         fe.logger.LogFlowEnd(fe.session.GetID(), time.Now(), "interrupt")
         _ = fe.logger.Close()
@@ -559,6 +944,14 @@ func (fe *FlowEngine) executeActions(actions []Action) error {
             if err := fe.executeAPICall(action); err != nil {
                 log.Printf("Warning: API call failed: %v", err)
             }
+        case "script":
+            if err := fe.executeScript(action); err != nil {
+                log.Printf("Warning: script action failed: %v", err)
+            }
+        case "notify":
+            if err := fe.executeNotify(action); err != nil {
+                log.Printf("Warning: notify action failed: %v", err)
+            }
         case "log":
             log.Printf("Log action: %s", action.Message)
         case "transfer":
@@ -582,19 +975,19 @@ func (fe *FlowEngine) executeAPICall(action Action) error {
     switch action.Endpoint {
     case "/add_to_dnc":
         // Do not call Vicidial immediately; mark intent and defer to hangup
-        fe.lastReason = "DNC"
+        fe.lastReason.Store("DNC")
         if fe.logger != nil {
             fe.logger.LogAPICallDetails(fe.session.GetID(), "/add_to_dnc", "ok", map[string]string{"vd_status": "DNC"})
         }
         return nil
     case "/mark_not_interested":
-        fe.lastReason = "NI"
+        fe.lastReason.Store("NI")
         if fe.logger != nil {
             fe.logger.LogAPICallDetails(fe.session.GetID(), "/mark_not_interested", "ok", map[string]string{"vd_status": "NI"})
         }
         return nil
     case "/schedule_callback":
-        fe.lastReason = "CALLBK"
+        fe.lastReason.Store("CALLBK")
         if fe.logger != nil {
             fe.logger.LogAPICallDetails(fe.session.GetID(), "/schedule_callback", "ok", map[string]string{"vd_status": "CALLBK"})
         }
@@ -603,6 +996,7 @@ func (fe *FlowEngine) executeAPICall(action Action) error {
         st := fe.apiClient.TransferStatus()
         phone := fe.apiClient.TransferPhone()
         err := fe.apiClient.UpdateRaCallControlBySession(fe.session.GetID(), "EXTENSIONTRANSFER", st, phone)
+        fe.countTransfer(err)
         if fe.logger != nil {
             fe.logger.LogAPICallDetails(fe.session.GetID(), "/transfer_call", map[bool]string{true: "ok", false: "error"}[err == nil], map[string]string{
                 "stage": "EXTENSIONTRANSFER", "vd_status": st, "phone": phone,
@@ -610,7 +1004,7 @@ func (fe *FlowEngine) executeAPICall(action Action) error {
         }
         return err
     case "/end_call":
-        status := fe.lastReason
+        status := fe.GetLastReason()
         if status == "" {
             status = "DC"
         }
@@ -636,7 +1030,7 @@ func (fe *FlowEngine) IsActive() bool {
 
 // GetCurrentNode returns the current node
 func (fe *FlowEngine) GetCurrentNode() *FlowNode {
-    return fe.currentNode
+    return fe.currentNode.Load()
 }
 
 // Close releases resources like the session logger
@@ -647,10 +1041,13 @@ func (fe *FlowEngine) Close() {
 }
 
 // GetLastReason returns the last determined final reason (e.g., A, NI, DNC, CALLBK)
-func (fe *FlowEngine) GetLastReason() string { return fe.lastReason }
+func (fe *FlowEngine) GetLastReason() string {
+    reason, _ := fe.lastReason.Load().(string)
+    return reason
+}
 
 // WasTransferred indicates if a transfer has occurred in this flow
-func (fe *FlowEngine) WasTransferred() bool { return fe.transferred }
+func (fe *FlowEngine) WasTransferred() bool { return fe.transferred.Load() }
 
 // hasEndCallAction checks if actions include an explicit /end_call API call
 func hasEndCallAction(actions []Action) bool {
@@ -0,0 +1,69 @@
+package flow
+
+import (
+	"log"
+	"time"
+)
+
+// EscalationSession is implemented by sessions that detect a shouting or
+// agitated caller from the audio. It delivers the level ("agitated" or
+// "shouting") each time the caller escalates.
+type EscalationSession interface {
+	Escalations() <-chan string
+}
+
+// escalations returns the session's escalation signal, or nil (never ready)
+// for sessions without detection
+func (fe *FlowEngine) escalations() <-chan string {
+	if es, ok := fe.session.(EscalationSession); ok {
+		return es.Escalations()
+	}
+	return nil
+}
+
+// escalate follows the question's "escalation" transition, e.g. to transfer
+// an upset caller to a human sooner. It reports whether the flow moved;
+// nodes without the transition keep waiting for an answer.
+func (fe *FlowEngine) escalate(node *FlowNode, level string) bool {
+	nextNode := fe.signalTarget(node, "escalation")
+	if nextNode == nil {
+		log.Printf("Caller escalation (%s) at node %s, which has no escalation transition", level, node.ID)
+		return false
+	}
+	log.Printf("Flow transition: %s (%s) -> %s (%s) | Escalation: %s",
+		node.ID, node.Content, nextNode.ID, nextNode.Content, level)
+	fe.leaveQuestion(node, nextNode, "escalation")
+	return true
+}
+
+// signalTarget returns the node a question's key transition leads to, or
+// nil if it has none
+func (fe *FlowEngine) signalTarget(node *FlowNode, key string) *FlowNode {
+	nextNodeID := node.Transitions[key]
+	if nextNodeID == "" {
+		return nil
+	}
+	nextNode := fe.findNode(nextNodeID)
+	if nextNode == nil {
+		log.Printf("Warning: %s node %s not found", key, nextNodeID)
+	}
+	return nextNode
+}
+
+// leaveQuestion stops waiting for an answer to node and runs nextNode in
+// response to something heard in the audio rather than said
+func (fe *FlowEngine) leaveQuestion(node, nextNode *FlowNode, reason string) {
+	if fe.logger != nil {
+		fe.logger.LogTransition(fe.session.GetID(), node, nextNode, reason)
+	}
+
+	if err := fe.stopAudio(); err != nil {
+		log.Printf("Warning: Failed to stop audio: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	fe.timer.Stop()
+	fe.waitingFor = nil
+	fe.currentNode.Store(nextNode)
+	fe.executeNode(nextNode)
+}
@@ -0,0 +1,279 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultExtractRetries is how often a question with extract rules is asked
+// again when the answer lacks a value
+const DefaultExtractRetries = 1
+
+// Outcomes of a question's extract rules, used as its transition keys
+const (
+	ExtractMatched = "extracted" // every required value was found
+	ExtractNoMatch = "no_match"  // a required value was still missing after the retries
+)
+
+// ExtractRule pulls one value out of the caller's answer to a question into
+// a session variable. The value is found with a regular expression, its
+// first group or else the whole match, or by a built-in entity type.
+type ExtractRule struct {
+	Variable string   `json:"variable"`           // session variable for the value
+	Pattern  string   `json:"pattern,omitempty"`  // regular expression
+	Entity   string   `json:"entity,omitempty"`   // number, digits, zip, phone or age
+	Min      *float64 `json:"min,omitempty"`      // numeric values: smallest accepted
+	Max      *float64 `json:"max,omitempty"`      // numeric values: largest accepted
+	Optional bool     `json:"optional,omitempty"` // a missing value is not asked for again
+
+	re *regexp.Regexp
+}
+
+// entityExtractors find a built-in entity in an answer, "" if none. All but
+// digits look at each number said on its own, so in "it's nine oh two one
+// oh and I'm forty two" the ZIP code is 90210 and the age 42.
+var entityExtractors = map[string]func(text string) string{
+	"digits": NormalizeDigits,
+	"number": func(text string) string {
+		return firstNumber(text, func(d string) bool { return true })
+	},
+	"zip": func(text string) string {
+		return firstNumber(text, func(d string) bool { return len(d) == 5 || len(d) == 9 })
+	},
+	"phone": func(text string) string {
+		d := firstNumber(text, func(d string) bool { return len(d) == 10 || (len(d) == 11 && d[0] == '1') })
+		if len(d) == 11 {
+			d = d[1:]
+		}
+		return d
+	},
+	"age": func(text string) string {
+		return firstNumber(text, func(d string) bool {
+			age, _ := strconv.Atoi(d)
+			return len(d) <= 3 && age <= 120
+		})
+	},
+}
+
+// firstNumber returns the first number said in text, as digits, that ok
+// accepts
+func firstNumber(text string, ok func(digits string) bool) string {
+	for _, d := range spokenNumbers(text) {
+		if ok(d) {
+			return d
+		}
+	}
+	return ""
+}
+
+// spokenNumbers returns the numbers said in text, each as digits: runs of
+// number words and numerals, split by any other word
+func spokenNumbers(text string) []string {
+	var numbers []string
+	numberRuns(text, func(digits string) { numbers = append(numbers, digits) }, func(string) {})
+	return numbers
+}
+
+// withNumerals returns text with each number said in it written as digits,
+// e.g. "i'm forty two years old" -> "i'm 42 years old"
+func withNumerals(text string) string {
+	var words []string
+	numberRuns(text, func(digits string) { words = append(words, digits) }, func(word string) { words = append(words, word) })
+	return strings.Join(words, " ")
+}
+
+// numberRuns splits text into words, calling number with the digits of each
+// run of number words and numerals and word with every other word
+func numberRuns(text string, number, word func(string)) {
+	var run []string
+	flush := func() {
+		if d := NormalizeDigits(strings.Join(run, " ")); d != "" {
+			number(d)
+		} else if len(run) > 0 {
+			word(strings.Join(run, " ")) // e.g. a lone "oh"
+		}
+		run = run[:0]
+	}
+	for _, tok := range strings.Fields(text) {
+		parts := strings.Split(tok, "-")
+		for _, part := range parts {
+			if w := strings.ToLower(strings.Trim(part, ".?!;:\"'(),")); isNumberWord(w) || w == "hundred" || w == "thousand" {
+				run = append(run, w)
+				continue
+			}
+			flush()
+			word(part)
+		}
+	}
+	flush()
+}
+
+// validate checks an extract rule and compiles its pattern
+func (r *ExtractRule) validate() error {
+	if r.Variable == "" {
+		return fmt.Errorf("extract rule needs a variable")
+	}
+	if (r.Pattern == "") == (r.Entity == "") {
+		return fmt.Errorf("extract %s: set one of pattern and entity", r.Variable)
+	}
+	if r.Entity != "" && entityExtractors[r.Entity] == nil {
+		return fmt.Errorf("extract %s: unknown entity %q", r.Variable, r.Entity)
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return fmt.Errorf("extract %s: min exceeds max", r.Variable)
+	}
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", r.Variable, err)
+		}
+		r.re = re
+	}
+	return nil
+}
+
+// extract returns the rule's value in text, or "" if there is none or it
+// fails validation. A pattern is tried on the answer as transcribed and
+// then with the numbers said in it written as digits.
+func (r *ExtractRule) extract(text string) string {
+	var value string
+	if r.re != nil {
+		for _, s := range []string{text, withNumerals(text)} {
+			if m := r.re.FindStringSubmatch(s); m != nil {
+				value = m[0]
+				if len(m) > 1 {
+					value = m[1]
+				}
+				break
+			}
+		}
+	} else {
+		value = entityExtractors[r.Entity](text)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" || (r.Min == nil && r.Max == nil) {
+		return value
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || (r.Min != nil && n < *r.Min) || (r.Max != nil && n > *r.Max) {
+		return ""
+	}
+	return value
+}
+
+// validateExtract checks the extract settings of a node
+func validateExtract(node *FlowNode) error {
+	if len(node.Extract) == 0 {
+		if node.ExtractRetries != nil || node.RetryAudio != "" {
+			return fmt.Errorf("extract_retries and retry_audio need extract rules")
+		}
+		return nil
+	}
+	if node.Type != "question" {
+		return fmt.Errorf("extract applies to question nodes")
+	}
+	if node.ExtractRetries != nil && *node.ExtractRetries < 0 {
+		return fmt.Errorf("extract_retries must not be negative")
+	}
+	for i := range node.Extract {
+		if err := node.Extract[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extraction tracks a question's extract rules over one visit
+type extraction struct {
+	found  map[string]bool // variables found so far
+	misses int             // answers that left a required value missing
+}
+
+// extractAnswer stores the values of node's extract rules found in the
+// caller's answer. Once every required value is found the flow follows
+// the "extracted" transition; until then the question is asked again,
+// with retry_audio if set, up to extract_retries times, and then the flow
+// follows "no_match". Without those transitions the answer is classified
+// as usual. It reports whether it handled the answer and whether the flow
+// moved on.
+func (fe *FlowEngine) extractAnswer(node *FlowNode, text string) (handled, moved bool) {
+	if fe.extracting == nil {
+		fe.extracting = &extraction{found: make(map[string]bool)}
+	}
+	x := fe.extracting
+
+	missing := 0
+	for i := range node.Extract {
+		rule := &node.Extract[i]
+		if x.found[rule.Variable] {
+			continue
+		}
+		value := rule.extract(text)
+		if value == "" {
+			if !rule.Optional {
+				missing++
+			}
+			continue
+		}
+		x.found[rule.Variable] = true
+		fe.session.SetVar(rule.Variable, value)
+		log.Printf("EXTRACT - Question: %s | Answer: %s | %s = %s | Node: %s", node.Content, text, rule.Variable, value, node.ID)
+		if fe.logger != nil {
+			fe.logger.LogExtract(fe.session.GetID(), node, rule.Variable, value)
+		}
+	}
+
+	outcome := ExtractMatched
+	if missing > 0 {
+		x.misses++
+		if x.misses <= node.extractRetries() {
+			log.Printf("EXTRACT - Question: %s | Answer: %s | %d value(s) missing, asking again | Node: %s", node.Content, text, missing, node.ID)
+			fe.askAgain(node)
+			return true, false
+		}
+		outcome = ExtractNoMatch
+	}
+	extractions.With(fe.labelValues(node.ID, outcome)...).Inc()
+	nextNode := fe.signalTarget(node, outcome)
+	if nextNode == nil {
+		return false, false
+	}
+	if fe.logger != nil {
+		fe.logger.LogQnA(fe.session.GetID(), node, text, outcome)
+	}
+	log.Printf("Flow transition: %s (%s) -> %s (%s) | Response: %s",
+		node.ID, node.Content, nextNode.ID, nextNode.Content, outcome)
+	fe.leaveQuestion(node, nextNode, outcome)
+	return true, true
+}
+
+// extractRetries returns how often the node is asked again for a missing value
+func (n *FlowNode) extractRetries() int {
+	if n.ExtractRetries != nil {
+		return *n.ExtractRetries
+	}
+	return DefaultExtractRetries
+}
+
+// askAgain replays the question, or its retry_audio, and restarts the
+// response timeout
+func (fe *FlowEngine) askAgain(node *FlowNode) {
+	if err := fe.stopAudio(); err != nil {
+		log.Printf("Warning: Failed to stop audio: %v", err)
+	}
+	file := fe.promptFile(node)
+	if node.RetryAudio != "" {
+		file = node.RetryAudio
+	}
+	play := fe.startPrompt(node, file)
+	go func() {
+		if err := play(); err != nil {
+			log.Printf("Failed to play audio: %v", err)
+		}
+	}()
+	fe.timer.Stop()
+	fe.timer.Start()
+}
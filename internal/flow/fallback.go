@@ -0,0 +1,89 @@
+package flow
+
+import (
+	"log"
+	"time"
+)
+
+// DefaultErrorStatus is the disposition of calls ended by a flow error when
+// the flow's error_fallback sets none
+const DefaultErrorStatus = "DC"
+
+// ErrorFallbackSettings name the node a flow jumps to when it cannot go on:
+// a missing node, an unknown node type or a panicking action. The node is
+// usually an apology followed by a transfer or a hangup.
+type ErrorFallbackSettings struct {
+	Node   string `json:"node"`
+	Status string `json:"status,omitempty"` // disposition of the call, default DefaultErrorStatus
+}
+
+func (s *ErrorFallbackSettings) status() string {
+	if s.Status == "" {
+		return DefaultErrorStatus
+	}
+	return s.Status
+}
+
+// fail handles an error that stops the flow at node (nil before the start
+// node). With an error_fallback configured the call is dispositioned and the
+// fallback node runs instead of leaving the caller in silence; if the
+// fallback fails too the call is ended, and nil is returned either way.
+// Without error_fallback err is returned unchanged.
+func (fe *FlowEngine) fail(node *FlowNode, err error) error {
+	settings := fe.config.Metadata.ErrorFallback
+	if settings == nil {
+		return err
+	}
+	nodeID := ""
+	if node != nil {
+		nodeID = node.ID
+	}
+	log.Printf("Session %s: Flow error at node %q: %v", fe.session.GetID(), nodeID, err)
+	flowErrors.With(fe.labelValues(nodeID)...).Inc()
+	if fe.logger != nil {
+		fe.logger.LogFlowError(fe.session.GetID(), nodeID, err)
+	}
+
+	fe.lastReason.Store(settings.status())
+	fe.timer.Stop()
+	fe.waitingFor = nil
+	fe.maskDigits.Store(false)
+
+	fallback := fe.findNode(settings.Node)
+	if fe.failing || fallback == nil {
+		// The fallback itself failed; nothing is left to play
+		fe.endOnError()
+		return nil
+	}
+	fe.failing = true
+
+	if err := fe.stopAudio(); err != nil {
+		log.Printf("Warning: Failed to stop audio: %v", err)
+	}
+	if fe.logger != nil && node != nil {
+		fe.logger.LogTransition(fe.session.GetID(), node, fallback, "error")
+	}
+	fe.currentNode.Store(fallback)
+	return fe.executeNode(fallback)
+}
+
+// endOnError hangs up a call whose flow cannot go on
+func (fe *FlowEngine) endOnError() {
+	if fe.apiClient != nil {
+		if err := fe.apiClient.UpdateRaCallControlBySession(fe.session.GetID(), "HANGUP", fe.GetLastReason(), ""); err != nil {
+			log.Printf("Warning: hangup ra_call_control failed: %v", err)
+		}
+	}
+	if err := fe.session.EndCall(); err != nil {
+		log.Printf("Warning: failed to send hangup command: %v", err)
+	}
+
+	fe.isActive = false
+	log.Printf("Flow ended on error for session %s", fe.session.GetID())
+	fe.exitNode()
+	if fe.logger != nil {
+		fe.logger.LogHangup(fe.session.GetID())
+		fe.logger.LogFlowEnd(fe.session.GetID(), time.Now(), "error")
+		_ = fe.logger.Close()
+	}
+}
@@ -0,0 +1,50 @@
+package flow
+
+// Feature flags gating experimental engine behavior. The server resolves
+// them per campaign so risky features can be rolled out gradually.
+const (
+	// FeatureEagerTransitions acts on a partial transcript as soon as it
+	// classifies as positive or negative instead of waiting for the final
+	FeatureEagerTransitions = "eager_transitions"
+	// FeatureBargeIn lets caller speech cut a prompt short
+	FeatureBargeIn = "barge_in"
+)
+
+// FeatureSession is implemented by sessions that resolve feature flags.
+// Every flag is off for sessions that do not implement it.
+type FeatureSession interface {
+	FeatureEnabled(name string) bool
+}
+
+// featureEnabled reports whether a feature flag is on for this session
+func (fe *FlowEngine) featureEnabled(name string) bool {
+	fs, ok := fe.session.(FeatureSession)
+	return ok && fs.FeatureEnabled(name)
+}
+
+// eagerFinal reports whether a partial transcript should be handled as the
+// caller's answer right away. The rest of the same utterance is then skipped
+// so it is not taken as the answer to the next question.
+func (fe *FlowEngine) eagerFinal(text string) bool {
+	if !fe.featureEnabled(FeatureEagerTransitions) {
+		return false
+	}
+	if fe.classifier.ClassifyResponse(text) == ResponseUnknown {
+		return false
+	}
+	fe.skipFinal = true
+	return true
+}
+
+// skipped reports whether result is the rest of an utterance already acted
+// on: its later partials and its final are dropped, and the final ends the
+// skipping
+func (fe *FlowEngine) skipped(result TranscriptionResult) bool {
+	if !fe.skipFinal {
+		return false
+	}
+	if result.IsFinal {
+		fe.skipFinal = false
+	}
+	return true
+}
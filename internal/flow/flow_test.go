@@ -1,13 +1,25 @@
 package flow
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/notify"
 )
 
 // MockSession implements the Session interface for testing
 type MockSession struct {
-	id string
+	id   string
+	vars map[string]string
 }
 
 func (m *MockSession) GetID() string {
@@ -33,6 +45,30 @@ func (m *MockSession) ReportStatus(status, reason string) error {
 	return nil
 }
 
+func (m *MockSession) StopAudio() error {
+	return nil
+}
+
+func (m *MockSession) CheckForInterrupt(text string) (string, bool) {
+	return "", false
+}
+
+func (m *MockSession) EndCall() error {
+	return nil
+}
+
+func (m *MockSession) GetVar(key string) (string, bool) {
+	v, ok := m.vars[key]
+	return v, ok
+}
+
+func (m *MockSession) SetVar(key, value string) {
+	if m.vars == nil {
+		m.vars = make(map[string]string)
+	}
+	m.vars[key] = value
+}
+
 func TestNewFlowEngine(t *testing.T) {
 	session := &MockSession{id: "test-session"}
 	
@@ -111,3 +147,1990 @@ func TestGlobalTimer(t *testing.T) {
 		t.Error("Timer should not be active after stop")
 	}
 }
+
+// recordingHooks records hook invocations and forces a classification
+type recordingHooks struct {
+	NopHooks
+	entered  []string
+	exited   []string
+	override ResponseType
+}
+
+func (h *recordingHooks) OnNodeEnter(sessionID string, node *FlowNode) {
+	h.entered = append(h.entered, node.ID)
+}
+
+func (h *recordingHooks) OnNodeExit(sessionID string, node *FlowNode) {
+	h.exited = append(h.exited, node.ID)
+}
+
+func (h *recordingHooks) OnClassify(sessionID string, node *FlowNode, text string, result ResponseType) ResponseType {
+	if h.override != "" {
+		return h.override
+	}
+	return result
+}
+
+func TestHooks(t *testing.T) {
+	session := &MockSession{id: "test-session"}
+
+	engine, err := NewFlowEngine(session, "../../config/flow.json")
+	if err != nil {
+		t.Fatalf("Failed to create flow engine: %v", err)
+	}
+
+	hooks := &recordingHooks{override: ResponseNegative}
+	engine.AddHooks(hooks)
+
+	engine.enterNode(engine.findNode("start"))
+	engine.enterNode(engine.findNode("greeting"))
+	engine.exitNode()
+
+	if len(hooks.entered) != 2 || hooks.entered[1] != "greeting" {
+		t.Errorf("Unexpected enter hooks: %v", hooks.entered)
+	}
+	if len(hooks.exited) != 2 || hooks.exited[0] != "start" || hooks.exited[1] != "greeting" {
+		t.Errorf("Unexpected exit hooks: %v", hooks.exited)
+	}
+
+	if got := engine.classify(engine.findNode("pitch"), "yes"); got != ResponseNegative {
+		t.Errorf("Expected hook override %s, got %s", ResponseNegative, got)
+	}
+}
+
+func TestMetricLabels(t *testing.T) {
+	engine, err := NewFlowEngine(&MockSession{id: "test-session"}, "../../config/flow.json")
+	if err != nil {
+		t.Fatalf("Failed to create flow engine: %v", err)
+	}
+	meta := engine.Metadata()
+	if got := engine.MetricLabels(); got.Flow != meta.Name || got.Version != meta.Version || got.Campaign != "" {
+		t.Errorf("default labels = %+v, want the flow metadata", got)
+	}
+
+	// The counters are process-wide, so compare with their values before
+	durations := nodeDurations.With("solar", "v2", "metrics-test", "start")
+	positives := classifications.With("solar", "v2", "metrics-test", "pitch", string(ResponsePositive))
+	dnc := interrupts.With("solar", "v2", "metrics-test", "dnc")
+	observed, positive, interrupted := durations.Count(), positives.Value(), dnc.Value()
+
+	engine.SetMetricLabels(MetricLabels{Flow: "solar", Version: "v2", Campaign: "metrics-test"})
+	engine.enterNode(engine.findNode("start"))
+	engine.exitNode()
+	engine.classify(engine.findNode("pitch"), "yes")
+	engine.notifyInterrupt(engine.findNode("pitch"), "stop calling me", "dnc")
+
+	if got := durations.Count() - observed; got != 1 {
+		t.Errorf("start duration observations = %d, want 1", got)
+	}
+	if got := positives.Value() - positive; got != 1 {
+		t.Errorf("positive classifications = %d, want 1", got)
+	}
+	if got := dnc.Value() - interrupted; got != 1 {
+		t.Errorf("dnc interrupts = %d, want 1", got)
+	}
+}
+
+func TestScriptAction(t *testing.T) {
+	session := &MockSession{id: "test-session", vars: map[string]string{"age": "70"}}
+
+	engine, err := NewFlowEngine(session, "../../config/flow.json")
+	if err != nil {
+		t.Fatalf("Failed to create flow engine: %v", err)
+	}
+
+	script := `
+age = int(get_var("age"))
+if age >= 65:
+    set_var("eligible", "yes")
+    set_reason("SALE")
+set_var("missing", get_var("nope", "fallback"))
+`
+	if err := engine.executeScript(Action{Type: "script", Script: script}); err != nil {
+		t.Fatalf("Script failed: %v", err)
+	}
+
+	if v, _ := session.GetVar("eligible"); v != "yes" {
+		t.Errorf("Expected eligible=yes, got %q", v)
+	}
+	if v, _ := session.GetVar("missing"); v != "fallback" {
+		t.Errorf("Expected missing=fallback, got %q", v)
+	}
+	if engine.GetLastReason() != "SALE" {
+		t.Errorf("Expected last reason SALE, got %q", engine.GetLastReason())
+	}
+
+	if err := engine.executeScript(Action{Type: "script", Script: "fail("}); err == nil {
+		t.Error("Expected syntax error to be reported")
+	}
+}
+
+func TestSessionSeed(t *testing.T) {
+	node := &FlowNode{ID: "greet", AudioFile: "a.wav", Variants: []string{"b.wav", "c.wav"}, Speed: 1.05}
+	run := func(seed int64) (prompts []string, picks string) {
+		session := &MockSession{id: "test-session"}
+		engine, err := NewFlowEngine(session, "../../config/flow.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetSeed(seed)
+		for i := 0; i < 20; i++ {
+			prompts = append(prompts, engine.promptFile(node))
+		}
+		script := `set_var("picks", "%s %s" % (random(), choice(["x", "y", "z"])))`
+		if err := engine.executeScript(Action{Type: "script", Script: script}); err != nil {
+			t.Fatal(err)
+		}
+		picks, _ = session.GetVar("picks")
+		return prompts, picks
+	}
+
+	prompts, picks := run(42)
+	again, pickedAgain := run(42)
+	if strings.Join(prompts, ",") != strings.Join(again, ",") || picks != pickedAgain {
+		t.Errorf("same seed, different choices: %v %q vs %v %q", prompts, picks, again, pickedAgain)
+	}
+	seen := make(map[string]bool)
+	for _, p := range prompts {
+		seen[p] = true
+	}
+	if len(seen) != 3 || !seen["b.wav@1.05x"] {
+		t.Errorf("prompts %v, want all three variants at the node's speed", prompts)
+	}
+	if other, _ := run(43); strings.Join(other, ",") == strings.Join(prompts, ",") {
+		t.Error("another seed made the same 20 choices")
+	}
+}
+
+func TestNodeSpeed(t *testing.T) {
+	node := &FlowNode{AudioFile: "disclosure.wav"}
+	if got := node.PromptFile(); got != "disclosure.wav" {
+		t.Errorf("PromptFile() = %q without speed", got)
+	}
+	node.Speed = 1.05
+	if got := node.PromptFile(); got != "disclosure.wav@1.05x" {
+		t.Errorf("PromptFile() = %q, want speed variant", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes":[{"id":"a","type":"audio","audio_file":"a.wav","speed":1.5}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("speed outside 0.9-1.1 should be rejected")
+	}
+}
+
+// featureSession is a MockSession with feature flags
+type featureSession struct {
+	MockSession
+	features map[string]bool
+}
+
+func (f *featureSession) FeatureEnabled(name string) bool { return f.features[name] }
+
+func TestEagerTransitions(t *testing.T) {
+	engine, err := NewFlowEngine(&MockSession{id: "test-session"}, "../../config/flow.json")
+	if err != nil {
+		t.Fatalf("Failed to create flow engine: %v", err)
+	}
+	if engine.eagerFinal("yes") {
+		t.Error("eager transitions should be off for sessions without feature flags")
+	}
+
+	session := &featureSession{MockSession: MockSession{id: "test-session"}, features: map[string]bool{FeatureEagerTransitions: true}}
+	engine, err = NewFlowEngine(session, "../../config/flow.json")
+	if err != nil {
+		t.Fatalf("Failed to create flow engine: %v", err)
+	}
+	if engine.eagerFinal("um let me") || engine.skipFinal {
+		t.Error("unclassified partial should not transition")
+	}
+	if !engine.eagerFinal("yes") || !engine.skipFinal {
+		t.Error("positive partial should transition and skip the following final")
+	}
+	// The rest of the utterance is dropped, up to and including its final
+	for _, result := range []TranscriptionResult{{Text: "yes no"}, {Text: "yes no thanks", IsFinal: true}} {
+		if !engine.skipped(result) {
+			t.Errorf("%q of the eager utterance should be skipped", result.Text)
+		}
+	}
+	if engine.skipped(TranscriptionResult{Text: "no"}) {
+		t.Error("the next utterance should not be skipped")
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vicidial/non_agent_api.php" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Query().Get("pass") != "secret" {
+			fmt.Fprint(w, "ERROR: Invalid Username/Password: |api|secret|")
+			return
+		}
+		fmt.Fprint(w, "ERROR: lead_field_info LEAD NOT FOUND - 0")
+	}))
+	defer ts.Close()
+
+	api := NewVicidialClient(ts.URL, "vicidial", "api", "secret", "ra", "admin", "", "")
+	if err := api.CheckCredentials(); err != nil {
+		t.Errorf("valid credentials rejected: %v", err)
+	}
+	api = NewVicidialClient(ts.URL, "vicidial", "api", "wrong", "ra", "admin", "", "")
+	if err := api.CheckCredentials(); err == nil {
+		t.Error("invalid credentials accepted")
+	}
+	api = NewVicidialClient(ts.URL, "admin", "api", "secret", "ra", "admin", "", "")
+	if err := api.CheckCredentials(); err == nil {
+		t.Error("wrong admin directory accepted")
+	}
+}
+
+// digitSession is a MockSession that delivers DTMF keys
+type digitSession struct {
+	MockSession
+	keys chan byte
+}
+
+func (d *digitSession) Digits() <-chan byte { return d.keys }
+
+// pressHooks presses keys shortly after the collect node starts
+type pressHooks struct {
+	NopHooks
+	keys    string
+	session *digitSession
+	engine  *FlowEngine
+	masked  chan bool
+}
+
+func (h *pressHooks) OnNodeEnter(sessionID string, node *FlowNode) {
+	if node.Type != "collect_digits" {
+		return
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		h.masked <- h.engine.MaskingDigits()
+		for i := 0; i < len(h.keys); i++ {
+			h.session.keys <- h.keys[i]
+		}
+	}()
+}
+
+func TestCollectDigits(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "collect_digits", "content": "Enter your account number",
+		 "collect": {"min_length": 3, "max_length": 5, "inter_digit_timeout_ms": 100, "variable": "account", "mask": true},
+		 "transitions": {"collected": "done", "invalid": "retry", "timeout": "none"}},
+		{"id": "done", "type": "hangup"},
+		{"id": "retry", "type": "hangup"},
+		{"id": "none", "type": "hangup"}
+	]}`), 0644)
+
+	tests := []struct {
+		keys    string
+		want    string // node the flow ends on
+		account string
+	}{
+		{"12345", "done", "12345"}, // max length
+		{"1234#", "done", "1234"},  // terminator
+		{"1*23", "done", "123"},    // pause; non-digit keys ignored
+		{"12#", "retry", ""},       // too short
+		{"", "none", ""},           // nothing pressed
+	}
+	for _, tt := range tests {
+		session := &digitSession{MockSession: MockSession{id: "test-session"}, keys: make(chan byte, 8)}
+		session.keys <- '9' // pressed before the node started
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.timer = NewGlobalTimer(300 * time.Millisecond)
+		engine.SetAPIClient(nil)
+		logger, err := NewSessionLogger(dir, "test-session", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetSessionLogger(logger)
+		hooks := &pressHooks{keys: tt.keys, session: session, engine: engine, masked: make(chan bool, 1)}
+		engine.AddHooks(hooks)
+
+		if err := engine.Start(); err != nil {
+			t.Fatalf("keys %q: %v", tt.keys, err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("keys %q: ended on %s, want %s", tt.keys, got, tt.want)
+		}
+		if got, _ := session.GetVar("account"); got != tt.account {
+			t.Errorf("keys %q: account = %q, want %q", tt.keys, got, tt.account)
+		}
+		if masked := <-hooks.masked; !masked {
+			t.Errorf("keys %q: digits not masked while collecting", tt.keys)
+		}
+		if engine.MaskingDigits() {
+			t.Errorf("keys %q: still masking after the node", tt.keys)
+		}
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	for _, file := range logs {
+		data, _ := os.ReadFile(file)
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var rec logRecord
+			json.Unmarshal([]byte(line), &rec)
+			if rec.Event == "digits" && strings.Trim(rec.Text, "*") != "" {
+				t.Errorf("session log records digits %q", rec.Text)
+			}
+		}
+	}
+
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "collect_digits", "collect": {"min_length": 6, "max_length": 4}}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("min_length above max_length should be rejected")
+	}
+}
+
+func TestNormalizeDigits(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"five five five one two three four", "5551234"},
+		{"Five, five, five. One, two, three, four.", "5551234"},
+		{"555-1234", "5551234"},
+		{"555 1234", "5551234"},
+		{"my number is 555 one two three four", "5551234"},
+		{"oh one two", "012"},
+		{"four oh seven", "407"},
+		{"double five", "55"},
+		{"double oh seven", "007"},
+		{"triple nine", "999"},
+		{"one double two three", "1223"},
+		{"nineteen eighty four", "1984"},
+		{"forty two", "42"},
+		{"twenty", "20"},
+		{"eight hundred five five five", "800555"},
+		{"five hundred twelve", "512"},
+		{"five hundred two", "502"},
+		{"one hundred and five", "105"},
+		{"twenty twenty", "2020"},
+		{"two thousand", "2000"},
+		{"Oh, it's five five five", "555"},
+		{"uh five um six", "56"},
+		{"I don't know", ""},
+		{"a hundred", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeDigits(tt.text); got != tt.want {
+			t.Errorf("NormalizeDigits(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// speechSession is a MockSession whose caller says one thing
+type speechSession struct {
+	MockSession
+	said string
+}
+
+func (s *speechSession) GetTranscriptionResults() <-chan TranscriptionResult {
+	ch := make(chan TranscriptionResult, 1)
+	ch <- TranscriptionResult{Text: s.said, IsFinal: true}
+	return ch
+}
+
+func TestCollectSpokenDigits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "collect_digits",
+		 "collect": {"min_length": 3, "max_length": 5, "speech": true},
+		 "transitions": {"collected": "done", "invalid": "retry"}},
+		{"id": "done", "type": "hangup"},
+		{"id": "retry", "type": "hangup"}
+	]}`), 0644)
+
+	tests := []struct {
+		said, want, digits string
+	}{
+		{"it's four oh double seven", "done", "4077"},
+		{"one two", "retry", ""},
+		{"five five five one two three", "retry", ""}, // over max_length
+	}
+	for _, tt := range tests {
+		session := &speechSession{MockSession: MockSession{id: "test-session"}, said: tt.said}
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(nil)
+		if err := engine.Start(); err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("%q: ended on %s, want %s", tt.said, got, tt.want)
+		}
+		if got, _ := session.GetVar("start"); got != tt.digits {
+			t.Errorf("%q: digits = %q, want %q", tt.said, got, tt.digits)
+		}
+	}
+}
+
+func TestMaskSpokenDigits(t *testing.T) {
+	for _, tt := range []struct{ said, want string }{
+		{"it's four oh double seven", "it's * * * *"},
+		{"my card is 4111-1111, twenty-two", "my card is ****-****, *"},
+		{"Five hundred. Thanks", "* *. Thanks"},
+		{"no numbers here", "no numbers here"},
+	} {
+		if got := MaskSpokenDigits(tt.said); got != tt.want {
+			t.Errorf("MaskSpokenDigits(%q) = %q, want %q", tt.said, got, tt.want)
+		}
+	}
+}
+
+func TestNodeBudget(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "question", "budget_ms": 50, "transitions": {"timeout": "bye"}},
+		{"id": "bye", "type": "hangup", "budget_ms": 10000}
+	]}`), 0644)
+	if _, err := loadFlowConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	session := &MockSession{id: "test-session"}
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.timer = NewGlobalTimer(150 * time.Millisecond)
+	engine.SetAPIClient(nil)
+	logger, err := NewSessionLogger(dir, "test-session", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetSessionLogger(logger)
+
+	before := nodesOverBudget.With(engine.labelValues("start")...).Value()
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := nodesOverBudget.With(engine.labelValues("start")...).Value() - before; got != 1 {
+		t.Errorf("over budget count = %d, want 1", got)
+	}
+	if got := nodesOverBudget.With(engine.labelValues("bye")...).Value(); got != 0 {
+		t.Errorf("bye counted over budget %d times", got)
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	var over []logRecord
+	for _, file := range logs {
+		data, _ := os.ReadFile(file)
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			var rec logRecord
+			json.Unmarshal([]byte(line), &rec)
+			if rec.Event == "over_budget" {
+				over = append(over, rec)
+			}
+		}
+	}
+	if len(over) != 1 || over[0].NodeID != "start" || over[0].Details["budget_ms"] != "50" {
+		t.Errorf("over_budget records = %+v", over)
+	}
+
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "hangup", "budget_ms": -1}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("negative budget_ms should be rejected")
+	}
+}
+
+type escalationSession struct {
+	MockSession
+	levels chan string
+}
+
+func (e *escalationSession) Escalations() <-chan string { return e.levels }
+
+func TestEscalationTransition(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "question", "transitions": {"escalation": "human", "timeout": "bye"}},
+		{"id": "calm", "type": "question", "transitions": {"timeout": "bye"}},
+		{"id": "human", "type": "hangup"},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+
+	for _, tt := range []struct{ node, want string }{
+		{"start", "human"},
+		{"calm", "bye"}, // no escalation transition: keeps waiting
+	} {
+		session := &escalationSession{MockSession: MockSession{id: "test-session"}, levels: make(chan string, 1)}
+		session.levels <- "shouting"
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.timer = NewGlobalTimer(200 * time.Millisecond)
+		engine.SetAPIClient(nil)
+		if err := engine.executeNode(engine.findNode(tt.node)); err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("%s: ended on %s, want %s", tt.node, got, tt.want)
+		}
+	}
+}
+
+type toneSession struct {
+	MockSession
+	tones chan string
+}
+
+func (s *toneSession) Tones() <-chan string { return s.tones }
+
+func TestToneTransition(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "question", "transitions": {"tone:beep": "voicemail", "tone:sit": "bye", "timeout": "bye"}},
+		{"id": "voicemail", "type": "hangup"},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+
+	for _, tt := range []struct{ tone, want, reason string }{
+		{"beep", "voicemail", "A"},
+		{"sit", "bye", "ADC"},
+		{"fax", "bye", ""}, // no fax transition: keeps waiting until the timeout
+	} {
+		session := &toneSession{MockSession: MockSession{id: "test-session"}, tones: make(chan string, 1)}
+		session.tones <- tt.tone
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.timer = NewGlobalTimer(200 * time.Millisecond)
+		engine.SetAPIClient(nil)
+		if err := engine.executeNode(engine.findNode("start")); err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("%s: ended on %s, want %s", tt.tone, got, tt.want)
+		}
+		if got := engine.GetLastReason(); got != tt.reason {
+			t.Errorf("%s: reason %q, want %q", tt.tone, got, tt.reason)
+		}
+	}
+}
+
+func TestBackgroundTransition(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "audio", "transitions": {"background:car": "short", "default": "long"}},
+		{"id": "short", "type": "hangup"},
+		{"id": "long", "type": "hangup"}
+	]}`), 0644)
+
+	for _, tt := range []struct{ background, want string }{
+		{"car", "short"},
+		{"tv", "long"},
+		{"", "long"}, // not classified yet
+	} {
+		session := &MockSession{id: "test-session"}
+		if tt.background != "" {
+			session.SetVar(BackgroundVar, tt.background)
+		}
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(nil)
+		if err := engine.executeNode(engine.findNode("start")); err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("background %q: ended on %s, want %s", tt.background, got, tt.want)
+		}
+	}
+}
+
+type panicHooks struct{ recordingHooks }
+
+func (h *panicHooks) OnNodeEnter(sessionID string, node *FlowNode) {
+	if node.ID == "boom" {
+		panic("hook failed")
+	}
+}
+
+type endCallSession struct {
+	MockSession
+	ended bool
+}
+
+func (s *endCallSession) EndCall() error {
+	s.ended = true
+	return nil
+}
+
+func TestErrorFallback(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.json")
+	flow := `{"metadata": {%s}, "nodes": [
+		{"id": "start", "type": "audio", "transitions": {"default": "missing"}},
+		{"id": "odd", "type": "poll"},
+		{"id": "boom", "type": "hangup"},
+		{"id": "sorry", "type": "%s"}
+	]}`
+	fallback := `"error_fallback": {"node": "sorry", "status": "ERR"}`
+
+	for _, tt := range []struct{ node, fallbackType, want string }{
+		{"start", "hangup", "sorry"}, // missing node
+		{"odd", "hangup", "sorry"},   // unknown node type
+		{"boom", "hangup", "sorry"},  // panic
+		{"start", "poll", "sorry"},   // the fallback fails too: the call is ended
+	} {
+		os.WriteFile(path, []byte(fmt.Sprintf(flow, fallback, tt.fallbackType)), 0644)
+		session := &endCallSession{MockSession: MockSession{id: "test-session"}}
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(nil)
+		engine.AddHooks(&panicHooks{})
+		engine.isActive = true
+
+		before := flowErrors.With(engine.labelValues(tt.node)...).Value()
+		if err := engine.executeNode(engine.findNode(tt.node)); err != nil {
+			t.Fatalf("%s: error not handled: %v", tt.node, err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("%s: ended on %s, want %s", tt.node, got, tt.want)
+		}
+		if got := engine.GetLastReason(); got != "ERR" {
+			t.Errorf("%s: reason %q, want ERR", tt.node, got)
+		}
+		if !session.ended || engine.IsActive() {
+			t.Errorf("%s: call not ended", tt.node)
+		}
+		if got := flowErrors.With(engine.labelValues(tt.node)...).Value() - before; got != 1 {
+			t.Errorf("%s: counted %d errors, want 1", tt.node, got)
+		}
+	}
+
+	// Without error_fallback errors are returned as before
+	os.WriteFile(path, []byte(fmt.Sprintf(flow, "", "hangup")), 0644)
+	engine, err := NewFlowEngine(&MockSession{id: "test-session"}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAPIClient(nil)
+	if err := engine.executeNode(engine.findNode("odd")); err == nil {
+		t.Error("unknown node type should fail without error_fallback")
+	}
+
+	os.WriteFile(path, []byte(fmt.Sprintf(flow, `"error_fallback": {"node": "nowhere"}`, "hangup")), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("error_fallback naming a missing node should be rejected")
+	}
+}
+
+// talkingSession is a MockSession whose caller starts talking and does not
+// stop: only partial transcripts arrive
+type talkingSession struct {
+	MockSession
+	results chan TranscriptionResult
+}
+
+func (s *talkingSession) GetTranscriptionResults() <-chan TranscriptionResult { return s.results }
+
+func TestMaxAnswerSeconds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "question", "max_answer_seconds": 1,
+		 "transitions": {"long_winded": "polite", "positive": "offer", "timeout": "bye"}},
+		{"id": "listen", "type": "question", "max_answer_seconds": 1,
+		 "transitions": {"positive": "offer", "timeout": "bye"}},
+		{"id": "patient", "type": "question", "transitions": {"positive": "offer", "timeout": "bye"}},
+		{"id": "polite", "type": "hangup"},
+		{"id": "offer", "type": "hangup"},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+
+	for _, tt := range []struct{ node, want string }{
+		{"start", "polite"}, // long_winded transition
+		{"listen", "offer"}, // what was heard is the answer
+		{"patient", "bye"},  // no limit: the question times out
+	} {
+		session := &talkingSession{MockSession: MockSession{id: "test-session"}, results: make(chan TranscriptionResult, 2)}
+		session.results <- TranscriptionResult{Text: "yes, well, you see"}
+		session.results <- TranscriptionResult{Text: "yes, well, you see, my cousin once had a roof"}
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.timer = NewGlobalTimer(1500 * time.Millisecond)
+		engine.SetAPIClient(nil)
+		if err := engine.executeNode(engine.findNode(tt.node)); err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("%s: ended on %s, want %s", tt.node, got, tt.want)
+		}
+		if long := tt.node != "patient"; engine.skipFinal != long {
+			t.Errorf("%s: skipFinal = %v, want %v", tt.node, engine.skipFinal, long)
+		}
+	}
+
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "question", "max_answer_seconds": -1}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("negative max_answer_seconds should be rejected")
+	}
+}
+
+func TestTransferFailed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "transfer", "transitions": {"failed": "callback"}},
+		{"id": "callback", "type": "hangup"}
+	]}`), 0644)
+
+	session := &MockSession{id: "test-session"}
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without Redis the call's Vicidial identifiers cannot be resolved, so
+	// the transfer request fails
+	engine.SetAPIClient(NewVicidialClient("http://127.0.0.1:1", "vicidial", "api", "pass", "bot", "bot", "TRSFR", "26000"))
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := engine.GetCurrentNode().ID; got != "callback" {
+		t.Errorf("ended on %s, want callback", got)
+	}
+	if engine.WasTransferred() {
+		t.Error("failed transfer counted as transferred")
+	}
+}
+
+func TestNoAgentsTransition(t *testing.T) {
+	waiting := "0"
+	vicidial := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("function") != "in_group_status" || r.URL.Query().Get("in_groups") != "CLOSERS" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		fmt.Fprintf(w, "ingroup|total_calls|calls_waiting|agents_logged_in|agents_in_calls|agents_waiting|agents_paused|agents_in_dispo|agents_in_dial\n")
+		fmt.Fprintf(w, "CLOSERS|4|1|3|2|%s|1|0|0\n", waiting)
+	}))
+	defer vicidial.Close()
+
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "transfer", "in_group": "CLOSERS", "transitions": {"no_agents": "callback"}},
+		{"id": "callback", "type": "hangup"}
+	]}`), 0644)
+
+	for _, tt := range []struct {
+		waiting     string
+		want        string
+		transferred bool
+	}{
+		{"0", "callback", false},
+		{"2", "start", true},
+	} {
+		waiting = tt.waiting
+		engine, err := NewFlowEngine(&MockSession{id: "test-session"}, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(NewVicidialClient(vicidial.URL, "vicidial", "api", "pass", "bot", "bot", "TRSFR", "26000"))
+		if err := engine.Start(); err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("%s waiting: ended on %s, want %s", tt.waiting, got, tt.want)
+		}
+		if engine.WasTransferred() != tt.transferred {
+			t.Errorf("%s waiting: transferred = %v", tt.waiting, engine.WasTransferred())
+		}
+	}
+}
+
+func TestParseCallbackTime(t *testing.T) {
+	now := time.Date(2026, 10, 14, 11, 0, 0, 0, time.UTC) // a Wednesday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		said string
+		want time.Time
+		ok   bool
+	}{
+		{"tomorrow at 3pm", at(15, 15, 0), true},
+		{"tomorrow at three", at(15, 15, 0), true},
+		{"call me tomorrow morning at nine thirty", at(15, 9, 30), true},
+		{"Friday, 10:15 a.m.", at(16, 10, 15), true},
+		{"wednesday", at(21, 10, 0), true}, // today's weekday is next week's
+		{"the day after tomorrow around noon", at(16, 12, 0), true},
+		{"half past four", at(14, 16, 30), true},
+		{"quarter to five", at(14, 16, 45), true},
+		{"ten o'clock", at(15, 10, 0), true}, // already past today
+		{"four oh five", at(14, 16, 5), true},
+		{"in two hours", at(14, 13, 0), true},
+		{"in forty five minutes", at(14, 11, 45), true},
+		{"in twenty hours", at(15, 7, 0), true},
+		{"four forty two", at(14, 16, 42), true},
+		{"tonight", at(14, 18, 0), true},
+		{"today at 9am", time.Time{}, false}, // past
+		{"I'm not sure", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseCallbackTime(tt.said, now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("ParseCallbackTime(%q) = %v, %v; want %v, %v", tt.said, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSayDateTime(t *testing.T) {
+	now := time.Date(2026, 10, 14, 11, 0, 0, 0, time.UTC)
+	say := Sayer{Dir: "nums"}
+	tests := []struct {
+		when time.Time
+		want string
+	}{
+		{time.Date(2026, 10, 14, 15, 0, 0, 0, time.UTC), "today at 3 oclock p-m"},
+		{time.Date(2026, 10, 15, 9, 5, 0, 0, time.UTC), "tomorrow at 9 oh 5 a-m"},
+		{time.Date(2026, 10, 17, 12, 45, 0, 0, time.UTC), "day-6 at 12 40 5 p-m"},
+		{time.Date(2026, 11, 3, 0, 30, 0, 0, time.UTC), "day-2 mon-10 h-3 at 12 30 a-m"},
+	}
+	for _, tt := range tests {
+		var words []string
+		for _, file := range say.SayDateTime(tt.when, now) {
+			words = append(words, strings.TrimSuffix(strings.TrimPrefix(file, "nums/"), ".wav"))
+		}
+		if got := strings.Join(words, " "); got != tt.want {
+			t.Errorf("SayDateTime(%v) = %q, want %q", tt.when, got, tt.want)
+		}
+	}
+}
+
+// callbackSession is a MockSession fed transcripts by the test that records
+// the audio played
+type callbackSession struct {
+	MockSession
+	results chan TranscriptionResult
+	mu      sync.Mutex
+	played  []string
+}
+
+func (s *callbackSession) GetTranscriptionResults() <-chan TranscriptionResult { return s.results }
+
+func (s *callbackSession) PlayAudio(filename string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.played = append(s.played, filename)
+	return nil
+}
+
+func (s *callbackSession) hasPlayed(filename string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.played {
+		if f == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// asked returns how many prompts other than the survey's introduction
+// have been played
+func (s *callbackSession) asked() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, f := range s.played {
+		if f != "intro.wav" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestScheduleCallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "schedule_callback", "audio_file": "when.wav",
+		 "callback": {"readback_audio": "so.wav", "confirm_audio": "right.wav"},
+		 "transitions": {"confirmed": "booked", "rejected": "bye", "unclear": "again"}},
+		{"id": "booked", "type": "hangup"},
+		{"id": "bye", "type": "hangup"},
+		{"id": "again", "type": "hangup"}
+	]}`), 0644)
+
+	tests := []struct {
+		said []string
+		want string
+	}{
+		{[]string{"tomorrow at ten", "yes please"}, "booked"},
+		{[]string{"tomorrow at ten", "no, tomorrow at eleven", "tomorrow at eleven"}, "booked"},
+		{[]string{"tomorrow at ten", "no thanks"}, "bye"},
+		{[]string{"whenever"}, "again"},
+	}
+	for _, tt := range tests {
+		session := &callbackSession{MockSession: MockSession{id: "test-session"}, results: make(chan TranscriptionResult)}
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(nil)
+		done := make(chan error)
+		go func() { done <- engine.executeNode(engine.findNode("start")) }()
+		for _, said := range tt.said {
+			session.results <- TranscriptionResult{Text: said, IsFinal: true}
+			if _, ok := ParseCallbackTime(said, time.Now()); ok {
+				// Answer once the time has been read back
+				for deadline := time.Now().Add(time.Second); !session.hasPlayed("right.wav") && time.Now().Before(deadline); {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("%q: ended on %s, want %s", tt.said, got, tt.want)
+		}
+		if tt.want == "booked" {
+			if !session.hasPlayed(filepath.Join(DefaultSoundsDir, "tomorrow.wav")) {
+				t.Errorf("%q: the time was not read back: %v", tt.said, session.played)
+			}
+			if when, _ := session.GetVar(DefaultCallbackVariable); when == "" || engine.GetLastReason() != DefaultCallbackStatus {
+				t.Errorf("%q: callback_time = %q, status = %q", tt.said, when, engine.GetLastReason())
+			}
+		}
+	}
+
+	// Partials keep the caller's time running as at a question node
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "schedule_callback", "extend_by": 600, "transitions": {"timeout": "bye"}},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+	session := &callbackSession{MockSession: MockSession{id: "test-session"}, results: make(chan TranscriptionResult)}
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAPIClient(nil)
+	engine.timer = NewGlobalTimer(200 * time.Millisecond)
+	start := time.Now()
+	done := make(chan error)
+	go func() { done <- engine.executeNode(engine.findNode("start")) }()
+	session.results <- TranscriptionResult{Text: "let me check my calendar"}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 550*time.Millisecond {
+		t.Errorf("timed out after %v, want extend_by to leave 600ms", waited.Round(time.Millisecond))
+	}
+	if got := engine.GetCurrentNode().ID; got != "bye" {
+		t.Errorf("ended on %s, want bye", got)
+	}
+
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "schedule_callback", "callback": {"timezone": "Mars/Olympus"}}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("an unknown callback timezone should be rejected")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "question", "audio_file": "zip.wav", "retry_audio": "zip_again.wav",
+		 "extract": [{"variable": "zip", "entity": "zip"},
+		             {"variable": "age", "pattern": "(\\d+) years old", "min": 18, "optional": true}],
+		 "transitions": {"extracted": "got", "no_match": "missing"}},
+		{"id": "got", "type": "hangup"},
+		{"id": "missing", "type": "hangup"}
+	]}`), 0644)
+
+	tests := []struct {
+		said     []string
+		want     string
+		zip, age string
+		retried  bool
+	}{
+		{[]string{"nine oh two one oh"}, "got", "90210", "", false},
+		{[]string{"I don't know", "it's 90210 and I'm forty two years old"}, "got", "90210", "42", true},
+		{[]string{"90210, I'm 12 years old"}, "got", "90210", "", false}, // age below min
+		{[]string{"no idea", "still no idea"}, "missing", "", "", true},
+	}
+	for _, tt := range tests {
+		session := &callbackSession{MockSession: MockSession{id: "test-session"}, results: make(chan TranscriptionResult)}
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(nil)
+		done := make(chan error)
+		go func() { done <- engine.executeNode(engine.findNode("start")) }()
+		for _, said := range tt.said {
+			session.results <- TranscriptionResult{Text: said, IsFinal: true}
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("%q: ended on %s, want %s", tt.said, got, tt.want)
+		}
+		zip, _ := session.GetVar("zip")
+		age, _ := session.GetVar("age")
+		if zip != tt.zip || age != tt.age {
+			t.Errorf("%q: zip = %q, age = %q, want %q and %q", tt.said, zip, age, tt.zip, tt.age)
+		}
+		// The retry prompt is played from a goroutine
+		time.Sleep(20 * time.Millisecond)
+		if session.hasPlayed("zip_again.wav") != tt.retried {
+			t.Errorf("%q: retry prompt played = %v", tt.said, !tt.retried)
+		}
+	}
+
+	for _, node := range []string{
+		`{"id": "start", "type": "audio", "extract": [{"variable": "zip", "entity": "zip"}]}`,
+		`{"id": "start", "type": "question", "extract": [{"variable": "zip", "entity": "postcode"}]}`,
+		`{"id": "start", "type": "question", "extract": [{"variable": "id", "pattern": "(\\d+"}]}`,
+		`{"id": "start", "type": "question", "extract": [{"variable": "id"}]}`,
+		`{"id": "start", "type": "question", "retry_audio": "again.wav"}`,
+	} {
+		os.WriteFile(path, []byte(`{"nodes": [`+node+`]}`), 0644)
+		if _, err := loadFlowConfig(path); err == nil {
+			t.Errorf("%s should be rejected", node)
+		}
+	}
+}
+
+func TestExtractEntities(t *testing.T) {
+	tests := []struct {
+		entity, said, want string
+	}{
+		{"zip", "it's nine oh two one oh", "90210"},
+		{"zip", "my zip is 1234", ""},
+		{"phone", "one eight hundred five five five oh one two two", "8005550122"},
+		{"phone", "555-0122", ""},
+		{"age", "I'm sixty-five and my zip is 90210", "65"},
+		{"age", "two hundred", ""},
+		{"number", "about forty two", "42"},
+	}
+	for _, tt := range tests {
+		rule := ExtractRule{Variable: "v", Entity: tt.entity}
+		if err := rule.validate(); err != nil {
+			t.Fatal(err)
+		}
+		if got := rule.extract(tt.said); got != tt.want {
+			t.Errorf("%s in %q = %q, want %q", tt.entity, tt.said, got, tt.want)
+		}
+	}
+	if got := withNumerals("Policy AB, seven seven two one, please"); got != "Policy AB, 7721 please" {
+		t.Errorf("withNumerals = %q", got)
+	}
+}
+
+func TestICalendar(t *testing.T) {
+	var method, path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(data)
+		switch r.URL.Path {
+		case "/hook":
+			w.Write([]byte(`{"id": "evt-42"}`))
+		case "/broken":
+			http.Error(w, "calendar is full", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Date(2026, 10, 15, 15, 0, 0, 0, time.UTC)
+	booking := Booking{SessionID: "s1", LeadID: "1001", Phone: "5551234567", Start: start, Status: "CALLBK"}
+
+	id, err := NewICalendar(srv.URL+"/dav/callbacks/", "", 30*time.Minute).Book(booking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || path != "/dav/callbacks/"+id+".ics" {
+		t.Errorf("CalDAV booking sent %s %s, want PUT of %s.ics", method, path, id)
+	}
+	for _, want := range []string{"UID:" + id, "DTSTART:20261015T150000Z", "DTEND:20261015T153000Z", "SUMMARY:Callback 5551234567"} {
+		if !strings.Contains(body, want+"\r\n") {
+			t.Errorf("event lacks %q:\n%s", want, body)
+		}
+	}
+
+	if id, err := NewICalendar(srv.URL+"/hook", "Bearer x", 0).Book(booking); err != nil || id != "evt-42" || method != http.MethodPost {
+		t.Errorf("webhook booking = %q, %v via %s; want evt-42 via POST", id, err, method)
+	}
+	if _, err := NewICalendar(srv.URL+"/broken", "", 0).Book(booking); err == nil {
+		t.Error("a failed booking should return an error")
+	}
+}
+
+// recordingSender is a notify.Sender keeping what it was asked to send
+type recordingSender struct{ sent []notify.Message }
+
+func (s *recordingSender) Send(msg notify.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestNotifyAction(t *testing.T) {
+	session := &MockSession{id: "test-session"}
+	session.SetVar("first_name", "Ann")
+	session.SetVar("plan", "Gold")
+	engine, err := NewFlowEngine(session, "../../config/flow.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetStartContext("5550199", "1001")
+	sms := &recordingSender{}
+	engine.SetNotifier(notify.SMS, sms)
+
+	engine.executeActions([]Action{
+		{Type: "notify", Params: map[string]string{"channel": "sms", "body": "Hi ${first_name}, the ${plan} plan is yours. Ref ${lead_id}"}},
+		{Type: "notify", Params: map[string]string{"channel": "email", "to": "${email}", "body": "not configured"}},
+	})
+	want := notify.Message{To: "5550199", Body: "Hi Ann, the Gold plan is yours. Ref 1001"}
+	if len(sms.sent) != 1 || sms.sent[0] != want {
+		t.Errorf("sent %+v, want %+v", sms.sent, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "hangup",
+		"actions": [{"type": "notify", "params": {"channel": "fax", "body": "x"}}]}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("a notify action on an unknown channel should be rejected")
+	}
+}
+
+func TestWebhookOnNodeEnter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "audio", "transitions": {"default": "bye"},
+		 "actions": [{"type": "notify", "params": {"channel": "webhook", "to": "https://hooks.example.com/${campaign_id}",
+		  "body": "{\"event\": \"entered\", \"node\": \"${node}\", \"name\": \"${first_name}\"}"}}]},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+
+	session := &MockSession{id: "test-session"}
+	session.SetVar("campaign_id", "SALES")
+	session.SetVar("first_name", `Ann "Annie" O'Neil`)
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAPIClient(nil)
+	hooks := &recordingSender{}
+	engine.SetNotifier(notify.Webhook, hooks)
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hooks.sent) != 1 {
+		t.Fatalf("sent %d webhooks, want 1", len(hooks.sent))
+	}
+	var body map[string]string
+	if err := json.Unmarshal([]byte(hooks.sent[0].Body), &body); err != nil {
+		t.Fatalf("webhook body %s is not JSON: %v", hooks.sent[0].Body, err)
+	}
+	if hooks.sent[0].To != "https://hooks.example.com/SALES" || body["node"] != "start" || body["name"] != `Ann "Annie" O'Neil` {
+		t.Errorf("sent to %s: %v", hooks.sent[0].To, body)
+	}
+
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "audio",
+		"actions": [{"type": "notify", "params": {"channel": "webhook", "body": "{}"}}]}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("a webhook notify action without a URL should be rejected")
+	}
+}
+
+func TestAgentCache(t *testing.T) {
+	cache := newAgentCache(2, 0)
+	cache.Put("1", "agent1")
+	cache.Put("2", "agent2")
+	cache.Get("1")
+	cache.Put("3", "agent3")
+	if _, ok := cache.Get("2"); ok {
+		t.Error("least recently used lead kept")
+	}
+	if user, ok := cache.Get("1"); !ok || user != "agent1" {
+		t.Errorf("Get(1) = %q, %v", user, ok)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", cache.Len())
+	}
+
+	cache = newAgentCache(2, time.Millisecond)
+	cache.Put("1", "agent1")
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.Get("1"); ok {
+		t.Error("expired lead returned")
+	}
+}
+
+func TestGetAgentUserByLeadCached(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		if r.URL.Query().Get("lead_id") == "404" {
+			fmt.Fprint(w, "ERROR: lead_field_info LEAD NOT FOUND - 404")
+			return
+		}
+		fmt.Fprint(w, "agent7")
+	}))
+	defer ts.Close()
+
+	shared := NewAgentCache(10, time.Minute)
+	api := NewVicidialClient(ts.URL, "vicidial", "api", "pass", "ra", "admin", "", "")
+	api.SetAgentCache(shared)
+	for i := 0; i < 3; i++ {
+		if user, err := api.GetAgentUserByLead("1001"); err != nil || user != "agent7" {
+			t.Fatalf("GetAgentUserByLead = %q, %v", user, err)
+		}
+	}
+	// Another client of the call finds the lead in the shared cache
+	next := NewVicidialClient(ts.URL, "vicidial", "api", "pass", "ra", "admin", "", "")
+	next.SetAgentCache(shared)
+	if user, _ := next.GetAgentUserByLead("1001"); user != "agent7" {
+		t.Errorf("shared lookup = %q", user)
+	}
+	// The lead's next call looks its agent up again
+	shared.Forget("1001")
+	redial := NewVicidialClient(ts.URL, "vicidial", "api", "pass", "ra", "admin", "", "")
+	redial.SetAgentCache(shared)
+	redial.GetAgentUserByLead("1001")
+	// Errors are looked up again
+	api.GetAgentUserByLead("404")
+	api.GetAgentUserByLead("404")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 4 {
+		t.Errorf("%d requests, want 2 for the cached lead, one per call, and 2 for the missing one", requests)
+	}
+}
+
+func TestChainedFlow(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "verify"), 0755)
+	path := filepath.Join(dir, "survey.json")
+	os.WriteFile(path, []byte(`{"metadata": {"name": "survey", "version": "1"}, "nodes": [
+		{"id": "start", "type": "audio", "transitions": {"default": "qualified"}},
+		{"id": "qualified", "type": "flow", "flow": "verify/verify.json"},
+		{"id": "end_call", "type": "hangup"}
+	]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "verify", "verify.json"), []byte(`{"metadata": {"name": "verify", "version": "3"}, "nodes": [
+		{"id": "start", "type": "audio", "audio_file": "verify.wav", "speed": 1.05, "transitions": {"default": "bye"}},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+
+	session := &MockSession{id: "test-session"}
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAPIClient(nil)
+	logger, err := NewSessionLogger(dir, "test-session", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetSessionLogger(logger)
+
+	// Prompts of chained flows are prepared with the session's own
+	if nodes := engine.Nodes(); len(nodes) != 5 || nodes[3].PromptFile() != "verify.wav@1.05x" {
+		t.Errorf("Nodes() = %+v, want the survey's and the verify flow's", nodes)
+	}
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got := engine.GetCurrentNode().ID; got != "bye" {
+		t.Errorf("ended on %s, want the verify flow's bye", got)
+	}
+	if engine.labels.Flow != "verify" || engine.labels.Version != "3" {
+		t.Errorf("metric labels %+v, want the verify flow's", engine.labels)
+	}
+
+	logs, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if len(logs) != 1 {
+		t.Fatalf("%d session logs, want 1", len(logs))
+	}
+	data, _ := os.ReadFile(logs[0])
+	var events []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec logRecord
+		json.Unmarshal([]byte(line), &rec)
+		if rec.Event == "node_start" || rec.Event == "flow_chain" {
+			events = append(events, rec.Event+":"+rec.NodeID)
+		}
+	}
+	if got := strings.Join(events, " "); got != "node_start:start node_start:qualified flow_chain:qualified node_start:start node_start:bye" {
+		t.Errorf("session log %s", got)
+	}
+}
+
+func TestChainedFlowErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "flow", "flow": "missing.json"}
+	]}`), 0644)
+	if _, err := LoadFlowMetadata(path); err == nil {
+		t.Error("a flow chaining to a missing flow should be rejected")
+	}
+	if _, err := NewFlowEngine(&MockSession{id: "test-session"}, path); err == nil {
+		t.Error("an engine chaining to a missing flow should not start")
+	}
+
+	// A flow chaining to itself stops after maxChainedFlows
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "flow", "flow": "flow.json"},
+		{"id": "end_call", "type": "hangup"}
+	]}`), 0644)
+	engine, err := NewFlowEngine(&MockSession{id: "test-session"}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAPIClient(nil)
+	engine.Start()
+	if engine.chained != maxChainedFlows {
+		t.Errorf("chained %d flows, want %d", engine.chained, maxChainedFlows)
+	}
+}
+
+func TestPromptReferences(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "survey.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "audio", "audio_file": "hello.wav", "variants": ["hi.wav"], "speed": 1.05, "transitions": {"default": "verify"}},
+		{"id": "verify", "type": "flow", "flow": "verify.json"},
+		{"id": "end_call", "type": "hangup", "audio_file": "bye.wav"}
+	]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "verify.json"), []byte(`{"nodes": [
+		{"id": "start", "type": "audio", "audio_file": "hello.wav", "transitions": {"default": "bye"}},
+		{"id": "bye", "type": "hangup", "audio_file": "bye.wav"}
+	]}`), 0644)
+
+	refs, err := PromptReferences(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Speed variants are derived from the files and not referenced
+	want := map[string]string{
+		"hello.wav": "[start verify.json:start]",
+		"hi.wav":    "[start]",
+		"bye.wav":   "[end_call verify.json:bye]",
+	}
+	if len(refs) != len(want) {
+		t.Errorf("references %v, want %v", refs, want)
+	}
+	for file, nodes := range want {
+		if fmt.Sprint(refs[file]) != nodes {
+			t.Errorf("%s played by %v, want %s", file, refs[file], nodes)
+		}
+	}
+
+	// Callback readbacks, their number prompts and the flow's beds are played too
+	os.WriteFile(path, []byte(`{"metadata": {"ambient": {"enabled": true, "file": "office.wav"},
+		"comfort_noise": {"enabled": true, "room_tone": "room.wav"}},
+	 "nodes": [
+		{"id": "when", "type": "schedule_callback", "audio_file": "when.wav",
+		 "callback": {"readback_audio": "so.wav", "confirm_audio": "right.wav"}, "transitions": {"default": "zip"}},
+		{"id": "zip", "type": "question", "audio_file": "zip.wav", "retry_audio": "zip_again.wav",
+		 "extract": [{"variable": "zip", "entity": "zip"}]}
+	]}`), 0644)
+	refs, err = PromptReferences(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, nodes := range map[string]string{
+		"so.wav":        "[when]",
+		"zip_again.wav": "[zip]",
+		"right.wav":     "[when]",
+		"digits/7.wav":  "[when]",
+		"office.wav":    "[metadata.ambient]",
+		"room.wav":      "[metadata.comfort_noise]",
+	} {
+		if fmt.Sprint(refs[file]) != nodes {
+			t.Errorf("%s played by %v, want %s", file, refs[file], nodes)
+		}
+	}
+
+	os.Remove(filepath.Join(dir, "verify.json"))
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "flow", "flow": "verify.json"}]}`), 0644)
+	if _, err := PromptReferences(path); err == nil {
+		t.Error("expected an error for a missing chained flow")
+	}
+}
+
+func TestPartialHeard(t *testing.T) {
+	no := false
+	long := "well let me think about it"
+	for _, tc := range []struct {
+		name  string
+		node  FlowNode
+		text  string
+		fires time.Duration // when the timeout should fire, from the partial
+	}{
+		{"default resets", FlowNode{}, long, 300 * time.Millisecond},
+		{"short partial", FlowNode{}, "um yes", 100 * time.Millisecond},
+		{"min_partial_len", FlowNode{MinPartialLen: 40}, long, 100 * time.Millisecond},
+		{"reset_on_partial off", FlowNode{ResetOnPartial: &no}, long, 100 * time.Millisecond},
+		{"extend_by", FlowNode{ExtendBy: 600}, long, 600 * time.Millisecond},
+		{"extend_by under the time left", FlowNode{ExtendBy: 50}, long, 100 * time.Millisecond},
+	} {
+		fe := &FlowEngine{timer: NewGlobalTimer(300 * time.Millisecond)}
+		fe.timer.Start()
+		time.Sleep(200 * time.Millisecond)
+		start := time.Now()
+		fe.partialHeard(&tc.node, tc.text)
+		<-fe.timer.GetTimeoutChan()
+		if waited := time.Since(start); waited < tc.fires-50*time.Millisecond || waited > tc.fires+100*time.Millisecond {
+			t.Errorf("%s: timed out %v after the partial, want about %v", tc.name, waited.Round(time.Millisecond), tc.fires)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "question", "extend_by": -1}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("expected an error for a negative extend_by")
+	}
+}
+
+func TestTTSTexts(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "audio", "tts_text": "Hello there", "transitions": {"default": "verify"}},
+		{"id": "verify", "type": "flow", "flow": "verify.json"},
+		{"id": "end_call", "type": "hangup", "audio_file": "bye.wav"}
+	]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "verify.json"), []byte(`{"nodes": [
+		{"id": "start", "type": "question", "tts_text": "Is that right?", "speed": 1.05, "transitions": {"default": "bye"}},
+		{"id": "bye", "type": "hangup", "tts_text": "Hello there"}
+	]}`), 0644)
+
+	texts, err := TTSTexts(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, right := TTSPrompt("Hello there"), TTSPrompt("Is that right?")
+	if len(texts) != 2 || texts[hello] != "Hello there" || texts[right] != "Is that right?" {
+		t.Errorf("texts = %v", texts)
+	}
+
+	// Synthesized prompts play like recordings, speed variants included
+	config, err := loadFlowConfig(filepath.Join(dir, "verify.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node := config.Nodes[0]; node.AudioFile != right || node.PromptFile() != right+"@1.05x" {
+		t.Errorf("node plays %s (%s)", node.AudioFile, node.PromptFile())
+	}
+	refs, err := PromptReferences(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(refs[hello]) != "[start verify.json:bye]" {
+		t.Errorf("%s played by %v", hello, refs[hello])
+	}
+
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "hangup", "audio_file": "bye.wav", "tts_text": "Bye"}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil || !strings.Contains(err.Error(), "exclusive") {
+		t.Errorf("tts_text with audio_file: %v", err)
+	}
+}
+
+type hangupTimingSession struct {
+	MockSession
+	played, ended time.Time
+}
+
+func (s *hangupTimingSession) PlayAudio(filename string) error {
+	s.played = time.Now()
+	return nil
+}
+
+func (s *hangupTimingSession) EndCall() error {
+	s.ended = time.Now()
+	return nil
+}
+
+func TestPostHangupDelay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "hangup", "audio_file": "bye.wav", "post_hangup_delay_ms": 150}
+	]}`), 0644)
+	session := &hangupTimingSession{MockSession: MockSession{id: "test-session"}}
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAPIClient(nil)
+	if err := engine.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if wait := session.ended.Sub(session.played); wait < 150*time.Millisecond {
+		t.Errorf("hung up %v after the goodbye, want at least 150ms", wait)
+	}
+
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "hangup", "post_hangup_delay_ms": -1}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("negative post_hangup_delay_ms should be rejected")
+	}
+}
+
+// bargeInSession is a featureSession that records how prompts were played
+type bargeInSession struct {
+	featureSession
+	mu                    sync.Mutex
+	played, bargeInPlayed []string
+}
+
+func (s *bargeInSession) PlayAudio(filename string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.played = append(s.played, filename)
+	return nil
+}
+
+func (s *bargeInSession) PlayAudioBargeIn(filename string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bargeInPlayed = append(s.bargeInPlayed, filename)
+	return nil
+}
+
+func TestBargeIn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "question", "audio_file": "default.wav"},
+		{"id": "on", "type": "question", "audio_file": "on.wav", "barge_in": true},
+		{"id": "off", "type": "audio", "audio_file": "off.wav", "barge_in": false}
+	]}`), 0644)
+	session := &bargeInSession{featureSession: featureSession{MockSession: MockSession{id: "test-session"}}}
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		node    string
+		flag    bool
+		bargeIn bool
+	}{
+		{"start", false, false},
+		{"start", true, true}, // the campaign's feature flag is the default
+		{"on", false, true},
+		{"off", true, false},
+	} {
+		session.features = map[string]bool{FeatureBargeIn: tt.flag}
+		session.played, session.bargeInPlayed = nil, nil
+		node := engine.findNode(tt.node)
+		engine.startPrompt(node, node.AudioFile)()
+		if got := len(session.bargeInPlayed) == 1; got != tt.bargeIn || len(session.played)+len(session.bargeInPlayed) != 1 {
+			t.Errorf("node %s with flag %v: played %v, with barge-in %v", tt.node, tt.flag, session.played, session.bargeInPlayed)
+		}
+	}
+
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "hangup", "audio_file": "bye.wav", "barge_in": true}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("barge_in on a hangup node should be rejected")
+	}
+}
+
+// playbackSession records which playbacks were stopped
+type playbackSession struct {
+	MockSession
+	mu      sync.Mutex
+	next    PlaybackID
+	played  []PlaybackID
+	stopped []PlaybackID
+	stopAll bool
+}
+
+func (s *playbackSession) NewPlayback() PlaybackID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	return s.next
+}
+
+func (s *playbackSession) PlayAudioAs(id PlaybackID, filename string, bargeIn bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.played = append(s.played, id)
+	return nil
+}
+
+func (s *playbackSession) StopPlayback(id PlaybackID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = append(s.stopped, id)
+	return nil
+}
+
+func (s *playbackSession) StopAll() error {
+	s.stopAll = true
+	return nil
+}
+
+func (s *playbackSession) StopAudio() error {
+	s.stopAll = true
+	return nil
+}
+
+func TestStopAudioByPlayback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [{"id": "start", "type": "question", "audio_file": "q.wav"}]}`), 0644)
+	session := &playbackSession{MockSession: MockSession{id: "test-session"}}
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A prompt reserved but not yet playing is stopped by its ID
+	pending := engine.startAudio("q.wav", false)
+	engine.stopAudio()
+	if len(session.stopped) != 1 || session.stopped[0] != 1 || session.stopAll {
+		t.Fatalf("stopped %v, stop all %v; want only playback 1", session.stopped, session.stopAll)
+	}
+
+	// A finished prompt is not stopped again, nor is one the engine did not start
+	pending()
+	engine.playAudio("q.wav")
+	session.NewPlayback()
+	session.stopped = nil
+	engine.stopAudio()
+	if len(session.stopped) != 0 {
+		t.Errorf("stopped %v after every prompt finished", session.stopped)
+	}
+	if len(session.played) != 2 {
+		t.Errorf("played %v, want 2 prompts", session.played)
+	}
+}
+
+func TestSurvey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "survey", "audio_file": "intro.wav",
+		 "survey": {"timeout_ms": 100, "retry_audio": "sorry.wav", "questions": [
+			{"id": "owner", "content": "Do you own your home?", "audio_file": "owner.wav"},
+			{"id": "smoker", "content": "Are you a non-smoker?", "audio_file": "smoker.wav", "variable": "non_smoker"}
+		 ]},
+		 "transitions": {"all_positive": "qualified", "any_negative": "bye", "incomplete": "unsure"}},
+		{"id": "qualified", "type": "hangup"},
+		{"id": "bye", "type": "hangup"},
+		{"id": "unsure", "type": "hangup"}
+	]}`), 0644)
+
+	tests := []struct {
+		said  []string // "" lets the question time out
+		want  string
+		owner string
+		smoke string
+	}{
+		{[]string{"yes", "yes"}, "qualified", "positive", "positive"},
+		{[]string{"yes please", "what do you mean", "no"}, "bye", "positive", "negative"},
+		{[]string{"no", "", ""}, "bye", "negative", SurveyTimeout},
+		{[]string{"yes", "", "yes"}, "qualified", "positive", "positive"},
+		{[]string{"hmm", "", "yes"}, "unsure", SurveyTimeout, "positive"},
+	}
+	for _, tt := range tests {
+		session := &callbackSession{MockSession: MockSession{id: "test-session"}, results: make(chan TranscriptionResult)}
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(nil)
+		done := make(chan error)
+		go func() { done <- engine.executeNode(engine.findNode("start")) }()
+		for i, said := range tt.said {
+			// Each step answers one asking of a question, so wait until it
+			// has been asked; after a timeout that is the next retry
+			for deadline := time.Now().Add(time.Second); session.asked() <= i && time.Now().Before(deadline); {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if said != "" {
+				session.results <- TranscriptionResult{Text: said, IsFinal: true}
+			}
+		}
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("%q: ended on %s, want %s", tt.said, got, tt.want)
+		}
+		owner, _ := session.GetVar("start_owner")
+		smoke, _ := session.GetVar("non_smoker")
+		if owner != tt.owner || smoke != tt.smoke {
+			t.Errorf("%q: answers %q and %q, want %q and %q", tt.said, owner, smoke, tt.owner, tt.smoke)
+		}
+		if !session.hasPlayed("intro.wav") || !session.hasPlayed("smoker.wav") {
+			t.Errorf("%q: played %v", tt.said, session.played)
+		}
+	}
+
+	// Asking stops at the first no when the survey says so
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "survey", "survey": {"stop_on_negative": true, "questions": [
+			{"id": "a", "audio_file": "a.wav"}, {"id": "b", "audio_file": "b.wav"}
+		 ]}, "transitions": {"default": "bye"}},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+	session := &callbackSession{MockSession: MockSession{id: "test-session"}, results: make(chan TranscriptionResult)}
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAPIClient(nil)
+	done := make(chan error)
+	go func() { done <- engine.executeNode(engine.findNode("start")) }()
+	session.results <- TranscriptionResult{Text: "no", IsFinal: true}
+	<-done
+	if session.hasPlayed("b.wav") || engine.GetCurrentNode().ID != "bye" {
+		t.Errorf("played %v and ended on %s after a no", session.played, engine.GetCurrentNode().ID)
+	}
+
+	// The survey's escalation transition applies while a question waits
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "survey", "survey": {"timeout_ms": 1000, "questions": [{"id": "a", "audio_file": "a.wav"}]},
+		 "transitions": {"escalation": "human", "default": "bye"}},
+		{"id": "human", "type": "hangup"},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+	escalating := &escalationSession{MockSession: MockSession{id: "test-session"}, levels: make(chan string, 1)}
+	escalating.levels <- "shouting"
+	engine, err = NewFlowEngine(escalating, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAPIClient(nil)
+	if err := engine.executeNode(engine.findNode("start")); err != nil {
+		t.Fatal(err)
+	}
+	if got := engine.GetCurrentNode().ID; got != "human" {
+		t.Errorf("escalation during a survey ended on %s, want human", got)
+	}
+
+	for _, bad := range []string{
+		`{"id": "start", "type": "survey"}`,
+		`{"id": "start", "type": "survey", "survey": {"questions": [{"id": "a", "audio_file": "a.wav"}, {"id": "a", "audio_file": "b.wav"}]}}`,
+		`{"id": "start", "type": "survey", "survey": {"questions": [{"id": "a"}]}}`,
+		`{"id": "start", "type": "question", "survey": {"questions": [{"id": "a", "audio_file": "a.wav"}]}}`,
+	} {
+		os.WriteFile(path, []byte(`{"nodes": [`+bad+`]}`), 0644)
+		if _, err := loadFlowConfig(path); err == nil {
+			t.Errorf("%s should be rejected", bad)
+		}
+	}
+}
+
+func TestLeadScore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"metadata": {"lead_score": {"field": "ai_score", "base": 10, "weights": {
+		"start.owner": {"positive": 30, "negative": -20},
+		"start.smoker": {"positive": 15},
+		"start": {"all_positive": 50}
+	}}}, "nodes": [
+		{"id": "start", "type": "survey", "survey": {"questions": [
+			{"id": "owner", "audio_file": "owner.wav"},
+			{"id": "smoker", "audio_file": "smoker.wav"}
+		 ]}, "transitions": {"default": "bye"}},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+
+	for _, tt := range []struct {
+		said    []string
+		score   int
+		answers string
+	}{
+		{[]string{"yes", "yes"}, 105, "[start.owner=positive start.smoker=positive start=all_positive]"},
+		{[]string{"no", "no"}, -10, "[start.owner=negative]"},
+		// A question answered again is scored by its last answer
+		{[]string{"what do you mean", "yes", "yes"}, 105, "[start.owner=positive start.smoker=positive start=all_positive]"},
+	} {
+		session := &callbackSession{MockSession: MockSession{id: "test-session"}, results: make(chan TranscriptionResult)}
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(nil)
+		if score, ok := engine.LeadScore(); !ok || score != 10 {
+			t.Errorf("score before any answer = %d, %v", score, ok)
+		}
+		done := make(chan error)
+		go func() { done <- engine.executeNode(engine.findNode("start")) }()
+		for _, said := range tt.said {
+			session.results <- TranscriptionResult{Text: said, IsFinal: true}
+		}
+		<-done
+		if score, _ := engine.LeadScore(); score != tt.score {
+			t.Errorf("%q: score %d, want %d", tt.said, score, tt.score)
+		}
+		if answers := fmt.Sprint(engine.LeadScoreAnswers()); answers != tt.answers {
+			t.Errorf("%q: answers %s, want %s", tt.said, answers, tt.answers)
+		}
+	}
+
+	if got := LeadRank(12000); got != MaxLeadRank {
+		t.Errorf("rank of 12000 = %d", got)
+	}
+	os.WriteFile(path, []byte(`{"metadata": {"lead_score": {"weights": {"start": {"positive": 1}}}}, "nodes": [{"id": "start", "type": "hangup"}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("lead_score without a field or rank should be rejected")
+	}
+	os.WriteFile(path, []byte(`{"metadata": {"lead_score": {"field": "status", "weights": {"start": {"positive": 1}}}}, "nodes": [{"id": "start", "type": "hangup"}]}`), 0644)
+	if _, err := loadFlowConfig(path); err == nil {
+		t.Error("a lead_score field overwriting the lead's status should be rejected")
+	}
+
+	// The first flow's settings score answers in chained flows by flow file
+	dir := filepath.Dir(path)
+	os.WriteFile(path, []byte(`{"metadata": {"lead_score": {"field": "ai_score", "weights": {
+		"start": {"positive": 10}, "verify.json:start": {"positive": 5}
+	}}}, "nodes": [
+		{"id": "start", "type": "question", "transitions": {"positive": "verify"}},
+		{"id": "verify", "type": "flow", "flow": "verify.json"}
+	]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "verify.json"), []byte(`{"nodes": [
+		{"id": "start", "type": "question", "transitions": {"default": "bye"}},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+	session := &callbackSession{MockSession: MockSession{id: "test-session"}, results: make(chan TranscriptionResult)}
+	engine, err := NewFlowEngine(session, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine.SetAPIClient(nil)
+	done := make(chan error)
+	go func() { done <- engine.executeNode(engine.findNode("start")) }()
+	session.results <- TranscriptionResult{Text: "yes", IsFinal: true}
+	session.results <- TranscriptionResult{Text: "yes", IsFinal: true}
+	<-done
+	if score, _ := engine.LeadScore(); score != 15 {
+		t.Errorf("score across chained flows = %d, want 15 (answers %v)", score, engine.LeadScoreAnswers())
+	}
+}
+
+func TestCondition(t *testing.T) {
+	vars := map[string]string{"age": "67", "state": "fl", "zip": "", "qualify_owner": "positive",
+		"callback_wanted": "false", "opted_in": "No", "retries": "0", "agreed": "yes"}
+	get := func(k string) (string, bool) { v, ok := vars[k]; return v, ok }
+	for _, tt := range []struct {
+		expr string
+		want bool
+	}{
+		{`age >= 65 && state == "FL"`, true},
+		{`age >= 65 && state != "FL"`, false},
+		{`age < 65 || qualify_owner == "positive"`, true},
+		{`age == 67.0`, true},
+		{`-1 < age`, true},
+		{`zip`, false},
+		{`!zip && age`, true},
+		{`missing > 0 || missing <= 0`, false}, // not a number either way
+		{`state > "a"`, false},
+		{`(age > 70 || state == "FL") == true`, true},
+		{`callback_wanted`, false}, // variables are text, so "false" must not hold
+		{`!callback_wanted`, true},
+		{`opted_in || retries`, false},
+		{`agreed && callback_wanted == false`, true},
+	} {
+		got, err := evalCondition(tt.expr, get)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+		} else if got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+	for _, expr := range []string{`age +`, `age + 1 > 65`, `len(state) == 2`, `state == 'F'`} {
+		if checkCondition(expr) == nil {
+			t.Errorf("%s should be rejected", expr)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "condition", "conditions": [
+			{"if": "age >= 65 && state == \"FL\"", "transition": "senior_fl"},
+			{"if": "age >= 65", "transition": "senior"}
+		 ], "transitions": {"senior_fl": "fl_offer", "senior": "offer", "default": "bye"}},
+		{"id": "fl_offer", "type": "hangup"},
+		{"id": "offer", "type": "hangup"},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+	for _, tt := range []struct{ age, state, want string }{
+		{"70", "FL", "fl_offer"},
+		{"70", "TX", "offer"},
+		{"40", "FL", "bye"},
+		{"", "", "bye"},
+	} {
+		session := &MockSession{id: "test-session"}
+		session.SetVar("age", tt.age)
+		session.SetVar("state", tt.state)
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(nil)
+		if err := engine.executeNode(engine.findNode("start")); err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != tt.want {
+			t.Errorf("age %q state %q: ended on %s, want %s", tt.age, tt.state, got, tt.want)
+		}
+	}
+
+	// A flag variable set to "false" takes the other branch
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "condition", "conditions": [{"if": "callback_wanted", "transition": "callback"}],
+		 "transitions": {"callback": "book", "default": "bye"}},
+		{"id": "book", "type": "hangup"},
+		{"id": "bye", "type": "hangup"}
+	]}`), 0644)
+	for flag, want := range map[string]string{"true": "book", "yes": "book", "false": "bye", "no": "bye", "0": "bye"} {
+		session := &MockSession{id: "test-session"}
+		session.SetVar("callback_wanted", flag)
+		engine, err := NewFlowEngine(session, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		engine.SetAPIClient(nil)
+		if err := engine.executeNode(engine.findNode("start")); err != nil {
+			t.Fatal(err)
+		}
+		if got := engine.GetCurrentNode().ID; got != want {
+			t.Errorf("callback_wanted %q: ended on %s, want %s", flag, got, want)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"id": "start", "type": "condition", "transitions": {"default": "start"}}`,
+		`{"id": "start", "type": "condition", "conditions": [{"if": "age > 65", "transition": "old"}], "transitions": {"default": "start"}}`,
+		`{"id": "start", "type": "condition", "conditions": [{"if": "age >", "transition": "old"}], "transitions": {"old": "start"}}`,
+		`{"id": "start", "type": "hangup", "conditions": [{"if": "age > 65", "transition": "old"}], "transitions": {"old": "start"}}`,
+		`{"id": "start", "type": "condition", "conditions": [{"if": "age > 65", "transition": "old"}], "transitions": {"old": "start"}}`,
+		`{"id": "start", "type": "condition", "conditions": [{"if": "age > 65", "transition": "old"}], "transitions": {"old": "bye", "default": "next"}},
+		 {"id": "next", "type": "condition", "conditions": [{"if": "zip", "transition": "zip"}], "transitions": {"zip": "start", "default": "bye"}},
+		 {"id": "bye", "type": "hangup"}`,
+	} {
+		os.WriteFile(path, []byte(`{"nodes": [`+bad+`]}`), 0644)
+		if _, err := loadFlowConfig(path); err == nil {
+			t.Errorf("%s should be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package flow
+
+import "time"
+
+// Hooks lets external Go code observe and influence flow execution without
+// forking the engine. Implementations are registered at server construction
+// and invoked synchronously from the flow goroutine, so they should return
+// quickly. Embed NopHooks to implement only the callbacks you need.
+type Hooks interface {
+	// OnNodeEnter is called right before a node is executed
+	OnNodeEnter(sessionID string, node *FlowNode)
+	// OnNodeExit is called when the flow leaves a node, either to move to
+	// another node or because the flow ended on it
+	OnNodeExit(sessionID string, node *FlowNode)
+	// OnClassify is called after a caller answer has been classified and may
+	// return a different classification to override the built-in result
+	OnClassify(sessionID string, node *FlowNode, text string, result ResponseType) ResponseType
+	// OnInterrupt is called when an interrupt (dnc, robot, ...) is detected
+	OnInterrupt(sessionID string, node *FlowNode, text, interruptType string)
+}
+
+// NopHooks is a Hooks implementation that does nothing
+type NopHooks struct{}
+
+func (NopHooks) OnNodeEnter(sessionID string, node *FlowNode) {}
+func (NopHooks) OnNodeExit(sessionID string, node *FlowNode)  {}
+func (NopHooks) OnClassify(sessionID string, node *FlowNode, text string, result ResponseType) ResponseType {
+	return result
+}
+func (NopHooks) OnInterrupt(sessionID string, node *FlowNode, text, interruptType string) {}
+
+// AddHooks registers plugin hooks; hooks run in registration order
+func (fe *FlowEngine) AddHooks(hooks ...Hooks) {
+	for _, h := range hooks {
+		if h != nil {
+			fe.hooks = append(fe.hooks, h)
+		}
+	}
+}
+
+// enterNode fires exit hooks for the previous node and enter hooks for node
+func (fe *FlowEngine) enterNode(node *FlowNode) {
+	fe.exitNode()
+	fe.activeNode = node
+	fe.enteredAt = time.Now()
+	for _, h := range fe.hooks {
+		h.OnNodeEnter(fe.session.GetID(), node)
+	}
+}
+
+// exitNode fires exit hooks for the node currently being executed, if any
+func (fe *FlowEngine) exitNode() {
+	node := fe.activeNode
+	if node == nil {
+		return
+	}
+	fe.activeNode = nil
+	elapsed := time.Since(fe.enteredAt)
+	fe.observeNode(node, elapsed)
+	fe.checkBudget(node, elapsed)
+	for _, h := range fe.hooks {
+		h.OnNodeExit(fe.session.GetID(), node)
+	}
+}
+
+// classify runs the response classifier and lets hooks override the result
+func (fe *FlowEngine) classify(node *FlowNode, text string) ResponseType {
+	result := fe.classifier.ClassifyResponse(text)
+	for _, h := range fe.hooks {
+		result = h.OnClassify(fe.session.GetID(), node, text, result)
+	}
+	classifications.With(fe.labelValues(node.ID, string(result))...).Inc()
+	fe.scoreAnswer(node, string(result))
+	return result
+}
+
+// notifyInterrupt counts an interrupt and fires interrupt hooks
+func (fe *FlowEngine) notifyInterrupt(node *FlowNode, text, interruptType string) {
+	interrupts.With(fe.labelValues(interruptType)...).Inc()
+	for _, h := range fe.hooks {
+		h.OnInterrupt(fe.session.GetID(), node, text, interruptType)
+	}
+}
@@ -0,0 +1,112 @@
+package flow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxLeadRank bounds the rank written to a lead; Vicidial keeps it in a
+// smallint and orders lists by it with "RANK" list orders
+const MaxLeadRank = 9999
+
+// LeadScoreSettings score a lead from the caller's answers. Each node the
+// caller answered adds the weight of its answer: the classification of a
+// question, or the outcome a survey, collect_digits or schedule_callback
+// node left by. Survey questions are scored as "<node>.<question>" and nodes
+// of chained flows as "<flow file>:<node>"; the first flow's settings score
+// the whole call. The server writes the score back to the lead when the call
+// ends, so hot leads can be called back first.
+type LeadScoreSettings struct {
+	Field   string                    `json:"field,omitempty"` // Vicidial custom field for the score
+	Rank    bool                      `json:"rank,omitempty"`  // also set the lead's rank, clamped to ±MaxLeadRank
+	Base    int                       `json:"base,omitempty"`  // score before any answer
+	Weights map[string]map[string]int `json:"weights"`         // points by node ID, then answer
+}
+
+// updateLeadParams are the parameters and standard lead fields of Vicidial's
+// update_lead; a score field named after one would overwrite it
+var updateLeadParams = map[string]bool{
+	"source": true, "user": true, "pass": true, "function": true, "lead_id": true,
+	"custom_fields": true, "search_method": true, "search_location": true, "records": true,
+	"insert_if_not_found": true, "no_update": true, "delete_lead": true, "reset_lead": true,
+	"list_exists_check": true, "update_phone_number": true, "callback": true,
+	"callback_status": true, "callback_datetime": true, "callback_type": true,
+	"callback_user": true, "callback_comments": true, "status": true, "list_id": true,
+	"vendor_lead_code": true, "source_id": true, "gmt_offset_now": true, "phone_code": true,
+	"phone_number": true, "title": true, "first_name": true, "middle_initial": true,
+	"last_name": true, "address1": true, "address2": true, "address3": true, "city": true,
+	"state": true, "province": true, "postal_code": true, "country_code": true,
+	"gender": true, "date_of_birth": true, "alt_phone": true, "email": true,
+	"security_phrase": true, "comments": true, "called_count": true, "rank": true,
+	"owner": true, "entry_list_id": true,
+}
+
+// validate checks the lead_score settings of a flow
+func (s *LeadScoreSettings) validate() error {
+	if s.Field == "" && !s.Rank {
+		return fmt.Errorf("lead_score needs a field or rank")
+	}
+	if updateLeadParams[strings.ToLower(s.Field)] {
+		return fmt.Errorf("lead_score field %q is an update_lead parameter; use a custom field", s.Field)
+	}
+	if len(s.Weights) == 0 {
+		return fmt.Errorf("lead_score has no weights")
+	}
+	return nil
+}
+
+// scoreAnswer remembers the caller's answer at node for the lead score; a
+// node answered again is scored by its last answer
+func (fe *FlowEngine) scoreAnswer(node *FlowNode, answer string) {
+	fe.scoreMu.Lock()
+	defer fe.scoreMu.Unlock()
+	if fe.answers == nil {
+		fe.answers = make(map[string]string)
+	}
+	fe.answers[fe.scorePrefix+node.ID] = answer
+}
+
+// LeadScoring returns the lead_score settings of the flow the call started
+// in, nil when it does not score leads
+func (fe *FlowEngine) LeadScoring() *LeadScoreSettings { return fe.scoring }
+
+// LeadScore returns the score of the caller's answers so far, and whether
+// the flow scores leads at all
+func (fe *FlowEngine) LeadScore() (int, bool) {
+	settings := fe.scoring
+	if settings == nil {
+		return 0, false
+	}
+	fe.scoreMu.Lock()
+	defer fe.scoreMu.Unlock()
+	score := settings.Base
+	for node, answer := range fe.answers {
+		score += settings.Weights[node][answer]
+	}
+	return score, true
+}
+
+// LeadScoreAnswers returns the answers the lead score counted, as
+// sorted "node=answer" pairs, for the session log
+func (fe *FlowEngine) LeadScoreAnswers() []string {
+	settings := fe.scoring
+	if settings == nil {
+		return nil
+	}
+	fe.scoreMu.Lock()
+	defer fe.scoreMu.Unlock()
+	var answers []string
+	for node, answer := range fe.answers {
+		if _, ok := settings.Weights[node][answer]; ok {
+			answers = append(answers, node+"="+answer)
+		}
+	}
+	sort.Strings(answers)
+	return answers
+}
+
+// LeadRank clamps a score to the range of a lead's rank
+func LeadRank(score int) int {
+	return max(-MaxLeadRank, min(MaxLeadRank, score))
+}
@@ -0,0 +1,29 @@
+package flow
+
+import "log"
+
+// answerTooLong handles a caller still talking max_answer_seconds after they
+// began answering node, keeping handle time predictable. The flow follows
+// the question's "long_winded" transition if it has one, and otherwise
+// takes what has been heard so far as the answer. The final transcript of
+// an utterance in progress is then skipped so it is not taken as the answer
+// to the next question. It reports whether the flow moved on.
+func (fe *FlowEngine) answerTooLong(node *FlowNode, heard string, inProgress bool) bool {
+	log.Printf("Caller still talking after max_answer_seconds (%ds) at node %s: %s", node.MaxAnswerSeconds, node.ID, heard)
+	if nextNode := fe.signalTarget(node, "long_winded"); nextNode != nil {
+		fe.skipFinal = inProgress
+		log.Printf("Flow transition: %s (%s) -> %s (%s) | Long-winded answer",
+			node.ID, node.Content, nextNode.ID, nextNode.Content)
+		fe.leaveQuestion(node, nextNode, "long_winded")
+		return true
+	}
+	if heard == "" {
+		return false
+	}
+	fe.skipFinal = inProgress
+	if fe.answer(node, heard) {
+		return true
+	}
+	fe.skipFinal = false
+	return false
+}
@@ -0,0 +1,53 @@
+package flow
+
+import (
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+// MetricLabels tag the engine's Prometheus metrics so dashboards can slice
+// them by flow, deployed version and campaign
+type MetricLabels struct {
+	Flow     string
+	Version  string
+	Campaign string
+}
+
+var (
+	nodeDurations   = metrics.NewHistogramVec("flow_node_duration_seconds", "Time spent in each flow node", nil, "flow", "version", "campaign", "node")
+	classifications = metrics.NewCounterVec("flow_classifications_total", "Caller answers by node and classification", "flow", "version", "campaign", "node", "classification")
+	interrupts      = metrics.NewCounterVec("flow_interrupts_total", "Interrupts detected in caller speech", "flow", "version", "campaign", "interrupt")
+	transfers       = metrics.NewCounterVec("flow_transfer_requests_total", "Vicidial transfer API calls by result, ok or error", "flow", "version", "campaign", "result")
+	flowErrors      = metrics.NewCounterVec("flow_errors_total", "Unrecoverable flow errors handled by the error_fallback node", "flow", "version", "campaign", "node")
+	extractions     = metrics.NewCounterVec("flow_extractions_total", "Answers to questions with extract rules by node and outcome, extracted or no_match", "flow", "version", "campaign", "node", "outcome")
+)
+
+// SetMetricLabels sets the labels of the engine's metrics. Until called,
+// flow and version come from the flow's metadata and campaign is empty.
+func (fe *FlowEngine) SetMetricLabels(labels MetricLabels) {
+	fe.labels = labels
+}
+
+// MetricLabels returns the labels of the engine's metrics
+func (fe *FlowEngine) MetricLabels() MetricLabels { return fe.labels }
+
+// labelValues returns the flow, version and campaign label values followed
+// by extra
+func (fe *FlowEngine) labelValues(extra ...string) []string {
+	return append([]string{fe.labels.Flow, fe.labels.Version, fe.labels.Campaign}, extra...)
+}
+
+// observeNode records the time spent in a node
+func (fe *FlowEngine) observeNode(node *FlowNode, elapsed time.Duration) {
+	nodeDurations.With(fe.labelValues(node.ID)...).Observe(elapsed.Seconds())
+}
+
+// countTransfer records the result of a transfer API call
+func (fe *FlowEngine) countTransfer(err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	transfers.With(fe.labelValues(result)...).Inc()
+}
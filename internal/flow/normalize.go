@@ -0,0 +1,87 @@
+package flow
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/spoken"
+)
+
+// zeroWords are the words callers use for zero besides "zero"
+var zeroWords = map[string]bool{"oh": true, "o": true, "nought": true}
+
+// spokenRepeats maps words repeating the next digit ("double five")
+var spokenRepeats = map[string]int{"double": 2, "triple": 3, "quadruple": 4}
+
+// NormalizeDigits converts a spoken digit sequence as transcribed by ASR into
+// a digit string, e.g. "five five five oh one double two" -> "5550122".
+// Numerals ("555-0122") and spoken numbers ("nineteen", "forty two",
+// "five hundred two" -> "502") are understood, read the way the transcriber
+// writes them. Other words are skipped so "my number is ..." works; the
+// result is empty if no digits were spoken.
+func NormalizeDigits(text string) string {
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '-' || r == ','
+	})
+
+	for i, tok := range tokens {
+		tokens[i] = strings.Trim(tok, ".?!;:\"'()")
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(tokens); i++ {
+		tok, prev, next := tokens[i], "", ""
+		if i > 0 {
+			prev = tokens[i-1]
+		}
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+
+		switch {
+		case (tok == "oh" || tok == "o") && !isNumberWord(prev) && !isNumberWord(next):
+			// "oh, it's five five..." - only zero between other digits
+		case zeroWords[tok]:
+			sb.WriteString("0")
+		case spokenRepeats[tok] > 0:
+			if d := digitToken(next); d != "" {
+				sb.WriteString(strings.Repeat(d, spokenRepeats[tok]))
+				i++
+			}
+		case spoken.IsNumberWord(tok):
+			// One number, "forty two" or "five hundred two"; single digits
+			// in a row are separate numbers, so "five five" is 55
+			n, used := spoken.Parse(tokens[i:])
+			sb.WriteString(strconv.Itoa(n.Value()))
+			i += used - 1
+		default:
+			for _, r := range tok {
+				if r >= '0' && r <= '9' {
+					sb.WriteRune(r)
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+// isNumberWord reports whether tok is part of a spoken number
+func isNumberWord(tok string) bool {
+	return digitToken(tok) != "" || spoken.IsNumberWord(tok) ||
+		spokenRepeats[tok] > 0 || strings.ContainsAny(tok, "0123456789")
+}
+
+// digitToken returns the digit a single-digit word or numeral stands for
+func digitToken(tok string) string {
+	if zeroWords[tok] {
+		return "0"
+	}
+	if d, ok := spoken.Digit(tok); ok {
+		return strconv.Itoa(d)
+	}
+	if len(tok) == 1 && tok[0] >= '0' && tok[0] <= '9' {
+		return tok
+	}
+	return ""
+}
@@ -0,0 +1,135 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/notify"
+)
+
+// defaultRecipients are the session variables a notify action sends to when
+// it names no "to"
+var defaultRecipients = map[string]string{
+	notify.SMS:   "${phone}",
+	notify.Email: "${email}",
+}
+
+// SetNotifier sets the sender of notify actions on channel (notify.SMS or
+// notify.Email)
+func (fe *FlowEngine) SetNotifier(channel string, sender notify.Sender) {
+	if fe.notifiers == nil {
+		fe.notifiers = make(map[string]notify.Sender)
+	}
+	fe.notifiers[channel] = sender
+}
+
+// executeNotify sends the SMS, email or webhook of a "notify" action. Its
+// params:
+//
+//	channel   "sms", "email" or "webhook"
+//	to        recipient, default ${phone} for sms and ${email} for email;
+//	          the endpoint URL for webhook
+//	subject   email subject
+//	body      message text; JSON for webhook
+//
+// "to", "subject" and "body" are templates: ${name} is replaced with the
+// session variable name, or with the call's phone, lead_id, session_id,
+// node (the node being run) or disposition. In webhook bodies values are
+// escaped for use inside JSON strings.
+func (fe *FlowEngine) executeNotify(action Action) error {
+	channel := action.Params["channel"]
+	sender := fe.notifiers[channel]
+	if sender == nil {
+		return fmt.Errorf("no %s notifier configured", channel)
+	}
+	to := action.Params["to"]
+	if to == "" {
+		to = defaultRecipients[channel]
+	}
+	body := fe.templateVar
+	if channel == notify.Webhook {
+		body = func(name string) string {
+			quoted, _ := json.Marshal(fe.templateVar(name))
+			return string(quoted[1 : len(quoted)-1])
+		}
+	}
+	msg := notify.Message{
+		To:      notify.Expand(to, fe.templateVar),
+		Subject: notify.Expand(action.Params["subject"], fe.templateVar),
+		Body:    notify.Expand(action.Params["body"], body),
+	}
+	if msg.To == "" {
+		return fmt.Errorf("notify %s action has no recipient", channel)
+	}
+
+	err := sender.Send(msg)
+	if err == nil {
+		log.Printf("Session %s: Sent %s to %s", fe.session.GetID(), channel, msg.To)
+	}
+	if fe.logger != nil {
+		details := map[string]string{"to": msg.To}
+		if err != nil {
+			details["error"] = err.Error()
+		}
+		fe.logger.LogAPICallDetails(fe.session.GetID(), "notify:"+channel, map[bool]string{true: "ok", false: "error"}[err == nil], details)
+	}
+	return err
+}
+
+// templateVar resolves ${name} in notify templates
+func (fe *FlowEngine) templateVar(name string) string {
+	if v, ok := fe.session.GetVar(name); ok {
+		return v
+	}
+	switch name {
+	case "phone":
+		return fe.startPhone
+	case "lead_id":
+		return fe.startLeadID
+	case "session_id":
+		return fe.session.GetID()
+	case "node":
+		if fe.activeNode != nil {
+			return fe.activeNode.ID
+		}
+	case "disposition":
+		return fe.GetLastReason()
+	}
+	return ""
+}
+
+// validateNotify checks the params of a notify action
+func validateNotify(action Action) error {
+	switch channel := action.Params["channel"]; channel {
+	case notify.SMS, notify.Email:
+	case notify.Webhook:
+		if action.Params["to"] == "" {
+			return fmt.Errorf("notify webhook action has no URL in to")
+		}
+	default:
+		return fmt.Errorf("notify action channel %q must be sms, email or webhook", channel)
+	}
+	if action.Params["body"] == "" {
+		return fmt.Errorf("notify action has no body")
+	}
+	return nil
+}
+
+// notifyOnEnter sends the notify actions of a node whose type runs no
+// actions of its own (audio, question, collect_digits, schedule_callback)
+// as the node is entered, so any node can report to automation endpoints
+func (fe *FlowEngine) notifyOnEnter(node *FlowNode) {
+	switch node.Type {
+	case "transfer", "hangup", "interrupt":
+		return // executeActions sends them with the node's other actions
+	}
+	for _, action := range node.Actions {
+		if action.Type != "notify" {
+			continue
+		}
+		if err := fe.executeNotify(action); err != nil {
+			log.Printf("Warning: notify action failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package flow
+
+import "time"
+
+// defaultMinPartialLen is how many characters a partial transcript must
+// exceed to count as the caller still answering; shorter partials are
+// often noise or a breath
+const defaultMinPartialLen = 10
+
+// partialHeard keeps the response timer of question node running while the
+// caller is still answering. By default a partial longer than 10 characters
+// restarts the full timeout; reset_on_partial, min_partial_len and extend_by
+// let a flow be more patient with slow talkers on one question and stricter
+// on another.
+func (fe *FlowEngine) partialHeard(node *FlowNode, text string) {
+	if !fe.timer.IsActive() || (node.ResetOnPartial != nil && !*node.ResetOnPartial) {
+		return
+	}
+	minLen := node.MinPartialLen
+	if minLen == 0 {
+		minLen = defaultMinPartialLen
+	}
+	if len(text) <= minLen {
+		return
+	}
+	if node.ExtendBy > 0 {
+		fe.timer.Extend(time.Duration(node.ExtendBy) * time.Millisecond)
+		return
+	}
+	fe.timer.Reset()
+}
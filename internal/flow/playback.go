@@ -0,0 +1,104 @@
+package flow
+
+import (
+	"log"
+	"sync"
+)
+
+// PlaybackID identifies one prompt a PlaybackSession plays
+type PlaybackID uint64
+
+// PlaybackSession is implemented by sessions that identify each prompt they
+// play, so the engine stops exactly the prompts it started. The engine
+// reserves a playback with NewPlayback before the goroutine that plays it
+// starts, so StopPlayback never misses a prompt that has not begun yet and
+// never cancels one started after it; a stopped playback that has not begun
+// returns at once. PlayAudioAs plays filename as the reserved playback,
+// stopped as soon as the caller speaks if bargeIn is set and the session can
+// hear it. StopAll stops every prompt of the session, whoever started it.
+type PlaybackSession interface {
+	NewPlayback() PlaybackID
+	PlayAudioAs(id PlaybackID, filename string, bargeIn bool) error
+	StopPlayback(id PlaybackID) error
+	StopAll() error
+}
+
+// playbacks tracks the prompts the engine started that have not finished
+type playbacks struct {
+	mu  sync.Mutex
+	ids map[PlaybackID]bool
+}
+
+func (p *playbacks) add(id PlaybackID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ids == nil {
+		p.ids = make(map[PlaybackID]bool)
+	}
+	p.ids[id] = true
+}
+
+func (p *playbacks) remove(id PlaybackID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.ids, id)
+}
+
+// take returns the tracked playbacks and forgets them
+func (p *playbacks) take() []PlaybackID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]PlaybackID, 0, len(p.ids))
+	for id := range p.ids {
+		ids = append(ids, id)
+	}
+	clear(p.ids)
+	return ids
+}
+
+// startAudio reserves a playback of file and returns the function that
+// plays it, to call in the goroutine that should wait for it. Stopping the
+// engine's audio before then skips it.
+func (fe *FlowEngine) startAudio(file string, bargeIn bool) func() error {
+	ps, ok := fe.session.(PlaybackSession)
+	if !ok {
+		return func() error {
+			if bs, ok := fe.session.(BargeInSession); ok && bargeIn {
+				return bs.PlayAudioBargeIn(file)
+			}
+			return fe.session.PlayAudio(file)
+		}
+	}
+	id := ps.NewPlayback()
+	fe.playing.add(id)
+	return func() error {
+		defer fe.playing.remove(id)
+		return ps.PlayAudioAs(id, file, bargeIn)
+	}
+}
+
+// startPrompt is startAudio for one of node's prompts, interruptible by
+// caller speech if the node allows barge-in
+func (fe *FlowEngine) startPrompt(node *FlowNode, file string) func() error {
+	return fe.startAudio(file, fe.bargeIn(node))
+}
+
+// playAudio plays file and waits for it to finish or be stopped
+func (fe *FlowEngine) playAudio(file string) error {
+	return fe.startAudio(file, false)()
+}
+
+// stopAudio stops the prompts the engine started, playing or about to. A
+// session without playback IDs stops whatever plays.
+func (fe *FlowEngine) stopAudio() error {
+	ps, ok := fe.session.(PlaybackSession)
+	if !ok {
+		return fe.session.StopAudio()
+	}
+	for _, id := range fe.playing.take() {
+		if err := ps.StopPlayback(id); err != nil {
+			log.Printf("Warning: Failed to stop playback %d: %v", id, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package flow
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// PromptReferences returns the audio files the flow at configPath, and the
+// flows it chains to, play, with the nodes playing each. Nodes of chained
+// flows are prefixed with their flow file, e.g. "verify.json:start". The
+// number and date prompts of SayPrompts are included, as are the flow's
+// ambient bed and room tone, listed as played by "metadata.ambient" and
+// "metadata.comfort_noise".
+func PromptReferences(configPath string) (map[string][]string, error) {
+	root, chain, err := loadWithChain(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string][]string)
+	for path, cf := range chain {
+		for _, node := range cf.config.Nodes {
+			where := nodeLabel(root, path, node.ID)
+			for _, file := range node.AudioFiles() {
+				refs[file] = append(refs[file], where)
+			}
+			if node.RetryAudio != "" {
+				refs[node.RetryAudio] = append(refs[node.RetryAudio], where)
+			}
+			if node.Survey != nil {
+				for _, q := range node.Survey.Questions {
+					refs[q.AudioFile] = append(refs[q.AudioFile], where+"."+q.ID)
+				}
+				if file := node.Survey.RetryAudio; file != "" {
+					refs[file] = append(refs[file], where)
+				}
+			}
+			if node.Callback != nil {
+				for _, file := range []string{node.Callback.ReadbackAudio, node.Callback.ConfirmAudio} {
+					if file != "" {
+						refs[file] = append(refs[file], where)
+					}
+				}
+			}
+		}
+	}
+	for file, nodes := range sayPrompts(root, chain) {
+		refs[file] = append(refs[file], nodes...)
+	}
+	// Only the first flow's metadata applies to the call
+	meta := chain[root].config.Metadata
+	if meta.Ambient != nil && meta.Ambient.Enabled && meta.Ambient.File != "" {
+		refs[meta.Ambient.File] = append(refs[meta.Ambient.File], "metadata.ambient")
+	}
+	if meta.ComfortNoise != nil && meta.ComfortNoise.Enabled && meta.ComfortNoise.RoomTone != "" {
+		refs[meta.ComfortNoise.RoomTone] = append(refs[meta.ComfortNoise.RoomTone], "metadata.comfort_noise")
+	}
+	for _, nodes := range refs {
+		sort.Strings(nodes)
+	}
+	return refs, nil
+}
+
+// SayPrompts returns the number and date prompts the schedule_callback nodes
+// of the flow at configPath, and the flows it chains to, read times back
+// with, with the nodes playing each. They are loaded from their sounds_dir
+// rather than the audio directory itself.
+func SayPrompts(configPath string) (map[string][]string, error) {
+	root, chain, err := loadWithChain(configPath)
+	if err != nil {
+		return nil, err
+	}
+	refs := sayPrompts(root, chain)
+	for _, nodes := range refs {
+		sort.Strings(nodes)
+	}
+	return refs, nil
+}
+
+// sayPrompts returns the number and date prompts of the schedule_callback
+// nodes in chain, with the nodes playing each
+func sayPrompts(root string, chain map[string]*chainedFlow) map[string][]string {
+	refs := make(map[string][]string)
+	for path, cf := range chain {
+		for _, node := range cf.config.Nodes {
+			if node.Type != "schedule_callback" {
+				continue
+			}
+			where := nodeLabel(root, path, node.ID)
+			for _, file := range (Sayer{Dir: node.callbackSettings().SoundsDir}).DateTimePrompts() {
+				refs[file] = append(refs[file], where)
+			}
+		}
+	}
+	return refs
+}
+
+// nodeLabel names a node of the flow at path, prefixed with its flow file
+// unless it belongs to the root flow
+func nodeLabel(root, path, id string) string {
+	if path == root {
+		return id
+	}
+	return fmt.Sprintf("%s:%s", filepath.Base(path), id)
+}
+
+// TTSPrompt names the audio a node's tts_text plays as. The name depends on
+// the text only, so nodes saying the same thing share one synthesis.
+func TTSPrompt(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return fmt.Sprintf("tts_%x.wav", sum[:8])
+}
+
+// TTSTexts returns the tts_text of the nodes in the flow at configPath, and
+// the flows it chains to, by the prompt name each plays as
+func TTSTexts(configPath string) (map[string]string, error) {
+	_, chain, err := loadWithChain(configPath)
+	if err != nil {
+		return nil, err
+	}
+	texts := make(map[string]string)
+	for _, cf := range chain {
+		for _, node := range cf.config.Nodes {
+			if node.TTSText != "" {
+				texts[node.AudioFile] = node.TTSText
+			}
+		}
+	}
+	return texts, nil
+}
+
+// loadWithChain loads the flow at configPath and the flows it chains to, by
+// path; root is the path of the flow itself
+func loadWithChain(configPath string) (string, map[string]*chainedFlow, error) {
+	config, err := loadFlowConfig(configPath)
+	if err != nil {
+		return "", nil, err
+	}
+	root := chainPath(filepath.Dir(configPath), filepath.Base(configPath))
+	chain := map[string]*chainedFlow{root: {config: config}}
+	if err := loadChain(config, filepath.Dir(configPath), chain); err != nil {
+		return "", nil, err
+	}
+	return root, chain, nil
+}
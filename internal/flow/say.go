@@ -0,0 +1,110 @@
+package flow
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// DefaultSoundsDir holds the number and date prompts SayNumber and
+// SayDateTime build sentences from. The prompts follow the naming of the
+// Asterisk core sounds: digits/1 to digits/20, digits/30 to digits/90,
+// digits/oh, digits/oclock, digits/a-m, digits/p-m, digits/at,
+// digits/today, digits/tomorrow, digits/day-0 (Sunday) to digits/day-6,
+// digits/mon-0 (January) to digits/mon-11 and digits/h-1 to digits/h-31
+// for ordinal days of the month, as .wav files.
+const DefaultSoundsDir = "digits"
+
+// Sayer builds lists of prompt files that read out numbers and times
+type Sayer struct {
+	Dir string // directory of the prompts, DefaultSoundsDir if empty
+}
+
+func (s Sayer) file(name string) string {
+	dir := s.Dir
+	if dir == "" {
+		dir = DefaultSoundsDir
+	}
+	return filepath.Join(dir, name+".wav")
+}
+
+// DateTimePrompts returns every prompt SayDateTime may play
+func (s Sayer) DateTimePrompts() []string {
+	names := []string{"oh", "oclock", "a-m", "p-m", "at", "today", "tomorrow"}
+	for n := 1; n <= 20; n++ {
+		names = append(names, fmt.Sprint(n))
+	}
+	for n := 30; n <= 50; n += 10 {
+		names = append(names, fmt.Sprint(n))
+	}
+	for d := 0; d < 7; d++ {
+		names = append(names, fmt.Sprintf("day-%d", d))
+	}
+	for m := 0; m < 12; m++ {
+		names = append(names, fmt.Sprintf("mon-%d", m))
+	}
+	for d := 1; d <= 31; d++ {
+		names = append(names, fmt.Sprintf("h-%d", d))
+	}
+	files := make([]string, len(names))
+	for i, name := range names {
+		files[i] = s.file(name)
+	}
+	return files
+}
+
+// SayNumber returns the prompts reading out n, from 0 to 99
+func (s Sayer) SayNumber(n int) []string {
+	switch {
+	case n < 0 || n > 99:
+		return nil
+	case n <= 20 || n%10 == 0:
+		return []string{s.file(fmt.Sprint(n))}
+	}
+	return []string{s.file(fmt.Sprint(n - n%10)), s.file(fmt.Sprint(n % 10))}
+}
+
+// SayClock returns the prompts reading out the time of day on a 12 hour
+// clock: "three o'clock p m", "nine oh five a m", "four thirty p m"
+func (s Sayer) SayClock(t time.Time) []string {
+	hour := t.Hour() % 12
+	if hour == 0 {
+		hour = 12
+	}
+	files := s.SayNumber(hour)
+	switch m := t.Minute(); {
+	case m == 0:
+		files = append(files, s.file("oclock"))
+	case m < 10:
+		files = append(append(files, s.file("oh")), s.SayNumber(m)...)
+	default:
+		files = append(files, s.SayNumber(m)...)
+	}
+	if t.Hour() < 12 {
+		return append(files, s.file("a-m"))
+	}
+	return append(files, s.file("p-m"))
+}
+
+// SayDateTime returns the prompts reading out t as heard by someone at now:
+// "today", "tomorrow" or the weekday within the coming week and the day
+// and month beyond it, then "at" and the time
+func (s Sayer) SayDateTime(t, now time.Time) []string {
+	var files []string
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, t.Location())
+	switch days := int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Sub(today).Hours() / 24); {
+	case days == 0:
+		files = append(files, s.file("today"))
+	case days == 1:
+		files = append(files, s.file("tomorrow"))
+	case days > 1 && days < 7:
+		files = append(files, s.file(fmt.Sprintf("day-%d", t.Weekday())))
+	default:
+		files = append(files,
+			s.file(fmt.Sprintf("day-%d", t.Weekday())),
+			s.file(fmt.Sprintf("mon-%d", t.Month()-1)),
+			s.file(fmt.Sprintf("h-%d", t.Day())))
+	}
+	files = append(files, s.file("at"))
+	return append(files, s.SayClock(t)...)
+}
@@ -0,0 +1,226 @@
+package flow
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// defaultScriptTimeout bounds how long a script action may run
+const defaultScriptTimeout = 5 * time.Second
+
+// scriptFileOptions allows top-level if/for/while so short campaign scripts
+// don't need to be wrapped in a function
+var scriptFileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+// executeScript runs a Starlark "script" action. The script can be inline
+// (action.Script) or a file shipped next to the flow (action.ScriptFile).
+//
+// Scripts get the following builtins:
+//
+//	session_id                  the current session ID
+//	get_var(key, default="")    read a session variable
+//	set_var(key, value)         write a session variable
+//	set_reason(status)          set the disposition used at hangup (e.g. "NI")
+//	http_get(url, params={})    GET request, returns {"status": int, "body": str}
+//	http_post(url, body, content_type="application/json")
+//	log(msg)                    write to the server log
+//	random()                    float in [0, 1) from the session seed
+//	choice(seq)                 element of a list or tuple picked from the session seed
+func (fe *FlowEngine) executeScript(action Action) error {
+	src := action.Script
+	name := "inline.star"
+	if action.ScriptFile != "" {
+		path := action.ScriptFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(fe.configDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read script %s: %w", action.ScriptFile, err)
+		}
+		src = string(data)
+		name = filepath.Base(path)
+	}
+	if strings.TrimSpace(src) == "" {
+		return fmt.Errorf("script action has no script or script_file")
+	}
+
+	timeout := defaultScriptTimeout
+	if action.Timeout > 0 {
+		timeout = time.Duration(action.Timeout) * time.Second
+	}
+
+	thread := &starlark.Thread{
+		Name:  "flow-script:" + fe.session.GetID(),
+		Print: func(_ *starlark.Thread, msg string) { log.Printf("Script %s: %s", name, msg) },
+	}
+	timer := time.AfterFunc(timeout, func() { thread.Cancel("script timeout") })
+	defer timer.Stop()
+
+	start := time.Now()
+	_, err := starlark.ExecFileOptions(scriptFileOptions, thread, name, src, fe.scriptBuiltins(name, timeout))
+	status := "ok"
+	details := map[string]string{"duration_ms": fmt.Sprintf("%d", time.Since(start).Milliseconds())}
+	if err != nil {
+		status = "error"
+		details["error"] = err.Error()
+	}
+	if fe.logger != nil {
+		fe.logger.LogAPICallDetails(fe.session.GetID(), "script:"+name, status, details)
+	}
+	if err != nil {
+		return fmt.Errorf("script %s failed: %w", name, err)
+	}
+	return nil
+}
+
+// scriptBuiltins builds the predeclared environment for a script
+func (fe *FlowEngine) scriptBuiltins(name string, timeout time.Duration) starlark.StringDict {
+	httpClient := &http.Client{Timeout: timeout}
+
+	getVar := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var key, def string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "key", &key, "default?", &def); err != nil {
+			return nil, err
+		}
+		if v, ok := fe.session.GetVar(key); ok {
+			return starlark.String(v), nil
+		}
+		return starlark.String(def), nil
+	}
+
+	setVar := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var key string
+		var value starlark.Value
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "key", &key, "value", &value); err != nil {
+			return nil, err
+		}
+		if s, ok := starlark.AsString(value); ok {
+			fe.session.SetVar(key, s)
+		} else {
+			fe.session.SetVar(key, value.String())
+		}
+		return starlark.None, nil
+	}
+
+	setReason := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var status string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "status", &status); err != nil {
+			return nil, err
+		}
+		fe.lastReason.Store(status)
+		return starlark.None, nil
+	}
+
+	httpGet := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var rawURL string
+		var params *starlark.Dict
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &rawURL, "params?", &params); err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("http_get: failed to parse URL: %w", err)
+		}
+		if params != nil {
+			q := u.Query()
+			for _, item := range params.Items() {
+				k, _ := starlark.AsString(item[0])
+				v, ok := starlark.AsString(item[1])
+				if !ok {
+					v = item[1].String()
+				}
+				q.Set(k, v)
+			}
+			u.RawQuery = q.Encode()
+		}
+		resp, err := httpClient.Get(u.String())
+		if err != nil {
+			return nil, fmt.Errorf("http_get: request failed: %w", err)
+		}
+		return scriptResponse(resp)
+	}
+
+	httpPost := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var rawURL, body string
+		contentType := "application/json"
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &rawURL, "body", &body, "content_type?", &contentType); err != nil {
+			return nil, err
+		}
+		resp, err := httpClient.Post(rawURL, contentType, strings.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("http_post: request failed: %w", err)
+		}
+		return scriptResponse(resp)
+	}
+
+	logFn := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var msg string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "msg", &msg); err != nil {
+			return nil, err
+		}
+		log.Printf("Session %s: Script %s: %s", fe.session.GetID(), name, msg)
+		return starlark.None, nil
+	}
+
+	// Drawn from the session seed so a replayed call makes the same choices
+	random := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs); err != nil {
+			return nil, err
+		}
+		fe.rngMu.Lock()
+		defer fe.rngMu.Unlock()
+		return starlark.Float(fe.scriptRand.Float64()), nil
+	}
+
+	choice := func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var seq starlark.Indexable
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "seq", &seq); err != nil {
+			return nil, err
+		}
+		if seq.Len() == 0 {
+			return nil, fmt.Errorf("choice: empty sequence")
+		}
+		return seq.Index(fe.draw(fe.scriptRand, seq.Len())), nil
+	}
+
+	return starlark.StringDict{
+		"session_id": starlark.String(fe.session.GetID()),
+		"get_var":    starlark.NewBuiltin("get_var", getVar),
+		"set_var":    starlark.NewBuiltin("set_var", setVar),
+		"set_reason": starlark.NewBuiltin("set_reason", setReason),
+		"http_get":   starlark.NewBuiltin("http_get", httpGet),
+		"http_post":  starlark.NewBuiltin("http_post", httpPost),
+		"log":        starlark.NewBuiltin("log", logFn),
+		"random":     starlark.NewBuiltin("random", random),
+		"choice":     starlark.NewBuiltin("choice", choice),
+	}
+}
+
+// scriptResponse converts an HTTP response into a Starlark dict
+func scriptResponse(resp *http.Response) (starlark.Value, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	result := starlark.NewDict(2)
+	_ = result.SetKey(starlark.String("status"), starlark.MakeInt(resp.StatusCode))
+	_ = result.SetKey(starlark.String("body"), starlark.String(body))
+	return result, nil
+}
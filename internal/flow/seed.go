@@ -0,0 +1,61 @@
+package flow
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// Randomized behavior draws from streams derived from one seed per session.
+// The seed is written to the session log, so a call's choices can be
+// replayed exactly, e.g. in the self-test. Each purpose gets its own stream
+// so a new random draw for one does not shift the others.
+const (
+	RandVariants     = "variants"      // prompt variant per node visit
+	RandScript       = "script"        // random() and choice() in scripts
+	RandDebug        = "debug"         // debug capture sampling
+	RandComfortNoise = "comfort_noise" // generated comfort noise
+)
+
+// NewRand returns the random stream for purpose derived from seed
+func NewRand(seed int64, purpose string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(purpose))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
+
+// SetSeed derives the engine's random streams from the session seed
+func (fe *FlowEngine) SetSeed(seed int64) {
+	fe.rngMu.Lock()
+	defer fe.rngMu.Unlock()
+	fe.seed = seed
+	fe.variantRand = NewRand(seed, RandVariants)
+	fe.scriptRand = NewRand(seed, RandScript)
+}
+
+// Seed returns the session seed the engine's random choices derive from
+func (fe *FlowEngine) Seed() int64 {
+	fe.rngMu.Lock()
+	defer fe.rngMu.Unlock()
+	return fe.seed
+}
+
+// draw returns a number in [0, n) from one of the engine's streams
+func (fe *FlowEngine) draw(r *rand.Rand, n int) int {
+	fe.rngMu.Lock()
+	defer fe.rngMu.Unlock()
+	return r.Intn(n)
+}
+
+// promptFile returns the prompt to play for a node visit: AudioFile, or
+// one of it and its variants picked from the session's variant stream
+func (fe *FlowEngine) promptFile(node *FlowNode) string {
+	if len(node.Variants) == 0 {
+		return node.PromptFile()
+	}
+	files := node.AudioFiles()
+	file := files[fe.draw(fe.variantRand, len(files))]
+	if fe.logger != nil {
+		fe.logger.LogVariant(fe.session.GetID(), node, file)
+	}
+	return node.PromptFor(file)
+}
@@ -8,12 +8,16 @@ import (
     "strings"
     "sync"
     "time"
+
+    "github.com/amanullahtanweer/audiosocket-transcriber/internal/buildinfo"
+    "github.com/amanullahtanweer/audiosocket-transcriber/internal/qa"
 )
 
 // SessionLogger writes structured JSONL session logs to a file
 type SessionLogger struct {
     mu   sync.Mutex
     file *os.File
+    path string
 }
 
 type logRecord struct {
@@ -47,7 +51,16 @@ func NewSessionLogger(outputDir, sessionID string, started time.Time) (*SessionL
     if err != nil {
         return nil, err
     }
-    return &SessionLogger{file: f}, nil
+    sl := &SessionLogger{file: f, path: filename}
+    // Header: the build that handled the call
+    build := buildinfo.Get()
+    sl.write(logRecord{Timestamp: started.Format(time.RFC3339Nano), Event: "build", SessionID: sessionID, Details: map[string]string{
+        "version":    build.Version,
+        "commit":     build.Commit,
+        "build_date": build.Date,
+        "go_version": build.GoVersion,
+    }})
+    return sl, nil
 }
 
 func (sl *SessionLogger) Close() error {
@@ -61,6 +74,22 @@ func (sl *SessionLogger) Close() error {
     return nil
 }
 
+// Reopen reopens a closed log to append records that arrive after the flow
+// ended, such as the outcome of a transfer. Close it again when done.
+func (sl *SessionLogger) Reopen() error {
+    sl.mu.Lock()
+    defer sl.mu.Unlock()
+    if sl.file != nil {
+        return nil
+    }
+    f, err := os.OpenFile(sl.path, os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return err
+    }
+    sl.file = f
+    return nil
+}
+
 func (sl *SessionLogger) write(rec logRecord) {
     sl.mu.Lock()
     defer sl.mu.Unlock()
@@ -77,6 +106,28 @@ func (sl *SessionLogger) LogFlowStart(sessionID, name, version string, started t
     sl.write(logRecord{Timestamp: started.Format(time.RFC3339Nano), Event: "flow_start", SessionID: sessionID, Details: map[string]string{"name": name, "version": version}})
 }
 
+// LogFlowVersion records which deployed flow version the session runs
+func (sl *SessionLogger) LogFlowVersion(sessionID, campaign, path, version string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "flow_version", SessionID: sessionID, Details: map[string]string{"campaign": campaign, "path": path, "version": version}})
+}
+
+// LogCaller records the phone number and lead the call is with, and where
+// the number came from
+func (sl *SessionLogger) LogCaller(sessionID, phone, leadID, source string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "caller", SessionID: sessionID, Details: map[string]string{"phone": phone, "lead_id": leadID, "source": source}})
+}
+
+// LogFlowError records an error that stopped the flow at a node
+func (sl *SessionLogger) LogFlowError(sessionID, nodeID string, err error) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "flow_error", SessionID: sessionID, NodeID: nodeID, Details: map[string]string{"error": err.Error()}})
+}
+
+// LogFlowChain records a flow node handing the call to a follow-on flow;
+// the follow-on flow's records follow in the same log
+func (sl *SessionLogger) LogFlowChain(sessionID string, node *FlowNode, file, name, version string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "flow_chain", SessionID: sessionID, NodeID: node.ID, NodeType: node.Type, NodeContent: node.Content, Details: map[string]string{"file": file, "name": name, "version": version}})
+}
+
 func (sl *SessionLogger) LogFlowEnd(sessionID string, ended time.Time, reason string) {
     sl.write(logRecord{Timestamp: ended.Format(time.RFC3339Nano), Event: "flow_end", SessionID: sessionID, Details: map[string]string{"reason": reason}})
 }
@@ -101,6 +152,114 @@ func (sl *SessionLogger) LogTransition(sessionID string, from, to *FlowNode, rea
     sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "transition", SessionID: sessionID, NodeID: from.ID, NodeType: from.Type, NodeContent: from.Content, NextNodeID: toID, Details: map[string]string{"reason": reason}})
 }
 
+// LogDigits records the outcome of a collect_digits node; digits are already
+// masked if the node asks for it
+func (sl *SessionLogger) LogDigits(sessionID string, node *FlowNode, digits, outcome string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "digits", SessionID: sessionID, NodeID: node.ID, NodeType: node.Type, NodeContent: node.Content, Text: digits, Details: map[string]string{"outcome": outcome}})
+}
+
+// LogExtract records a value an extract rule found in the caller's answer
+func (sl *SessionLogger) LogExtract(sessionID string, node *FlowNode, variable, value string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "extract", SessionID: sessionID, NodeID: node.ID, NodeType: node.Type, NodeContent: node.Content, Text: value, Details: map[string]string{"variable": variable}})
+}
+
+// LogCallback records the outcome of a schedule_callback node, the time
+// agreed and the calendar event booked for it, empty if none
+func (sl *SessionLogger) LogCallback(sessionID string, node *FlowNode, when, outcome, eventID string) {
+    details := map[string]string{"outcome": outcome}
+    if eventID != "" {
+        details["event_id"] = eventID
+    }
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "callback", SessionID: sessionID, NodeID: node.ID, NodeType: node.Type, NodeContent: node.Content, Text: when, Details: details})
+}
+
+// LogOverBudget records a node that took longer than its budget_ms
+func (sl *SessionLogger) LogOverBudget(sessionID string, node *FlowNode, elapsed, budget time.Duration) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "over_budget", SessionID: sessionID, NodeID: node.ID, NodeType: node.Type, NodeContent: node.Content, Details: map[string]string{
+        "elapsed_ms": fmt.Sprint(elapsed.Milliseconds()),
+        "budget_ms":  fmt.Sprint(budget.Milliseconds()),
+    }})
+}
+
+// LogEscalation records the caller becoming agitated or shouting
+func (sl *SessionLogger) LogEscalation(sessionID, level string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "escalation", SessionID: sessionID, Details: map[string]string{"level": level}})
+}
+
+// LogBackground records the classified environment of the caller
+func (sl *SessionLogger) LogBackground(sessionID, environment string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "background", SessionID: sessionID, Details: map[string]string{"environment": environment}})
+}
+
+// LogMachine records whether a person or hold music or an IVR answered
+func (sl *SessionLogger) LogMachine(sessionID, kind string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "machine", SessionID: sessionID, Details: map[string]string{"kind": kind}})
+}
+
+// LogTone records a signaling tone heard on the caller side
+func (sl *SessionLogger) LogTone(sessionID, tone string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "tone", SessionID: sessionID, Details: map[string]string{"tone": tone}})
+}
+
+// LogBargeIn records the caller speaking over a prompt, which was stopped
+func (sl *SessionLogger) LogBargeIn(sessionID, prompt string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "barge_in", SessionID: sessionID, Details: map[string]string{"prompt": prompt}})
+}
+
+// LogQA records the call's QA score and the rubric checks it failed, as a
+// JSON list
+func (sl *SessionLogger) LogQA(sessionID string, result qa.Result) {
+    violations, _ := json.Marshal(result.Violations)
+    if result.Violations == nil {
+        violations = []byte("[]")
+    }
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "qa", SessionID: sessionID, Details: map[string]string{
+        "score":      fmt.Sprint(result.Score),
+        "violations": string(violations),
+    }})
+}
+
+// LogMonitor records a supervisor starting or stopping to listen to the call
+func (sl *SessionLogger) LogMonitor(sessionID, listener, action string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "monitor", SessionID: sessionID, Details: map[string]string{"listener": listener, "action": action}})
+}
+
+// LogTransferOutcome records whether an agent answered a transferred call
+// (ANSWERED) or it was dropped (ABANDON), with the lead status that told
+func (sl *SessionLogger) LogTransferOutcome(sessionID, outcome, leadStatus string, waited time.Duration) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "transfer_outcome", SessionID: sessionID, Details: map[string]string{
+        "outcome":     outcome,
+        "lead_status": leadStatus,
+        "waited_ms":   fmt.Sprint(waited.Milliseconds()),
+    }})
+}
+
+// LogSeed records the session seed the call's random choices derive from
+func (sl *SessionLogger) LogSeed(sessionID string, seed int64) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "seed", SessionID: sessionID, Details: map[string]string{"seed": fmt.Sprint(seed)}})
+}
+
+// LogPeer records the address the call came from and, when it came through
+// a load balancer, the balancer's address
+func (sl *SessionLogger) LogPeer(sessionID, remoteAddr, proxyAddr string) {
+    details := map[string]string{"remote_addr": remoteAddr}
+    if proxyAddr != "" {
+        details["proxy_addr"] = proxyAddr
+    }
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "peer", SessionID: sessionID, Details: details})
+}
+
+// LogLeadScore records the lead score written back at call end and the
+// answers it counted
+func (sl *SessionLogger) LogLeadScore(sessionID string, score int, answers []string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "lead_score", SessionID: sessionID, Details: map[string]string{"score": fmt.Sprint(score), "answers": strings.Join(answers, ",")}})
+}
+
+// LogVariant records the prompt variant picked for a node visit
+func (sl *SessionLogger) LogVariant(sessionID string, node *FlowNode, file string) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "variant", SessionID: sessionID, NodeID: node.ID, NodeType: node.Type, Details: map[string]string{"audio_file": file}})
+}
+
 func (sl *SessionLogger) LogTimeout(sessionID string, node *FlowNode) {
     sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "timeout", SessionID: sessionID, NodeID: node.ID, NodeType: node.Type, NodeContent: node.Content})
 }
@@ -124,6 +283,12 @@ func (sl *SessionLogger) LogAPICallDetails(sessionID, endpoint, status string, d
     sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "api_call", SessionID: sessionID, Details: d})
 }
 
+// LogUtterance records a final transcription with its offsets (seconds from
+// call start) so it can be aligned with the call recording
+func (sl *SessionLogger) LogUtterance(sessionID, text string, start, end float64) {
+    sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "utterance", SessionID: sessionID, Text: text, Details: map[string]string{"start": fmt.Sprintf("%.2f", start), "end": fmt.Sprintf("%.2f", end)}})
+}
+
 func (sl *SessionLogger) LogHangup(sessionID string) {
     sl.write(logRecord{Timestamp: time.Now().Format(time.RFC3339Nano), Event: "hangup", SessionID: sessionID})
 }
@@ -0,0 +1,273 @@
+package flow
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Outcomes of a survey node, used as its transition keys
+const (
+	SurveyAllPositive = "all_positive" // every question answered yes
+	SurveyAnyNegative = "any_negative" // at least one question answered no
+	SurveyIncomplete  = "incomplete"   // no no, but a question left unclear or unanswered after its retries
+)
+
+// SurveyTimeout is stored for a question the caller never answered
+const SurveyTimeout = "timeout"
+
+// DefaultSurveyRetries is how many times a survey question is asked again
+// after an unclear answer or none
+const DefaultSurveyRetries = 1
+
+// SurveySettings configures a survey node: yes/no questions asked in order
+// under one timeout and retry policy
+type SurveySettings struct {
+	Questions      []SurveyQuestion `json:"questions"`
+	TimeoutMs      int              `json:"timeout_ms,omitempty"`       // time to answer each question, default the flow's response timeout
+	Retries        *int             `json:"retries,omitempty"`          // times a question is asked again after an unclear answer or none (default 1)
+	RetryAudio     string           `json:"retry_audio,omitempty"`      // played when asking again instead of the question
+	StopOnNegative bool             `json:"stop_on_negative,omitempty"` // skip the remaining questions after a no
+}
+
+// SurveyQuestion is one question of a survey node
+type SurveyQuestion struct {
+	ID        string `json:"id"`
+	Content   string `json:"content,omitempty"` // Human readable question
+	AudioFile string `json:"audio_file"`
+	Variable  string `json:"variable,omitempty"` // session variable for the answer, default <node>_<question>
+}
+
+// surveySettings returns the node's survey settings with defaults applied
+func (n *FlowNode) surveySettings() SurveySettings {
+	var s SurveySettings
+	if n.Survey != nil {
+		s = *n.Survey
+	}
+	if s.Retries == nil {
+		retries := DefaultSurveyRetries
+		s.Retries = &retries
+	}
+	return s
+}
+
+// validateSurvey checks the survey settings of a node
+func validateSurvey(node *FlowNode) error {
+	if node.Survey == nil {
+		if node.Type == "survey" {
+			return fmt.Errorf("survey node has no questions")
+		}
+		return nil
+	}
+	if node.Type != "survey" {
+		return fmt.Errorf("survey applies to survey nodes")
+	}
+	s := node.Survey
+	if len(s.Questions) == 0 {
+		return fmt.Errorf("survey node has no questions")
+	}
+	if s.TimeoutMs < 0 || s.Retries != nil && *s.Retries < 0 {
+		return fmt.Errorf("survey timeout_ms and retries must not be negative")
+	}
+	seen := make(map[string]bool)
+	for _, q := range s.Questions {
+		if q.ID == "" || q.AudioFile == "" {
+			return fmt.Errorf("survey questions need an id and an audio_file")
+		}
+		if seen[q.ID] {
+			return fmt.Errorf("duplicate survey question %q", q.ID)
+		}
+		seen[q.ID] = true
+	}
+	return nil
+}
+
+// variable returns the session variable holding the answer to q
+func (q SurveyQuestion) variable(node *FlowNode) string {
+	if q.Variable != "" {
+		return q.Variable
+	}
+	return node.ID + "_" + q.ID
+}
+
+// questionNode returns q as a question node of its own, so hooks, metrics
+// and session logs see which question of the survey was answered
+func (q SurveyQuestion) questionNode(node *FlowNode) *FlowNode {
+	return &FlowNode{ID: node.ID + "." + q.ID, Type: "question", Content: q.Content, AudioFile: q.AudioFile,
+		ResetOnPartial: node.ResetOnPartial, MinPartialLen: node.MinPartialLen, ExtendBy: node.ExtendBy}
+}
+
+// handleSurveyNode plays the node's prompt, if any, as an introduction and
+// asks its questions in order. Each answer is classified and stored in the
+// question's session variable; an unclear answer or none asks the question
+// again, up to retries times. Once the questions are asked the flow follows
+// one transition for all the answers: any_negative, incomplete or
+// all_positive. Interrupts leave the survey at once.
+func (fe *FlowEngine) handleSurveyNode(node *FlowNode) error {
+	settings := node.surveySettings()
+	log.Printf("Starting survey: %s - %d questions", node.Content, len(settings.Questions))
+	if node.AudioFile != "" {
+		if err := fe.playAudio(fe.promptFile(node)); err != nil {
+			log.Printf("Failed to play audio: %v", err)
+		}
+	}
+
+	fe.waitingFor = node
+	negative, incomplete := false, false
+	for _, q := range settings.Questions {
+		answer, moved := fe.askSurveyQuestion(node, q, settings)
+		if moved {
+			return nil
+		}
+		fe.session.SetVar(q.variable(node), answer)
+		switch ResponseType(answer) {
+		case ResponsePositive:
+		case ResponseNegative:
+			negative = true
+		default:
+			incomplete = true
+		}
+		if negative && settings.StopOnNegative {
+			break
+		}
+	}
+
+	outcome := SurveyAllPositive
+	switch {
+	case negative:
+		outcome = SurveyAnyNegative
+		fe.lastReason.Store("NI")
+	case incomplete:
+		outcome = SurveyIncomplete
+	}
+	log.Printf("SURVEY - Survey: %s | Result: %s | Node: %s", node.Content, outcome, node.ID)
+	return fe.followOutcome(node, outcome)
+}
+
+// askSurveyQuestion asks q until the caller answers yes or no or its retries
+// run out, and returns the classification of the last answer, SurveyTimeout
+// when there was none. The survey node's escalation, tone and long_winded
+// transitions apply while it waits, as they do at a question node. It
+// reports whether an interrupt or one of those moved the flow.
+func (fe *FlowEngine) askSurveyQuestion(node *FlowNode, q SurveyQuestion, settings SurveySettings) (string, bool) {
+	question := q.questionNode(node)
+	timeout := fe.timer.duration
+	if settings.TimeoutMs > 0 {
+		timeout = time.Duration(settings.TimeoutMs) * time.Millisecond
+	}
+	transcriptionChan := fe.session.GetTranscriptionResults()
+	escalations := fe.escalations()
+	tones := fe.tones()
+
+	// Partials keep the survey's own timeout running, tuned by the node's
+	// reset_on_partial, min_partial_len and extend_by
+	partials := *question
+	if partials.ExtendBy == 0 {
+		partials.ExtendBy = int(timeout / time.Millisecond)
+	}
+	var limit *time.Timer // max_answer_seconds of the current answer
+	defer func() {
+		if limit != nil {
+			limit.Stop()
+		}
+	}()
+
+	// classify takes text as the answer to q
+	classify := func(text string) string {
+		responseType := fe.classify(question, text)
+		log.Printf("Q&A LOG - Question: %s | Answer: %s | Classification: %s | Node: %s",
+			q.Content, text, responseType, question.ID)
+		if fe.logger != nil {
+			fe.logger.LogQnA(fe.session.GetID(), question, text, string(responseType))
+		}
+		return string(responseType)
+	}
+
+	answer := ""
+	for attempt := 0; attempt <= *settings.Retries; attempt++ {
+		file := q.AudioFile
+		if attempt > 0 && settings.RetryAudio != "" {
+			file = settings.RetryAudio
+		}
+		play := fe.startAudio(file, false)
+		go func() {
+			if err := play(); err != nil {
+				log.Printf("Failed to play audio: %v", err)
+			}
+		}()
+		fe.timer.start(timeout)
+
+		answer = ""
+		var partial string
+		var answerLimit <-chan time.Time
+		for answer == "" {
+			select {
+			case level := <-escalations:
+				if fe.escalate(node, level) {
+					return "", true
+				}
+
+			case tone := <-tones:
+				if fe.followTone(node, tone) {
+					return "", true
+				}
+
+			case <-answerLimit:
+				log.Printf("Caller still talking after max_answer_seconds (%ds) at node %s: %s", node.MaxAnswerSeconds, question.ID, partial)
+				if nextNode := fe.signalTarget(node, "long_winded"); nextNode != nil {
+					fe.skipFinal = partial != ""
+					log.Printf("Flow transition: %s (%s) -> %s (%s) | Long-winded answer",
+						node.ID, node.Content, nextNode.ID, nextNode.Content)
+					fe.leaveQuestion(node, nextNode, "long_winded")
+					return "", true
+				}
+				if partial != "" {
+					fe.skipFinal = true
+					answer = classify(partial)
+				}
+
+			case <-fe.timer.GetTimeoutChan():
+				log.Printf("Q&A TIMEOUT - Question: %s | Answer: [TIMEOUT] | Classification: [TIMEOUT] | Node: %s", q.Content, question.ID)
+				if fe.logger != nil {
+					fe.logger.LogTimeout(fe.session.GetID(), question)
+				}
+				answer = SurveyTimeout
+
+			case result, ok := <-transcriptionChan:
+				if !ok {
+					transcriptionChan = nil
+					continue
+				}
+				if fe.skipped(result) {
+					continue
+				}
+				if answerLimit == nil && node.MaxAnswerSeconds > 0 {
+					// The caller started answering
+					limit = time.NewTimer(time.Duration(node.MaxAnswerSeconds) * time.Second)
+					answerLimit = limit.C
+				}
+				if !result.IsFinal {
+					partial = result.Text
+					fe.partialHeard(&partials, result.Text)
+					continue
+				}
+				if fe.interrupted(node, result.Text) {
+					return "", true
+				}
+				answer = classify(result.Text)
+			}
+		}
+		fe.timer.Stop()
+		if limit != nil {
+			limit.Stop()
+			limit = nil
+		}
+		if err := fe.stopAudio(); err != nil {
+			log.Printf("Warning: Failed to stop audio: %v", err)
+		}
+		if answer == string(ResponsePositive) || answer == string(ResponseNegative) {
+			break
+		}
+	}
+	return answer, false
+}
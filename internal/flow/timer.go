@@ -2,6 +2,7 @@ package flow
 
 import (
 	"log"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,7 +12,8 @@ type GlobalTimer struct {
 	timer         *time.Timer
 	resetChan     chan struct{}
 	timeoutChan   chan struct{}
-	isActive      bool
+	isActive      atomic.Bool // cleared by the timer's own goroutine when it fires
+	deadline      time.Time   // when the running timer fires
 	lastReset     time.Time
 	resetDebounce time.Duration // Minimum time between resets
 }
@@ -22,21 +24,25 @@ func NewGlobalTimer(duration time.Duration) *GlobalTimer {
 		duration:      duration,
 		resetChan:     make(chan struct{}),
 		timeoutChan:   make(chan struct{}),
-		isActive:      false,
 		resetDebounce: 500 * time.Millisecond, // 500ms debounce
 	}
 }
 
 // Start starts the timer
 func (gt *GlobalTimer) Start() {
-	if gt.isActive {
+	gt.start(gt.duration)
+}
+
+func (gt *GlobalTimer) start(duration time.Duration) {
+	if gt.isActive.Load() {
 		gt.Stop()
 	}
 
-	gt.isActive = true
-	gt.timer = time.AfterFunc(gt.duration, func() {
+	gt.isActive.Store(true)
+	gt.deadline = time.Now().Add(duration)
+	gt.timer = time.AfterFunc(duration, func() {
 		gt.timeoutChan <- struct{}{}
-		gt.isActive = false
+		gt.isActive.Store(false)
 	})
 
 	// log.Printf("Global timer started: %v", gt.duration)
@@ -48,7 +54,7 @@ func (gt *GlobalTimer) Stop() {
 		gt.timer.Stop()
 		gt.timer = nil
 	}
-	gt.isActive = false
+	gt.isActive.Store(false)
 	// log.Printf("Global timer stopped")
 }
 
@@ -59,7 +65,7 @@ func (gt *GlobalTimer) Reset() {
 		return // Skip reset if too soon
 	}
 
-	if gt.isActive {
+	if gt.isActive.Load() {
 		gt.Stop()
 	}
 	gt.Start()
@@ -67,9 +73,24 @@ func (gt *GlobalTimer) Reset() {
 	log.Printf("Global timer reset")
 }
 
+// Extend makes the timer fire no sooner than d from now, restarting it if
+// less than d remains, debounced like Reset. A timer with more time left
+// keeps its deadline.
+func (gt *GlobalTimer) Extend(d time.Duration) {
+	if time.Since(gt.lastReset) < gt.resetDebounce {
+		return
+	}
+	if gt.isActive.Load() && time.Until(gt.deadline) >= d {
+		return
+	}
+	gt.start(d)
+	gt.lastReset = time.Now()
+	log.Printf("Global timer extended by %v", d)
+}
+
 // IsActive returns whether the timer is currently active
 func (gt *GlobalTimer) IsActive() bool {
-	return gt.isActive
+	return gt.isActive.Load()
 }
 
 // GetTimeoutChan returns the channel for timeout events
@@ -0,0 +1,46 @@
+package flow
+
+import "log"
+
+// ToneSession is implemented by sessions that detect signaling tones on the
+// caller side. It delivers "beep" (answering machine), "fax" or "sit"
+// (disconnected or unreachable number) as each is heard.
+type ToneSession interface {
+	Tones() <-chan string
+}
+
+// toneReasons are the hangup reasons set when a question follows a tone
+var toneReasons = map[string]string{
+	"beep": "A",
+	"fax":  "AFAX",
+	"sit":  "ADC",
+}
+
+// tones returns the session's tone events, or nil (never ready) for
+// sessions without detection
+func (fe *FlowEngine) tones() <-chan string {
+	if ts, ok := fe.session.(ToneSession); ok {
+		return ts.Tones()
+	}
+	return nil
+}
+
+// followTone follows the question's "tone:<tone>" transition, e.g. to leave
+// a voicemail after the beep or hang up on a fax machine, and sets the
+// matching hangup reason. It reports whether the flow moved; nodes without
+// the transition keep waiting for an answer.
+func (fe *FlowEngine) followTone(node *FlowNode, tone string) bool {
+	key := "tone:" + tone
+	nextNode := fe.signalTarget(node, key)
+	if nextNode == nil {
+		log.Printf("Tone %s at node %s, which has no %s transition", tone, node.ID, key)
+		return false
+	}
+	log.Printf("Flow transition: %s (%s) -> %s (%s) | Tone: %s",
+		node.ID, node.Content, nextNode.ID, nextNode.Content, tone)
+	if reason := toneReasons[tone]; reason != "" {
+		fe.lastReason.Store(reason)
+	}
+	fe.leaveQuestion(node, nextNode, key)
+	return true
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// AlertRule is a Prometheus alerting rule
+type AlertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+// DefaultAlertRules are the alerts every deployment should start with. The
+// thresholds are deliberately loose; tune them in the written rule file.
+func DefaultAlertRules() []AlertRule {
+	return []AlertRule{
+		{
+			Alert: "AudioSocketTranscriberErrorRateHigh",
+			Expr: `sum by (provider) (rate(audiosocket_transcriber_errors_total[10m]))
+  / sum by (provider) (rate(audiosocket_sessions_started_total[10m])) > 0.05`,
+			For:    "10m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary":     "Transcriber {{ $labels.provider }} failing on {{ $value | humanizePercentage }} of calls",
+				"description": "Transcribers could not be created or stopped accepting audio. Check that the provider is reachable and within its quota.",
+			},
+		},
+		{
+			Alert: "AudioSocketTransferFailures",
+			Expr: `sum by (campaign) (rate(flow_transfer_requests_total{result="error"}[10m]))
+  / sum by (campaign) (rate(flow_transfer_requests_total[10m])) > 0.1`,
+			For:    "5m",
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary":     "{{ $value | humanizePercentage }} of transfers failing in campaign {{ $labels.campaign }}",
+				"description": "Vicidial rejected or did not answer the transfer API call, so qualified callers are not reaching agents. Check the Vicidial API user and server.",
+			},
+		},
+		{
+			Alert: "AudioSocketDeadAirSpike",
+			Expr: `sum(rate(audiosocket_dead_air_hangups_total[10m]))
+  / sum(rate(audiosocket_sessions_started_total[10m])) > 0.1`,
+			For:    "10m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary":     "{{ $value | humanizePercentage }} of calls hung up for dead air",
+				"description": "Calls arrive with no caller audio, which usually means one-way audio: RTP blocked by a firewall or NAT, or a codec mismatch on a trunk.",
+			},
+		},
+		{
+			Alert:  "AudioSocketPromptProblems",
+			Expr:   `max by (instance) (audiosocket_prompt_problems) > 0`,
+			For:    "1m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary":     "{{ $labels.instance }} has {{ $value }} broken prompt audio files",
+				"description": "Audio files the flow or interrupts play are missing, do not decode, are not 8kHz or are silent; callers hear dead air instead. The server log lists each file.",
+			},
+		},
+		{
+			Alert: "AudioSocketSessionLeak",
+			Expr: `sum by (instance) (audiosocket_sessions_active) > 0
+  and sum by (instance) (rate(audiosocket_sessions_started_total[30m])) == 0`,
+			For:    "30m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary":     "{{ $labels.instance }} holds {{ $value }} sessions with no new calls for 30 minutes",
+				"description": "Sessions were not cleaned up after their calls ended. List them on the admin API (GET /sessions) and hang them up.",
+			},
+		},
+	}
+}
+
+// ruleGroup is a group of rules in a Prometheus rule file
+type ruleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []AlertRule `yaml:"rules"`
+}
+
+// WriteAlertRules writes rules as a Prometheus rule file with one group
+func WriteAlertRules(w io.Writer, group string, rules []AlertRule) error {
+	file := struct {
+		Groups []ruleGroup `yaml:"groups"`
+	}{[]ruleGroup{{group, rules}}}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(file); err != nil {
+		return fmt.Errorf("failed to encode alert rules: %w", err)
+	}
+	return enc.Close()
+}
@@ -0,0 +1,327 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing metric exported in Prometheus text
+// format by WritePrometheus
+type Counter struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() { c.value.Add(1) }
+
+// Add adds n to the counter
+func (c *Counter) Add(n int64) { c.value.Add(n) }
+
+// Value returns the current count
+func (c *Counter) Value() int64 { return c.value.Load() }
+
+// Gauge is a metric that can go up and down, such as a queue depth
+type Gauge struct {
+	name  string
+	help  string
+	value atomic.Int64
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v int64) { g.value.Store(v) }
+
+// Inc adds one to the gauge
+func (g *Gauge) Inc() { g.value.Add(1) }
+
+// Dec subtracts one from the gauge
+func (g *Gauge) Dec() { g.value.Add(-1) }
+
+// Value returns the current value
+func (g *Gauge) Value() int64 { return g.value.Load() }
+
+// CounterVec is a family of counters told apart by the values of their
+// labels, such as a flow node ID
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu       sync.Mutex
+	counters map[string]*labeledCounter
+}
+
+type labeledCounter struct {
+	values []string
+	*Counter
+}
+
+// With returns the counter for the label values, given in the order the
+// labels were registered, creating it on first use
+func (v *CounterVec) With(values ...string) *Counter {
+	checkLabels(v.name, v.labels, values)
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.counters[key]
+	if !ok {
+		c = &labeledCounter{values, &Counter{name: v.name, help: v.help}}
+		v.counters[key] = c
+	}
+	return c.Counter
+}
+
+// DefaultBuckets are histogram upper bounds in seconds suited to call
+// steps, from a quick prompt to a long silence
+var DefaultBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Histogram counts observations into buckets by upper bound, for
+// distributions such as node durations
+type Histogram struct {
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []int64 // per bucket, not cumulative
+	count  int64
+	sum    float64
+}
+
+// Observe records one value
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// HistogramVec is a family of histograms told apart by the values of their
+// labels
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu         sync.Mutex
+	histograms map[string]*labeledHistogram
+}
+
+type labeledHistogram struct {
+	values []string
+	*Histogram
+}
+
+// With returns the histogram for the label values, creating it on first use
+func (v *HistogramVec) With(values ...string) *Histogram {
+	checkLabels(v.name, v.labels, values)
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.histograms[key]
+	if !ok {
+		h = &labeledHistogram{values, &Histogram{buckets: v.buckets, counts: make([]int64, len(v.buckets))}}
+		v.histograms[key] = h
+	}
+	return h.Histogram
+}
+
+// checkLabels panics on a label count mismatch, a programming error that
+// would otherwise export malformed series
+func checkLabels(name string, labels, values []string) {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got %d values", name, labels, len(values)))
+	}
+}
+
+var (
+	registryMu sync.Mutex
+	counters   = map[string]*Counter{}
+	gauges     = map[string]*Gauge{}
+	vecs       = map[string]*CounterVec{}
+	histograms = map[string]*HistogramVec{}
+)
+
+// NewCounter registers a counter. Registering the same name twice returns
+// the existing counter so package-level metrics survive repeated setup.
+func NewCounter(name, help string) *Counter {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if c, ok := counters[name]; ok {
+		return c
+	}
+	c := &Counter{name: name, help: help}
+	counters[name] = c
+	return c
+}
+
+// NewGauge registers a gauge; like NewCounter, a name registered twice
+// returns the existing gauge
+func NewGauge(name, help string) *Gauge {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if g, ok := gauges[name]; ok {
+		return g
+	}
+	g := &Gauge{name: name, help: help}
+	gauges[name] = g
+	return g
+}
+
+// NewCounterVec registers a counter family with the given labels; like
+// NewCounter, a name registered twice returns the existing family
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if v, ok := vecs[name]; ok {
+		return v
+	}
+	v := &CounterVec{name: name, help: help, labels: labels, counters: map[string]*labeledCounter{}}
+	vecs[name] = v
+	return v
+}
+
+// NewHistogramVec registers a histogram family with the given bucket upper
+// bounds (DefaultBuckets if nil) and labels; a name registered twice
+// returns the existing family
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if v, ok := histograms[name]; ok {
+		return v
+	}
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	v := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, histograms: map[string]*labeledHistogram{}}
+	histograms[name] = v
+	return v
+}
+
+// labelEscaper escapes label values for the Prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sample is one metric as written by WritePrometheus; series holds the
+// lines written for it
+type sample struct {
+	name, help, kind string
+	series           []series
+}
+
+// series is one exported line: the metric name with any suffix such as
+// _bucket, the label set (empty for plain metrics) and the value
+type series struct {
+	name   string
+	labels string
+	value  string
+}
+
+// formatLabels renders a label set, with extra appended after the named
+// labels (for a histogram's le)
+func formatLabels(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(names)+len(extra)/2)
+	for i, name := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, extra[i], extra[i+1]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// histogramSeries renders one histogram as cumulative _bucket lines and its
+// _sum and _count
+func histogramSeries(name string, names, values []string, h *Histogram) []series {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]series, 0, len(h.buckets)+3)
+	var cumulative int64
+	for i, bound := range h.buckets {
+		cumulative += h.counts[i]
+		out = append(out, series{name + "_bucket", formatLabels(names, values, "le", formatFloat(bound)), strconv.FormatInt(cumulative, 10)})
+	}
+	out = append(out,
+		series{name + "_bucket", formatLabels(names, values, "le", "+Inf"), strconv.FormatInt(h.count, 10)},
+		series{name + "_sum", formatLabels(names, values), formatFloat(h.sum)},
+		series{name + "_count", formatLabels(names, values), strconv.FormatInt(h.count, 10)},
+	)
+	return out
+}
+
+// WritePrometheus writes all registered metrics in Prometheus text format
+func WritePrometheus(w io.Writer) error {
+	registryMu.Lock()
+	all := make([]sample, 0, len(counters)+len(gauges)+len(vecs)+len(histograms))
+	for _, c := range counters {
+		all = append(all, sample{c.name, c.help, "counter", []series{{c.name, "", strconv.FormatInt(c.Value(), 10)}}})
+	}
+	for _, g := range gauges {
+		all = append(all, sample{g.name, g.help, "gauge", []series{{g.name, "", strconv.FormatInt(g.Value(), 10)}}})
+	}
+	for _, v := range vecs {
+		m := sample{name: v.name, help: v.help, kind: "counter"}
+		v.mu.Lock()
+		for _, c := range v.counters {
+			m.series = append(m.series, series{v.name, formatLabels(v.labels, c.values), strconv.FormatInt(c.Value(), 10)})
+		}
+		v.mu.Unlock()
+		sort.Slice(m.series, func(i, j int) bool { return m.series[i].labels < m.series[j].labels })
+		all = append(all, m)
+	}
+	for _, v := range histograms {
+		m := sample{name: v.name, help: v.help, kind: "histogram"}
+		v.mu.Lock()
+		keys := make([]string, 0, len(v.histograms))
+		for key := range v.histograms {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			h := v.histograms[key]
+			m.series = append(m.series, histogramSeries(v.name, v.labels, h.values, h.Histogram)...)
+		}
+		v.mu.Unlock()
+		all = append(all, m)
+	}
+	registryMu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+
+	for _, m := range all {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+			return err
+		}
+		for _, s := range m.series {
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", s.name, s.labels, s.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
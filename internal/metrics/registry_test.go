@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// registered counts the metrics the tests have registered, so each run of
+// a test under -count registers fresh ones instead of adding to the last
+var registered atomic.Int64
+
+// uniqueName returns base made unique to this run of the test
+func uniqueName(base string) string {
+	return fmt.Sprintf("%s_%d", base, registered.Add(1))
+}
+
+func TestCounterExport(t *testing.T) {
+	name := uniqueName("test_events_total")
+	c := NewCounter(name, "Events seen by the test")
+	c.Inc()
+	c.Add(2)
+	if again := NewCounter(name, "ignored"); again != c {
+		t.Error("registering a name twice should return the existing counter")
+	}
+
+	var b strings.Builder
+	if err := WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("# HELP %[1]s Events seen by the test\n# TYPE %[1]s counter\n%[1]s 3\n", name)
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("export missing counter:\n%s", b.String())
+	}
+}
+
+func TestGaugeExport(t *testing.T) {
+	g := NewGauge("test_queue_depth", "Items waiting in the test queue")
+	g.Set(5)
+	g.Inc()
+	g.Dec()
+	g.Dec()
+
+	var b strings.Builder
+	if err := WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "# HELP test_queue_depth Items waiting in the test queue\n# TYPE test_queue_depth gauge\ntest_queue_depth 4\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("export missing gauge:\n%s", b.String())
+	}
+}
+
+func TestCounterVecExport(t *testing.T) {
+	name := uniqueName("test_node_events_total")
+	v := NewCounterVec(name, "Events per test node", "node")
+	v.With("pitch").Inc()
+	v.With("pitch").Inc()
+	v.With(`a"b`).Inc()
+
+	var b strings.Builder
+	if err := WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("# HELP %[1]s Events per test node\n# TYPE %[1]s counter\n"+
+		"%[1]s{node=\"a\\\"b\"} 1\n%[1]s{node=\"pitch\"} 2\n", name)
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("export missing counter family:\n%s", b.String())
+	}
+}
+
+func TestMultiLabelExport(t *testing.T) {
+	name := uniqueName("test_outcomes_total")
+	v := NewCounterVec(name, "Outcomes per test campaign", "campaign", "status")
+	v.With("solar", "SALE").Inc()
+	v.With("solar", "NI").Add(2)
+
+	var b strings.Builder
+	if err := WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("%[1]s{campaign=\"solar\",status=\"NI\"} 2\n%[1]s{campaign=\"solar\",status=\"SALE\"} 1\n", name)
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("export missing labeled counters:\n%s", b.String())
+	}
+}
+
+func TestHistogramExport(t *testing.T) {
+	name := uniqueName("test_step_seconds")
+	v := NewHistogramVec(name, "Test step durations", []float64{1, 0.5}, "step")
+	h := v.With("greet")
+	h.Observe(0.2)
+	h.Observe(0.7)
+	h.Observe(3)
+
+	var b strings.Builder
+	if err := WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("# HELP %[1]s Test step durations\n# TYPE %[1]s histogram\n"+
+		"%[1]s_bucket{step=\"greet\",le=\"0.5\"} 1\n"+
+		"%[1]s_bucket{step=\"greet\",le=\"1\"} 2\n"+
+		"%[1]s_bucket{step=\"greet\",le=\"+Inf\"} 3\n"+
+		"%[1]s_sum{step=\"greet\"} 3.9\n"+
+		"%[1]s_count{step=\"greet\"} 3\n", name)
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("export missing histogram:\n%s", b.String())
+	}
+}
+
+func TestWriteAlertRules(t *testing.T) {
+	var b strings.Builder
+	if err := WriteAlertRules(&b, "audiosocket", DefaultAlertRules()); err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Groups []struct {
+			Name  string      `yaml:"name"`
+			Rules []AlertRule `yaml:"rules"`
+		} `yaml:"groups"`
+	}
+	if err := yaml.Unmarshal([]byte(b.String()), &file); err != nil {
+		t.Fatalf("rule file is not valid YAML: %v\n%s", err, b.String())
+	}
+	if len(file.Groups) != 1 || file.Groups[0].Name != "audiosocket" {
+		t.Fatalf("groups = %+v, want one named audiosocket", file.Groups)
+	}
+	rules := file.Groups[0].Rules
+	if len(rules) != len(DefaultAlertRules()) {
+		t.Fatalf("wrote %d rules, want %d", len(rules), len(DefaultAlertRules()))
+	}
+	for _, r := range rules {
+		if r.Alert == "" || r.Expr == "" || r.For == "" || r.Labels["severity"] == "" || r.Annotations["summary"] == "" {
+			t.Errorf("incomplete rule %+v", r)
+		}
+	}
+}
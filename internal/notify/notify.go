@@ -0,0 +1,59 @@
+// Package notify sends follow-up SMS and email messages, e.g. the plan
+// details a caller agreed to receive, through provider adapters, and JSON
+// webhooks to automation endpoints through a durable outbox.
+package notify
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Channels a flow's notify action can send on
+const (
+	SMS     = "sms"
+	Email   = "email"
+	Webhook = "webhook" // JSON posted to an automation endpoint (Zapier, Make, n8n, ...): To is the URL
+)
+
+// Message is an SMS or email to send
+type Message struct {
+	To      string
+	Subject string // email only
+	Body    string
+}
+
+// Sender delivers messages on one channel
+type Sender interface {
+	Send(msg Message) error
+}
+
+// Expand fills ${name} and $name placeholders in tmpl with lookup(name);
+// unknown names expand to ""
+func Expand(tmpl string, lookup func(name string) string) string {
+	return os.Expand(tmpl, lookup)
+}
+
+// defaultTimeout bounds one provider request; sends run on the call's flow
+const defaultTimeout = 5 * time.Second
+
+func newClient() *http.Client {
+	return &http.Client{Timeout: defaultTimeout}
+}
+
+// do sends req and turns a non-2xx answer into an error naming provider
+func do(client *http.Client, provider string, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send via %s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("failed to send via %s: %s: %s", provider, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
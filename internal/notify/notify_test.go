@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"first_name": "Ann", "plan": "Gold"}
+	got := Expand("Hi ${first_name}, your $plan plan details${missing}", func(name string) string { return vars[name] })
+	if want := "Hi Ann, your Gold plan details"; got != want {
+		t.Errorf("Expand = %q, want %q", got, want)
+	}
+}
+
+func TestTwilio(t *testing.T) {
+	var path string
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "AC123" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r.ParseForm()
+		path, form = r.URL.Path, r.PostForm
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	tw := NewTwilio("AC123", "secret", "+15550100")
+	tw.BaseURL = srv.URL
+	if err := tw.Send(Message{To: "+15550199", Body: "Your plan: Gold"}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/2010-04-01/Accounts/AC123/Messages.json" || form.Get("To") != "+15550199" || form.Get("From") != "+15550100" || form.Get("Body") != "Your plan: Gold" {
+		t.Errorf("sent %s %v", path, form)
+	}
+
+	tw.AuthToken = "wrong"
+	if err := tw.Send(Message{To: "+15550199", Body: "x"}); err == nil {
+		t.Error("a rejected send should return an error")
+	}
+}
+
+func TestSendGrid(t *testing.T) {
+	var auth string
+	var mail sendGridMail
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &mail)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	sg := NewSendGrid("SG.key", "plans@example.com", "Plans")
+	sg.BaseURL = srv.URL
+	if err := sg.Send(Message{To: "ann@example.com", Subject: "Your plan", Body: "Gold"}); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer SG.key" || mail.Personalizations[0].To[0].Email != "ann@example.com" ||
+		mail.From.Email != "plans@example.com" || mail.Subject != "Your plan" || mail.Content[0].Value != "Gold" {
+		t.Errorf("sent %s %+v", auth, mail)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	var keys []string
+	var bodies []string
+	fail := 1 // answer the first attempt with a 500
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			http.Error(w, "no such hook", http.StatusNotFound)
+			return
+		}
+		if fail > 0 {
+			fail--
+			http.Error(w, "busy", http.StatusInternalServerError)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		bodies = append(bodies, string(data))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	outbox, err := NewOutbox(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Send(Message{To: srv.URL + "/hook", Body: "{not json"}); err == nil {
+		t.Error("a body that is not JSON should be refused")
+	}
+	if err := outbox.Send(Message{To: srv.URL + "/hook", Body: `{"lead_id": "1001"}`}); err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Send(Message{To: srv.URL + "/gone", Body: `{}`}); err != nil {
+		t.Fatal(err)
+	}
+
+	if wait := outbox.Flush(); wait != outboxFirstRetry {
+		t.Errorf("next attempt in %v, want %v", wait, outboxFirstRetry)
+	}
+	failed, _ := filepath.Glob(filepath.Join(dir, "failed", "*.json"))
+	if len(failed) != 1 {
+		t.Errorf("%d webhooks failed, want the one refused with 404", len(failed))
+	}
+	pending, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(pending) != 1 || len(bodies) != 0 {
+		t.Fatalf("after a 500: %d pending, %d delivered; want 1 and 0", len(pending), len(bodies))
+	}
+
+	// Make the retry due, as a later Flush would find it
+	entry, err := readEntry(pending[0])
+	if err != nil || entry.Attempts != 1 || entry.LastError == "" {
+		t.Fatalf("retried entry %+v, %v", entry, err)
+	}
+	entry.NextTry = time.Now()
+	outbox.write(pending[0], entry)
+	outbox.Flush()
+	if len(bodies) != 1 || bodies[0] != `{"lead_id": "1001"}` || keys[0] != entry.ID {
+		t.Errorf("delivered %v with keys %v, want the queued body keyed %s", bodies, keys, entry.ID)
+	}
+	if pending, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(pending) != 0 {
+		t.Errorf("%d webhooks still pending after delivery", len(pending))
+	}
+}
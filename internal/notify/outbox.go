@@ -0,0 +1,235 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+	"github.com/google/uuid"
+)
+
+// DefaultOutboxMaxAge is how long an undelivered webhook keeps being retried
+const DefaultOutboxMaxAge = 24 * time.Hour
+
+// Retry backoff of undelivered webhooks, doubling from the first to the last
+const (
+	outboxFirstRetry = 5 * time.Second
+	outboxLastRetry  = 10 * time.Minute
+)
+
+var (
+	webhookDeliveries = metrics.NewCounterVec("audiosocket_webhook_deliveries_total", "Webhook delivery attempts by result: delivered, retry or failed", "result")
+	webhooksPending   = metrics.NewGauge("audiosocket_webhooks_pending", "Webhooks queued in the outbox and not yet delivered")
+)
+
+// outboxEntry is a queued webhook as stored in the outbox directory
+type outboxEntry struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Body      string    `json:"body"`
+	Created   time.Time `json:"created"`
+	Attempts  int       `json:"attempts"`
+	NextTry   time.Time `json:"next_try"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Outbox delivers webhooks at least once. Send writes each message to a file
+// in the outbox directory before returning; Run posts queued messages until
+// the endpoint answers 2xx, retrying with backoff, and removes them then.
+// Messages left by a previous run are delivered after a restart. Every
+// attempt carries the message's ID in an Idempotency-Key header so endpoints
+// can drop duplicates. Messages refused with a 4xx status, or still
+// undelivered after the outbox's max age, are moved to the failed
+// subdirectory.
+type Outbox struct {
+	dir    string
+	maxAge time.Duration
+	client *http.Client
+	wake   chan struct{}
+}
+
+// NewOutbox opens the outbox in dir, creating it if needed. maxAge is how
+// long a message is retried, DefaultOutboxMaxAge if 0.
+func NewOutbox(dir string, maxAge time.Duration) (*Outbox, error) {
+	if maxAge <= 0 {
+		maxAge = DefaultOutboxMaxAge
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "failed"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create webhook outbox: %w", err)
+	}
+	return &Outbox{dir: dir, maxAge: maxAge, client: newClient(), wake: make(chan struct{}, 1)}, nil
+}
+
+// Send queues msg.Body, which must be JSON, for posting to the URL msg.To
+func (o *Outbox) Send(msg Message) error {
+	if !strings.HasPrefix(msg.To, "http://") && !strings.HasPrefix(msg.To, "https://") {
+		return fmt.Errorf("webhook URL %q is not http(s)", msg.To)
+	}
+	if !json.Valid([]byte(msg.Body)) {
+		return fmt.Errorf("webhook body is not valid JSON: %s", msg.Body)
+	}
+	now := time.Now()
+	entry := &outboxEntry{ID: uuid.New().String(), URL: msg.To, Body: msg.Body, Created: now, NextTry: now}
+	// Names sort by creation so webhooks go out in order
+	name := fmt.Sprintf("%d-%s.json", now.UnixNano(), entry.ID)
+	if err := o.write(filepath.Join(o.dir, name), entry); err != nil {
+		return err
+	}
+	webhooksPending.Inc()
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// write stores entry at path atomically, so a crash leaves the old or the
+// new entry but never half of one
+func (o *Outbox) write(path string, entry *outboxEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to queue webhook: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to queue webhook: %w", err)
+	}
+	return nil
+}
+
+// Run delivers queued webhooks until stop is closed
+func (o *Outbox) Run(stop <-chan struct{}) {
+	for {
+		wait := o.Flush()
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-o.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// Flush attempts every queued webhook that is due and returns how long
+// until the next one is
+func (o *Outbox) Flush() time.Duration {
+	names, err := filepath.Glob(filepath.Join(o.dir, "*.json"))
+	if err != nil {
+		log.Printf("Warning: Failed to list webhook outbox: %v", err)
+		return outboxLastRetry
+	}
+	sort.Strings(names)
+	webhooksPending.Set(int64(len(names)))
+
+	next := outboxLastRetry
+	for _, path := range names {
+		entry, err := readEntry(path)
+		if err != nil {
+			log.Printf("Warning: Unreadable webhook %s: %v", filepath.Base(path), err)
+			webhookDeliveries.With("failed").Inc()
+			o.moveToFailed(path)
+			continue
+		}
+		if wait := time.Until(entry.NextTry); wait > 0 {
+			if wait < next {
+				next = wait
+			}
+			continue
+		}
+		if wait := o.attempt(path, entry); wait > 0 && wait < next {
+			next = wait
+		}
+	}
+	return next
+}
+
+// attempt posts one webhook and updates its entry; it returns the wait
+// before the next attempt, 0 once the webhook is delivered or dropped
+func (o *Outbox) attempt(path string, entry *outboxEntry) time.Duration {
+	entry.Attempts++
+	status, err := o.post(entry)
+	switch {
+	case err == nil:
+		webhookDeliveries.With("delivered").Inc()
+		webhooksPending.Dec()
+		if err := os.Remove(path); err != nil {
+			log.Printf("Warning: Failed to remove delivered webhook %s: %v", entry.ID, err)
+		}
+		return 0
+	case status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests:
+		log.Printf("Webhook %s to %s refused: %v", entry.ID, entry.URL, err)
+	case time.Since(entry.Created) >= o.maxAge:
+		log.Printf("Webhook %s to %s undelivered after %d attempts: %v", entry.ID, entry.URL, entry.Attempts, err)
+	default:
+		webhookDeliveries.With("retry").Inc()
+		wait := outboxFirstRetry << (entry.Attempts - 1)
+		if wait > outboxLastRetry || wait <= 0 {
+			wait = outboxLastRetry
+		}
+		entry.NextTry = time.Now().Add(wait)
+		entry.LastError = err.Error()
+		if err := o.write(path, entry); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return wait
+	}
+	webhookDeliveries.With("failed").Inc()
+	webhooksPending.Dec()
+	entry.LastError = err.Error()
+	if err := o.write(path, entry); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	o.moveToFailed(path)
+	return 0
+}
+
+// post sends the webhook, returning the HTTP status if there was one
+func (o *Outbox) post(entry *outboxEntry) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, entry.URL, bytes.NewReader([]byte(entry.Body)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", entry.ID)
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to post webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return resp.StatusCode, fmt.Errorf("failed to post webhook: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (o *Outbox) moveToFailed(path string) {
+	if err := os.Rename(path, filepath.Join(o.dir, "failed", filepath.Base(path))); err != nil {
+		log.Printf("Warning: Failed to move webhook to failed: %v", err)
+	}
+}
+
+func readEntry(path string) (*outboxEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry outboxEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
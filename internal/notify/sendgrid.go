@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultSendGridURL is the SendGrid v3 API
+const DefaultSendGridURL = "https://api.sendgrid.com"
+
+// SendGrid sends plain text email through SendGrid's Mail Send API
+type SendGrid struct {
+	APIKey   string
+	From     string // sender address, verified in SendGrid
+	FromName string // optional display name
+	BaseURL  string // DefaultSendGridURL if empty
+	Client   *http.Client
+}
+
+// NewSendGrid creates a SendGrid email sender
+func NewSendGrid(apiKey, from, fromName string) *SendGrid {
+	return &SendGrid{APIKey: apiKey, From: from, FromName: fromName, Client: newClient()}
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridMail struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+// Send emails msg to the address msg.To
+func (s *SendGrid) Send(msg Message) error {
+	base := s.BaseURL
+	if base == "" {
+		base = DefaultSendGridURL
+	}
+	mail := sendGridMail{
+		Personalizations: []sendGridPersonalization{{To: []sendGridAddress{{Email: msg.To}}}},
+		From:             sendGridAddress{Email: s.From, Name: s.FromName},
+		Subject:          msg.Subject,
+		Content:          []sendGridContent{{Type: "text/plain", Value: msg.Body}},
+	}
+
+	body, err := json.Marshal(mail)
+	if err != nil {
+		return fmt.Errorf("failed to encode sendgrid mail: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(base, "/")+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create sendgrid request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	client := s.Client
+	if client == nil {
+		client = newClient()
+	}
+	return do(client, "sendgrid", req)
+}
@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultTwilioURL is the Twilio REST API
+const DefaultTwilioURL = "https://api.twilio.com"
+
+// Twilio sends SMS through Twilio's Messages API
+type Twilio struct {
+	AccountSID string
+	AuthToken  string
+	From       string // sending number or messaging service SID (MG...)
+	BaseURL    string // DefaultTwilioURL if empty
+	Client     *http.Client
+}
+
+// NewTwilio creates a Twilio SMS sender
+func NewTwilio(accountSID, authToken, from string) *Twilio {
+	return &Twilio{AccountSID: accountSID, AuthToken: authToken, From: from, Client: newClient()}
+}
+
+// Send sends msg.Body to the phone number msg.To
+func (t *Twilio) Send(msg Message) error {
+	base := t.BaseURL
+	if base == "" {
+		base = DefaultTwilioURL
+	}
+	form := url.Values{"To": {msg.To}, "Body": {msg.Body}}
+	if strings.HasPrefix(t.From, "MG") {
+		form.Set("MessagingServiceSid", t.From)
+	} else {
+		form.Set("From", t.From)
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", strings.TrimRight(base, "/"), url.PathEscape(t.AccountSID))
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create twilio request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	client := t.Client
+	if client == nil {
+		client = newClient()
+	}
+	return do(client, "twilio", req)
+}
@@ -0,0 +1,230 @@
+// Package qa scores finished calls against a compliance rubric: required
+// disclosures played in full, no caller talk-over on compliance lines and a
+// disposition that agrees with what the caller said.
+package qa
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Checks a violation can fail
+const (
+	CheckDisclosure  = "disclosure"
+	CheckTalkOver    = "talk_over"
+	CheckDisposition = "disposition"
+)
+
+// Rubric defaults
+const (
+	DefaultTalkOverSeconds = 0.5
+	MaxScore               = 100
+)
+
+// DefaultWeights are the points each violation of a check costs
+var DefaultWeights = map[string]int{
+	CheckDisclosure:  40,
+	CheckTalkOver:    20,
+	CheckDisposition: 40,
+}
+
+// Rubric is what calls are scored against
+type Rubric struct {
+	// Disclosures are prompt files every call must play to the end
+	Disclosures []string `yaml:"disclosures"`
+	// ExemptStatuses are final statuses of calls that need no disclosure,
+	// e.g. answering machines and callers who hung up at once
+	ExemptStatuses []string `yaml:"exempt_statuses"`
+
+	// ComplianceLines are prompt files the caller must not talk over
+	ComplianceLines []string `yaml:"compliance_lines"`
+	// TalkOverSeconds is how much caller speech may overlap a compliance
+	// line, allowing for transcript timing; DefaultTalkOverSeconds if 0
+	TalkOverSeconds float64 `yaml:"talk_over_seconds"`
+
+	// Dispositions maps an interrupt key heard in the transcript to the
+	// status it calls for, e.g. dnc: DNC
+	Dispositions map[string]string `yaml:"dispositions"`
+
+	// Weights overrides DefaultWeights per check
+	Weights map[string]int `yaml:"weights"`
+}
+
+// LoadRubric reads a YAML rubric
+func LoadRubric(path string) (*Rubric, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read qa rubric: %w", err)
+	}
+	var r Rubric
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse qa rubric: %w", err)
+	}
+	for check, weight := range r.Weights {
+		if _, ok := DefaultWeights[check]; !ok {
+			return nil, fmt.Errorf("qa rubric: unknown check %q in weights", check)
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("qa rubric: weight of %s must not be negative", check)
+		}
+	}
+	if r.TalkOverSeconds < 0 {
+		return nil, fmt.Errorf("qa rubric: talk_over_seconds must not be negative")
+	}
+	return &r, nil
+}
+
+// Prompt is a bot prompt played during the call; Start and End are seconds
+// from call start
+type Prompt struct {
+	File    string
+	Start   float64
+	End     float64
+	Stopped bool // cut short by barge-in, an answer or a timeout
+}
+
+// Utterance is something the caller said
+type Utterance struct {
+	Text  string
+	Start float64
+	End   float64
+}
+
+// Call is what a finished call is scored on
+type Call struct {
+	Prompts    []Prompt
+	Utterances []Utterance
+	Status     string   // final disposition
+	Interrupts []string // interrupt keys the caller's utterances matched
+}
+
+// Violation is one rubric check a call failed
+type Violation struct {
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+}
+
+// Result is a call's score out of MaxScore and what cost it points
+type Result struct {
+	Score      int         `json:"score"`
+	Violations []Violation `json:"violations,omitempty"`
+}
+
+// Score checks call against the rubric
+func (r *Rubric) Score(call Call) Result {
+	var violations []Violation
+	violations = append(violations, r.checkDisclosures(call)...)
+	violations = append(violations, r.checkTalkOver(call)...)
+	violations = append(violations, r.checkDisposition(call)...)
+
+	score := MaxScore
+	for _, v := range violations {
+		score -= r.weight(v.Check)
+	}
+	return Result{Score: max(score, 0), Violations: violations}
+}
+
+func (r *Rubric) weight(check string) int {
+	if w, ok := r.Weights[check]; ok {
+		return w
+	}
+	return DefaultWeights[check]
+}
+
+// checkDisclosures requires each disclosure to have played once without
+// being cut short
+func (r *Rubric) checkDisclosures(call Call) []Violation {
+	for _, status := range r.ExemptStatuses {
+		if status == call.Status {
+			return nil
+		}
+	}
+	var violations []Violation
+	for _, file := range r.Disclosures {
+		played, stopped := false, false
+		for _, p := range call.Prompts {
+			if p.File != file {
+				continue
+			}
+			if !p.Stopped {
+				played = true
+				break
+			}
+			stopped = true
+		}
+		switch {
+		case played:
+		case stopped:
+			violations = append(violations, Violation{CheckDisclosure, file + " was cut short"})
+		default:
+			violations = append(violations, Violation{CheckDisclosure, file + " was not played"})
+		}
+	}
+	return violations
+}
+
+// checkTalkOver flags compliance lines the caller spoke over
+func (r *Rubric) checkTalkOver(call Call) []Violation {
+	allowed := r.TalkOverSeconds
+	if allowed == 0 {
+		allowed = DefaultTalkOverSeconds
+	}
+	var violations []Violation
+	for _, p := range call.Prompts {
+		if !contains(r.ComplianceLines, p.File) {
+			continue
+		}
+		for _, u := range call.Utterances {
+			if overlap := min(p.End, u.End) - max(p.Start, u.Start); overlap > allowed {
+				violations = append(violations, Violation{CheckTalkOver,
+					fmt.Sprintf("caller spoke over %s at %.1fs: %q", p.File, max(p.Start, u.Start), u.Text)})
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// checkDisposition compares the final status with the interrupts heard: a
+// heard interrupt calls for its status, and a status the rubric maps to
+// needs one of its interrupts in the transcript
+func (r *Rubric) checkDisposition(call Call) []Violation {
+	if len(r.Dispositions) == 0 {
+		return nil
+	}
+	var expected []string
+	for _, key := range call.Interrupts {
+		if status, ok := r.Dispositions[key]; ok && !contains(expected, status) {
+			expected = append(expected, status)
+		}
+	}
+	if len(expected) > 0 && !contains(expected, call.Status) {
+		return []Violation{{CheckDisposition, fmt.Sprintf("dispositioned %s, but the caller's words call for %s", call.Status, strings.Join(expected, " or "))}}
+	}
+	if len(expected) == 0 {
+		var keys []string
+		for key, status := range r.Dispositions {
+			if status == call.Status {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) > 0 {
+			sort.Strings(keys)
+			return []Violation{{CheckDisposition, fmt.Sprintf("dispositioned %s without %s in the transcript", call.Status, strings.Join(keys, " or "))}}
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package qa
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	rubric, err := LoadRubric("../../config/qa.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clean := Call{
+		Prompts: []Prompt{
+			{File: "hello.wav", Start: 0, End: 2, Stopped: true},
+			{File: "greeting.wav", Start: 2, End: 8},
+		},
+		Utterances: []Utterance{{Text: "hello", Start: 1, End: 2.3}, {Text: "stop calling me", Start: 8.2, End: 9}},
+		Status:     "DNC",
+		Interrupts: []string{"dnc"},
+	}
+	if got := rubric.Score(clean); got.Score != MaxScore || len(got.Violations) != 0 {
+		t.Errorf("clean call = %+v", got)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		call   Call
+		score  int
+		checks []string
+		detail string
+	}{
+		{"disclosure not played", Call{Status: "NI", Interrupts: []string{"not_interested"}},
+			60, []string{CheckDisclosure}, "greeting.wav was not played"},
+		{"exempt status", Call{Status: "DC"}, 100, nil, ""},
+		{"cut short and talked over", Call{
+			Prompts:    []Prompt{{File: "greeting.wav", Start: 2, End: 4, Stopped: true}},
+			Utterances: []Utterance{{Text: "who is this", Start: 3, End: 4.5}},
+			Status:     "CALLBK", Interrupts: []string{"callback"},
+		}, 40, []string{CheckDisclosure, CheckTalkOver}, `caller spoke over greeting.wav at 3.0s: "who is this"`},
+		{"DNC request dispositioned NI", Call{
+			Prompts: clean.Prompts, Status: "NI", Interrupts: []string{"dnc"},
+		}, 60, []string{CheckDisposition}, "dispositioned NI, but the caller's words call for DNC"},
+		{"DNC without a request", Call{Prompts: clean.Prompts, Status: "DNC"},
+			60, []string{CheckDisposition}, "dispositioned DNC without dnc in the transcript"},
+		{"everything wrong", Call{Status: "DNC", Interrupts: []string{"callback"}},
+			20, []string{CheckDisclosure, CheckDisposition}, ""},
+	} {
+		got := rubric.Score(tt.call)
+		var checks []string
+		var details []string
+		for _, v := range got.Violations {
+			checks = append(checks, v.Check)
+			details = append(details, v.Detail)
+		}
+		if got.Score != tt.score || strings.Join(checks, ",") != strings.Join(tt.checks, ",") {
+			t.Errorf("%s: score %d, violations %+v; want %d, %v", tt.name, got.Score, got.Violations, tt.score, tt.checks)
+		}
+		if tt.detail != "" && !strings.Contains(strings.Join(details, "\n"), tt.detail) {
+			t.Errorf("%s: details %q lack %q", tt.name, details, tt.detail)
+		}
+	}
+
+	// Violations never take the score below zero
+	heavy := &Rubric{Disclosures: []string{"a.wav", "b.wav", "c.wav"}}
+	if got := heavy.Score(Call{}); got.Score != 0 || len(got.Violations) != 3 {
+		t.Errorf("three missing disclosures = %+v", got)
+	}
+}
+
+func TestLoadRubric(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "qa.yaml")
+	os.WriteFile(path, []byte("weights: {disclosure: 50, tone: 10}\n"), 0644)
+	if _, err := LoadRubric(path); err == nil || !strings.Contains(err.Error(), `"tone"`) {
+		t.Errorf("unknown check: %v", err)
+	}
+	os.WriteFile(path, []byte("talk_over_seconds: -1\n"), 0644)
+	if _, err := LoadRubric(path); err == nil {
+		t.Error("negative talk_over_seconds should be rejected")
+	}
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/buildinfo"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+// errSessionNotFound is returned for admin requests naming an unknown session
+var errSessionNotFound = errors.New("session not found")
+
+// WithAdminAddr serves the live session API on addr (e.g. "127.0.0.1:9020").
+// Without WithAdminAuth or WithAdminTLS client certificates the API is
+// unauthenticated; bind it to a private interface.
+func WithAdminAddr(addr string) Option {
+	return func(c *Config) { c.AdminAddr = addr }
+}
+
+// SessionInfo is the live view of an active session
+type SessionInfo struct {
+	ID         string       `json:"id"`
+	RemoteAddr string       `json:"remote_addr"`
+	ProxyAddr  string       `json:"proxy_addr,omitempty"` // load balancer the call came through
+	Provider   string       `json:"provider"`
+	StartTime  time.Time    `json:"start_time"`
+	Duration   float64      `json:"duration_seconds"`
+	Node       string       `json:"node,omitempty"`
+	LastFrame  float64      `json:"last_frame_age_seconds"`
+	Inbound    audio.Levels `json:"inbound"`
+	Outbound   audio.Levels `json:"outbound"`
+	Escalation string       `json:"escalation,omitempty"` // "agitated" or "shouting" with escalation detection
+	Background string       `json:"background,omitempty"` // caller environment once classified
+	Machine    string       `json:"machine,omitempty"`    // "human", "music" or "tones" once machine detection decides
+	Seed       int64        `json:"seed"`                 // random choices of the call derive from this
+	Phone      string       `json:"phone,omitempty"`      // number the call is with
+	LeadID     string       `json:"lead_id,omitempty"`
+}
+
+// Info returns a snapshot of the session for the live session API
+func (session *Session) Info() SessionInfo {
+	info := SessionInfo{
+		ID:         session.id.String(),
+		RemoteAddr: session.remoteAddr,
+		ProxyAddr:  session.proxyAddr,
+		StartTime:  session.startTime,
+		Duration:   time.Since(session.startTime).Seconds(),
+		LastFrame:  session.sinceLastFrame().Seconds(),
+		Inbound:    session.inLevel.Levels(),
+		Outbound:   session.outLevel.Levels(),
+		Seed:       session.seed,
+	}
+	session.liveMu.RLock()
+	info.Provider, info.Phone, info.LeadID = session.provider, session.phone, session.leadID
+	session.liveMu.RUnlock()
+	if session.escalation != nil {
+		info.Escalation = string(session.escalation.Level())
+	}
+	if session.background != nil {
+		info.Background = string(session.background.Result())
+	}
+	if session.machine != nil {
+		info.Machine = string(session.machine.Result())
+	}
+	if engine := session.engine(); engine != nil {
+		if node := engine.GetCurrentNode(); node != nil {
+			info.Node = node.ID
+		}
+	}
+	return info
+}
+
+// Sessions returns snapshots of all active sessions, oldest first
+func (s *Server) Sessions() []SessionInfo {
+	s.sessionsMu.RLock()
+	infos := make([]SessionInfo, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		infos = append(infos, session.Info())
+	}
+	s.sessionsMu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].StartTime.Before(infos[j].StartTime) })
+	return infos
+}
+
+// adminHandler serves the live session API:
+//
+//	GET /sessions       all active sessions
+//	GET /sessions/{id}  one session by UUID
+//	POST /sessions/{id}/hangup  end a call, {"status": "..."} optional (default the flow's last status, else DC)
+//	GET /metrics        counters in Prometheus text format
+//	GET /metrics/alerts default Prometheus alerting rules for those metrics
+//	GET /version        version, commit and build date of the binary
+//
+// plus the debug bundle export (see handleBundle), the flow deployment
+// endpoints (see handleFlows), the fleet lookup endpoints (see handleFleet),
+// the health probes (see handleHealth) and live listening (see handleListen)
+func (s *Server) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Sessions())
+	})
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		_ = metrics.WritePrometheus(w)
+	})
+	mux.HandleFunc("GET /metrics/alerts", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/yaml")
+		_ = metrics.WriteAlertRules(w, "audiosocket", metrics.DefaultAlertRules())
+	})
+	mux.HandleFunc("GET /sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		s.sessionsMu.RLock()
+		session, ok := s.sessions[r.PathValue("id")]
+		s.sessionsMu.RUnlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": errSessionNotFound.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, session.Info())
+	})
+	mux.HandleFunc("POST /sessions/{id}/hangup", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		var req struct {
+			Status string `json:"status"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+				return
+			}
+		}
+		s.sessionsMu.RLock()
+		session, ok := s.sessions[id]
+		s.sessionsMu.RUnlock()
+		if !ok {
+			s.audit(r, "hangup", id, nil, errSessionNotFound)
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": errSessionNotFound.Error()})
+			return
+		}
+		status := s.endSession(session, req.Status, "admin hangup")
+		s.audit(r, "hangup", id, map[string]string{"status": status}, nil)
+		writeJSON(w, http.StatusOK, map[string]string{"status": status})
+	})
+	mux.HandleFunc("GET /version", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, buildinfo.Get())
+	})
+	s.handleBundle(mux)
+	s.handleFlows(mux)
+	s.handleFleet(mux)
+	s.handleHealth(mux)
+	s.handleListen(mux)
+	return s.authorize(mux)
+}
+
+// startAdmin serves the admin API until Stop
+func (s *Server) startAdmin() {
+	s.admin = &http.Server{Addr: s.config.AdminAddr, Handler: s.adminHandler(), TLSConfig: s.adminTLS}
+	if !s.adminAuthEnabled() {
+		log.Printf("Warning: Admin API on %s is unauthenticated; configure API keys or mTLS unless it is bound to a private interface", s.config.AdminAddr)
+	}
+	go func() {
+		var err error
+		if s.adminTLS != nil {
+			log.Printf("Admin API listening on %s (HTTPS)", s.config.AdminAddr)
+			err = s.admin.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Admin API listening on %s", s.config.AdminAddr)
+			err = s.admin.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("Admin API error: %v", err)
+		}
+	}()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Admin API roles. Read covers every GET endpoint (sessions, metrics, flows,
+// fleet); control additionally allows the POST endpoints that change live
+// calls and deployments.
+const (
+	RoleRead    = "read"
+	RoleControl = "control"
+)
+
+// AdminCredential is a static API key for the admin API, sent as
+// "Authorization: Bearer <key>"
+type AdminCredential struct {
+	Name string // identifies the caller, e.g. in the audit log
+	Key  string
+	Role string // RoleRead or RoleControl
+}
+
+// WithAdminAuth requires an API key on the admin API. Only the health probes
+// (/healthz, /readyz) stay open. Combine with WithAdminTLS to also accept
+// client certificates.
+func WithAdminAuth(creds ...AdminCredential) Option {
+	return func(c *Config) { c.AdminCredentials = append(c.AdminCredentials, creds...) }
+}
+
+// WithAdminTLS serves the admin API over HTTPS with certFile and keyFile.
+// With clientCAFile, clients presenting a certificate signed by that CA are
+// authenticated by mTLS; clientRoles maps the certificate's common name to
+// its role, and certificates not listed there are refused.
+func WithAdminTLS(certFile, keyFile, clientCAFile string, clientRoles map[string]string) Option {
+	return func(c *Config) {
+		c.AdminTLSCert = certFile
+		c.AdminTLSKey = keyFile
+		c.AdminClientCA = clientCAFile
+		c.AdminClientRoles = clientRoles
+	}
+}
+
+// adminIdentity is the authenticated caller of an admin request
+type adminIdentity struct {
+	name string
+	role string
+}
+
+type adminIdentityKey struct{}
+
+// validateAdminAuth checks the admin authentication settings
+func validateAdminAuth(c *Config) error {
+	for _, cred := range c.AdminCredentials {
+		if cred.Key == "" {
+			return fmt.Errorf("admin credential %q has no key", cred.Name)
+		}
+		if cred.Role != RoleRead && cred.Role != RoleControl {
+			return fmt.Errorf("admin credential %q: role must be %q or %q", cred.Name, RoleRead, RoleControl)
+		}
+	}
+	for cn, role := range c.AdminClientRoles {
+		if role != RoleRead && role != RoleControl {
+			return fmt.Errorf("admin client %q: role must be %q or %q", cn, RoleRead, RoleControl)
+		}
+	}
+	if (c.AdminTLSCert == "") != (c.AdminTLSKey == "") {
+		return fmt.Errorf("admin TLS needs both a certificate and a key")
+	}
+	if c.AdminClientCA != "" && c.AdminTLSCert == "" {
+		return fmt.Errorf("admin client CA requires admin TLS")
+	}
+	return nil
+}
+
+// adminTLSConfig loads the admin API's TLS settings; nil when TLS is off
+func adminTLSConfig(c *Config) (*tls.Config, error) {
+	if c.AdminTLSCert == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.AdminTLSCert, c.AdminTLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load admin TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if c.AdminClientCA != "" {
+		pem, err := os.ReadFile(c.AdminClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read admin client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in admin client CA %s", c.AdminClientCA)
+		}
+		cfg.ClientCAs = pool
+		// Probes and API key clients connect without a certificate
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
+
+// adminAuthEnabled reports whether admin requests must authenticate
+func (s *Server) adminAuthEnabled() bool {
+	return len(s.config.AdminCredentials) > 0 || s.config.AdminClientCA != ""
+}
+
+// authorize wraps the admin API: requests must authenticate with an API key
+// or a client certificate, GET needs the read role and anything else the
+// control role. The health probes are always open, and listen requests with a
+// token are left to the listen handler to check.
+func (s *Server) authorize(next http.Handler) http.Handler {
+	if !s.adminAuthEnabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || s.tokenListen(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id, ok := s.identify(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && id.role != RoleControl {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "control role required"})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminIdentityKey{}, id)))
+	})
+}
+
+// identify authenticates r by its verified client certificate or API key
+func (s *Server) identify(r *http.Request) (adminIdentity, bool) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		if role, ok := s.config.AdminClientRoles[cn]; ok {
+			return adminIdentity{name: cn, role: role}, true
+		}
+	}
+
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || key == "" {
+		return adminIdentity{}, false
+	}
+	for _, cred := range s.config.AdminCredentials {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(cred.Key)) == 1 {
+			return adminIdentity{name: cred.Name, role: cred.Role}, true
+		}
+	}
+	return adminIdentity{}, false
+}
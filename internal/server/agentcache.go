@@ -0,0 +1,36 @@
+package server
+
+import (
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+// WithAgentCache shares the Vicidial agent user looked up for each lead
+// between the Vicidial clients of a call for ttl, keeping up to size leads.
+// Each client already remembers the agents it looked up; this saves the
+// lookup when the call's hangup, disposition and transfer checks each use
+// their own client. A lead's entry is dropped when its next call starts,
+// since another agent may take that one.
+func WithAgentCache(size int, ttl time.Duration) Option {
+	return func(c *Config) {
+		c.AgentCacheSize = size
+		c.AgentCacheTTL = ttl
+	}
+}
+
+// forgetAgent drops the agent user cached for the session's lead, left over
+// from an earlier call of the lead
+func (s *Server) forgetAgent(session *Session) {
+	if s.agentCache != nil && session.leadID != "" {
+		s.agentCache.Forget(session.leadID)
+	}
+}
+
+// newAgentCache returns the shared agent cache, nil when disabled
+func newAgentCache(c *Config) *flow.AgentCache {
+	if c.AgentCacheSize <= 0 || c.AgentCacheTTL <= 0 {
+		return nil
+	}
+	return flow.NewAgentCache(c.AgentCacheSize, c.AgentCacheTTL)
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"log"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+)
+
+// startAmbient starts the flow's ambient bed, if it has one, and reports
+// whether it is playing. The session connection mixes it into every prompt;
+// between prompts its outbound stream sends it on its own, replacing
+// comfort noise.
+func (session *Session) startAmbient() bool {
+	if session.flowEngine == nil {
+		return false
+	}
+	cfg := session.flowEngine.Metadata().Ambient
+	if cfg == nil || !cfg.Enabled {
+		return false
+	}
+	conn, ok := session.conn.(*sessionConn)
+	if !ok {
+		return false
+	}
+	ambient, err := session.server.audioPlayer.NewAmbient(cfg.File, audio.AmbientSettings{
+		LevelDB:  cfg.LevelDB,
+		DuckDB:   cfg.DuckDB,
+		PromptDB: cfg.PromptDB,
+		FadeMs:   cfg.FadeMs,
+		HoldMs:   cfg.HoldMs,
+	})
+	if err != nil {
+		log.Printf("Session %s: Ambient audio disabled: %v", session.id, err)
+		return false
+	}
+	conn.ambient.Store(ambient)
+	go func() {
+		<-session.stopAmbient
+		conn.ambient.Store(nil)
+	}()
+	log.Printf("Session %s: Ambient audio enabled (%s)", session.id, cfg.File)
+	return true
+}
+
+// watchAmbient mutes the ambient bed while the caller speaks
+func (session *Session) watchAmbient() {
+	conn, ok := session.conn.(*sessionConn)
+	if !ok || session.voice == nil {
+		return
+	}
+	if ambient := conn.ambient.Load(); ambient != nil && session.voice.Speaking() {
+		ambient.CallerSpeaking()
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithAuditLog records every admin API control action (flow deployments,
+// forced hangups) in an append-only log at path, separate from session logs.
+// Each record carries the hash of the one before it, so VerifyAuditLog
+// detects records that were edited, inserted, removed or reordered. Records
+// cut off the end leave a valid shorter chain; ship the log off the host to
+// guard against that.
+func WithAuditLog(path string) Option {
+	return func(c *Config) { c.AuditLogPath = path }
+}
+
+// AuditRecord is one admin action in the audit log
+type AuditRecord struct {
+	Time      time.Time         `json:"ts"`
+	Actor     string            `json:"actor"`
+	Action    string            `json:"action"`
+	SessionID string            `json:"session_id,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	Result    string            `json:"result"` // "ok" or the error
+	Prev      string            `json:"prev"`   // hash of the previous record
+	Hash      string            `json:"hash"`   // hash of this record with Hash empty
+}
+
+// digest returns the chain hash of rec
+func (rec AuditRecord) digest() (string, error) {
+	rec.Hash = ""
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// auditLog appends hash-chained records to a file
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+	last string // hash of the last record
+}
+
+// openAuditLog opens the audit log at path for appending, continuing the
+// hash chain of the records already in it
+func openAuditLog(path string) (*auditLog, error) {
+	_, last, err := scanAuditLog(path)
+	var broken *AuditChainError
+	switch {
+	case errors.As(err, &broken):
+		// Keep appending but make the break visible; the log is evidence
+		log.Printf("Warning: audit log %s fails verification: %v", path, err)
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &auditLog{file: f, last: last}, nil
+}
+
+// Record appends rec to the log, filling in its chain hashes
+func (a *auditLog) Record(rec AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rec.Prev = a.last
+	hash, err := rec.digest()
+	if err != nil {
+		return err
+	}
+	rec.Hash = hash
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := a.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := a.file.Sync(); err != nil {
+		return err
+	}
+	a.last = hash
+	return nil
+}
+
+// Close closes the log file
+func (a *auditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// AuditChainError reports the first audit record that breaks the hash chain
+type AuditChainError struct {
+	Line   int    // 1-based line of the offending record
+	Reason string // what is wrong with it
+}
+
+func (e *AuditChainError) Error() string {
+	return fmt.Sprintf("audit record %d: %s", e.Line, e.Reason)
+}
+
+// VerifyAuditLog checks the hash chain of the audit log at path and returns
+// the number of records. A record that was edited, removed, inserted or
+// reordered yields an *AuditChainError.
+func VerifyAuditLog(path string) (int, error) {
+	n, _, err := scanAuditLog(path)
+	return n, err
+}
+
+// scanAuditLog verifies the log and returns its record count and last hash
+func scanAuditLog(path string) (int, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	var chainErr *AuditChainError
+	prev, n := "", 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		n++
+		var rec AuditRecord
+		reason := ""
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			reason = "not a valid record"
+		} else if rec.Prev != prev {
+			reason = "does not follow the previous record"
+		} else if hash, err := rec.digest(); err != nil || hash != rec.Hash {
+			reason = "content does not match its hash"
+		}
+		if reason != "" && chainErr == nil {
+			chainErr = &AuditChainError{Line: n, Reason: reason}
+		}
+		prev = rec.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return n, prev, err
+	}
+	if chainErr != nil {
+		return n, prev, chainErr
+	}
+	return n, prev, nil
+}
+
+// auditActor identifies who made an admin request: the authenticated caller
+// and its address, or just the address when authentication is off
+func auditActor(r *http.Request) string {
+	if id, ok := r.Context().Value(adminIdentityKey{}).(adminIdentity); ok {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		return id.name + "@" + host
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// audit records an admin action if an audit log is configured; actionErr is
+// the action's outcome
+func (s *Server) audit(r *http.Request, action, sessionID string, details map[string]string, actionErr error) {
+	if s.auditLog == nil {
+		return
+	}
+	rec := AuditRecord{
+		Time:      time.Now().UTC(),
+		Actor:     auditActor(r),
+		Action:    action,
+		SessionID: sessionID,
+		Details:   details,
+		Result:    "ok",
+	}
+	if actionErr != nil {
+		rec.Result = actionErr.Error()
+	}
+	if err := s.auditLog.Record(rec); err != nil {
+		log.Printf("Failed to write audit record for %s by %s: %v", action, rec.Actor, err)
+	}
+}
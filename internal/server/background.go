@@ -0,0 +1,31 @@
+package server
+
+import (
+	"log"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+// WithBackgroundClassification classifies the caller's environment (quiet,
+// car, crowd, tv or noisy) once seconds of audio between their words have
+// been heard (audio.DefaultBackgroundSeconds if 0). The result is stored in
+// the "background" session variable and audio nodes can branch on it with
+// "background:<environment>" transitions.
+func WithBackgroundClassification(seconds float64) Option {
+	return func(c *Config) {
+		c.BackgroundClassification = true
+		c.BackgroundSeconds = seconds
+	}
+}
+
+// recordBackground stores the caller's environment once it is classified
+func (session *Session) recordBackground(env audio.Background) {
+	log.Printf("Session %s: Caller background classified as %s", session.id, env)
+	session.SetVar(flow.BackgroundVar, string(env))
+	if engine := session.engine(); engine != nil {
+		if logger := engine.GetSessionLogger(); logger != nil {
+			logger.LogBackground(session.id.String(), string(env))
+		}
+	}
+}
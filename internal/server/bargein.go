@@ -0,0 +1,51 @@
+package server
+
+import (
+	"log"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var bargeIns = metrics.NewCounter("audiosocket_barge_ins_total", "Prompts stopped because the caller started speaking over them")
+
+// PlayAudioBargeIn plays filename like PlayAudio, but stops it as soon as the
+// caller starts speaking rather than when the transcript arrives. The flow
+// uses it for nodes with barge_in.
+func (session *Session) PlayAudioBargeIn(filename string) error {
+	return session.playPrompt(session.playbacks.reserve(), filename, true)
+}
+
+// armBargeIn lets caller speech stop filename, which started playing, and
+// returns the function to call when it has finished
+func (session *Session) armBargeIn(filename string) func() {
+	if session.voice == nil {
+		return func() {}
+	}
+	session.voice.Reset()
+	prompt := &filename
+	session.bargeIn.Store(prompt)
+	return func() { session.bargeIn.CompareAndSwap(prompt, nil) }
+}
+
+// watchBargeIn follows the caller's audio for speech over a prompt
+func (session *Session) watchBargeIn(pcm []byte) {
+	if session.voice != nil && session.voice.Add(pcm) {
+		session.bargeInHeard()
+	}
+}
+
+// bargeInHeard stops the playing prompt if the caller may talk over it
+func (session *Session) bargeInHeard() {
+	prompt := session.bargeIn.Swap(nil)
+	if prompt == nil {
+		return
+	}
+	log.Printf("Session %s: Caller spoke over %s, stopping it", session.id, *prompt)
+	bargeIns.Inc()
+	if session.flowEngine != nil {
+		if logger := session.flowEngine.GetSessionLogger(); logger != nil {
+			logger.LogBargeIn(session.id.String(), *prompt)
+		}
+	}
+	session.StopAll()
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/buildinfo"
+	"github.com/google/uuid"
+)
+
+// BundleManifest describes the contents of a debug bundle
+type BundleManifest struct {
+	SessionID   string         `json:"session_id"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Build       buildinfo.Info `json:"build"`
+	Live        bool           `json:"live"`              // the call was still up; its files are incomplete
+	Session     *SessionInfo   `json:"session,omitempty"` // live view, for calls still up
+	Flow        *FlowVersion   `json:"flow,omitempty"`    // the flow the call ran
+	SampleRate  int            `json:"sample_rate"`       // of the .raw audio (16-bit mono PCM)
+	Files       []string       `json:"files"`
+	Missing     []string       `json:"missing,omitempty"` // files that could not be added
+}
+
+// handleBundle registers the debug bundle endpoint:
+//
+//	GET /sessions/{id}/bundle  zip of a call's session log, transcript, audio,
+//	                           debug captures, flow and config snapshot
+//
+// It works for active calls and for finished calls whose files are still in
+// the output directory.
+func (s *Server) handleBundle(mux *http.ServeMux) {
+	mux.HandleFunc("GET /sessions/{id}/bundle", func(w http.ResponseWriter, r *http.Request) {
+		id, err := uuid.Parse(r.PathValue("id"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid session id"})
+			return
+		}
+		files, err := s.sessionFiles(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		s.sessionsMu.RLock()
+		session := s.sessions[id.String()]
+		s.sessionsMu.RUnlock()
+		if session == nil && len(files) == 0 {
+			s.audit(r, "bundle", id.String(), nil, errSessionNotFound)
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": errSessionNotFound.Error()})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="session_%s.zip"`, id.String()[:8]))
+		err = s.writeBundle(w, id, session, files)
+		if err != nil {
+			log.Printf("Session %s: Failed to write debug bundle: %v", id, err)
+		}
+		s.audit(r, "bundle", id.String(), nil, err)
+	})
+}
+
+// sessionFiles lists the files saved for a session in the output directory:
+// session log, transcript, subtitles, audio and debug captures. They are
+// named <start>_<kind>_<first 8 characters of the UUID>.<ext>.
+func (s *Server) sessionFiles(id uuid.UUID) ([]string, error) {
+	dir := s.config.OutputDir
+	if dir == "" {
+		dir = "."
+	}
+	return filepath.Glob(filepath.Join(dir, "*_*_"+id.String()[:8]+".*"))
+}
+
+// writeBundle writes the debug bundle zip for a session; session is nil for
+// calls that have ended
+func (s *Server) writeBundle(w io.Writer, id uuid.UUID, session *Session, files []string) error {
+	manifest := BundleManifest{
+		SessionID:   id.String(),
+		GeneratedAt: time.Now().UTC(),
+		Build:       buildinfo.Get(),
+		Live:        session != nil,
+		SampleRate:  s.config.SampleRate,
+	}
+
+	zw := zip.NewWriter(w)
+	add := func(name string, write func(io.Writer) error) {
+		f, err := zw.Create(name)
+		if err == nil {
+			err = write(f)
+		}
+		if err != nil {
+			manifest.Missing = append(manifest.Missing, fmt.Sprintf("%s: %v", name, err))
+			return
+		}
+		manifest.Files = append(manifest.Files, name)
+	}
+
+	for _, file := range files {
+		add(filepath.Base(file), func(dst io.Writer) error { return copyFile(dst, file) })
+	}
+
+	if session != nil {
+		info := session.Info()
+		manifest.Session = &info
+		if session.flowVersion.Path != "" {
+			manifest.Flow = &session.flowVersion
+		}
+		if session.transcriber != nil {
+			add("transcript_live.txt", func(dst io.Writer) error {
+				_, err := io.WriteString(dst, session.transcriber.GetFullTranscript())
+				return err
+			})
+		}
+	} else if version, ok := flowVersionFromLog(files); ok {
+		manifest.Flow = &version
+	}
+	if manifest.Flow != nil {
+		add("flow.json", func(dst io.Writer) error { return copyFile(dst, manifest.Flow.Path) })
+	}
+
+	add("config.json", func(dst io.Writer) error {
+		enc := json.NewEncoder(dst)
+		enc.SetIndent("", "  ")
+		return enc.Encode(configSnapshot(s.config))
+	})
+
+	f, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// flowVersionFromLog reads the flow a finished call ran from its session log
+func flowVersionFromLog(files []string) (FlowVersion, bool) {
+	for _, file := range files {
+		if filepath.Ext(file) != ".jsonl" {
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			var rec struct {
+				Event   string            `json:"event"`
+				Details map[string]string `json:"details"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &rec) == nil && rec.Event == "flow_version" && rec.Details["path"] != "" {
+				f.Close()
+				return FlowVersion{Path: rec.Details["path"], Version: rec.Details["version"]}, true
+			}
+		}
+		f.Close()
+	}
+	return FlowVersion{}, false
+}
+
+// copyFile copies the file at path to dst
+func copyFile(dst io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(dst, f)
+	return err
+}
+
+// redacted replaces a secret in the config snapshot
+const redacted = "[redacted]"
+
+// configSnapshot returns the server settings for a debug bundle with secrets
+// redacted, including credentials in URLs. Code-only settings (transcriber factory, hooks, middleware,
+// notifiers, tts and re-transcription backends) are listed by type, so the
+// credentials they hold stay out. Registered providers' settings are listed by name
+// only, as any of them may be a credential.
+func configSnapshot(c Config) map[string]any {
+	if c.AssemblyAPIKey != "" {
+		c.AssemblyAPIKey = redacted
+	}
+	if c.Vicidial.APIPass != "" {
+		c.Vicidial.APIPass = redacted
+	}
+	c.VoskServerURL = withoutUserinfo(c.VoskServerURL)
+	urls := make([]string, len(c.VoskServerURLs))
+	for i, u := range c.VoskServerURLs {
+		urls[i] = withoutUserinfo(u)
+	}
+	c.VoskServerURLs = urls
+	c.RedisAddr = withoutUserinfo(c.RedisAddr)
+	c.Vicidial.ServerURL = withoutUserinfo(c.Vicidial.ServerURL)
+	creds := make([]AdminCredential, len(c.AdminCredentials))
+	for i, cred := range c.AdminCredentials {
+		cred.Key = redacted
+		creds[i] = cred
+	}
+	c.AdminCredentials = creds
+	providers := make(map[string]map[string]any, len(c.ProviderConfigs))
+	for name, config := range c.ProviderConfigs {
+		providers[name] = make(map[string]any, len(config))
+		for key := range config {
+			providers[name][key] = redacted
+		}
+	}
+	c.ProviderConfigs = providers
+
+	snapshot := make(map[string]any)
+	v := reflect.ValueOf(c)
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		switch {
+		case field.Type.Kind() == reflect.Func || field.Type.Kind() == reflect.Interface:
+			if !value.IsNil() {
+				snapshot[field.Name] = fmt.Sprintf("%T", value.Interface())
+			}
+		case field.Type.Kind() == reflect.Slice && (field.Type.Elem().Kind() == reflect.Func || field.Type.Elem().Kind() == reflect.Interface):
+			types := make([]string, value.Len())
+			for j := range types {
+				types[j] = fmt.Sprintf("%T", value.Index(j).Interface())
+			}
+			snapshot[field.Name] = types
+		case field.Type.Kind() == reflect.Map && (field.Type.Elem().Kind() == reflect.Func || field.Type.Elem().Kind() == reflect.Interface):
+			types := make(map[string]string, value.Len())
+			for iter := value.MapRange(); iter.Next(); {
+				types[fmt.Sprint(iter.Key().Interface())] = fmt.Sprintf("%T", iter.Value().Interface())
+			}
+			snapshot[field.Name] = types
+		default:
+			snapshot[field.Name] = value.Interface()
+		}
+	}
+	return snapshot
+}
+
+// withoutUserinfo drops the user and password from a URL such as
+// ws://user:pass@vosk:2700; anything else is returned as it is
+func withoutUserinfo(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return u.String()
+}
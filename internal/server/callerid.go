@@ -0,0 +1,37 @@
+package server
+
+import "log"
+
+// Where a session's phone number came from
+const (
+	callerFromVars    = "vars"     // a phone variable written by the dialer, usually to Redis
+	callerFromLeadAPI = "lead_api" // looked up from the lead with the Vicidial API
+)
+
+// captureCaller resolves the phone number the call is with and keeps it,
+// with the lead ID, on the session and in its phone_number variable, so
+// flows, logs, transcripts and the admin API carry it without consumers
+// re-joining on lead_id. The dialer usually provides the number; failing
+// that it is looked up from the lead when Vicidial is configured.
+func (s *Server) captureCaller(session *Session) {
+	leadID, _ := session.GetVar("lead_id")
+	phone, source := sessionPhone(session), callerFromVars
+	if phone == "" && leadID != "" && s.config.Vicidial.ServerURL != "" {
+		var err error
+		if phone, err = s.newVicidialClient().GetLeadPhone(leadID); err != nil {
+			log.Printf("Session %s: Failed to look up the phone number of lead %s: %v", session.id, leadID, err)
+		}
+		source = callerFromLeadAPI
+	}
+	session.liveMu.Lock()
+	session.leadID = leadID
+	if phone != "" {
+		session.phone, session.phoneSource = phone, source
+	}
+	session.liveMu.Unlock()
+	if phone == "" {
+		return
+	}
+	session.SetVar("phone_number", phone)
+	log.Printf("Session %s: Caller %s (lead %s, from %s)", session.id, phone, leadID, source)
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+)
+
+// errReconnected is returned by a read interrupted because the session
+// adopted a new connection
+var errReconnected = errors.New("connection replaced by reconnect")
+
+// sessionConn is the connection a session talks through. Its writes go
+// through an OutStream, which paces them and sends them one at a time. It
+// measures the level of every SLIN message written to the caller, whichever
+// component (prompts, comfort noise, ducking) sends it, mixes in the ambient
+// bed, notes when audio was last sent for keep-alives and passes it to live
+// listeners. It lets a reconnecting call swap in its new connection without
+// the rest of the session noticing.
+type sessionConn struct {
+	mu         sync.RWMutex
+	conn       net.Conn
+	generation uint64
+	out        *OutStream
+	meter      *audio.LevelMeter
+	lastAudio  atomic.Int64                  // UnixNano of the last SLIN message written
+	monitor    *liveMonitor                  // supervisors listening live; nil when disabled
+	ambient    atomic.Pointer[audio.Ambient] // background bed mixed into bot audio; nil when disabled
+}
+
+func newSessionConn(conn net.Conn, meter *audio.LevelMeter) *sessionConn {
+	c := &sessionConn{conn: conn, meter: meter}
+	c.lastAudio.Store(time.Now().UnixNano())
+	c.out = newOutStream(c.deliver, c.sendIdle)
+	return c
+}
+
+// sinceAudio returns how long ago audio was last sent to the caller
+func (c *sessionConn) sinceAudio() time.Duration {
+	return time.Since(time.Unix(0, c.lastAudio.Load()))
+}
+
+func (c *sessionConn) current() (net.Conn, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn, c.generation
+}
+
+// swap replaces the underlying connection and returns the previous one
+func (c *sessionConn) swap(conn net.Conn) net.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old := c.conn
+	c.conn = conn
+	c.generation++
+	return old
+}
+
+func (c *sessionConn) Read(b []byte) (int, error) {
+	conn, gen := c.current()
+	n, err := conn.Read(b)
+	if err != nil {
+		if _, now := c.current(); now != gen {
+			return n, errReconnected
+		}
+	}
+	return n, err
+}
+
+// Write sends an AudioSocket message: audio on the next free 20ms tick,
+// anything else at once
+func (c *sessionConn) Write(b []byte) (int, error) {
+	return c.send(b, outPrompt)
+}
+
+// send queues b on the outbound stream at priority
+func (c *sessionConn) send(b []byte, priority int) (int, error) {
+	if err := c.out.send(b, priority, !isSlin(b)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// PacesFrames tells audio sources that writes return once their frame is
+// due, so they need not sleep between frames
+func (c *sessionConn) PacesFrames() bool { return true }
+
+// filler returns a view of conn whose audio only goes out when no prompt
+// frame is waiting, for comfort noise; conn itself if it is not a session
+// connection
+func filler(conn net.Conn) net.Conn {
+	if c, ok := conn.(*sessionConn); ok {
+		return fillerConn{c}
+	}
+	return conn
+}
+
+// fillerConn is a sessionConn writing at filler priority
+type fillerConn struct{ *sessionConn }
+
+func (f fillerConn) Write(b []byte) (int, error) {
+	return f.send(b, outFiller)
+}
+
+// isSlin reports whether b is an AudioSocket audio message: 1 byte kind,
+// 2 byte length, payload
+func isSlin(b []byte) bool {
+	return len(b) > 3 && audiosocket.Kind(b[0]) == audiosocket.KindSlin
+}
+
+// deliver writes a message from the outbound stream to the connection,
+// mixing the ambient bed into audio
+func (c *sessionConn) deliver(b []byte) error {
+	if isSlin(b) {
+		pcm := b[3:]
+		if ambient := c.ambient.Load(); ambient != nil {
+			pcm = ambient.Mix(pcm)
+		}
+		_, err := c.writeSlin(pcm)
+		return err
+	}
+	conn, _ := c.current()
+	_, err := conn.Write(b)
+	return err
+}
+
+// sendIdle sends a frame of the ambient bed on a tick with nothing to send
+func (c *sessionConn) sendIdle() error {
+	ambient := c.ambient.Load()
+	if ambient == nil {
+		return nil
+	}
+	_, err := c.writeSlin(ambient.IdleFrame())
+	return err
+}
+
+// writeSlin writes pcm to the connection as is; only the outbound stream
+// calls it
+func (c *sessionConn) writeSlin(pcm []byte) (int, error) {
+	c.sent(pcm)
+	conn, _ := c.current()
+	return conn.Write(audiosocket.SlinMessage(pcm))
+}
+
+// sent notes bot audio on its way to the caller
+func (c *sessionConn) sent(pcm []byte) {
+	c.lastAudio.Store(time.Now().UnixNano())
+	if c.meter != nil {
+		c.meter.Add(pcm)
+	}
+	c.monitor.addBot(pcm)
+}
+
+func (c *sessionConn) Close() error {
+	c.out.close()
+	conn, _ := c.current()
+	return conn.Close()
+}
+
+func (c *sessionConn) LocalAddr() net.Addr {
+	conn, _ := c.current()
+	return conn.LocalAddr()
+}
+
+func (c *sessionConn) RemoteAddr() net.Addr {
+	conn, _ := c.current()
+	return conn.RemoteAddr()
+}
+
+func (c *sessionConn) SetDeadline(t time.Time) error {
+	conn, _ := c.current()
+	return conn.SetDeadline(t)
+}
+
+func (c *sessionConn) SetReadDeadline(t time.Time) error {
+	conn, _ := c.current()
+	return conn.SetReadDeadline(t)
+}
+
+func (c *sessionConn) SetWriteDeadline(t time.Time) error {
+	conn, _ := c.current()
+	return conn.SetWriteDeadline(t)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var deadAirHangups = metrics.NewCounter("audiosocket_dead_air_hangups_total", "Calls hung up because no caller audio arrived (one-way audio)")
+
+// DefaultDeadAirStatus is the disposition for calls with no inbound audio
+const DefaultDeadAirStatus = "DC"
+
+// WithOneWayAudioDetection hangs up calls whose caller audio carries no
+// energy during the first timeout, dispositioning them with status
+// (DefaultDeadAirStatus if empty) instead of running the whole flow against
+// a dead channel
+func WithOneWayAudioDetection(timeout time.Duration, status string) Option {
+	return func(c *Config) {
+		c.DeadAirTimeout = timeout
+		c.DeadAirStatus = status
+	}
+}
+
+// watchDeadAir ends the call if no caller audio energy arrived within the
+// configured timeout. done is closed when the session ends.
+func (s *Server) watchDeadAir(session *Session, done <-chan struct{}) {
+	timer := time.NewTimer(s.config.DeadAirTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	levels := session.inLevel.Levels()
+	if !levels.NearSilent() {
+		return
+	}
+
+	status := s.config.DeadAirStatus
+	if status == "" {
+		status = DefaultDeadAirStatus
+	}
+	log.Printf("Session %s: No inbound audio after %v (RMS %.1f dBFS, %d samples), one-way audio suspected; hanging up with %s",
+		session.id, s.config.DeadAirTimeout, levels.RMSDB, levels.Samples, status)
+
+	deadAirHangups.Inc()
+	s.dispose(session, status)
+	if err := session.EndCall(); err != nil {
+		log.Printf("Session %s: %v", session.id, err)
+	}
+}
+
+// dispose posts the final status for a session the server itself ends, once
+func (s *Server) dispose(session *Session, status string) {
+	if !session.dispositioned.CompareAndSwap(false, true) {
+		return
+	}
+	s.countDisposition(session, status)
+	if s.config.Vicidial.ServerURL == "" {
+		return
+	}
+	apiClient := s.newVicidialClient()
+	apiClient.SetRedis(s.redis, s.config.RedisPrefix)
+	if engine := session.engine(); engine != nil {
+		apiClient.SetLogger(engine.GetSessionLogger())
+	}
+	s.postDisposition(apiClient, session, status)
+}
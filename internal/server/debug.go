@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+)
+
+// WithDebugSampling captures detailed debug artifacts for a sample of calls:
+// every partial and final result, per-chunk audio timing and the raw provider
+// messages, written as JSONL to <output dir>/<time>_debug_<id>.jsonl. rate is
+// the sampled fraction of calls (0.01 = 1%); calls for leadIDs are always
+// captured.
+func WithDebugSampling(rate float64, leadIDs ...string) Option {
+	return func(c *Config) {
+		c.DebugSampleRate = rate
+		c.DebugLeadIDs = append(c.DebugLeadIDs, leadIDs...)
+	}
+}
+
+// debugRecord is one line of a debug capture
+type debugRecord struct {
+	T         float64 `json:"t"` // seconds since the capture started
+	Event     string  `json:"event"`
+	Text      string  `json:"text,omitempty"`
+	Start     float64 `json:"start,omitempty"`
+	End       float64 `json:"end,omitempty"`
+	Bytes     int     `json:"bytes,omitempty"`
+	GapMs     float64 `json:"gap_ms,omitempty"`
+	ProcessMs float64 `json:"process_ms,omitempty"`
+	Raw       string  `json:"raw,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// debugCapture writes the debug artifacts of one sampled session
+type debugCapture struct {
+	mu        sync.Mutex
+	file      *os.File
+	enc       *json.Encoder
+	start     time.Time
+	lastChunk time.Time
+}
+
+// sampleDebug decides whether a session gets a debug capture
+func (s *Server) sampleDebug(session *Session) (bool, string) {
+	if len(s.config.DebugLeadIDs) > 0 {
+		if leadID, ok := session.GetVar("lead_id"); ok {
+			for _, id := range s.config.DebugLeadIDs {
+				if id == leadID {
+					return true, "lead " + leadID
+				}
+			}
+		}
+	}
+	if s.config.DebugSampleRate > 0 && flow.NewRand(session.seed, flow.RandDebug).Float64() < s.config.DebugSampleRate {
+		return true, "sampled"
+	}
+	return false, ""
+}
+
+// startDebugCapture opens a debug capture if the session is sampled
+func (s *Server) startDebugCapture(session *Session) *debugCapture {
+	sampled, reason := s.sampleDebug(session)
+	if !sampled {
+		return nil
+	}
+
+	dir := s.config.OutputDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Session %s: Failed to create debug capture directory: %v", session.id, err)
+		return nil
+	}
+	id := session.id.String()
+	filename := filepath.Join(dir, fmt.Sprintf("%s_debug_%s.jsonl", session.startTime.Format("20060102_150405"), id[:8]))
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Printf("Session %s: Failed to create debug capture: %v", session.id, err)
+		return nil
+	}
+
+	log.Printf("Session %s: Debug capture enabled (%s): %s", session.id, reason, filename)
+	capture := &debugCapture{file: f, enc: json.NewEncoder(f), start: time.Now()}
+	capture.write(debugRecord{Event: "start", Text: reason})
+	return capture
+}
+
+func (c *debugCapture) write(rec debugRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return
+	}
+	rec.T = time.Since(c.start).Seconds()
+	_ = c.enc.Encode(rec)
+}
+
+// attach records results, chunk timing and raw provider messages of t
+func (c *debugCapture) attach(t *transcriber.TimedTranscriber) {
+	t.OnResult(func(r transcriber.TranscriptionResult) {
+		event := "partial"
+		if r.IsFinal {
+			event = "final"
+		}
+		c.write(debugRecord{Event: event, Text: r.Text, Start: r.Start, End: r.End})
+	})
+	if !transcriber.OnRawFrame(t, func(f transcriber.RawFrame) {
+		if f.Direction == transcriber.FrameRecv {
+			c.write(debugRecord{Event: "provider", Raw: string(f.Data)})
+		}
+	}) {
+		c.write(debugRecord{Event: "provider", Error: "raw messages not supported by provider"})
+	}
+}
+
+// chunk records an inbound audio chunk and how long the transcriber took
+func (c *debugCapture) chunk(size int, arrived time.Time, process time.Duration, err error) {
+	c.mu.Lock()
+	var gap float64
+	if !c.lastChunk.IsZero() {
+		gap = float64(arrived.Sub(c.lastChunk).Microseconds()) / 1000
+	}
+	c.lastChunk = arrived
+	c.mu.Unlock()
+
+	rec := debugRecord{Event: "chunk", Bytes: size, GapMs: gap, ProcessMs: float64(process.Microseconds()) / 1000}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	c.write(rec)
+}
+
+// Close ends the capture
+func (c *debugCapture) Close() error {
+	c.write(debugRecord{Event: "end"})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
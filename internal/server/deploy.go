@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+// FlowVersion is a deployed flow definition
+type FlowVersion struct {
+	Path       string    `json:"path"`
+	Name       string    `json:"name,omitempty"`
+	Version    string    `json:"version,omitempty"`
+	DeployedAt time.Time `json:"deployed_at"`
+}
+
+// Label identifies the version in logs
+func (v FlowVersion) Label() string {
+	if v.Version != "" {
+		return v.Version
+	}
+	return v.Path
+}
+
+// FlowDeployment holds the flow versions of one campaign; the empty campaign
+// is the default used by campaigns without their own deployment. New calls
+// run Active; Staged waits for promotion and Previous is kept for rollback.
+type FlowDeployment struct {
+	Campaign string       `json:"campaign,omitempty"`
+	Active   *FlowVersion `json:"active,omitempty"`
+	Staged   *FlowVersion `json:"staged,omitempty"`
+	Previous *FlowVersion `json:"previous,omitempty"`
+}
+
+// flowDeployments tracks blue/green flow versions per campaign
+type flowDeployments struct {
+	mu        sync.RWMutex
+	campaigns map[string]*FlowDeployment
+}
+
+// newFlowDeployments starts with path active for every campaign
+func newFlowDeployments(path string) *flowDeployments {
+	active := &FlowVersion{Path: path, DeployedAt: time.Now()}
+	if meta, err := flow.LoadFlowMetadata(path); err == nil {
+		active.Name = meta.Name
+		active.Version = meta.Version
+	}
+	return &flowDeployments{campaigns: map[string]*FlowDeployment{"": {Active: active}}}
+}
+
+// Active returns the flow version new calls for campaign should run
+func (d *flowDeployments) Active(campaign string) FlowVersion {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if dep, ok := d.campaigns[campaign]; ok && dep.Active != nil {
+		return *dep.Active
+	}
+	return *d.campaigns[""].Active
+}
+
+// Stage validates the flow file at path and stages it for campaign
+func (d *flowDeployments) Stage(campaign, path string) (FlowVersion, error) {
+	meta, err := flow.LoadFlowMetadata(path)
+	if err != nil {
+		return FlowVersion{}, fmt.Errorf("invalid flow %s: %w", path, err)
+	}
+	staged := &FlowVersion{Path: path, Name: meta.Name, Version: meta.Version, DeployedAt: time.Now()}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dep, ok := d.campaigns[campaign]
+	if !ok {
+		dep = &FlowDeployment{Campaign: campaign}
+		d.campaigns[campaign] = dep
+	}
+	dep.Staged = staged
+	return *staged, nil
+}
+
+// Promote makes the staged version active, keeping the old one for rollback
+func (d *flowDeployments) Promote(campaign string) (FlowVersion, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dep, ok := d.campaigns[campaign]
+	if !ok || dep.Staged == nil {
+		return FlowVersion{}, fmt.Errorf("no flow staged for campaign %q", campaign)
+	}
+	dep.Previous = dep.Active
+	if dep.Previous == nil {
+		// First campaign-specific deployment; roll back to the default
+		def := *d.campaigns[""].Active
+		dep.Previous = &def
+	}
+	dep.Active = dep.Staged
+	dep.Staged = nil
+	dep.Active.DeployedAt = time.Now()
+	return *dep.Active, nil
+}
+
+// Rollback swaps the active and previous versions, so a second rollback
+// restores the version that was rolled back
+func (d *flowDeployments) Rollback(campaign string) (FlowVersion, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dep, ok := d.campaigns[campaign]
+	if !ok || dep.Previous == nil {
+		return FlowVersion{}, fmt.Errorf("no previous flow for campaign %q", campaign)
+	}
+	dep.Active, dep.Previous = dep.Previous, dep.Active
+	dep.Active.DeployedAt = time.Now()
+	return *dep.Active, nil
+}
+
+// List returns a snapshot of all deployments, default first
+func (d *flowDeployments) List() []FlowDeployment {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	list := make([]FlowDeployment, 0, len(d.campaigns))
+	for _, dep := range d.campaigns {
+		list = append(list, *dep)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Campaign < list[j].Campaign })
+	return list
+}
+
+// flowRequest is the body of the flow deployment admin endpoints
+type flowRequest struct {
+	Campaign string `json:"campaign"`
+	Path     string `json:"path"`
+}
+
+// handleFlows registers the flow deployment endpoints on the admin API:
+//
+//	GET  /flows           deployments per campaign
+//	POST /flows/stage     {"campaign": "...", "path": "..."}
+//	POST /flows/promote   {"campaign": "..."}
+//	POST /flows/rollback  {"campaign": "..."}
+func (s *Server) handleFlows(mux *http.ServeMux) {
+	mux.HandleFunc("GET /flows", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.flows.List())
+	})
+
+	actions := map[string]func(req flowRequest) (FlowVersion, error){
+		"stage": func(req flowRequest) (FlowVersion, error) {
+			if s.config.TTS != nil {
+				if err := s.synthesizeFlow(req.Path); err != nil {
+					log.Printf("Warning: Staging %s: %v", req.Path, err)
+				}
+			}
+			if err := s.loadSayPrompts(req.Path); err != nil {
+				return FlowVersion{}, err
+			}
+			if s.config.PromptCheck && s.audioPlayer != nil {
+				if err := s.verifyPrompts(req.Path); err != nil {
+					return FlowVersion{}, err
+				}
+			}
+			return s.flows.Stage(req.Campaign, req.Path)
+		},
+		"promote": func(req flowRequest) (FlowVersion, error) {
+			return s.flows.Promote(req.Campaign)
+		},
+		"rollback": func(req flowRequest) (FlowVersion, error) {
+			return s.flows.Rollback(req.Campaign)
+		},
+	}
+	for name, action := range actions {
+		name, action := name, action
+		mux.HandleFunc("POST /flows/"+name, func(w http.ResponseWriter, r *http.Request) {
+			var req flowRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+				return
+			}
+			version, err := action(req)
+			s.audit(r, "flow_"+name, "", map[string]string{"campaign": req.Campaign, "path": req.Path, "version": version.Label()}, err)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			log.Printf("Flow %s for campaign %q: %s (%s)", name, req.Campaign, version.Label(), version.Path)
+			writeJSON(w, http.StatusOK, version)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+)
+
+// dialogueTurn is one side of the conversation on the call timeline
+type dialogueTurn struct {
+	speaker string
+	transcriber.Utterance
+}
+
+// playedPrompt is a bot prompt on the call timeline
+type playedPrompt struct {
+	transcriber.Utterance
+	file    string
+	stopped bool // cut short by StopPlayback, StopAll or barge-in
+}
+
+// recordPrompt remembers a bot prompt so the saved transcript reads as a
+// dialogue and QA can check what was played. start and end are seconds from
+// call start.
+func (session *Session) recordPrompt(file, text string, start, end float64, stopped bool) {
+	session.promptsMu.Lock()
+	defer session.promptsMu.Unlock()
+	session.prompts = append(session.prompts, playedPrompt{
+		Utterance: transcriber.Utterance{Text: text, Start: start, End: end},
+		file:      file,
+		stopped:   stopped,
+	})
+}
+
+// playedPrompts returns the prompts played so far
+func (session *Session) playedPrompts() []playedPrompt {
+	session.promptsMu.Lock()
+	defer session.promptsMu.Unlock()
+	return append([]playedPrompt(nil), session.prompts...)
+}
+
+// promptText returns the text to show for a prompt: the current node's
+// content when it is the node being played, otherwise the file name
+func (session *Session) promptText(filename string) string {
+	if session.flowEngine != nil {
+		if node := session.flowEngine.GetCurrentNode(); node != nil && node.Content != "" {
+			for _, file := range node.AudioFiles() {
+				if node.PromptFor(file) == filename {
+					return node.Content
+				}
+			}
+		}
+	}
+	return filename
+}
+
+// renderDialogue interleaves bot prompts and caller utterances by start time
+func renderDialogue(prompts []playedPrompt, utterances []transcriber.Utterance) string {
+	turns := make([]dialogueTurn, 0, len(prompts)+len(utterances))
+	for _, p := range prompts {
+		turns = append(turns, dialogueTurn{speaker: "BOT", Utterance: p.Utterance})
+	}
+	for _, u := range utterances {
+		turns = append(turns, dialogueTurn{speaker: "CALLER", Utterance: u})
+	}
+	sort.SliceStable(turns, func(i, j int) bool { return turns[i].Start < turns[j].Start })
+
+	var b strings.Builder
+	for _, t := range turns {
+		fmt.Fprintf(&b, "[%7.2f - %7.2f] %-6s %s\n", t.Start, t.End, t.speaker+":", t.Text)
+	}
+	return b.String()
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+	redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultDNCPrompt is played to callers found on the local DNC list
+	DefaultDNCPrompt = "dnc.wav"
+	// DNCStatus is the disposition posted for calls blocked by the DNC list
+	DNCStatus = "DNC"
+)
+
+// dncBlocked counts calls ended because the number was on the local DNC list
+var dncBlocked = metrics.NewCounter("audiosocket_dnc_blocked_total", "Calls ended at session start because the number was on the local DNC list")
+
+// WithDNCList checks every call against an internal do-not-call list before
+// the flow starts: a Redis set named redisKey and/or a file with one number
+// per line (# starts a comment). Listed callers hear prompt
+// (DefaultDNCPrompt if empty), are dispositioned DNC and hung up. The file is
+// reloaded when it changes.
+func WithDNCList(redisKey, file, prompt string) Option {
+	return func(c *Config) {
+		c.DNCRedisKey = redisKey
+		c.DNCFile = file
+		c.DNCPrompt = prompt
+	}
+}
+
+// dncList is the local do-not-call list
+type dncList struct {
+	redis    *redis.Client
+	redisKey string
+
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	numbers map[string]bool
+}
+
+// newDNCList creates the list and loads the file, if any
+func newDNCList(client *redis.Client, redisKey, path string) (*dncList, error) {
+	list := &dncList{redis: client, redisKey: redisKey, path: path}
+	if path != "" {
+		if err := list.reload(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// reload reads the file again if it was modified since the last load
+func (l *dncList) reload() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat DNC file: %w", err)
+	}
+	if l.numbers != nil && info.ModTime().Equal(l.modTime) {
+		return nil
+	}
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to open DNC file: %w", err)
+	}
+	defer f.Close()
+
+	numbers := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if n := normalizePhone(line); n != "" {
+			numbers[n] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read DNC file: %w", err)
+	}
+
+	l.numbers = numbers
+	l.modTime = info.ModTime()
+	log.Printf("Loaded %d DNC numbers from %s", len(numbers), l.path)
+	return nil
+}
+
+// Contains reports whether phone is on the list. Numbers are compared as
+// digits only, both in full and as their last 10 digits so a leading country
+// code does not matter.
+func (l *dncList) Contains(phone string) bool {
+	candidates := phoneCandidates(phone)
+	if len(candidates) == 0 {
+		return false
+	}
+
+	if l.path != "" {
+		l.mu.Lock()
+		if err := l.reload(); err != nil {
+			log.Printf("Warning: %v; using previously loaded DNC numbers", err)
+		}
+		for _, c := range candidates {
+			if l.numbers[c] {
+				l.mu.Unlock()
+				return true
+			}
+		}
+		l.mu.Unlock()
+	}
+
+	if l.redis != nil && l.redisKey != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		for _, c := range candidates {
+			listed, err := l.redis.SIsMember(ctx, l.redisKey, c).Result()
+			if err != nil {
+				log.Printf("Warning: DNC lookup in Redis set %s failed: %v", l.redisKey, err)
+				break
+			}
+			if listed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizePhone strips everything but digits
+func normalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// phoneCandidates returns the forms of phone looked up on the list
+func phoneCandidates(phone string) []string {
+	n := normalizePhone(phone)
+	if n == "" {
+		return nil
+	}
+	candidates := []string{n}
+	if len(n) > 10 {
+		candidates = append(candidates, n[len(n)-10:])
+	}
+	return candidates
+}
+
+// sessionPhone returns the caller's number from the first non-empty phone variable
+func sessionPhone(session *Session) string {
+	for _, k := range []string{"phone_number", "phone", "callerid", "cid", "ani"} {
+		if v, ok := session.GetVar(k); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// checkDNC ends the call if the caller is on the local DNC list and reports
+// whether it did
+func (s *Server) checkDNC(session *Session) bool {
+	if s.dnc == nil {
+		return false
+	}
+	phone := sessionPhone(session)
+	if phone == "" || !s.dnc.Contains(phone) {
+		return false
+	}
+
+	dncBlocked.Inc()
+	log.Printf("Session %s: %s is on the DNC list; ending call without running the flow", session.id, phone)
+
+	if s.audioPlayer != nil {
+		prompt := s.config.DNCPrompt
+		if prompt == "" {
+			prompt = DefaultDNCPrompt
+		}
+		stop, done := session.playback()
+		defer done()
+		if err := s.audioPlayer.PlayAudioWithStop(session.conn, prompt, stop); err != nil {
+			log.Printf("Session %s: Failed to play DNC message: %v", session.id, err)
+		}
+	}
+	s.dispose(session, DNCStatus)
+	if err := session.EndCall(); err != nil {
+		log.Printf("Session %s: %v", session.id, err)
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// DefaultDrainTimeout leaves a few seconds of Kubernetes' default 30s
+// terminationGracePeriodSeconds for dispositions and saving transcripts
+const DefaultDrainTimeout = 25 * time.Second
+
+// WithDrain sets how long Drain waits for active calls to end before hanging
+// them up, and the disposition posted for calls cut off that way; when status
+// is empty the flow's last status is posted, else DC. Keep timeout below the
+// pod's terminationGracePeriodSeconds.
+func WithDrain(timeout time.Duration, status string) Option {
+	return func(c *Config) {
+		c.DrainTimeout = timeout
+		c.DrainStatus = status
+	}
+}
+
+// Ready reports whether the server accepts new calls; it is false before
+// Start listens and once Drain or Stop begins
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+// handleHealth registers the probe endpoints on the admin API:
+//
+//	GET /healthz  200 while the process is up (liveness)
+//	GET /readyz   200 while accepting calls, 503 when starting or draining
+func (s *Server) handleHealth(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !s.Ready() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// Drain shuts the server down gracefully, e.g. on SIGTERM during a rolling
+// update: readiness turns false, the instance leaves the fleet, no new
+// connections are accepted and active calls get up to the drain timeout to
+// finish. Calls still running then are dispositioned and hung up. The admin
+// API keeps serving until the end so probes see the server draining.
+func (s *Server) Drain() {
+	timeout := s.config.DrainTimeout
+	if timeout <= 0 {
+		timeout = DefaultDrainTimeout
+	}
+	s.ready.Store(false)
+	s.stopAccepting()
+
+	log.Printf("Draining %d active sessions (up to %v)", s.activeSessions(), timeout)
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("All sessions ended, shutting down")
+	case <-time.After(timeout):
+		s.endSessions()
+		<-done
+	}
+	s.release()
+}
+
+// activeSessions returns the number of active sessions
+func (s *Server) activeSessions() int {
+	s.sessionsMu.RLock()
+	defer s.sessionsMu.RUnlock()
+	return len(s.sessions)
+}
+
+// endSessions posts final dispositions for the calls still active at the
+// end of a drain and hangs them up
+func (s *Server) endSessions() {
+	s.sessionsMu.RLock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.sessionsMu.RUnlock()
+
+	log.Printf("Drain timeout reached, ending %d sessions", len(sessions))
+	for _, session := range sessions {
+		s.endSession(session, s.config.DrainStatus, "shutdown")
+	}
+}
+
+// endSession posts a final disposition for an active call and hangs it up.
+// Without a status the flow's last status is posted, DC when it has none.
+func (s *Server) endSession(session *Session, status, reason string) string {
+	engine := session.engine()
+	if status == "" && engine != nil {
+		status = engine.GetLastReason()
+	}
+	if status == "" {
+		status = DefaultDeadAirStatus
+	}
+	if engine != nil && engine.WasTransferred() {
+		// Transfer already reported; do not override it
+		if session.dispositioned.CompareAndSwap(false, true) {
+			s.countDisposition(session, status)
+		}
+	}
+	log.Printf("Session %s: Ending call for %s (%s)", session.id, reason, status)
+	s.dispose(session, status)
+	if err := session.EndCall(); err != nil {
+		log.Printf("Session %s: %v", session.id, err)
+	}
+	session.conn.Close()
+	return status
+}
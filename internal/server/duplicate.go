@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var activeSessions = metrics.NewGauge("audiosocket_sessions_active", "Calls currently in progress")
+
+// Policies for a connection whose UUID already has an active session, which
+// happens when Asterisk retries a call it believes failed
+const (
+	DuplicateReject = "reject" // hang up the new connection (default)
+	DuplicateAdopt  = "adopt"  // move the existing session onto the new connection
+)
+
+// WithDuplicatePolicy sets how a connection reusing an active session's UUID
+// is handled: DuplicateReject or DuplicateAdopt. Adopting resumes the
+// existing flow, transcriber and timers on the new connection. Only
+// connections from the session's own host are adopted; the middleware chain
+// has not run for them.
+func WithDuplicatePolicy(policy string) Option {
+	return func(c *Config) { c.DuplicatePolicy = policy }
+}
+
+// register adds session to the active set. If its UUID is already active the
+// existing session is returned instead and session is not registered.
+func (s *Server) register(session *Session) *Session {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if existing, ok := s.sessions[session.id.String()]; ok {
+		return existing
+	}
+	s.sessions[session.id.String()] = session
+	activeSessions.Inc()
+	return nil
+}
+
+func (s *Server) unregister(session *Session) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	if s.sessions[session.id.String()] == session {
+		delete(s.sessions, session.id.String())
+		activeSessions.Dec()
+	}
+}
+
+// handleDuplicate applies the duplicate policy to conn, a new connection for
+// the active session existing. It reports whether existing took ownership of
+// conn.
+func (s *Server) handleDuplicate(existing *Session, conn net.Conn) bool {
+	if s.config.DuplicatePolicy == DuplicateAdopt && sameHost(existing.remoteAddr, conn.RemoteAddr().String()) {
+		if sc, ok := existing.conn.(*sessionConn); ok {
+			old := sc.swap(conn)
+			old.Close()
+			log.Printf("Session %s: Reconnected from %s, resuming flow", existing.id, conn.RemoteAddr())
+			return true
+		}
+	}
+
+	log.Printf("Session %s: Rejecting duplicate connection from %s", existing.id, conn.RemoteAddr())
+	if err := (&Session{id: existing.id, conn: conn}).EndCall(); err != nil {
+		log.Printf("Session %s: %v", existing.id, err)
+	}
+	return false
+}
+
+// sameHost reports whether two peer addresses share a host, ignoring ports
+func sameHost(a, b string) bool {
+	hostA, _, err := net.SplitHostPort(a)
+	if err != nil {
+		hostA = a
+	}
+	hostB, _, err := net.SplitHostPort(b)
+	if err != nil {
+		hostB = b
+	}
+	return hostA == hostB
+}
+
+func validDuplicatePolicy(policy string) error {
+	switch policy {
+	case "", DuplicateReject, DuplicateAdopt:
+		return nil
+	}
+	return fmt.Errorf("unknown duplicate session policy: %s", policy)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+)
+
+// WithEscalationDetection analyzes caller audio for shouting or a rise in
+// loudness and pitch over the start of the call. Each escalation sets the
+// "escalation" session variable to "agitated" or "shouting" and makes a
+// waiting question follow its "escalation" transition, if it has one.
+func WithEscalationDetection(settings audio.EscalationSettings) Option {
+	return func(c *Config) { c.Escalation = &settings }
+}
+
+// Escalations delivers caller escalations to the flow engine
+func (session *Session) Escalations() <-chan string {
+	return session.escalations
+}
+
+// escalate records an escalation and signals the flow
+func (session *Session) escalate(level audio.EscalationLevel) {
+	log.Printf("Session %s: Caller escalation detected: %s", session.id, level)
+	session.SetVar("escalation", string(level))
+	session.transcriber.AddMarker(fmt.Sprintf("[ESCALATION: %s]", level))
+	if engine := session.engine(); engine != nil {
+		if logger := engine.GetSessionLogger(); logger != nil {
+			logger.LogEscalation(session.id.String(), string(level))
+		}
+	}
+	select {
+	case session.escalations <- string(level):
+	default:
+		// An earlier escalation is still pending; the flow acts on one
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeatureFlags turns experimental behaviors (see the flow.Feature* names) on
+// or off. Campaign settings override the defaults; a Redis hash
+// "<prefix>features:<campaign_id>" overrides both at runtime, e.g.
+// HSET features:SALES eager_transitions 1.
+type FeatureFlags struct {
+	Defaults  map[string]bool
+	Campaigns map[string]map[string]bool
+}
+
+// WithFeatureFlags sets the default and per-campaign feature flags
+func WithFeatureFlags(flags FeatureFlags) Option {
+	return func(c *Config) { c.Features = flags }
+}
+
+// resolveFeatures computes the flags for a session once, at call start, so
+// behavior does not change mid-call
+func (s *Server) resolveFeatures(session *Session) map[string]bool {
+	features := make(map[string]bool)
+	for name, on := range s.config.Features.Defaults {
+		features[name] = on
+	}
+
+	campaign, _ := session.GetVar("campaign_id")
+	if campaign == "" {
+		return features
+	}
+	for name, on := range s.config.Features.Campaigns[campaign] {
+		features[name] = on
+	}
+
+	if s.redis != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		key := s.config.RedisPrefix + "features:" + campaign
+		overrides, err := s.redis.HGetAll(ctx, key).Result()
+		if err != nil {
+			log.Printf("Session %s: Failed to read feature overrides %s: %v", session.id, key, err)
+		}
+		for name, v := range overrides {
+			on, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err != nil {
+				log.Printf("Session %s: Ignoring feature override %s=%q in %s", session.id, name, v, key)
+				continue
+			}
+			features[name] = on
+		}
+	}
+	return features
+}
+
+// FeatureEnabled reports whether a feature flag is on for this call
+func (session *Session) FeatureEnabled(name string) bool {
+	return session.features[name]
+}
+
+// enabledFeatures lists the flags that are on, for logging
+func (session *Session) enabledFeatures() []string {
+	var names []string
+	for name, on := range session.features {
+		if on {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// DefaultInstanceCapacity is the capacity advertised when none is configured
+// and no worker pool bounds the server
+const DefaultInstanceCapacity = 100
+
+// registerInterval is how often an instance refreshes its registration; it
+// expires after three missed refreshes
+const registerInterval = 5 * time.Second
+
+// routeTTL is how long a call UUID keeps routing to the same instance
+const routeTTL = time.Hour
+
+// errNoInstance is returned when no registered instance has free capacity
+var errNoInstance = errors.New("no instance with free capacity")
+
+// WithFleet registers this instance in Redis so a fleet of servers can be
+// targeted by the Asterisk dialplan with least-loaded routing (see GET /route
+// on the admin API). advertiseAddr is the host:port Asterisk should connect
+// to; capacity is the number of concurrent calls the instance takes (0 uses
+// the worker pool size, or DefaultInstanceCapacity).
+func WithFleet(advertiseAddr string, capacity int) Option {
+	return func(c *Config) {
+		c.AdvertiseAddr = advertiseAddr
+		c.Capacity = capacity
+	}
+}
+
+// InstanceInfo is the registration of one server in the fleet
+type InstanceInfo struct {
+	Addr     string    `json:"addr"`
+	Capacity int       `json:"capacity"`
+	Active   int       `json:"active"`
+	Updated  time.Time `json:"updated"`
+}
+
+// Load returns the fraction of the instance's capacity in use
+func (i InstanceInfo) Load() float64 {
+	if i.Capacity <= 0 {
+		return 1
+	}
+	return float64(i.Active) / float64(i.Capacity)
+}
+
+// Full reports whether the instance takes no more calls
+func (i InstanceInfo) Full() bool {
+	return i.Active >= i.Capacity
+}
+
+func (s *Server) fleetSetKey() string { return s.config.RedisPrefix + "instances" }
+
+func (s *Server) instanceKey(addr string) string {
+	return s.config.RedisPrefix + "instance:" + addr
+}
+
+func (s *Server) routeKey(uuid string) string {
+	return s.config.RedisPrefix + "route:" + uuid
+}
+
+// instanceInfo returns this instance's current registration
+func (s *Server) instanceInfo() InstanceInfo {
+	capacity := s.config.Capacity
+	if capacity <= 0 {
+		capacity = s.config.Workers
+	}
+	if capacity <= 0 {
+		capacity = DefaultInstanceCapacity
+	}
+	s.sessionsMu.RLock()
+	active := len(s.sessions)
+	s.sessionsMu.RUnlock()
+	return InstanceInfo{Addr: s.config.AdvertiseAddr, Capacity: capacity, Active: active, Updated: time.Now()}
+}
+
+// registerInstance writes this instance's registration with a TTL
+func (s *Server) registerInstance(ctx context.Context) error {
+	data, err := json.Marshal(s.instanceInfo())
+	if err != nil {
+		return err
+	}
+	pipe := s.redis.TxPipeline()
+	pipe.Set(ctx, s.instanceKey(s.config.AdvertiseAddr), data, 3*registerInterval)
+	pipe.SAdd(ctx, s.fleetSetKey(), s.config.AdvertiseAddr)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// runRegistration keeps this instance registered until shutdown, then
+// removes it from the fleet
+func (s *Server) runRegistration() {
+	ticker := time.NewTicker(registerInterval)
+	defer ticker.Stop()
+
+	registered := false
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := s.registerInstance(ctx)
+		cancel()
+		switch {
+		case err != nil && registered:
+			log.Printf("Failed to refresh fleet registration: %v", err)
+		case err != nil:
+			log.Printf("Failed to register in fleet as %s: %v", s.config.AdvertiseAddr, err)
+		case !registered:
+			log.Printf("Registered in fleet as %s", s.config.AdvertiseAddr)
+		}
+		registered = err == nil
+
+		select {
+		case <-s.shutdown:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			s.redis.Del(ctx, s.instanceKey(s.config.AdvertiseAddr))
+			s.redis.SRem(ctx, s.fleetSetKey(), s.config.AdvertiseAddr)
+			cancel()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Instances returns the live instances of the fleet, least loaded first.
+// Instances whose registration expired are dropped from the fleet.
+func (s *Server) Instances(ctx context.Context) ([]InstanceInfo, error) {
+	addrs, err := s.redis.SMembers(ctx, s.fleetSetKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(addrs))
+	for i, addr := range addrs {
+		keys[i] = s.instanceKey(addr)
+	}
+	values, err := s.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instances: %w", err)
+	}
+
+	instances := make([]InstanceInfo, 0, len(addrs))
+	for i, v := range values {
+		data, ok := v.(string)
+		var info InstanceInfo
+		if !ok || json.Unmarshal([]byte(data), &info) != nil {
+			s.redis.SRem(ctx, s.fleetSetKey(), addrs[i])
+			continue
+		}
+		instances = append(instances, info)
+	}
+	sortByLoad(instances)
+	return instances, nil
+}
+
+// sortByLoad orders instances least loaded first, breaking ties by free
+// capacity and then address so routing is deterministic
+func sortByLoad(instances []InstanceInfo) {
+	sort.Slice(instances, func(i, j int) bool {
+		a, b := instances[i], instances[j]
+		if a.Load() != b.Load() {
+			return a.Load() < b.Load()
+		}
+		if free := a.Capacity - a.Active; free != b.Capacity-b.Active {
+			return free > b.Capacity-b.Active
+		}
+		return a.Addr < b.Addr
+	})
+}
+
+// Route picks the instance a new call should connect to: the least loaded
+// one with free capacity. With a call UUID, a call routed before (e.g. an
+// Asterisk retry) goes back to the same instance while it is alive.
+func (s *Server) Route(ctx context.Context, uuid string) (InstanceInfo, error) {
+	instances, err := s.Instances(ctx)
+	if err != nil {
+		return InstanceInfo{}, err
+	}
+
+	if uuid != "" {
+		if addr, err := s.redis.Get(ctx, s.routeKey(uuid)).Result(); err == nil {
+			for _, info := range instances {
+				if info.Addr == addr {
+					return info, nil
+				}
+			}
+		} else if err != redis.Nil {
+			return InstanceInfo{}, fmt.Errorf("failed to look up route: %w", err)
+		}
+	}
+
+	for _, info := range instances {
+		if info.Full() {
+			continue
+		}
+		if uuid != "" {
+			s.redis.Set(ctx, s.routeKey(uuid), info.Addr, routeTTL)
+		}
+		return info, nil
+	}
+	return InstanceInfo{}, errNoInstance
+}
+
+// handleFleet registers the fleet lookup endpoints on the admin API:
+//
+//	GET /instances        live instances, least loaded first
+//	GET /route[?uuid=..]  host:port of the instance a new call should use,
+//	                      as plain text for the dialplan's CURL(); empty
+//	                      with status 503 when no instance is available
+func (s *Server) handleFleet(mux *http.ServeMux) {
+	mux.HandleFunc("GET /instances", func(w http.ResponseWriter, r *http.Request) {
+		instances, err := s.Instances(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		if instances == nil {
+			instances = []InstanceInfo{}
+		}
+		writeJSON(w, http.StatusOK, instances)
+	})
+	mux.HandleFunc("GET /route", func(w http.ResponseWriter, r *http.Request) {
+		info, err := s.Route(r.Context(), r.URL.Query().Get("uuid"))
+		if err != nil {
+			// Empty body: the dialplan's CURL() returns the body even on errors
+			log.Printf("Fleet route lookup failed: %v", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprint(w, info.Addr)
+	})
+}
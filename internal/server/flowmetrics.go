@@ -0,0 +1,27 @@
+package server
+
+import (
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var dispositions = metrics.NewCounterVec("audiosocket_dispositions_total", "Final call statuses", "flow", "version", "campaign", "status")
+
+// metricLabels returns the flow, deployed version and campaign the
+// session's metrics are tagged with
+func (session *Session) metricLabels() flow.MetricLabels {
+	campaign, _ := session.GetVar("campaign_id")
+	return flow.MetricLabels{Flow: session.flowVersion.Name, Version: session.flowVersion.Label(), Campaign: campaign}
+}
+
+// countDisposition counts a call's final status. A transferred call counts
+// as the transfer status it was handed off with rather than the status
+// posted when it hangs up.
+func (s *Server) countDisposition(session *Session, status string) {
+	if session.flowEngine != nil && session.flowEngine.WasTransferred() && s.config.Vicidial.TransferStatus != "" {
+		status = s.config.Vicidial.TransferStatus
+	}
+	session.status.Store(&status)
+	l := session.metricLabels()
+	dispositions.With(l.Flow, l.Version, l.Campaign, status).Inc()
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+// staleSessions counts sessions terminated because Asterisk stopped sending
+var staleSessions = metrics.NewCounter("audiosocket_stale_sessions_total", "Sessions terminated after no inbound frames within the staleness threshold")
+
+// WithHeartbeat terminates sessions that receive no inbound frame for
+// threshold, which happens when the Asterisk side dies without closing the
+// TCP connection. Such calls are dispositioned with status (DC if empty).
+func WithHeartbeat(threshold time.Duration, status string) Option {
+	return func(c *Config) {
+		c.StaleTimeout = threshold
+		c.StaleStatus = status
+	}
+}
+
+// touch records that an inbound frame arrived
+func (session *Session) touch() {
+	session.lastFrame.Store(time.Now().UnixNano())
+}
+
+// sinceLastFrame returns how long ago the last inbound frame arrived
+func (session *Session) sinceLastFrame() time.Duration {
+	last := session.lastFrame.Load()
+	if last == 0 {
+		return time.Since(session.startTime)
+	}
+	return time.Since(time.Unix(0, last))
+}
+
+// watchHeartbeat closes the connection of a stale session so the read loop
+// ends. done is closed when the session ends.
+func (s *Server) watchHeartbeat(session *Session, done <-chan struct{}) {
+	ticker := time.NewTicker(s.config.StaleTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			idle := session.sinceLastFrame()
+			if idle < s.config.StaleTimeout {
+				continue
+			}
+
+			log.Printf("Session %s: No inbound frames for %v, connection presumed dead", session.id, idle.Round(time.Millisecond))
+			staleSessions.Inc()
+
+			status := s.config.StaleStatus
+			if status == "" {
+				status = DefaultDeadAirStatus
+			}
+			s.dispose(session, status)
+			session.conn.Close()
+			return
+		}
+	}
+}
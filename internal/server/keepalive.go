@@ -0,0 +1,48 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var keepAliveFrames = metrics.NewCounter("audiosocket_keepalive_frames_total", "Silence frames sent to keep AudioSocket streams open while no prompt plays")
+
+// keepAliveFrame is 20ms of 8kHz SLIN silence
+var keepAliveFrame = audiosocket.SlinMessage(make([]byte, audiosocket.DefaultSlinChunkSize))
+
+// WithKeepAlive sends Asterisk a 20ms frame of silence whenever no audio was
+// sent for interval, e.g. while the flow listens to a long answer, so the
+// AudioSocket application and NAT or firewall middleboxes don't time out a
+// stream that only carries the caller's audio.
+func WithKeepAlive(interval time.Duration) Option {
+	return func(c *Config) {
+		c.KeepAliveInterval = interval
+	}
+}
+
+// keepAlive sends a silence frame on conn each time it has been idle for
+// the keep-alive interval, until done is closed
+func (s *Server) keepAlive(session *Session, conn *sessionConn, done <-chan struct{}) {
+	interval := s.config.KeepAliveInterval
+	ticker := time.NewTicker(interval / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if conn.sinceAudio() < interval {
+				continue
+			}
+			if _, err := conn.send(keepAliveFrame, outFiller); err != nil {
+				log.Printf("Session %s: Keep-alive stopped: %v", session.id, err)
+				return
+			}
+			keepAliveFrames.Inc()
+		}
+	}
+}
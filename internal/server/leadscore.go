@@ -0,0 +1,38 @@
+package server
+
+import (
+	"log"
+)
+
+// writeLeadScore writes the score of the caller's answers back to the lead,
+// when the flow the call started in has lead_score settings
+func (s *Server) writeLeadScore(session *Session) {
+	engine := session.engine()
+	if engine == nil {
+		return
+	}
+	score, ok := engine.LeadScore()
+	if !ok {
+		return
+	}
+	leadID, _ := session.GetVar("lead_id")
+	if leadID == "" {
+		log.Printf("Session %s: No lead_id; lead score %d not written", session.id, score)
+		return
+	}
+	logger := engine.GetSessionLogger()
+	if logger != nil {
+		logger.LogLeadScore(session.id.String(), score, engine.LeadScoreAnswers())
+	}
+	if s.config.Vicidial.ServerURL == "" {
+		return
+	}
+	settings := engine.LeadScoring()
+	apiClient := s.newVicidialClient()
+	if logger != nil {
+		apiClient.SetLogger(logger)
+	}
+	if err := apiClient.UpdateLeadScore(session.id.String(), leadID, settings.Field, score, settings.Rank); err != nil {
+		log.Printf("Session %s: update_lead score %d failed: %v", session.id, score, err)
+	}
+}
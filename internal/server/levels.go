@@ -0,0 +1,24 @@
+package server
+
+import (
+	"log"
+)
+
+// logLevels reports session levels and warns about likely gain or one-way
+// audio problems
+func (session *Session) logLevels() {
+	in := session.inLevel.Levels()
+	out := session.outLevel.Levels()
+	log.Printf("Session %s: Audio levels in RMS %.1f/peak %.1f dBFS, out RMS %.1f/peak %.1f dBFS",
+		session.id, in.RMSDB, in.PeakDB, out.RMSDB, out.PeakDB)
+
+	if in.Clipping() {
+		log.Printf("Session %s: Warning: inbound audio clipping (%d of %d samples)", session.id, in.ClippedSamples, in.Samples)
+	}
+	if in.Samples > 0 && in.NearSilent() {
+		log.Printf("Session %s: Warning: inbound audio near silent, possible one-way audio", session.id)
+	}
+	if out.Clipping() {
+		log.Printf("Session %s: Warning: outbound audio clipping (%d of %d samples)", session.id, out.ClippedSamples, out.Samples)
+	}
+}
@@ -0,0 +1,498 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/dsp"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+	"github.com/gorilla/websocket"
+)
+
+var liveListeners = metrics.NewGauge("audiosocket_live_listeners", "Supervisors listening to calls live")
+
+const (
+	// listenRate is the sample rate of live audio, whatever the call's rate
+	listenRate = 8000
+
+	// maxBotBacklog bounds the bot audio waiting to be mixed with the caller's
+	// frames, one second at listenRate
+	maxBotBacklog = listenRate * 2
+
+	// listenBuffer is how many frames a slow listener may fall behind before
+	// frames are dropped for it
+	listenBuffer = 50
+
+	// ListenTokenTTL is how long a token from POST /sessions/{id}/listen/token
+	// can be used to start listening
+	ListenTokenTTL = 5 * time.Minute
+
+	// opusFrameBytes is the 20ms of audio in each Opus packet of a live stream
+	opusFrameBytes = listenRate / 50 * 2
+)
+
+// WithLiveListen lets supervisors listen to calls live through the admin API
+// (see handleListen). Off by default: callers' audio leaves the server only
+// when this is enabled.
+func WithLiveListen(enabled bool) Option {
+	return func(c *Config) { c.LiveListen = enabled }
+}
+
+// liveMonitor mixes the caller's audio with the bot's for the supervisors
+// listening to a session. The caller's frames set the pace; bot audio written
+// in between is mixed into the next caller frames. Nothing is buffered while
+// nobody listens.
+type liveMonitor struct {
+	listeners atomic.Int32
+	mu        sync.Mutex
+	subs      map[chan []byte]string // listener of each stream
+	bot       []byte                 // bot audio not yet mixed
+	closed    bool
+}
+
+func newLiveMonitor() *liveMonitor {
+	return &liveMonitor{subs: make(map[chan []byte]string)}
+}
+
+// subscribe returns a channel of mixed 8kHz frames for listener, closed when
+// the session ends, and the function that stops listening. That function
+// reports false if the session ended first.
+func (m *liveMonitor) subscribe(listener string) (<-chan []byte, func() bool) {
+	ch := make(chan []byte, listenBuffer)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		close(ch)
+		return ch, func() bool { return false }
+	}
+	m.subs[ch] = listener
+	m.listeners.Add(1)
+	return ch, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.subs[ch]; !ok {
+			return false
+		}
+		delete(m.subs, ch)
+		m.listeners.Add(-1)
+		close(ch)
+		return true
+	}
+}
+
+// addBot queues 8kHz audio sent to the caller for mixing
+func (m *liveMonitor) addBot(pcm []byte) {
+	if m == nil || m.listeners.Load() == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bot = append(m.bot, pcm...)
+	if over := len(m.bot) - maxBotBacklog; over > 0 {
+		m.bot = m.bot[over&^1:]
+	}
+}
+
+// addCaller mixes a caller frame at rate with the queued bot audio and sends
+// it to every listener. A listener that has fallen behind misses the frame.
+func (m *liveMonitor) addCaller(pcm []byte, rate int) {
+	if m == nil || m.listeners.Load() == 0 {
+		return
+	}
+	if rate != listenRate {
+		pcm = dsp.ResampleBytes(pcm, rate, listenRate)
+	}
+	frame := make([]byte, len(pcm)&^1)
+	copy(frame, pcm)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := min(len(frame), len(m.bot)&^1)
+	for i := 0; i < n; i += 2 {
+		mixed := int32(int16(binary.LittleEndian.Uint16(frame[i:]))) + int32(int16(binary.LittleEndian.Uint16(m.bot[i:])))
+		binary.LittleEndian.PutUint16(frame[i:], uint16(int16(max(-32768, min(32767, mixed)))))
+	}
+	m.bot = m.bot[n:]
+	for ch := range m.subs {
+		select {
+		case ch <- frame:
+		default:
+		}
+	}
+}
+
+// close ends every listener's stream and returns who was listening
+func (m *liveMonitor) close() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	var listeners []string
+	for ch, listener := range m.subs {
+		delete(m.subs, ch)
+		m.listeners.Add(-1)
+		close(ch)
+		listeners = append(listeners, listener)
+	}
+	m.bot = nil
+	return listeners
+}
+
+// listenToken lets its holder listen to one session until it expires
+type listenToken struct {
+	sessionID string
+	issuedTo  string // identity that requested the token
+	expires   time.Time
+}
+
+// listenTokens are the unexpired tokens issued by the admin API
+type listenTokens struct {
+	mu     sync.Mutex
+	tokens map[string]listenToken
+}
+
+// issue returns a new token for sessionID
+func (t *listenTokens) issue(sessionID, issuedTo string) (string, time.Time) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	expires := time.Now().Add(ListenTokenTTL)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tokens == nil {
+		t.tokens = make(map[string]listenToken)
+	}
+	for k, tok := range t.tokens {
+		if time.Now().After(tok.expires) {
+			delete(t.tokens, k)
+		}
+	}
+	t.tokens[token] = listenToken{sessionID: sessionID, issuedTo: issuedTo, expires: expires}
+	return token, expires
+}
+
+// check returns who token was issued to if it is valid for sessionID
+func (t *listenTokens) check(token, sessionID string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tok, ok := t.tokens[token]
+	if !ok || tok.sessionID != sessionID || time.Now().After(tok.expires) {
+		return "", false
+	}
+	return tok.issuedTo, true
+}
+
+// tokenListen reports whether r asks to listen with a token, which the
+// listen handler checks instead of the admin credentials
+func (s *Server) tokenListen(r *http.Request) bool {
+	return s.config.LiveListen && r.Method == http.MethodGet && r.URL.Query().Get("token") != "" &&
+		strings.HasPrefix(r.URL.Path, "/sessions/") && strings.HasSuffix(r.URL.Path, "/listen")
+}
+
+var listenUpgrader = websocket.Upgrader{
+	// Listening is authorized by credentials or a session token, not by origin
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// handleListen registers the live listen endpoints when live listening is
+// enabled:
+//
+//	POST /sessions/{id}/listen/token  a token to listen to the session, for players that cannot send credentials
+//	GET  /sessions/{id}/listen        the call's mixed audio, caller and bot
+//
+// Listening needs the control role, or ?token= for that session. The audio
+// is 8kHz mono in the ?format= asked for:
+//
+//	opus  Ogg Opus, the default when the server is built with -tags opus
+//	pcm   16-bit PCM, the default otherwise
+//
+// Over HTTP, Ogg Opus and PCM as a WAV stream are both playable by a
+// browser's audio element. Over a WebSocket the stream comes as binary
+// messages, Ogg pages or PCM frames, announced by a text message such as
+// {"format":"s16le","sample_rate":8000} or {"format":"ogg_opus",...}. Each
+// listener is recorded in the session log and the audit log.
+func (s *Server) handleListen(mux *http.ServeMux) {
+	if !s.config.LiveListen {
+		return
+	}
+	mux.HandleFunc("POST /sessions/{id}/listen/token", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		s.sessionsMu.RLock()
+		_, ok := s.sessions[id]
+		s.sessionsMu.RUnlock()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": errSessionNotFound.Error()})
+			return
+		}
+		token, expires := s.listenTokens.issue(id, auditActor(r))
+		s.audit(r, "listen_token", id, map[string]string{"expires": expires.UTC().Format(time.RFC3339)}, nil)
+		writeJSON(w, http.StatusOK, map[string]string{
+			"token":      token,
+			"expires_at": expires.UTC().Format(time.RFC3339),
+			"url":        "/sessions/" + id + "/listen?token=" + token,
+		})
+	})
+	mux.HandleFunc("GET /sessions/{id}/listen", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		listener, ok := s.listenAccess(r, id)
+		if !ok {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "control role or listen token required"})
+			return
+		}
+		format, err := listenFormat(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		s.sessionsMu.RLock()
+		session, ok := s.sessions[id]
+		s.sessionsMu.RUnlock()
+		if !ok || session.monitor == nil {
+			s.audit(r, "listen", id, nil, errSessionNotFound)
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": errSessionNotFound.Error()})
+			return
+		}
+		s.audit(r, "listen", id, map[string]string{"listener": listener}, nil)
+		if websocket.IsWebSocketUpgrade(r) {
+			s.listenWebSocket(w, r, session, listener, format)
+		} else {
+			s.listenHTTP(w, r, session, listener, format)
+		}
+	})
+}
+
+// listenFormat returns the stream format r asks for, "opus" or "pcm"
+func listenFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "":
+		if audio.OpusAvailable {
+			return "opus", nil
+		}
+		return "pcm", nil
+	case "pcm":
+		return format, nil
+	case "opus":
+		if !audio.OpusAvailable {
+			return "", fmt.Errorf("opus streams need a server built with -tags opus; use format=pcm")
+		}
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown format %q: use opus or pcm", format)
+	}
+}
+
+// oggStream encodes a listener's frames as Ogg Opus, 20ms packets at a time
+type oggStream struct {
+	enc     *audio.OpusEncoder
+	ogg     *audio.OggOpusWriter
+	pending []byte // audio short of a packet
+}
+
+// newOggStream writes the stream headers to w
+func newOggStream(w io.Writer) (*oggStream, error) {
+	enc, err := audio.NewOpusEncoder(listenRate)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, 4)
+	rand.Read(b)
+	ogg, err := audio.NewOggOpusWriter(w, listenRate, enc.PreSkip(), binary.LittleEndian.Uint32(b))
+	if err != nil {
+		enc.Close()
+		return nil, err
+	}
+	return &oggStream{enc: enc, ogg: ogg}, nil
+}
+
+// write encodes the whole packets of frame and keeps the rest for the next
+func (o *oggStream) write(frame []byte) error {
+	o.pending = append(o.pending, frame...)
+	sent := 0
+	for ; len(o.pending)-sent >= opusFrameBytes; sent += opusFrameBytes {
+		packet, err := o.enc.Encode(o.pending[sent : sent+opusFrameBytes])
+		if err != nil {
+			return err
+		}
+		if err := o.ogg.WritePacket(packet, opusFrameBytes/2); err != nil {
+			return err
+		}
+	}
+	o.pending = append(o.pending[:0], o.pending[sent:]...)
+	return nil
+}
+
+func (o *oggStream) close() { o.enc.Close() }
+
+// listenAccess returns who is listening if r may listen to session id
+func (s *Server) listenAccess(r *http.Request, id string) (string, bool) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		issuedTo, ok := s.listenTokens.check(token, id)
+		return issuedTo, ok
+	}
+	if !s.adminAuthEnabled() {
+		return auditActor(r), true
+	}
+	caller, ok := r.Context().Value(adminIdentityKey{}).(adminIdentity)
+	if !ok || caller.role != RoleControl {
+		return "", false
+	}
+	return caller.name, true
+}
+
+// listen subscribes listener to session's audio, logging the start and end
+func (session *Session) listen(listener string) (<-chan []byte, func()) {
+	frames, stop := session.monitor.subscribe(listener)
+	liveListeners.Inc()
+	session.logMonitor(listener, "start")
+	return frames, func() {
+		liveListeners.Dec()
+		if stop() {
+			session.logMonitor(listener, "stop")
+		}
+	}
+}
+
+// endListening ends the streams of the session's listeners as the call ends,
+// logging them while the session log is still open
+func (session *Session) endListening() {
+	for _, listener := range session.monitor.close() {
+		session.logMonitor(listener, "stop")
+	}
+}
+
+func (session *Session) logMonitor(listener, action string) {
+	if engine := session.engine(); engine != nil {
+		if logger := engine.GetSessionLogger(); logger != nil {
+			logger.LogMonitor(session.id.String(), listener, action)
+		}
+	}
+}
+
+// listenHTTP streams session's audio as Ogg Opus or a WAV of unknown
+// length until the call or the request ends
+func (s *Server) listenHTTP(w http.ResponseWriter, r *http.Request, session *Session, listener, format string) {
+	frames, stop := session.listen(listener)
+	defer stop()
+
+	flusher, _ := w.(http.Flusher)
+	write := func(b []byte) bool {
+		if _, err := w.Write(b); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if format == "opus" {
+		w.Header().Set("Content-Type", "audio/ogg; codecs=opus")
+	} else {
+		w.Header().Set("Content-Type", "audio/wav")
+	}
+	w.WriteHeader(http.StatusOK)
+
+	send := write
+	if format == "opus" {
+		ogg, err := newOggStream(writerFunc(func(b []byte) (int, error) {
+			if !write(b) {
+				return 0, io.ErrClosedPipe
+			}
+			return len(b), nil
+		}))
+		if err != nil {
+			return
+		}
+		defer ogg.close()
+		send = func(frame []byte) bool { return ogg.write(frame) == nil }
+	} else {
+		header := audio.EncodeWAV(nil)
+		binary.LittleEndian.PutUint32(header[4:], 0xFFFFFFFF)
+		binary.LittleEndian.PutUint32(header[40:], 0xFFFFFFFF-36)
+		if !write(header) {
+			return
+		}
+	}
+
+	for {
+		select {
+		case frame, ok := <-frames:
+			if !ok || !send(frame) {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// listenWebSocket sends session's audio as binary messages, Ogg pages or PCM
+// frames, until the call ends or the listener disconnects
+func (s *Server) listenWebSocket(w http.ResponseWriter, r *http.Request, session *Session, listener, format string) {
+	ws, err := listenUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+	frames, stop := session.listen(listener)
+	defer stop()
+
+	// Notice the listener leaving; nothing it sends is used
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(frame []byte) error { return ws.WriteMessage(websocket.BinaryMessage, frame) }
+	announce := "s16le"
+	if format == "opus" {
+		announce = "ogg_opus"
+	}
+	if err := ws.WriteJSON(map[string]interface{}{"format": announce, "sample_rate": listenRate}); err != nil {
+		return
+	}
+	if format == "opus" {
+		// Each Ogg page goes out as a message of its own
+		ogg, err := newOggStream(writerFunc(func(b []byte) (int, error) {
+			return len(b), ws.WriteMessage(websocket.BinaryMessage, b)
+		}))
+		if err != nil {
+			return
+		}
+		defer ogg.close()
+		send = ogg.write
+	}
+	for {
+		select {
+		case frame, ok := <-frames:
+			if !ok {
+				ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "call ended"))
+				return
+			}
+			if err := send(frame); err != nil {
+				return
+			}
+		case <-gone:
+			return
+		}
+	}
+}
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) { return f(b) }
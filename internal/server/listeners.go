@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// WithListeners runs n accept loops on the listen address, each with its own
+// socket bound with SO_REUSEPORT so the kernel spreads new connections
+// across them. This lets the accept path use many cores under very high call
+// setup rates. Each listener gets an even share of the worker pool. Only
+// supported on Linux and the BSDs; n <= 1 keeps a single listener.
+func WithListeners(n int) Option {
+	return func(c *Config) { c.Listeners = n }
+}
+
+// listen opens the configured number of listeners on addr
+func (s *Server) listen(addr string) ([]net.Listener, error) {
+	n := s.config.Listeners
+	if n <= 1 {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	}
+
+	if !reusePortSupported {
+		return nil, fmt.Errorf("%d listeners need SO_REUSEPORT, which is not supported on this platform", n)
+	}
+	lc := net.ListenConfig{Control: reusePort}
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		l, err := lc.Listen(context.Background(), "tcp", addr)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, l)
+		// Bind the others to the same port even if addr asked for any port
+		addr = l.Addr().String()
+	}
+	return listeners, nil
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+// DefaultMachineStatus is the disposition for calls answered by hold music
+// or an IVR, the same as an answering machine
+const DefaultMachineStatus = "A"
+
+var machineAnswers = metrics.NewCounterVec("audiosocket_machine_answers_total", "Calls ended because hold music or IVR tones answered", "kind")
+
+// WithMachineDetection judges the first seconds of caller audio
+// (audio.DefaultMachineSeconds if 0) and hangs up calls answered by hold
+// music or IVR tones, dispositioning them with status (DefaultMachineStatus
+// if empty) instead of running the flow against a machine. The verdict is
+// stored in the "machine" session variable.
+func WithMachineDetection(seconds float64, status string) Option {
+	return func(c *Config) {
+		c.MachineDetection = true
+		c.MachineSeconds = seconds
+		c.MachineStatus = status
+	}
+}
+
+// recordMachine stores what answered the call and ends calls a machine
+// answered
+func (session *Session) recordMachine(kind audio.Machine) {
+	session.SetVar("machine", string(kind))
+	if engine := session.engine(); engine != nil {
+		if logger := engine.GetSessionLogger(); logger != nil {
+			logger.LogMachine(session.id.String(), string(kind))
+		}
+	}
+	if kind == audio.MachineHuman {
+		return
+	}
+	machineAnswers.With(string(kind)).Inc()
+	session.transcriber.AddMarker(fmt.Sprintf("[MACHINE: %s]", kind))
+	go session.server.hangUpMachine(session, kind)
+}
+
+// hangUpMachine dispositions and ends a call answered by a machine
+func (s *Server) hangUpMachine(session *Session, kind audio.Machine) {
+	status := s.config.MachineStatus
+	if status == "" {
+		status = DefaultMachineStatus
+	}
+	log.Printf("Session %s: Call answered by %s, not a person; hanging up with %s", session.id, kind, status)
+
+	s.dispose(session, status)
+	if err := session.EndCall(); err != nil {
+		log.Printf("Session %s: %v", session.id, err)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// SessionHandler handles an accepted AudioSocket session. Returning an error
+// rejects the call: the server logs the error and sends a hangup.
+type SessionHandler func(session *Session) error
+
+// Middleware wraps a SessionHandler to add cross-cutting behavior such as
+// allow-lists, metrics, rate limiting or audit logging. A middleware that does
+// not call next prevents the flow engine from ever seeing the session.
+type Middleware func(next SessionHandler) SessionHandler
+
+// WithMiddleware appends session middleware; the first one added runs outermost
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Config) { c.Middleware = append(c.Middleware, mw...) }
+}
+
+// AllowList rejects sessions whose remote address is not inside one of the
+// given CIDR ranges (plain IPs are treated as single-host ranges)
+func AllowList(cidrs ...string) (Middleware, error) {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allow-list entry %w", err)
+	}
+
+	return func(next SessionHandler) SessionHandler {
+		return func(session *Session) error {
+			if containsAddr(nets, session.RemoteAddr()) {
+				return next(session)
+			}
+			return fmt.Errorf("remote address %s not in allow-list", session.RemoteAddr())
+		}
+	}, nil
+}
+
+// parseCIDRs parses CIDR ranges, treating plain IPs as single-host ranges
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		if ip := net.ParseIP(cidr); ip != nil {
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", cidr, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// containsAddr reports whether addr, a host:port or bare host, is inside
+// one of nets
+func containsAddr(nets []*net.IPNet, addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	ip := net.ParseIP(host)
+	for _, n := range nets {
+		if ip != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxConcurrentSessions rejects new sessions while limit sessions are active
+func MaxConcurrentSessions(limit int) Middleware {
+	var active int64
+	return func(next SessionHandler) SessionHandler {
+		return func(session *Session) error {
+			if atomic.AddInt64(&active, 1) > int64(limit) {
+				atomic.AddInt64(&active, -1)
+				return fmt.Errorf("concurrent session limit %d reached", limit)
+			}
+			defer atomic.AddInt64(&active, -1)
+			return next(session)
+		}
+	}
+}
+
+// LogSessions logs the start, end and duration of every session
+func LogSessions() Middleware {
+	return func(next SessionHandler) SessionHandler {
+		return func(session *Session) error {
+			start := time.Now()
+			log.Printf("Session %s: accepted from %s", session.GetID(), session.RemoteAddr())
+			err := next(session)
+			log.Printf("Session %s: handler finished after %v (err=%v)", session.GetID(), time.Since(start), err)
+			return err
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/notify"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+)
+
+// Option configures a Server
+type Option func(*Config)
+
+// TranscriberFactory creates a transcriber for a new session
+type TranscriberFactory func(sessionID string) (transcriber.Transcriber, error)
+
+// defaultConfig returns settings suitable for a local development setup
+func defaultConfig() Config {
+	return Config{
+		Host:           "0.0.0.0",
+		Port:           9019,
+		Provider:       "vosk",
+		VoskServerURL:  "ws://localhost:2700",
+		SampleRate:     8000,
+		AudioDir:       "./audios",
+		FlowPath:       "./config/flow.json",
+		InterruptsPath: "./config/interrupts.yaml",
+		RedisAddr:      "localhost:6379",
+		ReadTimeout:    DefaultReadTimeout,
+		ReadRetries:    DefaultReadRetries,
+	}
+}
+
+// WithListenAddr sets the AudioSocket listen address
+func WithListenAddr(host string, port int) Option {
+	return func(c *Config) {
+		c.Host = host
+		c.Port = port
+	}
+}
+
+// WithVosk transcribes with a Vosk WebSocket server
+func WithVosk(serverURL string, sampleRate int) Option {
+	return func(c *Config) {
+		c.Provider = "vosk"
+		c.VoskServerURL = serverURL
+		c.SampleRate = sampleRate
+	}
+}
+
+// WithVoskPool transcribes with several Vosk servers, sending each new session
+// to the healthy server with the fewest active streams
+func WithVoskPool(serverURLs []string, sampleRate int) Option {
+	return func(c *Config) {
+		c.Provider = "vosk"
+		c.VoskServerURLs = serverURLs
+		if len(serverURLs) > 0 {
+			c.VoskServerURL = serverURLs[0]
+		}
+		c.SampleRate = sampleRate
+	}
+}
+
+// WithVoskLocal transcribes in-process with libvosk. The binary must be built
+// with CGO_ENABLED=1 -tags vosk, otherwise New fails.
+func WithVoskLocal(modelPath string, sampleRate int) Option {
+	return func(c *Config) {
+		c.Provider = "vosk_local"
+		c.VoskModelPath = modelPath
+		c.SampleRate = sampleRate
+	}
+}
+
+// WithVoskSampleRate sets the rate the Vosk model was trained at when it
+// differs from the AudioSocket session rate, e.g. an 8kHz model behind 16kHz
+// wideband sessions. Session audio is resampled automatically.
+func WithVoskSampleRate(rate int) Option {
+	return func(c *Config) { c.VoskSampleRate = rate }
+}
+
+// WithSampleRate sets the AudioSocket session audio rate, for providers
+// added with transcriber.Register; the other provider options set it
+// themselves
+func WithSampleRate(rate int) Option {
+	return func(c *Config) { c.SampleRate = rate }
+}
+
+// WithAssemblyAI transcribes with AssemblyAI streaming
+func WithAssemblyAI(apiKey string, sampleRate int) Option {
+	return func(c *Config) {
+		c.Provider = "assemblyai"
+		c.AssemblyAPIKey = apiKey
+		c.SampleRate = sampleRate
+	}
+}
+
+// WithTranscriber uses a custom transcriber for every session. name is used
+// in logs and saved file names.
+func WithTranscriber(name string, sampleRate int, factory TranscriberFactory) Option {
+	return func(c *Config) {
+		c.Provider = name
+		c.SampleRate = sampleRate
+		c.TranscriberFactory = factory
+	}
+}
+
+// WithAudioDir sets the directory prompts are loaded from; empty disables
+// audio playback and the flow engine
+func WithAudioDir(dir string) Option {
+	return func(c *Config) { c.AudioDir = dir }
+}
+
+// WithFlow sets the flow definition and interrupt pattern files
+func WithFlow(flowPath, interruptsPath string) Option {
+	return func(c *Config) {
+		c.FlowPath = flowPath
+		c.InterruptsPath = interruptsPath
+	}
+}
+
+// WithOutput enables saving transcripts, raw audio and session logs to dir
+func WithOutput(dir string, transcripts, audio, sessionLogs bool) Option {
+	return func(c *Config) {
+		c.OutputDir = dir
+		c.SaveTranscripts = transcripts
+		c.SaveAudio = audio
+		c.SaveSessionLogs = sessionLogs
+	}
+}
+
+// WithSubtitles additionally exports saved transcripts as subtitles with
+// utterance timing; formats are "srt" and/or "vtt". Requires WithOutput with
+// transcripts enabled.
+func WithSubtitles(formats ...string) Option {
+	return func(c *Config) { c.SubtitleFormats = append(c.SubtitleFormats, formats...) }
+}
+
+// WithTranscriptNormalizer rewrites saved transcripts and subtitles with n
+// for reading, e.g. transcriber.TextNormalizer restores the casing and
+// punctuation Vosk leaves out and writes spoken numbers as digits
+func WithTranscriptNormalizer(n transcriber.Normalizer) Option {
+	return func(c *Config) { c.TranscriptNormalizer = n }
+}
+
+// WithVicidial configures the Vicidial API used for dispositions and transfers
+func WithVicidial(vc VicidialConfig) Option {
+	return func(c *Config) { c.Vicidial = vc }
+}
+
+// WithRedis sets the Redis instance holding per-call variables
+func WithRedis(addr string, db int, prefix string) Option {
+	return func(c *Config) {
+		if addr != "" {
+			c.RedisAddr = addr
+		}
+		c.RedisDB = db
+		c.RedisPrefix = prefix
+	}
+}
+
+// WithHooks attaches plugin hooks to every session's flow engine
+func WithHooks(hooks ...flow.Hooks) Option {
+	return func(c *Config) { c.Hooks = append(c.Hooks, hooks...) }
+}
+
+// WithCalendar books callbacks confirmed in schedule_callback nodes in cal
+// as well as in Vicidial; the event ID goes to the session log and the
+// Vicidial callback's comments (callback_comments). flow.NewICalendar books
+// into a CalDAV calendar or an iCalendar webhook.
+func WithCalendar(cal flow.Calendar) Option {
+	return func(c *Config) { c.Calendar = cal }
+}
+
+// WithNotifier sends the flow's notify actions on channel (notify.SMS or
+// notify.Email) through sender, e.g. notify.NewTwilio or notify.NewSendGrid
+func WithNotifier(channel string, sender notify.Sender) Option {
+	return func(c *Config) {
+		if c.Notifiers == nil {
+			c.Notifiers = make(map[string]notify.Sender)
+		}
+		c.Notifiers[channel] = sender
+	}
+}
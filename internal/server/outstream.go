@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var outLateFrames = metrics.NewCounter("audiosocket_out_late_frames_total", "Outbound frame ticks that fired more than a frame late, e.g. after a scheduler stall")
+
+// frameInterval is the length of one 320-byte SLIN frame at 8kHz
+const frameInterval = 20 * time.Millisecond
+
+// errStreamClosed is returned by writes to a closed OutStream
+var errStreamClosed = errors.New("outbound audio stream closed")
+
+// Priorities of outbound audio
+const (
+	outPrompt = iota // prompts and other bot speech
+	outFiller        // comfort noise and keep-alives, sent only when no prompt frame is waiting
+)
+
+// outFrame is a message waiting to be written and where to report the result
+type outFrame struct {
+	msg  []byte
+	sent chan error
+}
+
+// OutStream owns the write path of a session's connection. Every message the
+// bot sends goes through its single writer goroutine, so only one source ever
+// writes to the connection. Audio frames are sent one per tick of a 20ms clock
+// kept against the monotonic clock, so however bursty their sources are, or
+// however late one tick fires, Asterisk receives evenly paced audio without
+// drift. Each tick sends a waiting prompt frame first, then a filler frame
+// and, when neither is waiting, the idle frame, e.g. of the ambient bed.
+// Writers block until their frame is sent, which paces them too. Control
+// messages such as a hangup are sent at once, between ticks.
+type OutStream struct {
+	deliver func(msg []byte) error // writes a message to the connection
+	idle    func() error           // sends a frame of its own when nothing is waiting
+
+	audio   [2]chan outFrame // by priority
+	control chan outFrame
+	done    chan struct{}
+	once    sync.Once
+}
+
+// newOutStream starts a stream writing through deliver
+func newOutStream(deliver func(msg []byte) error, idle func() error) *OutStream {
+	o := &OutStream{
+		deliver: deliver,
+		idle:    idle,
+		audio:   [2]chan outFrame{make(chan outFrame), make(chan outFrame)},
+		control: make(chan outFrame),
+		done:    make(chan struct{}),
+	}
+	go o.run()
+	return o
+}
+
+// send writes msg on the next tick at priority, or at once for a control
+// message, and returns once it was written
+func (o *OutStream) send(msg []byte, priority int, control bool) error {
+	queue := o.audio[priority]
+	if control {
+		queue = o.control
+	}
+	f := outFrame{msg: msg, sent: make(chan error, 1)}
+	select {
+	case queue <- f:
+	case <-o.done:
+		return errStreamClosed
+	}
+	return <-f.sent
+}
+
+// close stops the stream; writes after it fail with errStreamClosed
+func (o *OutStream) close() {
+	o.once.Do(func() { close(o.done) })
+}
+
+func (o *OutStream) run() {
+	next := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-o.done:
+			return
+		case f := <-o.control:
+			f.sent <- o.deliver(f.msg)
+			continue
+		case <-timer.C:
+		}
+
+		o.tick()
+		next = next.Add(frameInterval)
+		// After a stall, carry on from now rather than bursting the frames missed
+		if time.Since(next) > frameInterval {
+			outLateFrames.Inc()
+			next = time.Now()
+		}
+		timer.Reset(time.Until(next))
+	}
+}
+
+// tick sends the frame due now
+func (o *OutStream) tick() {
+	for _, queue := range o.audio {
+		select {
+		case f := <-queue:
+			f.sent <- o.deliver(f.msg)
+			return
+		default:
+		}
+	}
+	if o.idle != nil {
+		o.idle()
+	}
+}
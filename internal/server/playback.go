@@ -0,0 +1,118 @@
+package server
+
+import (
+	"log"
+	"sync"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+// stoppedChan is the stop channel of a playback stopped before it began
+var stoppedChan = func() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}()
+
+// playbackManager hands out an ID and a stop channel for each prompt a
+// session plays, so stopping one prompt never touches another. The zero
+// value is ready to use.
+type playbackManager struct {
+	mu     sync.Mutex
+	last   flow.PlaybackID
+	active map[flow.PlaybackID]chan struct{} // reserved or playing
+}
+
+// reserve allocates a playback that has not begun yet
+func (p *playbackManager) reserve() flow.PlaybackID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active == nil {
+		p.active = make(map[flow.PlaybackID]chan struct{})
+	}
+	p.last++
+	p.active[p.last] = make(chan struct{})
+	return p.last
+}
+
+// stopChan returns the channel closed to stop playback id; already closed
+// if it was stopped or has ended
+func (p *playbackManager) stopChan(id flow.PlaybackID) <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if stop, ok := p.active[id]; ok {
+		return stop
+	}
+	return stoppedChan
+}
+
+// end forgets a playback that has finished
+func (p *playbackManager) end(id flow.PlaybackID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.active, id)
+}
+
+// stop stops playback id and reports whether it was reserved or playing
+func (p *playbackManager) stop(id flow.PlaybackID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stop, ok := p.active[id]
+	if ok {
+		close(stop)
+		delete(p.active, id)
+	}
+	return ok
+}
+
+// stopAll stops every playback and returns how many there were
+func (p *playbackManager) stopAll() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.active)
+	for id, stop := range p.active {
+		close(stop)
+		delete(p.active, id)
+	}
+	return n
+}
+
+// NewPlayback reserves a playback for PlayAudioAs
+func (session *Session) NewPlayback() flow.PlaybackID {
+	return session.playbacks.reserve()
+}
+
+// PlayAudioAs plays filename as the reserved playback id, stopped by
+// StopPlayback(id) or StopAll and, with bargeIn, as soon as the caller starts
+// speaking
+func (session *Session) PlayAudioAs(id flow.PlaybackID, filename string, bargeIn bool) error {
+	return session.playPrompt(id, filename, bargeIn)
+}
+
+// StopPlayback stops one prompt, playing or not yet begun
+func (session *Session) StopPlayback(id flow.PlaybackID) error {
+	if session.playbacks.stop(id) {
+		log.Printf("Session %s: Audio stop requested (playback %d)", session.id, id)
+	}
+	return nil
+}
+
+// StopAll stops every prompt the session is playing or about to
+func (session *Session) StopAll() error {
+	n := session.playbacks.stopAll()
+	log.Printf("Session %s: Audio stop requested (%d playbacks)", session.id, n)
+	return nil
+}
+
+// StopAudio stops every prompt; see StopAll
+func (session *Session) StopAudio() error {
+	return session.StopAll()
+}
+
+// playback reserves a playback for a prompt the server plays itself, such
+// as a do-not-call message, and returns its stop channel and the function
+// to call when it has finished
+func (session *Session) playback() (<-chan struct{}, func()) {
+	id := session.playbacks.reserve()
+	return session.playbacks.stopChan(id), func() { session.playbacks.end(id) }
+}
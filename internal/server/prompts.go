@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var promptProblems = metrics.NewGauge("audiosocket_prompt_problems", "Audio files the active flow or interrupts play that are missing, do not decode, have the wrong sample rate or are silent")
+
+// WithPromptCheck verifies every audio file the flow and the interrupt
+// patterns play when the server starts and when a flow is staged: it must
+// exist in the audio directory, decode, be recorded at 8kHz and not be
+// silent. With strict the server refuses to start, and the admin API to
+// stage the flow, listing the problems; otherwise they are logged and
+// exported as audiosocket_prompt_problems for alerting.
+func WithPromptCheck(strict bool) Option {
+	return func(c *Config) {
+		c.PromptCheck = true
+		c.PromptCheckStrict = strict
+	}
+}
+
+// checkPrompts returns one problem per broken audio file the flow at
+// flowPath or the interrupt patterns play, and records their number
+func (s *Server) checkPrompts(flowPath string) []string {
+	refs := make(map[string][]string)
+	var problems []string
+	var synthesized map[string]string
+	if flowPath != "" {
+		synthesized, _ = flow.TTSTexts(flowPath)
+		flowRefs, err := flow.PromptReferences(flowPath)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("flow %s: %v", flowPath, err))
+		}
+		for file, nodes := range flowRefs {
+			for _, node := range nodes {
+				if !strings.HasPrefix(node, "metadata.") {
+					node = "node " + node
+				}
+				refs[file] = append(refs[file], node)
+			}
+		}
+	}
+	if s.config.InterruptsPath != "" {
+		matcher, err := audio.NewPatternMatcher(s.config.InterruptsPath)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("interrupts %s: %v", s.config.InterruptsPath, err))
+		} else {
+			for key, rule := range matcher.GetInterrupts() {
+				if rule.AudioFile != "" {
+					refs[rule.AudioFile] = append(refs[rule.AudioFile], "interrupt "+key)
+				}
+			}
+		}
+	}
+
+	files := make([]string, 0, len(refs))
+	for file := range refs {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		sort.Strings(refs[file])
+		if _, ok := synthesized[file]; ok {
+			if err := s.checkSynthesized(file); err != nil {
+				problems = append(problems, fmt.Sprintf("%s (%s): %v", file, strings.Join(refs[file], ", "), err))
+			}
+			continue
+		}
+		err := audio.CheckPrompt(s.config.AudioDir, file)
+		if err == nil && s.audioPlayer != nil {
+			if _, ok := s.audioPlayer.GetAudio(file); !ok {
+				// Prompts are loaded once, when the server starts
+				err = fmt.Errorf("added after startup and not loaded; restart to play it")
+			}
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s (%s): %v", file, strings.Join(refs[file], ", "), err))
+		}
+	}
+	promptProblems.Set(int64(len(problems)))
+	return problems
+}
+
+// loadSayPrompts loads the number and date prompts the flow at flowPath
+// reads callback times back with, failing when one of them is missing: the
+// caller would confirm a time they never heard
+func (s *Server) loadSayPrompts(flowPath string) error {
+	if s.audioPlayer == nil || flowPath == "" {
+		return nil
+	}
+	prompts, err := flow.SayPrompts(flowPath)
+	if err != nil {
+		// The flow's own errors are reported when it is checked or run
+		return nil
+	}
+	dirs := make(map[string]bool)
+	for file := range prompts {
+		dirs[filepath.Dir(file)] = true
+	}
+	for dir := range dirs {
+		if err := s.audioPlayer.LoadDir(dir); err != nil {
+			return err
+		}
+	}
+	var missing []string
+	for file, nodes := range prompts {
+		if _, ok := s.audioPlayer.GetAudio(file); !ok {
+			missing = append(missing, fmt.Sprintf("%s (node %s)", file, strings.Join(nodes, ", node ")))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%d number and date prompts missing from %s for %s: %s", len(missing), s.config.AudioDir, flowPath, strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+// verifyPrompts checks the prompts of the flow at flowPath, logging every
+// problem; in strict mode it returns them as an error
+func (s *Server) verifyPrompts(flowPath string) error {
+	problems := s.checkPrompts(flowPath)
+	if len(problems) == 0 {
+		log.Printf("Prompt audio check passed for %s", flowPath)
+		return nil
+	}
+	for _, problem := range problems {
+		log.Printf("Warning: Prompt audio: %s", problem)
+	}
+	if s.config.PromptCheckStrict {
+		return fmt.Errorf("%d prompt audio problems in %s: %s", len(problems), flowPath, strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+)
+
+var (
+	sessionsStarted    = metrics.NewCounterVec("audiosocket_sessions_started_total", "Sessions started, by transcription provider", "provider")
+	transcriberErrors  = metrics.NewCounterVec("audiosocket_transcriber_errors_total", "Transcribers that could not be created or stopped accepting audio", "provider")
+	firstResultLatency = metrics.NewHistogramVec("audiosocket_transcriber_first_result_seconds", "Time from session start to the provider's first transcription", nil, "provider")
+)
+
+// ProviderRule selects a transcription provider for calls matching a campaign
+// and/or language. Empty match fields act as wildcards.
+type ProviderRule struct {
+	Campaign string // matched against the campaign_id session variable
+	Language string // matched against the language session variable
+	Provider string // provider to use when the rule matches
+}
+
+// WithProvider sets the default provider used when no rule matches
+func WithProvider(name string) Option {
+	return func(c *Config) { c.Provider = name }
+}
+
+// WithProviderConfig passes config to the factory of a provider added with
+// transcriber.Register each time it creates a transcriber, e.g. the
+// provider's section of a YAML file
+func WithProviderConfig(name string, config map[string]any) Option {
+	return func(c *Config) {
+		if c.ProviderConfigs == nil {
+			c.ProviderConfigs = make(map[string]map[string]any)
+		}
+		c.ProviderConfigs[name] = config
+	}
+}
+
+// WithProviderSelection enables per-call provider selection. overrideVar names
+// a session variable (e.g. a Redis hash field) whose value, when set to a
+// known provider, forces it; otherwise rules are evaluated in order.
+func WithProviderSelection(overrideVar string, rules ...ProviderRule) Option {
+	return func(c *Config) {
+		c.ProviderVar = overrideVar
+		c.ProviderRules = append(c.ProviderRules, rules...)
+	}
+}
+
+// selectProvider picks the transcription provider for a session
+func (s *Server) selectProvider(session *Session) string {
+	if s.config.ProviderVar != "" {
+		if v, ok := session.GetVar(s.config.ProviderVar); ok && v != "" {
+			name := strings.ToLower(strings.TrimSpace(v))
+			if _, registered := transcriber.Lookup(name); transcriber.IsBuiltin(name) || registered {
+				return name
+			}
+			log.Printf("Session %s: Unknown provider %q in %s; ignoring it", session.id, v, s.config.ProviderVar)
+		}
+	}
+	if len(s.config.ProviderRules) == 0 {
+		return s.config.Provider
+	}
+
+	campaign, _ := session.GetVar("campaign_id")
+	language, _ := session.GetVar("language")
+	for _, rule := range s.config.ProviderRules {
+		if rule.Campaign != "" && !strings.EqualFold(rule.Campaign, campaign) {
+			continue
+		}
+		if rule.Language != "" && !strings.EqualFold(rule.Language, language) {
+			continue
+		}
+		return rule.Provider
+	}
+	return s.config.Provider
+}
+
+// observeResult records how long the provider took to return its first
+// transcription in the call, and the ASR latency of finals
+func (session *Session) observeResult(result transcriber.TranscriptionResult) {
+	if result.IsFinal && result.Text != "" {
+		session.observeASRLatency()
+	}
+	if result.Text == "" || !session.firstResult.CompareAndSwap(false, true) {
+		return
+	}
+	firstResultLatency.With(session.provider).Observe(time.Since(session.startTime).Seconds())
+}
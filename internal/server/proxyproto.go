@@ -0,0 +1,168 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var proxyHeaderErrors = metrics.NewCounter("audiosocket_proxy_header_errors_total", "Connections from a load balancer closed for a missing or malformed PROXY protocol header")
+
+// DefaultProxyHeaderTimeout is how long a load balancer has to send the
+// PROXY header after connecting
+const DefaultProxyHeaderTimeout = 5 * time.Second
+
+// proxyV2Signature starts every PROXY protocol v2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyV1MaxLength is the longest v1 header, CRLF included
+const proxyV1MaxLength = 107
+
+// WithProxyProtocol reads a PROXY protocol header (v1 or v2), as sent by
+// HAProxy or an AWS NLB, at the start of connections from the given load
+// balancers, so sessions see the real source address of Asterisk rather
+// than the balancer's. trusted lists CIDR ranges or IPs; connections from
+// elsewhere are taken as direct and must not send a header. Without trusted
+// entries every connection must start with one. A connection from a trusted
+// peer without a valid header is closed.
+func WithProxyProtocol(trusted ...string) Option {
+	return func(c *Config) {
+		c.ProxyProtocol = true
+		c.ProxyTrusted = trusted
+	}
+}
+
+// proxyConn is a connection through a load balancer, reporting the source
+// address from its PROXY header
+type proxyConn struct {
+	net.Conn
+	source net.Addr
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr { return c.source }
+
+// acceptProxy reads the PROXY header of a connection from a trusted load
+// balancer and returns the connection with its real source address. It
+// returns conn unchanged for direct connections and when the balancer sends
+// no address, e.g. for its own health checks.
+func (s *Server) acceptProxy(conn net.Conn) (net.Conn, error) {
+	if len(s.proxyTrusted) > 0 && !containsAddr(s.proxyTrusted, conn.RemoteAddr().String()) {
+		return conn, nil
+	}
+	conn.SetReadDeadline(time.Now().Add(DefaultProxyHeaderTimeout))
+	source, err := readProxyHeader(conn)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		proxyHeaderErrors.Inc()
+		return nil, fmt.Errorf("PROXY header from %s: %w", conn.RemoteAddr(), err)
+	}
+	if source == nil {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, source: source}, nil
+}
+
+// readProxyHeader reads a v1 or v2 PROXY header from r and returns the
+// source address in it, nil for a LOCAL or UNKNOWN connection. It reads
+// nothing past the header, so the AudioSocket stream follows untouched.
+func readProxyHeader(r io.Reader) (net.Addr, error) {
+	start := make([]byte, 6)
+	if _, err := io.ReadFull(r, start); err != nil {
+		return nil, err
+	}
+	switch {
+	case string(start) == "PROXY ":
+		return readProxyV1(r)
+	case bytes.Equal(start, proxyV2Signature[:6]):
+		return readProxyV2(r)
+	}
+	return nil, fmt.Errorf("no PROXY header")
+}
+
+// readProxyV1 reads the rest of a text header, e.g.
+// "TCP4 192.0.2.10 10.0.0.5 40000 9019\r\n"
+func readProxyV1(r io.Reader) (net.Addr, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength-6 {
+			return nil, fmt.Errorf("v1 header too long")
+		}
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		line = append(line, b[0])
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) > 0 && fields[0] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 5 || (fields[0] != "TCP4" && fields[0] != "TCP6") {
+		return nil, fmt.Errorf("malformed v1 header %q", strings.TrimSpace(string(line)))
+	}
+	ip := net.ParseIP(fields[1])
+	port, err := strconv.ParseUint(fields[3], 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("malformed v1 source %s:%s", fields[1], fields[3])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2 reads the rest of a binary header after the first six bytes
+// of its signature
+func readProxyV2(r io.Reader) (net.Addr, error) {
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(head[:6], proxyV2Signature[6:]) {
+		return nil, fmt.Errorf("bad v2 signature")
+	}
+	verCmd, family := head[6], head[7]
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("unsupported version %d", verCmd>>4)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(head[8:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	switch verCmd & 0x0f {
+	case 0x0: // LOCAL: the balancer's own connection
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("unsupported command %d", verCmd&0x0f)
+	}
+	// Addresses come first; any TLVs after them are ignored
+	switch family >> 4 {
+	case 0x1: // IPv4
+		if len(body) < 12 {
+			return nil, fmt.Errorf("short v2 IPv4 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:]))}, nil
+	case 0x2: // IPv6
+		if len(body) < 36 {
+			return nil, fmt.Errorf("short v2 IPv6 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:]))}, nil
+	}
+	// UNSPEC or a Unix socket: no address to report
+	return nil, nil
+}
+
+// proxyPeer returns the address of the load balancer conn came through, ""
+// for a direct connection
+func proxyPeer(conn net.Conn) string {
+	if pc, ok := conn.(*proxyConn); ok {
+		return pc.Conn.RemoteAddr().String()
+	}
+	return ""
+}
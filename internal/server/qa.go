@@ -0,0 +1,77 @@
+package server
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/qa"
+)
+
+var (
+	qaScores = metrics.NewHistogramVec("audiosocket_qa_score", "QA score of finished calls out of 100",
+		[]float64{20, 40, 60, 80, 90, 100}, "flow", "campaign")
+	qaViolations = metrics.NewCounterVec("audiosocket_qa_violations_total", "QA rubric violations by check (disclosure, talk_over, disposition)", "check")
+)
+
+// WithQARubric scores each call against rubric after it ends: disclosures
+// played in full, no talk-over on compliance lines and a disposition that
+// matches the transcript. The score and violations go to the session log as
+// a "qa" event, which cmd/qareport summarizes; session logs must be saved
+// (WithOutput).
+func WithQARubric(rubric *qa.Rubric) Option {
+	return func(c *Config) {
+		c.QARubric = rubric
+	}
+}
+
+// scoreCall scores the ended call and logs the result
+func (session *Session) scoreCall() {
+	rubric := session.server.config.QARubric
+	logger := session.flowEngine.GetSessionLogger()
+	if rubric == nil || logger == nil {
+		return
+	}
+	result := rubric.Score(session.qaCall())
+	logger.LogQA(session.id.String(), result)
+
+	l := session.metricLabels()
+	qaScores.With(l.Flow, l.Campaign).Observe(float64(result.Score))
+	for _, v := range result.Violations {
+		qaViolations.With(v.Check).Inc()
+	}
+	if len(result.Violations) > 0 {
+		log.Printf("Session %s: QA score %d with %d violations", session.id, result.Score, len(result.Violations))
+	}
+}
+
+// qaCall collects what the rubric checks: the prompts played, the caller's
+// utterances and the interrupts they match, and the final status
+func (session *Session) qaCall() qa.Call {
+	call := qa.Call{Status: session.finalStatus()}
+	for _, p := range session.playedPrompts() {
+		// Time-stretched prompts are scored as the file they were made from
+		file, _, _ := strings.Cut(p.file, "@")
+		call.Prompts = append(call.Prompts, qa.Prompt{File: file, Start: p.Start, End: p.End, Stopped: p.stopped})
+	}
+	for _, u := range session.utterances() {
+		call.Utterances = append(call.Utterances, qa.Utterance{Text: u.Text, Start: u.Start, End: u.End})
+		if key, found := session.CheckForInterrupt(u.Text); found && !slices.Contains(call.Interrupts, key) {
+			call.Interrupts = append(call.Interrupts, key)
+		}
+	}
+	return call
+}
+
+// finalStatus is the status the call was dispositioned with; a call that
+// ended without one is scored with the flow's last status, else DC
+func (session *Session) finalStatus() string {
+	if status := session.status.Load(); status != nil {
+		return *status
+	}
+	if lr := session.flowEngine.GetLastReason(); lr != "" {
+		return lr
+	}
+	return "DC"
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+)
+
+// WithProviderCapture dumps every WebSocket frame exchanged with the Vosk or
+// AssemblyAI server to <output dir>/<time>_provider_<id>.jsonl for every
+// call. Credentials are redacted and audio frames are logged by size only.
+// Meant for diagnosing provider-side issues, not for production traffic.
+func WithProviderCapture(enabled bool) Option {
+	return func(c *Config) { c.CaptureProviderFrames = enabled }
+}
+
+// frameRecord is one line of a provider frame capture
+type frameRecord struct {
+	T         float64 `json:"t"` // seconds since the capture started
+	Direction string  `json:"dir"`
+	Type      string  `json:"type"`
+	Size      int     `json:"size"`
+	Data      string  `json:"data,omitempty"`
+}
+
+// frameCapture writes the provider frames of one session
+type frameCapture struct {
+	mu    sync.Mutex
+	file  *os.File
+	enc   *json.Encoder
+	start time.Time
+}
+
+// startFrameCapture opens the frame capture for a session and attaches it to
+// the provider behind t. It returns nil if the provider does not support it.
+func (s *Server) startFrameCapture(session *Session, t transcriber.Transcriber) *frameCapture {
+	dir := s.config.OutputDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Session %s: Failed to create provider capture directory: %v", session.id, err)
+		return nil
+	}
+	id := session.id.String()
+	filename := filepath.Join(dir, fmt.Sprintf("%s_provider_%s.jsonl", session.startTime.Format("20060102_150405"), id[:8]))
+
+	capture := &frameCapture{start: time.Now()}
+	if !transcriber.OnRawFrame(t, capture.write) {
+		log.Printf("Session %s: Provider %s does not support frame capture", session.id, session.provider)
+		return nil
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Printf("Session %s: Failed to create provider capture: %v", session.id, err)
+		return nil
+	}
+
+	capture.mu.Lock()
+	capture.file = f
+	capture.enc = json.NewEncoder(f)
+	capture.mu.Unlock()
+	log.Printf("Session %s: Capturing %s frames to %s", session.id, session.provider, filename)
+	return capture
+}
+
+func (c *frameCapture) write(f transcriber.RawFrame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return
+	}
+	_ = c.enc.Encode(frameRecord{
+		T:         time.Since(c.start).Seconds(),
+		Direction: f.Direction,
+		Type:      f.Type,
+		Size:      f.Size,
+		Data:      string(f.Data),
+	})
+}
+
+// Close ends the capture; frames arriving later are dropped
+func (c *frameCapture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
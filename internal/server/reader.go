@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/bufpool"
+)
+
+// Read timeout defaults: a healthy AudioSocket stream delivers a frame every
+// 20ms, so a few seconds without data is a gap worth tolerating, not a failure
+const (
+	DefaultReadTimeout = 2 * time.Second
+	DefaultReadRetries = 5
+)
+
+// WithReadTimeout sets the per-read deadline on AudioSocket connections and
+// how many consecutive timeouts are tolerated before the session is torn
+// down. A zero timeout blocks indefinitely.
+func WithReadTimeout(timeout time.Duration, retries int) Option {
+	return func(c *Config) {
+		c.ReadTimeout = timeout
+		c.ReadRetries = retries
+	}
+}
+
+// messageReader reads AudioSocket messages, keeping partially received
+// frames across read timeouts so a network stall does not desynchronize the
+// stream
+type messageReader struct {
+	conn    net.Conn
+	timeout time.Duration
+	retries int
+	id      string
+
+	buf  []byte // bytes of the message being assembled
+	prev []byte // message returned by the last Next, recycled on the next call
+}
+
+// frameBufSize fits a 20ms 16kHz SLIN frame plus its header, the largest
+// frame Asterisk normally sends
+const frameBufSize = 3 + 640
+
+func newMessageReader(conn net.Conn, id string, timeout time.Duration, retries int) *messageReader {
+	return &messageReader{conn: conn, id: id, timeout: timeout, retries: retries}
+}
+
+// Next returns the next complete message. Read timeouts are retried up to the
+// configured limit; any other error is returned as is. Messages are read into
+// pooled buffers: the returned message is only valid until the next call.
+func (r *messageReader) Next() (audiosocket.Message, error) {
+	if r.prev != nil {
+		bufpool.PutBytes(r.prev)
+		r.prev = nil
+	}
+	if r.buf == nil {
+		r.buf = bufpool.GetBytes(frameBufSize)
+	}
+
+	misses := 0
+	for {
+		need := 3
+		if len(r.buf) >= 3 {
+			need += int(binary.BigEndian.Uint16(r.buf[1:3]))
+		}
+		if len(r.buf) == need {
+			msg := audiosocket.Message(r.buf)
+			r.prev = r.buf
+			r.buf = nil
+			return msg, nil
+		}
+		if cap(r.buf) < need {
+			grown := append(bufpool.GetBytes(need), r.buf...)
+			bufpool.PutBytes(r.buf)
+			r.buf = grown
+		}
+
+		if r.timeout > 0 {
+			r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+		}
+		n, err := r.conn.Read(r.buf[len(r.buf):need])
+		r.buf = r.buf[:len(r.buf)+n]
+		if n > 0 {
+			misses = 0
+		}
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, errReconnected) {
+			// The call moved to a new connection; drop the old partial frame
+			log.Printf("Session %s: Reading from reconnected stream", r.id)
+			r.buf = r.buf[:0]
+			misses = 0
+			continue
+		}
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			misses++
+			if misses > r.retries {
+				return nil, fmt.Errorf("no data for %v: %w", time.Duration(misses)*r.timeout, err)
+			}
+			log.Printf("Session %s: Read timeout (%d/%d), %d bytes of partial frame kept", r.id, misses, r.retries, len(r.buf))
+			continue
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultRecentCallWindow is how soon after a call to a lead another call
+// to it counts as a redial
+const DefaultRecentCallWindow = 10 * time.Minute
+
+// WithRecentCallFlow runs flowPath, a short "we just spoke" flow, instead of
+// the campaign's flow when a lead is called again within window of its
+// previous call (DefaultRecentCallWindow if 0), as happens when the dialer
+// redials after a glitch. Calls are tracked per lead_id in Redis so every
+// instance sees them; without Redis each instance remembers its own.
+func WithRecentCallFlow(window time.Duration, flowPath string) Option {
+	return func(c *Config) {
+		c.RecentCallWindow = window
+		c.RecentCallFlow = flowPath
+	}
+}
+
+// loadRecentCallFlow validates the configured redial flow
+func loadRecentCallFlow(path string) (*FlowVersion, error) {
+	meta, err := flow.LoadFlowMetadata(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load recent call flow: %w", err)
+	}
+	return &FlowVersion{Path: path, Name: meta.Name, Version: meta.Version, DeployedAt: time.Now()}, nil
+}
+
+// recentLeads remembers leads called by this instance, for when Redis is
+// unavailable
+type recentLeads struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// seen records a call to lead and reports whether another started less
+// than window before it
+func (r *recentLeads) seen(lead string, window time.Duration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.until == nil {
+		r.until = make(map[string]time.Time)
+	}
+	for l, until := range r.until {
+		if now.After(until) {
+			delete(r.until, l)
+		}
+	}
+	_, recent := r.until[lead]
+	r.until[lead] = now.Add(window)
+	return recent
+}
+
+// isRedial records the session's call to its lead and reports whether the
+// lead was called within the recent call window. Each call restarts the
+// window, so a run of redials all get the short flow.
+func (s *Server) isRedial(session *Session) bool {
+	if s.recentFlow == nil || session.leadID == "" {
+		return false
+	}
+	window := s.recentCallWindow()
+	if s.redis != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		key := s.config.RedisPrefix + "recent_lead:" + session.leadID
+		_, err := s.redis.SetArgs(ctx, key, session.id.String(), redis.SetArgs{TTL: window, Get: true}).Result()
+		switch {
+		case err == nil:
+			return true
+		case errors.Is(err, redis.Nil):
+			return false
+		}
+		log.Printf("Session %s: Failed to check recent calls to lead %s in Redis, using this instance's: %v", session.id, session.leadID, err)
+	}
+	return s.recentLeads.seen(session.leadID, window)
+}
+
+func (s *Server) recentCallWindow() time.Duration {
+	if s.config.RecentCallWindow <= 0 {
+		return DefaultRecentCallWindow
+	}
+	return s.config.RecentCallWindow
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+// DefaultReconcileWindow is how long a posted disposition keeps being checked
+const DefaultReconcileWindow = time.Hour
+
+var (
+	dispositionDiscrepancies = metrics.NewCounter("audiosocket_disposition_discrepancies_total", "Posted dispositions whose Vicidial lead status did not match")
+	dispositionReposts       = metrics.NewCounter("audiosocket_disposition_reposts_total", "Dispositions re-posted by the reconciler")
+	dispositionRepostErrors  = metrics.NewCounter("audiosocket_disposition_repost_errors_total", "Reconciler re-posts that failed")
+)
+
+// WithReconciler periodically compares the dispositions this server posted
+// against the lead status in Vicidial and re-posts any that are missing or
+// different, catching API calls that failed silently. Each outcome is checked
+// every interval until it matches or is older than window
+// (DefaultReconcileWindow if zero). A lead whose status has moved on from
+// the one it had before the call, e.g. an agent's later disposition, is left
+// alone. Outcomes are kept in memory only.
+func WithReconciler(interval, window time.Duration) Option {
+	return func(c *Config) {
+		c.ReconcileInterval = interval
+		c.ReconcileWindow = window
+	}
+}
+
+// callOutcome is a disposition posted for a finished session
+type callOutcome struct {
+	sessionID  string
+	leadID     string
+	campaignID string
+	callID     string
+	status     string
+	prior      string // lead status before the disposition was posted
+	postedAt   time.Time
+}
+
+// recordOutcome remembers the status about to be posted for a session, and
+// the lead's status before it, so the reconciler can verify it later.
+// Vicidial identifiers are captured now because the Redis call variables may
+// expire before the check runs.
+func (s *Server) recordOutcome(apiClient *flow.APIClient, session *Session, status string) {
+	if s.config.ReconcileInterval <= 0 {
+		return
+	}
+	leadID, _ := session.GetVar("lead_id")
+	if leadID == "" {
+		return
+	}
+	campaignID, _ := session.GetVar("campaign_id")
+	callID, _ := session.GetVar("display")
+	prior, err := apiClient.GetLeadStatus(leadID)
+	if err != nil {
+		// Only an empty status will be re-posted over
+		log.Printf("Session %s: Failed to look up lead %s status: %v", session.id, leadID, err)
+	}
+
+	s.outcomesMu.Lock()
+	defer s.outcomesMu.Unlock()
+	s.outcomes = append(s.outcomes, &callOutcome{
+		sessionID:  session.id.String(),
+		leadID:     leadID,
+		campaignID: campaignID,
+		callID:     callID,
+		status:     status,
+		prior:      prior,
+		postedAt:   time.Now(),
+	})
+}
+
+// runReconciler checks recorded outcomes every interval until Stop
+func (s *Server) runReconciler() {
+	ticker := time.NewTicker(s.config.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdown:
+			return
+		case <-ticker.C:
+			s.reconcile(s.newVicidialClient())
+		}
+	}
+}
+
+// reconcile verifies outcomes posted at least one interval ago. Matching
+// outcomes are dropped, as are leads whose status something else has set
+// since; a lead still showing its status from before the call, or none, is
+// re-posted and checked again on the next pass until the window expires.
+func (s *Server) reconcile(apiClient *flow.APIClient) {
+	window := s.config.ReconcileWindow
+	if window <= 0 {
+		window = DefaultReconcileWindow
+	}
+	now := time.Now()
+
+	s.outcomesMu.Lock()
+	var due, pending []*callOutcome
+	for _, o := range s.outcomes {
+		age := now.Sub(o.postedAt)
+		switch {
+		case age > window:
+			log.Printf("Session %s: Giving up reconciling disposition %s for lead %s", o.sessionID, o.status, o.leadID)
+		case age < s.config.ReconcileInterval:
+			pending = append(pending, o)
+		default:
+			due = append(due, o)
+		}
+	}
+	s.outcomes = pending
+	s.outcomesMu.Unlock()
+
+	var retry []*callOutcome
+	for _, o := range due {
+		current, err := apiClient.GetLeadStatus(o.leadID)
+		if err != nil {
+			log.Printf("Session %s: Failed to look up lead %s status: %v", o.sessionID, o.leadID, err)
+			retry = append(retry, o)
+			continue
+		}
+		if current == o.status {
+			continue
+		}
+		if current != "" && current != o.prior {
+			log.Printf("Session %s: Lead %s status changed to %s since disposition %s was posted; leaving it",
+				o.sessionID, o.leadID, current, o.status)
+			continue
+		}
+
+		dispositionDiscrepancies.Inc()
+		log.Printf("Session %s: Disposition discrepancy for lead %s: posted %s, Vicidial has %q; re-posting",
+			o.sessionID, o.leadID, o.status, current)
+		dispositionReposts.Inc()
+		if err := apiClient.UpdateLeadStatus(o.leadID, o.status); err != nil {
+			dispositionRepostErrors.Inc()
+			log.Printf("Session %s: update_lead(%s) re-post failed: %v", o.sessionID, o.status, err)
+		}
+		if o.campaignID != "" && o.callID != "" {
+			if err := apiClient.UpdateLogEntry(o.campaignID, o.callID, o.status); err != nil {
+				dispositionRepostErrors.Inc()
+				log.Printf("Session %s: update_log_entry(%s) re-post failed: %v", o.sessionID, o.status, err)
+			}
+		}
+		retry = append(retry, o)
+	}
+
+	s.outcomesMu.Lock()
+	s.outcomes = append(s.outcomes, retry...)
+	s.outcomesMu.Unlock()
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/bufpool"
+)
+
+// recordingSegment is the size of one recording segment (~2s of 8kHz audio)
+const recordingSegment = 32 * 1024
+
+// DefaultAudioSpillBytes is how much recorded audio is kept in memory before
+// it spills to disk: 8MB, about 8.5 minutes of 8kHz audio
+const DefaultAudioSpillBytes = 8 << 20
+
+// WithAudioSpill sets how many bytes of a call's recorded audio (SaveAudio)
+// are held in memory before the recording spills to a temporary file in the
+// output directory, so calls lasting hours do not sit in RAM. 0 selects
+// DefaultAudioSpillBytes; a negative value keeps everything in memory.
+func WithAudioSpill(bytes int) Option {
+	return func(c *Config) { c.AudioSpillBytes = bytes }
+}
+
+// audioRecording accumulates a call's inbound audio for SaveAudio in pooled
+// fixed-size segments. Unlike one growing slice, appending never copies the
+// audio recorded so far, so every frame is copied exactly once.
+//
+// Once more than spillAt bytes are recorded, full segments are moved to a
+// temporary file in dir and only the segment being filled stays in memory.
+type audioRecording struct {
+	segments [][]byte
+	size     int
+
+	spillAt int    // bytes kept in memory before spilling; <= 0 never spills
+	dir     string // directory of the spill file
+	file    *os.File
+	failed  bool // spilling failed; the rest of the call stays in memory
+}
+
+// newAudioRecording creates a recording spilling to dir beyond spillAt bytes
+func newAudioRecording(spillAt int, dir string) audioRecording {
+	if spillAt == 0 {
+		spillAt = DefaultAudioSpillBytes
+	}
+	return audioRecording{spillAt: spillAt, dir: dir}
+}
+
+// Write appends p to the recording. The audio is always recorded; an error
+// only reports that spilling to disk failed and memory is used instead.
+func (r *audioRecording) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		last := len(r.segments) - 1
+		if last < 0 || len(r.segments[last]) == cap(r.segments[last]) {
+			r.segments = append(r.segments, bufpool.GetBytes(recordingSegment))
+			last++
+		}
+		seg := r.segments[last]
+		m := copy(seg[len(seg):cap(seg)], p)
+		r.segments[last] = seg[:len(seg)+m]
+		p = p[m:]
+	}
+	r.size += n
+
+	if r.failed || r.spillAt <= 0 || (r.file == nil && r.size <= r.spillAt) {
+		return n, nil
+	}
+	if err := r.spill(); err != nil {
+		r.failed = true
+		return n, fmt.Errorf("failed to spill audio to disk: %w", err)
+	}
+	return n, nil
+}
+
+// spill moves the full segments to the spill file, creating it on first use
+func (r *audioRecording) spill() error {
+	if r.file == nil {
+		dir := r.dir
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		f, err := os.CreateTemp(dir, "audio-*.spill")
+		if err != nil {
+			return err
+		}
+		r.file = f
+	}
+
+	full := 0
+	for full < len(r.segments) && len(r.segments[full]) == cap(r.segments[full]) {
+		if _, err := r.file.Write(r.segments[full]); err != nil {
+			r.segments = r.segments[full:]
+			return err
+		}
+		bufpool.PutBytes(r.segments[full])
+		full++
+	}
+	r.segments = append(r.segments[:0], r.segments[full:]...)
+	return nil
+}
+
+// Len returns the number of recorded bytes
+func (r *audioRecording) Len() int {
+	return r.size
+}
+
+// WriteTo writes the recording to w, streaming the spilled part from disk
+func (r *audioRecording) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	if r.file != nil {
+		if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		n, err := io.Copy(w, r.file)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	for _, seg := range r.segments {
+		n, err := w.Write(seg)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Release returns the segments to the pool and removes the spill file; the
+// recording is empty afterwards
+func (r *audioRecording) Release() {
+	for _, seg := range r.segments {
+		bufpool.PutBytes(seg)
+	}
+	r.segments = nil
+	r.size = 0
+	if r.file != nil {
+		r.file.Close()
+		os.Remove(r.file.Name())
+		r.file = nil
+	}
+}
+
+// writeRecording saves r to filename
+func writeRecording(filename string, r *audioRecording) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
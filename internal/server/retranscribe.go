@@ -0,0 +1,134 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/dsp"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+)
+
+var (
+	retranscriptions  = metrics.NewCounterVec("audiosocket_retranscriptions_total", "Post-call re-transcriptions by result (ok, failed, dropped)", "result")
+	liveTranscriptWER = metrics.NewHistogramVec("audiosocket_live_transcript_wer", "Word error rate of live transcripts against their offline re-transcription",
+		[]float64{0.05, 0.1, 0.15, 0.2, 0.3, 0.5, 1}, "provider")
+)
+
+// DefaultRetranscribeQueue is how many calls may wait for re-transcription
+// before further calls are skipped
+const DefaultRetranscribeQueue = 100
+
+// WithRetranscription re-transcribes each saved call recording after the
+// call with t, e.g. a Whisper model, using workers concurrent requests (1 if
+// 0). The offline transcript is saved next to the live one as
+// <start>_offline_<id>.txt with a word diff against the live transcript and
+// its word error rate. It runs off the call path, so live latency is
+// unaffected; it needs recordings to be saved (WithOutput).
+func WithRetranscription(t transcriber.OfflineTranscriber, workers int) Option {
+	return func(c *Config) {
+		c.Retranscriber = t
+		c.RetranscribeWorkers = workers
+	}
+}
+
+// retranscribeJob is an ended call waiting to be re-transcribed
+type retranscribeJob struct {
+	sessionID  string
+	provider   string
+	started    time.Time
+	recording  string // the saved .raw caller audio
+	sampleRate int
+	live       string // the live transcript
+}
+
+// startRetranscription runs the re-transcription workers until shutdown.
+// Calls still queued then are not re-transcribed.
+func (s *Server) startRetranscription() {
+	if s.retranscribe == nil {
+		return
+	}
+	workers := max(s.config.RetranscribeWorkers, 1)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-s.shutdown:
+					return
+				case job := <-s.retranscribe:
+					s.retranscribeCall(job)
+				}
+			}
+		}()
+	}
+}
+
+// queueRetranscription hands the call's saved recording to the
+// re-transcription workers
+func (session *Session) queueRetranscription() {
+	s := session.server
+	if s.retranscribe == nil || !s.config.SaveAudio || session.recording.Len() == 0 {
+		return
+	}
+	live := session.transcriber.GetFullTranscript()
+	if s.config.TranscriptNormalizer != nil {
+		live = transcriber.NormalizeTranscript(s.config.TranscriptNormalizer, live)
+	}
+	job := retranscribeJob{
+		sessionID:  session.id.String(),
+		provider:   session.provider,
+		started:    session.startTime,
+		recording:  session.audioFilename(),
+		sampleRate: s.config.SampleRate,
+		live:       live,
+	}
+	select {
+	case s.retranscribe <- job:
+	default:
+		retranscriptions.With("dropped").Inc()
+		log.Printf("Session %s: Re-transcription queue full, skipping the call", session.id)
+	}
+}
+
+// retranscribeCall transcribes job's recording offline and saves the result
+func (s *Server) retranscribeCall(job retranscribeJob) {
+	filename := filepath.Join(s.config.OutputDir, fmt.Sprintf("%s_offline_%s.txt", job.started.Format("20060102_150405"), job.sessionID[:8]))
+	if err := s.writeRetranscription(job, filename); err != nil {
+		retranscriptions.With("failed").Inc()
+		log.Printf("Session %s: Re-transcription failed: %v", job.sessionID, err)
+		return
+	}
+	retranscriptions.With("ok").Inc()
+	log.Printf("Session %s: Offline transcript saved to %s", job.sessionID, filename)
+}
+
+func (s *Server) writeRetranscription(job retranscribeJob, filename string) error {
+	pcm, err := os.ReadFile(job.recording)
+	if err != nil {
+		return err
+	}
+	if job.sampleRate != 8000 {
+		pcm = dsp.ResampleBytes(pcm, job.sampleRate, 8000)
+	}
+	started := time.Now()
+	text, err := s.config.Retranscriber.Transcribe(audio.EncodeWAV(pcm))
+	if err != nil {
+		return err
+	}
+
+	diff := transcriber.DiffTranscripts(job.live, text)
+	liveTranscriptWER.With(job.provider).Observe(diff.WER())
+	var b strings.Builder
+	fmt.Fprintf(&b, "Session ID: %s\nLive Provider: %s\nStart Time: %s\nTranscribed In: %v\n",
+		job.sessionID, job.provider, job.started.Format("2006-01-02 15:04:05"), time.Since(started).Round(time.Millisecond))
+	fmt.Fprintf(&b, "Live WER: %.1f%% (%d substituted, %d missed, %d extra of %d words)\n",
+		diff.WER()*100, diff.Substitutions, diff.Deletions, diff.Insertions, diff.Words)
+	b.WriteString("\n---TRANSCRIPT---\n\n" + text + "\n")
+	b.WriteString("\n---DIFF--- [-live-]{+offline+}\n\n" + diff.Text + "\n")
+	return os.WriteFile(filename, []byte(b.String()), 0644)
+}
@@ -0,0 +1,23 @@
+package server
+
+import "math/rand"
+
+// WithSeed fixes the seed every session's random choices (prompt variants,
+// script random() and choice(), comfort noise) derive from. A call replayed
+// with the seed from its session log makes the same choices, e.g. in the
+// self-test. 0 picks a random seed per session.
+func WithSeed(seed int64) Option {
+	return func(c *Config) { c.Seed = seed }
+}
+
+// sessionSeed returns the seed for a new session
+func (s *Server) sessionSeed() int64 {
+	if s.config.Seed != 0 {
+		return s.config.Seed
+	}
+	for {
+		if seed := rand.Int63(); seed != 0 {
+			return seed
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/CyCoreSystems/audiosocket"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+	"github.com/google/uuid"
+)
+
+// DefaultSelfTestAnswer is what the scripted caller says to every question
+// when no script is given
+const DefaultSelfTestAnswer = "yes"
+
+// selfTestAnswerInterval is how often the scripted caller repeats its answer
+// until the flow moves on, like a caller repeating themselves
+const selfTestAnswerInterval = 500 * time.Millisecond
+
+// SelfTestStep is one scripted caller answer
+type SelfTestStep struct {
+	Node   string `json:"node,omitempty"`   // question node the answer is for; empty matches any
+	Say    string `json:"say"`              // transcript returned for the node; empty lets it time out
+	Expect string `json:"expect,omitempty"` // node the flow should move to next
+}
+
+// NodeResult is the outcome of one node visited during a self-test call
+type NodeResult struct {
+	Node           string `json:"node"`
+	Type           string `json:"type"`
+	Said           string `json:"said,omitempty"`
+	Classification string `json:"classification,omitempty"`
+	Passed         bool   `json:"passed"`
+	Detail         string `json:"detail,omitempty"`
+
+	exited bool
+}
+
+// SelfTestReport is the outcome of a self-test call
+type SelfTestReport struct {
+	Nodes    []NodeResult  `json:"nodes"`
+	Ended    bool          `json:"ended"`            // the flow reached a transfer or hangup
+	Errors   []string      `json:"errors,omitempty"` // failures not tied to a node
+	Duration time.Duration `json:"duration"`
+	Seed     int64         `json:"seed"` // session seed; WithSeed replays the same choices
+}
+
+// Passed reports whether every node passed and the flow ended
+func (r *SelfTestReport) Passed() bool {
+	if !r.Ended || len(r.Errors) > 0 {
+		return false
+	}
+	for _, n := range r.Nodes {
+		if !n.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// SelfTest places one call through a server built from opts over a loopback
+// AudioSocket connection. A scripted transcriber answers each question node
+// following script (DefaultSelfTestAnswer everywhere when it is empty) and
+// every node visited is checked: its prompt is loaded, the answer moves the
+// flow to the expected node, and the flow ends in a transfer or hangup
+// within timeout.
+//
+// The call touches nothing outside the process: results are not saved,
+// Vicidial is not called and the admin API, fleet registration and
+// middleware are disabled.
+func SelfTest(script []SelfTestStep, timeout time.Duration, opts ...Option) (*SelfTestReport, error) {
+	st := &selfTest{
+		script:      script,
+		transcriber: &scriptedTranscriber{results: make(chan transcriber.TranscriptionResult, 16)},
+		ended:       make(chan struct{}),
+	}
+	opts = append(opts, func(c *Config) {
+		c.Host, c.Port = "127.0.0.1", 0
+		c.Provider = "selftest"
+		c.TranscriberFactory = func(string) (transcriber.Transcriber, error) { return st.transcriber, nil }
+		c.VoskServerURLs, c.VoskModelPath = nil, ""
+		c.OutputDir, c.SaveTranscripts, c.SaveAudio, c.SaveSessionLogs = "", false, false, false
+		c.Vicidial = VicidialConfig{}
+		c.Hooks = append(c.Hooks, st)
+		c.Middleware = nil
+		c.AdminAddr, c.AdvertiseAddr = "", ""
+		c.Workers, c.Listeners = 0, 0
+		c.DeadAirTimeout, c.StaleTimeout, c.ReconcileInterval = 0, 0, 0
+		c.DebugSampleRate, c.DebugLeadIDs, c.CaptureProviderFrames = 0, nil, false
+	})
+	srv, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if srv.audioPlayer == nil {
+		return nil, fmt.Errorf("self-test needs an audio directory to run the flow")
+	}
+	st.srv = srv
+	if srv.config.Seed == 0 {
+		// Fixed up front so the report can name it
+		srv.config.Seed = srv.sessionSeed()
+	}
+
+	started := make(chan error, 1)
+	go func() { started <- srv.Start() }()
+	for !srv.Ready() {
+		select {
+		case err := <-started:
+			return nil, err
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	defer srv.Stop()
+
+	begin := time.Now()
+	report := &SelfTestReport{Seed: srv.config.Seed}
+	if err := st.call(srv.listeners[0].Addr().String(), timeout); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	report.Duration = time.Since(begin)
+	st.finish(report)
+	return report, nil
+}
+
+// selfTest drives the flow of a self-test call through its hooks
+type selfTest struct {
+	flow.NopHooks
+	srv         *Server
+	transcriber *scriptedTranscriber
+
+	mu        sync.Mutex
+	script    []SelfTestStep
+	step      int
+	nodes     []NodeResult
+	expect    string        // node the last answer should lead to
+	answering chan struct{} // closed to stop repeating the current answer
+
+	ended     chan struct{}
+	endedOnce sync.Once
+}
+
+// call dials the server, streams silence as the caller's audio and waits for
+// the flow to end, the server to hang up or the timeout
+func (st *selfTest) call(addr string, timeout time.Duration) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(audiosocket.IDMessage(uuid.New())); err != nil {
+		return fmt.Errorf("failed to send call ID: %w", err)
+	}
+
+	// Read prompts until the server hangs up
+	hungUp := make(chan struct{})
+	go func() {
+		defer close(hungUp)
+		for {
+			msg, err := audiosocket.NextMessage(conn)
+			if err != nil || msg.Kind() == audiosocket.KindHangup {
+				return
+			}
+		}
+	}()
+
+	frame := audiosocket.SlinMessage(make([]byte, st.srv.config.SampleRate/50*2))
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := conn.Write(frame); err != nil {
+				return nil // the server closed the call
+			}
+		case <-st.ended:
+			conn.Write(audiosocket.HangupMessage())
+			return nil
+		case <-hungUp:
+			return nil
+		case <-deadline:
+			conn.Write(audiosocket.HangupMessage())
+			return fmt.Errorf("flow did not end within %v", timeout)
+		}
+	}
+}
+
+// OnNodeEnter checks the node's prompt and the previous answer's transition,
+// and starts answering question nodes
+func (st *selfTest) OnNodeEnter(sessionID string, node *flow.FlowNode) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.expect != "" && st.expect != node.ID && len(st.nodes) > 0 {
+		prev := &st.nodes[len(st.nodes)-1]
+		prev.fail(fmt.Sprintf("moved to %s, expected %s", node.ID, st.expect))
+	}
+	st.expect = ""
+
+	result := NodeResult{Node: node.ID, Type: node.Type, Passed: true}
+	for _, file := range node.AudioFiles() {
+		if _, ok := st.srv.audioPlayer.GetAudio(node.PromptFor(file)); !ok {
+			result.fail(fmt.Sprintf("prompt %s is not loaded", node.PromptFor(file)))
+		}
+	}
+	if node.Type == "question" {
+		say, err := st.answer(node)
+		if err != nil {
+			result.fail(err.Error())
+		}
+		result.Said = say
+		if say != "" {
+			st.answering = make(chan struct{})
+			go st.transcriber.repeat(say, st.answering)
+		}
+	}
+	st.nodes = append(st.nodes, result)
+}
+
+// OnNodeExit stops answering and ends the call after a terminal node
+func (st *selfTest) OnNodeExit(sessionID string, node *flow.FlowNode) {
+	st.mu.Lock()
+	if st.answering != nil {
+		close(st.answering)
+		st.answering = nil
+	}
+	if len(st.nodes) > 0 {
+		st.nodes[len(st.nodes)-1].exited = true
+	}
+	st.mu.Unlock()
+
+	if node.Type == "transfer" || node.Type == "hangup" || (node.Type == "interrupt" && node.Transitions["default"] == "") {
+		st.endedOnce.Do(func() { close(st.ended) })
+	}
+}
+
+// OnClassify records how the scripted answer was classified
+func (st *selfTest) OnClassify(sessionID string, node *flow.FlowNode, text string, result flow.ResponseType) flow.ResponseType {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if n := len(st.nodes); n > 0 && st.nodes[n-1].Node == node.ID && st.nodes[n-1].Classification == "" {
+		st.nodes[n-1].Classification = string(result)
+	}
+	return result
+}
+
+// answer returns the scripted answer for a question node and consumes its
+// step; st.mu must be held
+func (st *selfTest) answer(node *flow.FlowNode) (string, error) {
+	if len(st.script) == 0 {
+		return DefaultSelfTestAnswer, nil
+	}
+	if st.step >= len(st.script) {
+		return "", fmt.Errorf("script has no answer left")
+	}
+	step := st.script[st.step]
+	if step.Node != "" && step.Node != node.ID {
+		return "", fmt.Errorf("script expects node %s", step.Node)
+	}
+	st.step++
+	st.expect = step.Expect
+	return step.Say, nil
+}
+
+// finish completes report once the call is over
+func (st *selfTest) finish(report *SelfTestReport) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	select {
+	case <-st.ended:
+		report.Ended = true
+	default:
+	}
+	for i := range st.nodes {
+		n := &st.nodes[i]
+		if n.Type == "question" && !n.exited {
+			n.fail("flow did not move on")
+		}
+	}
+	if st.expect != "" {
+		report.Errors = append(report.Errors, fmt.Sprintf("flow ended before reaching %s", st.expect))
+	}
+	for _, step := range st.script[min(st.step, len(st.script)):] {
+		report.Errors = append(report.Errors, fmt.Sprintf("script step for %q was never reached", step.Node))
+	}
+	report.Nodes = append([]NodeResult(nil), st.nodes...)
+}
+
+// fail marks the node failed, keeping the first reason
+func (n *NodeResult) fail(detail string) {
+	if n.Passed {
+		n.Detail = detail
+	}
+	n.Passed = false
+}
+
+// scriptedTranscriber is the self-test transcriber: it ignores the caller's
+// audio and returns the scripted answers as final results
+type scriptedTranscriber struct {
+	mu      sync.Mutex
+	results chan transcriber.TranscriptionResult
+	closed  bool
+}
+
+func (t *scriptedTranscriber) ProcessAudio([]byte) error                       { return nil }
+func (t *scriptedTranscriber) Results() <-chan transcriber.TranscriptionResult { return t.results }
+func (t *scriptedTranscriber) GetFullTranscript() string                       { return "" }
+func (t *scriptedTranscriber) AddMarker(string)                                {}
+
+func (t *scriptedTranscriber) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.results)
+	}
+	return nil
+}
+
+// repeat says text every selfTestAnswerInterval until stop is closed
+func (t *scriptedTranscriber) repeat(text string, stop <-chan struct{}) {
+	ticker := time.NewTicker(selfTestAnswerInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			t.say(text)
+		}
+	}
+}
+
+// say returns text as a final result unless the transcriber is closed
+func (t *scriptedTranscriber) say(text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.results <- transcriber.TranscriptionResult{Text: text, IsFinal: true}:
+	default:
+	}
+}
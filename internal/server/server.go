@@ -2,75 +2,342 @@ package server
 
 import (
     "context"
+    "crypto/tls"
+    "errors"
     "fmt"
     "io"
     "log"
     "net"
+    "net/http"
     "os"
     "path/filepath"
+    "runtime/debug"
+    "strings"
     "sync"
+    "sync/atomic"
     "time"
 
     "github.com/CyCoreSystems/audiosocket"
     "github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+    "github.com/amanullahtanweer/audiosocket-transcriber/internal/buildinfo"
+    "github.com/amanullahtanweer/audiosocket-transcriber/internal/dsp"
     "github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+    "github.com/amanullahtanweer/audiosocket-transcriber/internal/notify"
+    "github.com/amanullahtanweer/audiosocket-transcriber/internal/qa"
     "github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+    "github.com/amanullahtanweer/audiosocket-transcriber/internal/tts"
     "github.com/google/uuid"
     redis "github.com/redis/go-redis/v9"
 )
 
+// Config holds the resolved server settings. It is populated from defaults
+// and the Options passed to New.
 type Config struct {
     Host            string
     Port            int
-    Provider        string // "vosk" or "assemblyai"
+    Provider        string // "vosk", "vosk_local", "assemblyai", a registered provider (transcriber.Register) or "custom" when a TranscriberFactory is set
+    ProviderConfigs map[string]map[string]any // settings of registered providers, by name
     VoskServerURL   string
+    VoskServerURLs  []string // several Vosk servers, balanced by least connections
+    VoskModelPath   string   // model directory for the in-process "vosk_local" provider
+    VoskSampleRate  int      // rate the Vosk model expects; 0 means SampleRate
     AssemblyAPIKey  string
-    SampleRate      int
+    SampleRate      int      // AudioSocket session audio rate
     OutputDir       string
     SaveTranscripts bool
     SaveAudio       bool
+    AudioSpillBytes int // recorded audio kept in memory before spilling to disk; 0 = DefaultAudioSpillBytes, < 0 = never
     AudioDir        string // Directory containing audio files
+    FlowPath        string // Flow definition (default ./config/flow.json)
+    InterruptsPath  string // Interrupt patterns (default ./config/interrupts.yaml)
     SaveSessionLogs bool   // Save structured session logs
-    // Vicidial API
-    VicidialServerURL   string
-    VicidialAdminDir    string
-    VicidialAPIUser     string
-    VicidialAPIPass     string
-    VicidialSourceRA    string
-    VicidialSourceAdmin string
-    TransferStatus      string // e.g., LVXFER
-    TransferPhone       string // e.g., 26000
+    SubtitleFormats []string // Also export transcripts as "srt" and/or "vtt"
+    TranscriptNormalizer transcriber.Normalizer // Rewrites saved transcripts for reading; nil saves them as transcribed
+
+    // Synthesizes nodes' tts_text prompts (see tts.go); nil leaves them unplayable
+    TTS tts.Synthesizer
+
+    // Re-transcribes saved recordings after the call (see retranscribe.go); nil disables
+    Retranscriber       transcriber.OfflineTranscriber
+    RetranscribeWorkers int
+
+    // Scores each call against a compliance rubric (see qa.go); nil disables
+    QARubric *qa.Rubric
+
+    // Prompt audio verification at startup and flow staging (see prompts.go)
+    PromptCheck       bool
+    PromptCheckStrict bool
+
+    // Optional custom transcriber, used instead of the built-in providers
+    TranscriberFactory TranscriberFactory
+
+    // Per-call provider selection (see provider.go)
+    ProviderVar   string
+    ProviderRules []ProviderRule
+
+    Vicidial VicidialConfig
 
     // Redis (defaults suitable for localhost)
     RedisAddr   string // e.g., "localhost:6379"
     RedisDB     int    // default 0
     RedisPrefix string // optional prefix; default empty means bare UUID key
+
+    // Plugin hooks attached to every session's flow engine
+    Hooks []flow.Hooks
+
+    // Calendar confirmed callbacks are booked in; nil books them in Vicidial only
+    Calendar flow.Calendar
+
+    // Senders of the flow's notify actions, by channel (sms, email, webhook)
+    Notifiers map[string]notify.Sender
+
+    // Outbox of webhook notify actions; empty disables them
+    WebhookOutboxDir string
+    WebhookMaxAge    time.Duration
+
+    // Agent users shared between calls; zero size or TTL disables sharing
+    AgentCacheSize int
+    AgentCacheTTL  time.Duration
+
+    // Provider connections kept open for new calls; zero disables them
+    StandbyTranscribers int
+    StandbyMaxIdle      time.Duration
+
+    // Session middleware, applied in order before the flow engine starts
+    Middleware []Middleware
+
+    // PROXY protocol headers from load balancers (see proxyproto.go)
+    ProxyProtocol bool
+    ProxyTrusted  []string // balancers sending the header; empty = every peer
+
+    // Live session API listen address; empty disables it
+    AdminAddr string
+
+    // One-way audio detection; zero timeout disables it
+    DeadAirTimeout time.Duration
+    DeadAirStatus  string
+
+    // AudioSocket read deadline and tolerated consecutive timeouts
+    ReadTimeout time.Duration
+    ReadRetries int
+
+    // Terminate sessions with no inbound frames for this long; zero disables
+    StaleTimeout time.Duration
+    StaleStatus  string
+
+    // Send a silence frame when no audio was sent for this long; zero disables
+    KeepAliveInterval time.Duration
+
+    // Let supervisors listen to calls through the admin API (see listen.go)
+    LiveListen bool
+
+    // Handling of connections reusing an active session's UUID
+    DuplicatePolicy string
+
+    // Disposition reconciliation against Vicidial; zero interval disables it
+    ReconcileInterval time.Duration
+    ReconcileWindow   time.Duration
+
+    // Transfer outcome tracking (see transfercheck.go); zero interval disables it
+    TransferCheckInterval time.Duration
+    TransferCheckTimeout  time.Duration
+    AbandonStatuses       []string
+
+    // Caller escalation detection from the audio (see escalation.go); nil disables
+    Escalation *audio.EscalationSettings
+
+    // Caller background noise classification (see background.go)
+    BackgroundClassification bool
+    BackgroundSeconds        float64 // background audio needed to decide; 0 = audio.DefaultBackgroundSeconds
+
+    // Hold music and IVR detection on the caller side (see machine.go)
+    MachineDetection bool
+    MachineSeconds   float64 // caller audio heard before deciding; 0 = audio.DefaultMachineSeconds
+    MachineStatus    string
+
+    // Fixed seed for every session's random choices; 0 picks one per session
+    Seed int64
+
+    // Beep, fax and SIT tone detection for the flow (see tone.go)
+    ToneDetection bool
+
+    // Disconnected-number disposition on an early SIT tone (see tone.go)
+    SITDisposition bool
+    SITWindow      time.Duration // 0 = DefaultSITWindow
+    SITStatus      string
+
+    // Short flow for leads called again soon after a call (see recentcall.go)
+    RecentCallWindow time.Duration // 0 = DefaultRecentCallWindow
+    RecentCallFlow   string
+
+    // Local do-not-call list checked at session start (see dnc.go)
+    DNCRedisKey string
+    DNCFile     string
+    DNCPrompt   string
+
+    // Experimental behaviors enabled per campaign (see features.go)
+    Features FeatureFlags
+
+    // Detailed debug capture for a fraction of calls and specific leads
+    DebugSampleRate float64
+    DebugLeadIDs    []string
+
+    // Dump raw provider WebSocket frames for every call
+    CaptureProviderFrames bool
+
+    // Bounded connection handling (see workers.go); 0 workers = one goroutine per connection
+    Workers     int
+    AcceptQueue int // connections waiting for a worker; 0 = Workers
+    Listeners   int // accept loops sharing the port via SO_REUSEPORT; 0 or 1 = one
+
+    // Fleet registration in Redis for least-loaded routing (see fleet.go)
+    AdvertiseAddr string // host:port Asterisk connects to; empty = not registered
+    Capacity      int    // concurrent calls advertised; 0 = Workers or DefaultInstanceCapacity
+
+    // Admin API authentication (see adminauth.go)
+    AdminCredentials []AdminCredential
+    AdminTLSCert     string
+    AdminTLSKey      string
+    AdminClientCA    string            // verify client certificates against this CA
+    AdminClientRoles map[string]string // client certificate common name -> role
+
+    // Append-only, hash-chained log of admin control actions (see audit.go)
+    AuditLogPath string
+
+    // Turn new calls away while providers are slow (see shed.go); nil disables
+    LoadShedding *LoadShedding
+
+    // Graceful shutdown (see drain.go)
+    DrainTimeout time.Duration // wait for calls to end; 0 = DefaultDrainTimeout
+    DrainStatus  string        // disposition for calls cut off by the drain; empty = DC
+}
+
+// VicidialConfig holds Vicidial API credentials and transfer settings
+type VicidialConfig struct {
+    ServerURL      string
+    AdminDir       string
+    APIUser        string
+    APIPass        string
+    SourceRA       string
+    SourceAdmin    string
+    TransferStatus string // e.g., LVXFER
+    TransferPhone  string // e.g., 26000
 }
 
 type Server struct {
     config     Config
-    listener   net.Listener
+    listeners  []net.Listener // several with SO_REUSEPORT (see listeners.go)
+    ready      atomic.Bool    // accepting calls; false while starting and draining
     wg         sync.WaitGroup
     shutdown   chan struct{}
     audioPlayer *audio.Player
     redis      *redis.Client
+    recentFlow  *FlowVersion // flow for redials, nil when off
+    recentLeads recentLeads
+    voskPool   *transcriber.VoskPool
+    voskModel  *transcriber.VoskModel // shared in-process Vosk model
+    standby    map[string]*standbyPool // connected transcribers by provider; nil when off
+    admin      *http.Server
+    adminTLS   *tls.Config // nil serves the admin API over plain HTTP
+    auditLog   *auditLog // admin action audit trail; nil when disabled
+
+    sessions   map[string]*Session // active sessions by UUID
+    sessionsMu sync.RWMutex
+
+    outcomes   []*callOutcome // posted dispositions awaiting reconciliation
+    outcomesMu sync.Mutex
+
+    dnc        *dncList // local do-not-call list; nil when disabled
+    proxyTrusted []*net.IPNet // load balancers sending PROXY headers; empty = every peer
+    shedder    *loadShedder // turns calls away while providers are slow; nil when disabled
+    flows      *flowDeployments // active/staged flow versions per campaign
+    webhooks   *notify.Outbox // queued webhook notify actions; nil when disabled
+    agentCache *flow.AgentCache // agent users shared between calls; nil when disabled
+    listenTokens listenTokens // tokens for listening to calls live
+    retranscribe chan retranscribeJob // calls waiting for offline re-transcription; nil when disabled
 }
 
 type Session struct {
     id          uuid.UUID
     conn        net.Conn
+    remoteAddr  string
+    proxyAddr   string // load balancer the call came through; empty when direct
     transcriber transcriber.Transcriber
+    timeline    *transcriber.TimedTranscriber // same transcriber, for utterance offsets
+    provider    string // transcription provider selected for this call
     server      *Server
-    audioBuffer []byte
+    recording   audioRecording // inbound audio kept for SaveAudio
     startTime   time.Time
     stopAmbient chan struct{} // Channel to stop ambient audio
+    comfortNoise *audio.ComfortNoise // fills silence between prompts; nil when disabled
+    sequencer   *audio.Sequencer // plays prompts one after another
     patternMatcher *audio.PatternMatcher // Handles pattern-based interrupt detection
     flowEngine  *flow.FlowEngine // Handles call flow execution
-    stopAudioChan chan struct{} // Channel to stop current audio playback
+    flowVersion FlowVersion // flow the engine runs
+    playbacks  playbackManager // prompts playing, each stopped on its own (see playback.go)
+    voice      *audio.VoiceDetector // caller speech onsets, for barge-in
+    wasSpeaking bool // the caller spoke in the last frame; read loop only
+    speechEnd  atomic.Int64 // unix nanos the caller last fell silent, for ASR latency (see shed.go)
+    bargeIn    atomic.Pointer[string] // prompt caller speech stops, while it plays
+    digits     chan byte // DTMF key presses for collect_digits nodes
+    escalation *audio.EscalationDetector // nil unless escalation detection is on
+    escalations chan string // escalation levels for the flow engine
+    background *audio.BackgroundClassifier // nil unless background classification is on
+    machine    *audio.MachineDetector // nil unless machine detection is on
+    tones      *dsp.ToneDetector // nil unless tone detection is on
+    toneEvents chan string // detected tones for the flow engine
     vars       map[string]string // session-scoped variables (placeholder for Redis)
+    varsMu     sync.RWMutex
+    dispositioned atomic.Bool // final status already posted to Vicidial
+    status        atomic.Pointer[string] // final status counted for the call, for QA
+    firstResult   atomic.Bool // first transcription seen, for the provider latency metric
+    lastFrame  atomic.Int64 // unix nanos of the last inbound frame
+    features   map[string]bool // feature flags resolved at call start
+    seed       int64 // random choices of the call derive from this (see seed.go)
+    phone       string // number the call is with, once captured (see callerid.go)
+    phoneSource string // where phone came from
+    leadID      string // Vicidial lead of the call
+    liveMu      sync.RWMutex // guards provider, flowEngine and the caller fields, which the admin API reads mid-call
+    redial      bool   // lead called again within the recent call window
+    debug      *debugCapture // detailed capture for sampled calls; nil otherwise
+    inLevel    *audio.LevelMeter // caller audio levels
+    outLevel   *audio.LevelMeter // audio sent to the caller
+    monitor    *liveMonitor // mixed audio for live listeners; nil when disabled
+    prompts    []playedPrompt // bot prompts played, for the dialogue transcript and QA
+    promptsMu  sync.Mutex
+    finalizeOnce sync.Once // finalize runs once, whichever exit path gets there first
 }
 
-func New(config Config) (*Server, error) {
+// New creates a server from defaults overridden by opts
+func New(opts ...Option) (*Server, error) {
+    config := defaultConfig()
+    for _, opt := range opts {
+        opt(&config)
+    }
+    if config.TranscriberFactory != nil && config.Provider == "" {
+        config.Provider = "custom"
+    }
+
+    if err := validDuplicatePolicy(config.DuplicatePolicy); err != nil {
+        return nil, err
+    }
+    if err := validateAdminAuth(&config); err != nil {
+        return nil, err
+    }
+    proxyTrusted, err := parseCIDRs(config.ProxyTrusted)
+    if err != nil {
+        return nil, fmt.Errorf("invalid PROXY protocol peer %w", err)
+    }
+    adminTLS, err := adminTLSConfig(&config)
+    if err != nil {
+        return nil, err
+    }
+    for _, format := range config.SubtitleFormats {
+        if format != "srt" && format != "vtt" {
+            return nil, fmt.Errorf("unsupported subtitle format: %s", format)
+        }
+    }
+
     // Create output directory if needed
     if (config.SaveTranscripts || config.SaveAudio || config.SaveSessionLogs) && config.OutputDir != "" {
         if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
@@ -92,13 +359,57 @@ func New(config Config) (*Server, error) {
         config:     config,
         shutdown:   make(chan struct{}),
         audioPlayer: audioPlayer,
+        sessions:   make(map[string]*Session),
+        adminTLS:   adminTLS,
+        proxyTrusted: proxyTrusted,
+        flows:      newFlowDeployments(config.FlowPath),
+        agentCache: newAgentCache(&config),
+    }
+
+    if config.Retranscriber != nil {
+        srv.retranscribe = make(chan retranscribeJob, DefaultRetranscribeQueue)
+    }
+    if config.LoadShedding != nil {
+        srv.shedder = newLoadShedder(*config.LoadShedding)
+    }
+
+    if config.TTS != nil && config.FlowPath != "" {
+        if err := srv.synthesizeFlow(config.FlowPath); err != nil {
+            log.Printf("Warning: %v", err)
+        }
+    }
+
+    if err := srv.loadSayPrompts(config.FlowPath); err != nil {
+        return nil, err
+    }
+
+    // Catch missing or broken prompts before a caller hears dead air
+    if config.PromptCheck && audioPlayer != nil {
+        if err := srv.verifyPrompts(config.FlowPath); err != nil {
+            return nil, err
+        }
+    }
+
+    // Balance Vosk sessions across several servers when more than one is configured
+    if len(config.VoskServerURLs) > 1 {
+        pool, err := transcriber.NewVoskPool(config.VoskServerURLs, 0)
+        if err != nil {
+            return nil, fmt.Errorf("failed to create Vosk pool: %w", err)
+        }
+        srv.voskPool = pool
+    }
+
+    // Load the in-process Vosk model once; recognizers share it
+    if config.VoskModelPath != "" {
+        model, err := transcriber.LoadVoskModel(config.VoskModelPath)
+        if err != nil {
+            return nil, fmt.Errorf("failed to load Vosk model: %w", err)
+        }
+        srv.voskModel = model
     }
 
     // Initialize Redis client (assume localhost if unset)
     addr := config.RedisAddr
-    if addr == "" {
-        addr = "localhost:6379"
-    }
     srv.redis = redis.NewClient(&redis.Options{Addr: addr, DB: config.RedisDB})
     ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
     defer cancel()
@@ -108,55 +419,153 @@ func New(config Config) (*Server, error) {
         log.Printf("Connected to Redis at %s (db=%d)", addr, config.RedisDB)
     }
 
+    if config.AuditLogPath != "" {
+        auditLog, err := openAuditLog(config.AuditLogPath)
+        if err != nil {
+            return nil, err
+        }
+        srv.auditLog = auditLog
+    }
+
+    if config.RecentCallFlow != "" {
+        if srv.recentFlow, err = loadRecentCallFlow(config.RecentCallFlow); err != nil {
+            return nil, err
+        }
+    }
+
+    if config.DNCRedisKey != "" || config.DNCFile != "" {
+        list, err := newDNCList(srv.redis, config.DNCRedisKey, config.DNCFile)
+        if err != nil {
+            return nil, err
+        }
+        srv.dnc = list
+    }
+
+    if err := srv.openWebhookOutbox(); err != nil {
+        return nil, err
+    }
+
     return srv, nil
 }
 
 func (s *Server) Start() error {
     addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
-    listener, err := net.Listen("tcp", addr)
+    listeners, err := s.listen(addr)
     if err != nil {
         return fmt.Errorf("failed to listen on %s: %w", addr, err)
     }
-    s.listener = listener
+    s.listeners = listeners
+    s.ready.Store(true)
 
-    log.Printf("AudioSocket server listening on %s", addr)
+    if len(listeners) > 1 {
+        log.Printf("AudioSocket server listening on %s (%d listeners)", addr, len(listeners))
+    } else {
+        log.Printf("AudioSocket server listening on %s", addr)
+    }
     log.Printf("Transcription provider: %s", s.config.Provider)
 
+    if s.config.AdminAddr != "" {
+        s.startAdmin()
+    }
+    if s.config.ReconcileInterval > 0 && s.config.Vicidial.ServerURL != "" {
+        go s.runReconciler()
+    }
+    if s.config.AdvertiseAddr != "" {
+        go s.runRegistration()
+    }
+    if s.webhooks != nil {
+        go s.webhooks.Run(s.shutdown)
+    }
+    s.startRetranscription()
+    s.startStandby()
+
+    for i, listener := range listeners[1:] {
+        go s.acceptLoop(listener, s.newShard(i+1, len(listeners)))
+    }
+    s.acceptLoop(listeners[0], s.newShard(0, len(listeners)))
+    return nil
+}
+
+// acceptLoop accepts connections on listener until shutdown and hands them
+// to the workers of sh
+func (s *Server) acceptLoop(listener net.Listener, sh *acceptShard) {
     for {
         select {
         case <-s.shutdown:
-            return nil
+            return
         default:
             conn, err := listener.Accept()
             if err != nil {
                 select {
                 case <-s.shutdown:
-                    return nil
+                    return
                 default:
                     log.Printf("Accept error: %v", err)
                     continue
                 }
             }
 
-            s.wg.Add(1)
-            go s.handleConnection(conn)
+            s.dispatch(sh, conn)
         }
     }
 }
 
 func (s *Server) Stop() {
+    s.ready.Store(false)
+    s.stopAccepting()
+    s.wg.Wait()
+    s.release()
+}
+
+// stopAccepting closes the listeners and signals background loops to stop
+func (s *Server) stopAccepting() {
     close(s.shutdown)
-    if s.listener != nil {
-        s.listener.Close()
+    for _, listener := range s.listeners {
+        listener.Close()
+    }
+}
+
+// release closes the admin API and the provider resources shared by sessions
+func (s *Server) release() {
+    if s.admin != nil {
+        s.admin.Close()
+    }
+    for _, pool := range s.standby {
+        pool.close()
+    }
+    if s.voskPool != nil {
+        s.voskPool.Close()
+    }
+    if s.voskModel != nil {
+        s.voskModel.Close()
+    }
+    if s.auditLog != nil {
+        s.auditLog.Close()
     }
-    s.wg.Wait()
 }
 
 func (s *Server) handleConnection(conn net.Conn) {
     defer s.wg.Done()
-    defer conn.Close()
+    adopted := false
+    defer func() {
+        if !adopted {
+            conn.Close()
+        }
+    }()
 
-    log.Printf("New connection from %s", conn.RemoteAddr())
+    if s.config.ProxyProtocol {
+        proxied, err := s.acceptProxy(conn)
+        if err != nil {
+            log.Printf("Rejecting connection: %v", err)
+            return
+        }
+        conn = proxied
+    }
+    if proxy := proxyPeer(conn); proxy != "" {
+        log.Printf("New connection from %s via %s", conn.RemoteAddr(), proxy)
+    } else {
+        log.Printf("New connection from %s", conn.RemoteAddr())
+    }
 
     // Read the initial ID message
     id, err := audiosocket.GetID(conn)
@@ -165,109 +574,233 @@ func (s *Server) handleConnection(conn net.Conn) {
         return
     }
 
-    log.Printf("Session %s started with %s", id, s.config.Provider)
+    session := &Session{
+        id:          id,
+        remoteAddr:  conn.RemoteAddr().String(),
+        proxyAddr:   proxyPeer(conn),
+        server:      s,
+        startTime:   time.Now(),
+        stopAmbient: make(chan struct{}),
+        digits:     make(chan byte, 32),
+        vars:       make(map[string]string),
+        recording:  newAudioRecording(s.config.AudioSpillBytes, s.config.OutputDir),
+        inLevel:    &audio.LevelMeter{},
+        outLevel:   &audio.LevelMeter{},
+        escalations: make(chan string, 1),
+        toneEvents: make(chan string, 4),
+        seed:       s.sessionSeed(),
+        voice:      audio.NewVoiceDetector(audio.VoiceSettings{}, s.config.SampleRate),
+    }
+    if s.config.Escalation != nil {
+        session.escalation = audio.NewEscalationDetector(*s.config.Escalation, s.config.SampleRate)
+    }
+    if s.config.BackgroundClassification {
+        session.background = audio.NewBackgroundClassifier(s.config.SampleRate, s.config.BackgroundSeconds)
+    }
+    if s.config.MachineDetection {
+        session.machine = audio.NewMachineDetector(s.config.SampleRate, s.config.MachineSeconds)
+    }
+    if s.config.ToneDetection {
+        session.tones = dsp.NewToneDetector(s.config.SampleRate)
+    }
+    sconn := newSessionConn(conn, session.outLevel)
+    if s.config.LiveListen {
+        session.monitor = newLiveMonitor()
+        sconn.monitor = session.monitor
+    }
+    session.conn = sconn
 
-    // Create appropriate transcriber based on provider
-    var sessionTranscriber transcriber.Transcriber
-    
-    switch s.config.Provider {
-    case "vosk":
-        sessionTranscriber, err = transcriber.NewVoskTranscriber(
-            s.config.VoskServerURL,
-            s.config.SampleRate,
-        )
-    case "assemblyai":
-        sessionTranscriber, err = transcriber.NewAssemblyAITranscriber(
-            s.config.AssemblyAPIKey,
-            s.config.SampleRate,
-        )
-    default:
-        err = fmt.Errorf("unknown provider: %s", s.config.Provider)
+    // Asterisk may retry a call whose session is still active
+    if existing := s.register(session); existing != nil {
+        sconn.out.close()
+        adopted = s.handleDuplicate(existing, conn)
+        return
+    }
+    defer s.unregister(session)
+    // Close whichever connection the session ended on
+    defer session.conn.Close()
+
+    // Run the middleware chain; the innermost handler runs the session itself
+    handler := s.runSession
+    for i := len(s.config.Middleware) - 1; i >= 0; i-- {
+        handler = s.config.Middleware[i](handler)
+    }
+    if err := handler(session); err != nil {
+        log.Printf("Session %s rejected: %v", id, err)
+        if err := session.EndCall(); err != nil {
+            log.Printf("Session %s: %v", id, err)
+        }
+    }
+}
+
+// runSession creates the transcriber and flow engine for an accepted session
+// and processes AudioSocket messages until the call ends
+func (s *Server) runSession(session *Session) error {
+    id := session.id
+    conn := session.conn
+
+    // Slow providers turn new calls away so the calls in progress keep working
+    if s.shedLoad(session) {
+        return nil
     }
 
+    s.captureCaller(session)
+    s.forgetAgent(session)
+    session.redial = s.isRedial(session)
+
+    // Callers on the local DNC list never reach the flow
+    if s.checkDNC(session) {
+        return nil
+    }
+
+    session.features = s.resolveFeatures(session)
+    if names := session.enabledFeatures(); len(names) > 0 {
+        log.Printf("Session %s: Features enabled: %s", id, strings.Join(names, ", "))
+    }
+
+    // Pick the provider for this call (campaign/language rules, Redis override)
+    provider := s.selectProvider(session)
+    session.liveMu.Lock()
+    session.provider = provider
+    session.liveMu.Unlock()
+    log.Printf("Session %s started with %s", id, session.provider)
+    sessionsStarted.With(session.provider).Inc()
+
+    // Sampled calls get a detailed debug capture, closed after the transcriber
+    if session.debug = s.startDebugCapture(session); session.debug != nil {
+        defer session.debug.Close()
+    }
+
+    // Create appropriate transcriber based on provider
+    sessionTranscriber, err := s.newTranscriber(id.String(), session.provider)
     if err != nil {
         log.Printf("Failed to create transcriber for session %s: %v", id, err)
-        return
+        transcriberErrors.With(session.provider).Inc()
+        return nil
+    }
+    if s.config.CaptureProviderFrames {
+        // Closed after the transcriber so the EOF/termination exchange is kept
+        if capture := s.startFrameCapture(session, sessionTranscriber); capture != nil {
+            defer capture.Close()
+        }
     }
     defer sessionTranscriber.Close()
-
-    session := &Session{
-        id:          id,
-        conn:        conn,
-        transcriber: sessionTranscriber,
-        server:      s,
-        audioBuffer: make([]byte, 0, 16000), // Buffer for ~1 second of audio
-        startTime:   time.Now(),
-        stopAmbient: make(chan struct{}),
-        stopAudioChan: make(chan struct{}),
-        vars:       make(map[string]string),
+    session.timeline = transcriber.NewTimedTranscriber(sessionTranscriber, s.config.SampleRate)
+    session.transcriber = session.timeline
+    // Saves the call on every way out, a panic included, before the
+    // transcriber closes; the regular exit below finalizes first
+    defer session.finalize()
+    if session.debug != nil {
+        session.debug.attach(session.timeline)
     }
 
     // Initialize pattern matcher if audio player is available
     if s.audioPlayer != nil {
         var err error
-        session.patternMatcher, err = audio.NewPatternMatcher("./config/interrupts.yaml")
+        session.patternMatcher, err = audio.NewPatternMatcher(s.config.InterruptsPath)
         if err != nil {
             log.Printf("Session %s: Failed to initialize pattern matcher: %v", id, err)
         } else {
             log.Printf("Session %s: Pattern matcher initialized", id)
         }
         
-        // Initialize flow engine
-        session.flowEngine, err = flow.NewFlowEngine(session, "./config/flow.json")
+        // Initialize flow engine with the version deployed for the campaign
+        campaign, _ := session.GetVar("campaign_id")
+        flowVersion := s.flows.Active(campaign)
+        if session.redial {
+            log.Printf("Session %s: Lead %s called again within %v, running %s", id, session.leadID, s.recentCallWindow(), s.recentFlow.Path)
+            flowVersion = *s.recentFlow
+        }
+        session.flowVersion = flowVersion
+        engine, err := flow.NewFlowEngine(session, flowVersion.Path)
         if err != nil {
             log.Printf("Session %s: Failed to initialize flow engine: %v", id, err)
         } else {
-            log.Printf("Session %s: Flow engine initialized", id)
+            log.Printf("Session %s: Flow engine initialized (flow %s, seed %d)", id, flowVersion.Label(), session.seed)
+            // Transcripts, subtitles and debug captures keep digits masked too
+            session.timeline.SetRedact(session.maskSpoken)
+            engine.SetSeed(session.seed)
+            engine.SetMetricLabels(session.metricLabels())
+            // Synthesize prompts added since the flow was loaded, then
+            // prepare speed-adjusted prompts; both cached after the first call
+            if err := s.synthesizeNodes(engine.Nodes()); err != nil {
+                log.Printf("Session %s: %v", id, err)
+            }
+            for _, node := range engine.Nodes() {
+                for _, file := range node.AudioFiles() {
+                    if name := node.PromptFor(file); name != file {
+                        if err := s.audioPlayer.AddSpeedVariant(name, file, node.Speed); err != nil {
+                            log.Printf("Session %s: Failed to prepare %s: %v", id, name, err)
+                        }
+                    }
+                }
+            }
+            engine.AddHooks(s.config.Hooks...)
+            engine.SetCalendar(s.config.Calendar)
+            for channel, sender := range s.config.Notifiers {
+                engine.SetNotifier(channel, sender)
+            }
             // Attach session logger if enabled
             if s.config.SaveSessionLogs {
                 logger, err := flow.NewSessionLogger(s.config.OutputDir, id.String(), session.startTime)
                 if err != nil {
                     log.Printf("Session %s: Failed to create session logger: %v", id, err)
                 } else {
-                    session.flowEngine.SetSessionLogger(logger)
-                }
-            }
-            // Provide start context (phone | lead_id) from Redis if available
-            if session.flowEngine != nil {
-                // Try multiple possible phone keys; pick first non-empty
-                phone := ""
-                for _, k := range []string{"phone_number", "phone", "callerid", "cid", "ani"} {
-                    if v, ok := session.GetVar(k); ok && v != "" {
-                        phone = v
-                        break
+                    engine.SetSessionLogger(logger)
+                    logger.LogFlowVersion(id.String(), campaign, flowVersion.Path, flowVersion.Label())
+                    logger.LogSeed(id.String(), session.seed)
+                    logger.LogPeer(id.String(), session.remoteAddr, session.proxyAddr)
+                    if session.phone != "" {
+                        logger.LogCaller(id.String(), session.phone, session.leadID, session.phoneSource)
                     }
+                    session.timeline.OnUtterance(func(u transcriber.Utterance) {
+                        logger.LogUtterance(id.String(), u.Text, u.Start, u.End)
+                    })
                 }
-                leadID := ""
-                if v, ok := session.GetVar("lead_id"); ok {
-                    leadID = v
-                }
-                session.flowEngine.SetStartContext(phone, leadID)
+            }
+            // Provide start context (phone | lead_id) captured at session start
+            if engine != nil {
+                engine.SetStartContext(session.phone, session.leadID)
             }
             // Configure Vicidial API client
-            apiClient := flow.NewVicidialClient(
-                s.config.VicidialServerURL,
-                s.config.VicidialAdminDir,
-                s.config.VicidialAPIUser,
-                s.config.VicidialAPIPass,
-                s.config.VicidialSourceRA,
-                s.config.VicidialSourceAdmin,
-                s.config.TransferStatus,
-                s.config.TransferPhone,
-            )
+            apiClient := s.newVicidialClient()
             apiClient.SetRedis(s.redis, s.config.RedisPrefix)
-            if session.flowEngine != nil { // propagate logger for session-scoped api_call logs
+            if engine != nil { // propagate logger for session-scoped api_call logs
                 // engine.SetAPIClient will also propagate, but set here in case of timing/order
-                apiClient.SetLogger(session.flowEngine.GetSessionLogger())
+                apiClient.SetLogger(engine.GetSessionLogger())
             }
-            session.flowEngine.SetAPIClient(apiClient)
+            engine.SetAPIClient(apiClient)
+            // Published once configured; the admin API reads it while the call runs
+            session.liveMu.Lock()
+            session.flowEngine = engine
+            session.liveMu.Unlock()
         }
     }
 
     // Start ambient audio if audio player is available
     if s.audioPlayer != nil {
-        // Start ambient audio
-        s.audioPlayer.StartAmbientAudio(conn, session.stopAmbient)
+        // The flow's background bed, under everything the bot says
+        ambient := session.startAmbient()
+
+        // Prompts play one at a time, joined as the flow specifies
+        var crossfade, gap time.Duration
+        if session.flowEngine != nil {
+            if pb := session.flowEngine.Metadata().Playback; pb != nil {
+                crossfade = time.Duration(pb.CrossfadeMs) * time.Millisecond
+                gap = time.Duration(pb.GapMs) * time.Millisecond
+            }
+        }
+        session.sequencer = s.audioPlayer.NewSequencer(conn, crossfade, gap)
+
+        // Comfort noise between prompts, if the flow asks for it
+        if session.flowEngine != nil {
+            if cfg := session.flowEngine.Metadata().ComfortNoise; cfg != nil && cfg.Enabled && !ambient {
+                session.comfortNoise = s.audioPlayer.NewComfortNoise(filler(conn), cfg.RoomTone, cfg.LevelDB)
+                session.comfortNoise.SetRand(flow.NewRand(session.seed, flow.RandComfortNoise))
+                session.comfortNoise.Start(session.stopAmbient)
+                log.Printf("Session %s: Comfort noise enabled", id)
+            }
+        }
     }
 
             // Start flow engine
@@ -283,15 +816,30 @@ func (s *Server) handleConnection(conn net.Conn) {
             go session.handleTranscription()
         }
 
+    // Hang up early on dead channels
+    done := make(chan struct{})
+    defer close(done)
+    if s.config.DeadAirTimeout > 0 {
+        go s.watchDeadAir(session, done)
+    }
+    if s.config.StaleTimeout > 0 {
+        go s.watchHeartbeat(session, done)
+    }
+    if sconn, ok := conn.(*sessionConn); ok && s.config.KeepAliveInterval > 0 {
+        go s.keepAlive(session, sconn, done)
+    }
+
     // Process messages
+    reader := newMessageReader(conn, id.String(), s.config.ReadTimeout, s.config.ReadRetries)
     for {
-        msg, err := audiosocket.NextMessage(conn)
+        msg, err := reader.Next()
         if err != nil {
-            if err != io.EOF {
+            if !errors.Is(err, io.EOF) {
                 log.Printf("Session %s: Failed to read message: %v", id, err)
             }
             break
         }
+        session.touch()
 
         if err := session.handleMessage(msg); err != nil {
             log.Printf("Session %s: Error handling message: %v", id, err)
@@ -302,63 +850,129 @@ func (s *Server) handleConnection(conn net.Conn) {
         if msg.Kind() == audiosocket.KindHangup {
             log.Printf("Session %s: Received hangup", id)
             // If the caller hung up (custom/non-flow), post DC updates
-            if session.flowEngine != nil {
-                apiClient := flow.NewVicidialClient(
-                    s.config.VicidialServerURL,
-                    s.config.VicidialAdminDir,
-                    s.config.VicidialAPIUser,
-                    s.config.VicidialAPIPass,
-                    s.config.VicidialSourceRA,
-                    s.config.VicidialSourceAdmin,
-                    s.config.TransferStatus,
-                    s.config.TransferPhone,
-                )
-                // Attach Redis for var resolution
-                apiClient.SetRedis(s.redis, s.config.RedisPrefix)
-                // Attach session logger if available to log Vicidial calls during hangup
-                if session.flowEngine != nil {
-                    apiClient.SetLogger(session.flowEngine.GetSessionLogger())
+            if engine := session.engine(); engine != nil {
+                // Determine final status: prefer flow-derived reason, DC as last resort
+                status := engine.GetLastReason()
+                if status == "" {
+                    status = "DC"
                 }
-                // Determine final status: prefer flow-derived reason; skip DC if transferred
-                status := "DC"
-                if session.flowEngine.WasTransferred() {
+                if engine.WasTransferred() {
                     // Transfer already reported; do not send DC or override
                     log.Printf("Session %s: Skipping DC due to prior transfer", id)
-                } else if lr := session.flowEngine.GetLastReason(); lr != "" {
-                    status = lr
-                }
-                if status != "DC" {
-                    if err := apiClient.UpdateRaCallControlBySession(id.String(), "HANGUP", status, ""); err != nil {
-                        log.Printf("Session %s: ra_call_control(HANGUP,%s) failed: %v", id, status, err)
-                    }
-                    if err := apiClient.UpdateLeadStatusBySession(id.String(), status); err != nil {
-                        log.Printf("Session %s: update_lead_status(%s) failed: %v", id, status, err)
-                    }
-                    if err := apiClient.UpdateLogEntryBySession(id.String(), status); err != nil {
-                        log.Printf("Session %s: update_log_entry(%s) failed: %v", id, status, err)
+                    if session.dispositioned.CompareAndSwap(false, true) {
+                        s.countDisposition(session, status)
                     }
                 } else {
-                    // No final status known; proceed with DC as last resort
-                    if err := apiClient.UpdateRaCallControlBySession(id.String(), "HANGUP", "DC", ""); err != nil {
-                        log.Printf("Session %s: ra_call_control(HANGUP,DC) failed: %v", id, err)
-                    }
-                    if err := apiClient.UpdateLeadStatusBySession(id.String(), "DC"); err != nil {
-                        log.Printf("Session %s: update_lead_status failed: %v", id, err)
-                    }
-                    if err := apiClient.UpdateLogEntryBySession(id.String(), "DC"); err != nil {
-                        log.Printf("Session %s: update_log_entry failed: %v", id, err)
-                    }
+                    // Once only, alongside dead air, heartbeat, admin hangup and drain
+                    s.dispose(session, status)
                 }
             }
             break
         }
     }
 
+    if session.flowEngine != nil && session.flowEngine.WasTransferred() {
+        s.trackTransfer(session)
+    }
+    s.writeLeadScore(session)
+
     // Finalize transcription
     session.finalize()
     
     duration := time.Since(session.startTime)
-    log.Printf("Session %s ended (Duration: %v, Provider: %s)", id, duration, s.config.Provider)
+    log.Printf("Session %s ended (Duration: %v, Provider: %s)", id, duration, session.provider)
+    return nil
+}
+
+// newTranscriber creates the per-session transcriber for the configured provider
+func (s *Server) newTranscriber(sessionID, provider string) (transcriber.Transcriber, error) {
+    if s.config.TranscriberFactory != nil {
+        return s.config.TranscriberFactory(sessionID)
+    }
+    if pool := s.standby[provider]; pool != nil {
+        if t, ok := pool.take(); ok {
+            return t, nil
+        }
+    }
+    return s.dialTranscriber(provider)
+}
+
+// dialTranscriber connects a new transcriber of provider: a built-in one,
+// which shares the server's Vosk pool or model, or one added with
+// transcriber.Register
+func (s *Server) dialTranscriber(provider string) (transcriber.Transcriber, error) {
+    // Vosk models are trained at a fixed rate; resample session audio to it
+    modelRate := s.config.VoskSampleRate
+    if modelRate == 0 {
+        modelRate = s.config.SampleRate
+    }
+
+    switch provider {
+    case "vosk":
+        var t transcriber.Transcriber
+        var err error
+        if s.voskPool != nil {
+            t, err = s.voskPool.NewTranscriber(modelRate)
+        } else {
+            t, err = transcriber.NewVoskTranscriber(s.config.VoskServerURL, modelRate)
+        }
+        if err != nil {
+            return nil, err
+        }
+        return transcriber.NewResamplingTranscriber(t, s.config.SampleRate, modelRate), nil
+    case "vosk_local":
+        t, err := transcriber.NewVoskLocalTranscriber(s.voskModel, modelRate)
+        if err != nil {
+            return nil, err
+        }
+        return transcriber.NewResamplingTranscriber(t, s.config.SampleRate, modelRate), nil
+    case "assemblyai":
+        // AssemblyAI resamples any input rate to 16kHz itself
+        return transcriber.NewAssemblyAITranscriber(s.config.AssemblyAPIKey, s.config.SampleRate)
+    default:
+        factory, ok := transcriber.Lookup(provider)
+        if !ok {
+            return nil, fmt.Errorf("unknown provider: %s", provider)
+        }
+        return factory(s.config.SampleRate, s.config.ProviderConfigs[provider])
+    }
+}
+
+// postDisposition reports the final call status to Vicidial
+func (s *Server) postDisposition(apiClient *flow.APIClient, session *Session, status string) {
+    sessionID := session.id.String()
+    s.recordOutcome(apiClient, session, status)
+    if err := apiClient.UpdateRaCallControlBySession(sessionID, "HANGUP", status, ""); err != nil {
+        log.Printf("Session %s: ra_call_control(HANGUP,%s) failed: %v", sessionID, status, err)
+    }
+    if err := apiClient.UpdateLeadStatusBySession(sessionID, status); err != nil {
+        log.Printf("Session %s: update_lead_status(%s) failed: %v", sessionID, status, err)
+    }
+    if err := apiClient.UpdateLogEntryBySession(sessionID, status); err != nil {
+        log.Printf("Session %s: update_log_entry(%s) failed: %v", sessionID, status, err)
+    }
+}
+
+// newVicidialClient builds a Vicidial API client from the server config
+func (s *Server) newVicidialClient() *flow.APIClient {
+    vc := s.config.Vicidial
+    // Sources carry the build tag so API log entries trace back to a build
+    client := flow.NewVicidialClient(vc.ServerURL, vc.AdminDir, vc.APIUser, vc.APIPass, buildinfo.Source(vc.SourceRA), buildinfo.Source(vc.SourceAdmin), vc.TransferStatus, vc.TransferPhone)
+    if s.agentCache != nil {
+        client.SetAgentCache(s.agentCache)
+    }
+    if s.shedder != nil {
+        client.SetLatencyObserver(s.shedder.observeVicidial)
+    }
+    return client
+}
+
+// engine returns the session's flow engine, nil until it is configured.
+// Code outside the session's own goroutine reads it through here.
+func (session *Session) engine() *flow.FlowEngine {
+    session.liveMu.RLock()
+    defer session.liveMu.RUnlock()
+    return session.flowEngine
 }
 
 // Session methods to implement flow.Session interface
@@ -366,9 +980,62 @@ func (session *Session) GetID() string {
     return session.id.String()
 }
 
+// RemoteAddr returns the address of the AudioSocket peer
+func (session *Session) RemoteAddr() string {
+    return session.remoteAddr
+}
+
 func (session *Session) PlayAudio(filename string) error {
-	// Use the interruptible audio player with stop channel
-	return session.server.audioPlayer.PlayAudioWithStop(session.conn, filename, session.stopAudioChan)
+	return session.playPrompt(session.playbacks.reserve(), filename, false)
+}
+
+// playPrompt plays filename as playback id, stopped by StopPlayback(id),
+// StopAll or, with bargeIn, as soon as the caller starts speaking
+func (session *Session) playPrompt(id flow.PlaybackID, filename string, bargeIn bool) error {
+	stop := session.playbacks.stopChan(id)
+	defer session.playbacks.end(id)
+	select {
+	case <-stop:
+		return nil // stopped before it began
+	default:
+	}
+
+	if session.comfortNoise != nil {
+		session.comfortNoise.Pause()
+		defer session.comfortNoise.Resume()
+	}
+
+	text := session.promptText(filename)
+	start := session.timeline.Offset()
+	defer func() {
+		stopped := false
+		select {
+		case <-stop:
+			stopped = true
+		default:
+		}
+		session.recordPrompt(filename, text, start, session.timeline.Offset(), stopped)
+	}()
+
+	var disarm func()
+	started := func() {
+		start = session.timeline.Offset()
+		if bargeIn {
+			disarm = session.armBargeIn(filename)
+		}
+	}
+	defer func() {
+		if disarm != nil {
+			disarm()
+		}
+	}()
+
+	// Queue behind any prompt still playing; the stop channel makes it interruptible
+	if session.sequencer != nil {
+		return session.sequencer.Play(filename, stop, started)
+	}
+	started()
+	return session.server.audioPlayer.PlayAudioWithStop(session.conn, filename, stop)
 }
 
 func (session *Session) StopTranscription() {
@@ -387,10 +1054,13 @@ func (session *Session) GetTranscriptionResults() <-chan flow.TranscriptionResul
         defer close(resultChan)
         
         for result := range session.transcriber.Results() {
+            session.observeResult(result)
             flowResult := flow.TranscriptionResult{
                 Text:      result.Text,
                 IsFinal:   result.IsFinal,
                 Timestamp: time.Now(),
+                Start:     result.Start,
+                End:       result.End,
             }
             resultChan <- flowResult
         }
@@ -399,6 +1069,11 @@ func (session *Session) GetTranscriptionResults() <-chan flow.TranscriptionResul
     return resultChan
 }
 
+// Digits delivers the caller's DTMF key presses to collect_digits nodes
+func (session *Session) Digits() <-chan byte {
+    return session.digits
+}
+
 func (session *Session) ReportStatus(status, reason string) error {
     // This will be implemented when you're ready for API calls
     log.Printf("Session %s: Status report - %s: %s", session.id, status, reason)
@@ -406,7 +1081,14 @@ func (session *Session) ReportStatus(status, reason string) error {
 }
 
 // GetVar returns a dynamic variable (later backed by Redis). Key examples: agent_user, display, lead_id, campaign_id
+// Values set during the call with SetVar take precedence over Redis fields of the same name.
 func (session *Session) GetVar(key string) (string, bool) {
+    session.varsMu.RLock()
+    v, ok := session.vars[key]
+    session.varsMu.RUnlock()
+    if ok {
+        return v, true
+    }
     // Fetch from Redis HGET <prefix+UUID> <field>
     if session.server != nil && session.server.redis != nil {
         ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
@@ -417,9 +1099,14 @@ func (session *Session) GetVar(key string) (string, bool) {
             return val, true
         }
     }
-    // Fallback to in-memory override if present
-    v, ok := session.vars[key]
-    return v, ok
+    return "", false
+}
+
+// SetVar stores a session-scoped variable in memory (e.g. values produced by flow scripts)
+func (session *Session) SetVar(key, value string) {
+    session.varsMu.Lock()
+    defer session.varsMu.Unlock()
+    session.vars[key] = value
 }
 
 func (session *Session) CheckForInterrupt(text string) (string, bool) {
@@ -458,30 +1145,54 @@ func (session *Session) EndCall() error {
 	return nil
 }
 
-func (session *Session) StopAudio() error {
-	// Signal to stop current audio playback
-	if session.stopAudioChan != nil {
-		close(session.stopAudioChan)
-		session.stopAudioChan = make(chan struct{})
-	}
-	log.Printf("Session %s: Audio stop requested", session.id)
-	return nil
-}
-
 func (session *Session) handleMessage(msg audiosocket.Message) error {
     switch msg.Kind() {
     case audiosocket.KindSlin:
         // Process audio data
         audioData := msg.Payload()
         if len(audioData) > 0 {
+            session.inLevel.Add(audioData)
+            session.monitor.addCaller(audioData, session.server.config.SampleRate)
+            session.watchBargeIn(audioData)
+            session.watchAmbient()
+            session.watchSpeechEnd()
+            if session.escalation != nil {
+                if level := session.escalation.Add(audioData); level != audio.EscalationNone {
+                    session.escalate(level)
+                }
+            }
+            if session.background != nil {
+                if env := session.background.Add(audioData); env != audio.BackgroundUnknown {
+                    session.recordBackground(env)
+                }
+            }
+            if session.machine != nil {
+                if kind := session.machine.Add(audioData); kind != audio.MachineUnknown {
+                    session.recordMachine(kind)
+                }
+            }
+            if session.tones != nil {
+                for _, tone := range session.tones.ProcessBytes(audioData) {
+                    session.recordTone(tone)
+                }
+            }
+
             // Send to transcriber
-            if err := session.transcriber.ProcessAudio(audioData); err != nil {
+            arrived := time.Now()
+            err := session.transcriber.ProcessAudio(audioData)
+            if session.debug != nil {
+                session.debug.chunk(len(audioData), arrived, time.Since(arrived), err)
+            }
+            if err != nil {
+                transcriberErrors.With(session.provider).Inc()
                 return fmt.Errorf("failed to process audio: %w", err)
             }
             
             // Buffer audio for saving if configured
             if session.server.config.SaveAudio {
-                session.audioBuffer = append(session.audioBuffer, audioData...)
+                if _, err := session.recording.Write(audioData); err != nil {
+                    log.Printf("Session %s: %v, keeping audio in memory", session.id, err)
+                }
             }
         }
 
@@ -489,8 +1200,17 @@ func (session *Session) handleMessage(msg audiosocket.Message) error {
         // Handle DTMF
         if len(msg.Payload()) > 0 {
             digit := msg.Payload()[0]
-            log.Printf("Session %s: DTMF digit: %c", session.id, digit)
-            session.transcriber.AddMarker(fmt.Sprintf("[DTMF: %c]", digit))
+            shown := digit
+            if session.flowEngine != nil && session.flowEngine.MaskingDigits() {
+                shown = '*'
+            }
+            log.Printf("Session %s: DTMF digit: %c", session.id, shown)
+            session.transcriber.AddMarker(fmt.Sprintf("[DTMF: %c]", shown))
+            select {
+            case session.digits <- digit:
+            default:
+                // Nobody is collecting; keys pressed long ago are not wanted
+            }
         }
 
     case audiosocket.KindSilence:
@@ -505,14 +1225,24 @@ func (session *Session) handleMessage(msg audiosocket.Message) error {
     return nil
 }
 
+// maskSpoken masks the numbers in a transcript while the flow collects
+// masked digits, which the caller may also speak
+func (session *Session) maskSpoken(text string) string {
+    if session.flowEngine != nil && session.flowEngine.MaskingDigits() {
+        return flow.MaskSpokenDigits(text)
+    }
+    return text
+}
+
 func (session *Session) handleTranscription() {
     for result := range session.transcriber.Results() {
+        session.observeResult(result)
         if result.Text != "" {
             timestamp := time.Now().Format("15:04:05")
-            provider := session.server.config.Provider
+            provider := session.provider
             
             if result.IsFinal {
-                log.Printf("[%s] Session %s [%s] Final: %s", provider, session.id, timestamp, result.Text)
+                log.Printf("[%s] Session %s [%s] Final: %s", provider, session.id, timestamp, session.maskSpoken(result.Text))
                 
                 // Check for interrupts only on final transcriptions
                 if session.patternMatcher != nil {
@@ -535,40 +1265,89 @@ func (session *Session) handleTranscription() {
                     }
                 }
             } else {
-                log.Printf("[%s] Session %s [%s] Partial: %s", provider, session.id, timestamp, result.Text)
+                log.Printf("[%s] Session %s [%s] Partial: %s", provider, session.id, timestamp, session.maskSpoken(result.Text))
             }
         }
     }
 }
 
+// finalize stops the session's audio and saves its transcript and
+// recording. It runs once however many exit paths call it, and a step that
+// panics is logged without skipping the steps after it.
 func (session *Session) finalize() {
-    // Stop ambient audio
-    close(session.stopAmbient)
-    
+    session.finalizeOnce.Do(func() {
+        session.cleanup("stop ambient audio", func() { close(session.stopAmbient) })
+        if session.monitor != nil {
+            session.cleanup("end live listening", session.endListening)
+        }
+        session.cleanup("log audio levels", session.logLevels)
+        session.cleanup("save transcript", session.saveTranscript)
+        session.cleanup("save audio", session.saveAudio)
+        session.cleanup("queue re-transcription", session.queueRetranscription)
+        session.cleanup("release recording", session.recording.Release)
+        // Ensure flow logger is closed
+        if session.flowEngine != nil {
+            session.cleanup("score call", session.scoreCall)
+            session.cleanup("close flow engine", session.flowEngine.Close)
+        }
+    })
+}
+
+// cleanup runs one finalize step, logging a panic instead of propagating it
+func (session *Session) cleanup(step string, fn func()) {
+    defer func() {
+        if r := recover(); r != nil {
+            log.Printf("Session %s: Panic during finalize (%s): %v\n%s", session.id, step, r, debug.Stack())
+        }
+    }()
+    fn()
+}
+
+// saveTranscript writes the transcript, with the dialogue and subtitles, if
+// transcripts are saved
+func (session *Session) saveTranscript() {
     // Pattern matcher doesn't need explicit cleanup
     // It will be garbage collected automatically
     
     // Get final transcription
     fullTranscript := session.transcriber.GetFullTranscript()
+    normalizer := session.server.config.TranscriptNormalizer
+    if normalizer != nil {
+        fullTranscript = transcriber.NormalizeTranscript(normalizer, fullTranscript)
+    }
     
     if session.server.config.SaveTranscripts && fullTranscript != "" {
         // Add metadata to transcript
-        metadata := fmt.Sprintf("Session ID: %s\nProvider: %s\nStart Time: %s\nDuration: %v\nSample Rate: %dHz\n\n---TRANSCRIPT---\n\n",
+        metadata := fmt.Sprintf("Session ID: %s\nProvider: %s\nStart Time: %s\nDuration: %v\nSample Rate: %dHz\nBuild: %s\n",
             session.id,
-            session.server.config.Provider,
+            session.provider,
             session.startTime.Format("2006-01-02 15:04:05"),
             time.Since(session.startTime),
             session.server.config.SampleRate,
+            buildinfo.Get(),
         )
+        if session.phone != "" {
+            metadata += fmt.Sprintf("Phone: %s\n", session.phone)
+        }
+        if session.leadID != "" {
+            metadata += fmt.Sprintf("Lead ID: %s\n", session.leadID)
+        }
+        metadata += "\n---TRANSCRIPT---\n\n"
         
         fullContent := metadata + fullTranscript
+
+        // Bot prompts and caller utterances with offsets, aligned with the call recording
+        prompts := session.playedPrompts()
+        if utterances := session.utterances(); len(utterances) > 0 || len(prompts) > 0 {
+            fullContent += "\n\n---CONVERSATION---\n\n" + renderDialogue(prompts, utterances)
+        }
         
         // Save transcript to file
         filename := filepath.Join(
             session.server.config.OutputDir,
             fmt.Sprintf("%s_%s_%s.txt", 
                 session.startTime.Format("20060102_150405"),
-                session.server.config.Provider,
+                session.provider,
                 session.id.String()[:8],
             ),
         )
@@ -578,31 +1357,78 @@ func (session *Session) finalize() {
         } else {
             log.Printf("Session %s: Transcript saved to %s", session.id, filename)
         }
+
+        session.saveSubtitles(strings.TrimSuffix(filename, ".txt"))
     }
-    
-    // Save raw audio if configured
-    if session.server.config.SaveAudio && len(session.audioBuffer) > 0 {
-        audioFilename := filepath.Join(
-            session.server.config.OutputDir,
-            fmt.Sprintf("%s_%s_%s.raw", 
-                session.startTime.Format("20060102_150405"),
-                session.server.config.Provider,
-                session.id.String()[:8],
-            ),
-        )
+}
+
+// saveAudio writes the caller's audio if recordings are saved
+func (session *Session) saveAudio() {
+    if session.server.config.SaveAudio && session.recording.Len() > 0 {
+        audioFilename := session.audioFilename()
         
-        if err := os.WriteFile(audioFilename, session.audioBuffer, 0644); err != nil {
+        if err := writeRecording(audioFilename, &session.recording); err != nil {
             log.Printf("Failed to save audio: %v", err)
         } else {
             log.Printf("Session %s: Audio saved to %s (%.2f seconds)", 
                 session.id, 
                 audioFilename, 
-                float64(len(session.audioBuffer))/(float64(session.server.config.SampleRate)*2))
+                float64(session.recording.Len())/(float64(session.server.config.SampleRate)*2))
         }
     }
+}
+
+// audioFilename is where saveAudio writes the caller's audio
+func (session *Session) audioFilename() string {
+    return filepath.Join(
+        session.server.config.OutputDir,
+        fmt.Sprintf("%s_%s_%s.raw",
+            session.startTime.Format("20060102_150405"),
+            session.provider,
+            session.id.String()[:8],
+        ),
+    )
+}
 
-    // Ensure flow logger is closed
-    if session.flowEngine != nil {
-        session.flowEngine.Close()
+// saveSubtitles writes the utterance timeline in each configured subtitle
+// format next to the transcript, for review alongside the call recording
+func (session *Session) saveSubtitles(basename string) {
+    utterances := session.utterances()
+    if len(utterances) == 0 {
+        return
+    }
+    for _, format := range session.server.config.SubtitleFormats {
+        write := transcriber.WriteSRT
+        if format == "vtt" {
+            write = transcriber.WriteVTT
+        }
+        filename := basename + "." + format
+        if err := writeSubtitleFile(filename, utterances, write); err != nil {
+            log.Printf("Session %s: Failed to save %s subtitles: %v", session.id, format, err)
+        } else {
+            log.Printf("Session %s: Subtitles saved to %s", session.id, filename)
+        }
+    }
+}
+
+// utterances returns the caller's utterances for saving, normalized if a
+// TranscriptNormalizer is configured
+func (session *Session) utterances() []transcriber.Utterance {
+    utterances := session.timeline.Utterances()
+    if n := session.server.config.TranscriptNormalizer; n != nil {
+        return transcriber.NormalizeUtterances(n, utterances)
+    }
+    return utterances
+}
+
+func writeSubtitleFile(filename string, utterances []transcriber.Utterance, write func(io.Writer, []transcriber.Utterance) error) error {
+    f, err := os.Create(filename)
+    if err != nil {
+        return err
+    }
+    if err := write(f, utterances); err != nil {
+        f.Close()
+        return err
     }
+    return f.Close()
 }
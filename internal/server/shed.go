@@ -0,0 +1,223 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var (
+	asrLatency   = metrics.NewHistogramVec("audiosocket_asr_latency_seconds", "Time from the caller falling silent to the final transcript of what they said", nil, "provider")
+	loadShedding = metrics.NewGauge("audiosocket_load_shedding", "1 while new calls are turned away because a provider is slow")
+	sessionsShed = metrics.NewCounterVec("audiosocket_sessions_shed_total", "Calls turned away at session start by load shedding, by the slow provider (asr or vicidial)", "reason")
+)
+
+// Load shedding defaults
+const (
+	DefaultShedWindow     = 30 * time.Second
+	DefaultShedMinSamples = 5
+	DefaultShedResume     = 0.8
+	DefaultShedStatus     = "DROP"
+)
+
+// LoadShedding turns new calls away while the services calls depend on
+// are slow, so the calls in progress keep responsive transcription and
+// dispositions. Latencies are averaged over Window. Shedding starts when an
+// average exceeds its threshold and stops once every average is below
+// Resume times its threshold, so the server does not flap around it.
+type LoadShedding struct {
+	ASRLatency      time.Duration // ASR latency that starts shedding; 0 = not watched
+	VicidialLatency time.Duration // Vicidial API latency that starts shedding; 0 = not watched
+	Window          time.Duration // 0 = DefaultShedWindow
+	MinSamples      int           // fewer samples in the window never start shedding; 0 = DefaultShedMinSamples
+	Resume          float64       // 0 = DefaultShedResume
+	Status          string        // disposition of calls turned away; empty = DefaultShedStatus
+	Prompt          string        // played to calls turned away; empty = none
+}
+
+// WithLoadShedding turns new calls away while ASR or Vicidial latency is
+// over the thresholds in settings
+func WithLoadShedding(settings LoadShedding) Option {
+	return func(c *Config) { c.LoadShedding = &settings }
+}
+
+// latencyWindow holds the latencies measured over the last window
+type latencyWindow struct {
+	window  time.Duration
+	times   []time.Time
+	samples []time.Duration
+}
+
+// add records a latency measured at now
+func (w *latencyWindow) add(now time.Time, d time.Duration) {
+	w.expire(now)
+	w.times = append(w.times, now)
+	w.samples = append(w.samples, d)
+}
+
+// average returns the mean latency within the window and how many
+// samples it holds
+func (w *latencyWindow) average(now time.Time) (time.Duration, int) {
+	w.expire(now)
+	if len(w.samples) == 0 {
+		return 0, 0
+	}
+	var sum time.Duration
+	for _, d := range w.samples {
+		sum += d
+	}
+	return sum / time.Duration(len(w.samples)), len(w.samples)
+}
+
+// expire drops samples older than the window
+func (w *latencyWindow) expire(now time.Time) {
+	i := 0
+	for i < len(w.times) && now.Sub(w.times[i]) > w.window {
+		i++
+	}
+	w.times = append(w.times[:0], w.times[i:]...)
+	w.samples = append(w.samples[:0], w.samples[i:]...)
+}
+
+// loadShedder decides whether new calls are turned away
+type loadShedder struct {
+	settings LoadShedding
+	now      func() time.Time
+
+	mu       sync.Mutex
+	asr      latencyWindow
+	vicidial latencyWindow
+	shedding string // provider being shed for, empty when accepting calls
+}
+
+func newLoadShedder(settings LoadShedding) *loadShedder {
+	if settings.Window <= 0 {
+		settings.Window = DefaultShedWindow
+	}
+	if settings.MinSamples <= 0 {
+		settings.MinSamples = DefaultShedMinSamples
+	}
+	if settings.Resume <= 0 || settings.Resume > 1 {
+		settings.Resume = DefaultShedResume
+	}
+	if settings.Status == "" {
+		settings.Status = DefaultShedStatus
+	}
+	return &loadShedder{
+		settings: settings,
+		now:      time.Now,
+		asr:      latencyWindow{window: settings.Window},
+		vicidial: latencyWindow{window: settings.Window},
+	}
+}
+
+// observeASR records the latency of a final transcript
+func (l *loadShedder) observeASR(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.asr.add(l.now(), d)
+}
+
+// observeVicidial records the latency of a Vicidial API request
+func (l *loadShedder) observeVicidial(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.vicidial.add(l.now(), d)
+}
+
+// check returns the provider new calls are shed for, or "" to accept them
+func (l *loadShedder) check() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	watched := []struct {
+		name      string
+		window    *latencyWindow
+		threshold time.Duration
+	}{
+		{"asr", &l.asr, l.settings.ASRLatency},
+		{"vicidial", &l.vicidial, l.settings.VicidialLatency},
+	}
+
+	if l.shedding == "" {
+		for _, w := range watched {
+			avg, n := w.window.average(now)
+			if w.threshold > 0 && n >= l.settings.MinSamples && avg > w.threshold {
+				l.shedding = w.name
+				loadShedding.Set(1)
+				log.Printf("Load shedding: %s latency %v over %v; turning new calls away", w.name, avg.Round(time.Millisecond), w.threshold)
+				break
+			}
+		}
+		return l.shedding
+	}
+
+	// An empty window has nothing left to say the provider is slow
+	for _, w := range watched {
+		avg, n := w.window.average(now)
+		if w.threshold > 0 && n > 0 && float64(avg) > float64(w.threshold)*l.settings.Resume {
+			return l.shedding
+		}
+	}
+	log.Printf("Load shedding: latencies back below %.0f%% of their thresholds; accepting calls", l.settings.Resume*100)
+	l.shedding = ""
+	loadShedding.Set(0)
+	return ""
+}
+
+// shedLoad ends the call if the server is shedding load and reports
+// whether it did
+func (s *Server) shedLoad(session *Session) bool {
+	if s.shedder == nil {
+		return false
+	}
+	reason := s.shedder.check()
+	if reason == "" {
+		return false
+	}
+
+	sessionsShed.With(reason).Inc()
+	log.Printf("Session %s: %s is slow; turning the call away with %s", session.id, reason, s.shedder.settings.Status)
+	if prompt := s.shedder.settings.Prompt; prompt != "" && s.audioPlayer != nil {
+		stop, done := session.playback()
+		defer done()
+		if err := s.audioPlayer.PlayAudioWithStop(session.conn, prompt, stop); err != nil {
+			log.Printf("Session %s: Failed to play %s: %v", session.id, prompt, err)
+		}
+	}
+	s.dispose(session, s.shedder.settings.Status)
+	if err := session.EndCall(); err != nil {
+		log.Printf("Session %s: %v", session.id, err)
+	}
+	return true
+}
+
+// watchSpeechEnd notes when the caller falls silent, for the ASR latency
+// of the final transcript that follows. It runs on the read loop.
+func (session *Session) watchSpeechEnd() {
+	if session.voice == nil {
+		return
+	}
+	speaking := session.voice.Speaking()
+	if session.wasSpeaking && !speaking {
+		session.speechEnd.Store(time.Now().UnixNano())
+	}
+	session.wasSpeaking = speaking
+}
+
+// observeASRLatency records the time from the caller falling silent to a
+// final transcript. A final arriving while the caller still speaks, e.g. a
+// provider cutting a long answer, has no latency to measure.
+func (session *Session) observeASRLatency() {
+	end := session.speechEnd.Swap(0)
+	if end == 0 || session.voice.Speaking() {
+		return
+	}
+	latency := time.Since(time.Unix(0, end))
+	asrLatency.With(session.provider).Observe(latency.Seconds())
+	if session.server != nil && session.server.shedder != nil {
+		session.server.shedder.observeASR(latency)
+	}
+}
@@ -0,0 +1,186 @@
+package server
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+)
+
+// DefaultStandbyMaxIdle is how long a standby transcriber waits for a call
+// before it is replaced with a fresh connection
+const DefaultStandbyMaxIdle = 60 * time.Second
+
+// standbyRetry is how long a pool waits after a failed connect
+const standbyRetry = 5 * time.Second
+
+var standbyTakes = metrics.NewCounterVec("audiosocket_standby_transcribers_total", "Sessions started on a standby transcriber (hit) or a new connection (miss), by provider", "provider", "result")
+
+// WithStandbyTranscribers keeps size transcriber connections open for each
+// WebSocket provider calls can use (vosk, assemblyai), so a new call starts
+// transcribing without waiting 300-800ms for the connect and authentication.
+// A standby connection unused for maxIdle (DefaultStandbyMaxIdle if 0) is
+// closed and replaced, since providers may drop or bill idle streams.
+func WithStandbyTranscribers(size int, maxIdle time.Duration) Option {
+	return func(c *Config) {
+		c.StandbyTranscribers = size
+		c.StandbyMaxIdle = maxIdle
+	}
+}
+
+// standbyPool keeps connected transcribers of one provider ready for calls
+type standbyPool struct {
+	provider string
+	size     int
+	maxIdle  time.Duration
+	dial     func() (transcriber.Transcriber, error)
+
+	mu     sync.Mutex
+	idle   []standbyConn // oldest first
+	closed bool
+	refill chan struct{}
+}
+
+type standbyConn struct {
+	t      transcriber.Transcriber
+	opened time.Time
+}
+
+func newStandbyPool(provider string, size int, maxIdle time.Duration, dial func() (transcriber.Transcriber, error)) *standbyPool {
+	if maxIdle <= 0 {
+		maxIdle = DefaultStandbyMaxIdle
+	}
+	return &standbyPool{provider: provider, size: size, maxIdle: maxIdle, dial: dial, refill: make(chan struct{}, 1)}
+}
+
+// take hands out the oldest standby transcriber that is still connected
+func (p *standbyPool) take() (transcriber.Transcriber, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.signal()
+	for len(p.idle) > 0 {
+		conn := p.idle[0]
+		p.idle = p.idle[1:]
+		if time.Since(conn.opened) < p.maxIdle && connected(conn.t) {
+			standbyTakes.With(p.provider, "hit").Inc()
+			return conn.t, true
+		}
+		go conn.t.Close()
+	}
+	standbyTakes.With(p.provider, "miss").Inc()
+	return nil, false
+}
+
+func (p *standbyPool) signal() {
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+// run keeps the pool full until stop is closed
+func (p *standbyPool) run(stop <-chan struct{}) {
+	// Expired connections are replaced within a quarter of maxIdle
+	ticker := time.NewTicker(p.maxIdle / 4)
+	defer ticker.Stop()
+	for {
+		var retry <-chan time.Time
+		if !p.fill() {
+			retry = time.After(standbyRetry)
+		}
+		select {
+		case <-stop:
+			return
+		case <-p.refill:
+		case <-ticker.C:
+		case <-retry:
+		}
+	}
+}
+
+// fill replaces expired or disconnected transcribers and connects new ones
+// up to size. It reports false if a connect failed.
+func (p *standbyPool) fill() bool {
+	p.mu.Lock()
+	var keep, expired []standbyConn
+	for _, conn := range p.idle {
+		if time.Since(conn.opened) < p.maxIdle && connected(conn.t) {
+			keep = append(keep, conn)
+		} else {
+			expired = append(expired, conn)
+		}
+	}
+	p.idle = keep
+	missing := p.size - len(keep)
+	p.mu.Unlock()
+	for _, conn := range expired {
+		conn.t.Close()
+	}
+
+	for ; missing > 0; missing-- {
+		t, err := p.dial()
+		if err != nil {
+			log.Printf("Standby %s transcriber: %v", p.provider, err)
+			return false
+		}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			t.Close()
+			return true
+		}
+		p.idle = append(p.idle, standbyConn{t: t, opened: time.Now()})
+		p.mu.Unlock()
+	}
+	return true
+}
+
+// close disconnects the standby transcribers
+func (p *standbyPool) close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	for _, conn := range idle {
+		conn.t.Close()
+	}
+}
+
+// connected reports whether t's provider connection is still open: an idle
+// transcriber has no results, so a closed results channel means the
+// provider hung up
+func connected(t transcriber.Transcriber) bool {
+	select {
+	case _, ok := <-t.Results():
+		return ok
+	default:
+		return true
+	}
+}
+
+// startStandby opens the standby pools of the providers calls can use
+func (s *Server) startStandby() {
+	if s.config.StandbyTranscribers <= 0 || s.config.TranscriberFactory != nil {
+		return
+	}
+	providers := []string{s.config.Provider}
+	for _, rule := range s.config.ProviderRules {
+		providers = append(providers, rule.Provider)
+	}
+	s.standby = make(map[string]*standbyPool)
+	for _, provider := range providers {
+		if s.standby[provider] != nil || (provider != "vosk" && provider != "assemblyai") {
+			continue
+		}
+		provider := provider
+		pool := newStandbyPool(provider, s.config.StandbyTranscribers, s.config.StandbyMaxIdle, func() (transcriber.Transcriber, error) {
+			return s.dialTranscriber(provider)
+		})
+		s.standby[provider] = pool
+		go pool.run(s.shutdown)
+		log.Printf("Keeping %d standby %s transcribers connected", s.config.StandbyTranscribers, provider)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/dsp"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var (
+	tonesDetected   = metrics.NewCounterVec("audiosocket_tones_detected_total", "Signaling tones heard on the caller side", "tone")
+	sitDispositions = metrics.NewCounter("audiosocket_sit_dispositions_total", "Calls ended as disconnected numbers after an early SIT tone")
+)
+
+// Defaults for WithSITDisposition
+const (
+	DefaultSITWindow = 10 * time.Second
+	DefaultSITStatus = "ADC"
+)
+
+// WithToneDetection listens on the caller side for answering machine
+// beeps, fax tones and special information tones. Each sets the "tone"
+// session variable, and a waiting question follows its "tone:beep",
+// "tone:fax" or "tone:sit" transition, if it has one.
+func WithToneDetection() Option {
+	return func(c *Config) { c.ToneDetection = true }
+}
+
+// WithSITDisposition turns on tone detection and ends calls that hear a
+// special information tone within window of the start (DefaultSITWindow if
+// 0), dispositioning the lead with status (DefaultSITStatus if empty, e.g.
+// "NA" instead) rather than running the flow against a disconnected number.
+// Later SIT tones go to the flow as usual.
+func WithSITDisposition(window time.Duration, status string) Option {
+	return func(c *Config) {
+		c.ToneDetection = true
+		c.SITDisposition = true
+		c.SITWindow = window
+		c.SITStatus = status
+	}
+}
+
+// Tones delivers detected tones to the flow engine
+func (session *Session) Tones() <-chan string {
+	return session.toneEvents
+}
+
+// recordTone records a tone and signals the flow
+func (session *Session) recordTone(tone dsp.Tone) {
+	log.Printf("Session %s: Tone detected: %s", session.id, tone)
+	tonesDetected.With(string(tone)).Inc()
+	session.SetVar("tone", string(tone))
+	session.transcriber.AddMarker(fmt.Sprintf("[TONE: %s]", tone))
+	if engine := session.engine(); engine != nil {
+		if logger := engine.GetSessionLogger(); logger != nil {
+			logger.LogTone(session.id.String(), string(tone))
+		}
+	}
+	if tone == dsp.ToneSIT && session.server.hangUpDisconnected(session) {
+		return
+	}
+	select {
+	case session.toneEvents <- string(tone):
+	default:
+		// The flow has not taken the earlier tones yet; repeats (fax
+		// tones every few seconds) add nothing
+	}
+}
+
+// hangUpDisconnected dispositions and ends a call that heard a SIT tone
+// early enough to be a disconnected number. It reports false when SIT
+// disposition is off or the window has passed.
+func (s *Server) hangUpDisconnected(session *Session) bool {
+	window := s.config.SITWindow
+	if window == 0 {
+		window = DefaultSITWindow
+	}
+	elapsed := time.Since(session.startTime)
+	if !s.config.SITDisposition || elapsed > window {
+		return false
+	}
+	status := s.config.SITStatus
+	if status == "" {
+		status = DefaultSITStatus
+	}
+	sitDispositions.Inc()
+	log.Printf("Session %s: SIT tone %v into the call, number disconnected; hanging up with %s",
+		session.id, elapsed.Round(time.Millisecond), status)
+
+	go func() {
+		s.dispose(session, status)
+		if err := session.EndCall(); err != nil {
+			log.Printf("Session %s: %v", session.id, err)
+		}
+	}()
+	return true
+}
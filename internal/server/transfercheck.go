@@ -0,0 +1,121 @@
+package server
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+// Outcomes of a transferred call, as logged
+const (
+	TransferAnswered = "ANSWERED" // an agent took the call
+	TransferAbandon  = "ABANDON"  // the call was dropped before an agent answered
+	TransferUnknown  = "UNKNOWN"  // Vicidial could not be asked
+)
+
+// DefaultTransferCheckTimeout is how long a transferred call is watched for
+// an agent to answer
+const DefaultTransferCheckTimeout = 2 * time.Minute
+
+// DefaultAbandonStatuses are the Vicidial lead statuses of a call dropped
+// from the transfer queue
+var DefaultAbandonStatuses = []string{"DROP", "XDROP", "AFTHRS", "NANQUE", "HOLDTO", "WAITTO"}
+
+var transferOutcomes = metrics.NewCounterVec("audiosocket_transfer_outcomes_total", "Transferred calls by outcome: answered, abandon or unknown", "outcome")
+
+// WithTransferTracking watches each transferred call after the bot lets go
+// of it, asking Vicidial for the lead's status every interval until an
+// agent answers (INCALL or an agent's disposition), the call is dropped (one
+// of abandonStatuses, DefaultAbandonStatuses if none) or timeout passes
+// (DefaultTransferCheckTimeout if 0), which counts as abandoned. The outcome
+// is logged, appended to the session log and counted.
+func WithTransferTracking(interval, timeout time.Duration, abandonStatuses ...string) Option {
+	return func(c *Config) {
+		c.TransferCheckInterval = interval
+		c.TransferCheckTimeout = timeout
+		c.AbandonStatuses = abandonStatuses
+	}
+}
+
+// trackTransfer starts watching a transferred call in the background
+func (s *Server) trackTransfer(session *Session) {
+	if s.config.TransferCheckInterval <= 0 || s.config.Vicidial.ServerURL == "" {
+		return
+	}
+	leadID := session.leadID
+	if leadID == "" {
+		leadID, _ = session.GetVar("lead_id")
+	}
+	if leadID == "" {
+		log.Printf("Session %s: No lead_id, not tracking the transfer", session.id)
+		return
+	}
+	go s.watchTransfer(session, leadID)
+}
+
+// watchTransfer polls the lead's status until the transfer's outcome is
+// known and returns it
+func (s *Server) watchTransfer(session *Session, leadID string) string {
+	timeout := s.config.TransferCheckTimeout
+	if timeout <= 0 {
+		timeout = DefaultTransferCheckTimeout
+	}
+	apiClient := s.newVicidialClient()
+	ticker := time.NewTicker(s.config.TransferCheckInterval)
+	defer ticker.Stop()
+
+	started := time.Now()
+	outcome, status := "", ""
+	for outcome == "" {
+		select {
+		case <-s.shutdown:
+			return ""
+		case <-ticker.C:
+		}
+		current, err := apiClient.GetLeadStatus(leadID)
+		if err != nil {
+			log.Printf("Session %s: Failed to look up lead %s status: %v", session.id, leadID, err)
+		} else {
+			status = current
+			outcome = s.transferOutcome(current)
+		}
+		if outcome == "" && time.Since(started) >= timeout {
+			outcome = TransferAbandon
+			if status == "" {
+				outcome = TransferUnknown
+			}
+		}
+	}
+
+	waited := time.Since(started)
+	log.Printf("Session %s: Transfer of lead %s %s after %v (lead status %q)", session.id, leadID, outcome, waited.Round(time.Second), status)
+	transferOutcomes.With(strings.ToLower(outcome)).Inc()
+	if session.flowEngine != nil {
+		// The flow closed its log when it handed the call over
+		if logger := session.flowEngine.GetSessionLogger(); logger != nil && logger.Reopen() == nil {
+			logger.LogTransferOutcome(session.id.String(), outcome, status, waited)
+			logger.Close()
+		}
+	}
+	return outcome
+}
+
+// transferOutcome tells the outcome from the lead's status, "" while the
+// call is still waiting for an agent
+func (s *Server) transferOutcome(status string) string {
+	abandon := s.config.AbandonStatuses
+	if len(abandon) == 0 {
+		abandon = DefaultAbandonStatuses
+	}
+	for _, st := range abandon {
+		if status == st {
+			return TransferAbandon
+		}
+	}
+	if status == "" || status == "QUEUE" || status == s.config.Vicidial.TransferStatus {
+		return ""
+	}
+	return TransferAnswered
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/tts"
+)
+
+var ttsPrompts = metrics.NewCounterVec("audiosocket_tts_prompts_total", "Prompts synthesized from a node's tts_text, by result: ok or error", "result")
+
+// WithTTS synthesizes the tts_text of flow nodes with synth, usually a
+// tts.Cache, and plays it like a recorded prompt. Prompts are synthesized
+// when the server starts, when a flow is staged and, for flows edited in
+// place, before a call's flow starts; each is kept in memory afterwards.
+func WithTTS(synth tts.Synthesizer) Option {
+	return func(c *Config) {
+		c.TTS = synth
+	}
+}
+
+// synthesizeFlow synthesizes the tts_text prompts of the flow at flowPath
+// and the flows it chains to
+func (s *Server) synthesizeFlow(flowPath string) error {
+	texts, err := flow.TTSTexts(flowPath)
+	if err != nil {
+		return err
+	}
+	return s.synthesize(texts)
+}
+
+// synthesizeNodes synthesizes the tts_text prompts of nodes
+func (s *Server) synthesizeNodes(nodes []flow.FlowNode) error {
+	texts := make(map[string]string)
+	for _, node := range nodes {
+		if node.TTSText != "" {
+			texts[node.AudioFile] = node.TTSText
+		}
+	}
+	return s.synthesize(texts)
+}
+
+// synthesize adds the speech of texts, by prompt name, that the player does
+// not have yet
+func (s *Server) synthesize(texts map[string]string) error {
+	if s.config.TTS == nil || s.audioPlayer == nil {
+		return nil
+	}
+	var failed []string
+	for name, text := range texts {
+		if _, ok := s.audioPlayer.GetAudio(name); ok {
+			continue
+		}
+		pcm, err := s.config.TTS.Synthesize(text)
+		if err != nil {
+			ttsPrompts.With("error").Inc()
+			failed = append(failed, fmt.Sprintf("%q: %v", text, err))
+			continue
+		}
+		ttsPrompts.With("ok").Inc()
+		s.audioPlayer.AddAudio(name, pcm)
+		log.Printf("Synthesized audio file: %s (%d bytes) %q", name, len(pcm), text)
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("failed to synthesize %d prompts: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// checkSynthesized reports why a tts_text prompt cannot play, or nil
+func (s *Server) checkSynthesized(name string) error {
+	if s.config.TTS == nil {
+		return fmt.Errorf("tts_text needs a tts provider")
+	}
+	if s.audioPlayer != nil {
+		if _, ok := s.audioPlayer.GetAudio(name); !ok {
+			return fmt.Errorf("tts_text was not synthesized")
+		}
+	}
+	return nil
+}
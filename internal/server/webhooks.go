@@ -0,0 +1,37 @@
+package server
+
+import (
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/notify"
+)
+
+// WithWebhookOutbox enables the flow's webhook notify actions, which post
+// templated JSON to automation endpoints (Zapier, Make, n8n, ...). Webhooks
+// are queued in dir and delivered at least once, surviving restarts; an
+// undelivered webhook is retried for maxAge (notify.DefaultOutboxMaxAge if
+// 0) and then moved to dir/failed.
+func WithWebhookOutbox(dir string, maxAge time.Duration) Option {
+	return func(c *Config) {
+		c.WebhookOutboxDir = dir
+		c.WebhookMaxAge = maxAge
+	}
+}
+
+// openWebhookOutbox opens the configured outbox and registers it as the
+// webhook notifier of every session
+func (s *Server) openWebhookOutbox() error {
+	if s.config.WebhookOutboxDir == "" {
+		return nil
+	}
+	outbox, err := notify.NewOutbox(s.config.WebhookOutboxDir, s.config.WebhookMaxAge)
+	if err != nil {
+		return err
+	}
+	s.webhooks = outbox
+	if s.config.Notifiers == nil {
+		s.config.Notifiers = make(map[string]notify.Sender)
+	}
+	s.config.Notifiers[notify.Webhook] = outbox
+	return nil
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"log"
+	"net"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+var (
+	connectionsAccepted = metrics.NewCounter("audiosocket_connections_accepted_total", "AudioSocket connections accepted")
+	connectionsRejected = metrics.NewCounter("audiosocket_connections_rejected_total", "AudioSocket connections refused because every worker was busy and the accept queue was full")
+	acceptQueueDepth    = metrics.NewGauge("audiosocket_accept_queue_depth", "Accepted connections waiting for a free worker")
+	workersBusy         = metrics.NewGauge("audiosocket_workers_busy", "Workers currently handling a connection")
+)
+
+// WithWorkerPool handles connections with a fixed number of workers instead
+// of one goroutine per connection, so a connection flood (e.g. a
+// misconfigured dialer) cannot exhaust memory. Up to queue accepted
+// connections wait for a free worker (workers if queue is 0); beyond that new
+// connections are closed right away and Asterisk falls through the dialplan.
+// With several listeners (WithListeners) workers and queue are split evenly
+// between them.
+func WithWorkerPool(workers, queue int) Option {
+	return func(c *Config) {
+		c.Workers = workers
+		c.AcceptQueue = queue
+	}
+}
+
+// acceptShard is the share of the worker pool serving one listener
+type acceptShard struct {
+	id    int
+	queue chan net.Conn // accepted connections awaiting a worker; nil without a pool
+}
+
+// newShard creates the accept shard id of n and starts its workers if a
+// worker pool is configured
+func (s *Server) newShard(id, n int) *acceptShard {
+	sh := &acceptShard{id: id}
+	if s.config.Workers <= 0 {
+		return sh
+	}
+	queue := s.config.AcceptQueue
+	if queue <= 0 {
+		queue = s.config.Workers
+	}
+	workers := (s.config.Workers + n - 1) / n
+	queue = (queue + n - 1) / n
+
+	sh.queue = make(chan net.Conn, queue)
+	for i := 0; i < workers; i++ {
+		go s.worker(sh)
+	}
+	log.Printf("Listener %d: handling connections with %d workers, accept queue %d", id, workers, queue)
+	return sh
+}
+
+// dispatch hands an accepted connection to a worker of sh, or to its own
+// goroutine when no pool is configured
+func (s *Server) dispatch(sh *acceptShard, conn net.Conn) {
+	connectionsAccepted.Inc()
+	if sh.queue == nil {
+		s.wg.Add(1)
+		go s.handleConnection(conn)
+		return
+	}
+
+	// Queued connections count towards wg so Stop waits for them to be
+	// handled or closed
+	s.wg.Add(1)
+	select {
+	case sh.queue <- conn:
+		acceptQueueDepth.Inc()
+	default:
+		connectionsRejected.Inc()
+		log.Printf("Listener %d: Rejecting connection from %s: all workers busy and accept queue full", sh.id, conn.RemoteAddr())
+		conn.Close()
+		s.wg.Done()
+	}
+}
+
+// worker handles connections queued on sh until shutdown, then closes
+// whatever is still waiting in the queue
+func (s *Server) worker(sh *acceptShard) {
+	for {
+		select {
+		case conn := <-sh.queue:
+			acceptQueueDepth.Dec()
+			select {
+			case <-s.shutdown:
+				// Shutting down; do not start new sessions
+				conn.Close()
+				s.wg.Done()
+				continue
+			default:
+			}
+			workersBusy.Inc()
+			s.handleConnection(conn)
+			workersBusy.Dec()
+		case <-s.shutdown:
+			for {
+				select {
+				case conn := <-sh.queue:
+					acceptQueueDepth.Dec()
+					conn.Close()
+					s.wg.Done()
+				default:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package spoken reads numbers said in words, as speech recognizers
+// transcribe them: "forty two", "five hundred two", "two thousand and
+// five". The flow engine uses it to collect digits and the transcriber to
+// write numbers as digits, so both read a number the same way.
+package spoken
+
+var (
+	units = map[string]int{
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4,
+		"five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+	}
+	teens = map[string]int{
+		"ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14,
+		"fifteen": 15, "sixteen": 16, "seventeen": 17, "eighteen": 18, "nineteen": 19,
+	}
+	tens = map[string]int{
+		"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50,
+		"sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	}
+	scales = map[string]int{"thousand": 1000, "million": 1000000}
+)
+
+// Digit returns the value of a single digit word, "zero" to "nine"
+func Digit(word string) (int, bool) {
+	v, ok := units[word]
+	return v, ok
+}
+
+// Kind classifies a lowercase number word as "unit", "teen" or "ten" and
+// returns its value; it is "" for any other word, including "hundred" and
+// the scales, which only continue a number
+func Kind(word string) (string, int) {
+	if v, ok := units[word]; ok {
+		return "unit", v
+	}
+	if v, ok := teens[word]; ok {
+		return "teen", v
+	}
+	if v, ok := tens[word]; ok {
+		return "ten", v
+	}
+	return "", 0
+}
+
+// IsNumberWord reports whether word can start a spoken number
+func IsNumberWord(word string) bool {
+	kind, _ := Kind(word)
+	return kind != ""
+}
+
+// Number accumulates one spoken cardinal number, word by word. Its zero
+// value is an empty number.
+type Number struct {
+	total, current int
+	last           string // kind of the last word: unit, teen, ten, hundred or scale
+	words          int
+}
+
+// Add takes the next lowercase word, reporting false if it does not
+// continue the number, e.g. a second unit after "five"
+func (n *Number) Add(word string) bool {
+	switch {
+	case word == "hundred":
+		if n.last != "unit" && n.last != "teen" && n.last != "ten" {
+			return false
+		}
+		n.current *= 100
+		n.last = "hundred"
+	case scales[word] > 0:
+		if n.words == 0 || n.last == "scale" {
+			return false
+		}
+		n.total += n.current * scales[word]
+		n.current = 0
+		n.last = "scale"
+	default:
+		kind, v := Kind(word)
+		switch {
+		case kind == "":
+			return false
+		case n.last == "ten" && kind == "unit" && v > 0:
+		case n.words > 0 && n.last != "hundred" && n.last != "scale":
+			return false
+		}
+		n.current += v
+		n.last = kind
+	}
+	n.words++
+	return true
+}
+
+// Value returns the number read so far
+func (n *Number) Value() int { return n.total + n.current }
+
+// Words returns how many words the number took
+func (n *Number) Words() int { return n.words }
+
+// Parse reads the number at the start of words, which are lowercase and
+// bare of punctuation. It takes "and" after a hundred or a scale ("one
+// hundred and five") and leaves a unit that starts a run of single digits,
+// so "eight hundred five five five" is 800 followed by 555. It returns the
+// number and the count of words it used, 0 if words does not start with one.
+func Parse(words []string) (Number, int) {
+	var n Number
+	used := 0
+	for j := 0; j < len(words); j++ {
+		word := words[j]
+		afterScale := n.last == "hundred" || n.last == "scale"
+		if word == "and" && afterScale && j+1 < len(words) {
+			j++
+			word = words[j]
+		}
+		if _, ok := units[word]; ok && afterScale && j+1 < len(words) {
+			if _, ok := units[words[j+1]]; ok {
+				break
+			}
+		}
+		if !n.Add(word) {
+			break
+		}
+		used = j + 1
+	}
+	return n, used
+}
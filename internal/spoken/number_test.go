@@ -0,0 +1,36 @@
+package spoken
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		text  string
+		want  int
+		words int
+	}{
+		{"forty two", 42, 2},
+		{"five hundred two", 502, 3},
+		{"five hundred twelve", 512, 3},
+		{"eight hundred five", 805, 3},
+		{"eight hundred five five five", 800, 2},
+		{"one hundred and five", 105, 4},
+		{"one hundred and", 100, 2},
+		{"one and five", 1, 1},
+		{"two thousand twenty", 2020, 3},
+		{"one thousand two hundred", 1200, 4},
+		{"twenty twenty", 20, 1},
+		{"five five", 5, 1},
+		{"nineteen eighty", 19, 1},
+		{"hundred", 0, 0},
+		{"thousand", 0, 0},
+	}
+	for _, tt := range tests {
+		n, used := Parse(strings.Fields(tt.text))
+		if n.Value() != tt.want || used != tt.words {
+			t.Errorf("Parse(%q) = %d in %d words, want %d in %d", tt.text, n.Value(), used, tt.want, tt.words)
+		}
+	}
+}
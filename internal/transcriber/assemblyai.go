@@ -1,7 +1,6 @@
 package transcriber
 
 import (
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -10,6 +9,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/bufpool"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/dsp"
 	"github.com/gorilla/websocket"
 )
 
@@ -22,17 +23,19 @@ const (
 
 type AssemblyAITranscriber struct {
 	conn        *websocket.Conn
-	results     chan TranscriptionResult
+	results     *resultQueue
 	fullText    strings.Builder
 	mu          sync.Mutex
 	sampleRate  int
 	apiKey      string
 	sessionID   string
-	audioBuffer []byte
-	bufferMu    sync.Mutex
+	chunker     *audioChunker
+	resampler   *dsp.Resampler // input rate -> 16kHz, nil if already 16kHz
+	sendMu      sync.Mutex     // serializes WebSocket writes
 	sendTicker  *time.Ticker
 	stopSending chan struct{}
 	wg          sync.WaitGroup
+	rawObservers             // debug capture of WebSocket frames
 }
 
 // AssemblyAI message types
@@ -66,14 +69,19 @@ func NewAssemblyAITranscriber(apiKey string, sampleRate int) (*AssemblyAITranscr
 	}
 
 	at := &AssemblyAITranscriber{
-		conn:        conn,
-		results:     make(chan TranscriptionResult, 100),
-		sampleRate:  sampleRate,
-		apiKey:      apiKey,
-		audioBuffer: make([]byte, 0, 8000), // Buffer for ~100ms at 16kHz
+		conn:       conn,
+		results:    newResultQueue("assemblyai", resultQueueSize),
+		sampleRate: sampleRate,
+		apiKey:     apiKey,
+		// AssemblyAI requires chunks between 50ms and 1000ms; stay under with 950ms
+		chunker:     newAudioChunker(targetSampleRate, MinChunkDurationMs*time.Millisecond, (MaxChunkDurationMs-50)*time.Millisecond),
 		stopSending: make(chan struct{}),
 	}
 
+	if sampleRate != targetSampleRate {
+		at.resampler = dsp.NewResampler(sampleRate, targetSampleRate)
+	}
+
 	// Start result handler
 	go at.handleResults()
 
@@ -107,104 +115,54 @@ func (at *AssemblyAITranscriber) audioSender() {
 }
 
 func (at *AssemblyAITranscriber) sendBufferedAudio() {
-	at.bufferMu.Lock()
-	defer at.bufferMu.Unlock()
-
-	// Calculate chunk size limits based on AssemblyAI requirements
-	// At 16kHz, 16-bit audio (2 bytes per sample):
-	// Min 50ms = 0.05 * 16000 * 2 = 1600 bytes
-	// Max 950ms = 0.95 * 16000 * 2 = 30400 bytes (staying under 1000ms limit)
-	const minChunkSize = 1600
-	const maxChunkSize = 30400
-	
-	// Only send if we have at least the minimum chunk size
-	// This prevents sending chunks that are too small
-	if len(at.audioBuffer) < minChunkSize {
-		return
-	}
-	
+	at.sendMu.Lock()
+	defer at.sendMu.Unlock()
+
 	// Send audio in chunks that respect AssemblyAI's duration limits
-	for len(at.audioBuffer) >= minChunkSize {
-		chunkSize := len(at.audioBuffer)
-		if chunkSize > maxChunkSize {
-			chunkSize = maxChunkSize
-		}
-		
-		// Extract chunk to send
-		chunk := at.audioBuffer[:chunkSize]
-		
-		// Send the chunk
-		if err := at.conn.WriteMessage(websocket.BinaryMessage, chunk); err != nil {
+	for chunk := at.chunker.Next(); chunk != nil; chunk = at.chunker.Next() {
+		at.observe(FrameSend, websocket.BinaryMessage, chunk)
+		err := at.conn.WriteMessage(websocket.BinaryMessage, chunk)
+		at.chunker.Release(chunk)
+		if err != nil {
 			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 				log.Printf("Failed to send audio to AssemblyAI: %v", err)
 			}
 			// Clear buffer on error to avoid infinite loop
-			at.audioBuffer = at.audioBuffer[:0]
+			at.chunker.Reset()
 			return
 		}
-		
-		// Remove sent chunk from buffer
-		at.audioBuffer = at.audioBuffer[chunkSize:]
 	}
 }
 
 func (at *AssemblyAITranscriber) ProcessAudio(audioData []byte) error {
-	at.bufferMu.Lock()
-	defer at.bufferMu.Unlock()
-
-	// If input is 8kHz, we need to resample to 16kHz for AssemblyAI
-	processedData := audioData
-	if at.sampleRate == 8000 {
-		processedData = at.resample8to16(audioData)
+	// AssemblyAI expects 16kHz; resample anything else with the polyphase filter
+	if at.resampler == nil {
+		at.chunker.Write(audioData)
+		return nil
 	}
+	pcm := at.resampler.ProcessBytes(bufpool.GetBytes(at.resampler.OutputSize(len(audioData)/2)*2), audioData)
 
-	// Add to buffer
-	at.audioBuffer = append(at.audioBuffer, processedData...)
+	// The chunker copies, so the buffer can go straight back to the pool
+	at.chunker.Write(pcm)
+	bufpool.PutBytes(pcm)
 
 	return nil
 }
 
-// Simple upsampling from 8kHz to 16kHz (linear interpolation)
-func (at *AssemblyAITranscriber) resample8to16(input []byte) []byte {
-	// Convert bytes to int16 samples
-	samples := make([]int16, len(input)/2)
-	for i := 0; i < len(samples); i++ {
-		samples[i] = int16(binary.LittleEndian.Uint16(input[i*2 : i*2+2]))
-	}
-
-	// Upsample by factor of 2 (8kHz -> 16kHz)
-	upsampled := make([]int16, len(samples)*2)
-	for i := 0; i < len(samples)-1; i++ {
-		upsampled[i*2] = samples[i]
-		// Linear interpolation for the sample in between
-		upsampled[i*2+1] = (samples[i] + samples[i+1]) / 2
-	}
-	// Handle last sample
-	if len(samples) > 0 {
-		upsampled[len(upsampled)-2] = samples[len(samples)-1]
-		upsampled[len(upsampled)-1] = samples[len(samples)-1]
-	}
-
-	// Convert back to bytes
-	output := make([]byte, len(upsampled)*2)
-	for i, sample := range upsampled {
-		binary.LittleEndian.PutUint16(output[i*2:i*2+2], uint16(sample))
-	}
-
-	return output
-}
-
 func (at *AssemblyAITranscriber) handleResults() {
 	for {
 		_, message, err := at.conn.ReadMessage()
 		if err != nil {
+			at.observeClose(err)
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("AssemblyAI WebSocket error: %v", err)
 			}
-			close(at.results)
+			at.results.close()
 			return
 		}
 
+		at.observe(FrameRecv, websocket.TextMessage, message)
+
 		var msg AssemblyAIMessage
 		if err := json.Unmarshal(message, &msg); err != nil {
 			log.Printf("Failed to parse AssemblyAI message: %v", err)
@@ -228,16 +186,16 @@ func (at *AssemblyAITranscriber) handleResults() {
 					at.fullText.WriteString(msg.Transcript)
 					at.mu.Unlock()
 
-					at.results <- TranscriptionResult{
+					at.results.push(TranscriptionResult{
 						Text:    msg.Transcript,
 						IsFinal: true,
-					}
+					})
 				} else {
 					// This is a partial transcript
-					at.results <- TranscriptionResult{
+					at.results.push(TranscriptionResult{
 						Text:    msg.Transcript,
 						IsFinal: false,
-					}
+					})
 				}
 			}
 
@@ -249,7 +207,7 @@ func (at *AssemblyAITranscriber) handleResults() {
 }
 
 func (at *AssemblyAITranscriber) Results() <-chan TranscriptionResult {
-	return at.results
+	return at.results.Results()
 }
 
 func (at *AssemblyAITranscriber) GetFullTranscript() string {
@@ -274,13 +232,14 @@ func (at *AssemblyAITranscriber) Close() error {
 	at.wg.Wait()
 
 	// Send any remaining audio in buffer (even if less than minimum)
-	at.bufferMu.Lock()
-	if len(at.audioBuffer) > 0 {
+	at.sendMu.Lock()
+	if chunk := at.chunker.Flush(); chunk != nil {
 		// Try to send remaining audio, but don't fail close if it errors
-		_ = at.conn.WriteMessage(websocket.BinaryMessage, at.audioBuffer)
-		at.audioBuffer = at.audioBuffer[:0]
+		at.observe(FrameSend, websocket.BinaryMessage, chunk)
+		_ = at.conn.WriteMessage(websocket.BinaryMessage, chunk)
+		at.chunker.Release(chunk)
 	}
-	at.bufferMu.Unlock()
+	at.sendMu.Unlock()
 
 	// Send termination message to AssemblyAI
 	terminateMsg := AssemblyAIMessage{
@@ -289,6 +248,7 @@ func (at *AssemblyAITranscriber) Close() error {
 
 	msgBytes, err := json.Marshal(terminateMsg)
 	if err == nil {
+		at.observe(FrameSend, websocket.TextMessage, msgBytes)
 		at.conn.WriteMessage(websocket.TextMessage, msgBytes)
 		// Give AssemblyAI time to process termination
 		time.Sleep(500 * time.Millisecond)
@@ -0,0 +1,199 @@
+package transcriber
+
+import (
+	"sync"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/bufpool"
+)
+
+// audioChunker buffers 16-bit PCM and releases it in chunks whose duration
+// stays within a provider's limits. Streaming providers reject (or handle
+// poorly) chunks that are too short or too long, e.g. AssemblyAI requires
+// 50ms-1000ms per message.
+//
+// Audio is kept in a pooled ring buffer and chunks are handed out as views
+// into it rather than copies. A chunk stays valid, and its bytes are not
+// overwritten by later writes, until it is passed to Release. Only a chunk
+// that wraps around the end of the ring is copied into a pooled scratch
+// buffer.
+type audioChunker struct {
+	mu       sync.Mutex
+	ring     []byte
+	start    int // ring index of the oldest unread byte
+	size     int // unread bytes
+	inflight int // bytes handed out by Next/Flush and not yet released
+	minBytes int
+	maxBytes int
+
+	scratch [][]byte // wrapped chunks handed out, returned to the pool on Release
+	retired [][]byte // rings replaced while chunks still pointed into them
+}
+
+// newAudioChunker creates a chunker for mono 16-bit PCM at sampleRate whose
+// chunks are between minDur and maxDur long
+func newAudioChunker(sampleRate int, minDur, maxDur time.Duration) *audioChunker {
+	minBytes := pcmBytes(sampleRate, minDur)
+	maxBytes := pcmBytes(sampleRate, maxDur)
+	if minBytes < 2 {
+		minBytes = 2
+	}
+	if maxBytes < minBytes {
+		maxBytes = minBytes
+	}
+	return &audioChunker{
+		minBytes: minBytes,
+		maxBytes: maxBytes,
+	}
+}
+
+// pcmBytes returns the byte length of d of mono 16-bit PCM, sample aligned
+func pcmBytes(sampleRate int, d time.Duration) int {
+	samples := int(int64(sampleRate) * int64(d) / int64(time.Second))
+	return samples * 2
+}
+
+// Direct reports whether p can be sent as one chunk as is, bypassing the
+// buffer: nothing is buffered and p is within the chunk limits. This lets a
+// provider that accepts every 20ms frame forward it without any copy.
+func (c *audioChunker) Direct(p []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size == 0 && len(p) >= c.minBytes && len(p) <= c.maxBytes && len(p)%2 == 0
+}
+
+// Write appends audio to the buffer
+func (c *audioChunker) Write(p []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(p) > len(c.ring)-c.size-c.inflight {
+		c.grow(c.size + c.inflight + len(p))
+	}
+	end := (c.start + c.size) % len(c.ring)
+	n := copy(c.ring[end:], p)
+	copy(c.ring, p[n:])
+	c.size += len(p)
+}
+
+// grow moves the unread audio to a ring of at least need bytes; mu must be held
+func (c *audioChunker) grow(need int) {
+	size := 2 * len(c.ring)
+	if size < c.maxBytes*2 {
+		size = c.maxBytes * 2
+	}
+	for size < need {
+		size *= 2
+	}
+
+	ring := bufpool.GetBytes(size)
+	ring = ring[:cap(ring)]
+	c.read(ring, c.size)
+
+	if c.ring != nil {
+		if c.inflight > 0 {
+			// Chunks still point into the old ring
+			c.retired = append(c.retired, c.ring)
+		} else {
+			bufpool.PutBytes(c.ring)
+		}
+	}
+	c.ring = ring
+	c.start = 0
+}
+
+// read copies the first n unread bytes to dst without consuming them; mu must be held
+func (c *audioChunker) read(dst []byte, n int) {
+	if n == 0 {
+		return
+	}
+	m := copy(dst[:n], c.ring[c.start:])
+	copy(dst[m:n], c.ring)
+}
+
+// Next returns the next chunk of at least the minimum and at most the maximum
+// duration, or nil if not enough audio is buffered yet
+func (c *audioChunker) Next() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size < c.minBytes {
+		return nil
+	}
+	n := c.size
+	if n > c.maxBytes {
+		n = c.maxBytes
+	}
+	// Never split a sample across chunks
+	n &^= 1
+	return c.take(n)
+}
+
+// Flush returns all buffered audio regardless of the minimum duration, or nil
+// if the buffer is empty
+func (c *audioChunker) Flush() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size == 0 {
+		return nil
+	}
+	return c.take(c.size)
+}
+
+// Reset drops all buffered audio; chunks already handed out stay valid
+func (c *audioChunker) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.start = (c.start + c.size) % max(len(c.ring), 1)
+	c.size = 0
+}
+
+// Len returns the number of buffered bytes
+func (c *audioChunker) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Release marks a chunk from Next or Flush as sent; the chunk must not be
+// used afterwards. Chunks are released in the order they were taken.
+func (c *audioChunker) Release(chunk []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inflight -= len(chunk)
+	if c.inflight < 0 {
+		c.inflight = 0
+	}
+	for i, s := range c.scratch {
+		if len(chunk) > 0 && &s[0] == &chunk[0] {
+			bufpool.PutBytes(s)
+			c.scratch = append(c.scratch[:i], c.scratch[i+1:]...)
+			break
+		}
+	}
+	if c.inflight == 0 {
+		for _, r := range c.retired {
+			bufpool.PutBytes(r)
+		}
+		c.retired = c.retired[:0]
+	}
+}
+
+// take consumes the first n unread bytes and returns them as a view into the
+// ring, or as a pooled copy if they wrap around its end; mu must be held
+func (c *audioChunker) take(n int) []byte {
+	var chunk []byte
+	if c.start+n <= len(c.ring) {
+		chunk = c.ring[c.start : c.start+n : c.start+n]
+	} else {
+		chunk = bufpool.GetBytes(n)[:n]
+		c.read(chunk, n)
+		c.scratch = append(c.scratch, chunk)
+	}
+	c.start = (c.start + n) % len(c.ring)
+	c.size -= n
+	c.inflight += n
+	return chunk
+}
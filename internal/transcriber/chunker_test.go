@@ -0,0 +1,229 @@
+package transcriber
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAudioChunkerBoundaries(t *testing.T) {
+	// 16kHz: 50ms = 1600 bytes, 950ms = 30400 bytes (AssemblyAI limits)
+	c := newAudioChunker(16000, 50*time.Millisecond, 950*time.Millisecond)
+	if c.minBytes != 1600 || c.maxBytes != 30400 {
+		t.Fatalf("Unexpected limits: min=%d max=%d", c.minBytes, c.maxBytes)
+	}
+
+	// Below the minimum nothing is released
+	c.Write(make([]byte, 1598))
+	if chunk := c.Next(); chunk != nil {
+		t.Errorf("Expected no chunk below minimum, got %d bytes", len(chunk))
+	}
+
+	// Exactly the minimum is released as one chunk
+	c.Write(make([]byte, 2))
+	if chunk := c.Next(); len(chunk) != 1600 {
+		t.Errorf("Expected 1600 byte chunk, got %d", len(chunk))
+	}
+	if c.Len() != 0 {
+		t.Errorf("Expected empty buffer, got %d bytes", c.Len())
+	}
+
+	// Above the maximum is split into max-sized chunks plus a remainder
+	c.Write(make([]byte, 30400*2+2000))
+	sizes := []int{}
+	for chunk := c.Next(); chunk != nil; chunk = c.Next() {
+		sizes = append(sizes, len(chunk))
+	}
+	if len(sizes) != 3 || sizes[0] != 30400 || sizes[1] != 30400 || sizes[2] != 2000 {
+		t.Errorf("Unexpected chunk sizes: %v", sizes)
+	}
+
+	// Flush returns audio shorter than the minimum
+	c.Write(make([]byte, 100))
+	if chunk := c.Flush(); len(chunk) != 100 {
+		t.Errorf("Expected flush of 100 bytes, got %d", len(chunk))
+	}
+	if chunk := c.Flush(); chunk != nil {
+		t.Errorf("Expected nil flush on empty buffer, got %d bytes", len(chunk))
+	}
+}
+
+func TestAudioChunkerSampleAlignment(t *testing.T) {
+	// Odd maximum must not split a 16-bit sample
+	c := &audioChunker{minBytes: 2, maxBytes: 5}
+	c.Write([]byte{1, 2, 3, 4, 5, 6, 7})
+	if chunk := c.Next(); len(chunk) != 4 {
+		t.Errorf("Expected 4 byte aligned chunk, got %d", len(chunk))
+	}
+	if chunk := c.Next(); len(chunk) != 2 || chunk[0] != 5 {
+		t.Errorf("Expected next chunk to start at byte 5, got %v", chunk)
+	}
+}
+
+func TestAudioChunkerCopiesData(t *testing.T) {
+	c := newAudioChunker(8000, 20*time.Millisecond, 100*time.Millisecond)
+	frame := make([]byte, 320)
+	frame[0] = 42
+	c.Write(frame)
+	frame[0] = 0
+
+	chunk := c.Next()
+	c.Write(make([]byte, 320))
+	if chunk[0] != 42 {
+		t.Error("Chunk must not alias the caller's buffer or be overwritten by later writes")
+	}
+}
+
+// pattern fills p with a running byte counter starting at seq
+func pattern(p []byte, seq int) int {
+	for i := range p {
+		p[i] = byte(seq % 251)
+		seq++
+	}
+	return seq
+}
+
+// checkPattern verifies that chunk continues the running counter at seq
+func checkPattern(t *testing.T, chunk []byte, seq int) int {
+	t.Helper()
+	for i, b := range chunk {
+		if b != byte(seq%251) {
+			t.Fatalf("Byte %d of chunk: got %d, want %d", i, b, seq%251)
+		}
+		seq++
+	}
+	return seq
+}
+
+func TestAudioChunkerRingWrap(t *testing.T) {
+	// Odd frame sizes against even chunk limits make chunks wrap around
+	// the end of the ring at varying offsets
+	c := newAudioChunker(8000, 20*time.Millisecond, 30*time.Millisecond)
+	var written, read int
+	frame := make([]byte, 333)
+	for i := 0; i < 500; i++ {
+		written = pattern(frame, written)
+		c.Write(frame)
+		for chunk := c.Next(); chunk != nil; chunk = c.Next() {
+			read = checkPattern(t, chunk, read)
+			c.Release(chunk)
+		}
+	}
+	if chunk := c.Flush(); chunk != nil {
+		read = checkPattern(t, chunk, read)
+		c.Release(chunk)
+	}
+	if read != written {
+		t.Errorf("Read %d bytes, wrote %d", read, written)
+	}
+	if len(c.ring) > 4*c.maxBytes {
+		t.Errorf("Ring grew to %d bytes for %d byte chunks", len(c.ring), c.maxBytes)
+	}
+	if c.inflight != 0 || len(c.scratch) != 0 || len(c.retired) != 0 {
+		t.Errorf("Leaked chunks: inflight=%d scratch=%d retired=%d", c.inflight, len(c.scratch), len(c.retired))
+	}
+}
+
+func TestAudioChunkerConcurrent(t *testing.T) {
+	// ProcessAudio writes while the AssemblyAI sender drains; chunks in
+	// flight must not be overwritten by the writer (run with -race)
+	c := newAudioChunker(16000, 50*time.Millisecond, 100*time.Millisecond)
+	const frames = 2000
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		seq := 0
+		frame := make([]byte, 640)
+		for i := 0; i < frames; i++ {
+			seq = pattern(frame, seq)
+			c.Write(frame)
+		}
+	}()
+
+	read := 0
+	drain := func() {
+		for chunk := c.Next(); chunk != nil; chunk = c.Next() {
+			// Hold the chunk a little so the writer runs meanwhile
+			for i := 0; i < 100; i++ {
+				_ = chunk[i%len(chunk)]
+			}
+			read = checkPattern(t, chunk, read)
+			c.Release(chunk)
+		}
+	}
+	for {
+		select {
+		case <-done:
+			drain()
+			if chunk := c.Flush(); chunk != nil {
+				read = checkPattern(t, chunk, read)
+				c.Release(chunk)
+			}
+			if read != frames*640 {
+				t.Errorf("Read %d bytes, want %d", read, frames*640)
+			}
+			return
+		default:
+			drain()
+		}
+	}
+}
+
+func TestAudioChunkerConcurrentSenders(t *testing.T) {
+	// Several goroutines taking chunks under a shared lock, as Vosk and
+	// AssemblyAI serialize sends, must see every byte exactly once
+	c := newAudioChunker(8000, 20*time.Millisecond, 60*time.Millisecond)
+	var sendMu sync.Mutex
+	var wg sync.WaitGroup
+	var sent []byte
+	written := 0
+	frame := make([]byte, 320)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				sendMu.Lock()
+				written = pattern(frame, written)
+				c.Write(frame)
+				for chunk := c.Next(); chunk != nil; chunk = c.Next() {
+					sent = append(sent, chunk...)
+					c.Release(chunk)
+				}
+				sendMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if read := checkPattern(t, sent, 0); read != written {
+		t.Errorf("Read %d bytes, wrote %d", read, written)
+	}
+}
+
+func TestAudioChunkerDirect(t *testing.T) {
+	c := newAudioChunker(8000, 20*time.Millisecond, 250*time.Millisecond)
+	if !c.Direct(make([]byte, 320)) {
+		t.Error("A 20ms frame should bypass an empty buffer")
+	}
+	if c.Direct(make([]byte, 160)) {
+		t.Error("A frame below the minimum must be buffered")
+	}
+	c.Write(make([]byte, 160))
+	if c.Direct(make([]byte, 320)) {
+		t.Error("A frame must not bypass buffered audio")
+	}
+}
+
+func BenchmarkAudioChunker(b *testing.B) {
+	// Vosk settings: every 20ms frame is forwarded as one chunk
+	c := newAudioChunker(8000, 20*time.Millisecond, 250*time.Millisecond)
+	frame := make([]byte, 320)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c.Write(frame)
+		for chunk := c.Next(); chunk != nil; chunk = c.Next() {
+			c.Release(chunk)
+		}
+	}
+}
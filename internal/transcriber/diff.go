@@ -0,0 +1,121 @@
+package transcriber
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TranscriptDiff compares the live transcript of a call with a reference
+// transcript of the same audio, word by word
+type TranscriptDiff struct {
+	Words         int // words in the reference
+	Substitutions int
+	Deletions     int    // reference words missing from the live transcript
+	Insertions    int    // live words not in the reference
+	Text          string // the reference, with "[-live-]{+reference+}" where they differ
+}
+
+// WER is the live transcript's word error rate against the reference
+func (d TranscriptDiff) WER() float64 {
+	if d.Words == 0 {
+		if d.Insertions == 0 {
+			return 0
+		}
+		return 1
+	}
+	return float64(d.Substitutions+d.Deletions+d.Insertions) / float64(d.Words)
+}
+
+// Edit operations of the alignment
+const (
+	opMatch byte = iota
+	opSubstitute
+	opDelete // a reference word the live transcript missed
+	opInsert // a live word the reference does not have
+)
+
+// DiffTranscripts aligns live with reference by minimum word edit distance.
+// Case, punctuation and markers such as "[TONE: beep]" are ignored.
+func DiffTranscripts(live, reference string) TranscriptDiff {
+	a, b := diffWords(live), diffWords(reference)
+
+	// ops[i][j] is the last edit of the best alignment of a[:i] with b[:j];
+	// only two rows of distances are kept
+	ops := make([][]byte, len(a)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range ops {
+		ops[i] = make([]byte, len(b)+1)
+		for j := range ops[i] {
+			switch {
+			case i == 0 && j == 0:
+				cur[j] = 0
+			case i == 0:
+				cur[j], ops[i][j] = j, opDelete
+			case j == 0:
+				cur[j], ops[i][j] = i, opInsert
+			case a[i-1] == b[j-1]:
+				cur[j], ops[i][j] = prev[j-1], opMatch
+			default:
+				cur[j], ops[i][j] = prev[j-1]+1, opSubstitute
+				if prev[j]+1 < cur[j] {
+					cur[j], ops[i][j] = prev[j]+1, opInsert
+				}
+				if cur[j-1]+1 < cur[j] {
+					cur[j], ops[i][j] = cur[j-1]+1, opDelete
+				}
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	d := TranscriptDiff{Words: len(b)}
+	var out []string
+	for i, j := len(a), len(b); i > 0 || j > 0; {
+		switch ops[i][j] {
+		case opMatch:
+			out = append(out, b[j-1])
+			i, j = i-1, j-1
+		case opSubstitute:
+			d.Substitutions++
+			out = append(out, "[-"+a[i-1]+"-]{+"+b[j-1]+"+}")
+			i, j = i-1, j-1
+		case opDelete:
+			d.Deletions++
+			out = append(out, "{+"+b[j-1]+"+}")
+			j--
+		case opInsert:
+			d.Insertions++
+			out = append(out, "[-"+a[i-1]+"-]")
+			i--
+		}
+	}
+	for l, r := 0, len(out)-1; l < r; l, r = l+1, r-1 {
+		out[l], out[r] = out[r], out[l]
+	}
+	d.Text = strings.Join(out, " ")
+	return d
+}
+
+// diffWords splits text into lowercase words without punctuation, skipping
+// bracketed markers
+func diffWords(text string) []string {
+	var words []string
+	marker := false
+	for _, field := range strings.Fields(text) {
+		if strings.HasPrefix(field, "[") {
+			marker = true
+		}
+		if marker {
+			marker = !strings.HasSuffix(field, "]")
+			continue
+		}
+		word := strings.ToLower(strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		}))
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
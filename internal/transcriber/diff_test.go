@@ -0,0 +1,30 @@
+package transcriber
+
+import "testing"
+
+func TestDiffTranscripts(t *testing.T) {
+	d := DiffTranscripts("[TONE: beep] Yes I half medicare.", "yes, I have Medicare part b")
+	if d.Words != 6 || d.Substitutions != 1 || d.Deletions != 2 || d.Insertions != 0 {
+		t.Errorf("diff = %+v", d)
+	}
+	if want := "yes i [-half-]{+have+} medicare {+part+} {+b+}"; d.Text != want {
+		t.Errorf("text = %q, want %q", d.Text, want)
+	}
+	if wer := d.WER(); wer != 0.5 {
+		t.Errorf("WER = %.2f, want 0.50", wer)
+	}
+
+	d = DiffTranscripts("um no thanks", "no thanks")
+	if d.Insertions != 1 || d.Text != "[-um-] no thanks" {
+		t.Errorf("inserted word: %+v", d)
+	}
+	if d := DiffTranscripts("Same words.", "same words"); d.WER() != 0 || d.Text != "same words" {
+		t.Errorf("identical transcripts: %+v", d)
+	}
+	if d := DiffTranscripts("hello", ""); d.WER() != 1 {
+		t.Errorf("WER against an empty reference = %.2f, want 1", d.WER())
+	}
+	if d := DiffTranscripts("", ""); d.WER() != 0 || d.Text != "" {
+		t.Errorf("empty transcripts: %+v", d)
+	}
+}
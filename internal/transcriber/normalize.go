@@ -0,0 +1,182 @@
+package transcriber
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/spoken"
+)
+
+// Normalizer rewrites transcript text for reading, e.g. restoring the
+// casing and punctuation Vosk leaves out. Normalize is given one utterance,
+// or the speech between two markers of a full transcript. Only saved
+// transcripts and subtitles are normalized; the text the flow classifies is
+// never changed.
+type Normalizer interface {
+	Normalize(text string) string
+}
+
+// NormalizerFunc adapts a function to the Normalizer interface
+type NormalizerFunc func(text string) string
+
+// Normalize calls f(text)
+func (f NormalizerFunc) Normalize(text string) string { return f(text) }
+
+// TextNormalizer is the built-in Normalizer. Each step leaves text alone
+// that a provider already formatted, so it is safe on AssemblyAI output.
+type TextNormalizer struct {
+	Casing      bool // capitalize sentences and "I" in all-lowercase text
+	Punctuation bool // end an utterance without punctuation with "." or "?"
+	Numbers     bool // write spoken numbers as digits: "twenty five" -> "25"
+}
+
+// Normalize applies the enabled steps: numbers, then punctuation, then casing
+func (n TextNormalizer) Normalize(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return text
+	}
+	lower := strings.ToLower(text) == text
+	if n.Numbers {
+		text = formatNumbers(text)
+	}
+	if n.Punctuation && !strings.ContainsAny(text[len(text)-1:], ".?!") {
+		if questionWords[strings.ToLower(strings.Fields(text)[0])] {
+			text += "?"
+		} else {
+			text += "."
+		}
+	}
+	if n.Casing && lower {
+		text = sentenceCase(text)
+	}
+	return text
+}
+
+// NormalizeTranscript normalizes a full transcript in which markers such as
+// "[SILENCE]" separate the caller's speech, keeping the markers as they are
+func NormalizeTranscript(n Normalizer, transcript string) string {
+	var b strings.Builder
+	for transcript != "" {
+		open := strings.Index(transcript, "[")
+		end := -1
+		if open >= 0 {
+			end = strings.Index(transcript[open:], "]")
+		}
+		if end < 0 {
+			appendSpaced(&b, n.Normalize(transcript))
+			break
+		}
+		appendSpaced(&b, n.Normalize(transcript[:open]))
+		appendSpaced(&b, transcript[open:open+end+1])
+		transcript = transcript[open+end+1:]
+	}
+	return b.String()
+}
+
+// NormalizeUtterances returns utterances with their text normalized
+func NormalizeUtterances(n Normalizer, utterances []Utterance) []Utterance {
+	out := make([]Utterance, len(utterances))
+	for i, u := range utterances {
+		u.Text = n.Normalize(u.Text)
+		out[i] = u
+	}
+	return out
+}
+
+func appendSpaced(b *strings.Builder, s string) {
+	if s = strings.TrimSpace(s); s == "" {
+		return
+	}
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	b.WriteString(s)
+}
+
+// questionWords start an utterance that is punctuated as a question
+var questionWords = map[string]bool{
+	"what": true, "where": true, "when": true, "why": true, "who": true, "how": true, "which": true,
+	"is": true, "are": true, "do": true, "does": true, "did": true, "can": true, "could": true,
+	"will": true, "would": true, "should": true, "may": true,
+}
+
+// sentenceCase capitalizes the first letter of each sentence and the
+// pronoun "I" with its contractions
+func sentenceCase(text string) string {
+	words := strings.Split(text, " ")
+	start := true
+	for i, w := range words {
+		if w == "" {
+			continue
+		}
+		bare := strings.TrimRight(w, ".,?!;:")
+		if bare == "i" || strings.HasPrefix(bare, "i'") {
+			w = "I" + w[1:]
+		}
+		if start {
+			r := []rune(w)
+			r[0] = unicode.ToUpper(r[0])
+			w = string(r)
+		}
+		words[i] = w
+		start = strings.ContainsAny(w[len(w)-1:], ".?!")
+	}
+	return strings.Join(words, " ")
+}
+
+// formatNumbers writes spoken numbers as digits. Two or more single digits
+// in a row are read digit by digit, like a phone number ("five five five"
+// -> "555"); a lone digit word ("one question") stays a word.
+func formatNumbers(text string) string {
+	words := strings.Fields(text)
+	out := make([]string, 0, len(words))
+	for i := 0; i < len(words); {
+		// Digit by digit
+		j, digits, trail := i, "", ""
+		for j < len(words) {
+			b, t := splitTrailing(words[j])
+			v, ok := spoken.Digit(strings.ToLower(b))
+			if !ok {
+				break
+			}
+			digits += strconv.Itoa(v)
+			trail = t
+			j++
+			if t != "" {
+				break
+			}
+		}
+		if j-i >= 2 {
+			out = append(out, digits+trail)
+			i = j
+			continue
+		}
+
+		// A cardinal number, up to the end of the phrase
+		var bare []string
+		for _, w := range words[i:] {
+			b, t := splitTrailing(w)
+			bare = append(bare, strings.ToLower(b))
+			if t != "" {
+				break
+			}
+		}
+		if p, n := spoken.Parse(bare); n > 0 && (p.Words() > 1 || p.Value() >= 10) {
+			_, trail := splitTrailing(words[i+n-1])
+			out = append(out, strconv.Itoa(p.Value())+trail)
+			i += n
+			continue
+		}
+		out = append(out, words[i])
+		i++
+	}
+	return strings.Join(out, " ")
+}
+
+// splitTrailing splits punctuation such as "," or "." off the end of word
+func splitTrailing(word string) (string, string) {
+	bare := strings.TrimRight(word, ".,?!;:")
+	return bare, word[len(bare):]
+}
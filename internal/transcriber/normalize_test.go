@@ -0,0 +1,48 @@
+package transcriber
+
+import "testing"
+
+func TestTextNormalizer(t *testing.T) {
+	n := TextNormalizer{Casing: true, Punctuation: true, Numbers: true}
+	for in, want := range map[string]string{
+		"yes i am interested":                         "Yes I am interested.",
+		"what is this about":                          "What is this about?",
+		"my number is five five five one two one two": "My number is 5551212.",
+		"i'm twenty five":                             "I'm 25.",
+		"about one hundred and twenty thousand":       "About 120000.",
+		"i have one question":                         "I have one question.",
+		"it was nineteen ninety nine":                 "It was 19 99.",
+		"":                                            "",
+		// Provider formatting is kept
+		"Yes, I am. Call me at 555-1212!": "Yes, I am. Call me at 555-1212!",
+	} {
+		if got := n.Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	// Steps are independent
+	if got := (TextNormalizer{Numbers: true}).Normalize("twenty two"); got != "22" {
+		t.Errorf("numbers only = %q", got)
+	}
+	if got := (TextNormalizer{Casing: true}).Normalize("yes. i will"); got != "Yes. I will" {
+		t.Errorf("casing only = %q", got)
+	}
+}
+
+func TestNormalizeTranscript(t *testing.T) {
+	n := TextNormalizer{Casing: true, Punctuation: true}
+	got := NormalizeTranscript(n, "who is this [SILENCE] no thanks [DTMF: 1]")
+	if want := "Who is this? [SILENCE] No thanks. [DTMF: 1]"; got != want {
+		t.Errorf("NormalizeTranscript = %q, want %q", got, want)
+	}
+
+	utterances := []Utterance{{Text: "yes", Start: 1, End: 2}, {Text: "call me at nine", Start: 3, End: 4}}
+	normalized := NormalizeUtterances(n, utterances)
+	if normalized[0].Text != "Yes." || normalized[1].Text != "Call me at nine." || normalized[1].Start != 3 {
+		t.Errorf("NormalizeUtterances = %+v", normalized)
+	}
+	if utterances[0].Text != "yes" {
+		t.Error("NormalizeUtterances changed its input")
+	}
+}
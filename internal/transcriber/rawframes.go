@@ -0,0 +1,150 @@
+package transcriber
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Directions of a RawFrame
+const (
+	FrameRecv = "recv"
+	FrameSend = "send"
+)
+
+// MaxRawFrameData caps the payload kept per captured text frame
+const MaxRawFrameData = 4096
+
+// RawFrame is a WebSocket frame exchanged with a streaming provider, for
+// diagnosing provider-side issues such as malformed turns or unexpected
+// termination. Binary audio frames carry only their Size. A "close" frame
+// reports why the connection ended in Data.
+type RawFrame struct {
+	Direction string // FrameRecv or FrameSend
+	Type      string // "text", "binary" or "close"
+	Size      int
+	Data      []byte // sanitized text payload
+}
+
+// RawObserver is implemented by providers that can report their raw
+// WebSocket frames
+type RawObserver interface {
+	OnRawFrame(fn func(RawFrame))
+}
+
+// OnRawFrame registers fn with t, or with the provider t wraps, and reports
+// whether the provider supports raw frame capture
+func OnRawFrame(t Transcriber, fn func(RawFrame)) bool {
+	for t != nil {
+		if ro, ok := t.(RawObserver); ok {
+			ro.OnRawFrame(fn)
+			return true
+		}
+		w, ok := t.(interface{ Unwrap() Transcriber })
+		if !ok {
+			return false
+		}
+		t = w.Unwrap()
+	}
+	return false
+}
+
+// rawObservers is embedded by WebSocket providers to implement RawObserver
+type rawObservers struct {
+	observersMu sync.Mutex
+	observers   []func(RawFrame)
+}
+
+// OnRawFrame registers a callback receiving every frame
+func (o *rawObservers) OnRawFrame(fn func(RawFrame)) {
+	o.observersMu.Lock()
+	defer o.observersMu.Unlock()
+	o.observers = append(o.observers, fn)
+}
+
+func (o *rawObservers) notify(frame RawFrame) {
+	o.observersMu.Lock()
+	observers := o.observers
+	o.observersMu.Unlock()
+	for _, fn := range observers {
+		fn(frame)
+	}
+}
+
+// observe reports a sent or received frame; payloads are only copied and
+// sanitized when somebody is listening
+func (o *rawObservers) observe(direction string, messageType int, data []byte) {
+	o.observersMu.Lock()
+	listening := len(o.observers) > 0
+	o.observersMu.Unlock()
+	if !listening {
+		return
+	}
+
+	frame := RawFrame{Direction: direction, Type: "binary", Size: len(data)}
+	if messageType == websocket.TextMessage {
+		frame.Type = "text"
+		frame.Data = SanitizeFrame(data)
+	}
+	o.notify(frame)
+}
+
+// observeClose reports the error that ended the read loop
+func (o *rawObservers) observeClose(err error) {
+	o.notify(RawFrame{Direction: FrameRecv, Type: "close", Data: []byte(err.Error())})
+}
+
+// SanitizeFrame redacts credentials from a JSON frame (any field whose name
+// mentions a token, key, secret, password or authorization) and truncates it
+// to MaxRawFrameData. Non-JSON payloads are only truncated.
+func SanitizeFrame(data []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err == nil {
+		if redact(v) {
+			if out, err := json.Marshal(v); err == nil {
+				data = out
+			}
+		}
+	}
+	if len(data) > MaxRawFrameData {
+		data = append(data[:MaxRawFrameData:MaxRawFrameData], "…"...)
+	}
+	return append([]byte(nil), data...)
+}
+
+// redact replaces sensitive values in place and reports whether it changed v
+func redact(v interface{}) bool {
+	changed := false
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, val := range t {
+			if sensitiveField(k) {
+				t[k] = "[REDACTED]"
+				changed = true
+				continue
+			}
+			if redact(val) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, val := range t {
+			if redact(val) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func sensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"token", "key", "secret", "password", "authorization"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package transcriber
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestSanitizeFrame(t *testing.T) {
+	got := string(SanitizeFrame([]byte(`{"type":"Begin","token":"abc","nested":{"api_key":"xyz","id":"1"}}`)))
+	if strings.Contains(got, "abc") || strings.Contains(got, "xyz") || !strings.Contains(got, `"id":"1"`) {
+		t.Errorf("credentials not redacted: %s", got)
+	}
+
+	plain := `{"partial" : "hello"}`
+	if got := string(SanitizeFrame([]byte(plain))); got != plain {
+		t.Errorf("frame without credentials changed: %s", got)
+	}
+
+	long := strings.Repeat("x", MaxRawFrameData+100)
+	if got := SanitizeFrame([]byte(long)); len(got) != MaxRawFrameData+len("…") {
+		t.Errorf("truncated length = %d", len(got))
+	}
+}
+
+func TestVoskRawFrames(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	vosk := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			kind, _, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if kind == websocket.TextMessage {
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"text": "hello"}`))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "done"))
+				return
+			}
+		}
+	}))
+	defer vosk.Close()
+
+	vt, err := NewVoskTranscriber("ws"+strings.TrimPrefix(vosk.URL, "http"), 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var frames []RawFrame
+	if !OnRawFrame(NewResamplingTranscriber(vt, 16000, 8000), func(f RawFrame) {
+		mu.Lock()
+		frames = append(frames, f)
+		mu.Unlock()
+	}) {
+		t.Fatal("Vosk should support raw frame capture through the resampler")
+	}
+
+	vt.ProcessAudio(make([]byte, 320))
+	finishAudio(vt)
+	for range vt.Results() {
+	}
+	vt.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	var kinds []string
+	for _, f := range frames {
+		kinds = append(kinds, f.Direction+":"+f.Type)
+	}
+	got := strings.Join(kinds, " ")
+	if !strings.HasPrefix(got, "send:binary send:text recv:text recv:close") {
+		t.Errorf("frames = %s", got)
+	}
+	if frames[0].Size != 320 || frames[0].Data != nil {
+		t.Errorf("audio frame = %+v, want size only", frames[0])
+	}
+	if string(frames[1].Data) != `{"eof": 1}` {
+		t.Errorf("EOF data = %s", frames[1].Data)
+	}
+	if string(frames[2].Data) != `{"text": "hello"}` {
+		t.Errorf("recv data = %s", frames[2].Data)
+	}
+}
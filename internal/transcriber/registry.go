@@ -0,0 +1,112 @@
+package transcriber
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Factory creates a transcriber for one call. sampleRate is the rate of the
+// 16-bit mono audio passed to ProcessAudio; config is the provider's section
+// of the server configuration, nil when it has none.
+type Factory func(sampleRate int, config map[string]any) (Transcriber, error)
+
+// BuiltinProviders are implemented by the server itself, which shares Vosk
+// connections and models between calls; they cannot be registered
+var BuiltinProviders = []string{"vosk", "vosk_local", "assemblyai"}
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+)
+
+// Register makes a provider available by name, usually from the init
+// function of the package implementing it. Calls select it like a built-in
+// provider: as the default, in provider rules or through the provider
+// variable. Register panics if name is empty or already taken, like
+// database/sql.Register.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if name == "" || factory == nil {
+		panic("transcriber: Register needs a name and a factory")
+	}
+	if IsBuiltin(name) {
+		panic("transcriber: " + name + " is a built-in provider")
+	}
+	if _, dup := factories[name]; dup {
+		panic("transcriber: Register called twice for provider " + name)
+	}
+	factories[name] = factory
+}
+
+// Lookup returns the factory registered as name
+func Lookup(name string) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := factories[name]
+	return factory, ok
+}
+
+// IsBuiltin reports whether name is one of BuiltinProviders
+func IsBuiltin(name string) bool {
+	for _, b := range BuiltinProviders {
+		if b == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Providers returns the built-in providers followed by the registered ones
+// in name order
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append(append([]string(nil), BuiltinProviders...), names...)
+}
+
+// ConfigString reads a string setting from a provider config; numbers and
+// booleans are formatted, a missing key is ""
+func ConfigString(config map[string]any, key string) string {
+	switch v := config[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ConfigInt reads an integer setting from a provider config, def when the key
+// is missing
+func ConfigInt(config map[string]any, key string, def int) (int, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return def, nil
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("%s: %v is not a whole number", key, v)
+		}
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %q is not a number", key, v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("%s: %v is not a number", key, v)
+	}
+}
@@ -0,0 +1,73 @@
+package transcriber
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	var gotRate int
+	var gotConfig map[string]any
+	Register("test-echo", func(sampleRate int, config map[string]any) (Transcriber, error) {
+		gotRate, gotConfig = sampleRate, config
+		return &captureTranscriber{}, nil
+	})
+	defer func() {
+		registryMu.Lock()
+		delete(factories, "test-echo")
+		registryMu.Unlock()
+	}()
+
+	factory, ok := Lookup("test-echo")
+	if !ok {
+		t.Fatal("registered provider not found")
+	}
+	config := map[string]any{"api_key": "k"}
+	if _, err := factory(16000, config); err != nil {
+		t.Fatal(err)
+	}
+	if gotRate != 16000 || !reflect.DeepEqual(gotConfig, config) {
+		t.Errorf("factory got %d, %v", gotRate, gotConfig)
+	}
+	if _, ok := Lookup("vosk"); ok {
+		t.Error("built-in providers have no registered factory")
+	}
+	want := append(append([]string(nil), BuiltinProviders...), "test-echo", "whisper_local")
+	if got := Providers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Providers() = %v, want %v", got, want)
+	}
+
+	for _, name := range []string{"test-echo", "vosk", ""} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) should panic", name)
+				}
+			}()
+			Register(name, factory)
+		}()
+	}
+}
+
+func TestProviderConfig(t *testing.T) {
+	config := map[string]any{"url": "wss://x", "rate": 8000, "ratio": 1.5, "port": "9000", "tls": true}
+	if got := ConfigString(config, "url"); got != "wss://x" {
+		t.Errorf("url = %q", got)
+	}
+	if got := ConfigString(config, "tls"); got != "true" {
+		t.Errorf("tls = %q", got)
+	}
+	if got := ConfigString(config, "missing"); got != "" {
+		t.Errorf("missing = %q", got)
+	}
+	for key, want := range map[string]int{"rate": 8000, "port": 9000, "missing": 7} {
+		if got, err := ConfigInt(config, key, 7); err != nil || got != want {
+			t.Errorf("%s = %d, %v, want %d", key, got, err, want)
+		}
+	}
+	for _, key := range []string{"ratio", "url", "tls"} {
+		if _, err := ConfigInt(config, key, 0); err == nil {
+			t.Errorf("%s should not read as an int", key)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package transcriber
+
+import (
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/bufpool"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/dsp"
+)
+
+// resamplingTranscriber converts session audio to the rate the wrapped
+// provider expects, e.g. 16kHz wideband AudioSocket audio for an 8kHz Vosk model
+type resamplingTranscriber struct {
+	Transcriber
+	resampler *dsp.Resampler
+}
+
+// NewResamplingTranscriber wraps t so audio arriving at inRate is resampled to
+// outRate before it reaches the provider. t is returned unchanged when the
+// rates already match.
+func NewResamplingTranscriber(t Transcriber, inRate, outRate int) Transcriber {
+	if inRate == outRate || inRate <= 0 || outRate <= 0 {
+		return t
+	}
+	return &resamplingTranscriber{
+		Transcriber: t,
+		resampler:   dsp.NewResampler(inRate, outRate),
+	}
+}
+
+// Unwrap returns the wrapped provider
+func (rt *resamplingTranscriber) Unwrap() Transcriber { return rt.Transcriber }
+
+// ProcessAudio resamples audioData and forwards it to the provider. The
+// output buffer is pooled; providers must not retain the audio they are
+// passed beyond ProcessAudio.
+func (rt *resamplingTranscriber) ProcessAudio(audioData []byte) error {
+	pcm := rt.resampler.ProcessBytes(bufpool.GetBytes(rt.resampler.OutputSize(len(audioData)/2)*2), audioData)
+	defer bufpool.PutBytes(pcm)
+	if len(pcm) == 0 {
+		return nil
+	}
+	return rt.Transcriber.ProcessAudio(pcm)
+}
@@ -0,0 +1,47 @@
+package transcriber
+
+import (
+	"testing"
+)
+
+type captureTranscriber struct {
+	received int
+}
+
+func (c *captureTranscriber) ProcessAudio(audioData []byte) error {
+	c.received += len(audioData)
+	return nil
+}
+func (c *captureTranscriber) Results() <-chan TranscriptionResult { return nil }
+func (c *captureTranscriber) GetFullTranscript() string           { return "" }
+func (c *captureTranscriber) AddMarker(marker string)             {}
+func (c *captureTranscriber) Close() error                        { return nil }
+
+func TestResamplingTranscriber(t *testing.T) {
+	inner := &captureTranscriber{}
+	if got := NewResamplingTranscriber(inner, 8000, 8000); got != Transcriber(inner) {
+		t.Fatal("matching rates should not wrap the transcriber")
+	}
+
+	rt := NewResamplingTranscriber(inner, 16000, 8000)
+	frame := make([]byte, 640) // 20ms at 16kHz
+	for i := 0; i < 50; i++ {
+		if err := rt.ProcessAudio(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One second of 16kHz input becomes ~one second of 8kHz output
+	if inner.received < 15800 || inner.received > 16000 {
+		t.Errorf("received %d bytes, want ~16000", inner.received)
+	}
+}
+
+func BenchmarkResamplingTranscriber(b *testing.B) {
+	rt := NewResamplingTranscriber(&captureTranscriber{}, 16000, 8000)
+	frame := make([]byte, 640) // 20ms at 16kHz
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rt.ProcessAudio(frame)
+	}
+}
@@ -0,0 +1,118 @@
+package transcriber
+
+import (
+	"sync"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
+)
+
+// resultQueueSize is how many results a transcriber holds for a consumer
+// that has fallen behind
+const resultQueueSize = 100
+
+var droppedResults = metrics.NewCounterVec("audiosocket_transcriber_dropped_results_total", "Partial transcripts dropped because the consumer fell behind, by provider", "provider")
+
+// resultQueue delivers a transcriber's results without ever blocking the
+// goroutine producing them, typically a provider's WebSocket reader. It
+// holds up to size results; when full, the oldest partial is dropped to
+// make room, since a later partial of the same utterance supersedes it.
+// Finals are never dropped: with only finals queued a new final is kept
+// beyond size and a new partial is dropped instead.
+type resultQueue struct {
+	provider string
+	size     int
+	out      chan TranscriptionResult
+
+	mu      sync.Mutex
+	pending []TranscriptionResult
+	closed  bool
+	wake    chan struct{}
+}
+
+// newResultQueue starts a queue of up to size results for provider
+func newResultQueue(provider string, size int) *resultQueue {
+	q := &resultQueue{
+		provider: provider,
+		size:     size,
+		out:      make(chan TranscriptionResult),
+		wake:     make(chan struct{}, 1),
+	}
+	go q.run()
+	return q
+}
+
+// Results returns the channel results are delivered on; it is closed after
+// close once every queued result has been delivered
+func (q *resultQueue) Results() <-chan TranscriptionResult {
+	return q.out
+}
+
+// push queues r, dropping a partial if the queue is full
+func (q *resultQueue) push(r TranscriptionResult) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	if len(q.pending) >= q.size {
+		if i := q.oldestPartial(); i >= 0 {
+			q.pending = append(q.pending[:i], q.pending[i+1:]...)
+			droppedResults.With(q.provider).Inc()
+		} else if !r.IsFinal {
+			q.mu.Unlock()
+			droppedResults.With(q.provider).Inc()
+			return
+		}
+	}
+	q.pending = append(q.pending, r)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// close delivers the queued results and then closes the results channel;
+// later pushes are ignored
+func (q *resultQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+// oldestPartial returns the index of the first queued partial, -1 if there
+// is none; mu must be held
+func (q *resultQueue) oldestPartial() int {
+	for i, r := range q.pending {
+		if !r.IsFinal {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *resultQueue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run hands queued results to the consumer in order
+func (q *resultQueue) run() {
+	defer close(q.out)
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			closed := q.closed
+			q.mu.Unlock()
+			if closed {
+				return
+			}
+			<-q.wake
+			continue
+		}
+		r := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+		q.out <- r
+	}
+}
@@ -0,0 +1,56 @@
+package transcriber
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestResultQueueStalledConsumer(t *testing.T) {
+	q := newResultQueue("test", 4)
+	dropped := droppedResults.With("test").Value()
+
+	// Nobody reads while the provider produces; push must never block
+	q.push(TranscriptionResult{Text: "p1"})
+	q.push(TranscriptionResult{Text: "f1", IsFinal: true})
+	for i := 2; i <= 5; i++ {
+		q.push(TranscriptionResult{Text: fmt.Sprintf("p%d", i)})
+	}
+	q.push(TranscriptionResult{Text: "f2", IsFinal: true})
+	q.close()
+	q.push(TranscriptionResult{Text: "late", IsFinal: true})
+
+	var got, finals []string
+	for r := range q.Results() {
+		got = append(got, r.Text)
+		if r.IsFinal {
+			finals = append(finals, r.Text)
+		}
+	}
+	// The run goroutine may hold one result for delivery outside the queue
+	if len(got) > 5 || got[len(got)-2] != "p5" {
+		t.Errorf("delivered %v, want at most 5 with the newest partial before f2", got)
+	}
+	if fmt.Sprint(finals) != "[f1 f2]" {
+		t.Errorf("delivered finals %v, want [f1 f2]", finals)
+	}
+	if n := droppedResults.With("test").Value() - dropped; int(n) != 7-len(got) {
+		t.Errorf("%d results counted as dropped, %d of 7 delivered", n, len(got))
+	}
+}
+
+func TestResultQueueKeepsFinals(t *testing.T) {
+	q := newResultQueue("test", 2)
+	for i := 0; i < 5; i++ {
+		q.push(TranscriptionResult{Text: fmt.Sprintf("f%d", i), IsFinal: true})
+	}
+	q.push(TranscriptionResult{Text: "partial"})
+	q.close()
+
+	var got []string
+	for r := range q.Results() {
+		got = append(got, r.Text)
+	}
+	if fmt.Sprint(got) != "[f0 f1 f2 f3 f4]" {
+		t.Errorf("delivered %v, want every final and no partial", got)
+	}
+}
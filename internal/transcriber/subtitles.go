@@ -0,0 +1,51 @@
+package transcriber
+
+import (
+	"fmt"
+	"io"
+)
+
+// minCueDuration keeps cues visible when an utterance has no measurable length
+const minCueDuration = 0.5
+
+// WriteSRT writes utterances as SubRip subtitles
+func WriteSRT(w io.Writer, utterances []Utterance) error {
+	for i, u := range utterances {
+		start, end := cueTimes(u)
+		if _, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, formatCueTime(start, ","), formatCueTime(end, ","), u.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteVTT writes utterances as WebVTT subtitles
+func WriteVTT(w io.Writer, utterances []Utterance) error {
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+	for _, u := range utterances {
+		start, end := cueTimes(u)
+		if _, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", formatCueTime(start, "."), formatCueTime(end, "."), u.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cueTimes(u Utterance) (float64, float64) {
+	end := u.End
+	if end-u.Start < minCueDuration {
+		end = u.Start + minCueDuration
+	}
+	return u.Start, end
+}
+
+// formatCueTime renders seconds as HH:MM:SS<sep>mmm
+func formatCueTime(seconds float64, sep string) string {
+	ms := int64(seconds*1000 + 0.5)
+	if ms < 0 {
+		ms = 0
+	}
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
@@ -0,0 +1,33 @@
+package transcriber
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubtitleExport(t *testing.T) {
+	utterances := []Utterance{
+		{Text: "hello there", Start: 1.25, End: 3},
+		{Text: "yes", Start: 3661.5, End: 3661.5},
+	}
+
+	var srt strings.Builder
+	if err := WriteSRT(&srt, utterances); err != nil {
+		t.Fatal(err)
+	}
+	wantSRT := "1\n00:00:01,250 --> 00:00:03,000\nhello there\n\n" +
+		"2\n01:01:01,500 --> 01:01:02,000\nyes\n\n"
+	if srt.String() != wantSRT {
+		t.Errorf("SRT =\n%q\nwant\n%q", srt.String(), wantSRT)
+	}
+
+	var vtt strings.Builder
+	if err := WriteVTT(&vtt, utterances); err != nil {
+		t.Fatal(err)
+	}
+	wantVTT := "WEBVTT\n\n00:00:01.250 --> 00:00:03.000\nhello there\n\n" +
+		"01:01:01.500 --> 01:01:02.000\nyes\n\n"
+	if vtt.String() != wantVTT {
+		t.Errorf("VTT =\n%q\nwant\n%q", vtt.String(), wantVTT)
+	}
+}
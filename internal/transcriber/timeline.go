@@ -0,0 +1,178 @@
+package transcriber
+
+import (
+	"strings"
+	"sync"
+)
+
+// Utterance is a final transcription positioned on the call timeline.
+// Start and End are seconds from call start.
+type Utterance struct {
+	Text  string
+	Start float64
+	End   float64
+}
+
+// TimedTranscriber stamps results with offsets from call start derived from
+// the number of audio bytes received, so transcripts can be aligned with the
+// dialer's call recording. Offsets include provider latency, which is
+// typically a few hundred milliseconds.
+type TimedTranscriber struct {
+	Transcriber
+	bytesPerSec float64
+	results     chan TranscriptionResult
+
+	mu          sync.Mutex
+	audioBytes  int64
+	uttStart    float64
+	inUtterance bool
+	utterances  []Utterance
+	onUtterance func(Utterance)
+	onResult    func(TranscriptionResult)
+	redact      func(string) string
+	redactions  []redaction // finals redact rewrote, in order
+}
+
+// redaction is a final transcript and the text it is kept as
+type redaction struct {
+	text, shown string
+}
+
+// NewTimedTranscriber wraps t; sampleRate is the rate of the 16-bit mono
+// audio passed to ProcessAudio
+func NewTimedTranscriber(t Transcriber, sampleRate int) *TimedTranscriber {
+	tt := &TimedTranscriber{
+		Transcriber: t,
+		bytesPerSec: float64(sampleRate * 2),
+		results:     make(chan TranscriptionResult, 100),
+	}
+	go tt.forward()
+	return tt
+}
+
+// ProcessAudio counts the audio and forwards it to the provider
+func (tt *TimedTranscriber) ProcessAudio(audioData []byte) error {
+	tt.mu.Lock()
+	tt.audioBytes += int64(len(audioData))
+	tt.mu.Unlock()
+	return tt.Transcriber.ProcessAudio(audioData)
+}
+
+// Offset returns the current position in the call in seconds
+func (tt *TimedTranscriber) Offset() float64 {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	return tt.offset()
+}
+
+func (tt *TimedTranscriber) offset() float64 {
+	if tt.bytesPerSec == 0 {
+		return 0
+	}
+	return float64(tt.audioBytes) / tt.bytesPerSec
+}
+
+// Results returns provider results with Start and End filled in
+func (tt *TimedTranscriber) Results() <-chan TranscriptionResult {
+	return tt.results
+}
+
+// Utterances returns the final utterances recognized so far
+func (tt *TimedTranscriber) Utterances() []Utterance {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	return append([]Utterance(nil), tt.utterances...)
+}
+
+// OnUtterance registers a callback invoked for every final utterance
+func (tt *TimedTranscriber) OnUtterance(fn func(Utterance)) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	tt.onUtterance = fn
+}
+
+// OnResult registers a callback invoked for every stamped result, partials
+// included
+func (tt *TimedTranscriber) OnResult(fn func(TranscriptionResult)) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	tt.onResult = fn
+}
+
+// SetRedact registers fn to rewrite the text of results before they are
+// kept as utterances or passed to the callbacks, e.g. to mask digits the
+// caller must not have logged. Results still delivers the text as
+// recognized, and GetFullTranscript applies the same rewrites to the
+// provider's transcript.
+func (tt *TimedTranscriber) SetRedact(fn func(string) string) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	tt.redact = fn
+}
+
+// GetFullTranscript returns the provider's transcript with redacted finals
+// rewritten
+func (tt *TimedTranscriber) GetFullTranscript() string {
+	full := tt.Transcriber.GetFullTranscript()
+	tt.mu.Lock()
+	redactions := tt.redactions
+	tt.mu.Unlock()
+	pos := 0
+	for _, r := range redactions {
+		i := strings.Index(full[pos:], r.text)
+		if i < 0 {
+			continue
+		}
+		full = full[:pos+i] + r.shown + full[pos+i+len(r.text):]
+		pos += i + len(r.shown)
+	}
+	return full
+}
+
+// Unwrap returns the wrapped transcriber
+func (tt *TimedTranscriber) Unwrap() Transcriber { return tt.Transcriber }
+
+// forward stamps provider results. An utterance starts at the first partial
+// and ends when the final result arrives.
+func (tt *TimedTranscriber) forward() {
+	defer close(tt.results)
+
+	for result := range tt.Transcriber.Results() {
+		tt.mu.Lock()
+		now := tt.offset()
+		if !tt.inUtterance {
+			tt.uttStart = now
+			tt.inUtterance = result.Text != ""
+		}
+		result.Start = tt.uttStart
+		result.End = now
+		shown := result
+		if tt.redact != nil && result.Text != "" {
+			shown.Text = tt.redact(result.Text)
+			if result.IsFinal && shown.Text != result.Text {
+				tt.redactions = append(tt.redactions, redaction{text: result.Text, shown: shown.Text})
+			}
+		}
+
+		var fn func(Utterance)
+		var u Utterance
+		if result.IsFinal {
+			tt.inUtterance = false
+			if result.Text != "" {
+				u = Utterance{Text: shown.Text, Start: result.Start, End: result.End}
+				tt.utterances = append(tt.utterances, u)
+				fn = tt.onUtterance
+			}
+		}
+		onResult := tt.onResult
+		tt.mu.Unlock()
+
+		if fn != nil {
+			fn(u)
+		}
+		if onResult != nil {
+			onResult(shown)
+		}
+		tt.results <- result
+	}
+}
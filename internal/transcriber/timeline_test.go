@@ -0,0 +1,102 @@
+package transcriber
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+type scriptedTranscriber struct {
+	captureTranscriber
+	results chan TranscriptionResult
+}
+
+func (s *scriptedTranscriber) Results() <-chan TranscriptionResult { return s.results }
+
+func TestTimedTranscriberOffsets(t *testing.T) {
+	inner := &scriptedTranscriber{results: make(chan TranscriptionResult)}
+	tt := NewTimedTranscriber(inner, 8000)
+
+	var logged []Utterance
+	tt.OnUtterance(func(u Utterance) { logged = append(logged, u) })
+
+	second := make([]byte, 16000) // 1s at 8kHz
+
+	// 1s of silence, then speech recognized over the next 2s
+	tt.ProcessAudio(second)
+	inner.results <- TranscriptionResult{Text: "hello", IsFinal: false}
+	partial := <-tt.Results()
+	tt.ProcessAudio(second)
+	tt.ProcessAudio(second)
+	inner.results <- TranscriptionResult{Text: "hello there", IsFinal: true}
+	final := <-tt.Results()
+
+	if partial.Start != 1 {
+		t.Errorf("partial start = %.2f, want 1", partial.Start)
+	}
+	if final.Start != 1 || final.End != 3 {
+		t.Errorf("final = %.2f-%.2f, want 1-3", final.Start, final.End)
+	}
+
+	// A final without partials starts and ends at the current offset
+	tt.ProcessAudio(second[:8000])
+	inner.results <- TranscriptionResult{Text: "yes", IsFinal: true}
+	<-tt.Results()
+
+	utterances := tt.Utterances()
+	if len(utterances) != 2 || len(logged) != 2 {
+		t.Fatalf("got %d utterances, %d logged, want 2", len(utterances), len(logged))
+	}
+	if u := utterances[1]; math.Abs(u.Start-3.5) > 1e-9 || u.Start != u.End {
+		t.Errorf("second utterance = %+v, want start=end=3.5", u)
+	}
+
+	close(inner.results)
+	if _, ok := <-tt.Results(); ok {
+		t.Error("results should close when the provider closes")
+	}
+}
+
+func TestTimedTranscriberRedact(t *testing.T) {
+	inner := &scriptedTranscriber{captureTranscriber: captureTranscriber{}, results: make(chan TranscriptionResult)}
+	tt := NewTimedTranscriber(&fullTextTranscriber{inner, "yes it's five five one ok five five one"}, 8000)
+	var seen []string
+	tt.OnResult(func(r TranscriptionResult) { seen = append(seen, r.Text) })
+	masking := true
+	tt.SetRedact(func(text string) string {
+		if !masking {
+			return text
+		}
+		return strings.ReplaceAll(text, "five", "*")
+	})
+
+	inner.results <- TranscriptionResult{Text: "yes"}
+	<-tt.Results()
+	inner.results <- TranscriptionResult{Text: "yes it's five five"}
+	<-tt.Results()
+	inner.results <- TranscriptionResult{Text: "yes it's five five one", IsFinal: true}
+	if final := <-tt.Results(); final.Text != "yes it's five five one" {
+		t.Errorf("results should keep the text as recognized, got %q", final.Text)
+	}
+	masking = false
+	inner.results <- TranscriptionResult{Text: "ok five five one", IsFinal: true}
+	<-tt.Results()
+
+	if want := "[yes yes it's * * yes it's * * one ok five five one]"; fmt.Sprint(seen) != want {
+		t.Errorf("callbacks saw %v, want %s", seen, want)
+	}
+	if u := tt.Utterances(); u[0].Text != "yes it's * * one" || u[1].Text != "ok five five one" {
+		t.Errorf("utterances = %+v", u)
+	}
+	if got := tt.GetFullTranscript(); got != "yes it's * * one ok five five one" {
+		t.Errorf("full transcript = %q", got)
+	}
+}
+
+type fullTextTranscriber struct {
+	*scriptedTranscriber
+	text string
+}
+
+func (f *fullTextTranscriber) GetFullTranscript() string { return f.text }
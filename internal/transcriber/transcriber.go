@@ -1,6 +1,8 @@
 package transcriber
 
-// Transcriber is the common interface for all transcription providers
+// Transcriber is the common interface for all transcription providers.
+// ProcessAudio must not retain audioData after it returns: the server reads
+// frames into pooled buffers that are reused for the next frame.
 type Transcriber interface {
 	ProcessAudio(audioData []byte) error
 	Results() <-chan TranscriptionResult
@@ -15,4 +17,6 @@ type TranscriptionResult struct {
 	IsFinal    bool
 	Confidence float64 // Optional confidence score
 	Timestamp  float64 // Optional timestamp
+	Start      float64 // Seconds from call start, set by TimedTranscriber
+	End        float64 // Seconds from call start, set by TimedTranscriber
 }
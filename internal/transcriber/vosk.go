@@ -6,16 +6,45 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/bufpool"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/metrics"
 	"github.com/gorilla/websocket"
 )
 
+// voskQueueFrames bounds the audio frames waiting for the writer goroutine,
+// one second of 20ms frames. A Vosk server that falls further behind loses
+// audio rather than stalling the call's read loop.
+const voskQueueFrames = 50
+
+// voskWriteTimeout bounds one WebSocket write, so a stalled server cannot
+// hold Close forever
+const voskWriteTimeout = 5 * time.Second
+
+var voskDroppedFrames = metrics.NewCounter("audiosocket_vosk_dropped_frames_total", "Audio frames dropped because a Vosk server fell behind")
+
+// VoskTranscriber streams audio to a Vosk server. ProcessAudio only queues
+// a copy of each frame; a writer goroutine owns the chunker and all
+// WebSocket writes, so frames never contend with result handling for mu,
+// which only guards the transcript.
 type VoskTranscriber struct {
     conn         *websocket.Conn
-    results      chan TranscriptionResult
+    results      *resultQueue
     fullText     strings.Builder
-    mu           sync.Mutex
+    mu           sync.Mutex // guards fullText
     sampleRate   int
+    chunker      *audioChunker // used by the writer goroutine only
+    queue        chan []byte   // pooled copies of frames to send
+    stop         chan struct{} // closed by Close; the writer drains and flushes
+    stopOnce     sync.Once
+    written      chan struct{} // closed when the writer has sent EOF
+    writeErr     atomic.Pointer[error]
+    dropping     atomic.Bool // logs once per run of dropped frames
+    release      func() // returns the stream slot to a VoskPool, if pooled
+    releaseOnce  sync.Once
+    rawObservers // debug capture of WebSocket frames
 }
 
 type VoskResult struct {
@@ -39,39 +68,133 @@ func NewVoskTranscriber(serverURL string, sampleRate int) (*VoskTranscriber, err
 
     vt := &VoskTranscriber{
         conn:       conn,
-        results:    make(chan TranscriptionResult, 100),
+        results:    newResultQueue("vosk", resultQueueSize),
         sampleRate: sampleRate,
+        // Vosk accepts any size; forward every 20ms frame but cap bursts at 250ms
+        chunker:    newAudioChunker(sampleRate, 20*time.Millisecond, 250*time.Millisecond),
+        queue:      make(chan []byte, voskQueueFrames),
+        stop:       make(chan struct{}),
+        written:    make(chan struct{}),
     }
 
     // Start result handler
     go vt.handleResults()
+    go vt.audioWriter()
 
     return vt, nil
 }
 
+// ProcessAudio queues a copy of audioData for the writer goroutine. It
+// returns the writer's error once a send has failed.
 func (vt *VoskTranscriber) ProcessAudio(audioData []byte) error {
-    vt.mu.Lock()
-    defer vt.mu.Unlock()
-
-    // Send audio data to Vosk
-    if err := vt.conn.WriteMessage(websocket.BinaryMessage, audioData); err != nil {
-        return fmt.Errorf("failed to send audio to Vosk: %w", err)
+    if err := vt.writeErr.Load(); err != nil {
+        return *err
     }
 
+    frame := bufpool.GetBytes(len(audioData))[:len(audioData)]
+    copy(frame, audioData)
+    select {
+    case vt.queue <- frame:
+        vt.dropping.Store(false)
+    default:
+        bufpool.PutBytes(frame)
+        voskDroppedFrames.Inc()
+        if !vt.dropping.Swap(true) {
+            log.Printf("Vosk send queue full, dropping audio")
+        }
+    }
     return nil
 }
 
+// audioWriter sends queued frames until Close, then the frames still
+// queued, the chunker's tail and EOF
+func (vt *VoskTranscriber) audioWriter() {
+    defer close(vt.written)
+    for {
+        select {
+        case frame := <-vt.queue:
+            vt.send(frame)
+        case <-vt.stop:
+            for len(vt.queue) > 0 {
+                vt.send(<-vt.queue)
+            }
+            vt.flush()
+            return
+        }
+    }
+}
+
+// send writes one queued frame and returns it to the pool
+func (vt *VoskTranscriber) send(frame []byte) {
+    defer bufpool.PutBytes(frame)
+    if vt.writeErr.Load() != nil {
+        return
+    }
+
+    // A frame Vosk accepts as is goes straight to the socket; anything else
+    // is regrouped by the chunker
+    if vt.chunker.Direct(frame) {
+        if err := vt.write(websocket.BinaryMessage, frame); err != nil {
+            vt.fail(err)
+        }
+        return
+    }
+
+    vt.chunker.Write(frame)
+    for chunk := vt.chunker.Next(); chunk != nil; chunk = vt.chunker.Next() {
+        err := vt.write(websocket.BinaryMessage, chunk)
+        vt.chunker.Release(chunk)
+        if err != nil {
+            vt.chunker.Reset()
+            vt.fail(err)
+            return
+        }
+    }
+}
+
+// write sends one WebSocket message
+func (vt *VoskTranscriber) write(messageType int, data []byte) error {
+    vt.observe(FrameSend, messageType, data)
+    vt.conn.SetWriteDeadline(time.Now().Add(voskWriteTimeout))
+    return vt.conn.WriteMessage(messageType, data)
+}
+
+// fail records a write error for ProcessAudio to return
+func (vt *VoskTranscriber) fail(err error) {
+    err = fmt.Errorf("failed to send audio to Vosk: %w", err)
+    vt.writeErr.CompareAndSwap(nil, &err)
+}
+
+// flush sends any partial chunk, so the tail of the call is recognized, and
+// EOF to get the final results
+func (vt *VoskTranscriber) flush() {
+    if vt.writeErr.Load() != nil {
+        return
+    }
+    if chunk := vt.chunker.Flush(); chunk != nil {
+        _ = vt.write(websocket.BinaryMessage, chunk)
+        vt.chunker.Release(chunk)
+    }
+
+    if err := vt.write(websocket.TextMessage, []byte(`{"eof": 1}`)); err != nil {
+        log.Printf("Failed to send EOF to Vosk: %v", err)
+    }
+}
+
 func (vt *VoskTranscriber) handleResults() {
     for {
         _, message, err := vt.conn.ReadMessage()
         if err != nil {
+            vt.observeClose(err)
             if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
                 log.Printf("Vosk WebSocket error: %v", err)
             }
-            close(vt.results)
+            vt.results.close()
             return
         }
 
+        vt.observe(FrameRecv, websocket.TextMessage, message)
+
         var result VoskResult
         if err := json.Unmarshal(message, &result); err != nil {
             log.Printf("Failed to parse Vosk result: %v", err)
@@ -80,10 +203,10 @@ func (vt *VoskTranscriber) handleResults() {
 
         // Handle partial results
         if result.Partial != "" {
-            vt.results <- TranscriptionResult{
+            vt.results.push(TranscriptionResult{
                 Text:    result.Partial,
                 IsFinal: false,
-            }
+            })
         }
 
         // Handle final results
@@ -95,16 +218,16 @@ func (vt *VoskTranscriber) handleResults() {
             vt.fullText.WriteString(result.Text)
             vt.mu.Unlock()
 
-            vt.results <- TranscriptionResult{
+            vt.results.push(TranscriptionResult{
                 Text:    result.Text,
                 IsFinal: true,
-            }
+            })
         }
     }
 }
 
 func (vt *VoskTranscriber) Results() <-chan TranscriptionResult {
-    return vt.results
+    return vt.results.Results()
 }
 
 func (vt *VoskTranscriber) GetFullTranscript() string {
@@ -124,10 +247,13 @@ func (vt *VoskTranscriber) AddMarker(marker string) {
 }
 
 func (vt *VoskTranscriber) Close() error {
-    // Send EOF to Vosk to get final results
-    if err := vt.conn.WriteMessage(websocket.TextMessage, []byte(`{"eof": 1}`)); err != nil {
-        log.Printf("Failed to send EOF to Vosk: %v", err)
+    if vt.release != nil {
+        vt.releaseOnce.Do(vt.release)
     }
-    
+
+    // The writer sends the queued audio and EOF before the socket closes
+    vt.stopOnce.Do(func() { close(vt.stop) })
+    <-vt.written
+
     return vt.conn.Close()
-}
\ No newline at end of file
+}
@@ -0,0 +1,161 @@
+//go:build vosk && cgo
+
+package transcriber
+
+/*
+#cgo LDFLAGS: -lvosk
+#include <stdlib.h>
+#include <vosk_api.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// VoskLocalAvailable reports whether the in-process Vosk backend was compiled in
+const VoskLocalAvailable = true
+
+// VoskModel is a Vosk model loaded into this process. Models are large and
+// read-only, so one model is shared by all sessions.
+type VoskModel struct {
+	model *C.VoskModel
+}
+
+// LoadVoskModel loads a Vosk model directory via libvosk
+func LoadVoskModel(path string) (*VoskModel, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	model := C.vosk_model_new(cPath)
+	if model == nil {
+		return nil, fmt.Errorf("failed to load Vosk model from %s", path)
+	}
+	log.Printf("Loaded in-process Vosk model from %s", path)
+	return &VoskModel{model: model}, nil
+}
+
+// Close frees the model; it must not be used by any recognizer afterwards
+func (m *VoskModel) Close() {
+	if m.model != nil {
+		C.vosk_model_free(m.model)
+		m.model = nil
+	}
+}
+
+// VoskLocalTranscriber recognizes speech in-process with libvosk, avoiding
+// the WebSocket hop to a Vosk server
+type VoskLocalTranscriber struct {
+	recognizer *C.VoskRecognizer
+	results    *resultQueue
+	fullText   strings.Builder
+	mu         sync.Mutex // guards recognizer and fullText
+	closed     bool
+}
+
+// NewVoskLocalTranscriber creates a recognizer on a shared model
+func NewVoskLocalTranscriber(model *VoskModel, sampleRate int) (Transcriber, error) {
+	if model == nil || model.model == nil {
+		return nil, fmt.Errorf("Vosk model not loaded")
+	}
+	rec := C.vosk_recognizer_new(model.model, C.float(sampleRate))
+	if rec == nil {
+		return nil, fmt.Errorf("failed to create Vosk recognizer")
+	}
+	return &VoskLocalTranscriber{
+		recognizer: rec,
+		results:    newResultQueue("vosk_local", resultQueueSize),
+	}, nil
+}
+
+func (vt *VoskLocalTranscriber) ProcessAudio(audioData []byte) error {
+	if len(audioData) == 0 {
+		return nil
+	}
+
+	vt.mu.Lock()
+	if vt.closed {
+		vt.mu.Unlock()
+		return fmt.Errorf("transcriber closed")
+	}
+	final := C.vosk_recognizer_accept_waveform(vt.recognizer, (*C.char)(unsafe.Pointer(&audioData[0])), C.int(len(audioData)))
+	var raw string
+	if final == 1 {
+		raw = C.GoString(C.vosk_recognizer_result(vt.recognizer))
+	} else if final == 0 {
+		raw = C.GoString(C.vosk_recognizer_partial_result(vt.recognizer))
+	} else {
+		vt.mu.Unlock()
+		return fmt.Errorf("Vosk recognizer failed to accept audio")
+	}
+	vt.mu.Unlock()
+
+	vt.handleResult(raw)
+	return nil
+}
+
+// handleResult parses a libvosk JSON result and publishes it
+func (vt *VoskLocalTranscriber) handleResult(raw string) {
+	var result VoskResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		log.Printf("Failed to parse Vosk result: %v", err)
+		return
+	}
+
+	if result.Partial != "" {
+		vt.results.push(TranscriptionResult{Text: result.Partial, IsFinal: false})
+	}
+
+	if result.Text != "" {
+		vt.mu.Lock()
+		if vt.fullText.Len() > 0 {
+			vt.fullText.WriteString(" ")
+		}
+		vt.fullText.WriteString(result.Text)
+		vt.mu.Unlock()
+
+		vt.results.push(TranscriptionResult{Text: result.Text, IsFinal: true})
+	}
+}
+
+func (vt *VoskLocalTranscriber) Results() <-chan TranscriptionResult {
+	return vt.results.Results()
+}
+
+func (vt *VoskLocalTranscriber) GetFullTranscript() string {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	return vt.fullText.String()
+}
+
+func (vt *VoskLocalTranscriber) AddMarker(marker string) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+
+	if vt.fullText.Len() > 0 {
+		vt.fullText.WriteString(" ")
+	}
+	vt.fullText.WriteString(marker)
+}
+
+func (vt *VoskLocalTranscriber) Close() error {
+	vt.mu.Lock()
+	if vt.closed {
+		vt.mu.Unlock()
+		return nil
+	}
+	vt.closed = true
+	raw := C.GoString(C.vosk_recognizer_final_result(vt.recognizer))
+	C.vosk_recognizer_free(vt.recognizer)
+	vt.recognizer = nil
+	vt.mu.Unlock()
+
+	vt.handleResult(raw)
+	vt.results.close()
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build !vosk || !cgo
+
+package transcriber
+
+import (
+	"fmt"
+)
+
+// VoskLocalAvailable reports whether the in-process Vosk backend was compiled in
+const VoskLocalAvailable = false
+
+// errVoskLocalUnavailable is returned when the binary was built without libvosk
+var errVoskLocalUnavailable = fmt.Errorf("in-process Vosk not available: rebuild with CGO_ENABLED=1 -tags vosk")
+
+// VoskModel is a placeholder when built without the vosk tag
+type VoskModel struct{}
+
+// LoadVoskModel always fails when built without the vosk tag
+func LoadVoskModel(path string) (*VoskModel, error) {
+	return nil, errVoskLocalUnavailable
+}
+
+// Close is a no-op
+func (m *VoskModel) Close() {}
+
+// NewVoskLocalTranscriber always fails when built without the vosk tag
+func NewVoskLocalTranscriber(model *VoskModel, sampleRate int) (Transcriber, error) {
+	return nil, errVoskLocalUnavailable
+}
@@ -0,0 +1,172 @@
+package transcriber
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultVoskHealthInterval is how often pooled Vosk servers are probed
+const DefaultVoskHealthInterval = 10 * time.Second
+
+// VoskPool balances sessions across several Vosk servers. A single Vosk
+// instance saturates around 40 concurrent streams, so new sessions go to the
+// healthy server with the fewest active streams.
+type VoskPool struct {
+	mu      sync.Mutex
+	servers []*voskServer
+	stop    chan struct{}
+	once    sync.Once
+}
+
+type voskServer struct {
+	url     string
+	active  int
+	healthy bool
+}
+
+// NewVoskPool creates a pool over serverURLs and starts background health
+// checks every interval (DefaultVoskHealthInterval if zero)
+func NewVoskPool(serverURLs []string, interval time.Duration) (*VoskPool, error) {
+	if len(serverURLs) == 0 {
+		return nil, fmt.Errorf("at least one Vosk server URL is required")
+	}
+	if interval <= 0 {
+		interval = DefaultVoskHealthInterval
+	}
+
+	pool := &VoskPool{stop: make(chan struct{})}
+	for _, u := range serverURLs {
+		pool.servers = append(pool.servers, &voskServer{url: u, healthy: true})
+	}
+
+	go pool.healthLoop(interval)
+	return pool, nil
+}
+
+// NewTranscriber connects a transcriber to the least-loaded healthy server,
+// falling back to the next candidate if the connection fails
+func (p *VoskPool) NewTranscriber(sampleRate int) (*VoskTranscriber, error) {
+	tried := make(map[*voskServer]bool)
+	var lastErr error
+
+	for {
+		server := p.pick(tried)
+		if server == nil {
+			if lastErr == nil {
+				lastErr = fmt.Errorf("no healthy Vosk servers")
+			}
+			return nil, lastErr
+		}
+		tried[server] = true
+
+		vt, err := NewVoskTranscriber(server.url, sampleRate)
+		if err != nil {
+			log.Printf("Vosk pool: %s unavailable: %v", server.url, err)
+			p.setHealthy(server, false)
+			p.release(server)
+			lastErr = err
+			continue
+		}
+		vt.release = func() { p.release(server) }
+		return vt, nil
+	}
+}
+
+// pick reserves a slot on the healthy server with the fewest active streams
+func (p *VoskPool) pick(skip map[*voskServer]bool) *voskServer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *voskServer
+	for _, s := range p.servers {
+		if !s.healthy || skip[s] {
+			continue
+		}
+		if best == nil || s.active < best.active {
+			best = s
+		}
+	}
+	if best != nil {
+		best.active++
+	}
+	return best
+}
+
+func (p *VoskPool) release(server *voskServer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if server.active > 0 {
+		server.active--
+	}
+}
+
+func (p *VoskPool) setHealthy(server *voskServer, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if server.healthy != healthy {
+		log.Printf("Vosk pool: %s healthy=%v", server.url, healthy)
+	}
+	server.healthy = healthy
+}
+
+// healthLoop probes every server with a TCP connect
+func (p *VoskPool) healthLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			servers := append([]*voskServer(nil), p.servers...)
+			p.mu.Unlock()
+			for _, s := range servers {
+				p.setHealthy(s, probeVosk(s.url))
+			}
+		}
+	}
+}
+
+// probeVosk reports whether the Vosk server's TCP port accepts connections
+func probeVosk(serverURL string) bool {
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return false
+	}
+	host := u.Host
+	if u.Port() == "" {
+		port := "80"
+		if u.Scheme == "wss" {
+			port = "443"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+	conn, err := net.DialTimeout("tcp", host, 2*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Stats returns active stream counts per server URL
+func (p *VoskPool) Stats() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make(map[string]int, len(p.servers))
+	for _, s := range p.servers {
+		stats[s.url] = s.active
+	}
+	return stats
+}
+
+// Close stops the health checks
+func (p *VoskPool) Close() {
+	p.once.Do(func() { close(p.stop) })
+}
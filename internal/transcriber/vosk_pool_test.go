@@ -0,0 +1,39 @@
+package transcriber
+
+import (
+	"testing"
+)
+
+func TestVoskPoolLeastConnections(t *testing.T) {
+	pool, err := NewVoskPool([]string{"ws://a:2700", "ws://b:2700", "ws://c:2700"}, 0)
+	if err != nil {
+		t.Fatalf("Failed to create pool: %v", err)
+	}
+	defer pool.Close()
+
+	// Spread three sessions, one per server
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		seen[pool.pick(nil).url] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected sessions spread across 3 servers, got %v", pool.Stats())
+	}
+
+	// Unhealthy servers are skipped
+	pool.setHealthy(pool.servers[0], false)
+	pool.release(pool.servers[1])
+	if s := pool.pick(nil); s.url != "ws://b:2700" {
+		t.Errorf("Expected least-loaded healthy server ws://b:2700, got %s", s.url)
+	}
+
+	pool.setHealthy(pool.servers[1], false)
+	pool.setHealthy(pool.servers[2], false)
+	if s := pool.pick(nil); s != nil {
+		t.Errorf("Expected no server when all are unhealthy, got %s", s.url)
+	}
+
+	if _, err := NewVoskPool(nil, 0); err == nil {
+		t.Error("Expected error for empty server list")
+	}
+}
@@ -0,0 +1,151 @@
+package transcriber
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeVosk is a Vosk server that counts the audio it receives, answers
+// every resultEvery binary messages with a final result, and closes after
+// EOF with a last result
+type fakeVosk struct {
+	*httptest.Server
+	resultEvery int
+	received    atomic.Int64
+	eofs        atomic.Int64
+}
+
+func newFakeVosk(resultEvery int) *fakeVosk {
+	f := &fakeVosk{resultEvery: resultEvery}
+	upgrader := websocket.Upgrader{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		frames := 0
+		for {
+			kind, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if kind == websocket.TextMessage {
+				f.eofs.Add(1)
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"text": "goodbye"}`))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			f.received.Add(int64(len(data)))
+			frames++
+			if f.resultEvery > 0 && frames%f.resultEvery == 0 {
+				conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"text": "frame %d"}`, frames)))
+			}
+		}
+	}))
+	f.URL = "ws" + strings.TrimPrefix(f.URL, "http")
+	return f
+}
+
+// finishAudio makes vt's writer send the queued audio and EOF, like Close
+// but keeping the socket open for the final results
+func finishAudio(vt *VoskTranscriber) {
+	vt.stopOnce.Do(func() { close(vt.stop) })
+	<-vt.written
+}
+
+func TestVoskTranscriber(t *testing.T) {
+	server := newFakeVosk(10)
+	defer server.Close()
+
+	vt, err := NewVoskTranscriber(server.URL, 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 20ms frames go out as they are, 10ms frames through the chunker
+	frame := make([]byte, 320)
+	for i := 0; i < 20; i++ {
+		if err := vt.ProcessAudio(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if err := vt.ProcessAudio(frame[:160]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	finishAudio(vt)
+	for range vt.Results() {
+	}
+	vt.Close()
+
+	if got := server.received.Load(); got != 20*320+5*160 {
+		t.Errorf("server received %d bytes, want %d", got, 20*320+5*160)
+	}
+	if server.eofs.Load() != 1 {
+		t.Errorf("server received %d EOFs, want 1", server.eofs.Load())
+	}
+	if text := vt.GetFullTranscript(); text != "frame 10 frame 20 goodbye" {
+		t.Errorf("transcript %q", text)
+	}
+}
+
+// BenchmarkVoskProcessAudio100Sessions streams 20ms frames from 100
+// concurrent sessions while their servers return a result every 5 frames;
+// one op is one frame from every session
+func BenchmarkVoskProcessAudio100Sessions(b *testing.B) {
+	const sessions = 100
+	server := newFakeVosk(5)
+	defer server.Close()
+
+	transcribers := make([]*VoskTranscriber, sessions)
+	var readers sync.WaitGroup
+	for i := range transcribers {
+		vt, err := NewVoskTranscriber(server.URL, 8000)
+		if err != nil {
+			b.Fatal(err)
+		}
+		transcribers[i] = vt
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for range vt.Results() {
+			}
+		}()
+	}
+
+	frame := make([]byte, 320)
+	b.ReportAllocs()
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for _, vt := range transcribers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < b.N; i++ {
+				vt.ProcessAudio(frame)
+				if i%voskQueueFrames == voskQueueFrames-1 {
+					// Pace like a call so the queue is not flooded
+					for len(vt.queue) > voskQueueFrames/2 {
+						runtime.Gosched()
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	b.StopTimer()
+
+	for _, vt := range transcribers {
+		vt.Close()
+	}
+	readers.Wait()
+}
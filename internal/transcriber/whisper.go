@@ -0,0 +1,123 @@
+package transcriber
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OfflineTranscriber transcribes a whole recording after the call, where
+// accuracy matters more than latency
+type OfflineTranscriber interface {
+	Transcribe(wav []byte) (string, error)
+}
+
+// DefaultWhisperURL is the OpenAI API
+const DefaultWhisperURL = "https://api.openai.com"
+
+// DefaultWhisperPath is the OpenAI transcriptions endpoint
+const DefaultWhisperPath = "/v1/audio/transcriptions"
+
+// DefaultWhisperModel is the model used when none is set
+const DefaultWhisperModel = "whisper-1"
+
+// whisperTimeout bounds one transcription; a long call takes a while
+const whisperTimeout = 5 * time.Minute
+
+// Whisper transcribes recordings with a Whisper model behind the OpenAI
+// audio transcriptions API. Self-hosted servers that speak the same API
+// (faster-whisper-server, LocalAI, whisper.cpp's server with
+// --inference-path) work with their URL and no API key; whisper.cpp's
+// default endpoint works by setting Path to "/inference".
+type Whisper struct {
+	BaseURL  string // DefaultWhisperURL if empty
+	Path     string // DefaultWhisperPath if empty
+	APIKey   string
+	Model    string // DefaultWhisperModel if empty
+	Language string // ISO-639-1, e.g. "en"; detected if empty
+	Prompt   string // vocabulary hint, e.g. product names
+	Client   *http.Client
+}
+
+// NewWhisper creates a Whisper client for the API at baseURL
+func NewWhisper(baseURL, apiKey, model, language string) *Whisper {
+	return &Whisper{
+		BaseURL:  baseURL,
+		APIKey:   apiKey,
+		Model:    model,
+		Language: language,
+		Client:   &http.Client{Timeout: whisperTimeout},
+	}
+}
+
+// Transcribe uploads a WAV recording and returns its text
+func (w *Whisper) Transcribe(wav []byte) (string, error) {
+	base := w.BaseURL
+	if base == "" {
+		base = DefaultWhisperURL
+	}
+	path := w.Path
+	if path == "" {
+		path = DefaultWhisperPath
+	}
+	model := w.Model
+	if model == "" {
+		model = DefaultWhisperModel
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "call.wav")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode whisper request: %w", err)
+	}
+	part.Write(wav)
+	form.WriteField("model", model)
+	form.WriteField("response_format", "json")
+	if w.Language != "" {
+		form.WriteField("language", w.Language)
+	}
+	if w.Prompt != "" {
+		form.WriteField("prompt", w.Prompt)
+	}
+	if err := form.Close(); err != nil {
+		return "", fmt.Errorf("failed to encode whisper request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(base, "/")+path, &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create whisper request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if w.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.APIKey)
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("whisper request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read whisper response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("whisper returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", fmt.Errorf("failed to decode whisper response: %w", err)
+	}
+	return strings.TrimSpace(result.Text), nil
+}
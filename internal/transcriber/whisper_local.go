@@ -0,0 +1,293 @@
+package transcriber
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+)
+
+// Local Whisper defaults
+const (
+	DefaultWhisperSilenceMs    = 600   // a pause this long ends an utterance
+	DefaultWhisperMaxSegmentMs = 15000 // longer speech is cut into segments
+	whisperPrerollMs           = 200   // audio kept from before speech was detected
+	whisperLocalTimeout        = 30 * time.Second
+)
+
+func init() {
+	Register("whisper_local", newWhisperLocalFromConfig)
+}
+
+// WhisperLocalSettings tunes the segmentation of a WhisperLocalTranscriber;
+// zero values use the defaults above
+type WhisperLocalSettings struct {
+	SilenceMs    int // pause that ends an utterance
+	MaxSegmentMs int // longest segment sent at once
+	PartialMs    int // re-send the utterance in progress this often for partials; 0 = no partials
+}
+
+// whisperJob is one request for the worker: a segment to transcribe, or a
+// marker to add to the transcript once the segments before it are done
+type whisperJob struct {
+	pcm    []byte
+	final  bool
+	marker string
+}
+
+// WhisperLocalTranscriber transcribes calls with a Whisper server on the
+// local network, for deployments that cannot send audio to a cloud
+// provider. Whisper is not a streaming model, so caller audio is cut into
+// utterances at pauses, using the barge-in voice detector, and each
+// utterance is uploaded as a WAV file once it ends; its text is delivered
+// as a final result. With PartialMs set, the utterance in progress is also
+// uploaded periodically and delivered as partials. Requests run one at a
+// time, in order, so results never arrive out of order.
+type WhisperLocalTranscriber struct {
+	client     OfflineTranscriber
+	sampleRate int
+	settings   WhisperLocalSettings
+	voice      *audio.VoiceDetector
+	results    *resultQueue
+	jobs       chan whisperJob
+	busy       atomic.Bool // the worker has requests to run
+	wg         sync.WaitGroup
+
+	mu           sync.Mutex
+	preroll      []byte // recent audio while the caller is quiet
+	segment      []byte // utterance in progress
+	speaking     bool   // an utterance is in progress
+	quiet        int    // bytes since the last voiced audio
+	sincePartial int    // bytes since the last partial request
+	closed       bool
+
+	textMu   sync.Mutex
+	fullText strings.Builder
+}
+
+// NewWhisperLocalTranscriber creates a transcriber for 16-bit mono PCM at
+// sampleRate that sends utterances to client
+func NewWhisperLocalTranscriber(client OfflineTranscriber, sampleRate int, settings WhisperLocalSettings) *WhisperLocalTranscriber {
+	if settings.SilenceMs <= 0 {
+		settings.SilenceMs = DefaultWhisperSilenceMs
+	}
+	if settings.MaxSegmentMs <= 0 {
+		settings.MaxSegmentMs = DefaultWhisperMaxSegmentMs
+	}
+	w := &WhisperLocalTranscriber{
+		client:     client,
+		sampleRate: sampleRate,
+		settings:   settings,
+		voice:      audio.NewVoiceDetector(audio.VoiceSettings{}, sampleRate),
+		results:    newResultQueue("whisper_local", resultQueueSize),
+		// Finals are never dropped; a backlog this deep means the server is far
+		// too slow for live calls
+		jobs: make(chan whisperJob, 32),
+	}
+	w.wg.Add(1)
+	go w.work()
+	return w
+}
+
+// newWhisperLocalFromConfig creates a transcriber from the provider's
+// section of the server configuration
+func newWhisperLocalFromConfig(sampleRate int, config map[string]any) (Transcriber, error) {
+	url := ConfigString(config, "url")
+	if url == "" {
+		return nil, fmt.Errorf("whisper_local: url is required")
+	}
+	var settings WhisperLocalSettings
+	var err error
+	if settings.SilenceMs, err = ConfigInt(config, "silence_ms", 0); err != nil {
+		return nil, fmt.Errorf("whisper_local: %w", err)
+	}
+	if settings.MaxSegmentMs, err = ConfigInt(config, "max_segment_ms", 0); err != nil {
+		return nil, fmt.Errorf("whisper_local: %w", err)
+	}
+	if settings.PartialMs, err = ConfigInt(config, "partial_ms", 0); err != nil {
+		return nil, fmt.Errorf("whisper_local: %w", err)
+	}
+	client := &Whisper{
+		BaseURL:  url,
+		Path:     ConfigString(config, "path"),
+		APIKey:   ConfigString(config, "api_key"),
+		Model:    ConfigString(config, "model"),
+		Language: ConfigString(config, "language"),
+		Prompt:   ConfigString(config, "prompt"),
+		Client:   &http.Client{Timeout: whisperLocalTimeout},
+	}
+	log.Printf("Whisper transcriber initialized (%s)", url)
+	return NewWhisperLocalTranscriber(client, sampleRate, settings), nil
+}
+
+// bytesFor returns the byte length of ms of audio
+func (w *WhisperLocalTranscriber) bytesFor(ms int) int {
+	return pcmBytes(w.sampleRate, time.Duration(ms)*time.Millisecond)
+}
+
+func (w *WhisperLocalTranscriber) ProcessAudio(audioData []byte) error {
+	started := w.voice.Add(audioData)
+	voiced := w.voice.Speaking()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	if !w.speaking {
+		w.preroll = append(w.preroll, audioData...)
+		if keep := w.bytesFor(whisperPrerollMs); len(w.preroll) > keep {
+			w.preroll = append(w.preroll[:0], w.preroll[len(w.preroll)-keep:]...)
+		}
+		if !started {
+			return nil
+		}
+		// The detector needs a moment to be sure; the preroll holds the onset
+		w.speaking = true
+		w.segment = append(w.segment[:0], w.preroll...)
+		w.preroll = w.preroll[:0]
+		w.quiet = 0
+		w.sincePartial = 0
+		return nil
+	}
+
+	w.segment = append(w.segment, audioData...)
+	w.sincePartial += len(audioData)
+	if voiced {
+		w.quiet = 0
+	} else {
+		w.quiet += len(audioData)
+	}
+	switch {
+	case w.quiet >= w.bytesFor(w.settings.SilenceMs):
+		w.speaking = false
+		w.send(whisperJob{pcm: w.takeSegment(), final: true})
+	case len(w.segment) >= w.bytesFor(w.settings.MaxSegmentMs):
+		// Still talking; carry on in a new segment
+		w.send(whisperJob{pcm: w.takeSegment(), final: true})
+	case w.settings.PartialMs > 0 && w.sincePartial >= w.bytesFor(w.settings.PartialMs) && !w.busy.Load():
+		// Partials are skipped while the server is still busy; a newer one
+		// supersedes them anyway
+		w.sincePartial = 0
+		w.send(whisperJob{pcm: append([]byte(nil), w.segment...)})
+	}
+	return nil
+}
+
+// takeSegment returns the utterance in progress and starts a new one; mu
+// must be held
+func (w *WhisperLocalTranscriber) takeSegment() []byte {
+	pcm := w.segment
+	w.segment = nil
+	w.sincePartial = 0
+	return pcm
+}
+
+// send queues a job for the worker; mu must be held
+func (w *WhisperLocalTranscriber) send(job whisperJob) {
+	w.busy.Store(true)
+	w.jobs <- job
+}
+
+// work runs requests in order until the job queue is closed
+func (w *WhisperLocalTranscriber) work() {
+	defer w.wg.Done()
+	for job := range w.jobs {
+		if job.marker != "" {
+			w.appendText(job.marker)
+		} else if job.final || len(w.jobs) == 0 {
+			w.transcribe(job)
+		}
+		w.busy.Store(len(w.jobs) > 0)
+	}
+}
+
+// transcribe sends one segment to the server and delivers its text
+func (w *WhisperLocalTranscriber) transcribe(job whisperJob) {
+	text, err := w.client.Transcribe(encodeWAV(job.pcm, w.sampleRate))
+	if err != nil {
+		log.Printf("Whisper transcription failed: %v", err)
+		return
+	}
+	if text == "" {
+		return
+	}
+	if job.final {
+		w.appendText(text)
+	}
+	w.results.push(TranscriptionResult{Text: text, IsFinal: job.final})
+}
+
+// appendText adds text to the full transcript
+func (w *WhisperLocalTranscriber) appendText(text string) {
+	w.textMu.Lock()
+	defer w.textMu.Unlock()
+	if w.fullText.Len() > 0 {
+		w.fullText.WriteString(" ")
+	}
+	w.fullText.WriteString(text)
+}
+
+func (w *WhisperLocalTranscriber) Results() <-chan TranscriptionResult {
+	return w.results.Results()
+}
+
+func (w *WhisperLocalTranscriber) GetFullTranscript() string {
+	w.textMu.Lock()
+	defer w.textMu.Unlock()
+	return w.fullText.String()
+}
+
+// AddMarker adds marker to the transcript after the text of the utterances
+// already ended, even if they are still being transcribed
+func (w *WhisperLocalTranscriber) AddMarker(marker string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.send(whisperJob{marker: marker})
+	}
+}
+
+// Close transcribes the utterance in progress and waits for the requests
+// already queued
+func (w *WhisperLocalTranscriber) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	if w.speaking && len(w.segment) > 0 {
+		w.send(whisperJob{pcm: w.takeSegment(), final: true})
+	}
+	close(w.jobs)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	w.results.close()
+	return nil
+}
+
+// encodeWAV wraps mono 16-bit PCM at sampleRate in a WAV header
+func encodeWAV(pcm []byte, sampleRate int) []byte {
+	out := make([]byte, 44, 44+len(pcm))
+	copy(out[0:], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(36+len(pcm)))
+	copy(out[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(out[16:], 16)
+	binary.LittleEndian.PutUint16(out[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(out[22:], 1)
+	binary.LittleEndian.PutUint32(out[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(out[28:], uint32(sampleRate*2))
+	binary.LittleEndian.PutUint16(out[32:], 2)
+	binary.LittleEndian.PutUint16(out[34:], 16)
+	copy(out[36:], "data")
+	binary.LittleEndian.PutUint32(out[40:], uint32(len(pcm)))
+	return append(out, pcm...)
+}
@@ -0,0 +1,137 @@
+package transcriber
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// speechFrame returns 20ms of a loud tone at 8kHz, or silence
+func speechFrame(loud bool) []byte {
+	frame := make([]byte, 320)
+	if loud {
+		for i := 0; i < 160; i++ {
+			v := int16(8000 * math.Sin(2*math.Pi*300*float64(i)/8000))
+			binary.LittleEndian.PutUint16(frame[i*2:], uint16(v))
+		}
+	}
+	return frame
+}
+
+func TestWhisperLocal(t *testing.T) {
+	var mu sync.Mutex
+	var lengths []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/inference" {
+			http.NotFound(w, r)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		wav, _ := io.ReadAll(file)
+		if binary.LittleEndian.Uint32(wav[24:]) != 8000 {
+			http.Error(w, "wrong sample rate", http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		lengths = append(lengths, len(wav)-44)
+		n := len(lengths)
+		mu.Unlock()
+		fmt.Fprintf(w, `{"text": " segment %d "}`, n)
+	}))
+	defer srv.Close()
+
+	factory, ok := Lookup("whisper_local")
+	if !ok {
+		t.Fatal("whisper_local is not registered")
+	}
+	if _, err := factory(8000, nil); err == nil {
+		t.Error("a config without url should be rejected")
+	}
+	tr, err := factory(8000, map[string]any{"url": srv.URL, "path": "/inference", "silence_ms": 300})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var results []TranscriptionResult
+	done := make(chan struct{})
+	go func() {
+		for r := range tr.Results() {
+			results = append(results, r)
+		}
+		close(done)
+	}()
+	send := func(loud bool, ms int) {
+		for i := 0; i < ms/20; i++ {
+			tr.ProcessAudio(speechFrame(loud))
+		}
+	}
+	send(false, 400)
+	send(true, 1000)
+	send(false, 400) // ends the first utterance
+	tr.AddMarker("[transfer]")
+	send(true, 500) // still in progress at Close
+	tr.Close()
+	<-done
+
+	if len(results) != 2 || results[0].Text != "segment 1" || results[1].Text != "segment 2" || !results[0].IsFinal || !results[1].IsFinal {
+		t.Fatalf("results = %+v", results)
+	}
+	if got := tr.GetFullTranscript(); got != "segment 1 [transfer] segment 2" {
+		t.Errorf("transcript = %q", got)
+	}
+	// The first segment holds the whole utterance, including its onset, and
+	// the pause that ended it, but not the silence before it
+	if first := lengths[0] / 16; first < 1000 || first > 1000+200+300+20 {
+		t.Errorf("first segment is %dms", first)
+	}
+}
+
+func TestWhisperLocalPartials(t *testing.T) {
+	tr := NewWhisperLocalTranscriber(fakeOffline{}, 8000, WhisperLocalSettings{PartialMs: 200, MaxSegmentMs: 1000})
+	send := func(frames int) {
+		for i := 0; i < frames; i++ {
+			tr.ProcessAudio(speechFrame(true))
+		}
+	}
+
+	// Speech is detected after a short onset, then partials follow every 200ms
+	send(25)
+	select {
+	case r := <-tr.Results():
+		if r.IsFinal || r.Text == "" {
+			t.Errorf("first result = %+v, want a partial", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no partial for an utterance in progress")
+	}
+
+	// 1.5s of speech in all is cut into two segments
+	send(50)
+	tr.Close()
+	var finals []string
+	for r := range tr.Results() {
+		if r.IsFinal {
+			finals = append(finals, r.Text)
+		}
+	}
+	if len(finals) != 2 || finals[0] != "1000ms" {
+		t.Errorf("finals = %v, want a 1000ms segment and the rest", finals)
+	}
+}
+
+// fakeOffline transcribes every recording as its length in ms
+type fakeOffline struct{}
+
+func (fakeOffline) Transcribe(wav []byte) (string, error) {
+	return fmt.Sprintf("%dms", (len(wav)-44)/16), nil
+}
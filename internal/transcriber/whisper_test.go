@@ -0,0 +1,38 @@
+package transcriber
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWhisper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		wav, _ := io.ReadAll(file)
+		if string(wav) != "RIFF" || r.FormValue("model") != DefaultWhisperModel || r.FormValue("language") != "en" {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"text": " Yes, I have Medicare. "}`))
+	}))
+	defer srv.Close()
+
+	text, err := NewWhisper(srv.URL, "key", "", "en").Transcribe([]byte("RIFF"))
+	if err != nil || text != "Yes, I have Medicare." {
+		t.Errorf("Transcribe = %q, %v", text, err)
+	}
+	if _, err := NewWhisper(srv.URL, "wrong", "", "en").Transcribe([]byte("RIFF")); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("rejected request: %v", err)
+	}
+}
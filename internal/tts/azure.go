@@ -0,0 +1,48 @@
+package tts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Azure synthesizes speech with the Azure AI Speech REST API
+type Azure struct {
+	Key     string // Speech resource key
+	Region  string // e.g. "eastus"
+	Voice   string // e.g. "en-US-JennyNeural"
+	BaseURL string // https://<Region>.tts.speech.microsoft.com if empty
+	Client  *http.Client
+}
+
+// NewAzure creates an Azure Speech backend
+func NewAzure(key, region, voice string) *Azure {
+	return &Azure{Key: key, Region: region, Voice: voice, Client: newClient()}
+}
+
+// Synthesize asks for raw 8kHz 16-bit mono PCM, which plays as returned
+func (a *Azure) Synthesize(text string) ([]byte, error) {
+	base := a.BaseURL
+	if base == "" {
+		base = fmt.Sprintf("https://%s.tts.speech.microsoft.com", a.Region)
+	}
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(text))
+	lang := "en-US"
+	if parts := strings.SplitN(a.Voice, "-", 3); len(parts) == 3 {
+		lang = parts[0] + "-" + parts[1]
+	}
+	ssml := fmt.Sprintf(`<speak version="1.0" xml:lang="%s"><voice name="%s">%s</voice></speak>`, lang, a.Voice, escaped.String())
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(base, "/")+"/cognitiveservices/v1", strings.NewReader(ssml))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create azure request: %w", err)
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", a.Key)
+	req.Header.Set("Content-Type", "application/ssml+xml")
+	req.Header.Set("X-Microsoft-OutputFormat", "raw-8khz-16bit-mono-pcm")
+	req.Header.Set("User-Agent", "audiosocket-transcriber")
+	return do(a.Client, "azure", req)
+}
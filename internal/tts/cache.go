@@ -0,0 +1,57 @@
+package tts
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+)
+
+// Cache keeps synthesized speech on disk as WAV files, so each text is
+// synthesized once per voice and prompts survive restarts without another
+// API call. The files can be listened to like recorded prompts.
+type Cache struct {
+	dir   string
+	voice string
+	synth Synthesizer
+}
+
+// NewCache caches what synth returns in dir. voice identifies the backend
+// and voice, e.g. "google:en-US-Neural2-F", so changing it synthesizes the
+// prompts again instead of playing the old voice.
+func NewCache(dir, voice string, synth Synthesizer) *Cache {
+	return &Cache{dir: dir, voice: voice, synth: synth}
+}
+
+// Synthesize returns the cached speech for text, synthesizing and storing it
+// on a miss
+func (c *Cache) Synthesize(text string) ([]byte, error) {
+	path := c.Path(text)
+	if pcm, err := audio.LoadWAV(path); err == nil {
+		return pcm, nil
+	}
+	pcm, err := c.synth.Synthesize(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tts cache: %w", err)
+	}
+	// Write and rename so a crash never leaves a truncated prompt behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, audio.EncodeWAV(pcm), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write tts cache: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("failed to write tts cache: %w", err)
+	}
+	return pcm, nil
+}
+
+// Path returns the cache file of text
+func (c *Cache) Path(text string) string {
+	sum := sha256.Sum256([]byte(c.voice + "\x00" + text))
+	return filepath.Join(c.dir, fmt.Sprintf("%x.wav", sum[:16]))
+}
@@ -0,0 +1,44 @@
+package tts
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+)
+
+// Coqui synthesizes speech with a self-hosted Coqui TTS server (tts-server),
+// so prompts never leave the network
+type Coqui struct {
+	BaseURL  string // e.g. "http://localhost:5002"
+	Speaker  string // speaker_id of multi-speaker models
+	Language string // language_id of multilingual models
+	Client   *http.Client
+}
+
+// NewCoqui creates a Coqui TTS backend for the server at baseURL
+func NewCoqui(baseURL, speaker string) *Coqui {
+	return &Coqui{BaseURL: baseURL, Speaker: speaker, Client: newClient()}
+}
+
+// Synthesize fetches a WAV file at the model's sample rate and resamples it
+func (c *Coqui) Synthesize(text string) ([]byte, error) {
+	query := url.Values{"text": {text}}
+	if c.Speaker != "" {
+		query.Set("speaker_id", c.Speaker)
+	}
+	if c.Language != "" {
+		query.Set("language_id", c.Language)
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimRight(c.BaseURL, "/")+"/api/tts?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create coqui request: %w", err)
+	}
+	wav, err := do(c.Client, "coqui", req)
+	if err != nil {
+		return nil, err
+	}
+	return audio.DecodeWAV(wav)
+}
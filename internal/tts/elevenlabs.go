@@ -0,0 +1,64 @@
+package tts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/dsp"
+)
+
+// DefaultElevenLabsURL is the ElevenLabs API
+const DefaultElevenLabsURL = "https://api.elevenlabs.io"
+
+// DefaultElevenLabsModel is the model used when none is set
+const DefaultElevenLabsModel = "eleven_multilingual_v2"
+
+// elevenLabsRate is the PCM sample rate requested from ElevenLabs
+const elevenLabsRate = 16000
+
+// ElevenLabs synthesizes speech with the ElevenLabs text-to-speech API
+type ElevenLabs struct {
+	APIKey  string
+	VoiceID string
+	Model   string // DefaultElevenLabsModel if empty
+	BaseURL string // DefaultElevenLabsURL if empty
+	Client  *http.Client
+}
+
+// NewElevenLabs creates an ElevenLabs backend
+func NewElevenLabs(apiKey, voiceID string) *ElevenLabs {
+	return &ElevenLabs{APIKey: apiKey, VoiceID: voiceID, Client: newClient()}
+}
+
+// Synthesize asks for raw 16kHz PCM and resamples it to 8kHz
+func (e *ElevenLabs) Synthesize(text string) ([]byte, error) {
+	base := e.BaseURL
+	if base == "" {
+		base = DefaultElevenLabsURL
+	}
+	model := e.Model
+	if model == "" {
+		model = DefaultElevenLabsModel
+	}
+	body, err := json.Marshal(map[string]string{"text": text, "model_id": model})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode elevenlabs request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/v1/text-to-speech/%s?output_format=pcm_%d", strings.TrimRight(base, "/"), url.PathEscape(e.VoiceID), elevenLabsRate)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create elevenlabs request: %w", err)
+	}
+	req.Header.Set("xi-api-key", e.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	pcm, err := do(e.Client, "elevenlabs", req)
+	if err != nil {
+		return nil, err
+	}
+	return dsp.ResampleBytes(pcm[:len(pcm)&^1], elevenLabsRate, 8000), nil
+}
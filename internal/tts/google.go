@@ -0,0 +1,87 @@
+package tts
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+)
+
+// DefaultGoogleURL is the Google Cloud Text-to-Speech API
+const DefaultGoogleURL = "https://texttospeech.googleapis.com"
+
+// Google synthesizes speech with Google Cloud Text-to-Speech
+type Google struct {
+	APIKey   string
+	Language string // e.g. "en-US"; "en-US" if empty
+	Voice    string // e.g. "en-US-Neural2-F"; Google picks one for Language if empty
+	BaseURL  string // DefaultGoogleURL if empty
+	Client   *http.Client
+}
+
+// NewGoogle creates a Google Text-to-Speech backend
+func NewGoogle(apiKey, language, voice string) *Google {
+	return &Google{APIKey: apiKey, Language: language, Voice: voice, Client: newClient()}
+}
+
+// Synthesize asks for 8kHz LINEAR16, which Google returns as a WAV file
+func (g *Google) Synthesize(text string) ([]byte, error) {
+	base := g.BaseURL
+	if base == "" {
+		base = DefaultGoogleURL
+	}
+	language := g.Language
+	if language == "" {
+		language = "en-US"
+	}
+	var request struct {
+		Input struct {
+			Text string `json:"text"`
+		} `json:"input"`
+		Voice struct {
+			LanguageCode string `json:"languageCode"`
+			Name         string `json:"name,omitempty"`
+		} `json:"voice"`
+		AudioConfig struct {
+			AudioEncoding   string `json:"audioEncoding"`
+			SampleRateHertz int    `json:"sampleRateHertz"`
+		} `json:"audioConfig"`
+	}
+	request.Input.Text = text
+	request.Voice.LanguageCode = language
+	request.Voice.Name = g.Voice
+	request.AudioConfig.AudioEncoding = "LINEAR16"
+	request.AudioConfig.SampleRateHertz = 8000
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode google request: %w", err)
+	}
+
+	endpoint := strings.TrimRight(base, "/") + "/v1/text:synthesize?key=" + url.QueryEscape(g.APIKey)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create google request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	data, err := do(g.Client, "google", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		AudioContent string `json:"audioContent"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse google response: %w", err)
+	}
+	wav, err := base64.StdEncoding.DecodeString(response.AudioContent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode google audio: %w", err)
+	}
+	return audio.DecodeWAV(wav)
+}
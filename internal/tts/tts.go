@@ -0,0 +1,102 @@
+// Package tts synthesizes flow prompts from text, as an alternative to
+// recording a WAV file for every node. Backends wrap the Google, Azure,
+// ElevenLabs and Coqui speech APIs; Cache keeps what they return on disk so
+// each text is synthesized once per voice.
+package tts
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Providers New accepts
+const (
+	ProviderGoogle     = "google"
+	ProviderAzure      = "azure"
+	ProviderElevenLabs = "elevenlabs"
+	ProviderCoqui      = "coqui"
+)
+
+// Synthesizer turns text into speech as 8kHz mono 16-bit PCM, the format
+// the audio player streams to AudioSocket
+type Synthesizer interface {
+	Synthesize(text string) ([]byte, error)
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Provider string // google, azure, elevenlabs or coqui
+	APIKey   string // google, azure (subscription key), elevenlabs
+	Voice    string // voice name; the ElevenLabs voice ID or the Coqui speaker
+	Language string // google, coqui, e.g. "en-US"
+	Region   string // azure, e.g. "eastus"
+	URL      string // API endpoint; required for coqui, the provider's own for the others if empty
+}
+
+// New creates the backend cfg names
+func New(cfg Config) (Synthesizer, error) {
+	switch cfg.Provider {
+	case ProviderGoogle:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("google tts needs an api_key")
+		}
+		g := NewGoogle(cfg.APIKey, cfg.Language, cfg.Voice)
+		g.BaseURL = cfg.URL
+		return g, nil
+	case ProviderAzure:
+		if cfg.APIKey == "" || (cfg.Region == "" && cfg.URL == "") || cfg.Voice == "" {
+			return nil, fmt.Errorf("azure tts needs an api_key, a region and a voice")
+		}
+		a := NewAzure(cfg.APIKey, cfg.Region, cfg.Voice)
+		a.BaseURL = cfg.URL
+		return a, nil
+	case ProviderElevenLabs:
+		if cfg.APIKey == "" || cfg.Voice == "" {
+			return nil, fmt.Errorf("elevenlabs tts needs an api_key and a voice ID")
+		}
+		e := NewElevenLabs(cfg.APIKey, cfg.Voice)
+		e.BaseURL = cfg.URL
+		return e, nil
+	case ProviderCoqui:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("coqui tts needs the url of its server")
+		}
+		c := NewCoqui(cfg.URL, cfg.Voice)
+		c.Language = cfg.Language
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown tts provider %q", cfg.Provider)
+	}
+}
+
+// defaultTimeout bounds one synthesis request
+const defaultTimeout = 15 * time.Second
+
+func newClient() *http.Client {
+	return &http.Client{Timeout: defaultTimeout}
+}
+
+// do sends req and returns the response body, turning a non-2xx answer into
+// an error naming provider
+func do(client *http.Client, provider string, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = newClient()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to synthesize via %s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("failed to synthesize via %s: %s: %s", provider, resp.Status, strings.TrimSpace(string(body)))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s audio: %w", provider, err)
+	}
+	return body, nil
+}
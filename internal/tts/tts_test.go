@@ -0,0 +1,175 @@
+package tts
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+)
+
+// pcm returns n samples of a non-silent 16-bit signal
+func pcm(n int) []byte {
+	out := make([]byte, 2*n)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(out[2*i:], uint16(int16((i%16-8)*1000)))
+	}
+	return out
+}
+
+func TestGoogle(t *testing.T) {
+	var got map[string]map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/text:synthesize" || r.URL.Query().Get("key") != "k" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		json.NewEncoder(w).Encode(map[string]string{"audioContent": base64.StdEncoding.EncodeToString(audio.EncodeWAV(pcm(800)))})
+	}))
+	defer srv.Close()
+
+	g := NewGoogle("k", "en-GB", "en-GB-Neural2-A")
+	g.BaseURL = srv.URL
+	out, err := g.Synthesize("Hello there")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1600 {
+		t.Errorf("got %d bytes of PCM, want 1600", len(out))
+	}
+	if got["input"]["text"] != "Hello there" || got["voice"]["name"] != "en-GB-Neural2-A" || got["audioConfig"]["sampleRateHertz"] != float64(8000) {
+		t.Errorf("request = %v", got)
+	}
+
+	g.APIKey = "wrong"
+	if _, err := g.Synthesize("Hello"); err == nil {
+		t.Error("a rejected request should return an error")
+	}
+}
+
+func TestAzure(t *testing.T) {
+	var ssml, format, key string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		ssml, format, key = string(data), r.Header.Get("X-Microsoft-OutputFormat"), r.Header.Get("Ocp-Apim-Subscription-Key")
+		w.Write(pcm(400))
+	}))
+	defer srv.Close()
+
+	a := NewAzure("k", "eastus", "en-US-JennyNeural")
+	a.BaseURL = srv.URL
+	out, err := a.Synthesize("Rates < 5% & more")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 800 || format != "raw-8khz-16bit-mono-pcm" || key != "k" {
+		t.Errorf("got %d bytes, format %q, key %q", len(out), format, key)
+	}
+	if !strings.Contains(ssml, `<voice name="en-US-JennyNeural">Rates &lt; 5% &amp; more</voice>`) {
+		t.Errorf("ssml = %s", ssml)
+	}
+}
+
+func TestElevenLabs(t *testing.T) {
+	var path, format, model string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		path, format, model = r.URL.Path, r.URL.Query().Get("output_format"), body["model_id"]
+		w.Write(pcm(1600))
+	}))
+	defer srv.Close()
+
+	e := NewElevenLabs("k", "voice1")
+	e.BaseURL = srv.URL
+	out, err := e.Synthesize("Hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/text-to-speech/voice1" || format != "pcm_16000" || model != DefaultElevenLabsModel {
+		t.Errorf("request %s %s %s", path, format, model)
+	}
+	// 16kHz resampled to 8kHz
+	if n := len(out) / 2; n < 790 || n > 810 {
+		t.Errorf("got %d samples, want about 800", n)
+	}
+}
+
+func TestCoqui(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write(audio.EncodeWAV(pcm(400)))
+	}))
+	defer srv.Close()
+
+	c := NewCoqui(srv.URL, "p225")
+	out, err := c.Synthesize("Hi there")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 800 || query != "speaker_id=p225&text=Hi+there" {
+		t.Errorf("got %d bytes for %s", len(out), query)
+	}
+}
+
+type countingSynth struct{ calls int }
+
+func (s *countingSynth) Synthesize(text string) ([]byte, error) {
+	s.calls++
+	return pcm(len(text) * 10), nil
+}
+
+func TestCache(t *testing.T) {
+	dir := t.TempDir()
+	synth := &countingSynth{}
+	c := NewCache(dir, "google:a", synth)
+	first, err := c.Synthesize("Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(c.Path("Hello")); err != nil {
+		t.Fatalf("cache file: %v", err)
+	}
+
+	// Another cache over the same directory, e.g. after a restart
+	again, err := NewCache(dir, "google:a", synth).Synthesize("Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synth.calls != 1 || string(again) != string(first) {
+		t.Errorf("cached text synthesized %d times", synth.calls)
+	}
+
+	// Another voice is synthesized again
+	if _, err := NewCache(dir, "google:b", synth).Synthesize("Hello"); err != nil || synth.calls != 2 {
+		t.Errorf("new voice: %v, %d calls", err, synth.calls)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		cfg Config
+		ok  bool
+	}{
+		{Config{Provider: "google", APIKey: "k"}, true},
+		{Config{Provider: "google"}, false},
+		{Config{Provider: "azure", APIKey: "k", Region: "eastus", Voice: "en-US-JennyNeural"}, true},
+		{Config{Provider: "azure", APIKey: "k", Region: "eastus"}, false},
+		{Config{Provider: "elevenlabs", APIKey: "k", Voice: "v"}, true},
+		{Config{Provider: "coqui", URL: "http://localhost:5002"}, true},
+		{Config{Provider: "coqui"}, false},
+		{Config{Provider: "polly"}, false},
+	} {
+		if _, err := New(tc.cfg); (err == nil) != tc.ok {
+			t.Errorf("New(%+v) error = %v", tc.cfg, err)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+// Package bot exposes the AudioSocket transcription and call-flow server as
+// a library so other Go programs can embed it instead of running cmd/server.
+//
+// A minimal embedding looks like:
+//
+//	b, err := bot.New(
+//		bot.WithListenAddr("0.0.0.0", 9019),
+//		bot.WithVosk("ws://localhost:2700", 8000),
+//		bot.WithAudioDir("./audios"),
+//		bot.WithFlow("./config/flow.json", "./config/interrupts.yaml"),
+//	)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	go b.Start()
+//	defer b.Stop()
+//
+// Custom business logic can be attached with WithHooks without forking the
+// flow engine.
+package bot
+
+import (
+	"time"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/audio"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/notify"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/qa"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/server"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/transcriber"
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/tts"
+)
+
+// Hooks receives flow engine events; see flow.Hooks for the callback semantics
+type Hooks = flow.Hooks
+
+// NopHooks can be embedded to implement only some Hooks callbacks
+type NopHooks = flow.NopHooks
+
+// FlowNode is a single step of a call flow as passed to Hooks
+type FlowNode = flow.FlowNode
+
+// ResponseType is the classification of a caller answer
+type ResponseType = flow.ResponseType
+
+// Classification values passed to and returned from Hooks.OnClassify
+const (
+	ResponsePositive = flow.ResponsePositive
+	ResponseNegative = flow.ResponseNegative
+	ResponseUnknown  = flow.ResponseUnknown
+)
+
+// Transcriber is the interface implemented by transcription providers
+type Transcriber = transcriber.Transcriber
+
+// TranscriptionResult is a single partial or final transcription
+type TranscriptionResult = transcriber.TranscriptionResult
+
+// Normalizer rewrites saved transcripts for reading; see WithTranscriptNormalizer
+type Normalizer = transcriber.Normalizer
+
+// NormalizerFunc adapts a function to the Normalizer interface
+type NormalizerFunc = transcriber.NormalizerFunc
+
+// TextNormalizer is the built-in Normalizer: sentence casing, punctuation
+// and spoken numbers as digits
+type TextNormalizer = transcriber.TextNormalizer
+
+// Option configures a Bot
+type Option = server.Option
+
+// VicidialConfig holds Vicidial API credentials and transfer settings
+type VicidialConfig = server.VicidialConfig
+
+// TranscriberFactory creates a transcriber for a new session
+type TranscriberFactory = server.TranscriberFactory
+
+// ProviderFactory creates a transcriber of a registered provider; see
+// RegisterProvider
+type ProviderFactory = transcriber.Factory
+
+// RegisterProvider adds a transcription provider calls can select by name
+// like the built-in ones, configured with WithProviderConfig
+var RegisterProvider = transcriber.Register
+
+// Readers of a provider's settings that accept the types YAML decodes to
+var (
+	ProviderConfigString = transcriber.ConfigString
+	ProviderConfigInt    = transcriber.ConfigInt
+)
+
+// Session is an accepted AudioSocket call as seen by middleware
+type Session = server.Session
+
+// SessionHandler handles an accepted session
+type SessionHandler = server.SessionHandler
+
+// Middleware wraps session handling; see server.Middleware
+type Middleware = server.Middleware
+
+// SessionInfo is the live view of an active session
+type SessionInfo = server.SessionInfo
+
+// SelfTestStep is one scripted caller answer for SelfTest
+type SelfTestStep = server.SelfTestStep
+
+// SelfTestReport is the per-node outcome of SelfTest
+type SelfTestReport = server.SelfTestReport
+
+// LoadShedding holds the latency thresholds of WithLoadShedding
+type LoadShedding = server.LoadShedding
+
+// Bot is an embeddable AudioSocket server
+type Bot struct {
+	srv *server.Server
+}
+
+// New builds a Bot. Without options it listens on 0.0.0.0:9019, transcribes
+// with a local Vosk server and plays prompts from ./audios.
+func New(opts ...Option) (*Bot, error) {
+	srv, err := server.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Bot{srv: srv}, nil
+}
+
+// Start accepts AudioSocket connections until Stop is called
+func (b *Bot) Start() error { return b.srv.Start() }
+
+// Sessions returns snapshots of all active sessions
+func (b *Bot) Sessions() []SessionInfo { return b.srv.Sessions() }
+
+// Stop closes the listener and waits for active sessions to finish
+func (b *Bot) Stop() { b.srv.Stop() }
+
+// Drain stops accepting calls, waits up to the drain timeout (WithDrain) for
+// active sessions to finish and then dispositions and hangs up the rest
+func (b *Bot) Drain() { b.srv.Drain() }
+
+// Ready reports whether the bot accepts new calls
+func (b *Bot) Ready() bool { return b.srv.Ready() }
+
+// SelfTest places one loopback call through a bot built from opts, with a
+// scripted transcriber answering each question, and reports pass/fail per
+// flow node. It makes a deployment smoke test; see server.SelfTest.
+func SelfTest(script []SelfTestStep, timeout time.Duration, opts ...Option) (*SelfTestReport, error) {
+	return server.SelfTest(script, timeout, opts...)
+}
+
+// Options re-exported from the server package
+var (
+	WithListenAddr     = server.WithListenAddr
+	WithVosk           = server.WithVosk
+	WithVoskPool       = server.WithVoskPool
+	WithVoskLocal      = server.WithVoskLocal
+	WithVoskSampleRate = server.WithVoskSampleRate
+	WithSampleRate     = server.WithSampleRate
+	WithAssemblyAI     = server.WithAssemblyAI
+	WithTranscriber    = server.WithTranscriber
+	WithAudioDir       = server.WithAudioDir
+	WithFlow           = server.WithFlow
+	WithOutput         = server.WithOutput
+	WithSubtitles      = server.WithSubtitles
+	WithAudioSpill     = server.WithAudioSpill
+	WithVicidial       = server.WithVicidial
+	WithRedis          = server.WithRedis
+	WithHooks          = server.WithHooks
+	WithCalendar       = server.WithCalendar
+	WithNotifier       = server.WithNotifier
+	WithWebhookOutbox  = server.WithWebhookOutbox
+	WithMiddleware     = server.WithMiddleware
+	WithAdminAddr      = server.WithAdminAddr
+	WithAuditLog       = server.WithAuditLog
+	WithAdminAuth      = server.WithAdminAuth
+	WithAdminTLS       = server.WithAdminTLS
+	WithProxyProtocol  = server.WithProxyProtocol
+
+	WithOneWayAudioDetection = server.WithOneWayAudioDetection
+	WithReadTimeout          = server.WithReadTimeout
+	WithHeartbeat            = server.WithHeartbeat
+	WithKeepAlive            = server.WithKeepAlive
+	WithLiveListen           = server.WithLiveListen
+	WithWorkerPool           = server.WithWorkerPool
+	WithListeners            = server.WithListeners
+	WithFleet                = server.WithFleet
+	WithDrain                = server.WithDrain
+	WithLoadShedding         = server.WithLoadShedding
+	WithDuplicatePolicy      = server.WithDuplicatePolicy
+	WithReconciler           = server.WithReconciler
+	WithDNCList              = server.WithDNCList
+	WithFeatureFlags         = server.WithFeatureFlags
+	WithDebugSampling        = server.WithDebugSampling
+	WithProviderCapture      = server.WithProviderCapture
+	WithEscalationDetection  = server.WithEscalationDetection
+
+	WithProvider          = server.WithProvider
+	WithProviderConfig    = server.WithProviderConfig
+	WithProviderSelection = server.WithProviderSelection
+
+	WithBackgroundClassification = server.WithBackgroundClassification
+	WithMachineDetection         = server.WithMachineDetection
+	WithToneDetection            = server.WithToneDetection
+	WithSITDisposition           = server.WithSITDisposition
+	WithSeed                     = server.WithSeed
+	WithRecentCallFlow           = server.WithRecentCallFlow
+	WithTransferTracking         = server.WithTransferTracking
+	WithAgentCache               = server.WithAgentCache
+	WithStandbyTranscribers      = server.WithStandbyTranscribers
+	WithPromptCheck              = server.WithPromptCheck
+	WithTranscriptNormalizer     = server.WithTranscriptNormalizer
+	WithTTS                      = server.WithTTS
+	WithRetranscription          = server.WithRetranscription
+	WithQARubric                 = server.WithQARubric
+)
+
+// Calendar books confirmed callbacks; see WithCalendar
+type Calendar = flow.Calendar
+
+// Booking is a confirmed callback passed to Calendar.Book
+type Booking = flow.Booking
+
+// NewICalendar creates a Calendar booking into a CalDAV collection (a URL
+// ending in "/") or posting to an iCalendar webhook
+var NewICalendar = flow.NewICalendar
+
+// NotifySender delivers the SMS or email of a flow's notify action; see
+// WithNotifier
+type NotifySender = notify.Sender
+
+// NotifyMessage is an SMS or email passed to NotifySender.Send
+type NotifyMessage = notify.Message
+
+// Channels for WithNotifier
+const (
+	NotifySMS     = notify.SMS
+	NotifyEmail   = notify.Email
+	NotifyWebhook = notify.Webhook
+)
+
+// Built-in senders for WithNotifier
+var (
+	NewTwilio   = notify.NewTwilio
+	NewSendGrid = notify.NewSendGrid
+)
+
+// OfflineTranscriber re-transcribes saved recordings after the call; see
+// WithRetranscription
+type OfflineTranscriber = transcriber.OfflineTranscriber
+
+// NewWhisper creates an OfflineTranscriber for the OpenAI transcriptions
+// API or a self-hosted server speaking it
+var NewWhisper = transcriber.NewWhisper
+
+// QARubric is what calls are scored against; see WithQARubric
+type QARubric = qa.Rubric
+
+// LoadQARubric reads a YAML rubric such as config/qa.yaml
+var LoadQARubric = qa.LoadRubric
+
+// Synthesizer turns a node's tts_text into speech; see WithTTS
+type Synthesizer = tts.Synthesizer
+
+// TTSConfig selects and configures a built-in Synthesizer for NewTTS
+type TTSConfig = tts.Config
+
+// Built-in speech synthesis: NewTTS creates a Google, Azure, ElevenLabs or
+// Coqui backend and NewTTSCache keeps what it returns on disk
+var (
+	NewTTS      = tts.New
+	NewTTSCache = tts.NewCache
+)
+
+// AdminCredential is an admin API key and its role
+type AdminCredential = server.AdminCredential
+
+// Admin API roles for AdminCredential and WithAdminTLS
+const (
+	RoleRead    = server.RoleRead
+	RoleControl = server.RoleControl
+)
+
+// EscalationSettings tunes WithEscalationDetection; zero values use defaults
+type EscalationSettings = audio.EscalationSettings
+
+// ProviderRule selects a transcription provider per campaign/language
+type ProviderRule = server.ProviderRule
+
+// FeatureFlags enables experimental behaviors per campaign
+type FeatureFlags = server.FeatureFlags
+
+// Feature flag names for FeatureFlags
+const (
+	FeatureEagerTransitions = flow.FeatureEagerTransitions
+	FeatureBargeIn          = flow.FeatureBargeIn
+)
+
+// Duplicate session policies for WithDuplicatePolicy
+const (
+	DuplicateReject = server.DuplicateReject
+	DuplicateAdopt  = server.DuplicateAdopt
+)
+
+// Built-in middleware
+var (
+	AllowList             = server.AllowList
+	MaxConcurrentSessions = server.MaxConcurrentSessions
+	LogSessions           = server.LogSessions
+)
@@ -0,0 +1,74 @@
+package flow_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/amanullahtanweer/audiosocket-transcriber/pkg/flow"
+)
+
+// chatSession runs a flow as a text chat: prompts are printed and the
+// caller's replies to each prompt come from a script
+type chatSession struct {
+	replies map[string]string // prompt -> what the caller types
+	said    chan flow.TranscriptionResult
+	vars    map[string]string
+}
+
+func (c *chatSession) GetID() string { return "chat-1" }
+
+func (c *chatSession) PlayAudio(prompt string) error {
+	fmt.Println("BOT:", prompt)
+	if reply, ok := c.replies[prompt]; ok {
+		fmt.Println("CALLER:", reply)
+		c.said <- flow.TranscriptionResult{Text: reply, IsFinal: true}
+	}
+	return nil
+}
+
+func (c *chatSession) StopAudio() error   { return nil }
+func (c *chatSession) StopTranscription() {}
+func (c *chatSession) GetTranscriptionResults() <-chan flow.TranscriptionResult {
+	return c.said
+}
+func (c *chatSession) ReportStatus(status, reason string) error     { return nil }
+func (c *chatSession) CheckForInterrupt(text string) (string, bool) { return "", false }
+func (c *chatSession) EndCall() error                               { fmt.Println("-- call ended"); return nil }
+func (c *chatSession) GetVar(key string) (string, bool)             { v, ok := c.vars[key]; return v, ok }
+func (c *chatSession) SetVar(key, value string)                     { c.vars[key] = value }
+
+func Example() {
+	dir, err := os.MkdirTemp("", "chat")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "flow.json")
+	os.WriteFile(path, []byte(`{"nodes": [
+		{"id": "start", "type": "question", "audio_file": "interested.wav",
+		 "transitions": {"positive": "thanks", "negative": "end_call"}},
+		{"id": "thanks", "type": "hangup", "audio_file": "thanks.wav"},
+		{"id": "end_call", "type": "hangup", "audio_file": "bye.wav"}
+	]}`), 0644)
+
+	session := &chatSession{
+		replies: map[string]string{"interested.wav": "yes please"},
+		said:    make(chan flow.TranscriptionResult, 1),
+		vars:    map[string]string{},
+	}
+	engine, err := flow.NewEngine(session, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer engine.Close()
+	if err := engine.Start(); err != nil {
+		log.Fatal(err)
+	}
+	// Output:
+	// BOT: interested.wav
+	// CALLER: yes please
+	// BOT: thanks.wav
+	// -- call ended
+}
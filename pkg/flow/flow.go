@@ -0,0 +1,157 @@
+// Package flow exposes the call-flow engine so it can be driven over
+// transports other than AudioSocket, e.g. a chat simulator for testing
+// flows or a SIP stack. The server in pkg/bot uses the same engine with
+// its own AudioSocket Session.
+//
+// An integrator implements Session for its transport, creates an Engine for
+// a flow file and runs it:
+//
+//	engine, err := flow.NewEngine(mySession, "./config/flow.json")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer engine.Close()
+//	engine.AddHooks(myHooks)
+//	if err := engine.Start(); err != nil {
+//		log.Print(err)
+//	}
+//
+// Start runs the flow on the calling goroutine until it ends in a transfer
+// or hangup, so each conversation gets its own goroutine and Engine. The
+// engine calls Session methods from that goroutine, except that question
+// prompts are played from a goroutine of their own so the caller can answer
+// while they play.
+package flow
+
+import (
+	"github.com/amanullahtanweer/audiosocket-transcriber/internal/flow"
+)
+
+// Session is the conversation an Engine drives: it plays the flow's
+// prompts, delivers what the caller says and carries the call's variables.
+type Session interface {
+	// GetID identifies the conversation in logs, session logs and Vicidial
+	// requests
+	GetID() string
+
+	// PlayAudio plays a prompt and returns once it has finished or was
+	// stopped by StopAudio. filename is a node's audio_file, or
+	// "<audio_file>@1.05x" for nodes with a speed; a transport without audio
+	// can show the node's content instead.
+	PlayAudio(filename string) error
+
+	// StopAudio cuts the current prompt short when the caller answers, is
+	// interrupted or the question times out. It must be safe to call
+	// while nothing plays. Sessions implementing PlaybackSession are stopped
+	// by playback ID instead.
+	StopAudio() error
+
+	// StopTranscription is called when the flow hands the call to an agent
+	// and stops listening
+	StopTranscription()
+
+	// GetTranscriptionResults returns the caller's speech. It is called each
+	// time the flow waits for an answer and may return the same channel.
+	// Final results are classified as the answer; partials (IsFinal false)
+	// keep the question's timeout from running out while the caller talks.
+	GetTranscriptionResults() <-chan TranscriptionResult
+
+	// ReportStatus is reserved for status updates; the engine does not call
+	// it yet
+	ReportStatus(status, reason string) error
+
+	// CheckForInterrupt looks for an interrupt (e.g. "dnc", "not_interested",
+	// "robot", "callback") in a final answer. When found, the flow moves to
+	// the node with the interrupt's key as its ID.
+	CheckForInterrupt(text string) (string, bool)
+
+	// EndCall hangs up after a hangup node
+	EndCall() error
+
+	// GetVar and SetVar read and write the call's variables, such as
+	// lead_id, campaign_id or digits a caller entered. Flows use them in
+	// scripts, notifications and templates.
+	GetVar(key string) (string, bool)
+	SetVar(key, value string)
+}
+
+// The engine accepts any Session
+var _ flow.Session = Session(nil)
+
+// Optional interfaces a Session can implement to feed the engine more
+// signals; sessions without them never produce those events
+type (
+	// DigitSession delivers DTMF key presses to collect_digits nodes
+	DigitSession = flow.DigitSession
+	// EscalationSession delivers "agitated" or "shouting" as the caller escalates
+	EscalationSession = flow.EscalationSession
+	// ToneSession delivers "beep", "fax" or "sit" tones heard on the call
+	ToneSession = flow.ToneSession
+	// FeatureSession turns feature flags such as FeatureBargeIn on per call
+	FeatureSession = flow.FeatureSession
+	// BargeInSession plays prompts that stop as soon as the caller speaks,
+	// for nodes with barge_in
+	BargeInSession = flow.BargeInSession
+	// PlaybackSession identifies each prompt it plays, so the engine stops
+	// only the prompts it started
+	PlaybackSession = flow.PlaybackSession
+	// PlaybackID identifies one prompt of a PlaybackSession
+	PlaybackID = flow.PlaybackID
+)
+
+// Feature flags resolved through FeatureSession
+const (
+	FeatureEagerTransitions = flow.FeatureEagerTransitions
+	FeatureBargeIn          = flow.FeatureBargeIn
+)
+
+// TranscriptionResult is a partial or final transcript of the caller
+type TranscriptionResult = flow.TranscriptionResult
+
+// Engine runs one conversation through a flow
+type Engine = flow.FlowEngine
+
+// Node is a single step of a flow
+type Node = flow.FlowNode
+
+// Hooks receives engine events; embed NopHooks to implement only some
+type Hooks = flow.Hooks
+
+// NopHooks implements every Hooks callback as a no-op
+type NopHooks = flow.NopHooks
+
+// ResponseType is the classification of a caller answer
+type ResponseType = flow.ResponseType
+
+// Classifications of caller answers
+const (
+	ResponsePositive = flow.ResponsePositive
+	ResponseNegative = flow.ResponseNegative
+	ResponseUnknown  = flow.ResponseUnknown
+)
+
+// SessionLogger writes a structured JSONL log of a conversation
+type SessionLogger = flow.SessionLogger
+
+// NewSessionLogger creates the session log of conversation id in dir; pass
+// it to Engine.SetSessionLogger
+var NewSessionLogger = flow.NewSessionLogger
+
+// APIClient reports dispositions and transfers to Vicidial
+type APIClient = flow.APIClient
+
+// NewVicidialClient creates the client to pass to Engine.SetAPIClient
+var NewVicidialClient = flow.NewVicidialClient
+
+// NewEngine loads the flow at path, and the flows it chains to, for
+// session. Unlike the server's engines it does not call Vicidial until
+// Engine.SetAPIClient is given a client, so transfers and hangups only end
+// the flow and call the session.
+func NewEngine(session Session, path string) (*Engine, error) {
+	engine, err := flow.NewFlowEngine(session, path)
+	if err != nil {
+		return nil, err
+	}
+	engine.SetAPIClient(nil)
+	return engine, nil
+}